@@ -0,0 +1,214 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var (
+	// ddlTablePattern finds the target of CREATE TABLE and ALTER TABLE, which SQLite compiles
+	// into edits of sqlite_master without opening the table itself
+	ddlTablePattern = regexp.MustCompile(`(?is)^\s*(?:ALTER\s+TABLE|CREATE\s+(?:TEMP\s+|TEMPORARY\s+|VIRTUAL\s+)?TABLE(?:\s+IF\s+NOT\s+EXISTS)?)\s+` + identifierPattern)
+	// pragmaArgumentPattern finds the table or index argument of pragmas such as table_info
+	pragmaArgumentPattern = regexp.MustCompile(`(?is)^\s*PRAGMA\s+(?:\w+\s*\.\s*)?\w+\s*\(\s*` + identifierPattern + `\s*\)`)
+	// tableReferencePattern finds names following keywords that introduce a table; it is
+	// only used when a statement cannot be compiled, e.g. one referencing a table created
+	// earlier in the same transaction
+	tableReferencePattern = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|INTO|UPDATE|TABLE(?:\s+IF\s+(?:NOT\s+)?EXISTS)?)\s+` + identifierPattern)
+)
+
+// identifierPattern matches a possibly schema-qualified, possibly quoted identifier, capturing
+// the last part. Single-quoted names are accepted since SQLite allows them in pragma arguments.
+const identifierPattern = `(?:(?:"(?:[^"]|"")+"|` + "`[^`]+`" + `|\[[^\]]+\]|[A-Za-z_][\w$]*)\s*\.\s*)?("(?:[^"]|"")+"|` + "`[^`]+`" + `|\[[^\]]+\]|'(?:[^']|'')+'|[A-Za-z_][\w$]*)`
+
+// unquoteIdentifier strips SQL identifier quoting
+func unquoteIdentifier(name string) string {
+	if len(name) >= 2 {
+		switch name[0] {
+		case '"':
+			return strings.ReplaceAll(name[1:len(name)-1], `""`, `"`)
+		case '\'':
+			return strings.ReplaceAll(name[1:len(name)-1], "''", "'")
+		case '`':
+			return name[1 : len(name)-1]
+		case '[':
+			return name[1 : len(name)-1]
+		}
+	}
+	return name
+}
+
+// ReferencedTables returns the tables a statement reads or writes, without running it. The
+// tables are taken from the cursors opened by the compiled statement, so views, subqueries,
+// and triggers resolve to the tables they touch, in the main database and in attached ones
+// alike; tables of attached databases are returned by name, without the schema. Pragma
+// functions such as pragma_table_info count as reading the table they are given. Statements
+// that fail to compile fall back to a scan for table names after FROM, JOIN, INTO, UPDATE, and
+// TABLE. args are values for the statement's placeholders, if it has any.
+func (s *SQLiteDB) ReferencedTables(statement string, args ...interface{}) ([]string, error) {
+	// Database numbers in the program belong to the connection that compiles it
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	byName, byRootPage, err := schemaObjects(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	tables := make(map[string]bool)
+	// addName records a table named in the statement text; index names map to their table
+	addName := func(name string) {
+		if table, ok := byName[strings.ToLower(name)]; ok {
+			name = table
+		}
+		tables[name] = true
+	}
+
+	if match := ddlTablePattern.FindStringSubmatch(statement); match != nil {
+		addName(unquoteIdentifier(match[1]))
+	}
+	if match := pragmaArgumentPattern.FindStringSubmatch(statement); match != nil {
+		addName(unquoteIdentifier(match[1]))
+	}
+	tokens := tokenizeSQL(statement)
+	for i := 0; i+2 < len(tokens); i++ {
+		if tokens[i].kind == 'i' && strings.HasPrefix(strings.ToLower(tokens[i].text), "pragma_") &&
+			tokens[i+1].kind == 'o' && tokens[i+1].text == "(" && (tokens[i+2].kind == 'i' || tokens[i+2].kind == 's') {
+			addName(tokens[i+2].text)
+		}
+	}
+
+	program, err := explainProgramOn(ctx, conn, statement, args...)
+	if err != nil {
+		for _, match := range tableReferencePattern.FindAllStringSubmatch(statement, -1) {
+			addName(unquoteIdentifier(match[1]))
+		}
+	}
+	for _, op := range program {
+		var database, rootPage int64
+		switch op.opcode {
+		case "OpenRead", "OpenWrite", "ReopenIdx":
+			database, rootPage = op.p3, op.p2
+		case "Destroy":
+			database, rootPage = op.p3, op.p1
+		case "Clear":
+			database, rootPage = op.p2, op.p1
+		default:
+			continue
+		}
+		pages, ok := byRootPage[database]
+		if !ok {
+			return nil, fmt.Errorf("the statement opens a table in unknown database %d", database)
+		}
+		if name, ok := pages[rootPage]; ok {
+			tables[name] = true
+		}
+	}
+
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// schemaObjects maps the names of the tables, indexes, and views of every database open on
+// conn to their table, and, by database number, the root page of each table and index
+func schemaObjects(ctx context.Context, conn *sql.Conn) (map[string]string, map[int64]map[int64]string, error) {
+	rows, err := conn.QueryContext(ctx, "PRAGMA database_list")
+	if err != nil {
+		return nil, nil, err
+	}
+	schemas := make(map[int64]string)
+	for rows.Next() {
+		var seq int64
+		var name, file string
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			rows.Close()
+			return nil, nil, err
+		}
+		schemas[seq] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	byName := make(map[string]string)
+	byRootPage := make(map[int64]map[int64]string)
+	for seq, schema := range schemas {
+		pages := make(map[int64]string)
+		byRootPage[seq] = pages
+		rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT name, tbl_name, rootpage FROM %s.sqlite_master WHERE type IN ('table', 'index', 'view')", quoteIdentifier(schema)))
+		if err != nil {
+			return nil, nil, err
+		}
+		for rows.Next() {
+			var name, table string
+			var rootPage sql.NullInt64
+			if err := rows.Scan(&name, &table, &rootPage); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			if seq == 0 || byName[strings.ToLower(name)] == "" {
+				byName[strings.ToLower(name)] = table
+			}
+			if rootPage.Int64 > 0 {
+				pages[rootPage.Int64] = table
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return byName, byRootPage, nil
+}
+
+// CheckReadQuery rejects anything but a single statement that only reads: it must start like
+// a query, with SELECT, WITH, or VALUES, and SQLite must report it as not writing. The second
+// check catches a WITH clause leading into an INSERT, UPDATE, or DELETE, which the first
+// keyword does not show. The statement is compiled but not run.
+func (s *SQLiteDB) CheckReadQuery(query string) error {
+	if !IsSingleStatement(query) || statementKind(query) != "read" {
+		return fmt.Errorf("only a single SELECT query is allowed")
+	}
+	conn, err := s.db.Conn(s.ctx())
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	readOnly := false
+	err = conn.Raw(func(driverConn interface{}) error {
+		c, ok := driverConn.(*sqlite3.SQLiteConn)
+		if !ok {
+			return fmt.Errorf("unexpected driver connection %T", driverConn)
+		}
+		stmt, err := c.Prepare(query)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+		readOnly = stmt.(*sqlite3.SQLiteStmt).Readonly()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if !readOnly {
+		return fmt.Errorf("the query writes to the database; only read-only queries are allowed")
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReferencedTables(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE secret (id INTEGER PRIMARY KEY, value TEXT)",
+		"CREATE VIEW user_secrets AS SELECT users.name, secret.value FROM users JOIN secret USING (id)",
+	)
+	// The same file attached again, so its tables have the same root pages
+	if err := db.AttachDatabase(db.GetCurrentDatabasePath(), "y"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		statement string
+		want      []string
+	}{
+		{"SELECT * FROM users", []string{"users"}},
+		{"SELECT * FROM user_secrets", []string{"secret", "users"}},
+		{"SELECT * FROM y.secret", []string{"secret"}},
+		{"SELECT name FROM users WHERE id IN (SELECT id FROM y.secret)", []string{"secret", "users"}},
+		{"DELETE FROM y.secret", []string{"secret"}},
+		{"SELECT * FROM pragma_table_info('secret')", []string{"secret"}},
+		{"SELECT * FROM y.pragma_index_list(\"secret\")", []string{"secret"}},
+		{"PRAGMA y.table_info(secret)", []string{"secret"}},
+	}
+	for _, test := range tests {
+		got, err := db.ReferencedTables(test.statement)
+		if err != nil {
+			t.Errorf("%s: %v", test.statement, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.statement, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,491 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// AffinityWarning describes a comparison whose literal or parameter does not have the type
+// of the column it is compared to
+type AffinityWarning struct {
+	Table    string `json:"table"`
+	Column   string `json:"column"`
+	Affinity string `json:"affinity"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	// ValueType is the storage class of the literal or parameter
+	ValueType string `json:"value_type"`
+	// Severity is "warning" when the comparison may not match the rows it appears to, and
+	// "info" when SQLite's conversion makes it match anyway
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// sqlToken is one token of a statement; identifiers and strings hold their unquoted text
+type sqlToken struct {
+	kind   byte // 'i' identifier, 's' string, 'n' number, 'b' blob, 'p' parameter, 'o' operator
+	text   string
+	quoted bool
+	index  int // argument index of a positional parameter, -1 for named ones
+	start  int // offset of the first rune of the token, quotes included
+	end    int // offset after the last rune of the token
+}
+
+var (
+	// tableAliasPattern finds a table named after FROM, JOIN, UPDATE, or INTO, with its alias
+	tableAliasPattern = regexp.MustCompile(`(?i)\b(?:FROM|JOIN|UPDATE|INTO)\s+` + identifierPattern + `(?:\s+(?:AS\s+)?([A-Za-z_][\w$]*))?`)
+	comparisonOps     = map[string]string{"=": "=", "==": "=", "!=": "!=", "<>": "!=", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+	// bindingOps bind more tightly than comparisons, so an operand next to one is an expression
+	bindingOps = map[string]bool{"+": true, "-": true, "*": true, "/": true, "%": true, "||": true,
+		"&": true, "|": true, "<<": true, ">>": true, "~": true, ".": true, "->": true, "->>": true}
+)
+
+// CheckAffinity looks for comparisons in a statement between a column and a literal or bound
+// parameter whose type does not match the column's type affinity, such as a TEXT column
+// compared to a number, which SQLite then compares as text. Comparisons with =, ==, !=, <>,
+// <, <=, >, >=, IN lists, and BETWEEN are checked when one side is a plain column and the
+// other a literal or parameter; columns are resolved against the tables and views the
+// statement reads, through their aliases, and skipped when ambiguous. Positional parameters
+// are checked against args.
+func (s *SQLiteDB) CheckAffinity(statement string, args ...interface{}) ([]AffinityWarning, error) {
+	tables, err := s.ReferencedTables(statement, args...)
+	if err != nil {
+		return nil, err
+	}
+	// Tables and views named in the statement come first, so that a column they share with
+	// the tables a view reads resolves to the named one
+	var named []string
+	aliases := make(map[string]string)
+	for _, match := range tableAliasPattern.FindAllStringSubmatch(statement, -1) {
+		name := unquoteIdentifier(match[1])
+		if !containsFold(named, name) {
+			named = append(named, name)
+		}
+		aliases[strings.ToLower(name)] = name
+		if match[2] != "" && !sqliteKeywords[strings.ToUpper(match[2])] {
+			aliases[strings.ToLower(match[2])] = name
+		}
+	}
+
+	for _, table := range tables {
+		if !containsFold(named, table) {
+			named = append(named, table)
+		}
+	}
+
+	// Declared affinity of every column, per table
+	type tableColumns struct {
+		table      string
+		affinities map[string]string
+	}
+	var sources []tableColumns
+	for _, table := range named {
+		columns, err := s.GetTableSchema(table)
+		if err != nil {
+			return nil, err
+		}
+		if len(columns) == 0 {
+			continue
+		}
+		byColumn := make(map[string]string)
+		for _, col := range columns {
+			name, _ := col["name"].(string)
+			declared, _ := col["type"].(string)
+			byColumn[strings.ToLower(name)] = columnAffinity(declared)
+		}
+		sources = append(sources, tableColumns{table, byColumn})
+	}
+
+	// resolve finds the table and affinity of a column reference
+	resolve := func(qualifier, column string) (string, string, bool) {
+		key := strings.ToLower(column)
+		if qualifier != "" {
+			table, ok := aliases[strings.ToLower(qualifier)]
+			if !ok {
+				return "", "", false
+			}
+			for _, src := range sources {
+				if strings.EqualFold(src.table, table) {
+					affinity, ok := src.affinities[key]
+					return src.table, affinity, ok
+				}
+			}
+			return "", "", false
+		}
+		var found, affinity string
+		for _, src := range sources {
+			if a, ok := src.affinities[key]; ok {
+				if found == "" {
+					found, affinity = src.table, a
+				} else if a != affinity {
+					return "", "", false
+				}
+			}
+		}
+		return found, affinity, found != ""
+	}
+
+	tokens := tokenizeSQL(statement)
+	var warnings []AffinityWarning
+	check := func(qualifier, column, op string, literal sqlToken) {
+		table, affinity, ok := resolve(qualifier, column)
+		if !ok {
+			return
+		}
+		value, valueType, ok := literalValue(literal, args)
+		if !ok {
+			return
+		}
+		if warning := affinityMismatch(affinity, op, value, valueType); warning != nil {
+			warning.Table, warning.Column = table, column
+			if literal.kind == 'p' {
+				warning.Value = fmt.Sprintf("%s (parameter %s)", value, literal.text)
+			}
+			warnings = append(warnings, *warning)
+		}
+	}
+
+	// The assignments of an UPDATE's SET clause look like comparisons; setDepth is the
+	// parenthesis depth of the SET being read, or -1
+	depth, setDepth := 0, -1
+	for i := 0; i < len(tokens); i++ {
+		switch token := tokens[i]; {
+		case token.kind == 'o' && token.text == "(":
+			depth++
+		case token.kind == 'o' && token.text == ")":
+			depth--
+		case token.kind == 'i' && !token.quoted:
+			switch strings.ToUpper(token.text) {
+			case "SET":
+				setDepth = depth
+			case "WHERE", "FROM", "RETURNING", "ORDER", "LIMIT":
+				if depth <= setDepth {
+					setDepth = -1
+				}
+			}
+		}
+		if setDepth >= 0 && depth == setDepth {
+			continue
+		}
+		if i > 0 && tokens[i-1].kind == 'o' && bindingOps[tokens[i-1].text] {
+			continue
+		}
+
+		// column op literal, column [NOT] IN (literals), column [NOT] BETWEEN literal AND literal
+		if qualifier, column, next, ok := columnAt(tokens, i); ok && next < len(tokens) {
+			if tokens[next].kind == 'o' && comparisonOps[tokens[next].text] != "" && isLiteralOperand(tokens, next+1) {
+				check(qualifier, column, comparisonOps[tokens[next].text], literalToken(tokens, next+1))
+				i = next
+				continue
+			}
+			j := next
+			if tokens[j].kind == 'i' && strings.EqualFold(tokens[j].text, "NOT") && j+1 < len(tokens) {
+				j++
+			}
+			if tokens[j].kind == 'i' && strings.EqualFold(tokens[j].text, "IN") && j+1 < len(tokens) && tokens[j+1].text == "(" {
+				end := j + 2
+				var literals []int
+				for end < len(tokens) && isLiteralOperand(tokens, end) {
+					literals = append(literals, end)
+					end = skipLiteral(tokens, end)
+					if end < len(tokens) && tokens[end].text == "," {
+						end++
+					}
+				}
+				if end < len(tokens) && tokens[end].kind == 'o' && tokens[end].text == ")" {
+					for _, k := range literals {
+						check(qualifier, column, "IN", literalToken(tokens, k))
+					}
+					i = end
+				}
+				continue
+			}
+			if tokens[j].kind == 'i' && strings.EqualFold(tokens[j].text, "BETWEEN") && isLiteralOperand(tokens, j+1) {
+				and := skipLiteral(tokens, j+1)
+				if and < len(tokens) && strings.EqualFold(tokens[and].text, "AND") && isLiteralOperand(tokens, and+1) {
+					check(qualifier, column, "BETWEEN", literalToken(tokens, j+1))
+					check(qualifier, column, "BETWEEN", literalToken(tokens, and+1))
+					i = and + 1
+				}
+				continue
+			}
+		}
+
+		// literal op column
+		if isLiteralOperand(tokens, i) {
+			opIndex := skipLiteral(tokens, i)
+			if opIndex < len(tokens) && tokens[opIndex].kind == 'o' && comparisonOps[tokens[opIndex].text] != "" {
+				if qualifier, column, next, ok := columnAt(tokens, opIndex+1); ok &&
+					(next >= len(tokens) || tokens[next].kind != 'o' || !bindingOps[tokens[next].text]) {
+					check(qualifier, column, mirroredOp(comparisonOps[tokens[opIndex].text]), literalToken(tokens, i))
+					i = next - 1
+				}
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// affinityMismatch returns a warning for comparing a column of the given affinity to a value
+// of the given storage class, or nil when the two agree
+func affinityMismatch(affinity, op, value, valueType string) *AffinityWarning {
+	warning := &AffinityWarning{Affinity: affinity, Operator: op, Value: value, ValueType: valueType, Severity: "warning"}
+	ordering := op != "=" && op != "!=" && op != "IN"
+	numeric := affinity == "INTEGER" || affinity == "REAL" || affinity == "NUMERIC"
+	switch {
+	case valueType == "BLOB" && affinity != "BLOB":
+		warning.Message = fmt.Sprintf("a BLOB literal never equals the text or numbers stored in a %s-affinity column", affinity)
+	case affinity == "TEXT" && (valueType == "INTEGER" || valueType == "REAL"):
+		asText := numberAsText(value, valueType)
+		if ordering {
+			warning.Message = fmt.Sprintf("the column has TEXT affinity, so %s is compared as the text '%s' and the ordering is alphabetical ('9' sorts after '10'); compare to a string or CAST the column to a number", value, asText)
+		} else {
+			warning.Message = fmt.Sprintf("the column has TEXT affinity, so %s is compared as the text '%s' and only matches that exact spelling; write the literal as a string", value, asText)
+		}
+	case numeric && valueType == "TEXT":
+		if isNumericText(value) {
+			warning.Severity = "info"
+			warning.Message = fmt.Sprintf("the column has %s affinity, so the string '%s' is converted to a number before comparing and does match; a numeric literal does not rely on the conversion", affinity, value)
+		} else if ordering {
+			warning.Message = fmt.Sprintf("'%s' is not a number and SQLite orders every number before any text, so the comparison ignores the numeric values of this %s-affinity column", value, affinity)
+		} else {
+			warning.Message = fmt.Sprintf("'%s' is not a number, so it can only match rows that store text in this %s-affinity column", value, affinity)
+		}
+	case affinity == "BLOB" && valueType == "TEXT" && isNumericText(value):
+		warning.Message = "the column has no type affinity, so nothing is converted: the string only matches values stored as text, not the same number stored as an integer or real"
+	case affinity == "BLOB" && (valueType == "INTEGER" || valueType == "REAL"):
+		warning.Message = "the column has no type affinity, so nothing is converted: the number only matches values stored as numbers, not the same digits stored as text"
+	default:
+		return nil
+	}
+	return warning
+}
+
+// isNumericText reports whether numeric affinity would convert a string to a number
+func isNumericText(value string) bool {
+	_, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return err == nil
+}
+
+// numberAsText renders a number the way SQLite converts it to text
+func numberAsText(value, valueType string) string {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	if valueType == "INTEGER" {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	text := strconv.FormatFloat(f, 'g', 15, 64)
+	if !strings.ContainsAny(text, ".e") {
+		text += ".0"
+	}
+	return text
+}
+
+// literalValue returns the text and storage class of a literal, or of the argument bound to a
+// parameter; false for NULL and for parameters that cannot be matched to an argument
+func literalValue(literal sqlToken, args []interface{}) (string, string, bool) {
+	switch literal.kind {
+	case 's':
+		return literal.text, "TEXT", true
+	case 'b':
+		return "x'" + literal.text + "'", "BLOB", true
+	case 'n':
+		if strings.ContainsAny(literal.text, ".eE") && !strings.HasPrefix(strings.ToLower(strings.TrimLeft(literal.text, "-+")), "0x") {
+			return literal.text, "REAL", true
+		}
+		return literal.text, "INTEGER", true
+	case 'p':
+		if literal.index < 0 || literal.index >= len(args) {
+			return "", "", false
+		}
+		value := args[literal.index]
+		switch v := value.(type) {
+		case []byte:
+			return fmt.Sprintf("x'%X'", v), "BLOB", true
+		case string:
+			return v, "TEXT", true
+		case nil:
+			return "", "", false
+		}
+		affinity := valueAffinity(value)
+		return fmt.Sprint(value), affinity, true
+	}
+	return "", "", false
+}
+
+// columnAt reads a possibly qualified column reference at tokens[i], returning the qualifier,
+// the column, and the index after it
+func columnAt(tokens []sqlToken, i int) (string, string, int, bool) {
+	if i >= len(tokens) || tokens[i].kind != 'i' || (!tokens[i].quoted && sqliteKeywords[strings.ToUpper(tokens[i].text)]) {
+		return "", "", 0, false
+	}
+	qualifier, column, next := "", tokens[i].text, i+1
+	for next+1 < len(tokens) && tokens[next].text == "." && tokens[next].kind == 'o' && tokens[next+1].kind == 'i' {
+		qualifier, column = column, tokens[next+1].text
+		next += 2
+	}
+	if next < len(tokens) && tokens[next].kind == 'o' && tokens[next].text == "(" {
+		// A function call
+		return "", "", 0, false
+	}
+	return qualifier, column, next, true
+}
+
+// isLiteralOperand reports whether tokens[i] starts a literal or parameter that is a whole
+// comparison operand, not part of a larger expression
+func isLiteralOperand(tokens []sqlToken, i int) bool {
+	if i >= len(tokens) {
+		return false
+	}
+	if tokens[i].kind == 'o' && (tokens[i].text == "-" || tokens[i].text == "+") {
+		i++
+		if i >= len(tokens) || tokens[i].kind != 'n' {
+			return false
+		}
+	}
+	switch tokens[i].kind {
+	case 's', 'n', 'b', 'p':
+	default:
+		return false
+	}
+	return i+1 >= len(tokens) || tokens[i+1].kind != 'o' || !bindingOps[tokens[i+1].text]
+}
+
+// skipLiteral returns the index after the literal starting at tokens[i]
+func skipLiteral(tokens []sqlToken, i int) int {
+	if tokens[i].kind == 'o' {
+		return i + 2
+	}
+	return i + 1
+}
+
+// literalToken returns the literal starting at tokens[i], folding a sign into a number
+func literalToken(tokens []sqlToken, i int) sqlToken {
+	if tokens[i].kind == 'o' {
+		literal := tokens[i+1]
+		if tokens[i].text == "-" {
+			literal.text = "-" + literal.text
+		}
+		return literal
+	}
+	return tokens[i]
+}
+
+// mirroredOp returns the operator that gives the same result with its operands swapped
+func mirroredOp(op string) string {
+	switch op {
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	}
+	return op
+}
+
+// tokenizeSQL splits a statement into tokens, dropping whitespace and comments. Positional
+// parameters are numbered like SQLite does: ? takes the number after the largest one so far.
+// Token offsets count runes, not bytes.
+func tokenizeSQL(text string) []sqlToken {
+	var tokens []sqlToken
+	parameters := 0
+	runes := []rune(text)
+	isIdentRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$'
+	}
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		start, count := i, len(tokens)
+		switch {
+		case unicode.IsSpace(r):
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for ; i < len(runes) && runes[i] != '\n'; i++ {
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i++
+		case (r == 'x' || r == 'X') && i+1 < len(runes) && runes[i+1] == '\'':
+			start := i + 2
+			for i = start; i < len(runes) && runes[i] != '\''; i++ {
+			}
+			tokens = append(tokens, sqlToken{kind: 'b', text: string(runes[start:min(i, len(runes))])})
+		case r == '\'' || r == '"' || r == '`':
+			// Doubled quotes are escapes
+			var value strings.Builder
+			for i++; i < len(runes); i++ {
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+					} else {
+						break
+					}
+				}
+				value.WriteRune(runes[i])
+			}
+			kind := byte('i')
+			if r == '\'' {
+				kind = 's'
+			}
+			tokens = append(tokens, sqlToken{kind: kind, text: value.String(), quoted: true})
+		case r == '[':
+			start := i + 1
+			for i = start; i < len(runes) && runes[i] != ']'; i++ {
+			}
+			tokens = append(tokens, sqlToken{kind: 'i', text: string(runes[start:min(i, len(runes))]), quoted: true})
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i+1 < len(runes) && (isIdentRune(runes[i+1]) || runes[i+1] == '.' ||
+				((runes[i+1] == '+' || runes[i+1] == '-') && (runes[i] == 'e' || runes[i] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{kind: 'n', text: string(runes[start : i+1])})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i+1 < len(runes) && isIdentRune(runes[i+1]) {
+				i++
+			}
+			tokens = append(tokens, sqlToken{kind: 'i', text: string(runes[start : i+1])})
+		case r == '?' || ((r == ':' || r == '@' || r == '$') && i+1 < len(runes) && isIdentRune(runes[i+1])):
+			start := i
+			for i+1 < len(runes) && isIdentRune(runes[i+1]) {
+				i++
+			}
+			param := sqlToken{kind: 'p', text: string(runes[start : i+1]), index: -1}
+			if param.text == "?" {
+				parameters++
+				param.index = parameters - 1
+			} else if n, err := strconv.Atoi(param.text[1:]); err == nil && r == '?' {
+				parameters = max(parameters, n)
+				param.index = n - 1
+			}
+			tokens = append(tokens, param)
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<>", "<=", ">=", "||", "<<", ">>", "->":
+					op = two
+					i++
+					if two == "->" && i+1 < len(runes) && runes[i+1] == '>' {
+						op = "->>"
+						i++
+					}
+				}
+			}
+			tokens = append(tokens, sqlToken{kind: 'o', text: op})
+		}
+		if len(tokens) > count {
+			tokens[count].start, tokens[count].end = start, min(i+1, len(runes))
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,63 @@
+package database
+
+import "testing"
+
+func TestCheckAffinity(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE accounts (id INTEGER PRIMARY KEY, code TEXT, balance REAL, raw)",
+		"CREATE TABLE owners (id INTEGER PRIMARY KEY, code VARCHAR(10))",
+		"CREATE VIEW rich AS SELECT id, code FROM accounts WHERE balance > 1000",
+	)
+
+	tests := []struct {
+		statement string
+		args      []interface{}
+		// column, value type, and severity of each warning expected
+		want [][3]string
+	}{
+		// Matched comparisons
+		{"SELECT * FROM accounts WHERE code = 'A1' AND id = 5 AND balance > 1.5", nil, nil},
+		{"SELECT * FROM accounts a JOIN owners o ON o.id = a.id WHERE o.code IN ('x', 'y')", nil, nil},
+		{"SELECT * FROM accounts WHERE code = ? AND id = ?", []interface{}{"A1", 5}, nil},
+		{"SELECT * FROM accounts WHERE code = 5 + 1", nil, nil},
+
+		// Mismatched comparisons
+		{"SELECT * FROM accounts WHERE code = 5", nil, [][3]string{{"code", "INTEGER", "warning"}}},
+		{"SELECT * FROM accounts WHERE 10 < code", nil, [][3]string{{"code", "INTEGER", "warning"}}},
+		{"SELECT * FROM accounts WHERE id = '5'", nil, [][3]string{{"id", "TEXT", "info"}}},
+		{"SELECT * FROM accounts WHERE balance > 'abc'", nil, [][3]string{{"balance", "TEXT", "warning"}}},
+		{"SELECT * FROM accounts WHERE raw = '5'", nil, [][3]string{{"raw", "TEXT", "warning"}}},
+		{"SELECT * FROM accounts WHERE code = x'41'", nil, [][3]string{{"code", "BLOB", "warning"}}},
+		{"SELECT * FROM accounts WHERE code = ?", []interface{}{int64(7)}, [][3]string{{"code", "INTEGER", "warning"}}},
+		{"SELECT * FROM owners o WHERE o.code BETWEEN 1 AND 'z'", nil, [][3]string{{"code", "INTEGER", "warning"}}},
+		{"SELECT * FROM rich WHERE code IN (1, 'b')", nil, [][3]string{{"code", "INTEGER", "warning"}}},
+		{"UPDATE accounts SET balance = 0 WHERE code = 1.5", nil, [][3]string{{"code", "REAL", "warning"}}},
+	}
+	for _, test := range tests {
+		warnings, err := db.CheckAffinity(test.statement, test.args...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.statement, err)
+		}
+		if len(warnings) != len(test.want) {
+			t.Errorf("%s: got %d warnings %+v, want %d", test.statement, len(warnings), warnings, len(test.want))
+			continue
+		}
+		for i, want := range test.want {
+			got := warnings[i]
+			if got.Column != want[0] || got.ValueType != want[1] || got.Severity != want[2] || got.Message == "" {
+				t.Errorf("%s: warning %+v, want column %s, value type %s, severity %s", test.statement, got, want[0], want[1], want[2])
+			}
+		}
+	}
+}
+
+func TestAffinityMismatch(t *testing.T) {
+	if warning := affinityMismatch("TEXT", "<", "9", "INTEGER"); warning == nil || warning.Severity != "warning" {
+		t.Fatalf("ordering a TEXT column by a number: %+v", warning)
+	}
+	for _, test := range [][3]string{{"TEXT", "=", "TEXT"}, {"INTEGER", "=", "INTEGER"}, {"REAL", "<", "INTEGER"}, {"NUMERIC", "=", "REAL"}, {"BLOB", "=", "BLOB"}} {
+		if warning := affinityMismatch(test[0], test[1], "1", test[2]); warning != nil {
+			t.Errorf("%s column %s %s: %+v", test[0], test[1], test[2], warning)
+		}
+	}
+}
@@ -0,0 +1,420 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DependentObject describes a view, trigger, or table whose definition references a table or column
+type DependentObject struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	SQL  string `json:"sql"`
+}
+
+// RenameColumnResult reports the outcome of a column rename and its effect on dependent objects
+type RenameColumnResult struct {
+	Dependents []DependentObject `json:"dependents"`
+	Broken     []DependentObject `json:"broken,omitempty"`
+	Fixed      []DependentObject `json:"fixed,omitempty"`
+}
+
+// columnReferencePattern matches a column name as a bare word or a double-quoted identifier
+func columnReferencePattern(columnName string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(quoteIdentifier(columnName))
+	bare := `\b` + regexp.QuoteMeta(columnName) + `\b`
+	return regexp.MustCompile(`(?i)` + quoted + `|` + bare)
+}
+
+// FindColumnDependents lists views and triggers that reference both the table and the column
+func (s *SQLiteDB) FindColumnDependents(tableName, columnName string) ([]DependentObject, error) {
+	rows, err := s.db.QueryContext(s.ctx(), `
+		SELECT type, name, sql FROM sqlite_master
+		WHERE type IN ('view', 'trigger')
+		AND sql IS NOT NULL
+		ORDER BY type, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tablePattern := columnReferencePattern(tableName)
+	columnPattern := columnReferencePattern(columnName)
+
+	var dependents []DependentObject
+	for rows.Next() {
+		var obj DependentObject
+		if err := rows.Scan(&obj.Type, &obj.Name, &obj.SQL); err != nil {
+			return nil, err
+		}
+		if tablePattern.MatchString(obj.SQL) && columnPattern.MatchString(obj.SQL) {
+			dependents = append(dependents, obj)
+		}
+	}
+
+	return dependents, rows.Err()
+}
+
+// RenameColumn renames a column, then checks that dependent views and triggers still resolve.
+// When fixDependents is set, objects that no longer resolve are rewritten and recreated,
+// replacing only the references that belong to the renamed column: those qualified with the
+// table's name or alias, and unqualified ones where the table is the only one in scope.
+func (s *SQLiteDB) RenameColumn(tableName, oldName, newName string, fixDependents bool) (*RenameColumnResult, error) {
+	if err := validateIdentifier(newName); err != nil {
+		return nil, fmt.Errorf("invalid column name: %w", err)
+	}
+	dependents, err := s.FindColumnDependents(tableName, oldName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan dependent objects: %w", err)
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s",
+		quoteIdentifier(tableName), quoteIdentifier(oldName), quoteIdentifier(newName))
+	if _, err := s.db.ExecContext(s.ctx(), query); err != nil {
+		return nil, err
+	}
+
+	result := &RenameColumnResult{Dependents: dependents}
+
+	for _, dep := range dependents {
+		var currentSQL string
+		err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type=? AND name=?", dep.Type, dep.Name).Scan(&currentSQL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reload %s '%s': %w", dep.Type, dep.Name, err)
+		}
+		current := DependentObject{Type: dep.Type, Name: dep.Name, SQL: currentSQL}
+
+		refs := parseDefinition(currentSQL).columnReferences(tableName, oldName)
+		if !s.dependentIsBroken(current, refs) {
+			continue
+		}
+		if !fixDependents || len(refs) == 0 {
+			result.Broken = append(result.Broken, current)
+			continue
+		}
+
+		fixed, err := s.rewriteDependent(current, refs, newName)
+		if err != nil {
+			result.Broken = append(result.Broken, current)
+			continue
+		}
+		result.Fixed = append(result.Fixed, fixed)
+	}
+
+	return result, nil
+}
+
+// RenameTableResult reports the outcome of a table rename and its effect on dependent objects
+type RenameTableResult struct {
+	Dependents []DependentObject `json:"dependents"`
+	Broken     []DependentObject `json:"broken,omitempty"`
+	Fixed      []DependentObject `json:"fixed,omitempty"`
+	// LegacyAlterTable is set when the rename ran with PRAGMA legacy_alter_table=ON, which
+	// leaves references in views and triggers unchanged
+	LegacyAlterTable bool `json:"legacy_alter_table"`
+}
+
+// FindTableDependents lists views and triggers whose definition mentions a table, and tables
+// whose foreign keys reference it
+func (s *SQLiteDB) FindTableDependents(tableName string) ([]DependentObject, error) {
+	rows, err := s.ExecuteQuery(`
+		SELECT type, name, sql FROM sqlite_master
+		WHERE type IN ('table', 'view', 'trigger')
+		AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY type, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+
+	tablePattern := columnReferencePattern(tableName)
+	var dependents []DependentObject
+	for _, row := range rows {
+		obj := DependentObject{}
+		obj.Type, _ = row["type"].(string)
+		obj.Name, _ = row["name"].(string)
+		obj.SQL, _ = row["sql"].(string)
+
+		if obj.Type != "table" {
+			if tablePattern.MatchString(obj.SQL) {
+				dependents = append(dependents, obj)
+			}
+			continue
+		}
+		if strings.EqualFold(obj.Name, tableName) {
+			continue
+		}
+		references, err := s.referencesTable(obj.Name, tableName)
+		if err != nil {
+			return nil, err
+		}
+		if references {
+			dependents = append(dependents, obj)
+		}
+	}
+
+	return dependents, nil
+}
+
+// referencesTable reports whether a table has a foreign key referencing parent
+func (s *SQLiteDB) referencesTable(tableName, parent string) (bool, error) {
+	fks, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA foreign_key_list(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return false, err
+	}
+	for _, fk := range fks {
+		if target, _ := fk["table"].(string); strings.EqualFold(target, parent) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RenameTable renames a table, then checks that dependent views, triggers, and foreign keys
+// still resolve. SQLite updates these references itself unless legacyAlterTable is set, in
+// which case only the table is renamed. When fixDependents is set, views and triggers still
+// referring to the old name are rewritten and recreated, replacing the name where it names
+// a table or qualifies a column, but not columns or aliases that happen to share it; foreign
+// keys of other tables can only be reported, since changing them requires rebuilding those
+// tables.
+func (s *SQLiteDB) RenameTable(oldName, newName string, legacyAlterTable, fixDependents bool) (*RenameTableResult, error) {
+	if err := validateIdentifier(newName); err != nil {
+		return nil, fmt.Errorf("invalid table name: %w", err)
+	}
+	dependents, err := s.FindTableDependents(oldName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan dependent objects: %w", err)
+	}
+
+	if err := s.renameWithLegacyAlter(oldName, newName, legacyAlterTable); err != nil {
+		return nil, err
+	}
+
+	result := &RenameTableResult{Dependents: dependents, LegacyAlterTable: legacyAlterTable}
+
+	for _, dep := range dependents {
+		if dep.Type == "table" {
+			references, err := s.referencesTable(dep.Name, oldName)
+			if err != nil {
+				return nil, err
+			}
+			if references {
+				result.Broken = append(result.Broken, dep)
+			}
+			continue
+		}
+
+		var currentSQL string
+		err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type=? AND name=?", dep.Type, dep.Name).Scan(&currentSQL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reload %s '%s': %w", dep.Type, dep.Name, err)
+		}
+		current := DependentObject{Type: dep.Type, Name: dep.Name, SQL: currentSQL}
+
+		refs := parseDefinition(currentSQL).tableReferences(oldName)
+		if !s.dependentIsBroken(current, refs) {
+			continue
+		}
+		if !fixDependents || len(refs) == 0 {
+			result.Broken = append(result.Broken, current)
+			continue
+		}
+
+		fixed, err := s.rewriteDependent(current, refs, newName)
+		if err != nil {
+			result.Broken = append(result.Broken, current)
+			continue
+		}
+		result.Fixed = append(result.Fixed, fixed)
+	}
+
+	return result, nil
+}
+
+// renameWithLegacyAlter renames a table with PRAGMA legacy_alter_table set as requested.
+// legacy_alter_table is a per-connection setting, so the rename runs on a pinned connection,
+// which is released before returning so that a pool of one connection can go on.
+func (s *SQLiteDB) renameWithLegacyAlter(oldName, newName string, legacyAlterTable bool) error {
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	var previous int
+	if err := conn.QueryRowContext(ctx, "PRAGMA legacy_alter_table").Scan(&previous); err != nil {
+		return err
+	}
+	legacy := 0
+	if legacyAlterTable {
+		legacy = 1
+	}
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA legacy_alter_table = %d", legacy)); err != nil {
+		return err
+	}
+	defer conn.ExecContext(context.Background(), fmt.Sprintf("PRAGMA legacy_alter_table = %d", previous))
+
+	query := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdentifier(oldName), quoteIdentifier(newName))
+	_, err = conn.ExecContext(ctx, query)
+	return err
+}
+
+// dependentIsBroken reports whether an object no longer compiles. A view is compiled; a
+// trigger only compiles when it fires, so it counts as broken while refs, its references to
+// the old name, remain.
+func (s *SQLiteDB) dependentIsBroken(obj DependentObject, refs []sqlToken) bool {
+	if obj.Type == "view" {
+		_, err := s.db.ExecContext(s.ctx(), fmt.Sprintf("SELECT * FROM %s LIMIT 0", quoteIdentifier(obj.Name)))
+		return err != nil
+	}
+	return len(refs) > 0
+}
+
+// rewriteDependent replaces refs, the references to the old name, and recreates the object atomically
+func (s *SQLiteDB) rewriteDependent(obj DependentObject, refs []sqlToken, newName string) (DependentObject, error) {
+	newSQL := replaceTokens(obj.SQL, refs, newName)
+
+	dropKeyword := "VIEW"
+	if obj.Type == "trigger" {
+		dropKeyword = "TRIGGER"
+	}
+
+	tx, err := s.db.BeginTx(s.ctx(), nil)
+	if err != nil {
+		return obj, err
+	}
+	if _, err := tx.ExecContext(s.ctx(), fmt.Sprintf("DROP %s %s", dropKeyword, quoteIdentifier(obj.Name))); err != nil {
+		tx.Rollback()
+		return obj, err
+	}
+	if _, err := tx.ExecContext(s.ctx(), newSQL); err != nil {
+		tx.Rollback()
+		return obj, err
+	}
+	if err := tx.Commit(); err != nil {
+		return obj, err
+	}
+
+	return DependentObject{Type: obj.Type, Name: obj.Name, SQL: newSQL}, nil
+}
+
+// isSimpleIdentifier reports whether a name can be used in SQL without quoting
+func isSimpleIdentifier(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// AddColumn adds a column to a table with ALTER TABLE ADD COLUMN. constraints, such as
+// "NOT NULL DEFAULT 0", follow the type as written; SQLite only accepts constraints an
+// existing row can satisfy, so a NOT NULL column needs a non-NULL default.
+func (s *SQLiteDB) AddColumn(tableName, columnName, columnType, constraints string) error {
+	if columnName == "" {
+		return fmt.Errorf("column name is required")
+	}
+	if err := validateIdentifier(columnName); err != nil {
+		return fmt.Errorf("invalid column name: %w", err)
+	}
+	columnType = strings.TrimSpace(columnType)
+	if !typeNamePattern.MatchString(columnType) {
+		return fmt.Errorf("invalid column type '%s'", columnType)
+	}
+
+	def := quoteIdentifier(columnName) + " " + columnType
+	if constraints = strings.TrimSpace(constraints); constraints != "" {
+		def += " " + constraints
+	}
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s", quoteIdentifier(tableName), def)
+	if !IsSingleStatement(query) {
+		return fmt.Errorf("invalid column constraints '%s'", constraints)
+	}
+	_, err := s.db.ExecContext(s.ctx(), query)
+	return s.diskFullError(err)
+}
+
+// nativeDropColumnVersion is the first SQLite version with ALTER TABLE DROP COLUMN (3.35.0)
+const nativeDropColumnVersion = 3035000
+
+// DropColumn removes a column from a table. SQLite 3.35.0 and later drop it in place with
+// ALTER TABLE DROP COLUMN; older libraries rebuild the table without the column, as
+// ChangeColumnType does. Either way a column that is part of the primary key, a UNIQUE
+// constraint, an index, or a view or trigger cannot be dropped. Reports whether the table
+// was rebuilt.
+func (s *SQLiteDB) DropColumn(tableName, columnName string) (bool, error) {
+	if _, version, _ := sqlite3.Version(); version >= nativeDropColumnVersion {
+		query := fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", quoteIdentifier(tableName), quoteIdentifier(columnName))
+		_, err := s.db.ExecContext(s.ctx(), query)
+		return false, s.diskFullError(err)
+	}
+
+	var tableSQL string
+	err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return false, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if err != nil {
+		return false, err
+	}
+	if virtualTablePattern.MatchString(tableSQL) {
+		return false, fmt.Errorf("cannot drop columns of virtual table '%s'", tableName)
+	}
+
+	columns, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA table_xinfo(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return false, err
+	}
+	dropped := ""
+	var copied []string
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		if strings.EqualFold(name, columnName) {
+			dropped = name
+			if toInt64(col["pk"]) != 0 {
+				return false, fmt.Errorf("cannot drop PRIMARY KEY column '%s'", name)
+			}
+			continue
+		}
+		if toInt64(col["hidden"]) == 0 {
+			copied = append(copied, quoteIdentifier(name))
+		}
+	}
+	if dropped == "" {
+		return false, fmt.Errorf("column '%s' does not exist in table '%s'", columnName, tableName)
+	}
+
+	def, err := locateColumn(tableSQL, dropped)
+	if err != nil {
+		return false, err
+	}
+	// The definition goes with the comma that separates it from its neighbour
+	var rewritten string
+	switch {
+	case tableSQL[def.end] == ',':
+		rewritten = tableSQL[:def.start] + tableSQL[def.end+1:]
+	case tableSQL[def.start-1] == ',':
+		rewritten = tableSQL[:def.start-1] + tableSQL[def.end:]
+	default:
+		return false, fmt.Errorf("cannot drop '%s', the only column of table '%s'", dropped, tableName)
+	}
+
+	tempName := "_mcp_new_" + tableName
+	newSQL := fmt.Sprintf("CREATE TABLE %s %s", quoteIdentifier(tempName), rewritten[def.open:])
+	if _, _, err := s.rebuildTable(tableName, tempName, newSQL, copied, copied, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
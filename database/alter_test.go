@@ -0,0 +1,140 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestColumnReferences(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       string
+	}{
+		{
+			"CREATE VIEW v AS SELECT a.name AS an, b.name AS bn FROM a JOIN b ON a.id = b.a_id",
+			"CREATE VIEW v AS SELECT a.title AS an, b.name AS bn FROM a JOIN b ON a.id = b.a_id",
+		},
+		{
+			"CREATE VIEW v AS SELECT x.name, y.name FROM a AS x, b y",
+			"CREATE VIEW v AS SELECT x.title, y.name FROM a AS x, b y",
+		},
+		{
+			// Unqualified names are only rewritten where a is the only table
+			"CREATE VIEW v AS SELECT name, upper(name) AS name FROM a WHERE name <> ''",
+			"CREATE VIEW v AS SELECT title, upper(title) AS name FROM a WHERE title <> ''",
+		},
+		{
+			"CREATE VIEW v AS SELECT name FROM a JOIN b USING (id)",
+			"CREATE VIEW v AS SELECT name FROM a JOIN b USING (id)",
+		},
+		{
+			`CREATE VIEW v AS SELECT "name" FROM main.a`,
+			`CREATE VIEW v AS SELECT "title" FROM main.a`,
+		},
+		{
+			"CREATE TRIGGER tr AFTER UPDATE OF name ON a BEGIN INSERT INTO b (name, a_id) VALUES (NEW.name, NEW.id); END",
+			"CREATE TRIGGER tr AFTER UPDATE OF title ON a BEGIN INSERT INTO b (name, a_id) VALUES (NEW.title, NEW.id); END",
+		},
+		{
+			"CREATE TRIGGER tr AFTER INSERT ON b BEGIN UPDATE a SET name = NEW.name WHERE a.id = NEW.a_id; END",
+			"CREATE TRIGGER tr AFTER INSERT ON b BEGIN UPDATE a SET name = NEW.name WHERE a.id = NEW.a_id; END",
+		},
+	}
+	for _, test := range tests {
+		refs := parseDefinition(test.definition).columnReferences("a", "name")
+		if got := replaceTokens(test.definition, refs, "title"); got != test.want {
+			t.Errorf("rewriting %s\n got %s\nwant %s", test.definition, got, test.want)
+		}
+	}
+}
+
+func TestRenameColumnKeepsJoinView(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE a (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE b (id INTEGER PRIMARY KEY, a_id INTEGER, name TEXT)",
+		"INSERT INTO a VALUES (1, 'alpha')",
+		"INSERT INTO b VALUES (1, 1, 'beta')",
+		"CREATE VIEW v AS SELECT a.name AS an, b.name AS bn FROM a JOIN b ON a.id = b.a_id",
+	)
+
+	result, err := db.RenameColumn("a", "name", "title", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Broken) != 0 || len(result.Fixed) != 0 {
+		t.Fatalf("broken %v, fixed %v; the view still resolves", result.Broken, result.Fixed)
+	}
+	rows, err := db.ExecuteQuery("SELECT an, bn FROM v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["an"] != "alpha" || rows[0]["bn"] != "beta" {
+		t.Fatalf("view returned %v", rows)
+	}
+	var definition string
+	db.db.QueryRow("SELECT sql FROM sqlite_master WHERE name = 'v'").Scan(&definition)
+	if !strings.Contains(definition, "b.name") {
+		t.Fatalf("b.name was rewritten: %s", definition)
+	}
+}
+
+func TestTableReferences(t *testing.T) {
+	tests := []struct {
+		definition string
+		want       string
+	}{
+		{
+			"CREATE VIEW v AS SELECT a.x, b.a, t.x AS tx FROM a JOIN b ON b.id = a.id JOIN main.a AS t ON t.id = a.id",
+			"CREATE VIEW v AS SELECT z.x, b.a, t.x AS tx FROM z JOIN b ON b.id = z.id JOIN main.z AS t ON t.id = z.id",
+		},
+		{
+			// a is an alias of b here, not the table
+			"CREATE VIEW v AS SELECT a.x FROM b AS a",
+			"CREATE VIEW v AS SELECT a.x FROM b AS a",
+		},
+		{
+			`CREATE VIEW v AS SELECT "a".x FROM "a", c WHERE c.a = 1`,
+			`CREATE VIEW v AS SELECT "z".x FROM "z", c WHERE c.a = 1`,
+		},
+		{
+			"CREATE TRIGGER tr AFTER INSERT ON a BEGIN INSERT INTO a_log (a) SELECT a FROM b WHERE b.id = NEW.id; UPDATE a SET x = 0; END",
+			"CREATE TRIGGER tr AFTER INSERT ON z BEGIN INSERT INTO a_log (a) SELECT a FROM b WHERE b.id = NEW.id; UPDATE z SET x = 0; END",
+		},
+	}
+	for _, test := range tests {
+		refs := parseDefinition(test.definition).tableReferences("a")
+		if got := replaceTokens(test.definition, refs, "z"); got != test.want {
+			t.Errorf("rewriting %s\n got %s\nwant %s", test.definition, got, test.want)
+		}
+	}
+}
+
+func TestRenameTableFixesJoinView(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE a (id INTEGER PRIMARY KEY, x TEXT)",
+		"CREATE TABLE b (id INTEGER PRIMARY KEY, a TEXT)",
+		"INSERT INTO a VALUES (1, 'ax')",
+		"INSERT INTO b VALUES (1, 'ba')",
+		"CREATE VIEW v AS SELECT a.x, b.a, t.x AS tx FROM a JOIN b ON b.id = a.id JOIN a AS t ON t.id = a.id",
+	)
+
+	// The legacy rename leaves the view referring to a
+	result, err := db.RenameTable("a", "z", true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Broken) != 0 || len(result.Fixed) != 1 {
+		t.Fatalf("broken %v, fixed %v", result.Broken, result.Fixed)
+	}
+	want := "CREATE VIEW v AS SELECT z.x, b.a, t.x AS tx FROM z JOIN b ON b.id = z.id JOIN z AS t ON t.id = z.id"
+	if result.Fixed[0].SQL != want {
+		t.Fatalf("view rewritten as %s", result.Fixed[0].SQL)
+	}
+	rows, err := db.ExecuteQuery("SELECT * FROM v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["a"] != "ba" || rows[0]["tx"] != "ax" {
+		t.Fatalf("view returned %v", rows)
+	}
+}
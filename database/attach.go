@@ -0,0 +1,125 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// aliasPattern matches the schema names accepted by AttachDatabase
+var aliasPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Attachment is a database file attached to every connection under an alias
+type Attachment struct {
+	Alias string `json:"alias"`
+	Path  string `json:"path"`
+	// Scratch marks an in-memory database created with CreateScratch; Path is its URI
+	Scratch bool `json:"scratch,omitempty"`
+	// KeepOnSwitch keeps a scratch database attached when switching databases
+	KeepOnSwitch bool `json:"keep_on_switch,omitempty"`
+}
+
+// checkAlias reports whether alias can be used for a new attachment; the caller holds settingsMu
+func (s *SQLiteDB) checkAlias(alias string) error {
+	if !aliasPattern.MatchString(alias) {
+		return fmt.Errorf("alias '%s' must be a simple identifier: letters, digits, and underscores, not starting with a digit", alias)
+	}
+	if strings.EqualFold(alias, "main") || strings.EqualFold(alias, "temp") {
+		return fmt.Errorf("alias '%s' is reserved", alias)
+	}
+	for _, a := range s.attached {
+		if strings.EqualFold(a.Alias, alias) && a.Scratch {
+			return fmt.Errorf("alias '%s' is already used by a scratch database", a.Alias)
+		}
+		if strings.EqualFold(a.Alias, alias) {
+			return fmt.Errorf("alias '%s' is already attached to '%s'", a.Alias, a.Path)
+		}
+	}
+	return nil
+}
+
+// AttachDatabase attaches the database file at path under alias, so statements can refer to
+// its tables as alias.table. ATTACH only affects the connection it runs on, so the file is
+// attached to every pooled connection from the ConnectHook. Attachments are dropped when
+// switching databases.
+func (s *SQLiteDB) AttachDatabase(path, alias string) error {
+	// ATTACH would silently create a missing file
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("cannot attach '%s': %w", path, err)
+	}
+
+	s.settingsMu.Lock()
+	if err := s.checkAlias(alias); err != nil {
+		s.settingsMu.Unlock()
+		return err
+	}
+	previous := s.attached
+	s.attached = append(append([]Attachment(nil), previous...), Attachment{Alias: alias, Path: path})
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.attached = previous
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// DetachDatabase detaches the database attached under alias from every connection
+func (s *SQLiteDB) DetachDatabase(alias string) error {
+	s.settingsMu.Lock()
+	previous := s.attached
+	var remaining []Attachment
+	for _, a := range previous {
+		if !strings.EqualFold(a.Alias, alias) {
+			remaining = append(remaining, a)
+		} else if a.Scratch {
+			s.settingsMu.Unlock()
+			return fmt.Errorf("'%s' is a scratch database, not an attached database file", a.Alias)
+		}
+	}
+	if len(remaining) == len(previous) {
+		s.settingsMu.Unlock()
+		return fmt.Errorf("no database is attached as '%s'", alias)
+	}
+	s.attached = remaining
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.attached = previous
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// AttachedDatabases returns the databases attached with AttachDatabase
+func (s *SQLiteDB) AttachedDatabases() []Attachment {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return append([]Attachment(nil), s.attached...)
+}
+
+// attachStatements returns the ATTACH statements, with their file arguments, run on every
+// new connection
+func (s *SQLiteDB) attachStatements() (statements []string, files []string) {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+
+	for _, a := range s.attached {
+		statements = append(statements, fmt.Sprintf("ATTACH DATABASE ? AS %s", quoteIdentifier(a.Alias)))
+		if a.Scratch {
+			files = append(files, a.Path)
+			continue
+		}
+		file := "file:" + uriEscaper.Replace(a.Path)
+		if s.readOnly {
+			file += "?mode=ro"
+		}
+		files = append(files, file)
+	}
+	return statements, files
+}
@@ -0,0 +1,184 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var withoutRowidPattern = regexp.MustCompile(`(?i)\bWITHOUT\s+ROWID\b`)
+
+// AuditTableName returns the name of the companion audit table for a table
+func AuditTableName(tableName string) string {
+	return tableName + "_audit"
+}
+
+// auditTriggerName returns the name of the audit trigger for a table and operation
+func auditTriggerName(tableName, operation string) string {
+	return fmt.Sprintf("%s_audit_%s", tableName, strings.ToLower(operation))
+}
+
+// EnableAudit creates a <table>_audit table and AFTER INSERT/UPDATE/DELETE triggers that record
+// each change with its timestamp and the old/new row values as JSON. Calling it again refreshes
+// the triggers, for example after columns were added to the table.
+func (s *SQLiteDB) EnableAudit(tableName string) error {
+	var tableSQL string
+	err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if err != nil {
+		return err
+	}
+	if strings.HasSuffix(tableName, "_audit") {
+		return fmt.Errorf("cannot audit an audit table")
+	}
+
+	// json_object is provided by the JSON1 extension
+	if _, err := s.db.ExecContext(s.ctx(), "SELECT json_object('probe', 1)"); err != nil {
+		return fmt.Errorf("JSON functions are not available in this SQLite build: %w", err)
+	}
+
+	columns, err := s.GetTableSchema(tableName)
+	if err != nil {
+		return err
+	}
+
+	hasRowid := !withoutRowidPattern.MatchString(tableSQL)
+
+	// jsonRow builds a json_object(...) expression over all columns of the NEW or OLD row.
+	// BLOB values cannot be stored in JSON, so they are hex encoded.
+	jsonRow := func(ref string) string {
+		var parts []string
+		for _, col := range columns {
+			name, _ := col["name"].(string)
+			value := fmt.Sprintf("%s.%s", ref, quoteIdentifier(name))
+			parts = append(parts, fmt.Sprintf("'%s', CASE typeof(%s) WHEN 'blob' THEN hex(%s) ELSE %s END",
+				strings.ReplaceAll(name, "'", "''"), value, value, value))
+		}
+		return fmt.Sprintf("json_object(%s)", strings.Join(parts, ", "))
+	}
+	rowID := func(ref string) string {
+		if hasRowid {
+			return ref + ".rowid"
+		}
+		return "NULL"
+	}
+
+	auditTable := quoteIdentifier(AuditTableName(tableName))
+	table := quoteIdentifier(tableName)
+
+	triggers := map[string]string{
+		"INSERT": fmt.Sprintf("INSERT INTO %s (operation, row_id, old_values, new_values) VALUES ('INSERT', %s, NULL, %s)",
+			auditTable, rowID("NEW"), jsonRow("NEW")),
+		"UPDATE": fmt.Sprintf("INSERT INTO %s (operation, row_id, old_values, new_values) VALUES ('UPDATE', %s, %s, %s)",
+			auditTable, rowID("NEW"), jsonRow("OLD"), jsonRow("NEW")),
+		"DELETE": fmt.Sprintf("INSERT INTO %s (operation, row_id, old_values, new_values) VALUES ('DELETE', %s, %s, NULL)",
+			auditTable, rowID("OLD"), jsonRow("OLD")),
+	}
+
+	return s.Transaction(func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(s.ctx(), fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				id INTEGER PRIMARY KEY,
+				operation TEXT NOT NULL,
+				changed_at TEXT NOT NULL DEFAULT (strftime('%%Y-%%m-%%d %%H:%%M:%%f', 'now')),
+				row_id INTEGER,
+				old_values TEXT,
+				new_values TEXT
+			)
+		`, auditTable))
+		if err != nil {
+			return fmt.Errorf("failed to create audit table: %w", err)
+		}
+
+		for _, operation := range []string{"INSERT", "UPDATE", "DELETE"} {
+			trigger := quoteIdentifier(auditTriggerName(tableName, operation))
+			if _, err := tx.ExecContext(s.ctx(), fmt.Sprintf("DROP TRIGGER IF EXISTS %s", trigger)); err != nil {
+				return err
+			}
+			createSQL := fmt.Sprintf("CREATE TRIGGER %s AFTER %s ON %s BEGIN %s; END",
+				trigger, operation, table, triggers[operation])
+			if _, err := tx.ExecContext(s.ctx(), createSQL); err != nil {
+				return fmt.Errorf("failed to create %s audit trigger: %w", strings.ToLower(operation), err)
+			}
+		}
+		return nil
+	})
+}
+
+// AuditFilter narrows the audit entries returned by QueryAudit
+type AuditFilter struct {
+	Since     string // inclusive lower bound, any date/time format SQLite understands
+	Until     string // inclusive upper bound
+	Operation string // INSERT, UPDATE, or DELETE
+	Limit     int
+}
+
+// QueryAudit returns audit entries of a table, newest first, filtered by time range and operation
+func (s *SQLiteDB) QueryAudit(tableName string, filter AuditFilter) ([]map[string]interface{}, error) {
+	auditTable := AuditTableName(tableName)
+
+	var exists int
+	if err := s.db.QueryRowContext(s.ctx(), "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", auditTable).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, fmt.Errorf("auditing has never been enabled for table '%s'", tableName)
+	}
+
+	var conditions []string
+	var args []interface{}
+
+	// julianday() accepts both "YYYY-MM-DD HH:MM:SS" and ISO-8601 "T" separated values
+	for _, bound := range []struct {
+		value string
+		op    string
+		name  string
+	}{{filter.Since, ">=", "since"}, {filter.Until, "<=", "until"}} {
+		if bound.value == "" {
+			continue
+		}
+		var valid bool
+		if err := s.db.QueryRowContext(s.ctx(), "SELECT julianday(?) IS NOT NULL", bound.value).Scan(&valid); err != nil {
+			return nil, err
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid %s timestamp: %s", bound.name, bound.value)
+		}
+		conditions = append(conditions, fmt.Sprintf("julianday(changed_at) %s julianday(?)", bound.op))
+		args = append(args, bound.value)
+	}
+
+	if filter.Operation != "" {
+		operation := strings.ToUpper(filter.Operation)
+		if operation != "INSERT" && operation != "UPDATE" && operation != "DELETE" {
+			return nil, fmt.Errorf("operation must be INSERT, UPDATE, or DELETE")
+		}
+		conditions = append(conditions, "operation = ?")
+		args = append(args, operation)
+	}
+
+	query := fmt.Sprintf("SELECT id, operation, changed_at, row_id, old_values, new_values FROM %s", quoteIdentifier(auditTable))
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY changed_at DESC, id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	return s.ExecuteQuery(query, args...)
+}
+
+// DisableAudit drops the audit triggers of a table, keeping the recorded history
+func (s *SQLiteDB) DisableAudit(tableName string) error {
+	return s.Transaction(func(tx *sql.Tx) error {
+		for _, operation := range []string{"INSERT", "UPDATE", "DELETE"} {
+			trigger := quoteIdentifier(auditTriggerName(tableName, operation))
+			if _, err := tx.ExecContext(s.ctx(), fmt.Sprintf("DROP TRIGGER IF EXISTS %s", trigger)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestEnableAuditRecordsChanges(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE accounts (id INTEGER PRIMARY KEY, owner TEXT, balance INTEGER, photo BLOB)")
+	if err := db.EnableAudit("accounts"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, statement := range []string{
+		"INSERT INTO accounts (owner, balance, photo) VALUES ('ann', 100, x'cafe')",
+		"UPDATE accounts SET balance = 150 WHERE owner = 'ann'",
+		"DELETE FROM accounts",
+	} {
+		if _, err := db.ExecuteStatement(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := db.QueryAudit("accounts", AuditFilter{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("%d audit entries, want 3", len(entries))
+	}
+	// Newest first
+	update := entries[1]
+	if update["operation"] != "UPDATE" || update["row_id"] != int64(1) {
+		t.Fatalf("unexpected update entry %v", update)
+	}
+	var before, after map[string]interface{}
+	if err := json.Unmarshal([]byte(update["old_values"].(string)), &before); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(update["new_values"].(string)), &after); err != nil {
+		t.Fatal(err)
+	}
+	if before["balance"] != float64(100) || after["balance"] != float64(150) || after["owner"] != "ann" || after["photo"] != "CAFE" {
+		t.Fatalf("recorded %v -> %v", before, after)
+	}
+	if entries[0]["operation"] != "DELETE" || entries[0]["new_values"] != nil {
+		t.Fatalf("unexpected delete entry %v", entries[0])
+	}
+
+	// Disabling keeps the history but records nothing more
+	if err := db.DisableAudit("accounts"); err != nil {
+		t.Fatal(err)
+	}
+	db.ExecuteStatement("INSERT INTO accounts (owner) VALUES ('bob')")
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM accounts_audit"); n != 3 {
+		t.Fatalf("%d audit entries after disabling, want 3", n)
+	}
+	if err := db.EnableAudit("accounts_audit"); err == nil {
+		t.Fatal("audited an audit table")
+	}
+}
+
+func TestQueryAuditFilters(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)")
+	if err := db.EnableAudit("items"); err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"INSERT INTO items_audit (operation, changed_at, row_id) VALUES ('INSERT', '2024-01-01 09:00:00.000', 1)",
+		"INSERT INTO items_audit (operation, changed_at, row_id) VALUES ('UPDATE', '2024-01-02 09:00:00.000', 1)",
+		"INSERT INTO items_audit (operation, changed_at, row_id) VALUES ('INSERT', '2024-01-03 09:00:00.000', 2)",
+		"INSERT INTO items_audit (operation, changed_at, row_id) VALUES ('DELETE', '2024-01-04 09:00:00.000', 1)",
+	} {
+		if _, err := db.ExecuteStatement(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		filter AuditFilter
+		want   []int64 // row_id of the entries, newest first
+		ops    []string
+	}{
+		{AuditFilter{Limit: 10}, []int64{1, 2, 1, 1}, []string{"DELETE", "INSERT", "UPDATE", "INSERT"}},
+		{AuditFilter{Since: "2024-01-02", Until: "2024-01-03T12:00:00", Limit: 10}, []int64{2, 1}, []string{"INSERT", "UPDATE"}},
+		{AuditFilter{Since: "2024-01-02", Operation: "insert", Limit: 10}, []int64{2}, []string{"INSERT"}},
+		{AuditFilter{Limit: 1}, []int64{1}, []string{"DELETE"}},
+	}
+	for _, test := range tests {
+		entries, err := db.QueryAudit("items", test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(test.want) {
+			t.Errorf("%+v: got %d entries, want %d", test.filter, len(entries), len(test.want))
+			continue
+		}
+		for i, entry := range entries {
+			if entry["row_id"] != test.want[i] || entry["operation"] != test.ops[i] {
+				t.Errorf("%+v: entry %d is %v", test.filter, i, entry)
+			}
+		}
+	}
+
+	for _, filter := range []AuditFilter{{Since: "yesterday-ish"}, {Operation: "MERGE"}} {
+		if _, err := db.QueryAudit("items", filter); err == nil {
+			t.Errorf("accepted %+v", filter)
+		}
+	}
+	if _, err := db.QueryAudit("other", AuditFilter{Limit: 10}); err == nil {
+		t.Error("queried a table that was never audited")
+	}
+}
@@ -0,0 +1,386 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// IndexSuggestion is an index that would let a query search a table instead of scanning it
+type IndexSuggestion struct {
+	Table     string   `json:"table"`
+	Columns   []string `json:"columns"`
+	Name      string   `json:"name"`
+	Statement string   `json:"statement"`
+	Used      bool     `json:"used"` // set by AutoIndex when the new plan uses the index
+}
+
+// AutoIndexResult reports the indexes AutoIndex suggested or created and the plans around them
+type AutoIndexResult struct {
+	Before      []PlanStep        `json:"before"`
+	After       []PlanStep        `json:"after,omitempty"`
+	Suggestions []IndexSuggestion `json:"suggestions"`
+	Created     bool              `json:"created"`
+	Improved    bool              `json:"improved"`
+	Dropped     []string          `json:"dropped,omitempty"` // created indexes the new plan did not use
+}
+
+// Comparison opcodes of the EXPLAIN program; P1 and P3 are the compared registers. A WHERE
+// clause "col = ?" compiles to a jump on Ne, so every equality test counts.
+var (
+	equalityOps = map[string]bool{"Eq": true, "Ne": true, "IsNull": true, "NotNull": true}
+	rangeOps    = map[string]bool{"Lt": true, "Le": true, "Gt": true, "Ge": true}
+)
+
+// IndexAdvice reports the full table scans of a query's plan and the indexes that could turn
+// them into searches
+type IndexAdvice struct {
+	Plan []PlanStep `json:"plan"`
+	// FullScans are the plan steps that read every row of a table
+	FullScans []PlanStep `json:"full_scans"`
+	// AutomaticIndexes are the plan steps that read a whole table to build a temporary index,
+	// on every run of the query
+	AutomaticIndexes []PlanStep        `json:"automatic_indexes"`
+	Suggestions      []IndexSuggestion `json:"suggestions"`
+}
+
+// planConstraintPattern finds the column comparisons in the constraint of a plan step, such
+// as "(a=? AND b>?)"
+var planConstraintPattern = regexp.MustCompile(`([^\s()=<>]+)(=|>=|<=|>|<)\?`)
+
+var indexNameCleaner = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// SuggestIndexes finds the tables a SELECT reads with a full scan and the columns the scan
+// filters on, from the query's EXPLAIN program, and proposes an index for each: equality
+// columns first, then the first range column. Tables that already have an index starting
+// with the same columns are skipped, since the planner chose not to use it.
+func (s *SQLiteDB) SuggestIndexes(query string) ([]IndexSuggestion, error) {
+	program, err := s.explainProgram(query)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := s.rootPageSources()
+	if err != nil {
+		return nil, err
+	}
+
+	type columnRef struct {
+		cursor int64
+		column string
+	}
+	cursors := make(map[int64]*cursorSource)
+	scanned := make(map[int64]bool)
+	registers := make(map[int64]columnRef)
+	equality := make(map[int64][]string)
+	ranged := make(map[int64][]string)
+
+	record := func(target map[int64][]string, ref columnRef) {
+		for _, col := range target[ref.cursor] {
+			if col == ref.column {
+				return
+			}
+		}
+		target[ref.cursor] = append(target[ref.cursor], ref.column)
+	}
+
+	for _, op := range program {
+		switch {
+		case op.opcode == "OpenRead" && op.p3 == 0:
+			cursors[op.p1] = sources[op.p2]
+		case op.opcode == "Rewind" || op.opcode == "Last":
+			// A loop from the first or last entry reads the whole b-tree
+			scanned[op.p1] = true
+		case op.opcode == "Column":
+			delete(registers, op.p3)
+			if src := cursors[op.p1]; src != nil && op.p2 >= 0 && int(op.p2) < len(src.columns) && src.columns[op.p2] != "" {
+				registers[op.p3] = columnRef{op.p1, src.columns[op.p2]}
+			}
+		case equalityOps[op.opcode] || rangeOps[op.opcode]:
+			target := equality
+			if rangeOps[op.opcode] {
+				target = ranged
+			}
+			for _, r := range []int64{op.p1, op.p3} {
+				if ref, ok := registers[r]; ok {
+					record(target, ref)
+				}
+			}
+		}
+	}
+
+	var order []int64
+	for cursor := range scanned {
+		order = append(order, cursor)
+	}
+	sort.Slice(order, func(i, j int) bool { return order[i] < order[j] })
+
+	var suggestions []IndexSuggestion
+	seen := make(map[string]bool)
+	for _, cursor := range order {
+		src := cursors[cursor]
+		if src == nil || strings.HasPrefix(strings.ToLower(src.table), "sqlite_") {
+			continue
+		}
+		columns := append([]string{}, equality[cursor]...)
+		for _, col := range ranged[cursor] {
+			if !containsFold(columns, col) {
+				columns = append(columns, col)
+				break
+			}
+		}
+		if len(columns) == 0 {
+			continue
+		}
+
+		key := strings.ToLower(src.table + "\x00" + strings.Join(columns, "\x00"))
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		covered, err := s.hasIndexPrefix(src.table, columns)
+		if err != nil {
+			return nil, err
+		}
+		if covered {
+			continue
+		}
+
+		suggestion, err := s.indexSuggestion(src.table, columns)
+		if err != nil {
+			return nil, err
+		}
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, nil
+}
+
+// indexSuggestion names an index on the columns of table and writes its CREATE INDEX statement
+func (s *SQLiteDB) indexSuggestion(table string, columns []string) (IndexSuggestion, error) {
+	name, err := s.unusedIndexName("idx_" + table + "_" + strings.Join(columns, "_"))
+	if err != nil {
+		return IndexSuggestion{}, err
+	}
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		quoted[i] = quoteIdentifier(col)
+	}
+	return IndexSuggestion{
+		Table:     table,
+		Columns:   columns,
+		Name:      name,
+		Statement: fmt.Sprintf("CREATE INDEX %s ON %s (%s)", quoteIdentifier(name), quoteIdentifier(table), strings.Join(quoted, ", ")),
+	}, nil
+}
+
+// AdviseIndexes plans a SELECT and suggests an index for each full table scan that filters
+// or joins on columns, see SuggestIndexes, and a permanent index in place of each automatic
+// index the planner builds for a join. Nothing is created.
+func (s *SQLiteDB) AdviseIndexes(query string) (*IndexAdvice, error) {
+	query, err := singleSelect(query)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := s.ExplainQueryPlan(query)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, err := s.SuggestIndexes(query)
+	if err != nil {
+		return nil, err
+	}
+
+	advice := &IndexAdvice{Plan: plan, FullScans: []PlanStep{}, AutomaticIndexes: []PlanStep{}, Suggestions: suggestions}
+	if advice.Suggestions == nil {
+		advice.Suggestions = []IndexSuggestion{}
+	}
+
+	// Plan steps name tables by their alias
+	aliases := make(map[string]string)
+	for _, match := range tableAliasPattern.FindAllStringSubmatch(query, -1) {
+		name := unquoteIdentifier(match[1])
+		aliases[strings.ToLower(name)] = name
+		if match[2] != "" && !sqliteKeywords[strings.ToUpper(match[2])] {
+			aliases[strings.ToLower(match[2])] = name
+		}
+	}
+
+	for _, step := range plan {
+		if step.FullScan() {
+			advice.FullScans = append(advice.FullScans, step)
+		}
+		if !step.AutomaticIndex {
+			continue
+		}
+		advice.AutomaticIndexes = append(advice.AutomaticIndexes, step)
+
+		table, ok := aliases[strings.ToLower(step.Table)]
+		if !ok {
+			continue
+		}
+		var columns, ranged []string
+		for _, m := range planConstraintPattern.FindAllStringSubmatch(step.Constraint, -1) {
+			if m[2] == "=" {
+				columns = append(columns, m[1])
+			} else if !containsFold(ranged, m[1]) {
+				ranged = append(ranged, m[1])
+			}
+		}
+		for _, col := range ranged {
+			if !containsFold(columns, col) {
+				columns = append(columns, col)
+				break
+			}
+		}
+		if len(columns) == 0 || advice.suggests(table, columns) {
+			continue
+		}
+		covered, err := s.hasIndexPrefix(table, columns)
+		if err != nil {
+			return nil, err
+		}
+		if covered {
+			continue
+		}
+		suggestion, err := s.indexSuggestion(table, columns)
+		if err != nil {
+			return nil, err
+		}
+		advice.Suggestions = append(advice.Suggestions, suggestion)
+	}
+	return advice, nil
+}
+
+// suggests reports whether an index on the columns of table is suggested already
+func (a *IndexAdvice) suggests(table string, columns []string) bool {
+	for _, suggestion := range a.Suggestions {
+		if strings.EqualFold(suggestion.Table, table) && strings.EqualFold(strings.Join(suggestion.Columns, "\x00"), strings.Join(columns, "\x00")) {
+			return true
+		}
+	}
+	return false
+}
+
+// singleSelect checks that query is one SELECT and returns it without a trailing semicolon
+func singleSelect(query string) (string, error) {
+	if !IsSingleStatement(query) || statementKind(query) != "read" {
+		return "", fmt.Errorf("only a single SELECT query can be analyzed")
+	}
+	return strings.TrimSuffix(strings.TrimSpace(query), ";"), nil
+}
+
+// AutoIndex suggests indexes for a SELECT and, when create is set, creates them and plans
+// the query again. Created indexes the new plan does not use are dropped again.
+func (s *SQLiteDB) AutoIndex(query string, create bool) (*AutoIndexResult, error) {
+	query, err := singleSelect(query)
+	if err != nil {
+		return nil, err
+	}
+
+	before, err := s.ExplainQueryPlan(query)
+	if err != nil {
+		return nil, err
+	}
+	suggestions, err := s.SuggestIndexes(query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AutoIndexResult{Before: before, Suggestions: suggestions}
+	if !create || len(suggestions) == 0 {
+		return result, nil
+	}
+
+	for _, suggestion := range suggestions {
+		if _, err := s.db.ExecContext(s.ctx(), suggestion.Statement); err != nil {
+			return nil, fmt.Errorf("failed to create index '%s': %w", suggestion.Name, err)
+		}
+	}
+	result.Created = true
+
+	after, err := s.ExplainQueryPlan(query)
+	if err != nil {
+		return nil, err
+	}
+	result.After = after
+
+	for i := range result.Suggestions {
+		suggestion := &result.Suggestions[i]
+		for _, step := range after {
+			if strings.EqualFold(step.Index, suggestion.Name) {
+				suggestion.Used = true
+			}
+		}
+		if suggestion.Used {
+			result.Improved = true
+			continue
+		}
+		if _, err := s.db.ExecContext(s.ctx(), fmt.Sprintf("DROP INDEX %s", quoteIdentifier(suggestion.Name))); err != nil {
+			return nil, fmt.Errorf("failed to drop unused index '%s': %w", suggestion.Name, err)
+		}
+		result.Dropped = append(result.Dropped, suggestion.Name)
+	}
+
+	return result, nil
+}
+
+// hasIndexPrefix reports whether an index of the table starts with the given columns
+func (s *SQLiteDB) hasIndexPrefix(table string, columns []string) (bool, error) {
+	indexes, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_list(%s)", quoteIdentifier(table)))
+	if err != nil {
+		return false, err
+	}
+	for _, index := range indexes {
+		if toInt64(index["partial"]) != 0 {
+			continue
+		}
+		name, _ := index["name"].(string)
+		info, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_info(%s)", quoteIdentifier(name)))
+		if err != nil {
+			return false, err
+		}
+		if len(info) < len(columns) {
+			continue
+		}
+		matches := true
+		for _, col := range info {
+			seqno := int(toInt64(col["seqno"]))
+			indexed, _ := col["name"].(string)
+			if seqno < len(columns) && !strings.EqualFold(indexed, columns[seqno]) {
+				matches = false
+			}
+		}
+		if matches {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// unusedIndexName turns base into a valid index name that no schema object uses yet
+func (s *SQLiteDB) unusedIndexName(base string) (string, error) {
+	base = strings.Trim(indexNameCleaner.ReplaceAllString(base, "_"), "_")
+	name := base
+	for n := 2; ; n++ {
+		var count int
+		if err := s.db.QueryRowContext(s.ctx(), "SELECT COUNT(*) FROM sqlite_master WHERE name = ? COLLATE NOCASE", name).Scan(&count); err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return name, nil
+		}
+		name = fmt.Sprintf("%s_%d", base, n)
+	}
+}
+
+// containsFold reports whether list holds value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuggestIndexes(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, status TEXT, total REAL)",
+		"CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT)",
+		"CREATE INDEX idx_body ON notes (body)",
+	)
+
+	suggestions, err := db.SuggestIndexes("SELECT * FROM orders WHERE customer = 'ann' AND status = 'open' AND total > 10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) != 1 {
+		t.Fatalf("got %d suggestions, want 1", len(suggestions))
+	}
+	got := suggestions[0]
+	if got.Table != "orders" || !reflect.DeepEqual(got.Columns, []string{"customer", "status", "total"}) {
+		t.Fatalf("got %+v", got)
+	}
+	if got.Statement != `CREATE INDEX "idx_orders_customer_status_total" ON "orders" ("customer", "status", "total")` {
+		t.Fatalf("got statement %s", got.Statement)
+	}
+
+	// A search by rowid or on an existing index needs nothing new
+	for _, query := range []string{"SELECT * FROM orders WHERE id = 3", "SELECT * FROM notes WHERE body = 'x'"} {
+		if suggestions, err = db.SuggestIndexes(query); err != nil {
+			t.Fatal(err)
+		}
+		if len(suggestions) != 0 {
+			t.Errorf("%s: got suggestions %+v", query, suggestions)
+		}
+	}
+}
+
+func TestAutoIndex(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total REAL)",
+		"CREATE TABLE idx_orders_customer (x)",
+	)
+	query := "SELECT id FROM orders WHERE customer = 'ann'"
+
+	result, err := db.AutoIndex(query, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Created || len(result.Suggestions) != 1 || len(result.Before) == 0 {
+		t.Fatalf("got %+v", result)
+	}
+	// The name taken by the table is skipped
+	name := result.Suggestions[0].Name
+	if name != "idx_orders_customer_2" {
+		t.Fatalf("got index name %s", name)
+	}
+	if queryInt(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'index'") != 0 {
+		t.Fatal("index created without create")
+	}
+
+	if result, err = db.AutoIndex(query+";", true); err != nil {
+		t.Fatal(err)
+	}
+	if !result.Created || !result.Improved || !result.Suggestions[0].Used || len(result.Dropped) != 0 {
+		t.Fatalf("got %+v", result)
+	}
+	if queryInt(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND name = ?", name) != 1 {
+		t.Fatal("index not kept")
+	}
+
+	// Now the query searches the index, so there is nothing left to do
+	if result, err = db.AutoIndex(query, true); err != nil {
+		t.Fatal(err)
+	}
+	if result.Created || len(result.Suggestions) != 0 {
+		t.Fatalf("got %+v", result)
+	}
+
+	for _, query := range []string{"DELETE FROM orders", "SELECT 1; SELECT 2"} {
+		if _, err := db.AutoIndex(query, true); err == nil {
+			t.Errorf("%s accepted", query)
+		}
+	}
+}
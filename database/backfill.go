@@ -0,0 +1,103 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// DefaultBackfillBatchSize is the number of rows updated per statement by BackfillColumn
+const DefaultBackfillBatchSize = 1000
+
+// BackfillColumn sets every NULL value of a column to the given value. Rows are updated in
+// batches of batchSize within one transaction, so either all NULLs are filled or none are.
+// With dryRun set nothing is written and the number of rows that would change is returned.
+func (s *SQLiteDB) BackfillColumn(tableName, columnName string, value interface{}, batchSize int, dryRun bool) (int64, error) {
+	if value == nil {
+		return 0, fmt.Errorf("backfill value must not be null")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBackfillBatchSize
+	}
+
+	var tableSQL string
+	err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return 0, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	columns, err := s.GetTableSchema(tableName)
+	if err != nil {
+		return 0, err
+	}
+	var column map[string]interface{}
+	for _, col := range columns {
+		if name, _ := col["name"].(string); strings.EqualFold(name, columnName) {
+			column = col
+			break
+		}
+	}
+	if column == nil {
+		return 0, fmt.Errorf("column '%s' does not exist in table '%s'", columnName, tableName)
+	}
+
+	colType, _ := column["type"].(string)
+	affinity := columnAffinity(colType)
+	if !valueMatchesAffinity(value, affinity) {
+		return 0, fmt.Errorf("value %v is not compatible with %s affinity of column '%s'", value, affinity, columnName)
+	}
+
+	table := quoteIdentifier(tableName)
+	col := quoteIdentifier(columnName)
+
+	if dryRun {
+		var count int64
+		err := s.db.QueryRowContext(s.ctx(), fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s IS NULL", table, col)).Scan(&count)
+		return count, err
+	}
+
+	var total int64
+	err = s.Transaction(func(tx *sql.Tx) error {
+		// WITHOUT ROWID tables cannot be addressed in batches by rowid
+		if withoutRowidPattern.MatchString(tableSQL) {
+			result, err := tx.ExecContext(s.ctx(), fmt.Sprintf("UPDATE %s SET %s = ? WHERE %s IS NULL", table, col, col), value)
+			if err != nil {
+				return err
+			}
+			total, err = result.RowsAffected()
+			return err
+		}
+
+		// Bound the loop by the initial NULL count so triggers that write NULLs back cannot spin it
+		var remaining int64
+		if err := tx.QueryRowContext(s.ctx(), fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s IS NULL", table, col)).Scan(&remaining); err != nil {
+			return err
+		}
+
+		batchSQL := fmt.Sprintf("UPDATE %s SET %s = ? WHERE rowid IN (SELECT rowid FROM %s WHERE %s IS NULL LIMIT %d)",
+			table, col, table, col, batchSize)
+		for total < remaining {
+			result, err := tx.ExecContext(s.ctx(), batchSQL, value)
+			if err != nil {
+				return err
+			}
+			affected, err := result.RowsAffected()
+			if err != nil {
+				return err
+			}
+			if affected == 0 {
+				break
+			}
+			total += affected
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("backfill failed: %w", err)
+	}
+
+	return total, nil
+}
@@ -0,0 +1,57 @@
+package database
+
+import "testing"
+
+func TestBackfillColumn(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, status TEXT)",
+		"INSERT INTO users (status) WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 25) SELECT CASE WHEN i % 5 = 0 THEN 'banned' END FROM n",
+	)
+
+	count, err := db.BackfillColumn("users", "status", "active", 4, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 20 || queryInt(t, db, "SELECT COUNT(*) FROM users WHERE status IS NULL") != 20 {
+		t.Fatalf("dry run reported %d rows and must change nothing", count)
+	}
+
+	// Batches of 4 over 20 NULLs
+	count, err = db.BackfillColumn("users", "status", "active", 4, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 20 {
+		t.Fatalf("backfilled %d rows, want 20", count)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM users WHERE status = 'active'"); n != 20 {
+		t.Fatalf("%d active rows, want 20", n)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM users WHERE status = 'banned'"); n != 5 {
+		t.Fatalf("%d banned rows left, want 5", n)
+	}
+
+	if _, err := db.BackfillColumn("users", "id", "x", 0, false); err == nil {
+		t.Error("backfilled text into an INTEGER column")
+	}
+	if _, err := db.BackfillColumn("users", "missing", "x", 0, false); err == nil {
+		t.Error("backfilled a missing column")
+	}
+	if _, err := db.BackfillColumn("users", "status", nil, 0, false); err == nil {
+		t.Error("backfilled NULL")
+	}
+}
+
+func TestBackfillWithoutRowid(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE kv (k TEXT PRIMARY KEY, v INTEGER) WITHOUT ROWID",
+		"INSERT INTO kv VALUES ('a', NULL), ('b', 2), ('c', NULL)",
+	)
+	count, err := db.BackfillColumn("kv", "v", float64(0), 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2 || queryInt(t, db, "SELECT SUM(v) FROM kv") != 2 {
+		t.Fatalf("backfilled %d rows", count)
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// MaxBenchmarkIterations caps how many times a query can be run by BenchmarkQuery
+const MaxBenchmarkIterations = 100
+
+// BenchmarkResult holds timing statistics for repeated executions of a query
+type BenchmarkResult struct {
+	Iterations int     `json:"iterations"`
+	RowCount   int     `json:"row_count"`
+	ClearCache bool    `json:"clear_cache"`
+	MinMs      float64 `json:"min_ms"`
+	MaxMs      float64 `json:"max_ms"`
+	MeanMs     float64 `json:"mean_ms"`
+	MedianMs   float64 `json:"median_ms"`
+}
+
+// BenchmarkQuery runs a query the given number of times on a single connection and reports timing statistics.
+// Rows are stepped through but not kept. When clearCache is set, the connection's page cache is released
+// before every run so each iteration reads from disk.
+func (s *SQLiteDB) BenchmarkQuery(query string, iterations int, clearCache bool) (*BenchmarkResult, error) {
+	if iterations < 1 || iterations > MaxBenchmarkIterations {
+		return nil, fmt.Errorf("iterations must be between 1 and %d", MaxBenchmarkIterations)
+	}
+	// Running a write repeatedly would repeat its changes
+	if err := s.CheckReadQuery(query); err != nil {
+		return nil, err
+	}
+
+	ctx := s.ctx()
+
+	// Pin a single connection so cache state is consistent between runs
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	durations := make([]time.Duration, 0, iterations)
+	rowCount := 0
+
+	for i := 0; i < iterations; i++ {
+		if clearCache {
+			if _, err := conn.ExecContext(ctx, "PRAGMA shrink_memory"); err != nil {
+				return nil, fmt.Errorf("failed to clear cache: %w", err)
+			}
+		}
+
+		start := time.Now()
+		rows, err := conn.QueryContext(ctx, query)
+		if err != nil {
+			return nil, fmt.Errorf("query failed: %w", err)
+		}
+		count := 0
+		for rows.Next() {
+			count++
+		}
+		err = rows.Err()
+		rows.Close()
+		elapsed := time.Since(start)
+		if err != nil {
+			return nil, fmt.Errorf("rows error: %w", err)
+		}
+
+		if i == 0 {
+			rowCount = count
+		}
+		durations = append(durations, elapsed)
+	}
+
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	var total time.Duration
+	for _, d := range durations {
+		total += d
+	}
+
+	median := durations[len(durations)/2]
+	if len(durations)%2 == 0 {
+		median = (durations[len(durations)/2-1] + durations[len(durations)/2]) / 2
+	}
+
+	return &BenchmarkResult{
+		Iterations: iterations,
+		RowCount:   rowCount,
+		ClearCache: clearCache,
+		MinMs:      toMilliseconds(durations[0]),
+		MaxMs:      toMilliseconds(durations[len(durations)-1]),
+		MeanMs:     toMilliseconds(total / time.Duration(len(durations))),
+		MedianMs:   toMilliseconds(median),
+	}, nil
+}
+
+// toMilliseconds converts a duration to fractional milliseconds
+func toMilliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
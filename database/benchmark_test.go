@@ -0,0 +1,42 @@
+package database
+
+import "testing"
+
+func TestBenchmarkQuery(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t1 (x INTEGER)", "INSERT INTO t1 VALUES (1), (2), (3)")
+
+	result, err := db.BenchmarkQuery("SELECT * FROM t1", 3, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Iterations != 3 || result.RowCount != 3 || !result.ClearCache {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.MinMs > result.MedianMs || result.MedianMs > result.MaxMs {
+		t.Errorf("statistics out of order: %+v", result)
+	}
+	if _, err := db.BenchmarkQuery("SELECT 1", 0, false); err == nil {
+		t.Error("0 iterations were accepted")
+	}
+	if _, err := db.BenchmarkQuery("SELECT 1", MaxBenchmarkIterations+1, false); err == nil {
+		t.Error("too many iterations were accepted")
+	}
+}
+
+func TestBenchmarkQueryRejectsWrites(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t1 (x INTEGER)", "INSERT INTO t1 VALUES (1)")
+
+	for _, query := range []string{
+		"SELECT 1; DROP TABLE t1",
+		"DELETE FROM t1",
+		"WITH d AS (SELECT 1) DELETE FROM t1",
+		"WITH d AS (SELECT 1) INSERT INTO t1 SELECT * FROM d",
+	} {
+		if _, err := db.BenchmarkQuery(query, 1, false); err == nil {
+			t.Errorf("%q was benchmarked", query)
+		}
+	}
+	if !hasTable(t, db, "t1") || queryInt(t, db, "SELECT COUNT(*) FROM t1") != 1 {
+		t.Error("a rejected query changed the database")
+	}
+}
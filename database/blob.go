@@ -0,0 +1,34 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Blob is a BLOB value read by a query. It encodes to JSON as {"base64": "..."}, the form
+// ExportJSON writes, so binary data survives the trip intact and is not mistaken for text.
+type Blob []byte
+
+// MarshalJSON encodes the blob as {"base64": "..."}
+func (b Blob) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+// DecodeBlob returns the bytes of a decoded JSON value of the form {"base64": "..."}. ok is
+// false when value is not an object; an object of any other form is an error.
+func DecodeBlob(value interface{}) (data []byte, ok bool, err error) {
+	object, isObject := value.(map[string]interface{})
+	if !isObject {
+		return nil, false, nil
+	}
+	encoded, isString := object["base64"].(string)
+	if !isString || len(object) != 1 {
+		return nil, true, fmt.Errorf("objects must have the form {\"base64\": \"...\"}")
+	}
+	data, err = base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, true, fmt.Errorf("invalid base64: %w", err)
+	}
+	return data, true, nil
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestBlobJSONRoundTrip(t *testing.T) {
+	data := []byte{0, 1, 2, 0xfe, 0xff, 'a'}
+	encoded, err := json.Marshal(map[string]interface{}{"b": Blob(data)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(encoded) != `{"b":{"base64":"AAEC/v9h"}}` {
+		t.Fatalf("got %s", encoded)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(encoded, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	got, ok, err := DecodeBlob(decoded["b"])
+	if err != nil || !ok || !bytes.Equal(got, data) {
+		t.Fatalf("got %v, %v, %v", got, ok, err)
+	}
+}
+
+func TestDecodeBlob(t *testing.T) {
+	if _, ok, err := DecodeBlob("AAEC"); ok || err != nil {
+		t.Fatalf("a string decoded: %v, %v", ok, err)
+	}
+	for _, value := range []map[string]interface{}{
+		{"base64": "not base64!"},
+		{"base64": 12},
+		{"hex": "00ff"},
+		{"base64": "AAEC", "extra": true},
+	} {
+		if _, ok, err := DecodeBlob(value); !ok || err == nil {
+			t.Errorf("%v: got ok %v and error %v", value, ok, err)
+		}
+	}
+}
+
+func TestBlobQueryRoundTrip(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE files (data BLOB, note TEXT)")
+	data := []byte("\x00binary\xff")
+	if _, err := db.ExecuteStatement("INSERT INTO files VALUES (?, ?)", data, "text"); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := db.ExecuteQuery("SELECT data, note FROM files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, ok := rows[0]["data"].(Blob)
+	if !ok || !bytes.Equal(blob, data) {
+		t.Fatalf("got %T %v", rows[0]["data"], rows[0]["data"])
+	}
+	// Text is never read as a BLOB
+	if _, ok := rows[0]["note"].(string); !ok {
+		t.Fatalf("got %T for text", rows[0]["note"])
+	}
+}
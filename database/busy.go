@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DefaultBusyTimeout is how long a statement waits for a lock held by another connection
+// before failing with SQLITE_BUSY, unless SetBusyTimeout changes it
+const DefaultBusyTimeout = 5 * time.Second
+
+// busyRetries is how often an operation that failed with SQLITE_BUSY or SQLITE_LOCKED is
+// retried, with busyBackoff doubling between attempts
+const busyRetries = 4
+
+const busyBackoff = 50 * time.Millisecond
+
+// isBusy reports whether err means another connection holds a conflicting lock
+func isBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && (sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked)
+}
+
+// SetBusyTimeout sets PRAGMA busy_timeout on all connections: how long a statement waits for
+// another connection, possibly another process, to release a lock before it fails with
+// "database is locked". 0 fails at once. The setting is kept when switching databases.
+func (s *SQLiteDB) SetBusyTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("busy timeout cannot be negative")
+	}
+	s.settingsMu.Lock()
+	previous := s.busyTimeout
+	s.busyTimeout = timeout
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.busyTimeout = previous
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// GetBusyTimeout reads the busy timeout in effect on a pool connection
+func (s *SQLiteDB) GetBusyTimeout() (time.Duration, error) {
+	var ms int64
+	err := s.db.QueryRowContext(s.ctx(), "PRAGMA busy_timeout").Scan(&ms)
+	return time.Duration(ms) * time.Millisecond, err
+}
+
+// retryBusy runs op and runs it again, up to busyRetries times with a growing pause, while it
+// fails with SQLITE_BUSY or SQLITE_LOCKED. The busy timeout only covers waiting for a lock;
+// some conflicts, such as a read transaction in WAL mode that another connection wrote past,
+// fail at once and succeed when started over. op must be safe to repeat after a failed
+// attempt. When every attempt fails the error says how long the lock was waited for.
+func (s *SQLiteDB) retryBusy(ctx context.Context, op func() error) error {
+	start := time.Now()
+	pause := busyBackoff
+	for attempt := 1; ; attempt++ {
+		err := op()
+		if !isBusy(err) {
+			return err
+		}
+		if attempt > busyRetries {
+			return fmt.Errorf("database is locked by another connection, gave up after %d attempts over %s: %w",
+				attempt, time.Since(start).Round(time.Millisecond), err)
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(pause):
+		}
+		pause *= 2
+	}
+}
+
+// RetryTransaction runs fn in a transaction like Transaction, and starts the transaction over
+// when it fails because another connection holds a lock, see retryBusy. fn must only act
+// through tx and must not rely on state left over from a previous run.
+func (s *SQLiteDB) RetryTransaction(fn func(*sql.Tx) error) error {
+	return s.retryBusy(s.ctx(), func() error {
+		return s.Transaction(fn)
+	})
+}
@@ -0,0 +1,109 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// holdWriteLock takes the write lock of the database from a connection outside the pool and
+// returns a function releasing it, which may be called more than once
+func holdWriteLock(t *testing.T, db *SQLiteDB) func() {
+	t.Helper()
+	other, err := sql.Open("sqlite3", db.GetCurrentDatabasePath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { other.Close() })
+	conn, err := other.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.ExecContext(context.Background(), "BEGIN IMMEDIATE"); err != nil {
+		t.Fatal(err)
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if _, err := conn.ExecContext(context.Background(), "COMMIT"); err != nil {
+				t.Error(err)
+			}
+			conn.Close()
+		})
+	}
+}
+
+// busyDB opens a database that fails at once on a lock, so only the retries wait for it
+func busyDB(t *testing.T) *SQLiteDB {
+	t.Helper()
+	db := newTestDB(t, "CREATE TABLE t (id INTEGER)")
+	if err := db.SetBusyTimeout(0); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRetryBusyStatement(t *testing.T) {
+	db := busyDB(t)
+
+	// Released well within the backoff of 50+100+200+400ms
+	release := holdWriteLock(t, db)
+	time.AfterFunc(120*time.Millisecond, release)
+	if _, err := db.ExecuteStatement("INSERT INTO t VALUES (1)"); err != nil {
+		t.Fatalf("statement not retried until the lock was released: %v", err)
+	}
+
+	release = holdWriteLock(t, db)
+	defer release()
+	start := time.Now()
+	_, err := db.ExecuteStatement("INSERT INTO t VALUES (2)")
+	if err == nil || !isBusy(err) || !strings.Contains(err.Error(), "gave up after 5 attempts") {
+		t.Fatalf("got %v, want the retries exhausted", err)
+	}
+	if elapsed := time.Since(start); elapsed < 750*time.Millisecond {
+		t.Fatalf("gave up after %s, before the backoff ran out", elapsed)
+	}
+}
+
+func TestRetryTransaction(t *testing.T) {
+	db := busyDB(t)
+	insert := func(tx *sql.Tx) error {
+		_, err := tx.Exec("INSERT INTO t VALUES (1)")
+		return err
+	}
+
+	release := holdWriteLock(t, db)
+	time.AfterFunc(120*time.Millisecond, release)
+	if err := db.RetryTransaction(insert); err != nil {
+		t.Fatalf("transaction not retried until the lock was released: %v", err)
+	}
+
+	release = holdWriteLock(t, db)
+	defer release()
+	if err := db.RetryTransaction(insert); err == nil || !strings.Contains(err.Error(), "database is locked by another connection") {
+		t.Fatalf("got %v, want the retries exhausted", err)
+	}
+	release()
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM t"); n != 1 {
+		t.Fatalf("got %d rows, want the one committed transaction", n)
+	}
+}
+
+func TestRetryBusyStopsOnCancel(t *testing.T) {
+	db := busyDB(t)
+	release := holdWriteLock(t, db)
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 80*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := db.ExecuteStatementContext(ctx, "INSERT INTO t VALUES (1)"); err == nil {
+		t.Fatal("statement succeeded while the lock was held")
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("kept retrying for %s after the context ended", elapsed)
+	}
+}
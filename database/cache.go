@@ -0,0 +1,89 @@
+package database
+
+import (
+	"fmt"
+)
+
+// CacheStats describes the page cache and memory-mapped I/O settings of the database
+type CacheStats struct {
+	// CacheSize is the raw PRAGMA cache_size: a page count, or the size in KiB when negative
+	CacheSize  int64 `json:"cache_size"`
+	CacheBytes int64 `json:"cache_bytes"`
+	CachePages int64 `json:"cache_pages"`
+	PageSize   int64 `json:"page_size"`
+	PageCount  int64 `json:"page_count"`
+	// DatabaseBytes is the size of the main database file
+	DatabaseBytes int64 `json:"database_bytes"`
+	// CacheCoverage is the fraction of the database that fits in one connection's cache
+	CacheCoverage float64 `json:"cache_coverage"`
+	MmapSize      int64   `json:"mmap_size"`
+	CacheSpill    int64   `json:"cache_spill"`
+	// Each pooled connection has its own page cache
+	OpenConnections int `json:"open_connections"`
+	// Hit and miss counters come from sqlite3_db_status, which go-sqlite3 does not expose
+	HitStatistics string `json:"hit_statistics"`
+}
+
+// GetCacheStats reports the page cache size, mmap size, and how much of the database the
+// cache can hold, as read from a pool connection
+func (s *SQLiteDB) GetCacheStats() (*CacheStats, error) {
+	stats := &CacheStats{
+		OpenConnections: s.db.Stats().OpenConnections,
+		HitStatistics:   "unavailable: the go-sqlite3 driver does not expose sqlite3_db_status",
+	}
+
+	for pragma, target := range map[string]*int64{
+		"cache_size":  &stats.CacheSize,
+		"page_size":   &stats.PageSize,
+		"page_count":  &stats.PageCount,
+		"mmap_size":   &stats.MmapSize,
+		"cache_spill": &stats.CacheSpill,
+	} {
+		if err := s.db.QueryRowContext(s.ctx(), fmt.Sprintf("PRAGMA %s", pragma)).Scan(target); err != nil {
+			return nil, fmt.Errorf("failed to read PRAGMA %s: %w", pragma, err)
+		}
+	}
+
+	if stats.CacheSize < 0 {
+		stats.CacheBytes = -stats.CacheSize * 1024
+		stats.CachePages = stats.CacheBytes / stats.PageSize
+	} else {
+		stats.CachePages = stats.CacheSize
+		stats.CacheBytes = stats.CacheSize * stats.PageSize
+	}
+	stats.DatabaseBytes = stats.PageCount * stats.PageSize
+	stats.CacheCoverage = 1
+	if stats.PageCount > stats.CachePages {
+		stats.CacheCoverage = float64(stats.CachePages) / float64(stats.PageCount)
+	}
+
+	return stats, nil
+}
+
+// SetCacheSettings sets PRAGMA cache_size and PRAGMA mmap_size on all connections; a nil
+// argument leaves that setting unchanged. cache_size follows SQLite's convention: a page
+// count, or the size in KiB when negative. mmap_size is in bytes, 0 disables memory-mapped
+// I/O. The settings are kept when switching databases.
+func (s *SQLiteDB) SetCacheSettings(cacheSize, mmapSize *int64) error {
+	if mmapSize != nil && *mmapSize < 0 {
+		return fmt.Errorf("mmap_size must be 0 or a size in bytes")
+	}
+
+	s.settingsMu.Lock()
+	previousCache, previousMmap := s.cacheSize, s.mmapSize
+	if cacheSize != nil {
+		s.cacheSize = cacheSize
+	}
+	if mmapSize != nil {
+		s.mmapSize = mmapSize
+	}
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.cacheSize, s.mmapSize = previousCache, previousMmap
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
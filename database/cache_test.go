@@ -0,0 +1,58 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheStatsReportConfiguredSize(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (v TEXT)")
+
+	cacheSize, mmapSize := int64(-8192), int64(1<<20)
+	if err := db.SetCacheSettings(&cacheSize, &mmapSize); err != nil {
+		t.Fatal(err)
+	}
+	stats, err := db.GetCacheStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.CacheSize != cacheSize || stats.CacheBytes != 8192*1024 || stats.CachePages != 8192*1024/stats.PageSize {
+		t.Fatalf("cache reported as %d (%d bytes, %d pages), want %d", stats.CacheSize, stats.CacheBytes, stats.CachePages, cacheSize)
+	}
+	if stats.MmapSize != mmapSize {
+		t.Fatalf("mmap_size reported as %d, want %d", stats.MmapSize, mmapSize)
+	}
+	for _, size := range pragmaOnEachConnection(t, db, "cache_size", 3) {
+		if size != cacheSize {
+			t.Fatalf("a connection has cache_size %d", size)
+		}
+	}
+
+	// A page count, with mmap_size left as it was
+	cacheSize = 500
+	if err := db.SetCacheSettings(&cacheSize, nil); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = db.GetCacheStats(); err != nil {
+		t.Fatal(err)
+	}
+	if stats.CacheSize != 500 || stats.CachePages != 500 || stats.CacheBytes != 500*stats.PageSize || stats.MmapSize != mmapSize {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	// The settings are kept for the next database
+	if err := db.SwitchDatabase(filepath.Join(t.TempDir(), "other.db")); err != nil {
+		t.Fatal(err)
+	}
+	if stats, err = db.GetCacheStats(); err != nil {
+		t.Fatal(err)
+	}
+	if stats.CacheSize != 500 || stats.MmapSize != mmapSize {
+		t.Fatalf("after switching, cache_size %d and mmap_size %d", stats.CacheSize, stats.MmapSize)
+	}
+
+	negative := int64(-1)
+	if err := db.SetCacheSettings(nil, &negative); err == nil {
+		t.Fatal("accepted a negative mmap_size")
+	}
+}
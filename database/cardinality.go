@@ -0,0 +1,150 @@
+package database
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	// DefaultCardinalitySample is the number of rows EstimateCardinality samples by default
+	DefaultCardinalitySample = 10000
+	// LowCardinalityMax is the most distinct values a column can have and still be low-cardinality
+	LowCardinalityMax = 50
+	// lowCardinalityRatio is the distinct-to-value ratio at or below which a column is low-cardinality
+	lowCardinalityRatio = 0.01
+	// nearUniqueRatio is the distinct-to-value ratio at or above which a column is near-unique
+	nearUniqueRatio = 0.95
+)
+
+// ColumnCardinality is the estimated number of distinct values of one column
+type ColumnCardinality struct {
+	Column   string  `json:"column"`
+	NonNull  int64   `json:"non_null"`
+	Distinct int64   `json:"distinct"`
+	Ratio    float64 `json:"ratio"` // distinct values per non-NULL value
+	// Class is "low", "medium", "near_unique", or "empty" for a column with only NULLs
+	Class string `json:"class"`
+	// Candidate marks low-cardinality columns, which suit faceting and GROUP BY, and make
+	// good partial-index conditions or leading columns of composite indexes
+	Candidate bool `json:"candidate"`
+}
+
+// CardinalityEstimate reports the estimated cardinality of every column of a table
+type CardinalityEstimate struct {
+	Table      string              `json:"table"`
+	Rows       int64               `json:"rows"`
+	Sampled    bool                `json:"sampled"`
+	SampleRows int64               `json:"sample_rows"`
+	Columns    []ColumnCardinality `json:"columns"`
+}
+
+// EstimateCardinality estimates the number of distinct values in each column of a table. With
+// fullScan, or when the table has at most sampleSize rows, the counts are exact. Otherwise
+// about sampleSize rows are sampled at random and the distinct count is extrapolated with the
+// GEE estimator, which scales the values seen once in the sample by sqrt(rows/sample) and
+// counts the values seen more than once as they are; a column that is near-unique in the
+// sample is scaled up linearly instead. Every column is estimated from one read
+// of the table. A column is low-cardinality when it has at most LowCardinalityMax distinct
+// values or one per hundred values, and near-unique at 95 distinct values per hundred.
+func (s *SQLiteDB) EstimateCardinality(tableName string, sampleSize int, fullScan bool) (*CardinalityEstimate, error) {
+	if sampleSize <= 0 {
+		sampleSize = DefaultCardinalitySample
+	}
+
+	var exists int
+	if err := s.db.QueryRowContext(s.ctx(), "SELECT COUNT(*) FROM sqlite_master WHERE type IN ('table', 'view') AND name=?", tableName).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if exists == 0 {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	// Generated columns are included; hidden columns of virtual tables are not
+	columns, err := s.ExecuteQuery("SELECT name FROM pragma_table_xinfo(?) WHERE hidden != 1 ORDER BY cid", tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table '%s' has no columns", tableName)
+	}
+
+	result := &CardinalityEstimate{Table: tableName}
+	quotedTable := quoteIdentifier(tableName)
+	if err := s.db.QueryRowContext(s.ctx(), fmt.Sprintf("SELECT COUNT(*) FROM %s", quotedTable)).Scan(&result.Rows); err != nil {
+		return nil, err
+	}
+
+	source := quotedTable
+	if !fullScan && result.Rows > int64(sampleSize) {
+		// Keeping each row with probability sampleSize/rows needs no sort, unlike ORDER BY random()
+		result.Sampled = true
+		source = fmt.Sprintf("(SELECT * FROM %s WHERE abs(random() %% %d) < %d)", quotedTable, result.Rows, sampleSize)
+	}
+
+	// Per column: values, distinct values, and values seen exactly once
+	var selects []string
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		quoted := quoteIdentifier(name)
+		selects = append(selects,
+			fmt.Sprintf("(SELECT COUNT(%s) FROM sample)", quoted),
+			fmt.Sprintf("(SELECT COUNT(DISTINCT %s) FROM sample)", quoted),
+			fmt.Sprintf("(SELECT COUNT(*) FROM (SELECT 1 FROM sample WHERE %s IS NOT NULL GROUP BY %s HAVING COUNT(*) = 1))", quoted, quoted))
+	}
+	query := fmt.Sprintf("WITH sample AS MATERIALIZED (SELECT * FROM %s) SELECT (SELECT COUNT(*) FROM sample), %s",
+		source, strings.Join(selects, ", "))
+
+	counts := make([]int64, 1+len(selects))
+	ptrs := make([]interface{}, len(counts))
+	for i := range counts {
+		ptrs[i] = &counts[i]
+	}
+	if err := s.db.QueryRowContext(s.ctx(), query).Scan(ptrs...); err != nil {
+		return nil, fmt.Errorf("failed to count distinct values: %w", err)
+	}
+	result.SampleRows = counts[0]
+
+	// Sampled counts are scaled up to the whole table
+	scale := 1.0
+	if result.Sampled && result.SampleRows > 0 {
+		scale = float64(result.Rows) / float64(result.SampleRows)
+	}
+	for i, col := range columns {
+		name, _ := col["name"].(string)
+		values, distinct, singletons := counts[1+3*i], counts[2+3*i], counts[3+3*i]
+
+		column := ColumnCardinality{Column: name, NonNull: values, Distinct: distinct}
+		if result.Sampled {
+			column.NonNull = int64(math.Round(float64(values) * scale))
+			estimate := math.Sqrt(scale)*float64(singletons) + float64(distinct-singletons)
+			if values > 0 && float64(distinct)/float64(values) >= nearUniqueRatio {
+				// GEE badly underestimates a column that is unique in the sample, which is
+				// most likely unique in the table too
+				estimate = float64(distinct) * scale
+			}
+			column.Distinct = min(max(int64(math.Round(estimate)), distinct), column.NonNull)
+		}
+		classifyCardinality(&column)
+		result.Columns = append(result.Columns, column)
+	}
+	return result, nil
+}
+
+// classifyCardinality sets the ratio, class, and candidate flag of a column from its counts
+func classifyCardinality(column *ColumnCardinality) {
+	if column.NonNull == 0 {
+		column.Class = "empty"
+		return
+	}
+	column.Ratio = float64(column.Distinct) / float64(column.NonNull)
+	switch {
+	case column.Ratio >= nearUniqueRatio:
+		column.Class = "near_unique"
+	case column.Distinct <= LowCardinalityMax || column.Ratio <= lowCardinalityRatio:
+		column.Class = "low"
+		column.Candidate = true
+	default:
+		column.Class = "medium"
+	}
+}
@@ -0,0 +1,72 @@
+package database
+
+import "testing"
+
+func TestEstimateCardinality(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, email TEXT, status TEXT, amount INTEGER, note TEXT)",
+		"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 50000) "+
+			"INSERT INTO orders SELECT i, 'user' || i || '@example.com', CASE i % 4 WHEN 0 THEN 'new' WHEN 1 THEN 'paid' WHEN 2 THEN 'sent' ELSE 'done' END, i % 2000, NULL FROM n",
+	)
+	want := map[string]string{"id": "near_unique", "email": "near_unique", "status": "low", "amount": "medium", "note": "empty"}
+
+	for _, fullScan := range []bool{true, false} {
+		estimate, err := db.EstimateCardinality("orders", 5000, fullScan)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if estimate.Rows != 50000 || estimate.Sampled == fullScan {
+			t.Fatalf("full scan %v: %d rows, sampled %v", fullScan, estimate.Rows, estimate.Sampled)
+		}
+		if estimate.Sampled && (estimate.SampleRows < 4000 || estimate.SampleRows > 6000) {
+			t.Fatalf("sampled %d rows, want about 5000", estimate.SampleRows)
+		}
+		for _, column := range estimate.Columns {
+			if column.Class != want[column.Column] {
+				t.Errorf("full scan %v: %s classified %s (%d distinct of %d), want %s",
+					fullScan, column.Column, column.Class, column.Distinct, column.NonNull, want[column.Column])
+			}
+			if column.Candidate != (column.Class == "low") {
+				t.Errorf("%s candidate %v", column.Column, column.Candidate)
+			}
+			switch column.Column {
+			case "status":
+				if column.Distinct != 4 {
+					t.Errorf("full scan %v: status has %d distinct values, want 4", fullScan, column.Distinct)
+				}
+			case "email":
+				if fullScan && column.Distinct != 50000 {
+					t.Errorf("email has %d distinct values, want 50000", column.Distinct)
+				}
+				if !fullScan && (column.Distinct < 40000 || column.Distinct > 50000) {
+					t.Errorf("email estimated at %d distinct values, want about 50000", column.Distinct)
+				}
+			}
+		}
+	}
+
+	if _, err := db.EstimateCardinality("missing", 0, false); err == nil {
+		t.Fatal("estimated a missing table")
+	}
+}
+
+func TestClassifyCardinality(t *testing.T) {
+	tests := []struct {
+		nonNull, distinct int64
+		class             string
+	}{
+		{0, 0, "empty"},
+		{100, 100, "near_unique"},
+		{100, 95, "near_unique"},
+		{100, 50, "low"},
+		{100000, 1000, "low"},
+		{100000, 1001, "medium"},
+	}
+	for _, test := range tests {
+		column := ColumnCardinality{NonNull: test.nonNull, Distinct: test.distinct}
+		classifyCardinality(&column)
+		if column.Class != test.class {
+			t.Errorf("%d distinct of %d classified %s, want %s", test.distinct, test.nonNull, column.Class, test.class)
+		}
+	}
+}
@@ -0,0 +1,345 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// typeNamePattern accepts SQLite type names such as INTEGER, VARCHAR(255), DECIMAL(10, 2),
+	// or UNSIGNED BIG INT
+	typeNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\s+[A-Za-z_][A-Za-z0-9_]*)*\s*(\(\s*[+-]?\d+\s*(,\s*[+-]?\d+\s*)?\))?$`)
+	strictPattern   = regexp.MustCompile(`(?i)\)\s*(WITHOUT\s+ROWID\s*,\s*)?STRICT\s*(,\s*WITHOUT\s+ROWID\s*)?$`)
+	// strictTypes are the only column types STRICT tables accept
+	strictTypes = map[string]bool{"INT": true, "INTEGER": true, "REAL": true, "TEXT": true, "BLOB": true, "ANY": true}
+	// columnConstraintWords end the type name of a column definition
+	columnConstraintWords = map[string]bool{"CONSTRAINT": true, "PRIMARY": true, "NOT": true, "NULL": true, "UNIQUE": true,
+		"CHECK": true, "DEFAULT": true, "COLLATE": true, "REFERENCES": true, "GENERATED": true, "AS": true}
+	// tableConstraintWords start a table constraint rather than a column definition
+	tableConstraintWords = map[string]bool{"CONSTRAINT": true, "PRIMARY": true, "UNIQUE": true, "CHECK": true, "FOREIGN": true}
+)
+
+// ChangeColumnTypeResult reports the outcome of a column type change
+type ChangeColumnTypeResult struct {
+	Table         string `json:"table"`
+	Column        string `json:"column"`
+	OldType       string `json:"old_type"`
+	NewType       string `json:"new_type"`
+	RowsCopied    int64  `json:"rows_copied"`
+	ValuesChanged int64  `json:"values_changed"` // values whose text form the CAST changed, e.g. 'abc' becoming 0
+	// Recreated lists the indexes, triggers, and views rebuilt on the new table
+	Recreated []string `json:"recreated,omitempty"`
+}
+
+// ChangeColumnType changes the declared type of a column. SQLite cannot alter a column type
+// in place, so the table is rebuilt in one transaction: a copy is created from the original
+// CREATE TABLE statement with the new type, rows are copied with CAST, the original is
+// dropped, the copy renamed, and indexes, triggers, and views on the table are recreated.
+func (s *SQLiteDB) ChangeColumnType(tableName, columnName, newType string) (*ChangeColumnTypeResult, error) {
+	newType = strings.TrimSpace(newType)
+	if !typeNamePattern.MatchString(newType) {
+		return nil, fmt.Errorf("invalid column type '%s'", newType)
+	}
+
+	var tableSQL string
+	err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if virtualTablePattern.MatchString(tableSQL) {
+		return nil, fmt.Errorf("cannot change column types of virtual table '%s'", tableName)
+	}
+	if strictPattern.MatchString(tableSQL) && !strictTypes[strings.ToUpper(newType)] {
+		return nil, fmt.Errorf("'%s' is a STRICT table; the type must be one of INT, INTEGER, REAL, TEXT, BLOB, or ANY", tableName)
+	}
+
+	// table_xinfo includes generated columns, which are computed and cannot be copied
+	columns, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA table_xinfo(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	result := &ChangeColumnTypeResult{Table: tableName, NewType: newType}
+	var copied []string
+	var selected []string
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		if toInt64(col["hidden"]) != 0 {
+			continue
+		}
+		copied = append(copied, quoteIdentifier(name))
+		if strings.EqualFold(name, columnName) {
+			result.Column = name
+			result.OldType, _ = col["type"].(string)
+			selected = append(selected, fmt.Sprintf("CAST(%s AS %s)", quoteIdentifier(name), newType))
+		} else {
+			selected = append(selected, quoteIdentifier(name))
+		}
+	}
+	if result.Column == "" {
+		return nil, fmt.Errorf("column '%s' does not exist in table '%s' or is a generated column", columnName, tableName)
+	}
+
+	tempName := "_mcp_new_" + tableName
+	newSQL, err := rewriteColumnType(tableSQL, result.Column, newType, tempName)
+	if err != nil {
+		return nil, err
+	}
+
+	quotedColumn := quoteIdentifier(result.Column)
+	result.RowsCopied, result.Recreated, err = s.rebuildTable(tableName, tempName, newSQL, copied, selected, func(tx *sql.Tx) error {
+		// Comparing text forms avoids the column's affinity converting the CAST result back
+		return tx.QueryRowContext(s.ctx(), fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE CAST(CAST(%s AS %s) AS TEXT) IS NOT CAST(%s AS TEXT)",
+			quoteIdentifier(tableName), quotedColumn, newType, quotedColumn)).Scan(&result.ValuesChanged)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rebuildTable replaces a table with a new definition in one transaction: newSQL creates the
+// table as tempName, the selected expressions of every row are inserted into its copied
+// columns, the original is dropped, the copy renamed, and the table's indexes and triggers and
+// the views naming it are recreated. prepare, when not nil, runs first in the transaction and
+// can abort the rebuild by returning an error. Returns the number of rows copied and the
+// objects recreated.
+func (s *SQLiteDB) rebuildTable(tableName, tempName, newSQL string, copied, selected []string, prepare func(tx *sql.Tx) error) (int64, []string, error) {
+	// Indexes and triggers are dropped with the table; views that name it must be dropped
+	// before the rename and are recreated afterwards
+	dependents, err := s.ExecuteQuery(`
+		SELECT type, name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND ((type IN ('index', 'trigger') AND tbl_name = ?) OR type = 'view')
+		ORDER BY CASE type WHEN 'index' THEN 0 WHEN 'view' THEN 1 ELSE 2 END, name
+	`, tableName)
+	if err != nil {
+		return 0, nil, err
+	}
+	tablePattern := columnReferencePattern(tableName)
+	var views, recreate []map[string]interface{}
+	for _, obj := range dependents {
+		objSQL, _ := obj["sql"].(string)
+		if obj["type"] == "view" {
+			if !tablePattern.MatchString(objSQL) {
+				continue
+			}
+			views = append(views, obj)
+		}
+		recreate = append(recreate, obj)
+	}
+
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	// Foreign key enforcement would cascade or fail on the DROP TABLE, and can only be
+	// switched off outside a transaction
+	var foreignKeys int
+	if err := conn.QueryRowContext(ctx, "PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return 0, nil, err
+	}
+	if foreignKeys != 0 {
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return 0, nil, err
+		}
+		// Restored even if the rebuild was interrupted
+		defer conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	if prepare != nil {
+		if err := prepare(tx); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	for _, view := range views {
+		if _, err := tx.ExecContext(s.ctx(), fmt.Sprintf("DROP VIEW %s", quoteIdentifier(view["name"].(string)))); err != nil {
+			return 0, nil, err
+		}
+	}
+	if _, err := tx.ExecContext(s.ctx(), newSQL); err != nil {
+		return 0, nil, fmt.Errorf("failed to create the new table: %w", err)
+	}
+	copyResult, err := tx.ExecContext(s.ctx(), fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s", quoteIdentifier(tempName),
+		strings.Join(copied, ", "), strings.Join(selected, ", "), quoteIdentifier(tableName)))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to copy rows: %w", err)
+	}
+	rowsCopied, _ := copyResult.RowsAffected()
+
+	if _, err := tx.ExecContext(s.ctx(), fmt.Sprintf("DROP TABLE %s", quoteIdentifier(tableName))); err != nil {
+		return 0, nil, err
+	}
+	if _, err := tx.ExecContext(s.ctx(), fmt.Sprintf("ALTER TABLE %s RENAME TO %s", quoteIdentifier(tempName), quoteIdentifier(tableName))); err != nil {
+		return 0, nil, err
+	}
+	var recreated []string
+	for _, obj := range recreate {
+		objSQL, _ := obj["sql"].(string)
+		if _, err := tx.ExecContext(s.ctx(), objSQL); err != nil {
+			return 0, nil, fmt.Errorf("failed to recreate %s %v: %w", obj["type"], obj["name"], err)
+		}
+		recreated = append(recreated, fmt.Sprintf("%s %v", obj["type"], obj["name"]))
+	}
+
+	if foreignKeys != 0 {
+		var violations int
+		if err := tx.QueryRowContext(s.ctx(), "SELECT COUNT(*) FROM pragma_foreign_key_check").Scan(&violations); err != nil {
+			return 0, nil, err
+		}
+		if violations > 0 {
+			return 0, nil, fmt.Errorf("rebuilding the table would leave %d foreign key violation(s); no changes were made", violations)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, nil, s.diskFullError(err)
+	}
+	return rowsCopied, recreated, nil
+}
+
+// rewriteColumnType returns a CREATE TABLE statement for newName that matches tableSQL
+// except for the type of one column. Constraints, defaults, collations, and table options
+// are kept as written.
+func rewriteColumnType(tableSQL, columnName, newType, newName string) (string, error) {
+	def, err := locateColumn(tableSQL, columnName)
+	if err != nil {
+		return "", err
+	}
+	rewritten := tableSQL[:def.nameEnd] + " " + newType + tableSQL[def.typeEnd:]
+	return fmt.Sprintf("CREATE TABLE %s %s", quoteIdentifier(newName), rewritten[def.open:]), nil
+}
+
+// columnDefinition gives the offsets of one column definition in a CREATE TABLE statement
+type columnDefinition struct {
+	open    int // the parenthesis opening the definitions
+	start   int // just past the comma or parenthesis before the definition
+	nameEnd int // just past the column name
+	typeEnd int // just past the type name, or nameEnd when the column has no type
+	end     int // the comma or parenthesis ending the definition
+}
+
+// locateColumn finds the definition of a column in a CREATE TABLE statement
+func locateColumn(tableSQL, columnName string) (columnDefinition, error) {
+	open := indexTopLevel(tableSQL, 0, '(')
+	if open < 0 {
+		return columnDefinition{}, fmt.Errorf("cannot parse table definition")
+	}
+
+	// Column definitions are separated by commas outside parentheses and quotes
+	start := open + 1
+	for start < len(tableSQL) {
+		end := indexTopLevel(tableSQL, start, ',')
+		closing := indexTopLevel(tableSQL, start, ')')
+		if end < 0 || (closing >= 0 && closing < end) {
+			end = closing
+		}
+		if end < 0 {
+			break
+		}
+
+		def := tableSQL[start:end]
+		trimmed := strings.TrimLeft(def, " \t\r\n")
+		name, rest := splitLeadingIdentifier(trimmed)
+		if !tableConstraintWords[strings.ToUpper(name)] && strings.EqualFold(unquoteIdentifier(name), columnName) {
+			// The type is every word up to the first constraint keyword, plus an optional (n, m)
+			typeEnd := 0
+			for {
+				word := strings.TrimLeft(rest[typeEnd:], " \t\r\n")
+				next, _ := splitLeadingIdentifier(word)
+				if next == "" || columnConstraintWords[strings.ToUpper(next)] || strings.HasPrefix(next, `"`) {
+					if strings.HasPrefix(word, "(") {
+						if closeParen := strings.Index(word, ")"); closeParen >= 0 {
+							typeEnd = len(rest) - len(word) + closeParen + 1
+						}
+					}
+					break
+				}
+				typeEnd = len(rest) - len(word) + len(next)
+			}
+			nameEnd := start + (len(def) - len(trimmed)) + len(name)
+			return columnDefinition{open: open, start: start, nameEnd: nameEnd, typeEnd: nameEnd + typeEnd, end: end}, nil
+		}
+
+		if tableSQL[end] == ')' {
+			break
+		}
+		start = end + 1
+	}
+
+	return columnDefinition{}, fmt.Errorf("column '%s' not found in the table definition", columnName)
+}
+
+// indexTopLevel returns the index of the first occurrence of target at or after from that is
+// outside quotes, and for anything but '(' also outside nested parentheses
+func indexTopLevel(text string, from int, target byte) int {
+	depth := 0
+	for i := from; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := strings.IndexByte(text[i+1:], c)
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+			continue
+		case c == '[':
+			end := strings.IndexByte(text[i+1:], ']')
+			if end < 0 {
+				return -1
+			}
+			i += end + 1
+			continue
+		}
+		if c == target && (depth == 0 || target == '(') {
+			return i
+		}
+		if c == '(' {
+			depth++
+		} else if c == ')' {
+			depth--
+		}
+	}
+	return -1
+}
+
+// splitLeadingIdentifier splits a quoted or bare identifier off the start of text
+func splitLeadingIdentifier(text string) (string, string) {
+	if text == "" {
+		return "", ""
+	}
+	if closer, ok := map[byte]byte{'"': '"', '`': '`', '[': ']'}[text[0]]; ok {
+		// A doubled quote inside a quoted identifier is an escaped quote
+		for i := 1; i < len(text); i++ {
+			if text[i] == closer {
+				if closer == '"' && i+1 < len(text) && text[i+1] == '"' {
+					i++
+					continue
+				}
+				return text[:i+1], text[i+1:]
+			}
+		}
+		return text, ""
+	}
+	end := 0
+	for end < len(text) && (text[end] == '_' || text[end] == '$' || ('0' <= text[end] && text[end] <= '9') ||
+		('a' <= text[end]|0x20 && text[end]|0x20 <= 'z') || text[end] >= 0x80) {
+		end++
+	}
+	return text[:end], text[end:]
+}
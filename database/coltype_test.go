@@ -0,0 +1,89 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestChangeColumnTypeTextToInteger(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE readings (id INTEGER PRIMARY KEY, sensor TEXT NOT NULL, value TEXT DEFAULT '0')",
+		"CREATE INDEX idx_readings_value ON readings (value)",
+		"CREATE UNIQUE INDEX idx_readings_sensor ON readings (sensor, value)",
+		"CREATE TABLE log (reading_id INTEGER)",
+		"CREATE TRIGGER readings_log AFTER INSERT ON readings BEGIN INSERT INTO log VALUES (NEW.id); END",
+		"CREATE VIEW high AS SELECT id, value FROM readings WHERE value > 10",
+		"INSERT INTO readings VALUES (1, 'a', '12'), (2, 'b', '7'), (3, 'c', 'abc')",
+	)
+
+	result, err := db.ChangeColumnType("readings", "value", "INTEGER")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.OldType != "TEXT" || result.NewType != "INTEGER" || result.RowsCopied != 3 || result.ValuesChanged != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	sort.Strings(result.Recreated)
+	if want := []string{"index idx_readings_sensor", "index idx_readings_value", "trigger readings_log", "view high"}; strings.Join(result.Recreated, ",") != strings.Join(want, ",") {
+		t.Fatalf("recreated %v, want %v", result.Recreated, want)
+	}
+
+	rows, err := db.ExecuteQuery("SELECT id, sensor, value, typeof(value) AS kind FROM readings ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		sensor string
+		value  int64
+	}{{"a", 12}, {"b", 7}, {"c", 0}}
+	for i, row := range rows {
+		if row["sensor"] != want[i].sensor || row["value"] != want[i].value || row["kind"] != "integer" {
+			t.Fatalf("row %d is %v", i, row)
+		}
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM pragma_table_info('readings') WHERE name = 'value' AND type = 'INTEGER'"); n != 1 {
+		t.Fatal("value is not declared INTEGER")
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'readings' AND name LIKE 'idx_%'"); n != 2 {
+		t.Fatalf("%d indexes left on readings", n)
+	}
+	// The unique index is still enforced and the trigger still fires
+	if _, err := db.ExecuteStatement("INSERT INTO readings VALUES (4, 'a', 12)"); err == nil {
+		t.Fatal("the unique index was lost")
+	}
+	if _, err := db.ExecuteStatement("INSERT INTO readings VALUES (5, 'd', 20)"); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM log WHERE reading_id = 5"); n != 1 {
+		t.Fatal("the trigger was lost")
+	}
+	// Values compare as integers now, so 7 is no longer above 10 as the text '7' was
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM high"); n != 2 {
+		t.Fatalf("the view returns %d rows, want 2", n)
+	}
+	if hasTable(t, db, "_mcp_new_readings") {
+		t.Fatal("the temporary table was left behind")
+	}
+}
+
+func TestChangeColumnTypeRejects(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT)",
+		"CREATE TABLE s (id INTEGER PRIMARY KEY, v TEXT) STRICT",
+	)
+	for _, test := range []struct{ table, column, typ string }{
+		{"t", "v", "INTEGER; DROP TABLE t"},
+		{"t", "v", ""},
+		{"t", "missing", "INTEGER"},
+		{"missing", "v", "INTEGER"},
+		{"s", "v", "VARCHAR(10)"},
+	} {
+		if _, err := db.ChangeColumnType(test.table, test.column, test.typ); err == nil {
+			t.Errorf("changed %s.%s to %q", test.table, test.column, test.typ)
+		}
+	}
+	if !hasTable(t, db, "t") {
+		t.Fatal("t was dropped")
+	}
+}
@@ -0,0 +1,299 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// connector opens pool connections through a driver whose ConnectHook configures each one
+type connector struct {
+	driver *sqlite3.SQLiteDriver
+	dsn    string
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
+
+// open opens a connection pool for dbPath. Per-connection settings such as PRAGMAs and SQL
+// functions only affect the connection they are set on, so they are applied from a
+// ConnectHook to every connection the pool creates.
+func (s *SQLiteDB) open(dbPath string) (*sql.DB, error) {
+	drv := &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if err := s.registerFunctions(conn); err != nil {
+				return err
+			}
+			for _, pragma := range s.connectionPragmas() {
+				if _, err := conn.Exec(pragma, nil); err != nil {
+					return fmt.Errorf("failed to apply %s: %w", pragma, err)
+				}
+			}
+			statements, files := s.attachStatements()
+			for i, statement := range statements {
+				if _, err := conn.Exec(statement, []driver.Value{files[i]}); err != nil {
+					return fmt.Errorf("failed to attach '%s': %w", files[i], err)
+				}
+			}
+			return nil
+		},
+	}
+
+	db := sql.OpenDB(&connector{driver: drv, dsn: s.dsn(dbPath)})
+	s.settingsMu.RLock()
+	if s.serialized {
+		db.SetMaxOpenConns(1)
+	}
+	s.settingsMu.RUnlock()
+
+	// Test connection
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	return db, nil
+}
+
+// uriEscaper escapes the characters that end the path of an SQLite URI filename
+var uriEscaper = strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+
+// dsn returns the data source name used to open dbPath with the current settings
+func (s *SQLiteDB) dsn(dbPath string) string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+
+	if s.readOnly {
+		return "file:" + uriEscaper.Replace(dbPath) + "?mode=ro"
+	}
+	return dbPath
+}
+
+// SetReadOnly opens the database, and databases switched to later, with mode=ro so that
+// SQLite rejects every write
+func (s *SQLiteDB) SetReadOnly(readOnly bool) error {
+	s.settingsMu.Lock()
+	previous := s.readOnly
+	s.readOnly = readOnly
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.readOnly = previous
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// connectionPragmas returns the PRAGMA statements run on every new connection
+func (s *SQLiteDB) connectionPragmas() []string {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+
+	var pragmas []string
+	// Changing the journal mode writes to the database, which mode=ro does not allow
+	if s.journalMode != "" && !s.readOnly {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA journal_mode = %s", s.journalMode))
+	}
+	if s.foreignKeys {
+		pragmas = append(pragmas, "PRAGMA foreign_keys = ON")
+	}
+	if s.journalSizeLimit != nil {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA journal_size_limit = %d", *s.journalSizeLimit))
+	}
+	if s.tempStore != nil {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA temp_store = %s", *s.tempStore))
+	}
+	if s.tempDirectory != nil {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA temp_store_directory = '%s'", strings.ReplaceAll(*s.tempDirectory, "'", "''")))
+	}
+	if s.cacheSize != nil {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA cache_size = %d", *s.cacheSize))
+	}
+	if s.mmapSize != nil {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA mmap_size = %d", *s.mmapSize))
+	}
+	pragmas = append(pragmas, fmt.Sprintf("PRAGMA busy_timeout = %d", s.busyTimeout.Milliseconds()))
+	return pragmas
+}
+
+// reopen replaces the connection pool so that changed connection settings reach every
+// connection, not only those created after the change
+func (s *SQLiteDB) reopen() error {
+	db, err := s.open(s.dbPath)
+	if err != nil {
+		return err
+	}
+	s.closeCursors()
+	old := s.db
+	s.db = db
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// JournalModes lists the values accepted by SetJournalMode
+var JournalModes = []string{"DELETE", "TRUNCATE", "PERSIST", "MEMORY", "WAL", "OFF"}
+
+// SetJournalMode sets PRAGMA journal_mode on all connections and returns the mode now in
+// effect, which can differ from the requested one, for example an in-memory database only
+// supports MEMORY and OFF. WAL is persistent in the database file while the other modes only
+// last as long as a connection, so the mode is reapplied to every new connection and kept
+// when switching databases.
+func (s *SQLiteDB) SetJournalMode(mode string) (string, error) {
+	mode = strings.ToUpper(mode)
+	valid := false
+	for _, m := range JournalModes {
+		valid = valid || m == mode
+	}
+	if !valid {
+		return "", fmt.Errorf("invalid journal mode '%s', must be one of %s", mode, strings.Join(JournalModes, ", "))
+	}
+
+	s.settingsMu.Lock()
+	previous := s.journalMode
+	s.journalMode = mode
+	s.settingsMu.Unlock()
+
+	// Leaving WAL mode needs the database to itself, so the idle connections of the current
+	// pool are closed before the new pool opens rather than after
+	leavingWAL := strings.EqualFold(previous, "WAL") && mode != "WAL"
+	if leavingWAL {
+		s.closeCursors()
+		s.db.SetMaxIdleConns(0)
+	}
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.journalMode = previous
+		s.settingsMu.Unlock()
+		if leavingWAL {
+			// The default of database/sql
+			s.db.SetMaxIdleConns(2)
+		}
+		return "", err
+	}
+
+	var current string
+	if err := s.db.QueryRowContext(s.ctx(), "PRAGMA journal_mode").Scan(&current); err != nil {
+		return "", err
+	}
+	return strings.ToUpper(current), nil
+}
+
+// SetJournalSizeLimit sets PRAGMA journal_size_limit, in bytes, on all connections so the
+// WAL or rollback journal is truncated back to this size after checkpoints and transactions.
+// A negative limit removes the bound. The setting is kept when switching databases.
+func (s *SQLiteDB) SetJournalSizeLimit(limit int64) error {
+	s.settingsMu.Lock()
+	previous := s.journalSizeLimit
+	s.journalSizeLimit = &limit
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.journalSizeLimit = previous
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// GetJournalSizeLimit reads the journal size limit in effect on a pool connection
+func (s *SQLiteDB) GetJournalSizeLimit() (int64, error) {
+	var limit int64
+	err := s.db.QueryRowContext(s.ctx(), "PRAGMA journal_size_limit").Scan(&limit)
+	return limit, err
+}
+
+// tempStoreModes maps PRAGMA temp_store values to their names
+var tempStoreModes = []string{"DEFAULT", "FILE", "MEMORY"}
+
+// SetTempStorage sets where SQLite keeps temporary tables and indices, and the sort and join
+// spill files of complex queries. store is DEFAULT, FILE, or MEMORY and directory is the
+// directory for temp files; an empty argument leaves that setting unchanged, and a directory
+// of "default" restores SQLite's own choice. The temp directory is process-wide in SQLite.
+func (s *SQLiteDB) SetTempStorage(store, directory string) error {
+	if store != "" {
+		store = strings.ToUpper(store)
+		valid := false
+		for _, mode := range tempStoreModes {
+			if store == mode {
+				valid = true
+			}
+		}
+		if !valid {
+			return fmt.Errorf("temp_store must be DEFAULT, FILE, or MEMORY")
+		}
+	}
+	if directory != "" && directory != "default" {
+		info, err := os.Stat(directory)
+		if err != nil {
+			return fmt.Errorf("temp directory: %w", err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("temp directory '%s' is not a directory", directory)
+		}
+	}
+
+	s.settingsMu.Lock()
+	previousStore, previousDirectory := s.tempStore, s.tempDirectory
+	if store != "" {
+		s.tempStore = &store
+	}
+	if directory == "default" {
+		empty := ""
+		s.tempDirectory = &empty
+	} else if directory != "" {
+		s.tempDirectory = &directory
+	}
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.tempStore, s.tempDirectory = previousStore, previousDirectory
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// GetTempStorage reads the temp_store mode and temp directory in effect on a pool connection
+func (s *SQLiteDB) GetTempStorage() (map[string]interface{}, error) {
+	var store int
+	if err := s.db.QueryRowContext(s.ctx(), "PRAGMA temp_store").Scan(&store); err != nil {
+		return nil, err
+	}
+
+	// An unset temp directory returns no row
+	var directory string
+	err := s.db.QueryRowContext(s.ctx(), "PRAGMA temp_store_directory").Scan(&directory)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	mode := "UNKNOWN"
+	if store >= 0 && store < len(tempStoreModes) {
+		mode = tempStoreModes[store]
+	}
+
+	result := map[string]interface{}{
+		"temp_store":     mode,
+		"temp_directory": directory,
+	}
+	if directory == "" {
+		result["temp_directory"] = "default (SQLITE_TMPDIR, TMPDIR, or /tmp)"
+	}
+	return result, nil
+}
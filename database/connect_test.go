@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+// pragmaOnEachConnection reads an integer pragma on n connections held at once, so each
+// is a distinct pool connection
+func pragmaOnEachConnection(t *testing.T, db *SQLiteDB, pragma string, n int) []int64 {
+	t.Helper()
+	var values []int64
+	for i := 0; i < n; i++ {
+		conn, err := db.db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		var value int64
+		if err := conn.QueryRowContext(context.Background(), "PRAGMA "+pragma).Scan(&value); err != nil {
+			t.Fatal(err)
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+func TestSetJournalSizeLimit(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.SetJournalSizeLimit(4 << 20); err != nil {
+		t.Fatal(err)
+	}
+	if limit, err := db.GetJournalSizeLimit(); err != nil || limit != 4<<20 {
+		t.Fatalf("journal_size_limit reads back %d, %v", limit, err)
+	}
+	// Applied to every connection of the pool, not only the one that ran the pragma
+	for _, limit := range pragmaOnEachConnection(t, db, "journal_size_limit", 3) {
+		if limit != 4<<20 {
+			t.Fatalf("a connection has journal_size_limit %d", limit)
+		}
+	}
+	stats, err := db.GetDatabaseStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["journal_size_limit"] != int64(4<<20) {
+		t.Fatalf("database_stats reports journal_size_limit %v", stats["journal_size_limit"])
+	}
+}
+
+func TestSetTempStorage(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.SetTempStorage("memory", ""); err != nil {
+		t.Fatal(err)
+	}
+	for _, store := range pragmaOnEachConnection(t, db, "temp_store", 3) {
+		if store != 2 {
+			t.Fatalf("a connection has temp_store %d, want 2 (MEMORY)", store)
+		}
+	}
+
+	// The temp directory is process-wide, so it is restored for the other tests
+	dir := t.TempDir()
+	if err := db.SetTempStorage("", dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.SetTempStorage("", "default") })
+	storage, err := db.GetTempStorage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if storage["temp_store"] != "MEMORY" || storage["temp_directory"] != dir {
+		t.Fatalf("temp storage reads back as %v", storage)
+	}
+
+	if err := db.SetTempStorage("disk", ""); err == nil {
+		t.Error("accepted temp_store disk")
+	}
+	if err := db.SetTempStorage("", dir+"/missing"); err == nil {
+		t.Error("accepted a missing temp directory")
+	}
+	if storage, _ := db.GetTempStorage(); storage["temp_store"] != "MEMORY" {
+		t.Errorf("a rejected setting changed temp_store to %v", storage["temp_store"])
+	}
+}
@@ -0,0 +1,138 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MaxReportedViolations caps the violating rows a rejected constraint lists
+const MaxReportedViolations = 20
+
+// AddConstraintResult reports the outcome of adding a constraint to a column
+type AddConstraintResult struct {
+	Table      string `json:"table"`
+	Column     string `json:"column"`
+	Constraint string `json:"constraint"`
+	RowsCopied int64  `json:"rows_copied"`
+	// Recreated lists the indexes, triggers, and views rebuilt on the new table
+	Recreated []string `json:"recreated,omitempty"`
+}
+
+// ConstraintViolationError is returned when existing rows do not satisfy a constraint being
+// added; Rows holds up to MaxReportedViolations of them, with their rowid when the table has one
+type ConstraintViolationError struct {
+	Constraint string
+	Column     string
+	Count      int64
+	Rows       []map[string]interface{}
+}
+
+func (e *ConstraintViolationError) Error() string {
+	return fmt.Sprintf("%d row(s) violate %s on column '%s'; no changes were made", e.Count, e.Constraint, e.Column)
+}
+
+// AddColumnConstraint adds a NOT NULL constraint, a CHECK constraint, or both to an existing
+// column. SQLite cannot add either in place, so the table is rebuilt like ChangeColumnType
+// does, with the constraint appended to the column's definition. The existing rows are
+// checked first, in the same transaction; if any violate the constraint the table is left
+// unchanged and a *ConstraintViolationError lists them.
+func (s *SQLiteDB) AddColumnConstraint(tableName, columnName string, notNull bool, check string) (*AddConstraintResult, error) {
+	check = strings.TrimSpace(check)
+	if !notNull && check == "" {
+		return nil, fmt.Errorf("no constraint specified; set not_null or give a check expression")
+	}
+	if check != "" {
+		// The expression is spliced into SQL, so it must not close the CHECK's parenthesis early
+		if indexTopLevel(check, 0, ')') >= 0 || !IsSingleStatement("SELECT "+check) {
+			return nil, fmt.Errorf("invalid check expression '%s'", check)
+		}
+	}
+
+	var tableSQL string
+	err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if virtualTablePattern.MatchString(tableSQL) {
+		return nil, fmt.Errorf("cannot add constraints to virtual table '%s'", tableName)
+	}
+
+	// table_xinfo includes generated columns, which are computed and cannot be copied
+	columns, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA table_xinfo(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	result := &AddConstraintResult{Table: tableName}
+	var copied []string
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		if strings.EqualFold(name, columnName) {
+			result.Column = name
+			if notNull && toInt64(col["notnull"]) != 0 {
+				return nil, fmt.Errorf("column '%s' is already NOT NULL", name)
+			}
+		}
+		if toInt64(col["hidden"]) == 0 {
+			copied = append(copied, quoteIdentifier(name))
+		}
+	}
+	if result.Column == "" {
+		return nil, fmt.Errorf("column '%s' does not exist in table '%s'", columnName, tableName)
+	}
+
+	var constraints, violations []string
+	quotedColumn := quoteIdentifier(result.Column)
+	if notNull {
+		constraints = append(constraints, "NOT NULL")
+		violations = append(violations, fmt.Sprintf("%s IS NULL", quotedColumn))
+	}
+	if check != "" {
+		constraints = append(constraints, fmt.Sprintf("CHECK (%s)", check))
+		// A CHECK only fails when its expression is false; NULL passes
+		violations = append(violations, fmt.Sprintf("NOT (%s)", check))
+	}
+	result.Constraint = strings.Join(constraints, " ")
+
+	def, err := locateColumn(tableSQL, result.Column)
+	if err != nil {
+		return nil, err
+	}
+	tempName := "_mcp_new_" + tableName
+	rewritten := strings.TrimRight(tableSQL[:def.end], " \t") + " " + result.Constraint + tableSQL[def.end:]
+	newSQL := fmt.Sprintf("CREATE TABLE %s %s", quoteIdentifier(tempName), rewritten[def.open:])
+
+	where := strings.Join(violations, " OR ")
+	result.RowsCopied, result.Recreated, err = s.rebuildTable(tableName, tempName, newSQL, copied, copied, func(tx *sql.Tx) error {
+		var count int64
+		if err := tx.QueryRowContext(s.ctx(), fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", quoteIdentifier(tableName), where)).Scan(&count); err != nil {
+			return fmt.Errorf("failed to check existing rows: %w", err)
+		}
+		if count == 0 {
+			return nil
+		}
+
+		selectList := "*"
+		if !withoutRowidPattern.MatchString(tableSQL) {
+			selectList = "rowid AS _rowid_, *"
+		}
+		rows, err := tx.QueryContext(s.ctx(), fmt.Sprintf("SELECT %s FROM %s WHERE %s LIMIT %d",
+			selectList, quoteIdentifier(tableName), where, MaxReportedViolations))
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		offending, err := scanRows(rows, 0)
+		if err != nil {
+			return err
+		}
+		return &ConstraintViolationError{Constraint: result.Constraint, Column: result.Column, Count: count, Rows: offending}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
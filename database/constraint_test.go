@@ -0,0 +1,90 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAddNotNullConstraint(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)",
+		"CREATE INDEX idx_people_name ON people (name)",
+		"CREATE TABLE audit (person_id INTEGER)",
+		"CREATE TRIGGER people_audit AFTER INSERT ON people BEGIN INSERT INTO audit VALUES (NEW.id); END",
+		"INSERT INTO people VALUES (1, 'ann', 30), (2, 'bo', NULL)",
+	)
+
+	result, err := db.AddColumnConstraint("people", "name", true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Constraint != "NOT NULL" || result.RowsCopied != 2 || len(result.Recreated) != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM pragma_table_info('people') WHERE name = 'name' AND \"notnull\" = 1"); n != 1 {
+		t.Fatal("name is not NOT NULL")
+	}
+	if _, err := db.ExecuteStatement("INSERT INTO people VALUES (3, NULL, 1)"); err == nil {
+		t.Fatal("inserted a NULL name")
+	}
+	if _, err := db.ExecuteStatement("INSERT INTO people VALUES (3, 'cy', 1)"); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM audit"); n != 3 {
+		t.Fatalf("the trigger was lost, audit has %d rows", n)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM sqlite_master WHERE name = 'idx_people_name'"); n != 1 {
+		t.Fatal("the index was lost")
+	}
+
+	if _, err := db.AddColumnConstraint("people", "name", true, ""); err == nil {
+		t.Fatal("added NOT NULL twice")
+	}
+}
+
+func TestAddConstraintRejectsViolatingRows(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT, age INTEGER)",
+		"INSERT INTO people VALUES (1, 'ann', 30), (2, NULL, 5), (3, 'cy', -1), (4, NULL, NULL)",
+	)
+	before := queryInt(t, db, "SELECT count(*) FROM sqlite_master")
+
+	_, err := db.AddColumnConstraint("people", "name", true, "")
+	var violation *ConstraintViolationError
+	if !errors.As(err, &violation) {
+		t.Fatalf("got %v, want a ConstraintViolationError", err)
+	}
+	if violation.Count != 2 || len(violation.Rows) != 2 || violation.Rows[0]["id"] != int64(2) || violation.Rows[1]["id"] != int64(4) {
+		t.Fatalf("violation %+v, want rows 2 and 4", violation)
+	}
+	if !strings.Contains(err.Error(), "2 row(s) violate NOT NULL on column 'name'; no changes were made") {
+		t.Fatalf("unexpected message %v", err)
+	}
+
+	// NULL passes a CHECK, so only row 3 violates it
+	_, err = db.AddColumnConstraint("people", "age", false, "age >= 0")
+	if !errors.As(err, &violation) || violation.Count != 1 || violation.Rows[0]["id"] != int64(3) {
+		t.Fatalf("got %v, want row 3 to violate the check", err)
+	}
+
+	// The table is unchanged and still accepts NULLs
+	if n := queryInt(t, db, "SELECT count(*) FROM people"); n != 4 {
+		t.Fatalf("people has %d rows", n)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM sqlite_master"); n != before {
+		t.Fatalf("the schema changed from %d to %d objects", before, n)
+	}
+	if _, err := db.ExecuteStatement("INSERT INTO people VALUES (5, NULL, -5)"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, check := range []string{"age > 0) OR (1", "1; DROP TABLE people"} {
+		if _, err := db.AddColumnConstraint("people", "age", false, check); err == nil || errors.As(err, &violation) {
+			t.Errorf("check %q: got %v, want it rejected", check, err)
+		}
+	}
+	if _, err := db.AddColumnConstraint("people", "age", false, ""); err == nil {
+		t.Error("accepted no constraint")
+	}
+}
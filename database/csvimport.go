@@ -0,0 +1,292 @@
+package database
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// DefaultCSVInferRows is the number of data rows ImportCSV inspects to infer column types
+const DefaultCSVInferRows = 100
+
+// CSVImportOptions controls how ImportCSV reads a file
+type CSVImportOptions struct {
+	// HasHeader means the first record names the columns
+	HasHeader bool
+	// CreateTable creates the table from the file when it does not exist
+	CreateTable bool
+	// Delimiter separates fields; zero means a comma
+	Delimiter rune
+	// InferRows is the number of data rows used to infer column types; zero means DefaultCSVInferRows
+	InferRows int
+}
+
+// CSVColumn is one column of an imported CSV file
+type CSVColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type,omitempty"`
+}
+
+// CSVImport describes the rows ImportCSV loaded into a table
+type CSVImport struct {
+	Table   string      `json:"table"`
+	Created bool        `json:"created"`
+	Rows    int64       `json:"rows"`
+	Columns []CSVColumn `json:"columns"`
+}
+
+// ImportCSV loads the records of a CSV file into a table. Quoted fields may contain the
+// delimiter, quotes, and newlines; empty fields are stored as NULL. With a header the columns
+// are matched by name, otherwise by position. When the table does not exist and
+// options.CreateTable is set, it is created with INTEGER, REAL, or TEXT columns inferred from
+// the first options.InferRows data rows. All rows are inserted in one transaction, so a
+// malformed record or a failed insert leaves the table as it was; errors name the line of
+// the file they occurred on.
+func (s *SQLiteDB) ImportCSV(path, tableName string, options CSVImportOptions) (*CSVImport, error) {
+	if tableName == "" {
+		return nil, fmt.Errorf("table name is required")
+	}
+	if strings.HasPrefix(strings.ToLower(tableName), "sqlite_") {
+		return nil, fmt.Errorf("table names beginning with 'sqlite_' are reserved")
+	}
+	if options.Delimiter == 0 {
+		options.Delimiter = ','
+	}
+	if options.InferRows <= 0 {
+		options.InferRows = DefaultCSVInferRows
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(bufio.NewReader(file))
+	reader.Comma = options.Delimiter
+	read := func() ([]string, error) {
+		record, err := reader.Read()
+		if err != nil && err != io.EOF {
+			// csv.ParseError already names the line and column
+			return nil, fmt.Errorf("invalid CSV: %w", err)
+		}
+		return record, err
+	}
+
+	result := &CSVImport{Table: tableName}
+	first, err := read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the CSV file is empty")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Data rows read ahead to infer the column types, and the line each starts on
+	var pending [][]string
+	var pendingLines []int
+	if options.HasHeader {
+		for i, name := range first {
+			if i == 0 {
+				name = strings.TrimPrefix(name, "\ufeff")
+			}
+			if name = strings.TrimSpace(name); name == "" {
+				name = fmt.Sprintf("column%d", i+1)
+			}
+			result.Columns = append(result.Columns, CSVColumn{Name: name})
+		}
+	} else {
+		for i := range first {
+			result.Columns = append(result.Columns, CSVColumn{Name: fmt.Sprintf("column%d", i+1)})
+		}
+		line, _ := reader.FieldPos(0)
+		pending, pendingLines = append(pending, first), append(pendingLines, line)
+	}
+
+	var exists int
+	if err := s.db.QueryRowContext(s.ctx(), "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	quoted := make([]string, len(result.Columns))
+	if exists == 0 {
+		if !options.CreateTable {
+			return nil, fmt.Errorf("table '%s' does not exist; set create_table to create it from the file", tableName)
+		}
+		for len(pending) < options.InferRows {
+			record, err := read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			line, _ := reader.FieldPos(0)
+			pending, pendingLines = append(pending, record), append(pendingLines, line)
+		}
+
+		seen := make(map[string]bool)
+		columns := make([]map[string]string, len(result.Columns))
+		for i := range result.Columns {
+			col := &result.Columns[i]
+			if seen[strings.ToLower(col.Name)] {
+				return nil, fmt.Errorf("the header names column '%s' more than once", col.Name)
+			}
+			seen[strings.ToLower(col.Name)] = true
+			col.Type = inferCSVType(pending, i)
+			quoted[i] = quoteIdentifier(col.Name)
+			columns[i] = map[string]string{"name": col.Name, "type": col.Type}
+		}
+		if err := s.CreateTable(tableName, columns); err != nil {
+			return nil, fmt.Errorf("failed to create table: %w", err)
+		}
+		result.Created = true
+	} else {
+		tableColumns, err := s.csvTableColumns(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if options.HasHeader {
+			present := make(map[string]string)
+			for _, name := range tableColumns {
+				present[strings.ToLower(name)] = name
+			}
+			var missing []string
+			for i, col := range result.Columns {
+				if name, ok := present[strings.ToLower(col.Name)]; ok {
+					quoted[i] = quoteIdentifier(name)
+				} else {
+					missing = append(missing, col.Name)
+				}
+			}
+			if len(missing) > 0 {
+				return nil, fmt.Errorf("table '%s' has no column(s) %s", tableName, strings.Join(missing, ", "))
+			}
+		} else {
+			if len(result.Columns) > len(tableColumns) {
+				return nil, fmt.Errorf("the file has %d fields per record but table '%s' has only %d columns", len(result.Columns), tableName, len(tableColumns))
+			}
+			for i := range result.Columns {
+				result.Columns[i].Name = tableColumns[i]
+				quoted[i] = quoteIdentifier(tableColumns[i])
+			}
+		}
+	}
+
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(tableName), strings.Join(quoted, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(quoted)), ", "))
+
+	err = s.Transaction(func(tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(s.ctx(), insertSQL)
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		args := make([]interface{}, len(quoted))
+		insert := func(record []string, line int) error {
+			for i, field := range record {
+				if field == "" {
+					args[i] = nil
+				} else {
+					args[i] = field
+				}
+			}
+			if _, err := stmt.ExecContext(s.ctx(), args...); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			result.Rows++
+			return nil
+		}
+
+		for i, record := range pending {
+			if err := insert(record, pendingLines[i]); err != nil {
+				return err
+			}
+		}
+		for {
+			record, err := read()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			line, _ := reader.FieldPos(0)
+			if err := insert(record, line); err != nil {
+				return err
+			}
+		}
+	})
+	if err != nil {
+		if result.Created {
+			// The table was created for this import, so it goes with the rows
+			if dropErr := s.DropTable(tableName); dropErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to drop the new table: %w", dropErr))
+			}
+		}
+		return nil, err
+	}
+	return result, nil
+}
+
+// csvTableColumns lists the insertable columns of a table in order
+func (s *SQLiteDB) csvTableColumns(tableName string) ([]string, error) {
+	rows, err := s.db.QueryContext(s.ctx(), "SELECT name FROM pragma_table_xinfo(?) WHERE hidden = 0 ORDER BY cid", tableName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns = append(columns, name)
+	}
+	return columns, rows.Err()
+}
+
+// inferCSVType returns INTEGER when every non-empty field of column is an integer, REAL when
+// every one is a number, and TEXT otherwise or when the column has no values. Numbers with
+// leading zeros, such as postal codes, are text, since a numeric column would drop the zeros.
+func inferCSVType(records [][]string, column int) string {
+	integer, number := true, true
+	values := 0
+	for _, record := range records {
+		field := record[column]
+		if field == "" {
+			continue
+		}
+		values++
+		digits := strings.TrimLeft(field, "+-")
+		if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+			number = false
+			break
+		}
+		if _, err := strconv.ParseInt(field, 10, 64); err != nil {
+			integer = false
+			if f, err := strconv.ParseFloat(field, 64); err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+				number = false
+				break
+			}
+		}
+	}
+	switch {
+	case values == 0 || !number:
+		return "TEXT"
+	case integer:
+		return "INTEGER"
+	default:
+		return "REAL"
+	}
+}
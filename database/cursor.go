@@ -0,0 +1,208 @@
+package database
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxOpenCursors bounds how many queries ReadBatch keeps open between batches; opening one
+// more closes the least recently read
+const MaxOpenCursors = 8
+
+// CursorIdleTimeout is how long ReadBatch keeps a query open without its next batch being read
+const CursorIdleTimeout = 2 * time.Minute
+
+// queryCursor is a query kept open at the first row of its next batch
+type queryCursor struct {
+	key      string
+	rows     *sql.Rows
+	reader   *rowReader
+	ctx      context.Context
+	cancel   context.CancelFunc
+	position int  // rows returned so far
+	pending  bool // rows is at a row not yet returned
+	lastRead time.Time
+	timer    *time.Timer // closes the cursor once idle
+}
+
+// close ends the query of the cursor
+func (c *queryCursor) close() {
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+	c.rows.Close()
+	c.cancel()
+}
+
+// queryCursors are the cursors kept open by ReadBatch, by ID
+type queryCursors struct {
+	mu   sync.Mutex
+	open map[string]*queryCursor
+}
+
+// RowBatch is one batch of query rows read by ReadBatch
+type RowBatch struct {
+	Columns []string
+	Rows    []map[string]interface{}
+	More    bool   // more rows follow the batch
+	Cursor  string // ID of the cursor kept open at the next row, if any
+}
+
+// ReadBatch reads at most size rows of a query, after its first offset rows. When more rows
+// follow, the query is kept open at the next row and the returned batch names the cursor;
+// passing that cursor ID with the offset reached reads the next batch from where the last
+// one stopped, so paging through a result reads each row once. When the cursor is gone, the
+// query runs again and steps past the first offset rows.
+//
+// A cursor is closed once its last row is read, after CursorIdleTimeout without a read, when
+// more than MaxOpenCursors are open, on Interrupt, and when the connection pool changes.
+// No cursor is kept while the pool is serialized, where an open query would hold the only
+// connection, or outside WAL mode, where its read lock would block writers.
+func (s *SQLiteDB) ReadBatch(ctx context.Context, cursorID, query string, offset, size int, args ...interface{}) (*RowBatch, error) {
+	if size < 1 {
+		return nil, fmt.Errorf("batch size must be at least 1")
+	}
+	key := cursorKey(query, args)
+	cursor := s.takeCursor(cursorID, key, offset)
+	if cursor == nil {
+		var err error
+		if cursor, err = s.openCursor(query, key, args); err != nil {
+			return nil, err
+		}
+	}
+
+	// The call, Interrupt, and the query timeout end the query, which then cannot be continued
+	ctx, cancel := s.statementContext(ctx)
+	defer cancel()
+	stop := context.AfterFunc(ctx, cursor.cancel)
+	defer stop()
+
+	for cursor.position < offset && cursor.rows.Next() {
+		cursor.position++
+	}
+	batch := &RowBatch{Columns: cursor.reader.columns}
+	for len(batch.Rows) < size && (cursor.pending || cursor.rows.Next()) {
+		cursor.pending = false
+		row, err := cursor.reader.read(cursor.rows)
+		if err != nil {
+			cursor.close()
+			return nil, err
+		}
+		batch.Rows = append(batch.Rows, row)
+	}
+	batch.More = len(batch.Rows) == size && cursor.rows.Next()
+	cursor.pending = batch.More
+	if err := cursor.rows.Err(); err != nil {
+		cursor.close()
+		return nil, fmt.Errorf("query failed: %w", timeoutError(ctx, err))
+	}
+	cursor.position += len(batch.Rows)
+
+	if batch.More && s.keepsCursors() {
+		batch.Cursor = s.keepCursor(cursor)
+	} else {
+		cursor.close()
+	}
+	return batch, nil
+}
+
+// openCursor runs query. It runs under the context of the database rather than of the
+// call, so that it can outlive the call.
+func (s *SQLiteDB) openCursor(query, key string, args []interface{}) (*queryCursor, error) {
+	ctx, cancel := context.WithCancel(s.interruptContext())
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	reader, err := newRowReader(rows)
+	if err != nil {
+		rows.Close()
+		cancel()
+		return nil, err
+	}
+	return &queryCursor{key: key, rows: rows, reader: reader, ctx: ctx, cancel: cancel}, nil
+}
+
+// keepsCursors reports whether ReadBatch may keep queries open between batches
+func (s *SQLiteDB) keepsCursors() bool {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return !s.serialized && strings.EqualFold(s.journalMode, "WAL")
+}
+
+// keepCursor registers cursor, whose current row is the first of the next batch, and
+// returns its ID
+func (s *SQLiteDB) keepCursor(cursor *queryCursor) string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	cursorID := hex.EncodeToString(id)
+
+	s.cursors.mu.Lock()
+	defer s.cursors.mu.Unlock()
+	if s.cursors.open == nil {
+		s.cursors.open = make(map[string]*queryCursor)
+	}
+	for len(s.cursors.open) >= MaxOpenCursors {
+		var oldest string
+		for id, c := range s.cursors.open {
+			if oldest == "" || c.lastRead.Before(s.cursors.open[oldest].lastRead) {
+				oldest = id
+			}
+		}
+		s.cursors.open[oldest].close()
+		delete(s.cursors.open, oldest)
+	}
+	cursor.lastRead = time.Now()
+	cursor.timer = time.AfterFunc(CursorIdleTimeout, func() {
+		if c := s.takeCursor(cursorID, cursor.key, cursor.position); c != nil {
+			c.close()
+		}
+	})
+	s.cursors.open[cursorID] = cursor
+	return cursorID
+}
+
+// takeCursor removes and returns the open cursor cursorID when it belongs to the query key
+// and stands at offset, or returns nil. The cursor's current row is the first not yet read.
+func (s *SQLiteDB) takeCursor(cursorID, key string, offset int) *queryCursor {
+	if cursorID == "" {
+		return nil
+	}
+	s.cursors.mu.Lock()
+	defer s.cursors.mu.Unlock()
+	cursor, ok := s.cursors.open[cursorID]
+	if !ok || cursor.key != key || cursor.position != offset {
+		return nil
+	}
+	delete(s.cursors.open, cursorID)
+	cursor.timer.Stop()
+	if cursor.ctx.Err() != nil {
+		// Interrupted, so the query has ended
+		cursor.close()
+		return nil
+	}
+	return cursor
+}
+
+// closeCursors closes every cursor kept open by ReadBatch
+func (s *SQLiteDB) closeCursors() {
+	s.cursors.mu.Lock()
+	defer s.cursors.mu.Unlock()
+	for id, cursor := range s.cursors.open {
+		cursor.close()
+		delete(s.cursors.open, id)
+	}
+}
+
+// cursorKey identifies a query and its arguments, so that a cursor is only continued by
+// the query that opened it
+func cursorKey(query string, args []interface{}) string {
+	return fmt.Sprintf("%s\x00%#v", query, args)
+}
@@ -0,0 +1,55 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetTableDDL(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT UNIQUE, total REAL)",
+		"CREATE INDEX idx_orders_total ON orders (total)",
+		"CREATE INDEX idx_orders_customer_total ON orders (customer, total)",
+		"CREATE TRIGGER orders_touch AFTER UPDATE ON orders BEGIN SELECT 1; END",
+		"CREATE TABLE other (id INTEGER)",
+		"CREATE INDEX idx_other ON other (id)",
+	)
+
+	script, err := db.GetTableDDL("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"CREATE TABLE orders",
+		"CREATE INDEX idx_orders_customer_total",
+		"CREATE INDEX idx_orders_total",
+		"CREATE TRIGGER orders_touch",
+	}
+	last := -1
+	for _, statement := range want {
+		i := strings.Index(script, statement)
+		if i < 0 {
+			t.Fatalf("script lacks %q:\n%s", statement, script)
+		}
+		if i < last {
+			t.Fatalf("%q is out of order:\n%s", statement, script)
+		}
+		last = i
+	}
+	if strings.Contains(script, "idx_other") {
+		t.Fatalf("script includes an index of another table:\n%s", script)
+	}
+
+	// The script recreates the table on an empty database
+	fresh := newTestDB(t)
+	if _, err := fresh.db.Exec(script); err != nil {
+		t.Fatalf("script does not run: %v\n%s", err, script)
+	}
+	if n := queryInt(t, fresh, "SELECT COUNT(*) FROM sqlite_master WHERE tbl_name = 'orders'"); n != 5 {
+		t.Fatalf("recreated %d objects, want the table, its 3 indexes and its trigger", n)
+	}
+
+	if _, err := db.GetTableDDL("missing"); err == nil {
+		t.Fatal("no error for a missing table")
+	}
+}
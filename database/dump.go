@@ -0,0 +1,215 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpOptions controls what DumpSchema writes
+type DumpOptions struct {
+	// IncludeData adds an INSERT statement for every row
+	IncludeData bool
+	// Include, when set, leaves out the tables and views for which it returns false, with
+	// their indexes and triggers
+	Include func(table string) bool
+	// Redact, when set, writes '***' instead of the non-NULL values of columns for which it
+	// returns true
+	Redact func(column string) bool
+}
+
+// DumpSchema writes an SQL script that recreates the database: CREATE statements for every
+// table, index, view, and trigger, and with options.IncludeData the rows of each table and the
+// AUTOINCREMENT counters. Objects come in dependency order: tables, their rows, indexes,
+// views ordered so that a view follows the views it selects from, and triggers last so that
+// they do not fire on the inserted rows. SQLite's internal sqlite_* objects, the shadow tables
+// of virtual tables, which the virtual tables create themselves, and the server's _mcp_init
+// marker table are left out, as are the rows of virtual tables. The script runs in one
+// transaction with foreign key enforcement off, like the sqlite3 shell's .dump.
+func (s *SQLiteDB) DumpSchema(out io.Writer, options DumpOptions) error {
+	ctx := s.ctx()
+	// A read transaction gives a consistent snapshot across tables
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	objects, err := jsonSchemaObjects(ctx, tx)
+	if err != nil {
+		return err
+	}
+
+	var tables, indexes, views, triggers []JSONSchemaObject
+	var virtualTables []string
+	skipped := make(map[string]bool)
+	for _, obj := range objects {
+		switch obj.Type {
+		case "table":
+			virtual := virtualTablePattern.MatchString(obj.SQL)
+			if virtual {
+				virtualTables = append(virtualTables, obj.Name)
+			}
+			if strings.EqualFold(obj.Name, "_mcp_init") || (!virtual && shadowTableOf(obj.Name, virtualTables) != "") ||
+				(options.Include != nil && !options.Include(obj.Name)) {
+				skipped[strings.ToLower(obj.Name)] = true
+				continue
+			}
+			tables = append(tables, obj)
+		case "index":
+			if !skipped[strings.ToLower(obj.Table)] {
+				indexes = append(indexes, obj)
+			}
+		case "view":
+			if options.Include == nil || options.Include(obj.Name) {
+				views = append(views, obj)
+			}
+		case "trigger":
+			if !skipped[strings.ToLower(obj.Table)] {
+				triggers = append(triggers, obj)
+			}
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	w.WriteString("PRAGMA foreign_keys=OFF;\nBEGIN TRANSACTION;\n")
+	for _, table := range tables {
+		fmt.Fprintf(w, "%s;\n", table.SQL)
+		if !options.IncludeData || virtualTablePattern.MatchString(table.SQL) {
+			continue
+		}
+		if err := dumpTableRows(ctx, tx, w, table.Name, options.Redact); err != nil {
+			return fmt.Errorf("table '%s': %w", table.Name, err)
+		}
+	}
+	if options.IncludeData {
+		if err := dumpSequences(ctx, tx, w, skipped); err != nil {
+			return err
+		}
+	}
+	for _, group := range [][]JSONSchemaObject{indexes, orderViews(views), triggers} {
+		for _, obj := range group {
+			fmt.Fprintf(w, "%s;\n", obj.SQL)
+		}
+	}
+	w.WriteString("COMMIT;\n")
+	return w.Flush()
+}
+
+// dumpTableRows writes an INSERT statement for every row of a table. SQLite's quote()
+// renders each value as a literal of its own storage class, so values are restored exactly.
+func dumpTableRows(ctx context.Context, tx *sql.Tx, w *bufio.Writer, table string, redact func(string) bool) error {
+	// Generated columns are computed from the others and cannot be inserted
+	info, err := tx.QueryContext(ctx, "SELECT name FROM pragma_table_xinfo(?) WHERE hidden = 0 ORDER BY cid", table)
+	if err != nil {
+		return err
+	}
+	var names, quoted []string
+	for info.Next() {
+		var name string
+		if err := info.Scan(&name); err != nil {
+			info.Close()
+			return err
+		}
+		names = append(names, quoteIdentifier(name))
+		quoted = append(quoted, fmt.Sprintf("quote(%s)", quoteIdentifier(name)))
+		if redact != nil && redact(name) {
+			quoted[len(quoted)-1] = fmt.Sprintf("CASE WHEN %s IS NULL THEN 'NULL' ELSE '''***''' END", quoteIdentifier(name))
+		}
+	}
+	info.Close()
+	if err := info.Err(); err != nil {
+		return err
+	}
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(quoted, ", "), quoteIdentifier(table)))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	prefix := fmt.Sprintf("INSERT INTO %s (%s) VALUES (", quoteIdentifier(table), strings.Join(names, ", "))
+	literals := make([]string, len(names))
+	ptrs := make([]interface{}, len(names))
+	for i := range literals {
+		ptrs[i] = &literals[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		w.WriteString(prefix)
+		w.WriteString(strings.Join(literals, ", "))
+		w.WriteString(");\n")
+	}
+	return rows.Err()
+}
+
+// dumpSequences writes the AUTOINCREMENT counters of the dumped tables, replacing the ones the
+// inserts produced
+func dumpSequences(ctx context.Context, tx *sql.Tx, w *bufio.Writer, skipped map[string]bool) error {
+	rows, err := tx.QueryContext(ctx, "SELECT name, seq FROM sqlite_sequence")
+	if err != nil {
+		// The table only exists once an AUTOINCREMENT table has been created
+		return nil
+	}
+	defer rows.Close()
+
+	started := false
+	for rows.Next() {
+		var name string
+		var seq int64
+		if err := rows.Scan(&name, &seq); err != nil {
+			return err
+		}
+		if skipped[strings.ToLower(name)] {
+			continue
+		}
+		if !started {
+			w.WriteString("DELETE FROM sqlite_sequence;\n")
+			started = true
+		}
+		fmt.Fprintf(w, "INSERT INTO sqlite_sequence (name, seq) VALUES ('%s', %d);\n", strings.ReplaceAll(name, "'", "''"), seq)
+	}
+	return rows.Err()
+}
+
+// orderViews sorts views so that each follows the views its definition names, keeping the
+// original order otherwise. Views in a reference cycle, which SQLite cannot create anyway,
+// keep their original order.
+func orderViews(views []JSONSchemaObject) []JSONSchemaObject {
+	ordered := make([]JSONSchemaObject, 0, len(views))
+	placed := make([]bool, len(views))
+	for len(ordered) < len(views) {
+		progress := false
+		for i, view := range views {
+			if placed[i] {
+				continue
+			}
+			ready := true
+			for j, other := range views {
+				if j != i && !placed[j] && columnReferencePattern(other.Name).MatchString(view.SQL) {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				ordered = append(ordered, view)
+				placed[i] = true
+				progress = true
+			}
+		}
+		if !progress {
+			for i, view := range views {
+				if !placed[i] {
+					ordered = append(ordered, view)
+					placed[i] = true
+				}
+			}
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,146 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// EREntity describes a table and its columns in an entity-relationship model
+type EREntity struct {
+	Name    string     `json:"name"`
+	Columns []ERColumn `json:"columns"`
+}
+
+// ERColumn describes a column of an entity
+type ERColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	PrimaryKey bool   `json:"primary_key"`
+	ForeignKey bool   `json:"foreign_key"`
+	NotNull    bool   `json:"not_null"`
+}
+
+// ERRelationship describes a foreign-key edge from a child table to its parent
+type ERRelationship struct {
+	FromTable   string   `json:"from_table"`
+	FromColumns []string `json:"from_columns"`
+	ToTable     string   `json:"to_table"`
+	ToColumns   []string `json:"to_columns"`
+}
+
+// ERModel is a machine-readable description of the schema's tables and foreign-key relationships
+type ERModel struct {
+	Entities      []EREntity       `json:"entities"`
+	Relationships []ERRelationship `json:"relationships"`
+}
+
+// GetERModel builds an entity-relationship model of all user tables
+func (s *SQLiteDB) GetERModel() (*ERModel, error) {
+	tables, err := s.GetTables()
+	if err != nil {
+		return nil, err
+	}
+
+	model := &ERModel{
+		Entities:      []EREntity{},
+		Relationships: []ERRelationship{},
+	}
+
+	for _, table := range tables {
+		columns, err := s.GetAnnotatedSchema(table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read columns of %s: %w", table, err)
+		}
+
+		entity := EREntity{Name: table}
+		for _, col := range columns {
+			name, _ := col["name"].(string)
+			colType, _ := col["type"].(string)
+			entity.Columns = append(entity.Columns, ERColumn{
+				Name:       name,
+				Type:       colType,
+				PrimaryKey: col["is_primary_key"] == true,
+				ForeignKey: col["is_foreign_key"] == true,
+				NotNull:    col["is_not_null"] == true,
+			})
+		}
+		model.Entities = append(model.Entities, entity)
+
+		foreignKeys, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA foreign_key_list(%s)", quoteIdentifier(table)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read foreign keys of %s: %w", table, err)
+		}
+
+		// Composite foreign keys share an id, one row per column
+		byID := make(map[int64]*ERRelationship)
+		var order []int64
+		for _, fk := range foreignKeys {
+			id := toInt64(fk["id"])
+			rel, exists := byID[id]
+			if !exists {
+				parent, _ := fk["table"].(string)
+				rel = &ERRelationship{FromTable: table, ToTable: parent}
+				byID[id] = rel
+				order = append(order, id)
+			}
+			from, _ := fk["from"].(string)
+			rel.FromColumns = append(rel.FromColumns, from)
+			// "to" is NULL when the foreign key references the parent's primary key implicitly
+			to, _ := fk["to"].(string)
+			rel.ToColumns = append(rel.ToColumns, to)
+		}
+		for _, id := range order {
+			model.Relationships = append(model.Relationships, *byID[id])
+		}
+	}
+
+	return model, nil
+}
+
+var mermaidUnsafe = regexp.MustCompile(`[^A-Za-z0-9_]+`)
+
+// mermaidName converts an identifier into a token Mermaid accepts
+func mermaidName(name string) string {
+	cleaned := strings.Trim(mermaidUnsafe.ReplaceAllString(name, "_"), "_")
+	if cleaned == "" {
+		return "_"
+	}
+	return cleaned
+}
+
+// Mermaid renders the model as a Mermaid erDiagram definition
+func (m *ERModel) Mermaid() string {
+	var b strings.Builder
+	b.WriteString("erDiagram\n")
+
+	for _, entity := range m.Entities {
+		fmt.Fprintf(&b, "    %s {\n", mermaidName(entity.Name))
+		for _, col := range entity.Columns {
+			colType := mermaidName(col.Type)
+			if col.Type == "" {
+				colType = "ANY"
+			}
+			var keys []string
+			if col.PrimaryKey {
+				keys = append(keys, "PK")
+			}
+			if col.ForeignKey {
+				keys = append(keys, "FK")
+			}
+			line := fmt.Sprintf("        %s %s", colType, mermaidName(col.Name))
+			if len(keys) > 0 {
+				line += " " + strings.Join(keys, ",")
+			}
+			b.WriteString(line + "\n")
+		}
+		b.WriteString("    }\n")
+	}
+
+	for _, rel := range m.Relationships {
+		fmt.Fprintf(&b, "    %s }o--|| %s : \"%s\"\n",
+			mermaidName(rel.FromTable), mermaidName(rel.ToTable), strings.Join(rel.FromColumns, ", "))
+	}
+
+	return b.String()
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetERModel(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers(id))",
+	)
+
+	model, err := db.GetERModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, entity := range model.Entities {
+		names = append(names, entity.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"customers", "orders"}) {
+		t.Fatalf("entities %v", names)
+	}
+	want := []ERRelationship{{FromTable: "orders", FromColumns: []string{"customer_id"}, ToTable: "customers", ToColumns: []string{"id"}}}
+	if !reflect.DeepEqual(model.Relationships, want) {
+		t.Fatalf("relationships %+v, want %+v", model.Relationships, want)
+	}
+
+	diagram := model.Mermaid()
+	for _, line := range []string{
+		"erDiagram",
+		"    customers {",
+		"        INTEGER id PK",
+		"    orders {",
+		"        INTEGER customer_id FK",
+		`    orders }o--|| customers : "customer_id"`,
+	} {
+		if !strings.Contains(diagram, line+"\n") {
+			t.Errorf("diagram lacks %q:\n%s", line, diagram)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrDiskFull is returned when a write fails with SQLITE_FULL because the disk, or the
+// database's max_page_count limit, has no room left
+var ErrDiskFull = errors.New("insufficient disk space")
+
+// isDiskFull reports whether err is an SQLITE_FULL error from the driver
+func isDiskFull(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrFull
+}
+
+// diskFullError replaces an SQLITE_FULL error with an ErrDiskFull error naming the database
+// file, and returns any other error unchanged
+func (s *SQLiteDB) diskFullError(err error) error {
+	if err == nil || errors.Is(err, ErrDiskFull) || !isDiskFull(err) {
+		return err
+	}
+	return fmt.Errorf("%w: writing to database '%s' failed and the changes were rolled back; free disk space or raise max_page_count (%w)",
+		ErrDiskFull, s.dbPath, err)
+}
+
+// SQLiteErrorInfo carries the SQLite result codes behind a failed operation
+type SQLiteErrorInfo struct {
+	Code             int    `json:"code"`
+	CodeName         string `json:"code_name"`
+	ExtendedCode     int    `json:"extended_code"`
+	ExtendedCodeName string `json:"extended_code_name,omitempty"`
+	SystemErrno      int    `json:"system_errno,omitempty"`
+}
+
+// Name returns the most specific known name of the error code
+func (i *SQLiteErrorInfo) Name() string {
+	if i.ExtendedCodeName != "" {
+		return i.ExtendedCodeName
+	}
+	return i.CodeName
+}
+
+var errorCodeNames = map[sqlite3.ErrNo]string{
+	sqlite3.ErrError:      "SQLITE_ERROR",
+	sqlite3.ErrInternal:   "SQLITE_INTERNAL",
+	sqlite3.ErrPerm:       "SQLITE_PERM",
+	sqlite3.ErrAbort:      "SQLITE_ABORT",
+	sqlite3.ErrBusy:       "SQLITE_BUSY",
+	sqlite3.ErrLocked:     "SQLITE_LOCKED",
+	sqlite3.ErrNomem:      "SQLITE_NOMEM",
+	sqlite3.ErrReadonly:   "SQLITE_READONLY",
+	sqlite3.ErrInterrupt:  "SQLITE_INTERRUPT",
+	sqlite3.ErrIoErr:      "SQLITE_IOERR",
+	sqlite3.ErrCorrupt:    "SQLITE_CORRUPT",
+	sqlite3.ErrNotFound:   "SQLITE_NOTFOUND",
+	sqlite3.ErrFull:       "SQLITE_FULL",
+	sqlite3.ErrCantOpen:   "SQLITE_CANTOPEN",
+	sqlite3.ErrProtocol:   "SQLITE_PROTOCOL",
+	sqlite3.ErrEmpty:      "SQLITE_EMPTY",
+	sqlite3.ErrSchema:     "SQLITE_SCHEMA",
+	sqlite3.ErrTooBig:     "SQLITE_TOOBIG",
+	sqlite3.ErrConstraint: "SQLITE_CONSTRAINT",
+	sqlite3.ErrMismatch:   "SQLITE_MISMATCH",
+	sqlite3.ErrMisuse:     "SQLITE_MISUSE",
+	sqlite3.ErrNoLFS:      "SQLITE_NOLFS",
+	sqlite3.ErrAuth:       "SQLITE_AUTH",
+	sqlite3.ErrFormat:     "SQLITE_FORMAT",
+	sqlite3.ErrRange:      "SQLITE_RANGE",
+	sqlite3.ErrNotADB:     "SQLITE_NOTADB",
+	sqlite3.ErrNotice:     "SQLITE_NOTICE",
+	sqlite3.ErrWarning:    "SQLITE_WARNING",
+}
+
+var extendedCodeNames = map[sqlite3.ErrNoExtended]string{
+	sqlite3.ErrIoErrRead:              "SQLITE_IOERR_READ",
+	sqlite3.ErrIoErrShortRead:         "SQLITE_IOERR_SHORT_READ",
+	sqlite3.ErrIoErrWrite:             "SQLITE_IOERR_WRITE",
+	sqlite3.ErrIoErrFsync:             "SQLITE_IOERR_FSYNC",
+	sqlite3.ErrIoErrDirFsync:          "SQLITE_IOERR_DIR_FSYNC",
+	sqlite3.ErrIoErrTruncate:          "SQLITE_IOERR_TRUNCATE",
+	sqlite3.ErrIoErrFstat:             "SQLITE_IOERR_FSTAT",
+	sqlite3.ErrIoErrUnlock:            "SQLITE_IOERR_UNLOCK",
+	sqlite3.ErrIoErrRDlock:            "SQLITE_IOERR_RDLOCK",
+	sqlite3.ErrIoErrDelete:            "SQLITE_IOERR_DELETE",
+	sqlite3.ErrIoErrBlocked:           "SQLITE_IOERR_BLOCKED",
+	sqlite3.ErrIoErrNoMem:             "SQLITE_IOERR_NOMEM",
+	sqlite3.ErrIoErrAccess:            "SQLITE_IOERR_ACCESS",
+	sqlite3.ErrIoErrCheckReservedLock: "SQLITE_IOERR_CHECKRESERVEDLOCK",
+	sqlite3.ErrIoErrLock:              "SQLITE_IOERR_LOCK",
+	sqlite3.ErrIoErrClose:             "SQLITE_IOERR_CLOSE",
+	sqlite3.ErrIoErrDirClose:          "SQLITE_IOERR_DIR_CLOSE",
+	sqlite3.ErrIoErrSHMOpen:           "SQLITE_IOERR_SHMOPEN",
+	sqlite3.ErrIoErrSHMSize:           "SQLITE_IOERR_SHMSIZE",
+	sqlite3.ErrIoErrSHMLock:           "SQLITE_IOERR_SHMLOCK",
+	sqlite3.ErrIoErrSHMMap:            "SQLITE_IOERR_SHMMAP",
+	sqlite3.ErrIoErrSeek:              "SQLITE_IOERR_SEEK",
+	sqlite3.ErrIoErrDeleteNoent:       "SQLITE_IOERR_DELETE_NOENT",
+	sqlite3.ErrIoErrMMap:              "SQLITE_IOERR_MMAP",
+	sqlite3.ErrIoErrGetTempPath:       "SQLITE_IOERR_GETTEMPPATH",
+	sqlite3.ErrIoErrConvPath:          "SQLITE_IOERR_CONVPATH",
+	sqlite3.ErrLockedSharedCache:      "SQLITE_LOCKED_SHAREDCACHE",
+	sqlite3.ErrBusyRecovery:           "SQLITE_BUSY_RECOVERY",
+	sqlite3.ErrBusySnapshot:           "SQLITE_BUSY_SNAPSHOT",
+	sqlite3.ErrCantOpenNoTempDir:      "SQLITE_CANTOPEN_NOTEMPDIR",
+	sqlite3.ErrCantOpenIsDir:          "SQLITE_CANTOPEN_ISDIR",
+	sqlite3.ErrCantOpenFullPath:       "SQLITE_CANTOPEN_FULLPATH",
+	sqlite3.ErrCantOpenConvPath:       "SQLITE_CANTOPEN_CONVPATH",
+	sqlite3.ErrCorruptVTab:            "SQLITE_CORRUPT_VTAB",
+	sqlite3.ErrReadonlyRecovery:       "SQLITE_READONLY_RECOVERY",
+	sqlite3.ErrReadonlyCantLock:       "SQLITE_READONLY_CANTLOCK",
+	sqlite3.ErrReadonlyRollback:       "SQLITE_READONLY_ROLLBACK",
+	sqlite3.ErrReadonlyDbMoved:        "SQLITE_READONLY_DBMOVED",
+	sqlite3.ErrAbortRollback:          "SQLITE_ABORT_ROLLBACK",
+	sqlite3.ErrConstraintCheck:        "SQLITE_CONSTRAINT_CHECK",
+	sqlite3.ErrConstraintCommitHook:   "SQLITE_CONSTRAINT_COMMITHOOK",
+	sqlite3.ErrConstraintForeignKey:   "SQLITE_CONSTRAINT_FOREIGNKEY",
+	sqlite3.ErrConstraintFunction:     "SQLITE_CONSTRAINT_FUNCTION",
+	sqlite3.ErrConstraintNotNull:      "SQLITE_CONSTRAINT_NOTNULL",
+	sqlite3.ErrConstraintPrimaryKey:   "SQLITE_CONSTRAINT_PRIMARYKEY",
+	sqlite3.ErrConstraintTrigger:      "SQLITE_CONSTRAINT_TRIGGER",
+	sqlite3.ErrConstraintUnique:       "SQLITE_CONSTRAINT_UNIQUE",
+	sqlite3.ErrConstraintVTab:         "SQLITE_CONSTRAINT_VTAB",
+	sqlite3.ErrConstraintRowID:        "SQLITE_CONSTRAINT_ROWID",
+	sqlite3.ErrNoticeRecoverWAL:       "SQLITE_NOTICE_RECOVER_WAL",
+	sqlite3.ErrNoticeRecoverRollback:  "SQLITE_NOTICE_RECOVER_ROLLBACK",
+	sqlite3.ErrWarningAutoIndex:       "SQLITE_WARNING_AUTOINDEX",
+}
+
+// SQLiteErrorCode extracts the SQLite result codes from an error returned by the driver,
+// reporting false when err does not wrap a driver error
+func SQLiteErrorCode(err error) (*SQLiteErrorInfo, bool) {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		return nil, false
+	}
+	return &SQLiteErrorInfo{
+		Code:             int(sqliteErr.Code),
+		CodeName:         errorCodeNames[sqliteErr.Code],
+		ExtendedCode:     int(sqliteErr.ExtendedCode),
+		ExtendedCodeName: extendedCodeNames[sqliteErr.ExtendedCode],
+		SystemErrno:      int(sqliteErr.SystemErrno),
+	}, true
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MaxParameterSets caps the parameter sets ExecuteMany accepts in one call
+const MaxParameterSets = 100000
+
+// ParameterSetResult is the outcome of running a statement with one parameter set
+type ParameterSetResult struct {
+	RowsAffected int64 `json:"rows_affected"`
+	LastInsertID int64 `json:"last_insert_id,omitempty"`
+}
+
+// ExecuteManyResult reports the outcome of ExecuteMany
+type ExecuteManyResult struct {
+	Sets         int   `json:"sets"`
+	RowsAffected int64 `json:"rows_affected"`
+	LastInsertID int64 `json:"last_insert_id,omitempty"`
+	// Results holds one entry per parameter set, in order, when requested
+	Results []ParameterSetResult `json:"results,omitempty"`
+}
+
+// ExecuteMany runs one INSERT, UPDATE, DELETE, or REPLACE statement once for every parameter
+// set. The statement is prepared once and every execution runs in a single transaction, so
+// either all parameter sets are applied or, when one fails, none are; the error names the
+// failing set. With perSet the result lists the rows affected by each set.
+func (s *SQLiteDB) ExecuteMany(ctx context.Context, statement string, paramSets [][]interface{}, perSet bool) (*ExecuteManyResult, error) {
+	if !IsSingleStatement(statement) {
+		return nil, fmt.Errorf("the statement must be a single SQL statement")
+	}
+	if statementKind(statement) != "write" {
+		return nil, fmt.Errorf("only INSERT, UPDATE, DELETE, and REPLACE statements can be executed with parameter sets")
+	}
+	if len(paramSets) == 0 {
+		return nil, fmt.Errorf("no parameter sets given")
+	}
+	if len(paramSets) > MaxParameterSets {
+		return nil, fmt.Errorf("at most %d parameter sets can be executed at once", MaxParameterSets)
+	}
+
+	ctx, cancel := s.statementContext(ctx)
+	defer cancel()
+
+	result := &ExecuteManyResult{Sets: len(paramSets)}
+	if perSet {
+		result.Results = make([]ParameterSetResult, 0, len(paramSets))
+	}
+	isInsert := strings.HasPrefix(strings.ToUpper(strings.TrimSpace(statement)), "INSERT")
+
+	err := s.RetryTransaction(func(tx *sql.Tx) error {
+		result.RowsAffected, result.LastInsertID = 0, 0
+		if perSet {
+			result.Results = result.Results[:0]
+		}
+		stmt, err := tx.PrepareContext(ctx, statement)
+		if err != nil {
+			return timeoutError(ctx, err)
+		}
+		defer stmt.Close()
+
+		for i, params := range paramSets {
+			res, err := stmt.ExecContext(ctx, params...)
+			if err != nil {
+				return fmt.Errorf("parameter set %d: %w", i, timeoutError(ctx, err))
+			}
+			affected, _ := res.RowsAffected()
+			result.RowsAffected += affected
+			var lastID int64
+			if isInsert {
+				lastID, _ = res.LastInsertId()
+				result.LastInsertID = lastID
+			}
+			if perSet {
+				result.Results = append(result.Results, ParameterSetResult{RowsAffected: affected, LastInsertID: lastID})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,92 @@
+package database
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// countPrepares counts the statements prepared on the only connection of a serialized pool
+// that update table. The authorizer is consulted while a statement is prepared, not when it
+// runs, so its calls count prepares.
+func countPrepares(t *testing.T, db *SQLiteDB, table string) *int {
+	t.Helper()
+	db.SetSerialized(true)
+	conn, err := db.db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	prepares := new(int)
+	err = conn.Raw(func(driverConn interface{}) error {
+		driverConn.(*sqlite3.SQLiteConn).RegisterAuthorizer(func(action int, arg1, arg2, arg3 string) int {
+			if action == sqlite3.SQLITE_UPDATE && arg1 == table && arg2 == "score" {
+				*prepares++
+			}
+			return sqlite3.SQLITE_OK
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prepares
+}
+
+func TestExecuteManyPreparesOnce(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE players (id INTEGER PRIMARY KEY, score INTEGER)",
+		"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1000) INSERT INTO players SELECT i, 0 FROM n",
+	)
+	prepares := countPrepares(t, db, "players")
+
+	sets := make([][]interface{}, 1000)
+	for i := range sets {
+		id := int64(i + 1)
+		sets[i] = []interface{}{id * 10, id}
+	}
+	result, err := db.ExecuteMany(context.Background(), "UPDATE players SET score = ? WHERE id = ?", sets, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Sets != 1000 || result.RowsAffected != 1000 || len(result.Results) != 1000 {
+		t.Fatalf("unexpected result: %d sets, %d rows, %d results", result.Sets, result.RowsAffected, len(result.Results))
+	}
+	if *prepares != 1 {
+		t.Fatalf("the statement was prepared %d times, want once", *prepares)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM players WHERE score = id * 10"); n != 1000 {
+		t.Fatalf("%d of 1000 rows updated correctly", n)
+	}
+}
+
+func TestExecuteManyIsAtomic(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT NOT NULL)")
+
+	result, err := db.ExecuteMany(context.Background(), "INSERT INTO t (v) VALUES (?)", [][]interface{}{{"a"}, {"b"}}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.LastInsertID != 2 || result.Results != nil {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	_, err = db.ExecuteMany(context.Background(), "INSERT INTO t (v) VALUES (?)", [][]interface{}{{"c"}, {nil}, {"d"}}, false)
+	if err == nil || !strings.Contains(err.Error(), "parameter set 1") {
+		t.Fatalf("got %v, want parameter set 1 to fail", err)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM t"); n != 2 {
+		t.Fatalf("a failed set left %d rows, want 2", n)
+	}
+
+	for _, statement := range []string{"SELECT ?", "INSERT INTO t (v) VALUES (?); DELETE FROM t", "DROP TABLE t"} {
+		if _, err := db.ExecuteMany(context.Background(), statement, [][]interface{}{{"x"}}, false); err == nil {
+			t.Errorf("accepted %q", statement)
+		}
+	}
+	if _, err := db.ExecuteMany(context.Background(), "DELETE FROM t WHERE id = ?", nil, false); err == nil {
+		t.Error("accepted no parameter sets")
+	}
+}
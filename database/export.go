@@ -0,0 +1,350 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ExportDialects lists the target engines supported by ExportDDL
+var ExportDialects = []string{"postgres"}
+
+var (
+	virtualTablePattern  = regexp.MustCompile(`(?i)^\s*CREATE\s+VIRTUAL\s+TABLE`)
+	checkPattern         = regexp.MustCompile(`(?i)\bCHECK\s*\(`)
+	autoincrementPattern = regexp.MustCompile(`(?i)\bAUTOINCREMENT\b`)
+	typeSizePattern      = regexp.MustCompile(`\(\s*\d+(\s*,\s*\d+)?\s*\)`)
+)
+
+// exportTable holds what ExportDDL needs to know about one table
+type exportTable struct {
+	name    string
+	sql     string
+	columns []map[string]interface{}
+	parents []string
+}
+
+// ExportDDL translates the schema of all user tables into DDL for another database engine.
+// The translation is best effort: tables, columns with mapped types, NOT NULL, defaults,
+// primary keys, unique constraints, foreign keys, and plain indexes are converted, while
+// features without a direct equivalent are listed as comments for manual review.
+func (s *SQLiteDB) ExportDDL(dialect string) (string, error) {
+	if !strings.EqualFold(dialect, "postgres") && !strings.EqualFold(dialect, "postgresql") {
+		return "", fmt.Errorf("unsupported dialect '%s', supported: %s", dialect, strings.Join(ExportDialects, ", "))
+	}
+
+	rows, err := s.ExecuteQuery(`
+		SELECT name, sql FROM sqlite_master
+		WHERE type='table' AND name NOT LIKE 'sqlite_%' AND name NOT LIKE '\_mcp\_%' ESCAPE '\'
+		ORDER BY name
+	`)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	out.WriteString("-- Schema exported from SQLite for PostgreSQL (best effort, review before use)\n")
+
+	tables := make(map[string]*exportTable)
+	var names []string
+	for _, row := range rows {
+		name, _ := row["name"].(string)
+		tableSQL, _ := row["sql"].(string)
+		if virtualTablePattern.MatchString(tableSQL) {
+			fmt.Fprintf(&out, "\n-- Skipped virtual table %s: no PostgreSQL equivalent\n", name)
+			continue
+		}
+
+		columns, err := s.GetTableSchema(name)
+		if err != nil {
+			return "", err
+		}
+		tables[name] = &exportTable{name: name, sql: tableSQL, columns: columns}
+		names = append(names, name)
+	}
+
+	foreignKeys := make(map[string][]map[string]interface{})
+	for _, name := range names {
+		fks, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA foreign_key_list(%s)", quoteIdentifier(name)))
+		if err != nil {
+			return "", err
+		}
+		foreignKeys[name] = fks
+		for _, fk := range fks {
+			parent, _ := fk["table"].(string)
+			tables[name].parents = append(tables[name].parents, parent)
+		}
+	}
+
+	for _, name := range orderByDependencies(names, tables) {
+		ddl, err := s.postgresTable(tables[name], foreignKeys[name])
+		if err != nil {
+			return "", err
+		}
+		out.WriteString("\n")
+		out.WriteString(ddl)
+	}
+
+	// Views and triggers are written in SQLite's SQL dialect and are not translated
+	objects, err := s.ExecuteQuery(`
+		SELECT type, name FROM sqlite_master
+		WHERE type IN ('view', 'trigger') AND name NOT LIKE 'sqlite_%'
+		ORDER BY type, name
+	`)
+	if err != nil {
+		return "", err
+	}
+	if len(objects) > 0 {
+		out.WriteString("\n-- Not translated, recreate manually:\n")
+		for _, obj := range objects {
+			fmt.Fprintf(&out, "--   %s %v\n", obj["type"], obj["name"])
+		}
+	}
+
+	return out.String(), nil
+}
+
+// orderByDependencies orders tables so that referenced tables come before the tables that
+// reference them. Tables in a reference cycle keep alphabetical order.
+func orderByDependencies(names []string, tables map[string]*exportTable) []string {
+	var ordered []string
+	done := make(map[string]bool)
+	visiting := make(map[string]bool)
+
+	var visit func(name string)
+	visit = func(name string) {
+		if done[name] || visiting[name] {
+			return
+		}
+		visiting[name] = true
+		for _, parent := range tables[name].parents {
+			if _, ok := tables[parent]; ok && parent != name {
+				visit(parent)
+			}
+		}
+		visiting[name] = false
+		done[name] = true
+		ordered = append(ordered, name)
+	}
+
+	for _, name := range names {
+		visit(name)
+	}
+	return ordered
+}
+
+// postgresTable builds the CREATE TABLE and CREATE INDEX statements for one table
+func (s *SQLiteDB) postgresTable(table *exportTable, foreignKeys []map[string]interface{}) (string, error) {
+	var notes []string
+	var lines []string
+
+	var pkColumns []map[string]interface{}
+	for _, col := range table.columns {
+		if toInt64(col["pk"]) > 0 {
+			pkColumns = append(pkColumns, col)
+		}
+	}
+	sort.SliceStable(pkColumns, func(i, j int) bool {
+		return toInt64(pkColumns[i]["pk"]) < toInt64(pkColumns[j]["pk"])
+	})
+
+	// A single INTEGER PRIMARY KEY column aliases rowid and is filled automatically
+	withoutRowid := withoutRowidPattern.MatchString(table.sql)
+	identityColumn := ""
+	if len(pkColumns) == 1 && !withoutRowid {
+		colType, _ := pkColumns[0]["type"].(string)
+		if strings.EqualFold(strings.TrimSpace(colType), "INTEGER") {
+			identityColumn, _ = pkColumns[0]["name"].(string)
+		}
+	}
+
+	for _, col := range table.columns {
+		name, _ := col["name"].(string)
+		colType, _ := col["type"].(string)
+
+		var def string
+		if name == identityColumn {
+			def = fmt.Sprintf("%s BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY", quoteIdentifier(name))
+		} else {
+			pgType, note := postgresType(colType)
+			if note != "" {
+				notes = append(notes, fmt.Sprintf("column %s: %s", name, note))
+			}
+			def = fmt.Sprintf("%s %s", quoteIdentifier(name), pgType)
+			if toInt64(col["notnull"]) != 0 {
+				def += " NOT NULL"
+			}
+			if dflt, ok := col["dflt_value"].(string); ok {
+				def += " DEFAULT " + dflt
+			}
+		}
+		lines = append(lines, def)
+	}
+
+	if identityColumn == "" && len(pkColumns) > 0 {
+		var cols []string
+		for _, col := range pkColumns {
+			name, _ := col["name"].(string)
+			cols = append(cols, quoteIdentifier(name))
+		}
+		lines = append(lines, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(cols, ", ")))
+	}
+
+	indexes, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_list(%s)", quoteIdentifier(table.name)))
+	if err != nil {
+		return "", err
+	}
+
+	// UNIQUE constraints become table constraints, explicitly created indexes become CREATE INDEX
+	var indexStatements []string
+	for _, idx := range indexes {
+		indexName, _ := idx["name"].(string)
+		origin, _ := idx["origin"].(string)
+		unique := toInt64(idx["unique"]) != 0
+		partial := toInt64(idx["partial"]) != 0
+		if origin == "pk" {
+			continue
+		}
+
+		info, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_info(%s)", quoteIdentifier(indexName)))
+		if err != nil {
+			return "", err
+		}
+		var cols []string
+		expression := false
+		for _, c := range info {
+			name, ok := c["name"].(string)
+			if !ok {
+				expression = true
+				break
+			}
+			cols = append(cols, quoteIdentifier(name))
+		}
+		if expression || partial {
+			notes = append(notes, fmt.Sprintf("index %s uses expressions or a WHERE clause and was not translated", indexName))
+			continue
+		}
+
+		if origin == "u" {
+			lines = append(lines, fmt.Sprintf("UNIQUE (%s)", strings.Join(cols, ", ")))
+			continue
+		}
+		keyword := "INDEX"
+		if unique {
+			keyword = "UNIQUE INDEX"
+		}
+		indexStatements = append(indexStatements, fmt.Sprintf("CREATE %s %s ON %s (%s);\n",
+			keyword, quoteIdentifier(indexName), quoteIdentifier(table.name), strings.Join(cols, ", ")))
+	}
+	sort.Strings(indexStatements)
+
+	// Composite foreign keys share an id, one row per column
+	type foreignKey struct {
+		parent, onUpdate, onDelete string
+		from, to                   []string
+	}
+	byID := make(map[int64]*foreignKey)
+	var order []int64
+	for _, fk := range foreignKeys {
+		id := toInt64(fk["id"])
+		key, ok := byID[id]
+		if !ok {
+			key = &foreignKey{}
+			key.parent, _ = fk["table"].(string)
+			key.onUpdate, _ = fk["on_update"].(string)
+			key.onDelete, _ = fk["on_delete"].(string)
+			byID[id] = key
+			order = append(order, id)
+		}
+		from, _ := fk["from"].(string)
+		key.from = append(key.from, quoteIdentifier(from))
+		if to, ok := fk["to"].(string); ok {
+			key.to = append(key.to, quoteIdentifier(to))
+		}
+	}
+	for _, id := range order {
+		key := byID[id]
+		line := fmt.Sprintf("FOREIGN KEY (%s) REFERENCES %s", strings.Join(key.from, ", "), quoteIdentifier(key.parent))
+		// "to" is NULL when the parent's primary key is referenced implicitly
+		if len(key.to) == len(key.from) {
+			line += fmt.Sprintf(" (%s)", strings.Join(key.to, ", "))
+		}
+		if key.onUpdate != "" && key.onUpdate != "NO ACTION" {
+			line += " ON UPDATE " + key.onUpdate
+		}
+		if key.onDelete != "" && key.onDelete != "NO ACTION" {
+			line += " ON DELETE " + key.onDelete
+		}
+		lines = append(lines, line)
+	}
+
+	if checkPattern.MatchString(table.sql) {
+		notes = append(notes, "CHECK constraints were not translated")
+	}
+	if autoincrementPattern.MatchString(table.sql) {
+		notes = append(notes, "AUTOINCREMENT is covered by the identity column")
+	}
+	if withoutRowid {
+		notes = append(notes, "WITHOUT ROWID has no PostgreSQL equivalent and was dropped")
+	}
+
+	var out strings.Builder
+	for _, note := range notes {
+		fmt.Fprintf(&out, "-- %s: %s\n", table.name, note)
+	}
+	fmt.Fprintf(&out, "CREATE TABLE %s (\n    %s\n);\n", quoteIdentifier(table.name), strings.Join(lines, ",\n    "))
+	for _, stmt := range indexStatements {
+		out.WriteString(stmt)
+	}
+
+	return out.String(), nil
+}
+
+// postgresType maps a declared SQLite column type to a PostgreSQL type, with a note when
+// the mapping loses information
+func postgresType(declaredType string) (string, string) {
+	t := strings.ToUpper(strings.TrimSpace(declaredType))
+	size := typeSizePattern.FindString(t)
+	base := strings.TrimSpace(typeSizePattern.ReplaceAllString(t, ""))
+
+	switch base {
+	case "":
+		return "TEXT", "no declared type, mapped to TEXT"
+	case "BIGINT", "INT8", "UNSIGNED BIG INT":
+		return "BIGINT", ""
+	case "SMALLINT", "INT2", "TINYINT", "MEDIUMINT":
+		return "SMALLINT", ""
+	case "VARCHAR", "CHARACTER VARYING", "NVARCHAR", "VARYING CHARACTER", "NATIVE CHARACTER":
+		return "VARCHAR" + size, ""
+	case "CHAR", "CHARACTER", "NCHAR":
+		return "CHAR" + size, ""
+	case "DECIMAL", "NUMERIC":
+		return "NUMERIC" + size, ""
+	case "BOOLEAN", "BOOL":
+		return "BOOLEAN", ""
+	case "DATE":
+		return "DATE", ""
+	case "DATETIME", "TIMESTAMP":
+		return "TIMESTAMP", ""
+	case "TIME":
+		return "TIME", ""
+	case "JSON":
+		return "JSONB", ""
+	case "UUID":
+		return "UUID", ""
+	}
+
+	switch columnAffinity(t) {
+	case "INTEGER":
+		return "INTEGER", ""
+	case "TEXT":
+		return "TEXT", ""
+	case "BLOB":
+		return "BYTEA", ""
+	case "REAL":
+		return "DOUBLE PRECISION", ""
+	default:
+		return "NUMERIC", fmt.Sprintf("declared type %s mapped to NUMERIC", declaredType)
+	}
+}
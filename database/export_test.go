@@ -0,0 +1,68 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPostgresType(t *testing.T) {
+	tests := map[string]string{
+		"INTEGER":       "INTEGER",
+		"BIGINT":        "BIGINT",
+		"VARCHAR(255)":  "VARCHAR(255)",
+		"DECIMAL(10,2)": "NUMERIC(10,2)",
+		"TEXT":          "TEXT",
+		"BLOB":          "BYTEA",
+		"REAL":          "DOUBLE PRECISION",
+		"DOUBLE":        "DOUBLE PRECISION",
+		"BOOLEAN":       "BOOLEAN",
+		"DATETIME":      "TIMESTAMP",
+		"JSON":          "JSONB",
+		"":              "TEXT",
+	}
+	for declared, want := range tests {
+		if got, _ := postgresType(declared); got != want {
+			t.Errorf("%q mapped to %s, want %s", declared, got, want)
+		}
+	}
+	if _, note := postgresType("WHATEVER"); note == "" {
+		t.Error("no note for an unknown type")
+	}
+}
+
+func TestExportDDLToPostgres(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY AUTOINCREMENT, customer_id INTEGER NOT NULL REFERENCES customers(id) ON DELETE CASCADE, total DECIMAL(10,2) DEFAULT 0, placed DATETIME, code VARCHAR(8) UNIQUE)",
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL, photo BLOB)",
+		"CREATE INDEX idx_orders_placed ON orders (placed)",
+	)
+
+	ddl, err := db.ExportDDL("postgres")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"id" BIGINT GENERATED BY DEFAULT AS IDENTITY PRIMARY KEY`,
+		`"customer_id" INTEGER NOT NULL`,
+		`"total" NUMERIC(10,2) DEFAULT 0`,
+		`"placed" TIMESTAMP`,
+		`"code" VARCHAR(8)`,
+		`UNIQUE ("code")`,
+		`FOREIGN KEY ("customer_id") REFERENCES "customers" ("id") ON DELETE CASCADE`,
+		`"photo" BYTEA`,
+		`CREATE INDEX "idx_orders_placed" ON "orders" ("placed");`,
+		"-- orders: AUTOINCREMENT is covered by the identity column",
+	} {
+		if !strings.Contains(ddl, want) {
+			t.Errorf("DDL lacks %s:\n%s", want, ddl)
+		}
+	}
+	// The parent is created before the table referring to it
+	if strings.Index(ddl, `CREATE TABLE "customers"`) > strings.Index(ddl, `CREATE TABLE "orders"`) {
+		t.Errorf("orders is created before customers:\n%s", ddl)
+	}
+
+	if _, err := db.ExportDDL("oracle"); err == nil {
+		t.Error("accepted an unsupported dialect")
+	}
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestListDatabaseFiles(t *testing.T) {
+	dir := t.TempDir()
+	databases := []string{"a.db", "b.sqlite", "c.sqlite3", "d.db3", "MyData.DB", "Mixed.Sqlite3", "UPPER.DB3"}
+	for _, name := range databases {
+		db, err := sql.Open("sqlite3", filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("CREATE TABLE t (v)"); err != nil {
+			t.Fatal(err)
+		}
+		db.Close()
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not a database"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "fake.db"), []byte(strings.Repeat("not a database ", 100)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "folder.db"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ListDatabaseFiles(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, file := range files {
+		found[filepath.Base(file)] = true
+	}
+	for _, name := range databases {
+		if !found[name] {
+			t.Errorf("%s was not discovered", name)
+		}
+	}
+	if len(files) != len(databases) {
+		t.Fatalf("discovered %v, want only %v", files, databases)
+	}
+}
+
+func TestHasDatabaseExtension(t *testing.T) {
+	for path, want := range map[string]bool{
+		"a.db": true, "a.DB": true, "a.Db3": true, "dir/a.sqlite": true, "a.SQLITE3": true,
+		"a.db-wal": false, "a.db-journal": false, "a.dbx": false, "db": false, "a.sql": false,
+	} {
+		if got := HasDatabaseExtension(path); got != want {
+			t.Errorf("HasDatabaseExtension(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
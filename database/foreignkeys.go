@@ -0,0 +1,338 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// maxReportedViolations caps the foreign keys named in a violation error
+const maxReportedViolations = 5
+
+// namedForeignKeyPattern matches table-level "CONSTRAINT name FOREIGN KEY (columns)" clauses
+var namedForeignKeyPattern = regexp.MustCompile(`(?i)\bCONSTRAINT\s+("(?:[^"]|"")+"|` + "`[^`]+`" + `|\[[^\]]+\]|\w+)\s+FOREIGN\s+KEY\s*\(([^)]*)\)`)
+
+// SetForeignKeys turns enforcement of foreign key constraints on or off for all connections.
+// SQLite leaves it off unless a connection asks for it; this server turns it on by default.
+// The setting is kept when switching databases.
+func (s *SQLiteDB) SetForeignKeys(enabled bool) error {
+	s.settingsMu.Lock()
+	previous := s.foreignKeys
+	s.foreignKeys = enabled
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.foreignKeys = previous
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// GetForeignKeys reports whether foreign key constraints are enforced on a pool connection
+func (s *SQLiteDB) GetForeignKeys() (bool, error) {
+	var enabled bool
+	err := s.db.QueryRowContext(s.ctx(), "PRAGMA foreign_keys").Scan(&enabled)
+	return enabled, err
+}
+
+// isForeignKeyViolation reports whether err is an SQLITE_CONSTRAINT_FOREIGNKEY error
+func isForeignKeyViolation(err error) bool {
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintForeignKey
+}
+
+// ExplainForeignKeyError adds the violated foreign keys to err when it is a foreign key
+// violation; any other error is returned unchanged. SQLite only reports "FOREIGN KEY
+// constraint failed", so the statements that led to the error, the last one with args, are
+// replayed in a transaction with deferred foreign keys, PRAGMA foreign_key_check lists the
+// rows they left in violation, and the transaction is rolled back.
+func (s *SQLiteDB) ExplainForeignKeyError(err error, statements []string, args ...interface{}) error {
+	if !isForeignKeyViolation(err) || len(statements) == 0 {
+		return err
+	}
+	violations, checkErr := s.foreignKeyViolations(statements, args)
+	if checkErr != nil || len(violations) == 0 {
+		return err
+	}
+	if len(violations) > maxReportedViolations {
+		violations = append(violations[:maxReportedViolations], "...")
+	}
+	return fmt.Errorf("%w; violated: %s", err, strings.Join(violations, "; "))
+}
+
+// foreignKeyViolations replays statements and describes the foreign keys they violate
+func (s *SQLiteDB) foreignKeyViolations(statements []string, args []interface{}) ([]string, error) {
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+	if _, err := conn.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+		return nil, err
+	}
+
+	// Rows that violated a foreign key before the statements ran are not their doing
+	before, err := foreignKeyCheck(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for i, statement := range statements {
+		var stmtArgs []interface{}
+		if i == len(statements)-1 {
+			stmtArgs = args
+		}
+		// RESTRICT actions fail immediately even when foreign keys are deferred
+		if _, err := conn.ExecContext(ctx, statement, stmtArgs...); err != nil {
+			return nil, err
+		}
+	}
+	after, err := foreignKeyCheck(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	var order []string
+	for key, n := range after {
+		if n -= before[key]; n > 0 {
+			counts[key] = n
+			order = append(order, key)
+		}
+	}
+
+	// Sorted so errors are reported deterministically
+	sort.Strings(order)
+	var violations []string
+	for _, key := range order {
+		table, id, _ := strings.Cut(key, "\x00")
+		fkid, _ := strconv.ParseInt(id, 10, 64)
+		description, err := describeForeignKey(ctx, conn, table, fkid)
+		if err != nil {
+			return nil, err
+		}
+		rows := "rows"
+		if counts[key] == 1 {
+			rows = "row"
+		}
+		violations = append(violations, fmt.Sprintf("%s (%d %s)", description, counts[key], rows))
+	}
+	return violations, nil
+}
+
+// foreignKeyCheck counts the violating rows per table and foreign key id
+func foreignKeyCheck(ctx context.Context, conn *sql.Conn) (map[string]int, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT \"table\", fkid FROM pragma_foreign_key_check")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var table string
+		var fkid int64
+		if err := rows.Scan(&table, &fkid); err != nil {
+			return nil, err
+		}
+		counts[fmt.Sprintf("%s\x00%d", table, fkid)]++
+	}
+	return counts, rows.Err()
+}
+
+// describeForeignKey names a foreign key as "constraint name: child(columns) REFERENCES
+// parent(columns)", or without the name when the constraint is unnamed
+func describeForeignKey(ctx context.Context, conn *sql.Conn, table string, fkid int64) (string, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT "table", "from", "to" FROM pragma_foreign_key_list(?) WHERE id = ? ORDER BY seq`, table, fkid)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	var parent string
+	var from, to []string
+	for rows.Next() {
+		var fromCol string
+		var toCol sql.NullString
+		if err := rows.Scan(&parent, &fromCol, &toCol); err != nil {
+			return "", err
+		}
+		from = append(from, fromCol)
+		// A foreign key without parent columns references the primary key
+		if toCol.Valid {
+			to = append(to, toCol.String)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	description := fmt.Sprintf("%s(%s) REFERENCES %s", table, strings.Join(from, ", "), parent)
+	if len(to) > 0 {
+		description += "(" + strings.Join(to, ", ") + ")"
+	}
+
+	var tableSQL string
+	if err := conn.QueryRowContext(ctx, "SELECT sql FROM sqlite_master WHERE type='table' AND name = ?", table).Scan(&tableSQL); err == nil {
+		if name := foreignKeyName(tableSQL, from); name != "" {
+			description = fmt.Sprintf("constraint %s: %s", name, description)
+		}
+	}
+	return description, nil
+}
+
+// foreignKeyName finds the name of the table-level foreign key constraint on columns
+func foreignKeyName(tableSQL string, columns []string) string {
+	for _, match := range namedForeignKeyPattern.FindAllStringSubmatch(tableSQL, -1) {
+		var matchColumns []string
+		for _, column := range strings.Split(match[2], ",") {
+			matchColumns = append(matchColumns, unquoteIdentifier(strings.TrimSpace(column)))
+		}
+		if len(matchColumns) != len(columns) {
+			continue
+		}
+		same := true
+		for i := range columns {
+			same = same && strings.EqualFold(matchColumns[i], columns[i])
+		}
+		if same {
+			return unquoteIdentifier(match[1])
+		}
+	}
+	return ""
+}
+
+// DefaultForeignKeyViolations is the number of violating rows CheckForeignKeys reports at
+// most by default
+const DefaultForeignKeyViolations = 1000
+
+// TableForeignKeys lists the foreign keys of one table
+type TableForeignKeys struct {
+	Table       string       `json:"table"`
+	ForeignKeys []ForeignKey `json:"foreign_keys"`
+}
+
+// ForeignKeyViolation is a row whose foreign key has no matching parent row
+type ForeignKeyViolation struct {
+	Table string `json:"table"`
+	// Rowid is omitted for WITHOUT ROWID tables
+	Rowid           *int64 `json:"rowid,omitempty"`
+	ReferencedTable string `json:"referenced_table"`
+	ForeignKeyID    int64  `json:"foreign_key_id"`
+	// ForeignKey describes the constraint as child(columns) REFERENCES parent(columns)
+	ForeignKey string `json:"foreign_key"`
+}
+
+// ForeignKeyCheck reports the result of CheckForeignKeys
+type ForeignKeyCheck struct {
+	Violations []ForeignKeyViolation `json:"violations"`
+	// Total counts every violating row, also those beyond the reported ones
+	Total     int  `json:"total"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// ListForeignKeys returns the foreign keys of a table, or with an empty tableName those of
+// every table that has any
+func (s *SQLiteDB) ListForeignKeys(tableName string) ([]TableForeignKeys, error) {
+	tables := []string{tableName}
+	if tableName == "" {
+		var err error
+		if tables, err = s.GetTables(); err != nil {
+			return nil, err
+		}
+	} else if columns, err := s.GetTableSchema(tableName); err != nil {
+		return nil, err
+	} else if len(columns) == 0 {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	result := []TableForeignKeys{}
+	for _, table := range tables {
+		keys, err := s.GetForeignKeyList(table)
+		if err != nil {
+			return nil, err
+		}
+		if len(keys) > 0 || tableName != "" {
+			result = append(result, TableForeignKeys{Table: table, ForeignKeys: keys})
+		}
+	}
+	return result, nil
+}
+
+// CheckForeignKeys runs PRAGMA foreign_key_check on a table, or on every table when
+// tableName is empty, and reports the rows whose parent row is missing. Such rows are left
+// behind by writes made while enforcement was off, such as bulk imports, since SQLite only
+// checks foreign keys when a row is written. Up to maxViolations rows are reported,
+// DefaultForeignKeyViolations when not positive.
+func (s *SQLiteDB) CheckForeignKeys(tableName string, maxViolations int) (*ForeignKeyCheck, error) {
+	if maxViolations <= 0 {
+		maxViolations = DefaultForeignKeyViolations
+	}
+	query := "PRAGMA foreign_key_check"
+	if tableName != "" {
+		query = fmt.Sprintf("PRAGMA foreign_key_check(%s)", quoteIdentifier(tableName))
+	}
+
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	rows, err := conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("foreign_key_check failed: %w", err)
+	}
+	check := &ForeignKeyCheck{Violations: []ForeignKeyViolation{}}
+	for rows.Next() {
+		var violation ForeignKeyViolation
+		var rowid sql.NullInt64
+		if err := rows.Scan(&violation.Table, &rowid, &violation.ReferencedTable, &violation.ForeignKeyID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		check.Total++
+		if len(check.Violations) == maxViolations {
+			check.Truncated = true
+			continue
+		}
+		if rowid.Valid {
+			violation.Rowid = &rowid.Int64
+		}
+		check.Violations = append(check.Violations, violation)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("foreign_key_check failed: %w", err)
+	}
+
+	// The rows are read before describing them, as the connection runs one query at a time
+	descriptions := make(map[string]string)
+	for i := range check.Violations {
+		violation := &check.Violations[i]
+		key := fmt.Sprintf("%s\x00%d", violation.Table, violation.ForeignKeyID)
+		if _, ok := descriptions[key]; !ok {
+			if descriptions[key], err = describeForeignKey(ctx, conn, violation.Table, violation.ForeignKeyID); err != nil {
+				return nil, err
+			}
+		}
+		violation.ForeignKey = descriptions[key]
+	}
+	return check, nil
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestForeignKeysRejectOrphan(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE parents (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER, CONSTRAINT fk_child_parent FOREIGN KEY (parent_id) REFERENCES parents (id))",
+		"INSERT INTO parents VALUES (1)",
+	)
+
+	if enabled, err := db.GetForeignKeys(); err != nil || !enabled {
+		t.Fatalf("foreign keys enabled %v, %v; want on by default", enabled, err)
+	}
+	if _, err := db.ExecuteStatement("INSERT INTO children VALUES (1, 1)"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.ExecuteStatement("INSERT INTO children VALUES (2, 99)")
+	if err == nil {
+		t.Fatal("inserted a child without a parent")
+	}
+	want := "violated: constraint fk_child_parent: children(parent_id) REFERENCES parents(id) (1 row)"
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("got %v, want it to name %q", err, want)
+	}
+	if _, err := db.ExecuteStatement("DELETE FROM parents WHERE id = 1"); err == nil {
+		t.Fatal("deleted a parent with children")
+	}
+
+	// Every pool connection enforces them, and they stay on for the next database
+	for _, enabled := range pragmaOnEachConnection(t, db, "foreign_keys", 3) {
+		if enabled != 1 {
+			t.Fatal("a connection does not enforce foreign keys")
+		}
+	}
+	if err := db.SwitchDatabase(filepath.Join(t.TempDir(), "other.db")); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, err := db.GetForeignKeys(); err != nil || !enabled {
+		t.Fatalf("foreign keys enabled %v, %v after switching", enabled, err)
+	}
+}
+
+func TestSetForeignKeysOff(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE parents (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER REFERENCES parents)",
+	)
+	if err := db.SetForeignKeys(false); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, err := db.GetForeignKeys(); err != nil || enabled {
+		t.Fatalf("foreign keys enabled %v, %v; want off", enabled, err)
+	}
+	if _, err := db.ExecuteStatement("INSERT INTO children VALUES (1, 99)"); err != nil {
+		t.Fatalf("insert rejected with enforcement off: %v", err)
+	}
+
+	// Turning them back on reports new violations only, not the orphan already there
+	if err := db.SetForeignKeys(true); err != nil {
+		t.Fatal(err)
+	}
+	_, err := db.ExecuteStatement("INSERT INTO children VALUES (2, 98)")
+	if err == nil || !strings.Contains(err.Error(), "children(parent_id) REFERENCES parents (1 row)") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestForeignKeyName(t *testing.T) {
+	tableSQL := `CREATE TABLE c (a INTEGER, b INTEGER, CONSTRAINT "fk ab" FOREIGN KEY (a, "b") REFERENCES p (x, y), CONSTRAINT fk_a FOREIGN KEY (a) REFERENCES q)`
+	if name := foreignKeyName(tableSQL, []string{"a", "b"}); name != "fk ab" {
+		t.Fatalf("got %q for (a, b)", name)
+	}
+	if name := foreignKeyName(tableSQL, []string{"A"}); name != "fk_a" {
+		t.Fatalf("got %q for (a)", name)
+	}
+	if name := foreignKeyName(tableSQL, []string{"b"}); name != "" {
+		t.Fatalf("got %q for (b)", name)
+	}
+}
@@ -0,0 +1,111 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultFTSSearchLimit is the number of matches SearchFTS returns when no limit is given
+const DefaultFTSSearchLimit = 20
+
+// ErrFTS5Unavailable is returned when the SQLite library was built without the FTS5 extension
+var ErrFTS5Unavailable = errors.New("full-text search is unavailable: this build of SQLite does not include FTS5 (build the server with -tags sqlite_fts5)")
+
+var fts5TablePattern = regexp.MustCompile(`(?i)\bUSING\s+fts5\b`)
+
+// FTS5Available reports whether the linked SQLite library includes the FTS5 extension
+func (s *SQLiteDB) FTS5Available() (bool, error) {
+	var used int
+	if err := s.db.QueryRowContext(s.ctx(), "SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used); err != nil {
+		return false, err
+	}
+	return used == 1, nil
+}
+
+// checkFTS5 returns ErrFTS5Unavailable when FTS5 is not compiled in
+func (s *SQLiteDB) checkFTS5() error {
+	available, err := s.FTS5Available()
+	if err != nil {
+		return fmt.Errorf("failed to check for FTS5: %w", err)
+	}
+	if !available {
+		return ErrFTS5Unavailable
+	}
+	return nil
+}
+
+// CreateFTSTable creates an FTS5 virtual table indexing the given columns. tokenize, when not
+// empty, is passed as the table's tokenize option, such as "porter unicode61".
+func (s *SQLiteDB) CreateFTSTable(tableName string, columns []string, tokenize string) error {
+	if tableName == "" {
+		return fmt.Errorf("table name is required")
+	}
+	if err := validateIdentifier(tableName); err != nil {
+		return fmt.Errorf("invalid table name: %w", err)
+	}
+	if strings.HasPrefix(strings.ToLower(tableName), "sqlite_") {
+		return fmt.Errorf("table names beginning with 'sqlite_' are reserved")
+	}
+	if len(columns) == 0 {
+		return fmt.Errorf("no columns specified")
+	}
+	if err := s.checkFTS5(); err != nil {
+		return err
+	}
+
+	definitions := make([]string, 0, len(columns)+1)
+	for _, col := range columns {
+		if strings.TrimSpace(col) == "" {
+			return fmt.Errorf("column names cannot be empty")
+		}
+		if err := validateIdentifier(col); err != nil {
+			return fmt.Errorf("invalid column name: %w", err)
+		}
+		definitions = append(definitions, quoteIdentifier(col))
+	}
+	if tokenize != "" {
+		definitions = append(definitions, fmt.Sprintf("tokenize = '%s'", strings.ReplaceAll(tokenize, "'", "''")))
+	}
+
+	createSQL := fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s)", quoteIdentifier(tableName), strings.Join(definitions, ", "))
+	_, err := s.db.ExecContext(s.ctx(), createSQL)
+	return s.diskFullError(err)
+}
+
+// FTSSearchQuery returns the SELECT SearchFTS runs against an FTS5 table, with the MATCH
+// expression and the limit as its two parameters
+func FTSSearchQuery(tableName string) string {
+	table := quoteIdentifier(tableName)
+	return fmt.Sprintf("SELECT *, bm25(%s) AS rank FROM %s WHERE %s MATCH ? ORDER BY rank LIMIT ?", table, table, table)
+}
+
+// SearchFTS runs an FTS5 MATCH query against tableName and returns the matching rows, best
+// first, each with its bm25() score as "rank"; lower scores are better matches. limit is
+// DefaultFTSSearchLimit when not positive.
+func (s *SQLiteDB) SearchFTS(tableName, match string, limit int) ([]map[string]interface{}, error) {
+	if tableName == "" {
+		return nil, fmt.Errorf("table name is required")
+	}
+	if strings.TrimSpace(match) == "" {
+		return nil, fmt.Errorf("search query cannot be empty")
+	}
+	if limit <= 0 {
+		limit = DefaultFTSSearchLimit
+	}
+	if err := s.checkFTS5(); err != nil {
+		return nil, err
+	}
+
+	var sqlText string
+	err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&sqlText)
+	if err != nil {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if !fts5TablePattern.MatchString(sqlText) {
+		return nil, fmt.Errorf("table '%s' is not an FTS5 table", tableName)
+	}
+
+	return s.ExecuteQuery(FTSSearchQuery(tableName), match, limit)
+}
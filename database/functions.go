@@ -0,0 +1,275 @@
+package database
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLFunction is a Go function made available to SQL on every connection
+type SQLFunction struct {
+	Name        string
+	Description string
+	// Impl is passed to go-sqlite3's RegisterFunc: a func taking and returning SQL-compatible
+	// values, optionally returning an error as well
+	Impl interface{}
+	// Pure functions always return the same result for the same arguments, which lets
+	// SQLite use them in indexes and optimize repeated calls
+	Pure bool
+}
+
+var (
+	sqlFunctionsMu sync.RWMutex
+	sqlFunctions   = map[string]SQLFunction{}
+)
+
+// RegisterSQLFunction adds a function to the set registered on new connections, replacing
+// any function with the same name. Connections opened earlier are not affected.
+func RegisterSQLFunction(fn SQLFunction) {
+	sqlFunctionsMu.Lock()
+	defer sqlFunctionsMu.Unlock()
+	sqlFunctions[strings.ToLower(fn.Name)] = fn
+}
+
+// SQLFunctions returns the registered functions sorted by name
+func SQLFunctions() []SQLFunction {
+	sqlFunctionsMu.RLock()
+	defer sqlFunctionsMu.RUnlock()
+
+	functions := make([]SQLFunction, 0, len(sqlFunctions))
+	for _, fn := range sqlFunctions {
+		functions = append(functions, fn)
+	}
+	sort.Slice(functions, func(i, j int) bool { return functions[i].Name < functions[j].Name })
+	return functions
+}
+
+func init() {
+	for _, fn := range []SQLFunction{
+		// X REGEXP Y is evaluated by SQLite as regexp(Y, X)
+		{Name: "regexp", Description: "regexp(pattern, value): 1 if value matches the Go regular expression, NULL if either is NULL; used by the REGEXP operator", Impl: regexpMatch, Pure: true},
+		{Name: "slugify", Description: "slugify(text): lowercase text with runs of other characters replaced by '-'", Impl: slugify, Pure: true},
+		{Name: "sha256", Description: "sha256(value): hex-encoded SHA-256 digest of a text or blob", Impl: sha256Hex, Pure: true},
+		{Name: "base64_encode", Description: "base64_encode(value): standard base64 encoding of a text or blob", Impl: base64Encode, Pure: true},
+		{Name: "base64_decode", Description: "base64_decode(text): blob decoded from standard base64", Impl: base64Decode, Pure: true},
+		{Name: "levenshtein", Description: "levenshtein(a, b): edit distance between two strings, counted in characters", Impl: levenshtein, Pure: true},
+	} {
+		RegisterSQLFunction(fn)
+	}
+}
+
+// registerFunctions adds the enabled SQL functions to a new connection
+func (s *SQLiteDB) registerFunctions(conn *sqlite3.SQLiteConn) error {
+	s.settingsMu.RLock()
+	enabled := s.enabledFunctions
+	s.settingsMu.RUnlock()
+
+	for _, fn := range SQLFunctions() {
+		if enabled != nil && !enabled[fn.Name] {
+			continue
+		}
+		if err := conn.RegisterFunc(fn.Name, fn.Impl, fn.Pure); err != nil {
+			return fmt.Errorf("failed to register %s function: %w", fn.Name, err)
+		}
+	}
+	return nil
+}
+
+// SetEnabledFunctions limits the SQL functions registered on connections to the named ones.
+// A nil slice enables every registered function. Unknown names are rejected.
+func (s *SQLiteDB) SetEnabledFunctions(names []string) error {
+	var enabled map[string]bool
+	if names != nil {
+		known := make(map[string]bool)
+		for _, fn := range SQLFunctions() {
+			known[fn.Name] = true
+		}
+		enabled = make(map[string]bool)
+		for _, name := range names {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			if !known[name] {
+				return fmt.Errorf("unknown SQL function '%s'", name)
+			}
+			enabled[name] = true
+		}
+	}
+
+	s.settingsMu.Lock()
+	previous := s.enabledFunctions
+	s.enabledFunctions = enabled
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.enabledFunctions = previous
+		s.settingsMu.Unlock()
+		return err
+	}
+	return nil
+}
+
+// EnabledFunctions returns the SQL functions registered on this database's connections
+func (s *SQLiteDB) EnabledFunctions() []SQLFunction {
+	s.settingsMu.RLock()
+	enabled := s.enabledFunctions
+	s.settingsMu.RUnlock()
+
+	var functions []SQLFunction
+	for _, fn := range SQLFunctions() {
+		if enabled == nil || enabled[fn.Name] {
+			functions = append(functions, fn)
+		}
+	}
+	return functions
+}
+
+// maxCachedPatterns bounds the compiled pattern cache shared by all connections
+const maxCachedPatterns = 256
+
+var (
+	patternCacheMu sync.Mutex
+	patternCache   = make(map[string]*regexp.Regexp)
+)
+
+// compilePattern compiles a regular expression, reusing earlier compilations
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternCacheMu.Lock()
+	defer patternCacheMu.Unlock()
+
+	if re, ok := patternCache[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if len(patternCache) >= maxCachedPatterns {
+		patternCache = make(map[string]*regexp.Regexp)
+	}
+	patternCache[pattern] = re
+	return re, nil
+}
+
+// regexpMatch implements the REGEXP operator with Go's regexp syntax. As with LIKE, a NULL
+// operand gives NULL, so neither REGEXP nor NOT REGEXP selects it.
+func regexpMatch(pattern, value interface{}) (interface{}, error) {
+	if isNull(pattern) || isNull(value) {
+		return nil, nil
+	}
+	re, err := compilePattern(fmt.Sprint(pattern))
+	if err != nil {
+		return nil, fmt.Errorf("invalid regular expression: %w", err)
+	}
+
+	switch v := value.(type) {
+	case []byte:
+		return re.Match(v), nil
+	case string:
+		return re.MatchString(v), nil
+	default:
+		return re.MatchString(fmt.Sprint(v)), nil
+	}
+}
+
+// isNull reports whether a function argument is SQL NULL, which go-sqlite3 passes to
+// interface{} parameters as a nil []byte
+func isNull(value interface{}) bool {
+	b, ok := value.([]byte)
+	return value == nil || (ok && b == nil)
+}
+
+// sqlBytes returns the bytes of a text or blob argument; numbers use their text form
+func sqlBytes(value interface{}) []byte {
+	switch v := value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+func slugify(value interface{}) interface{} {
+	if isNull(value) {
+		return nil
+	}
+
+	var slug strings.Builder
+	pendingDash := false
+	for _, r := range strings.ToLower(string(sqlBytes(value))) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if pendingDash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			pendingDash = false
+			slug.WriteRune(r)
+		} else {
+			pendingDash = true
+		}
+	}
+	return slug.String()
+}
+
+func sha256Hex(value interface{}) interface{} {
+	if isNull(value) {
+		return nil
+	}
+	sum := sha256.Sum256(sqlBytes(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func base64Encode(value interface{}) interface{} {
+	if isNull(value) {
+		return nil
+	}
+	return base64.StdEncoding.EncodeToString(sqlBytes(value))
+}
+
+func base64Decode(value interface{}) (interface{}, error) {
+	if isNull(value) {
+		return nil, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sqlBytes(value))))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	return decoded, nil
+}
+
+func levenshtein(a, b interface{}) interface{} {
+	if isNull(a) || isNull(b) {
+		return nil
+	}
+	s, t := []rune(string(sqlBytes(a))), []rune(string(sqlBytes(b)))
+
+	// Two rows of the edit distance matrix are enough
+	previous := make([]int, len(t)+1)
+	current := make([]int, len(t)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(s); i++ {
+		current[0] = i
+		for j := 1; j <= len(t); j++ {
+			cost := 1
+			if s[i-1] == t[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return int64(previous[len(t)])
+}
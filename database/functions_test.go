@@ -0,0 +1,94 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRegexpOperator(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (email TEXT)",
+		"INSERT INTO users VALUES ('ann@example.com'), ('bob@example.org'), ('not an email'), (NULL)",
+	)
+
+	rows, err := db.ExecuteQuery(`SELECT email FROM users WHERE email REGEXP '^[a-z]+@example\.(com|net)$' ORDER BY email`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0]["email"] != "ann@example.com" {
+		t.Fatalf("matched %v", rows)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM users WHERE email NOT REGEXP '@'"); n != 1 {
+		t.Fatalf("NOT REGEXP matched %d rows, want 1 (NULL matches neither way)", n)
+	}
+	if _, err := db.ExecuteQuery("SELECT 'a' REGEXP '('"); err == nil {
+		t.Fatal("no error for an invalid pattern")
+	}
+
+	// Still registered after the pool is reopened
+	if _, err := db.SetJournalMode("DELETE"); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM users WHERE email REGEXP 'example'"); n != 2 {
+		t.Fatalf("REGEXP matched %d rows after reopening, want 2", n)
+	}
+}
+
+func TestCustomFunctions(t *testing.T) {
+	db := newTestDB(t)
+
+	rows, err := db.ExecuteQuery(`SELECT
+		slugify('  Hello, World! 2024 ') AS slug,
+		sha256('abc') AS digest,
+		base64_encode('hi there') AS encoded,
+		CAST(base64_decode('aGkgdGhlcmU=') AS TEXT) AS decoded,
+		hex(base64_decode(base64_encode(x'00ff10'))) AS blob_round_trip,
+		levenshtein('kitten', 'sitting') AS distance,
+		levenshtein('héllo', 'hello') AS rune_distance,
+		slugify(NULL) IS NULL AS null_in_null_out`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"slug":             "hello-world-2024",
+		"digest":           "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad",
+		"encoded":          "aGkgdGhlcmU=",
+		"decoded":          "hi there",
+		"blob_round_trip":  "00FF10",
+		"distance":         int64(3),
+		"rune_distance":    int64(1),
+		"null_in_null_out": int64(1),
+	}
+	if !reflect.DeepEqual(rows[0], want) {
+		t.Fatalf("got %v\nwant %v", rows[0], want)
+	}
+	if _, err := db.ExecuteQuery("SELECT base64_decode('not base64!')"); err == nil {
+		t.Fatal("no error for invalid base64")
+	}
+}
+
+func TestSetEnabledFunctions(t *testing.T) {
+	db := newTestDB(t)
+
+	if err := db.SetEnabledFunctions([]string{"slugify"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecuteQuery("SELECT slugify('A B')"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecuteQuery("SELECT sha256('a')"); err == nil {
+		t.Fatal("sha256 is registered although only slugify is enabled")
+	}
+	if names := db.EnabledFunctions(); len(names) != 1 || names[0].Name != "slugify" {
+		t.Fatalf("enabled functions %v", names)
+	}
+	if err := db.SetEnabledFunctions([]string{"nope"}); err == nil {
+		t.Fatal("enabled an unknown function")
+	}
+	if err := db.SetEnabledFunctions(nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecuteQuery("SELECT sha256('a')"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,38 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newTestDB opens a new database in a temporary directory and runs setup statements on it
+func newTestDB(t *testing.T, setup ...string) *SQLiteDB {
+	t.Helper()
+	db, err := NewSQLiteDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("failed to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	for _, statement := range setup {
+		if _, err := db.ExecuteStatement(statement); err != nil {
+			t.Fatalf("setup %q failed: %v", statement, err)
+		}
+	}
+	return db
+}
+
+// queryInt runs a query returning one integer
+func queryInt(t *testing.T, db *SQLiteDB, query string, args ...interface{}) int64 {
+	t.Helper()
+	var n int64
+	if err := db.db.QueryRow(query, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+// hasTable reports whether the database has a table of that name
+func hasTable(t *testing.T, db *SQLiteDB, name string) bool {
+	t.Helper()
+	return queryInt(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name) > 0
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// bareIdentifierPattern matches names SQLite accepts unquoted, unless they are keywords
+var bareIdentifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_$]*$`)
+
+// sqliteKeywords lists SQLite's keywords (https://www.sqlite.org/lang_keywords.html)
+var sqliteKeywords = makeKeywordSet(`ABORT ACTION ADD AFTER ALL ALTER ALWAYS ANALYZE AND AS ASC
+	ATTACH AUTOINCREMENT BEFORE BEGIN BETWEEN BY CASCADE CASE CAST CHECK COLLATE COLUMN COMMIT
+	CONFLICT CONSTRAINT CREATE CROSS CURRENT CURRENT_DATE CURRENT_TIME CURRENT_TIMESTAMP DATABASE
+	DEFAULT DEFERRABLE DEFERRED DELETE DESC DETACH DISTINCT DO DROP EACH ELSE END ESCAPE EXCEPT
+	EXCLUDE EXCLUSIVE EXISTS EXPLAIN FAIL FILTER FIRST FOLLOWING FOR FOREIGN FROM FULL GENERATED
+	GLOB GROUP GROUPS HAVING IF IGNORE IMMEDIATE IN INDEX INDEXED INITIALLY INNER INSERT INSTEAD
+	INTERSECT INTO IS ISNULL JOIN KEY LAST LEFT LIKE LIMIT MATCH MATERIALIZED NATURAL NO NOT
+	NOTHING NOTNULL NULL NULLS OF OFFSET ON OR ORDER OTHERS OUTER OVER PARTITION PLAN PRAGMA
+	PRECEDING PRIMARY QUERY RAISE RANGE RECURSIVE REFERENCES REGEXP REINDEX RELEASE RENAME REPLACE
+	RESTRICT RETURNING RIGHT ROLLBACK ROW ROWS SAVEPOINT SELECT SET TABLE TEMP TEMPORARY THEN TIES
+	TO TRANSACTION TRIGGER UNBOUNDED UNION UNIQUE UPDATE USING VACUUM VALUES VIEW VIRTUAL WHEN
+	WHERE WINDOW WITH WITHOUT`)
+
+func makeKeywordSet(list string) map[string]bool {
+	set := make(map[string]bool)
+	for _, word := range strings.Fields(list) {
+		set[word] = true
+	}
+	return set
+}
+
+// QuotedIdentifier describes how a name is written as an SQLite identifier
+type QuotedIdentifier struct {
+	Name   string `json:"name"`
+	Quoted string `json:"quoted"`
+	// NeedsQuoting is false when the name can also be written bare: a plain identifier that is
+	// not a keyword
+	NeedsQuoting bool `json:"needs_quoting"`
+	Keyword      bool `json:"keyword"`
+}
+
+// validateIdentifier rejects names that cannot be used as identifiers even when quoted:
+// SQLite cuts a name off at a NUL, and other control characters such as line breaks make a
+// name that cannot be typed back or shown in a listing
+func validateIdentifier(name string) error {
+	if name == "" {
+		return fmt.Errorf("identifier cannot be empty")
+	}
+	if strings.ContainsRune(name, 0) {
+		return fmt.Errorf("identifier cannot contain NUL characters")
+	}
+	if strings.IndexFunc(name, unicode.IsControl) >= 0 {
+		return fmt.Errorf("identifier %q cannot contain control characters", name)
+	}
+	return nil
+}
+
+// QuoteIdentifier returns name as a double-quoted SQLite identifier with embedded double
+// quotes doubled, the same quoting the server uses for the statements it builds, and reports
+// whether the name would also be valid unquoted
+func QuoteIdentifier(name string) (*QuotedIdentifier, error) {
+	if err := validateIdentifier(name); err != nil {
+		return nil, err
+	}
+	keyword := sqliteKeywords[strings.ToUpper(name)]
+	return &QuotedIdentifier{
+		Name:         name,
+		Quoted:       quoteIdentifier(name),
+		NeedsQuoting: keyword || !bareIdentifierPattern.MatchString(name),
+		Keyword:      keyword,
+	}, nil
+}
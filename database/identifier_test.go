@@ -0,0 +1,54 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name         string
+		quoted       string
+		needsQuoting bool
+		keyword      bool
+	}{
+		{"users", `"users"`, false, false},
+		{"_col$1", `"_col$1"`, false, false},
+		{"order items", `"order items"`, true, false},
+		{`say "hi"`, `"say ""hi"""`, true, false},
+		{`"`, `""""`, true, false},
+		{"select", `"select"`, true, true},
+		{"Order", `"Order"`, true, true},
+		{"1st", `"1st"`, true, false},
+		{"naïve", `"naïve"`, true, false},
+	}
+	db := newTestDB(t)
+	for _, test := range tests {
+		quoted, err := QuoteIdentifier(test.name)
+		if err != nil {
+			t.Fatalf("%q: %v", test.name, err)
+		}
+		if quoted.Quoted != test.quoted || quoted.NeedsQuoting != test.needsQuoting || quoted.Keyword != test.keyword {
+			t.Errorf("QuoteIdentifier(%q) = %+v, want %s, needs quoting %v, keyword %v",
+				test.name, quoted, test.quoted, test.needsQuoting, test.keyword)
+		}
+
+		// The quoted form names the table and column exactly
+		statement := fmt.Sprintf("CREATE TABLE %s (%s TEXT)", quoted.Quoted, quoted.Quoted)
+		if _, err := db.ExecuteStatement(statement); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+		if !hasTable(t, db, test.name) {
+			t.Errorf("%s did not create a table named %q", statement, test.name)
+		}
+		if n := queryInt(t, db, "SELECT count(*) FROM pragma_table_info(?) WHERE name = ?", test.name, test.name); n != 1 {
+			t.Errorf("%s did not create a column named %q", statement, test.name)
+		}
+	}
+
+	for _, name := range []string{"", "a\x00b", "line\nbreak", "tab\there"} {
+		if _, err := QuoteIdentifier(name); err == nil {
+			t.Errorf("accepted %q", name)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// inferSampleRows is how many rows InferTableFromQuery reads to type expression columns
+const inferSampleRows = 1000
+
+// InferredColumn is one column of a table definition derived from a query
+type InferredColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Source is "declared" when the type comes from a table column, "values" when it was
+	// inferred from the returned values, and "none" when every sampled value was NULL
+	Source string `json:"source"`
+}
+
+// InferTableFromQuery builds a CREATE TABLE statement whose columns match the result columns
+// of a SELECT. Columns read from a table keep their declared type; expression columns get
+// the affinity of the values in the first rows of the result. The query is only read.
+func (s *SQLiteDB) InferTableFromQuery(query, tableName string) (string, []InferredColumn, error) {
+	if tableName == "" {
+		return "", nil, fmt.Errorf("table name is required")
+	}
+	if strings.HasPrefix(strings.ToLower(tableName), "sqlite_") {
+		return "", nil, fmt.Errorf("table names beginning with 'sqlite_' are reserved")
+	}
+	if !IsSingleStatement(query) {
+		return "", nil, fmt.Errorf("the query must be a single SELECT statement")
+	}
+
+	rows, err := s.db.QueryContext(s.ctx(), strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	if err != nil {
+		return "", nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to get column types: %w", err)
+	}
+
+	columns := make([]InferredColumn, len(columnTypes))
+	affinities := make([]string, len(columnTypes))
+	needsValues := false
+	for i, ct := range columnTypes {
+		columns[i] = InferredColumn{Name: ct.Name(), Type: ct.DatabaseTypeName(), Source: "declared"}
+		if columns[i].Type == "" {
+			needsValues = true
+		}
+	}
+
+	if needsValues {
+		values := make([]interface{}, len(columnTypes))
+		pointers := make([]interface{}, len(columnTypes))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		for n := 0; n < inferSampleRows && rows.Next(); n++ {
+			if err := rows.Scan(pointers...); err != nil {
+				return "", nil, err
+			}
+			for i, value := range values {
+				affinities[i] = mergeAffinity(affinities[i], valueAffinity(value))
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	// Result column names can repeat (e.g. a.id, b.id) but table columns cannot
+	taken := make(map[string]bool)
+	defs := make([]string, len(columns))
+	for i := range columns {
+		col := &columns[i]
+		name := col.Name
+		for n := 2; taken[strings.ToLower(name)]; n++ {
+			name = fmt.Sprintf("%s_%d", col.Name, n)
+		}
+		taken[strings.ToLower(name)] = true
+		col.Name = name
+
+		if col.Type == "" {
+			col.Type, col.Source = affinities[i], "values"
+			if col.Type == "" {
+				col.Source = "none"
+			}
+		}
+		defs[i] = strings.TrimSpace(quoteIdentifier(col.Name) + " " + col.Type)
+	}
+
+	ddl := fmt.Sprintf("CREATE TABLE %s (\n    %s\n)", quoteIdentifier(tableName), strings.Join(defs, ",\n    "))
+	return ddl, columns, nil
+}
+
+// valueAffinity returns the storage class of a scanned value, or "" for NULL
+func valueAffinity(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return ""
+	case int64, int, bool:
+		return "INTEGER"
+	case float64:
+		return "REAL"
+	case []byte:
+		return "BLOB"
+	default:
+		return "TEXT"
+	}
+}
+
+// mergeAffinity combines the affinities seen so far in a column with that of another value:
+// integers widen to REAL, and any other mix falls back to TEXT
+func mergeAffinity(current, next string) string {
+	switch {
+	case next == "" || current == next:
+		return current
+	case current == "":
+		return next
+	case (current == "INTEGER" && next == "REAL") || (current == "REAL" && next == "INTEGER"):
+		return "REAL"
+	default:
+		return "TEXT"
+	}
+}
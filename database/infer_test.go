@@ -0,0 +1,81 @@
+package database
+
+import "testing"
+
+func TestInferTableFromQuery(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer VARCHAR(40), total DECIMAL(10,2))",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY, order_id INTEGER, sku TEXT)",
+		"INSERT INTO orders VALUES (1, 'ann', 9.5)",
+		"INSERT INTO items VALUES (1, 1, 'pen')",
+	)
+
+	ddl, columns, err := db.InferTableFromQuery(`
+		SELECT o.id, o.customer, o.total, i.id, i.sku,
+		       count(*) AS n, o.total * 2 AS doubled, upper(o.customer) AS shout, NULL AS missing
+		FROM orders o JOIN items i ON i.order_id = o.id GROUP BY i.id`, "summary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []InferredColumn{
+		{"id", "INTEGER", "declared"},
+		{"customer", "VARCHAR(40)", "declared"},
+		{"total", "DECIMAL(10,2)", "declared"},
+		{"id_2", "INTEGER", "declared"},
+		{"sku", "TEXT", "declared"},
+		{"n", "INTEGER", "values"},
+		{"doubled", "REAL", "values"},
+		{"shout", "TEXT", "values"},
+		{"missing", "", "none"},
+	}
+	if len(columns) != len(want) {
+		t.Fatalf("got columns %v, want %v", columns, want)
+	}
+	for i := range want {
+		if columns[i] != want[i] {
+			t.Errorf("column %d is %+v, want %+v", i, columns[i], want[i])
+		}
+	}
+
+	// The statement creates a table with the same column set
+	if _, err := db.ExecuteStatement(ddl); err != nil {
+		t.Fatalf("generated DDL %s failed: %v", ddl, err)
+	}
+	rows, err := db.ExecuteQuery("SELECT name, type FROM pragma_table_info('summary') ORDER BY cid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("created table has columns %v", rows)
+	}
+	for i, row := range rows {
+		if row["name"] != want[i].Name || row["type"] != want[i].Type {
+			t.Errorf("created column %d is %v, want %+v", i, row, want[i])
+		}
+	}
+
+	for _, name := range []string{"", "sqlite_x"} {
+		if _, _, err := db.InferTableFromQuery("SELECT 1", name); err == nil {
+			t.Errorf("accepted table name %q", name)
+		}
+	}
+	if _, _, err := db.InferTableFromQuery("SELECT 1; SELECT 2", "t"); err == nil {
+		t.Error("accepted two statements")
+	}
+}
+
+func TestMergeAffinity(t *testing.T) {
+	tests := []struct{ current, next, want string }{
+		{"", "INTEGER", "INTEGER"},
+		{"INTEGER", "", "INTEGER"},
+		{"INTEGER", "REAL", "REAL"},
+		{"REAL", "INTEGER", "REAL"},
+		{"INTEGER", "TEXT", "TEXT"},
+		{"BLOB", "REAL", "TEXT"},
+	}
+	for _, test := range tests {
+		if got := mergeAffinity(test.current, test.next); got != test.want {
+			t.Errorf("mergeAffinity(%q, %q) = %q, want %q", test.current, test.next, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// MaxInsertRows caps the rows InsertRows accepts in one call
+const MaxInsertRows = 100000
+
+// maxInsertVariables is the number of bound values per INSERT statement, SQLite's historic
+// SQLITE_MAX_VARIABLE_NUMBER, which every build accepts
+const maxInsertVariables = 999
+
+// InsertRowsResult reports the outcome of InsertRows
+type InsertRowsResult struct {
+	Inserted   int64 `json:"inserted"`
+	Statements int   `json:"statements"`
+	// FirstInsertID and LastInsertID are the rowids of the first and last row, omitted for
+	// WITHOUT ROWID tables
+	FirstInsertID *int64 `json:"first_insert_id,omitempty"`
+	LastInsertID  *int64 `json:"last_insert_id,omitempty"`
+}
+
+// InsertRows inserts rows into a table with multi-row INSERT statements of up to
+// maxInsertVariables values each, all in one transaction, so either every row is inserted
+// or, when one fails, none are. Every row must have the same columns, and those must exist
+// in the table.
+func (s *SQLiteDB) InsertRows(ctx context.Context, tableName string, rows []map[string]interface{}) (*InsertRowsResult, error) {
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows given")
+	}
+	if len(rows) > MaxInsertRows {
+		return nil, fmt.Errorf("at most %d rows can be inserted at once", MaxInsertRows)
+	}
+	schema, err := s.GetTableSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(schema) == 0 {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	known := make(map[string]string)
+	for _, col := range schema {
+		name, _ := col["name"].(string)
+		known[strings.ToLower(name)] = name
+	}
+
+	// The first row fixes the columns, spelled as the row spells them so later rows can be
+	// read with the same keys
+	var keys, quoted []string
+	for key := range rows[0] {
+		declared, ok := known[strings.ToLower(key)]
+		if !ok {
+			return nil, fmt.Errorf("table '%s' has no column '%s'", tableName, key)
+		}
+		keys = append(keys, key)
+		quoted = append(quoted, quoteIdentifier(declared))
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("row 0 has no columns")
+	}
+	if len(keys) > maxInsertVariables {
+		return nil, fmt.Errorf("a row can have at most %d columns", maxInsertVariables)
+	}
+	for i, row := range rows[1:] {
+		if len(row) != len(keys) {
+			return nil, fmt.Errorf("row %d has %d columns but row 0 has %d; every row must have the same columns", i+1, len(row), len(keys))
+		}
+		for _, key := range keys {
+			if _, ok := row[key]; !ok {
+				return nil, fmt.Errorf("row %d has no value for column '%s', which row 0 has; every row must have the same columns", i+1, key)
+			}
+		}
+	}
+
+	rowids := true
+	if rowid, err := s.GetRowidColumn(tableName); err != nil {
+		return nil, err
+	} else if rowid.WithoutRowid {
+		rowids = false
+	}
+
+	tuple := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(keys)), ", ") + ")"
+	insert := func(count int) string {
+		return fmt.Sprintf("INSERT INTO %s (%s) VALUES %s", quoteIdentifier(tableName), strings.Join(quoted, ", "),
+			strings.TrimSuffix(strings.Repeat(tuple+", ", count), ", "))
+	}
+	perStatement := maxInsertVariables / len(keys)
+
+	ctx, cancel := s.statementContext(ctx)
+	defer cancel()
+
+	result := &InsertRowsResult{}
+	err = s.RetryTransaction(func(tx *sql.Tx) error {
+		*result = InsertRowsResult{}
+		// The first row goes in alone: last_insert_rowid only reports the last row of a
+		// statement, and rowids given in the rows need not be consecutive
+		chunks := []int{1}
+		for rest := len(rows) - 1; rest > 0; rest -= perStatement {
+			chunks = append(chunks, min(rest, perStatement))
+		}
+
+		statements := make(map[int]*sql.Stmt)
+		defer func() {
+			for _, stmt := range statements {
+				stmt.Close()
+			}
+		}()
+		start := 0
+		for _, count := range chunks {
+			stmt := statements[count]
+			if stmt == nil {
+				var err error
+				if stmt, err = tx.PrepareContext(ctx, insert(count)); err != nil {
+					return timeoutError(ctx, err)
+				}
+				statements[count] = stmt
+			}
+			args := make([]interface{}, 0, count*len(keys))
+			for _, row := range rows[start : start+count] {
+				for _, key := range keys {
+					args = append(args, row[key])
+				}
+			}
+			res, err := stmt.ExecContext(ctx, args...)
+			if err != nil {
+				if count == 1 {
+					return fmt.Errorf("row %d: %w", start, timeoutError(ctx, err))
+				}
+				return fmt.Errorf("rows %d to %d: %w", start, start+count-1, timeoutError(ctx, err))
+			}
+			affected, _ := res.RowsAffected()
+			result.Inserted += affected
+			result.Statements++
+			if rowids {
+				id, _ := res.LastInsertId()
+				if result.FirstInsertID == nil {
+					result.FirstInsertID = &id
+				}
+				result.LastInsertID = &id
+			}
+			start += count
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultIntegrityErrors is the number of problems IntegrityCheck reports at most by default
+const DefaultIntegrityErrors = 100
+
+// IntegrityCheck runs PRAGMA integrity_check, or the faster PRAGMA quick_check with quick,
+// which skips checking that indexes match their tables. The result is ["ok"] for a healthy
+// database and otherwise lists up to maxErrors problems, DefaultIntegrityErrors when not
+// positive. A file too damaged to check at all returns an error.
+func (s *SQLiteDB) IntegrityCheck(quick bool, maxErrors int) ([]string, error) {
+	if maxErrors <= 0 {
+		maxErrors = DefaultIntegrityErrors
+	}
+	pragma := "integrity_check"
+	if quick {
+		pragma = "quick_check"
+	}
+
+	rows, err := s.db.QueryContext(s.ctx(), fmt.Sprintf("PRAGMA %s(%d)", pragma, maxErrors))
+	if err != nil {
+		return nil, fmt.Errorf("%s failed: %w", pragma, err)
+	}
+	defer rows.Close()
+
+	var messages []string
+	for rows.Next() {
+		var message string
+		if err := rows.Scan(&message); err != nil {
+			return nil, err
+		}
+		// A row can hold several problems, one per line, under a "*** in database main ***" heading
+		for _, line := range strings.Split(message, "\n") {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "***") {
+				messages = append(messages, line)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s failed: %w", pragma, err)
+	}
+	if len(messages) > maxErrors {
+		messages = messages[:maxErrors]
+	}
+	return messages, nil
+}
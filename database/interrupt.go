@@ -0,0 +1,108 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrQueryTimeout is returned when a statement is interrupted because it ran longer than the
+// query timeout
+var ErrQueryTimeout = errors.New("query cancelled")
+
+// WithContext returns a handle of the database whose statements also end when ctx ends, so
+// that a tool call cancels its own statements without interrupting those of other calls.
+// The handle shares the connections and settings of s.
+func (s *SQLiteDB) WithContext(ctx context.Context) *SQLiteDB {
+	return &SQLiteDB{dbState: s.dbState, call: ctx}
+}
+
+// ctx returns the context statements run under. It ends when Interrupt is called and, for a
+// handle made by WithContext, when the context of the call ends.
+func (s *SQLiteDB) ctx() context.Context {
+	interrupted := s.interruptContext()
+	if s.call == nil {
+		return interrupted
+	}
+	ctx, cancel := context.WithCancel(s.call)
+	stop := context.AfterFunc(interrupted, cancel)
+	context.AfterFunc(ctx, func() { stop() })
+	if interrupted.Err() != nil {
+		// AfterFunc cancels in a goroutine of its own, which may not have run yet
+		cancel()
+	}
+	return ctx
+}
+
+// interruptContext returns the context that stays valid until Interrupt is called. What
+// outlives a single call, such as a cursor kept open by ReadBatch, runs under it.
+func (s *SQLiteDB) interruptContext() context.Context {
+	s.interruptMu.Lock()
+	defer s.interruptMu.Unlock()
+
+	if s.interruptCtx == nil {
+		s.interruptCtx, s.cancelInterrupt = context.WithCancel(context.Background())
+	}
+	return s.interruptCtx
+}
+
+// Interrupt cancels every statement currently running on the database, including
+// open transactions, which are rolled back. Statements started afterwards are not affected.
+func (s *SQLiteDB) Interrupt() {
+	s.interruptMu.Lock()
+	defer s.interruptMu.Unlock()
+
+	if s.cancelInterrupt != nil {
+		s.cancelInterrupt()
+	}
+	s.interruptCtx, s.cancelInterrupt = context.WithCancel(context.Background())
+}
+
+// SetQueryTimeout bounds how long a single query or statement run through ExecuteQuery or
+// ExecuteStatement may take. The driver interrupts a statement whose context ends, so a
+// runaway query is stopped rather than left running. Zero disables the timeout.
+func (s *SQLiteDB) SetQueryTimeout(timeout time.Duration) {
+	s.settingsMu.Lock()
+	s.queryTimeout = timeout
+	s.settingsMu.Unlock()
+}
+
+// statementContext returns the context one statement runs under. It ends when parent ends,
+// when Interrupt is called, or when the query timeout elapses, whichever comes first.
+func (s *SQLiteDB) statementContext(parent context.Context) (context.Context, context.CancelFunc) {
+	s.settingsMu.RLock()
+	timeout := s.queryTimeout
+	s.settingsMu.RUnlock()
+
+	ctx, cancel := context.WithCancel(parent)
+	base := s.ctx()
+	stop := context.AfterFunc(base, cancel)
+	if base.Err() != nil {
+		cancel()
+	}
+	if timeout <= 0 {
+		return ctx, func() {
+			stop()
+			cancel()
+		}
+	}
+	ctx, cancelTimeout := context.WithTimeoutCause(ctx, timeout, fmt.Errorf("%w after %s", ErrQueryTimeout, timeout))
+	return ctx, func() {
+		cancelTimeout()
+		stop()
+		cancel()
+	}
+}
+
+// timeoutError names the query timeout as the reason a statement failed when it was the
+// timeout that interrupted it, and returns any other error unchanged
+func timeoutError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if cause := context.Cause(ctx); errors.Is(cause, ErrQueryTimeout) {
+		return fmt.Errorf("%w; the statement was interrupted (%v)", cause, err)
+	}
+	return err
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowQuery counts far enough to run for minutes
+const slowQuery = "WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 10000000000) SELECT count(*) FROM c"
+
+func TestQueryTimeoutInterruptsSlowQuery(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (n INTEGER)")
+	// One connection, so a statement left running would block the next
+	db.SetSerialized(true)
+	db.SetQueryTimeout(100 * time.Millisecond)
+
+	start := time.Now()
+	_, err := db.ExecuteQuery(slowQuery)
+	if !errors.Is(err, ErrQueryTimeout) || !strings.Contains(err.Error(), "query cancelled after 100ms") {
+		t.Fatalf("got %v, want the query cancelled after 100ms", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("the query ran for %s", elapsed)
+	}
+
+	_, err = db.ExecuteStatement("INSERT INTO t WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 10000000000) SELECT i FROM c")
+	if !errors.Is(err, ErrQueryTimeout) {
+		t.Fatalf("got %v, want the statement cancelled", err)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM t"); n != 0 {
+		t.Fatalf("the interrupted insert left %d rows", n)
+	}
+
+	// The connection is free again and fast queries are unaffected
+	if rows, err := db.ExecuteQuery("SELECT 1 AS one"); err != nil || rows[0]["one"] != int64(1) {
+		t.Fatalf("query after the timeout: %v, %v", rows, err)
+	}
+}
+
+func TestCallContextInterruptsSlowQuery(t *testing.T) {
+	db := newTestDB(t)
+	db.SetQueryTimeout(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err := db.ExecuteQueryColumnsContext(ctx, slowQuery)
+	if err == nil {
+		t.Fatal("the query outlived its context")
+	}
+	// Only the query timeout is reported as such
+	if errors.Is(err, ErrQueryTimeout) {
+		t.Fatalf("a cancelled call reported as a query timeout: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("the query ran for %s", elapsed)
+	}
+}
@@ -0,0 +1,558 @@
+package database
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JSONExportFormat identifies documents written by ExportJSON
+const JSONExportFormat = "mcp-sqlite-server/json-export"
+
+// jsonExportVersion is the version of the document layout
+const jsonExportVersion = 1
+
+// redactedJSONValue replaces the values of redacted columns in an export
+const redactedJSONValue = "***"
+
+// JSONSchemaObject is one table, index, view, or trigger of an exported database
+type JSONSchemaObject struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Table string `json:"table"`
+	SQL   string `json:"sql"`
+}
+
+// JSONColumn describes a column of an exported table
+type JSONColumn struct {
+	Name       string `json:"name"`
+	Type       string `json:"type"`
+	NotNull    bool   `json:"not_null"`
+	PrimaryKey int    `json:"primary_key"`
+}
+
+// JSONExportTable reports how many rows of a table were exported or imported
+type JSONExportTable struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+}
+
+// JSONExport reports the outcome of ExportJSON
+type JSONExport struct {
+	Path    string            `json:"path"`
+	Bytes   int64             `json:"bytes"`
+	Tables  []JSONExportTable `json:"tables"`
+	Skipped []string          `json:"skipped,omitempty"`
+}
+
+// JSONImport reports the outcome of ImportJSON
+type JSONImport struct {
+	Path   string            `json:"path"`
+	Tables []JSONExportTable `json:"tables"`
+	// Failed lists indexes, views, and triggers that could not be recreated
+	Failed []string `json:"failed_objects,omitempty"`
+}
+
+// ExportJSON writes the schema and all rows of the database to a new file at path as one JSON
+// document that non-SQLite systems can read:
+//
+//	{"format": ..., "version": 1, "exported_at": ..., "schema": [objects in creation order],
+//	 "sequences": {table: seq}, "tables": [{"name": ..., "columns": [...], "rows": [[...], ...]}]}
+//
+// Rows are arrays in column order. BLOBs are written as {"base64": "..."} and REAL values
+// always carry a decimal point or exponent, so ImportJSON restores every storage class.
+// Rows are streamed to the file, so the document is never held in memory. Tables for which
+// include returns false are left out with the objects that belong to them, as are virtual
+// tables, and non-NULL values of columns for which redact returns true are written as "***".
+func (s *SQLiteDB) ExportJSON(path string, include func(table string) bool, redact func(column string) bool) (result *JSONExport, err error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		file.Close()
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	ctx := s.ctx()
+	// A read transaction gives a consistent snapshot across tables
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	objects, err := jsonSchemaObjects(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+
+	result = &JSONExport{Path: path}
+	var schema []JSONSchemaObject
+	var tables, virtualTables []string
+	skipped := make(map[string]bool)
+	for _, obj := range objects {
+		if obj.Type == "table" && virtualTablePattern.MatchString(obj.SQL) {
+			virtualTables = append(virtualTables, obj.Name)
+		}
+		if obj.Type == "table" && (virtualTablePattern.MatchString(obj.SQL) || shadowTableOf(obj.Name, virtualTables) != "" || !include(obj.Name)) {
+			skipped[obj.Name] = true
+			result.Skipped = append(result.Skipped, obj.Name)
+			continue
+		}
+		if skipped[obj.Table] {
+			continue
+		}
+		schema = append(schema, obj)
+		if obj.Type == "table" {
+			tables = append(tables, obj.Name)
+		}
+	}
+
+	sequences := make(map[string]int64)
+	if rows, err := tx.QueryContext(ctx, "SELECT name, seq FROM sqlite_sequence"); err == nil {
+		for rows.Next() {
+			var name string
+			var seq int64
+			if err := rows.Scan(&name, &seq); err == nil && !skipped[name] {
+				sequences[name] = seq
+			}
+		}
+		rows.Close()
+	}
+
+	w := bufio.NewWriter(file)
+	header, err := json.Marshal(struct {
+		Format     string             `json:"format"`
+		Version    int                `json:"version"`
+		ExportedAt string             `json:"exported_at"`
+		Schema     []JSONSchemaObject `json:"schema"`
+		Sequences  map[string]int64   `json:"sequences"`
+	}{JSONExportFormat, jsonExportVersion, time.Now().UTC().Format(time.RFC3339), schema, sequences})
+	if err != nil {
+		return nil, err
+	}
+	// The header object is left open so the tables can be streamed after it
+	w.Write(header[:len(header)-1])
+	w.WriteString(`,"tables":[`)
+
+	for i, table := range tables {
+		if i > 0 {
+			w.WriteString(",")
+		}
+		rows, err := writeJSONTable(ctx, tx, w, table, redact)
+		if err != nil {
+			return nil, fmt.Errorf("table '%s': %w", table, err)
+		}
+		result.Tables = append(result.Tables, JSONExportTable{Name: table, Rows: rows})
+	}
+	w.WriteString("]}\n")
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	result.Bytes = info.Size()
+	return result, nil
+}
+
+// jsonSchemaObjects reads the user objects of the database in creation order
+func jsonSchemaObjects(ctx context.Context, tx *sql.Tx) ([]JSONSchemaObject, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT type, name, tbl_name, sql FROM sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY rowid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []JSONSchemaObject
+	for rows.Next() {
+		var obj JSONSchemaObject
+		if err := rows.Scan(&obj.Type, &obj.Name, &obj.Table, &obj.SQL); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// writeJSONTable writes one table object with its columns and rows
+func writeJSONTable(ctx context.Context, tx *sql.Tx, w *bufio.Writer, table string, redact func(string) bool) (int64, error) {
+	// Generated columns are computed from the others and are not exported
+	info, err := tx.QueryContext(ctx, `SELECT name, type, "notnull", pk FROM pragma_table_xinfo(?) WHERE hidden = 0 ORDER BY cid`, table)
+	if err != nil {
+		return 0, err
+	}
+	var columns []JSONColumn
+	var selected []string
+	for info.Next() {
+		var col JSONColumn
+		if err := info.Scan(&col.Name, &col.Type, &col.NotNull, &col.PrimaryKey); err != nil {
+			info.Close()
+			return 0, err
+		}
+		columns = append(columns, col)
+		selected = append(selected, quoteIdentifier(col.Name))
+	}
+	info.Close()
+	if err := info.Err(); err != nil {
+		return 0, err
+	}
+
+	header, err := json.Marshal(struct {
+		Name    string       `json:"name"`
+		Columns []JSONColumn `json:"columns"`
+	}{table, columns})
+	if err != nil {
+		return 0, err
+	}
+	w.Write(header[:len(header)-1])
+	w.WriteString(`,"rows":[`)
+
+	rows, err := tx.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s", strings.Join(selected, ", "), quoteIdentifier(table)))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	masked := make([]bool, len(columns))
+	for i, col := range columns {
+		masked[i] = redact(col.Name)
+	}
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	var count int64
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, err
+		}
+		if count > 0 {
+			w.WriteString(",")
+		}
+		w.WriteString("\n[")
+		for i, value := range values {
+			if i > 0 {
+				w.WriteString(",")
+			}
+			if masked[i] && value != nil {
+				value = redactedJSONValue
+			}
+			if err := writeJSONValue(w, value); err != nil {
+				return 0, err
+			}
+		}
+		w.WriteString("]")
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	w.WriteString("]}")
+	return count, nil
+}
+
+// writeJSONValue writes one column value, keeping its SQLite storage class recoverable
+func writeJSONValue(w io.Writer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		_, err := io.WriteString(w, "null")
+		return err
+	case []byte:
+		_, err := fmt.Fprintf(w, `{"base64":"%s"}`, base64.StdEncoding.EncodeToString(v))
+		return err
+	case float64:
+		var text string
+		switch {
+		case math.IsInf(v, 1):
+			text = "1e999"
+		case math.IsInf(v, -1):
+			text = "-1e999"
+		default:
+			text = strconv.FormatFloat(v, 'g', -1, 64)
+			if !strings.ContainsAny(text, ".e") {
+				text += ".0"
+			}
+		}
+		_, err := io.WriteString(w, text)
+		return err
+	case time.Time:
+		value = v.Format(time.RFC3339Nano)
+	}
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded)
+	return err
+}
+
+// ImportJSON rebuilds a database from a document written by ExportJSON into a new database
+// file at destPath. Tables are created and filled first; indexes, views, and triggers follow
+// so that triggers do not fire on the imported rows. Rows are read from the document one at
+// a time. The current database is not changed.
+func ImportJSON(sourcePath, destPath string) (result *JSONImport, err error) {
+	source, err := os.Open(sourcePath)
+	if err != nil {
+		return nil, err
+	}
+	defer source.Close()
+
+	if _, err := os.Stat(destPath); err == nil {
+		return nil, fmt.Errorf("file '%s' already exists", destPath)
+	}
+	db, err := sql.Open("sqlite3", "file:"+uriEscaper.Replace(destPath))
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		db.Close()
+		if err != nil {
+			os.Remove(destPath)
+		}
+	}()
+	db.SetMaxOpenConns(1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result = &JSONImport{Path: destPath}
+	var deferred []JSONSchemaObject
+	sequences := make(map[string]int64)
+	created := false
+
+	decoder := json.NewDecoder(bufio.NewReader(source))
+	decoder.UseNumber()
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "format":
+			var format string
+			if err := decoder.Decode(&format); err != nil {
+				return nil, err
+			}
+			if format != JSONExportFormat {
+				return nil, fmt.Errorf("not an export_json document: format is '%s'", format)
+			}
+		case "version":
+			var version int
+			if err := decoder.Decode(&version); err != nil {
+				return nil, err
+			}
+			if version > jsonExportVersion {
+				return nil, fmt.Errorf("document version %d is newer than the supported version %d", version, jsonExportVersion)
+			}
+		case "schema":
+			var schema []JSONSchemaObject
+			if err := decoder.Decode(&schema); err != nil {
+				return nil, fmt.Errorf("invalid schema: %w", err)
+			}
+			for _, obj := range schema {
+				if obj.Type != "table" {
+					deferred = append(deferred, obj)
+					continue
+				}
+				if _, err := tx.Exec(obj.SQL); err != nil {
+					return nil, fmt.Errorf("failed to create table '%s': %w", obj.Name, err)
+				}
+			}
+			created = true
+		case "sequences":
+			if err := decoder.Decode(&sequences); err != nil {
+				return nil, fmt.Errorf("invalid sequences: %w", err)
+			}
+		case "tables":
+			if !created {
+				return nil, fmt.Errorf("the schema must come before the tables")
+			}
+			if err := expectDelim(decoder, '['); err != nil {
+				return nil, err
+			}
+			for decoder.More() {
+				table, err := importJSONTable(decoder, tx)
+				if err != nil {
+					return nil, err
+				}
+				result.Tables = append(result.Tables, *table)
+			}
+			if err := expectDelim(decoder, ']'); err != nil {
+				return nil, err
+			}
+		default:
+			// Unknown keys are skipped for forward compatibility
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !created {
+		return nil, fmt.Errorf("the document has no schema")
+	}
+
+	// Row inserts advanced the AUTOINCREMENT counters; the exported values replace them
+	if len(sequences) > 0 {
+		if _, err := tx.Exec("DELETE FROM sqlite_sequence"); err == nil {
+			for name, seq := range sequences {
+				if _, err := tx.Exec("INSERT INTO sqlite_sequence(name, seq) VALUES (?, ?)", name, seq); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	for _, obj := range deferred {
+		if _, err := tx.Exec(obj.SQL); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s %s: %v", obj.Type, obj.Name, err))
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// expectDelim reads the next token and checks that it is delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != delim {
+		return fmt.Errorf("invalid document: expected '%s', found %v", delim, token)
+	}
+	return nil
+}
+
+// importJSONTable reads one table object and inserts its rows
+func importJSONTable(decoder *json.Decoder, tx *sql.Tx) (*JSONExportTable, error) {
+	if err := expectDelim(decoder, '{'); err != nil {
+		return nil, err
+	}
+	table := &JSONExportTable{}
+	var columns []JSONColumn
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch key {
+		case "name":
+			if err := decoder.Decode(&table.Name); err != nil {
+				return nil, err
+			}
+		case "columns":
+			if err := decoder.Decode(&columns); err != nil {
+				return nil, err
+			}
+		case "rows":
+			if table.Name == "" || len(columns) == 0 {
+				return nil, fmt.Errorf("a table's name and columns must come before its rows")
+			}
+			if err := insertJSONRows(decoder, tx, table, columns); err != nil {
+				return nil, fmt.Errorf("table '%s': %w", table.Name, err)
+			}
+		default:
+			var skip json.RawMessage
+			if err := decoder.Decode(&skip); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := expectDelim(decoder, '}'); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// insertJSONRows streams the rows array of a table into it
+func insertJSONRows(decoder *json.Decoder, tx *sql.Tx, table *JSONExportTable, columns []JSONColumn) error {
+	names := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	for i, col := range columns {
+		names[i] = quoteIdentifier(col.Name)
+		placeholders[i] = "?"
+	}
+	stmt, err := tx.Prepare(fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		quoteIdentifier(table.Name), strings.Join(names, ", "), strings.Join(placeholders, ", ")))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	if err := expectDelim(decoder, '['); err != nil {
+		return err
+	}
+	for decoder.More() {
+		var row []interface{}
+		if err := decoder.Decode(&row); err != nil {
+			return fmt.Errorf("row %d: %w", table.Rows+1, err)
+		}
+		if len(row) != len(columns) {
+			return fmt.Errorf("row %d has %d values, expected %d", table.Rows+1, len(row), len(columns))
+		}
+		for i, value := range row {
+			if row[i], err = importJSONValue(value); err != nil {
+				return fmt.Errorf("row %d, column '%s': %w", table.Rows+1, columns[i].Name, err)
+			}
+		}
+		if _, err := stmt.Exec(row...); err != nil {
+			return fmt.Errorf("row %d: %w", table.Rows+1, err)
+		}
+		table.Rows++
+	}
+	return expectDelim(decoder, ']')
+}
+
+// importJSONValue converts a decoded JSON value back into the value ExportJSON wrote
+func importJSONValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case json.Number:
+		text := v.String()
+		if !strings.ContainsAny(text, ".eE") {
+			if n, err := v.Int64(); err == nil {
+				return n, nil
+			}
+		}
+		f, err := strconv.ParseFloat(text, 64)
+		if err != nil && !math.IsInf(f, 0) {
+			return nil, err
+		}
+		return f, nil
+	case map[string]interface{}:
+		data, _, err := DecodeBlob(v)
+		return data, err
+	case []interface{}:
+		return nil, fmt.Errorf("arrays are not valid column values")
+	}
+	return value, nil
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// dumpRows returns every row of table with the storage class and hex form of each value
+func dumpRows(t *testing.T, db *SQLiteDB, table string, columns ...string) string {
+	t.Helper()
+	var exprs []string
+	for _, column := range columns {
+		exprs = append(exprs, "typeof("+column+")", "hex("+column+")")
+	}
+	rows, err := db.ExecuteQuery("SELECT " + strings.Join(exprs, ", ") + " FROM " + table + " ORDER BY rowid")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(rows)
+	return string(encoded)
+}
+
+// schemaDefinitions returns the statements of the schema objects by name
+func schemaDefinitions(t *testing.T, db *SQLiteDB) string {
+	t.Helper()
+	rows, err := db.ExecuteQuery("SELECT type, name, sql FROM sqlite_master WHERE sql IS NOT NULL ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(rows)
+	return string(encoded)
+}
+
+func everyTable(string) bool { return true }
+
+func noColumn(string) bool { return false }
+
+func TestJSONExportRoundTrip(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE items (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL, price REAL, qty, data BLOB)",
+		"CREATE INDEX idx_items_name ON items (name)",
+		"CREATE TABLE item_log (item_id INTEGER, at TEXT)",
+		"CREATE VIEW cheap AS SELECT name FROM items WHERE price < 5",
+		"CREATE TRIGGER items_log AFTER INSERT ON items BEGIN INSERT INTO item_log VALUES (NEW.id, 'now'); END",
+		"INSERT INTO items (name, price, qty, data) VALUES ('pen', 1.5, 10, x'00ff10')",
+		"INSERT INTO items (name, price, qty, data) VALUES ('ink', 3.0, 'many', NULL)",
+		"INSERT INTO items (name, price, qty, data) VALUES ('pad \"quoted\"\n', NULL, 2.5, x'')",
+		"INSERT INTO items (name, price, qty, data) VALUES ('big', 1e300, 9223372036854775807, zeroblob(3))",
+		"DELETE FROM items WHERE name = 'big'",
+	)
+	dir := t.TempDir()
+	exported := filepath.Join(dir, "export.json")
+
+	export, err := db.ExportJSON(exported, everyTable, noColumn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int64)
+	for _, table := range export.Tables {
+		counts[table.Name] = table.Rows
+	}
+	if counts["items"] != 3 || counts["item_log"] != 4 {
+		t.Fatalf("exported %v", export.Tables)
+	}
+	document, err := os.ReadFile(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed map[string]interface{}
+	if err := json.Unmarshal(document, &parsed); err != nil {
+		t.Fatalf("the export is not valid JSON: %v", err)
+	}
+	if parsed["format"] != JSONExportFormat || !strings.Contains(string(document), `{"base64":"AP8Q"}`) {
+		t.Fatalf("unexpected document %s", document)
+	}
+
+	imported := filepath.Join(dir, "imported.db")
+	result, err := ImportJSON(exported, imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Failed) != 0 {
+		t.Fatalf("failed objects %v", result.Failed)
+	}
+	copied, err := NewSQLiteDB(imported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer copied.Close()
+
+	if got, want := schemaDefinitions(t, copied), schemaDefinitions(t, db); got != want {
+		t.Fatalf("schema differs\n got %s\nwant %s", got, want)
+	}
+	for table, columns := range map[string][]string{
+		"items":    {"id", "name", "price", "qty", "data"},
+		"item_log": {"item_id", "at"},
+	} {
+		if got, want := dumpRows(t, copied, table, columns...), dumpRows(t, db, table, columns...); got != want {
+			t.Fatalf("%s rows differ\n got %s\nwant %s", table, got, want)
+		}
+	}
+	// The trigger did not fire on the imported rows, and AUTOINCREMENT goes on from the deleted row
+	if _, err := copied.ExecuteStatement("INSERT INTO items (name) VALUES ('new')"); err != nil {
+		t.Fatal(err)
+	}
+	if id := queryInt(t, copied, "SELECT id FROM items WHERE name = 'new'"); id != 5 {
+		t.Fatalf("new row got id %d, want 5", id)
+	}
+	if n := queryInt(t, copied, "SELECT count(*) FROM item_log"); n != 5 {
+		t.Fatalf("item_log has %d rows, want 5", n)
+	}
+
+	if _, err := ImportJSON(exported, imported); err == nil {
+		t.Fatal("overwrote an existing database")
+	}
+	if _, err := db.ExportJSON(exported, everyTable, noColumn); err == nil {
+		t.Fatal("overwrote an existing export")
+	}
+}
+
+func TestJSONExportFilters(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)",
+		"CREATE TABLE secrets (v TEXT)",
+		"CREATE INDEX idx_secrets ON secrets (v)",
+		"INSERT INTO users VALUES (1, 'a@example.com'), (2, NULL)",
+		"INSERT INTO secrets VALUES ('s')",
+	)
+	path := filepath.Join(t.TempDir(), "export.json")
+	export, err := db.ExportJSON(path,
+		func(table string) bool { return table != "secrets" },
+		func(column string) bool { return column == "email" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(export.Tables) != 1 || export.Tables[0].Name != "users" {
+		t.Fatalf("exported %v", export.Tables)
+	}
+	document, _ := os.ReadFile(path)
+	text := string(document)
+	if strings.Contains(text, "secrets") || strings.Contains(text, "a@example.com") || !strings.Contains(text, `[1,"***"]`) || !strings.Contains(text, `[2,null]`) {
+		t.Fatalf("unexpected document %s", text)
+	}
+}
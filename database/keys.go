@@ -0,0 +1,154 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CandidateKey is a set of columns whose values identify a row of a table
+type CandidateKey struct {
+	Columns []string `json:"columns"`
+	// Source is "primary_key", "unique_constraint" for a UNIQUE clause of CREATE TABLE, or
+	// "unique_index" for CREATE UNIQUE INDEX
+	Source string `json:"source"`
+	Index  string `json:"index,omitempty"`
+	// Nullable lists key columns without NOT NULL; rows whose key contains NULL never
+	// conflict, so such a key does not stop duplicates
+	Nullable []string `json:"nullable,omitempty"`
+	// Partial keys are only unique among the rows matching the index's WHERE clause
+	Partial bool `json:"partial,omitempty"`
+}
+
+// TableKeys reports the keys of a table, for choosing an upsert conflict target or
+// deduplicating rows
+type TableKeys struct {
+	Table      string   `json:"table"`
+	PrimaryKey []string `json:"primary_key,omitempty"`
+	// RowidAlias is the INTEGER PRIMARY KEY column, a surrogate key assigned by SQLite
+	RowidAlias string `json:"rowid_alias,omitempty"`
+	// CandidateKeys lists the primary key and every unique constraint and unique index
+	CandidateKeys []CandidateKey `json:"candidate_keys"`
+	// NaturalKey is the first candidate key made of data columns rather than the rowid
+	// alias, preferring keys without nullable columns; empty when there is none
+	NaturalKey []string `json:"natural_key,omitempty"`
+	// UpsertKey is the conflict target to use with INSERT ... ON CONFLICT: the natural key,
+	// else the primary key; empty when the table has no usable key
+	UpsertKey    []string `json:"upsert_key,omitempty"`
+	UpsertClause string   `json:"upsert_clause,omitempty"`
+}
+
+// DetectKeys reports a table's declared primary key, its candidate keys from unique
+// constraints and unique indexes, and the natural key, if any, to use for upserts and
+// deduplication. Unique indexes on expressions are left out, since they cannot be named as
+// columns.
+func (s *SQLiteDB) DetectKeys(tableName string) (*TableKeys, error) {
+	rowid, err := s.GetRowidColumn(tableName)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := s.GetTableSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	notNull := make(map[string]bool)
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		notNull[strings.ToLower(name)] = toInt64(col["notnull"]) != 0
+	}
+
+	keys := &TableKeys{Table: tableName, PrimaryKey: rowid.PrimaryKey, RowidAlias: rowid.AliasColumn, CandidateKeys: []CandidateKey{}}
+	if rowid.AliasColumn != "" || rowid.WithoutRowid {
+		// The primary key of a rowid table can hold NULL unless declared NOT NULL, except for
+		// the rowid alias; WITHOUT ROWID tables enforce NOT NULL on it
+		for _, col := range rowid.PrimaryKey {
+			notNull[strings.ToLower(col)] = true
+		}
+	}
+	nullable := func(cols []string) []string {
+		var result []string
+		for _, col := range cols {
+			if !notNull[strings.ToLower(col)] {
+				result = append(result, col)
+			}
+		}
+		return result
+	}
+	seen := make(map[string]bool)
+	add := func(key CandidateKey) {
+		id := fmt.Sprintf("%t\x00%s", key.Partial, strings.ToLower(strings.Join(key.Columns, "\x00")))
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		key.Nullable = nullable(key.Columns)
+		keys.CandidateKeys = append(keys.CandidateKeys, key)
+	}
+	if len(rowid.PrimaryKey) > 0 {
+		add(CandidateKey{Columns: rowid.PrimaryKey, Source: "primary_key"})
+	}
+
+	indexList, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_list(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	// index_list returns the newest index first
+	for i := len(indexList) - 1; i >= 0; i-- {
+		index := indexList[i]
+		origin, _ := index["origin"].(string)
+		if toInt64(index["unique"]) != 1 || origin == "pk" {
+			continue
+		}
+		indexName, _ := index["name"].(string)
+		indexInfo, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_info(%s)", quoteIdentifier(indexName)))
+		if err != nil {
+			return nil, err
+		}
+		key := CandidateKey{Source: "unique_index", Index: indexName, Partial: toInt64(index["partial"]) != 0}
+		if origin == "u" {
+			key.Source = "unique_constraint"
+		}
+		for _, col := range indexInfo {
+			name, ok := col["name"].(string)
+			if !ok {
+				key.Columns = nil
+				break
+			}
+			key.Columns = append(key.Columns, name)
+		}
+		if len(key.Columns) > 0 {
+			add(key)
+		}
+	}
+
+	// The natural key: not the surrogate rowid alias, usable as a conflict target, and
+	// preferably free of NULLs
+	var fallback []string
+	for _, key := range keys.CandidateKeys {
+		if key.Partial || (len(key.Columns) == 1 && key.Columns[0] == rowid.AliasColumn) {
+			continue
+		}
+		if len(key.Nullable) == 0 {
+			keys.NaturalKey = key.Columns
+			break
+		}
+		if fallback == nil {
+			fallback = key.Columns
+		}
+	}
+	if keys.NaturalKey == nil {
+		keys.NaturalKey = fallback
+	}
+
+	keys.UpsertKey = keys.NaturalKey
+	if keys.UpsertKey == nil {
+		keys.UpsertKey = keys.PrimaryKey
+	}
+	if len(keys.UpsertKey) > 0 {
+		quoted := make([]string, len(keys.UpsertKey))
+		for i, col := range keys.UpsertKey {
+			quoted[i] = quoteIdentifier(col)
+		}
+		keys.UpsertClause = fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET ...", strings.Join(quoted, ", "))
+	}
+	return keys, nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetectKeysCompositeUnique(t *testing.T) {
+	db := newTestDB(t, `CREATE TABLE enrollments (
+		id INTEGER PRIMARY KEY,
+		student TEXT NOT NULL,
+		course TEXT NOT NULL,
+		term TEXT,
+		UNIQUE (student, course)
+	)`, "CREATE UNIQUE INDEX idx_term ON enrollments (student, term)")
+
+	keys, err := db.DetectKeys("enrollments")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys.RowidAlias != "id" || !reflect.DeepEqual(keys.PrimaryKey, []string{"id"}) {
+		t.Fatalf("got primary key %v with rowid alias %q", keys.PrimaryKey, keys.RowidAlias)
+	}
+	want := []CandidateKey{
+		{Columns: []string{"id"}, Source: "primary_key"},
+		{Columns: []string{"student", "course"}, Source: "unique_constraint", Index: "sqlite_autoindex_enrollments_1"},
+		{Columns: []string{"student", "term"}, Source: "unique_index", Index: "idx_term", Nullable: []string{"term"}},
+	}
+	if !reflect.DeepEqual(keys.CandidateKeys, want) {
+		t.Fatalf("got candidate keys %+v, want %+v", keys.CandidateKeys, want)
+	}
+	// The composite key beats the surrogate id and the key with a nullable column
+	if !reflect.DeepEqual(keys.NaturalKey, []string{"student", "course"}) {
+		t.Fatalf("got natural key %v", keys.NaturalKey)
+	}
+	if keys.UpsertClause != `ON CONFLICT ("student", "course") DO UPDATE SET ...` {
+		t.Fatalf("got upsert clause %q", keys.UpsertClause)
+	}
+}
+
+func TestDetectKeysFallbacks(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE tags (id INTEGER PRIMARY KEY, label TEXT UNIQUE)",
+		"CREATE TABLE events (id INTEGER PRIMARY KEY, kind TEXT)",
+		"CREATE UNIQUE INDEX idx_open ON events (kind) WHERE id > 0",
+		"CREATE TABLE loose (a, b)",
+	)
+
+	// A nullable key is still better than none
+	keys, err := db.DetectKeys("tags")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(keys.NaturalKey, []string{"label"}) {
+		t.Fatalf("tags: got natural key %v", keys.NaturalKey)
+	}
+
+	// A partial index is no natural key, so upserts fall back to the primary key
+	if keys, err = db.DetectKeys("events"); err != nil {
+		t.Fatal(err)
+	}
+	if keys.NaturalKey != nil || !reflect.DeepEqual(keys.UpsertKey, []string{"id"}) {
+		t.Fatalf("events: got natural key %v and upsert key %v", keys.NaturalKey, keys.UpsertKey)
+	}
+	if len(keys.CandidateKeys) != 2 || !keys.CandidateKeys[1].Partial {
+		t.Fatalf("events: got candidate keys %+v", keys.CandidateKeys)
+	}
+
+	if keys, err = db.DetectKeys("loose"); err != nil {
+		t.Fatal(err)
+	}
+	if len(keys.CandidateKeys) != 0 || keys.UpsertKey != nil || keys.UpsertClause != "" {
+		t.Fatalf("loose: got %+v", keys)
+	}
+}
@@ -0,0 +1,76 @@
+package database
+
+import (
+	"testing"
+)
+
+func TestLegacyMethodsQuoteNames(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE keep (x)")
+	table := `order "items"; DROP TABLE keep`
+
+	err := db.CreateTable(table, []map[string]string{
+		{"name": "group", "type": "TEXT", "constraints": "NOT NULL"},
+		{"name": "unit price", "type": "REAL"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !hasTable(t, db, table) || !hasTable(t, db, "keep") {
+		t.Fatal("table name not taken literally")
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM pragma_table_info(?) WHERE name IN ('group', 'unit price')", table); n != 2 {
+		t.Fatalf("got %d of the quoted columns", n)
+	}
+
+	if err := db.CreateIndex("by group", table, []string{"group"}, true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.CreateIndex("by group", table, []string{"group"}, true, true); err != nil {
+		t.Fatalf("IF NOT EXISTS: %v", err)
+	}
+	err = db.CreateIndexWithOptions(IndexOptions{
+		IndexName:   "by price",
+		TableName:   table,
+		Columns:     []IndexColumn{{Name: "unit price", SortOrder: "desc"}, {Name: "group"}},
+		WhereClause: `"unit price" > 0`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name IN ('by group', 'by price')", table); n != 2 {
+		t.Fatalf("got %d of the quoted indexes", n)
+	}
+
+	if err := db.DropTable(table); err != nil {
+		t.Fatal(err)
+	}
+	if hasTable(t, db, table) || !hasTable(t, db, "keep") {
+		t.Fatal("drop did not remove exactly the named table")
+	}
+}
+
+func TestLegacyMethodsRejectNames(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (a)")
+	column := []map[string]string{{"name": "a", "type": "TEXT"}}
+
+	for name, err := range map[string]error{
+		"table with line break":  db.CreateTable("bad\nname", column),
+		"column with NUL":        db.CreateTable("ok", []map[string]string{{"name": "a\x00b", "type": "TEXT"}}),
+		"drop with control char": db.DropTable("t\x01"),
+		"index name":             db.CreateIndex("idx\r", "t", []string{"a"}, false, false),
+		"index column":           db.CreateIndex("idx", "t", []string{"a\tb"}, false, false),
+		"options index name":     db.CreateIndexWithOptions(IndexOptions{IndexName: "i\n", TableName: "t", Columns: []IndexColumn{{Name: "a"}}}),
+		"sort order":             db.CreateIndexWithOptions(IndexOptions{IndexName: "i", TableName: "t", Columns: []IndexColumn{{Name: "a", SortOrder: "DESC; DROP TABLE t"}}}),
+		"add column":             db.AddColumn("t", "b\nc", "TEXT", ""),
+	} {
+		if err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+	if hasTable(t, db, "ok") || !hasTable(t, db, "t") {
+		t.Fatal("a rejected call changed the schema")
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM sqlite_master WHERE type = 'index'"); n != 0 {
+		t.Fatalf("got %d indexes from rejected calls", n)
+	}
+}
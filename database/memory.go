@@ -0,0 +1,46 @@
+package database
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrResultTooLarge is returned when the values read by a query exceed the result memory budget
+var ErrResultTooLarge = errors.New("result exceeded memory budget")
+
+// cellOverhead approximates the memory a result row map spends per column besides the value
+const cellOverhead = 16
+
+// SetMaxResultBytes sets the approximate memory budget, in bytes, of the rows a query may
+// read into memory. A query whose values grow past it is aborted with ErrResultTooLarge,
+// which protects against a few very wide rows or huge BLOBs that a row or cell count limit
+// lets through. Zero disables the budget.
+func (s *SQLiteDB) SetMaxResultBytes(limit int64) {
+	s.settingsMu.Lock()
+	s.maxResultBytes = limit
+	s.settingsMu.Unlock()
+}
+
+// resultBudget returns the current result memory budget, 0 for none
+func (s *SQLiteDB) resultBudget() int64 {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+	return s.maxResultBytes
+}
+
+// valueSize approximates the bytes a scanned value occupies in a result row
+func valueSize(column string, value interface{}) int64 {
+	size := int64(len(column) + cellOverhead)
+	switch v := value.(type) {
+	case []byte:
+		size += int64(len(v))
+	case string:
+		size += int64(len(v))
+	case time.Time:
+		size += 24
+	case nil:
+	default:
+		size += 8
+	}
+	return size
+}
@@ -0,0 +1,64 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestResultMemoryBudget(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE files (id INTEGER PRIMARY KEY, body BLOB)",
+		"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 10) INSERT INTO files SELECT i, zeroblob(100000) FROM n",
+	)
+
+	// Each row is about 100 KB, so the budget is crossed while reading the fourth
+	db.SetMaxResultBytes(350000)
+	_, err := db.ExecuteQuery("SELECT id, body FROM files ORDER BY id")
+	if !errors.Is(err, ErrResultTooLarge) {
+		t.Fatalf("got %v, want ErrResultTooLarge", err)
+	}
+	if !strings.Contains(err.Error(), "of 350000 bytes after reading 3 complete rows") {
+		t.Fatalf("error lacks the partial result notice: %v", err)
+	}
+
+	// Narrower results fit
+	rows, err := db.ExecuteQuery("SELECT id, length(body) AS size FROM files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 10 {
+		t.Fatalf("got %d rows", len(rows))
+	}
+	if rows, err = db.ExecuteQuery("SELECT id, body FROM files WHERE id <= 3"); err != nil || len(rows) != 3 {
+		t.Fatalf("three rows under the budget: %d rows, %v", len(rows), err)
+	}
+
+	// One row larger than the whole budget is refused too
+	db.SetMaxResultBytes(1000)
+	if _, err := db.ExecuteQuery("SELECT body FROM files WHERE id = 1"); !errors.Is(err, ErrResultTooLarge) {
+		t.Fatalf("got %v for a single wide row", err)
+	}
+
+	db.SetMaxResultBytes(0)
+	if rows, err = db.ExecuteQuery("SELECT id, body FROM files"); err != nil || len(rows) != 10 {
+		t.Fatalf("without a budget: %d rows, %v", len(rows), err)
+	}
+}
+
+func TestValueSize(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  int64
+	}{
+		{nil, 1 + cellOverhead},
+		{int64(5), 1 + cellOverhead + 8},
+		{"hello", 1 + cellOverhead + 5},
+		{[]byte{1, 2, 3}, 1 + cellOverhead + 3},
+	}
+	for _, test := range tests {
+		if got := valueSize("c", test.value); got != test.want {
+			t.Errorf("valueSize(%#v) = %d, want %d", test.value, got, test.want)
+		}
+	}
+}
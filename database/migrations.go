@@ -0,0 +1,171 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// migrationFilePattern matches migration file names: a version number, optionally followed by
+// a separator and a description, with the .sql extension, e.g. 001_init.sql
+var migrationFilePattern = regexp.MustCompile(`(?i)^(\d+)(?:[_\-. ].*)?\.sql$`)
+
+// Migration is one schema migration file
+type Migration struct {
+	Version int64  `json:"version"`
+	File    string `json:"file"`
+	path    string
+}
+
+// MigrationStatus compares the migration files of a directory with the database's
+// user_version: migrations up to that version count as applied
+type MigrationStatus struct {
+	Directory      string      `json:"directory"`
+	CurrentVersion int64       `json:"current_version"`
+	LatestVersion  int64       `json:"latest_version"`
+	Applied        []Migration `json:"applied"`
+	Pending        []Migration `json:"pending"`
+	// Ignored lists .sql files without a version number prefix
+	Ignored []string `json:"ignored,omitempty"`
+}
+
+// FailedMigration is the migration ApplyMigrations stopped at
+type FailedMigration struct {
+	Migration
+	Error string `json:"error"`
+}
+
+// MigrationRun reports the outcome of ApplyMigrations
+type MigrationRun struct {
+	PreviousVersion int64            `json:"previous_version"`
+	CurrentVersion  int64            `json:"current_version"`
+	Applied         []Migration      `json:"applied"`
+	Failed          *FailedMigration `json:"failed,omitempty"`
+	// Pending lists the migrations left to apply after a failure, the failed one first
+	Pending []Migration `json:"pending,omitempty"`
+}
+
+// ListMigrations returns the migration files of a directory ordered by version, and the .sql
+// files it ignored for lacking a version prefix. Versions must be unique, positive, and fit
+// user_version, a 32-bit integer.
+func ListMigrations(directory string) ([]Migration, []string, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read migration directory: %w", err)
+	}
+
+	var migrations []Migration
+	var ignored []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.EqualFold(filepath.Ext(name), ".sql") {
+			continue
+		}
+		match := migrationFilePattern.FindStringSubmatch(name)
+		if match == nil {
+			ignored = append(ignored, name)
+			continue
+		}
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil || version < 1 || version > math.MaxInt32 {
+			return nil, nil, fmt.Errorf("migration '%s' must have a version from 1 to %d", name, math.MaxInt32)
+		}
+		migrations = append(migrations, Migration{Version: version, File: name, path: filepath.Join(directory, name)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, nil, fmt.Errorf("migrations '%s' and '%s' have the same version %d",
+				migrations[i-1].File, migrations[i].File, migrations[i].Version)
+		}
+	}
+	return migrations, ignored, nil
+}
+
+// GetMigrationStatus lists which migrations of a directory are applied and which are pending,
+// going by PRAGMA user_version
+func (s *SQLiteDB) GetMigrationStatus(directory string) (*MigrationStatus, error) {
+	migrations, ignored, err := ListMigrations(directory)
+	if err != nil {
+		return nil, err
+	}
+	current, err := s.GetUserVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Directory: directory, CurrentVersion: current, Applied: []Migration{}, Pending: []Migration{}, Ignored: ignored}
+	for _, m := range migrations {
+		if m.Version <= current {
+			status.Applied = append(status.Applied, m)
+		} else {
+			status.Pending = append(status.Pending, m)
+		}
+		status.LatestVersion = m.Version
+	}
+	return status, nil
+}
+
+// ApplyMigrations applies, in version order, the migrations of a directory whose version
+// exceeds PRAGMA user_version. Each migration runs in a transaction of its own that also sets
+// user_version to the migration's version, so a migration is either applied and recorded or
+// not at all. ApplyMigrations stops at the first migration that fails and reports it along
+// with those applied before it; the error return is for failures before any migration ran.
+// Migration files must not begin or commit transactions themselves.
+func (s *SQLiteDB) ApplyMigrations(directory string) (*MigrationRun, error) {
+	status, err := s.GetMigrationStatus(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	run := &MigrationRun{PreviousVersion: status.CurrentVersion, CurrentVersion: status.CurrentVersion, Applied: []Migration{}}
+	for i, m := range status.Pending {
+		if err := s.applyMigration(m); err != nil {
+			run.Failed = &FailedMigration{Migration: m, Error: err.Error()}
+			run.Pending = status.Pending[i:]
+			break
+		}
+		run.Applied = append(run.Applied, m)
+		run.CurrentVersion = m.Version
+	}
+	return run, nil
+}
+
+// applyMigration runs one migration file and records its version in the same transaction
+func (s *SQLiteDB) applyMigration(m Migration) error {
+	script, err := os.ReadFile(m.path)
+	if err != nil {
+		return fmt.Errorf("failed to read migration: %w", err)
+	}
+	for _, statement := range SplitStatements(string(script)) {
+		tokens := tokenizeSQL(statement)
+		if len(tokens) == 0 || tokens[0].kind != 'i' || tokens[0].quoted {
+			continue
+		}
+		keyword := strings.ToUpper(tokens[0].text)
+		switch keyword {
+		case "BEGIN", "COMMIT", "END", "ROLLBACK":
+			// ROLLBACK TO undoes to a savepoint, which a migration may use
+			if keyword == "ROLLBACK" && len(tokens) > 1 && strings.EqualFold(tokens[1].text, "TO") {
+				continue
+			}
+			return fmt.Errorf("the migration must not contain %s; every migration already runs in a transaction of its own", keyword)
+		}
+	}
+
+	return s.RetryTransaction(func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(s.ctx(), string(script)); err != nil {
+			return err
+		}
+		// PRAGMA arguments cannot be bound parameters
+		_, err := tx.ExecContext(s.ctx(), fmt.Sprintf("PRAGMA user_version = %d", m.Version))
+		return err
+	})
+}
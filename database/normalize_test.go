@@ -0,0 +1,64 @@
+package database
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNormalizeValue(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		affinity string
+		want     interface{}
+	}{
+		{"42", "INTEGER", int64(42)},
+		{" -7 ", "INTEGER", int64(-7)},
+		{"2.5", "INTEGER", 2.5},
+		{"1e3", "NUMERIC", 1000.0},
+		{".5", "NUMERIC", 0.5},
+		{"1e999", "NUMERIC", "1e999"},
+		{"12abc", "INTEGER", "12abc"},
+		{"0x10", "INTEGER", "0x10"},
+		{int64(3), "REAL", 3.0},
+		{"3", "REAL", 3.0},
+		{"three", "REAL", "three"},
+		{int64(3), "INTEGER", int64(3)},
+		{"42", "TEXT", "42"},
+		{"42", "", "42"},
+		{int64(3), "", int64(3)},
+		{nil, "INTEGER", nil},
+	}
+	for _, tt := range tests {
+		got := normalizeValue(tt.value, tt.affinity)
+		if fmt.Sprintf("%T %v", got, got) != fmt.Sprintf("%T %v", tt.want, tt.want) {
+			t.Errorf("%#v as %s: got %T %v, want %T %v", tt.value, tt.affinity, got, got, tt.want, tt.want)
+		}
+	}
+}
+
+func TestQueryValueTypesByAffinity(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE v (i INTEGER, r DOUBLE, n DECIMAL(10,2), t VARCHAR(10), b BLOB, x)",
+		"INSERT INTO v VALUES ('42', 3, '1.5', 42, x'00ff', '42')",
+	)
+
+	rows, err := db.ExecuteQuery("SELECT i, r, n, t, b, x, r + 0 AS expr FROM v")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"i": "int64 42",
+		"r": "float64 3",
+		"n": "float64 1.5",
+		"t": "string 42",
+		"b": "database.Blob [0 255]",
+		// Columns without a declared type keep the type SQLite stored
+		"x":    "string 42",
+		"expr": "float64 3",
+	}
+	for col, shape := range want {
+		if got := fmt.Sprintf("%T %v", rows[0][col], rows[0][col]); got != shape {
+			t.Errorf("%s: got %s, want %s", col, got, shape)
+		}
+	}
+}
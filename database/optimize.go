@@ -0,0 +1,131 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// autoVacuumModes maps PRAGMA auto_vacuum values to their names
+var autoVacuumModes = []string{"NONE", "FULL", "INCREMENTAL"}
+
+// IncrementalVacuumResult reports what IncrementalVacuum freed
+type IncrementalVacuumResult struct {
+	// PreviousMode is the auto_vacuum mode before the call: NONE, FULL, or INCREMENTAL
+	PreviousMode string `json:"previous_mode"`
+	// Converted is true when the database was rebuilt with VACUUM to enable incremental vacuum
+	Converted       bool  `json:"converted"`
+	FreePagesBefore int64 `json:"free_pages_before"`
+	FreePagesAfter  int64 `json:"free_pages_after"`
+	FreedPages      int64 `json:"freed_pages"`
+	FreedBytes      int64 `json:"freed_bytes"`
+}
+
+// Optimize runs PRAGMA optimize, which analyzes the tables whose statistics are missing or
+// out of date and is cheap when there is nothing to do. Every table is considered, not only
+// those the connection has queried. It returns the statements SQLite chose to run.
+func (s *SQLiteDB) Optimize() ([]string, error) {
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	// 0x10000 considers every table; 0x01 only lists the work, which 0x02 then does
+	rows, err := conn.QueryContext(ctx, "PRAGMA optimize(0x10003)")
+	if err != nil {
+		return nil, err
+	}
+	statements := []string{}
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		statements = append(statements, strings.TrimSuffix(strings.TrimSpace(statement), ";"))
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA optimize(0x10002)"); err != nil {
+		return nil, s.diskFullError(err)
+	}
+	return statements, nil
+}
+
+// IncrementalVacuum returns up to pages free pages to the file system with PRAGMA
+// incremental_vacuum, all of them when pages is not positive. Unlike VACUUM it only moves
+// pages from the end of the file, so it is quick and does not rewrite the database. It needs
+// auto_vacuum=INCREMENTAL: a database in FULL mode is switched over directly, while one in
+// NONE mode must be rebuilt once with VACUUM, which only happens with convert.
+func (s *SQLiteDB) IncrementalVacuum(pages int64, convert bool) (*IncrementalVacuumResult, error) {
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	var mode int
+	if err := conn.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&mode); err != nil {
+		return nil, err
+	}
+	result := &IncrementalVacuumResult{PreviousMode: "UNKNOWN"}
+	if mode >= 0 && mode < len(autoVacuumModes) {
+		result.PreviousMode = autoVacuumModes[mode]
+	}
+
+	switch result.PreviousMode {
+	case "INCREMENTAL":
+	case "NONE":
+		if !convert {
+			return nil, fmt.Errorf("auto_vacuum is NONE; enabling incremental vacuum rebuilds the database once with a full VACUUM, pass convert to do so")
+		}
+		if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return nil, err
+		}
+		if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+			return nil, s.diskFullError(err)
+		}
+		result.Converted = true
+	default:
+		// Switching between FULL and INCREMENTAL takes effect without a rebuild
+		if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+			return nil, err
+		}
+	}
+
+	var pageSize int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return nil, err
+	}
+	if err := conn.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&result.FreePagesBefore); err != nil {
+		return nil, err
+	}
+	statement := "PRAGMA incremental_vacuum"
+	if pages > 0 {
+		statement = fmt.Sprintf("PRAGMA incremental_vacuum(%d)", pages)
+	}
+	// The pragma frees one page per step, so its rows must be read to the end
+	rows, err := conn.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, s.diskFullError(err)
+	}
+	for rows.Next() {
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, s.diskFullError(err)
+	}
+	if err := conn.QueryRowContext(ctx, "PRAGMA freelist_count").Scan(&result.FreePagesAfter); err != nil {
+		return nil, err
+	}
+	result.FreedPages = result.FreePagesBefore - result.FreePagesAfter
+	result.FreedBytes = result.FreedPages * pageSize
+	return result, nil
+}
@@ -0,0 +1,69 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetPageSizeInWALMode(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE t (id INTEGER PRIMARY KEY, value TEXT)",
+		"INSERT INTO t (value) SELECT hex(randomblob(100)) FROM (SELECT 1 UNION ALL SELECT 2 UNION ALL SELECT 3)",
+	)
+	var mode string
+	db.db.QueryRow("PRAGMA journal_mode").Scan(&mode)
+	if !strings.EqualFold(mode, "wal") {
+		t.Fatalf("a new database is in %s mode, want WAL", mode)
+	}
+
+	result, err := db.SetPageSize(8192)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PageSize != 8192 || !result.HadData || !strings.EqualFold(result.JournalMode, "wal") {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	if size := queryInt(t, db, "PRAGMA page_size"); size != 8192 {
+		t.Fatalf("page size is %d", size)
+	}
+	db.db.QueryRow("PRAGMA journal_mode").Scan(&mode)
+	if !strings.EqualFold(mode, "wal") {
+		t.Fatalf("journal mode is %s after the change, want WAL", mode)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM t"); n != 3 {
+		t.Fatalf("%d rows after VACUUM", n)
+	}
+}
+
+func TestSetPageSizeOnPopulatedDatabase(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE t (id INTEGER PRIMARY KEY, value TEXT)",
+		"INSERT INTO t (value) WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 1000) SELECT printf('%0200d', i) FROM n",
+	)
+	if _, err := db.SetJournalMode("DELETE"); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.SetPageSize(16384)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.PageSize != 16384 || !result.HadData || result.JournalMode != "" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	// The VACUUM rebuilt the file with the new page size, read on a new connection
+	for _, size := range pragmaOnEachConnection(t, db, "page_size", 2) {
+		if size != 16384 {
+			t.Fatalf("page size is %d", size)
+		}
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM t WHERE value LIKE '%1000'"); n != 1 {
+		t.Fatal("data was lost in the VACUUM")
+	}
+
+	for _, size := range []int64{256, 1000, 131072} {
+		if _, err := db.SetPageSize(size); err == nil {
+			t.Errorf("accepted page size %d", size)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package database
+
+import (
+	"strings"
+)
+
+// NamedParameters returns the names of a statement's :name, @name, and $name parameters,
+// without their prefix, in order of first appearance. Parameters inside string literals and
+// comments are not counted.
+func NamedParameters(statement string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, token := range tokenizeSQL(statement) {
+		if token.kind != 'p' || strings.HasPrefix(token.text, "?") {
+			continue
+		}
+		name := token.text[1:]
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
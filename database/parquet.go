@@ -0,0 +1,547 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+)
+
+// parquetBatchRows is how many rows ExportParquet buffers before writing them out
+const parquetBatchRows = 1000
+
+// ParquetColumn is one column of an exported Parquet file
+type ParquetColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"` // INT64, DOUBLE, STRING, or BYTE_ARRAY
+}
+
+// ParquetExport describes a Parquet file written by ExportParquet
+type ParquetExport struct {
+	Path    string          `json:"path"`
+	Rows    int64           `json:"rows"`
+	Columns []ParquetColumn `json:"columns"`
+}
+
+// parquetRecord is the root of a Parquet schema. parquet.Group orders its fields by name, so
+// the fields are kept separately in the order of the result columns.
+type parquetRecord struct {
+	parquet.Group
+	fields []parquet.Field
+}
+
+func (r parquetRecord) Fields() []parquet.Field { return r.fields }
+
+// parquetField is a named column of a parquetRecord. Rows are written as parquet.Row values,
+// never reflected from Go structs, so Value is not used.
+type parquetField struct {
+	parquet.Node
+	name string
+}
+
+func (f parquetField) Name() string { return f.name }
+
+func (f parquetField) Value(base reflect.Value) reflect.Value { return reflect.Value{} }
+
+// parquetTypes maps a column affinity to the Parquet type its values are written as
+var parquetTypes = map[string]string{
+	"INTEGER": "INT64",
+	"REAL":    "DOUBLE",
+	"TEXT":    "STRING",
+	"BLOB":    "BYTE_ARRAY",
+}
+
+// ExportParquet writes the results of a SELECT to a new Parquet file at path. Every column is
+// optional so NULLs are kept. Column types follow the values: integer columns become INT64,
+// columns with any real value DOUBLE, blobs BYTE_ARRAY, and mixed columns STRING; columns that
+// are NULL in every row use their declared type. masked maps result column names to a value
+// written in place of every non-NULL value of that column. The query is read twice inside
+// one read transaction, first to type the columns and then to write the rows.
+func (s *SQLiteDB) ExportParquet(path string, masked map[string]string, query string, args ...interface{}) (*ParquetExport, error) {
+	if !IsSingleStatement(query) {
+		return nil, fmt.Errorf("the query must be a single SELECT statement")
+	}
+	query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+
+	tx, err := s.db.BeginTx(s.ctx(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	columns, err := parquetColumns(s.ctx(), tx, masked, query, args)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file: %w", err)
+	}
+	count, err := writeParquet(s.ctx(), file, tx, columns, masked, query, args)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+
+	return &ParquetExport{Path: path, Rows: count, Columns: columns}, nil
+}
+
+// parquetColumns runs the query once to choose the Parquet type of each result column
+func parquetColumns(ctx context.Context, tx *sql.Tx, masked map[string]string, query string, args []interface{}) ([]ParquetColumn, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %w", err)
+	}
+
+	affinities := make([]string, len(columnTypes))
+	values := make([]interface{}, len(columnTypes))
+	pointers := make([]interface{}, len(columnTypes))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		for i, value := range values {
+			affinities[i] = mergeAffinity(affinities[i], valueAffinity(value))
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Parquet column names must be unique, result column names need not be
+	taken := make(map[string]bool)
+	columns := make([]ParquetColumn, len(columnTypes))
+	for i, ct := range columnTypes {
+		name := ct.Name()
+		for n := 2; taken[name]; n++ {
+			name = fmt.Sprintf("%s_%d", ct.Name(), n)
+		}
+		taken[name] = true
+
+		affinity := affinities[i]
+		if _, ok := masked[ct.Name()]; ok {
+			affinity = "TEXT"
+		} else if affinity == "" && ct.DatabaseTypeName() != "" {
+			affinity = columnAffinity(ct.DatabaseTypeName())
+			if affinity == "NUMERIC" {
+				affinity = "REAL"
+			}
+		}
+		if affinity == "" {
+			affinity = "TEXT"
+		}
+		columns[i] = ParquetColumn{Name: name, Type: parquetTypes[affinity]}
+	}
+	return columns, nil
+}
+
+// writeParquet runs the query again and writes its rows to file with the given columns
+func writeParquet(ctx context.Context, file *os.File, tx *sql.Tx, columns []ParquetColumn, masked map[string]string, query string, args []interface{}) (int64, error) {
+	record := parquetRecord{Group: parquet.Group{}}
+	for _, col := range columns {
+		var node parquet.Node
+		switch col.Type {
+		case "INT64":
+			node = parquet.Leaf(parquet.Int64Type)
+		case "DOUBLE":
+			node = parquet.Leaf(parquet.DoubleType)
+		case "BYTE_ARRAY":
+			node = parquet.Leaf(parquet.ByteArrayType)
+		default:
+			node = parquet.String()
+		}
+		node = parquet.Optional(node)
+		record.Group[col.Name] = node
+		record.fields = append(record.fields, parquetField{Node: node, name: col.Name})
+	}
+	writer := parquet.NewWriter(file, parquet.NewSchema("query", record))
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("query failed: %w", err)
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	values := make([]interface{}, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+
+	var count int64
+	batch := make([]parquet.Row, 0, parquetBatchRows)
+	for rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			return 0, err
+		}
+		row := make(parquet.Row, len(columns))
+		for i, value := range values {
+			if mask, ok := masked[names[i]]; ok && value != nil {
+				value = mask
+			}
+			row[i] = parquetValue(value, columns[i].Type).Level(0, 0, i)
+			if value != nil {
+				row[i] = row[i].Level(0, 1, i)
+			}
+		}
+		batch = append(batch, row)
+		count++
+
+		if len(batch) == parquetBatchRows {
+			if _, err := writer.WriteRows(batch); err != nil {
+				return 0, fmt.Errorf("failed to write rows: %w", err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	if _, err := writer.WriteRows(batch); err != nil {
+		return 0, fmt.Errorf("failed to write rows: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return 0, fmt.Errorf("failed to finish file: %w", err)
+	}
+	return count, nil
+}
+
+// parquetValue converts a scanned value to the Parquet type of its column
+func parquetValue(value interface{}, parquetType string) parquet.Value {
+	if value == nil {
+		return parquet.NullValue()
+	}
+	switch parquetType {
+	case "INT64":
+		switch v := value.(type) {
+		case int64:
+			return parquet.Int64Value(v)
+		case bool:
+			if v {
+				return parquet.Int64Value(1)
+			}
+			return parquet.Int64Value(0)
+		}
+	case "DOUBLE":
+		switch v := value.(type) {
+		case float64:
+			return parquet.DoubleValue(v)
+		case int64:
+			return parquet.DoubleValue(float64(v))
+		case bool:
+			if v {
+				return parquet.DoubleValue(1)
+			}
+			return parquet.DoubleValue(0)
+		}
+	case "BYTE_ARRAY":
+		if v, ok := value.([]byte); ok {
+			return parquet.ByteArrayValue(v)
+		}
+	}
+
+	// STRING, and any value that does not fit its column's type
+	switch v := value.(type) {
+	case []byte:
+		return parquet.ByteArrayValue(v)
+	case string:
+		return parquet.ByteArrayValue([]byte(v))
+	case float64:
+		return parquet.ByteArrayValue([]byte(strconv.FormatFloat(v, 'g', -1, 64)))
+	case time.Time:
+		return parquet.ByteArrayValue([]byte(v.Format(time.RFC3339Nano)))
+	default:
+		return parquet.ByteArrayValue([]byte(fmt.Sprint(v)))
+	}
+}
+
+// DefaultImportBatchSize is the number of rows ImportParquet inserts per transaction
+const DefaultImportBatchSize = 1000
+
+// ImportedColumn is one column of a Parquet file read by ImportParquet
+type ImportedColumn struct {
+	Name        string `json:"name"`
+	ParquetType string `json:"parquet_type"`
+	SQLiteType  string `json:"sqlite_type"`
+}
+
+// ParquetImport describes the rows ImportParquet loaded into a table
+type ParquetImport struct {
+	Table   string           `json:"table"`
+	Created bool             `json:"created"`
+	Rows    int64            `json:"rows"`
+	Columns []ImportedColumn `json:"columns"`
+}
+
+// julianUnixEpoch is the Julian day number of 1970-01-01, used by INT96 timestamps
+const julianUnixEpoch = 2440588
+
+// ImportParquet loads the rows of a Parquet file into a table. The table is created from the
+// file's schema when it does not exist; otherwise every Parquet column must exist in it.
+// Rows are inserted in transactions of batchSize rows, so a failure keeps the batches
+// committed before it and the returned import counts them. Only flat schemas are supported.
+func (s *SQLiteDB) ImportParquet(path, tableName string, batchSize int) (*ParquetImport, error) {
+	if tableName == "" {
+		return nil, fmt.Errorf("table name is required")
+	}
+	if strings.HasPrefix(strings.ToLower(tableName), "sqlite_") {
+		return nil, fmt.Errorf("table names beginning with 'sqlite_' are reserved")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	parquetFile, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("not a valid Parquet file: %w", err)
+	}
+
+	schema := parquetFile.Schema()
+	paths := schema.Columns()
+	result := &ParquetImport{Table: tableName, Columns: make([]ImportedColumn, len(paths))}
+	converters := make([]func(parquet.Value) interface{}, len(paths))
+	for _, columnPath := range paths {
+		leaf, _ := schema.Lookup(columnPath...)
+		if len(columnPath) != 1 || leaf.MaxRepetitionLevel > 0 {
+			return nil, fmt.Errorf("column '%s' is nested or repeated; only flat Parquet schemas can be imported", strings.Join(columnPath, "."))
+		}
+		sqliteType, convert := parquetConverter(leaf.Node)
+		result.Columns[leaf.ColumnIndex] = ImportedColumn{Name: columnPath[0], ParquetType: leaf.Node.Type().String(), SQLiteType: sqliteType}
+		converters[leaf.ColumnIndex] = convert
+	}
+	if len(result.Columns) == 0 {
+		return nil, fmt.Errorf("the Parquet file has no columns")
+	}
+
+	var exists int
+	if err := s.db.QueryRowContext(s.ctx(), "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&exists); err != nil {
+		return nil, err
+	}
+	quoted := make([]string, len(result.Columns))
+	for i, col := range result.Columns {
+		quoted[i] = quoteIdentifier(col.Name)
+	}
+	if exists == 0 {
+		defs := make([]string, len(result.Columns))
+		for i, col := range result.Columns {
+			defs[i] = quoted[i] + " " + col.SQLiteType
+		}
+		createSQL := fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdentifier(tableName), strings.Join(defs, ", "))
+		if _, err := s.db.ExecContext(s.ctx(), createSQL); err != nil {
+			return nil, fmt.Errorf("failed to create table: %w", err)
+		}
+		result.Created = true
+	} else {
+		columns, err := s.GetTableSchema(tableName)
+		if err != nil {
+			return nil, err
+		}
+		present := make(map[string]bool)
+		for _, col := range columns {
+			name, _ := col["name"].(string)
+			present[strings.ToLower(name)] = true
+		}
+		var missing []string
+		for _, col := range result.Columns {
+			if !present[strings.ToLower(col.Name)] {
+				missing = append(missing, col.Name)
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("table '%s' has no column(s) %s", tableName, strings.Join(missing, ", "))
+		}
+	}
+
+	insertSQL := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", quoteIdentifier(tableName), strings.Join(quoted, ", "),
+		strings.TrimSuffix(strings.Repeat("?, ", len(quoted)), ", "))
+
+	reader := parquet.NewReader(file)
+	defer reader.Close()
+	rows := make([]parquet.Row, batchSize)
+	for {
+		n, readErr := reader.ReadRows(rows)
+		if readErr != nil && readErr != io.EOF {
+			return result, fmt.Errorf("failed to read rows after %d imported: %w", result.Rows, readErr)
+		}
+		if n > 0 {
+			err := s.Transaction(func(tx *sql.Tx) error {
+				stmt, err := tx.PrepareContext(s.ctx(), insertSQL)
+				if err != nil {
+					return err
+				}
+				defer stmt.Close()
+
+				args := make([]interface{}, len(converters))
+				for _, row := range rows[:n] {
+					for i := range args {
+						args[i] = nil
+					}
+					for _, value := range row {
+						if column := value.Column(); column >= 0 && column < len(args) && !value.IsNull() {
+							args[column] = converters[column](value)
+						}
+					}
+					if _, err := stmt.ExecContext(s.ctx(), args...); err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return result, fmt.Errorf("failed to insert rows after %d imported: %w", result.Rows, err)
+			}
+			result.Rows += int64(n)
+		}
+		if readErr == io.EOF {
+			return result, nil
+		}
+	}
+}
+
+// parquetConverter returns the SQLite type a Parquet column is stored as and a function
+// converting its non-NULL values
+func parquetConverter(node parquet.Node) (string, func(parquet.Value) interface{}) {
+	kind := node.Type().Kind()
+	logical := node.Type().LogicalType()
+	if logical == nil {
+		logical = &format.LogicalType{}
+	}
+
+	switch {
+	case logical.Decimal != nil:
+		scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(logical.Decimal.Scale)), nil))
+		return "REAL", func(v parquet.Value) interface{} {
+			unscaled := new(big.Int)
+			switch kind {
+			case parquet.Int32:
+				unscaled.SetInt64(int64(v.Int32()))
+			case parquet.Int64:
+				unscaled.SetInt64(v.Int64())
+			default:
+				// Big-endian two's complement
+				bytes := v.ByteArray()
+				unscaled.SetBytes(bytes)
+				if len(bytes) > 0 && bytes[0]&0x80 != 0 {
+					unscaled.Sub(unscaled, new(big.Int).Lsh(big.NewInt(1), uint(len(bytes)*8)))
+				}
+			}
+			f, _ := new(big.Float).Quo(new(big.Float).SetInt(unscaled), scale).Float64()
+			return f
+		}
+	case logical.Date != nil:
+		return "TEXT", func(v parquet.Value) interface{} {
+			return time.Unix(int64(v.Int32())*86400, 0).UTC().Format("2006-01-02")
+		}
+	case logical.Timestamp != nil:
+		unit := timeUnitDuration(logical.Timestamp.Unit)
+		return "TEXT", func(v parquet.Value) interface{} {
+			return time.Unix(0, 0).Add(time.Duration(v.Int64()) * unit).UTC().Format(time.RFC3339Nano)
+		}
+	case logical.Time != nil:
+		unit := timeUnitDuration(logical.Time.Unit)
+		return "TEXT", func(v parquet.Value) interface{} {
+			value := v.Int64()
+			if kind == parquet.Int32 {
+				value = int64(v.Int32())
+			}
+			return time.Unix(0, 0).Add(time.Duration(value) * unit).UTC().Format("15:04:05.999999999")
+		}
+	case logical.UUID != nil:
+		return "TEXT", func(v parquet.Value) interface{} {
+			b := v.ByteArray()
+			if len(b) != 16 {
+				return fmt.Sprintf("%x", b)
+			}
+			return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+		}
+	case logical.UTF8 != nil, logical.Enum != nil, logical.Json != nil:
+		return "TEXT", func(v parquet.Value) interface{} { return string(v.ByteArray()) }
+	case logical.Integer != nil && !logical.Integer.IsSigned:
+		return "INTEGER", func(v parquet.Value) interface{} {
+			if kind == parquet.Int32 {
+				return int64(uint32(v.Int32()))
+			}
+			// SQLite integers are signed 64-bit; larger values are kept approximately
+			if u := uint64(v.Int64()); u > math.MaxInt64 {
+				return float64(u)
+			}
+			return v.Int64()
+		}
+	}
+
+	switch kind {
+	case parquet.Boolean:
+		return "INTEGER", func(v parquet.Value) interface{} {
+			if v.Boolean() {
+				return int64(1)
+			}
+			return int64(0)
+		}
+	case parquet.Int32:
+		return "INTEGER", func(v parquet.Value) interface{} { return int64(v.Int32()) }
+	case parquet.Int64:
+		return "INTEGER", func(v parquet.Value) interface{} { return v.Int64() }
+	case parquet.Int96:
+		// Legacy timestamps: nanoseconds within the day, then the Julian day
+		return "TEXT", func(v parquet.Value) interface{} {
+			i := v.Int96()
+			nanos := int64(uint64(i[1])<<32 | uint64(i[0]))
+			days := int64(i[2]) - julianUnixEpoch
+			return time.Unix(days*86400, nanos).UTC().Format(time.RFC3339Nano)
+		}
+	case parquet.Float:
+		return "REAL", func(v parquet.Value) interface{} { return float64(v.Float()) }
+	case parquet.Double:
+		return "REAL", func(v parquet.Value) interface{} { return v.Double() }
+	default:
+		return "BLOB", func(v parquet.Value) interface{} { return append([]byte(nil), v.ByteArray()...) }
+	}
+}
+
+// timeUnitDuration returns the length of one tick of a Parquet time unit
+func timeUnitDuration(unit format.TimeUnit) time.Duration {
+	switch {
+	case unit.Millis != nil:
+		return time.Millisecond
+	case unit.Micros != nil:
+		return time.Microsecond
+	default:
+		return time.Nanosecond
+	}
+}
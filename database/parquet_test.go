@@ -0,0 +1,203 @@
+package database
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// readParquet returns the column names, physical types, and rows of a Parquet file
+func readParquet(t *testing.T, path string) ([]string, []string, []parquet.Row) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parquetFile, err := parquet.OpenFile(file, info.Size())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names, types []string
+	for _, field := range parquetFile.Schema().Fields() {
+		names = append(names, field.Name())
+		types = append(types, field.Type().Kind().String())
+		if !field.Optional() {
+			t.Errorf("column %s is not optional", field.Name())
+		}
+	}
+	reader := parquet.NewReader(parquetFile)
+	defer reader.Close()
+	var rows []parquet.Row
+	buffer := make([]parquet.Row, 10)
+	for {
+		n, err := reader.ReadRows(buffer)
+		for _, row := range buffer[:n] {
+			rows = append(rows, row.Clone())
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return names, types, rows
+}
+
+func TestExportParquet(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT, score REAL, photo BLOB, note TEXT)",
+		"INSERT INTO people VALUES (1, 'ann', 9.5, x'0102', NULL)",
+		"INSERT INTO people VALUES (2, NULL, 7, NULL, NULL)",
+		"INSERT INTO people VALUES (3, 'cy', NULL, x'ff', NULL)",
+	)
+	path := filepath.Join(t.TempDir(), "people.parquet")
+
+	export, err := db.ExportParquet(path, map[string]string{"name": "***"}, "SELECT id, name, score, photo, note FROM people ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Rows != 3 || export.Path != path {
+		t.Fatalf("unexpected export %+v", export)
+	}
+	wantTypes := []string{"INT64", "STRING", "DOUBLE", "BYTE_ARRAY", "STRING"}
+	for i, col := range export.Columns {
+		if col.Type != wantTypes[i] {
+			t.Errorf("column %s exported as %s, want %s", col.Name, col.Type, wantTypes[i])
+		}
+	}
+
+	names, types, rows := readParquet(t, path)
+	if want := []string{"id", "name", "score", "photo", "note"}; len(names) != len(want) {
+		t.Fatalf("file has columns %v, want %v", names, want)
+	} else {
+		for i := range want {
+			if names[i] != want[i] {
+				t.Fatalf("file has columns %v, want %v", names, want)
+			}
+		}
+	}
+	if types[0] != "INT64" || types[2] != "DOUBLE" {
+		t.Fatalf("file has types %v", types)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("file has %d rows", len(rows))
+	}
+	first, second := rows[0], rows[1]
+	if first[0].Int64() != 1 || string(first[1].ByteArray()) != "***" || first[2].Double() != 9.5 ||
+		!bytes.Equal(first[3].ByteArray(), []byte{1, 2}) || !first[4].IsNull() {
+		t.Fatalf("first row read back as %v", first)
+	}
+	// NULLs stay NULL, masked or not, and the integer 7 in a REAL column becomes 7.0
+	if !second[1].IsNull() || second[2].Double() != 7 || !second[3].IsNull() {
+		t.Fatalf("second row read back as %v", second)
+	}
+
+	// An existing file is not overwritten
+	if _, err := db.ExportParquet(path, nil, "SELECT 1"); err == nil {
+		t.Fatal("overwrote an existing file")
+	}
+	if _, err := db.ExportParquet(filepath.Join(t.TempDir(), "x.parquet"), nil, "SELECT 1; SELECT 2"); err == nil {
+		t.Fatal("accepted two statements")
+	}
+}
+
+type importedRow struct {
+	ID    int64   `parquet:"id"`
+	Name  *string `parquet:"name,optional"`
+	Score float64 `parquet:"score"`
+	Flag  bool    `parquet:"flag"`
+	Data  []byte  `parquet:"data"`
+}
+
+func TestImportParquet(t *testing.T) {
+	db := newTestDB(t)
+	path := filepath.Join(t.TempDir(), "rows.parquet")
+	var rows []importedRow
+	for i := int64(1); i <= 2500; i++ {
+		name := "row"
+		row := importedRow{ID: i, Name: &name, Score: float64(i) / 4, Flag: i%2 == 0, Data: []byte{byte(i)}}
+		if i%10 == 0 {
+			row.Name = nil
+		}
+		rows = append(rows, row)
+	}
+	if err := parquet.WriteFile(path, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.ImportParquet(path, "imported", 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Created || result.Rows != 2500 {
+		t.Fatalf("unexpected import %+v", result)
+	}
+	wantTypes := map[string]string{"id": "INTEGER", "name": "TEXT", "score": "REAL", "flag": "INTEGER", "data": "BLOB"}
+	for _, col := range result.Columns {
+		if col.SQLiteType != wantTypes[col.Name] {
+			t.Errorf("column %s (%s) imported as %s, want %s", col.Name, col.ParquetType, col.SQLiteType, wantTypes[col.Name])
+		}
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM imported"); n != 2500 {
+		t.Fatalf("table has %d rows", n)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM imported WHERE name IS NULL"); n != 250 {
+		t.Fatalf("%d NULL names, want 250", n)
+	}
+	sample, err := db.ExecuteQuery("SELECT id, name, score, flag, hex(data) AS data FROM imported WHERE id IN (1, 10, 2500) ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sample) != 3 ||
+		sample[0]["name"] != "row" || sample[0]["score"] != 0.25 || sample[0]["flag"] != int64(0) || sample[0]["data"] != "01" ||
+		sample[1]["name"] != nil || sample[1]["flag"] != int64(1) ||
+		sample[2]["score"] != 625.0 || sample[2]["data"] != "C4" {
+		t.Fatalf("sampled rows %v", sample)
+	}
+
+	// Importing again appends to the existing table
+	if result, err = db.ImportParquet(path, "imported", 0); err != nil || result.Created || result.Rows != 2500 {
+		t.Fatalf("second import %+v, %v", result, err)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM imported"); n != 5000 {
+		t.Fatalf("table has %d rows after the second import", n)
+	}
+
+	db.ExecuteStatement("CREATE TABLE narrow (id INTEGER)")
+	if _, err := db.ImportParquet(path, "narrow", 0); err == nil {
+		t.Fatal("imported into a table missing columns")
+	}
+	if _, err := db.ImportParquet(path, "sqlite_x", 0); err == nil {
+		t.Fatal("imported into a reserved table name")
+	}
+}
+
+func TestParquetRoundTrip(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE src (id INTEGER, label TEXT, amount REAL, raw BLOB)",
+		"INSERT INTO src VALUES (1, 'a', 1.5, x'00'), (2, NULL, NULL, NULL), (3, 'c', -2, x'abcd')",
+	)
+	path := filepath.Join(t.TempDir(), "src.parquet")
+	if _, err := db.ExportParquet(path, nil, "SELECT * FROM src"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ImportParquet(path, "dst", 0); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM (SELECT * FROM src EXCEPT SELECT * FROM dst)"); n != 0 {
+		t.Fatalf("%d rows differ after the round trip", n)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM dst"); n != 3 {
+		t.Fatalf("dst has %d rows", n)
+	}
+}
@@ -0,0 +1,215 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PlanStep is one EXPLAIN QUERY PLAN row with the structured fields parsed out of its detail text
+type PlanStep struct {
+	ID     int64  `json:"id"`
+	Parent int64  `json:"parent"`
+	Detail string `json:"detail"`
+
+	Operation      string `json:"operation,omitempty"` // SCAN or SEARCH
+	Table          string `json:"table,omitempty"`     // as named in the query, so an alias if one was used
+	Index          string `json:"index,omitempty"`
+	CoveringIndex  bool   `json:"covering_index,omitempty"`
+	AutomaticIndex bool   `json:"automatic_index,omitempty"`
+	PrimaryKey     bool   `json:"primary_key,omitempty"`
+	VirtualTable   bool   `json:"virtual_table,omitempty"`
+	Constraint     string `json:"constraint,omitempty"`
+}
+
+var (
+	planAccessPattern = regexp.MustCompile(`^(SCAN|SEARCH)\s+(.+?)(?:\s+(USING\s+.*|VIRTUAL TABLE\s+.*))?$`)
+	planIndexPattern  = regexp.MustCompile(`^USING\s+(AUTOMATIC\s+)?(?:PARTIAL\s+)?(COVERING\s+)?INDEX(?:\s+([^\s(]+))?(?:\s+(\(.*\)))?$`)
+	planPKPattern     = regexp.MustCompile(`^USING\s+(?:INTEGER\s+)?PRIMARY KEY(?:\s+(\(.*\)))?$`)
+)
+
+// ExplainQueryPlan runs EXPLAIN QUERY PLAN and parses each step's detail into structured fields
+func (s *SQLiteDB) ExplainQueryPlan(query string) ([]PlanStep, error) {
+	rows, err := s.AnalyzeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	steps := make([]PlanStep, 0, len(rows))
+	for _, row := range rows {
+		detail, _ := row["detail"].(string)
+		step := ParsePlanDetail(detail)
+		step.ID = toInt64(row["id"])
+		step.Parent = toInt64(row["parent"])
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// ParsePlanDetail extracts the operation, table, and index from an EXPLAIN QUERY PLAN detail
+// line such as "SEARCH users USING COVERING INDEX idx_email (email=?)". Lines that do not
+// describe a table access (e.g. "USE TEMP B-TREE FOR ORDER BY") only carry the raw detail.
+func ParsePlanDetail(detail string) PlanStep {
+	step := PlanStep{Detail: detail}
+
+	text := strings.TrimSpace(detail)
+	if text == "SCAN CONSTANT ROW" {
+		step.Operation = "SCAN"
+		return step
+	}
+
+	m := planAccessPattern.FindStringSubmatch(text)
+	if m == nil {
+		return step
+	}
+	step.Operation = m[1]
+	// SQLite before 3.36 wrote "SCAN TABLE t" and "SEARCH TABLE t"
+	step.Table = strings.TrimPrefix(m[2], "TABLE ")
+
+	using := m[3]
+	switch {
+	case using == "":
+	case strings.HasPrefix(using, "VIRTUAL TABLE"):
+		step.VirtualTable = true
+		step.Index = strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(using, "VIRTUAL TABLE"), " INDEX"))
+	default:
+		if im := planIndexPattern.FindStringSubmatch(using); im != nil {
+			step.AutomaticIndex = im[1] != ""
+			step.CoveringIndex = im[2] != ""
+			step.Index = im[3]
+			step.Constraint = im[4]
+		} else if pm := planPKPattern.FindStringSubmatch(using); pm != nil {
+			step.PrimaryKey = true
+			step.Constraint = pm[1]
+		}
+	}
+
+	return step
+}
+
+// PlanNode is a plan step with the steps nested under it, as EXPLAIN QUERY PLAN links them
+// through their id and parent columns
+type PlanNode struct {
+	PlanStep
+	// Warning flags a step that reads a whole table
+	Warning  string      `json:"warning,omitempty"`
+	Children []*PlanNode `json:"children,omitempty"`
+}
+
+// FullScan reports whether a step reads every row of a table, without an index to narrow it
+// down. Scans of a covering index, of a virtual table, or of a constant row are not flagged.
+func (p PlanStep) FullScan() bool {
+	return p.Operation == "SCAN" && p.Table != "" && p.Index == "" && !p.VirtualTable && !p.AutomaticIndex
+}
+
+// BuildPlanTree nests plan steps under their parents. Steps are listed after their parent by
+// EXPLAIN QUERY PLAN; a step whose parent is missing becomes a root.
+func BuildPlanTree(steps []PlanStep) []*PlanNode {
+	roots := []*PlanNode{}
+	nodes := make(map[int64]*PlanNode, len(steps))
+	for _, step := range steps {
+		node := &PlanNode{PlanStep: step}
+		if step.FullScan() {
+			node.Warning = fmt.Sprintf("full table scan: every row of %s is read; an index on the columns it is filtered or joined on may help", step.Table)
+		}
+		if parent, ok := nodes[step.Parent]; ok && step.Parent != 0 {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+		nodes[step.ID] = node
+	}
+	return roots
+}
+
+// RenderPlanTree draws a plan tree the way the sqlite3 shell shows EXPLAIN QUERY PLAN,
+// appending warnings to the steps they concern
+func RenderPlanTree(roots []*PlanNode) string {
+	var b strings.Builder
+	b.WriteString("QUERY PLAN\n")
+	var render func(nodes []*PlanNode, indent string)
+	render = func(nodes []*PlanNode, indent string) {
+		for i, node := range nodes {
+			branch, next := "|--", "|  "
+			if i == len(nodes)-1 {
+				branch, next = "`--", "   "
+			}
+			b.WriteString(indent + branch + node.Detail)
+			if node.Warning != "" {
+				b.WriteString("  <- warning: " + node.Warning)
+			}
+			b.WriteString("\n")
+			render(node.Children, indent+next)
+		}
+	}
+	render(roots, "")
+	return b.String()
+}
+
+// ScriptStep is the analysis of one statement of a script
+type ScriptStep struct {
+	Index     int        `json:"index"`
+	Statement string     `json:"statement"`
+	Kind      string     `json:"kind"` // read, write, ddl, or other
+	Plan      []PlanStep `json:"plan,omitempty"`
+	Note      string     `json:"note,omitempty"`
+}
+
+var leadingCommentPattern = regexp.MustCompile(`^(\s+|--[^\n]*(\n|$)|/\*(?s:.*?)\*/)+`)
+
+// statementKind classifies a statement by its first keyword
+func statementKind(statement string) string {
+	text := leadingCommentPattern.ReplaceAllString(statement, "")
+	keyword := strings.ToUpper(strings.SplitN(strings.TrimSpace(text), " ", 2)[0])
+	if i := strings.IndexAny(keyword, "(\n\t\r"); i >= 0 {
+		keyword = keyword[:i]
+	}
+
+	switch keyword {
+	case "SELECT", "WITH", "VALUES":
+		return "read"
+	case "INSERT", "UPDATE", "DELETE", "REPLACE":
+		return "write"
+	case "CREATE", "DROP", "ALTER":
+		return "ddl"
+	default:
+		return "other"
+	}
+}
+
+// AnalyzeScript splits a script into statements and returns the query plan of each read
+// and write statement without running any of them. DDL and other statements get a note
+// instead. Statements that depend on objects created earlier in the same script cannot be
+// planned and report the planner's error in their note.
+func (s *SQLiteDB) AnalyzeScript(script string) ([]ScriptStep, error) {
+	statements := SplitStatements(script)
+	if len(statements) == 0 {
+		return nil, fmt.Errorf("script contains no statements")
+	}
+
+	steps := make([]ScriptStep, 0, len(statements))
+	for i, statement := range statements {
+		step := ScriptStep{Index: i + 1, Statement: statement, Kind: statementKind(statement)}
+
+		switch step.Kind {
+		case "read", "write":
+			plan, err := s.ExplainQueryPlan(statement)
+			if err != nil {
+				step.Note = fmt.Sprintf("plan unavailable: %v", err)
+				break
+			}
+			step.Plan = plan
+			if step.Kind == "write" {
+				step.Note = "write statement, plan shows how affected rows are located"
+			}
+		case "ddl":
+			step.Note = "schema change, no query plan; cost depends on table size (e.g. CREATE INDEX scans the whole table)"
+		default:
+			step.Note = "not analyzed"
+		}
+
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
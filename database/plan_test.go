@@ -0,0 +1,100 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParsePlanDetail(t *testing.T) {
+	tests := []struct {
+		detail string
+		want   PlanStep
+	}{
+		{"SCAN users", PlanStep{Operation: "SCAN", Table: "users"}},
+		{"SCAN TABLE users", PlanStep{Operation: "SCAN", Table: "users"}},
+		{"SEARCH users USING INDEX idx_email (email=?)", PlanStep{Operation: "SEARCH", Table: "users", Index: "idx_email", Constraint: "(email=?)"}},
+		{"SEARCH u USING COVERING INDEX idx_a_b (a=? AND b>?)", PlanStep{Operation: "SEARCH", Table: "u", Index: "idx_a_b", CoveringIndex: true, Constraint: "(a=? AND b>?)"}},
+		{"SEARCH o USING AUTOMATIC COVERING INDEX (user_id=?)", PlanStep{Operation: "SEARCH", Table: "o", AutomaticIndex: true, CoveringIndex: true, Constraint: "(user_id=?)"}},
+		{"SEARCH users USING INTEGER PRIMARY KEY (rowid=?)", PlanStep{Operation: "SEARCH", Table: "users", PrimaryKey: true, Constraint: "(rowid=?)"}},
+		{"SCAN docs VIRTUAL TABLE INDEX 0:M1", PlanStep{Operation: "SCAN", Table: "docs", VirtualTable: true, Index: "0:M1"}},
+		{"SCAN CONSTANT ROW", PlanStep{Operation: "SCAN"}},
+		{"USE TEMP B-TREE FOR ORDER BY", PlanStep{}},
+	}
+	for _, test := range tests {
+		test.want.Detail = test.detail
+		if got := ParsePlanDetail(test.detail); got != test.want {
+			t.Errorf("%q:\n got %+v\nwant %+v", test.detail, got, test.want)
+		}
+	}
+}
+
+func TestExplainQueryPlan(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT, name TEXT)",
+		"CREATE INDEX idx_users_email ON users (email)",
+	)
+
+	steps, err := db.ExplainQueryPlan("SELECT name FROM users WHERE email = 'a@example.com'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 1 {
+		t.Fatalf("got %d steps, want 1: %+v", len(steps), steps)
+	}
+	if step := steps[0]; step.Operation != "SEARCH" || step.Table != "users" || step.Index != "idx_users_email" || step.CoveringIndex {
+		t.Fatalf("unexpected step %+v", step)
+	}
+
+	steps, err = db.ExplainQueryPlan("SELECT email FROM users WHERE email > 'm'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if step := steps[0]; step.Index != "idx_users_email" || !step.CoveringIndex {
+		t.Fatalf("unexpected step %+v", step)
+	}
+}
+
+func TestAnalyzeScript(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT)")
+
+	steps, err := db.AnalyzeScript(`
+		-- a mixed script
+		SELECT * FROM users WHERE id = 1;
+		CREATE INDEX idx_users_email ON users (email);
+		UPDATE users SET email = 'x' WHERE id = 2;
+		PRAGMA optimize;
+		SELECT * FROM later;
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(steps) != 5 {
+		t.Fatalf("got %d steps, want 5", len(steps))
+	}
+	kinds := []string{"read", "ddl", "write", "other", "read"}
+	for i, step := range steps {
+		if step.Index != i+1 || step.Kind != kinds[i] {
+			t.Errorf("step %d is %d, %s; want %s", i, step.Index, step.Kind, kinds[i])
+		}
+	}
+	if len(steps[0].Plan) != 1 || steps[0].Plan[0].Operation != "SEARCH" || !steps[0].Plan[0].PrimaryKey {
+		t.Errorf("unexpected plan of the SELECT: %+v", steps[0].Plan)
+	}
+	if steps[1].Plan != nil || !strings.Contains(steps[1].Note, "schema change") {
+		t.Errorf("unexpected DDL step %+v", steps[1])
+	}
+	if len(steps[2].Plan) == 0 || !strings.Contains(steps[2].Note, "write statement") {
+		t.Errorf("unexpected write step %+v", steps[2])
+	}
+	if !strings.Contains(steps[4].Note, "plan unavailable") {
+		t.Errorf("unexpected step for a missing table %+v", steps[4])
+	}
+	// Nothing ran
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM sqlite_master WHERE name = 'idx_users_email'"); n != 0 {
+		t.Fatal("the script's index was created")
+	}
+
+	if _, err := db.AnalyzeScript("  -- only a comment\n"); err == nil {
+		t.Error("no error for a script without statements")
+	}
+}
@@ -0,0 +1,150 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// DefaultMaxOpenDatabases is the number of databases, the current one included, whose
+// connection pools are kept open unless SetMaxOpenDatabases changes it
+const DefaultMaxOpenDatabases = 4
+
+// parkedPool is the connection pool of a database switched away from, kept for switching back
+type parkedPool struct {
+	path string
+	db   *sql.DB
+	// settings are the connection settings the pool's connections were opened with
+	settings string
+	// file identifies the database file, so a file replaced meanwhile is not served from
+	// connections to the old one
+	file os.FileInfo
+}
+
+// poolCache holds parked pools, least recently used first
+type poolCache struct {
+	max   int // 0 means DefaultMaxOpenDatabases
+	pools []parkedPool
+}
+
+// SetMaxOpenDatabases bounds the number of databases, the current one included, whose
+// connection pools stay open. Switching back to a database whose pool is still open reuses
+// its connections, with their page caches and PRAGMA settings, instead of reconnecting; the
+// least recently used pools are closed beyond the limit. 1 closes a database as soon as the
+// server switches away from it.
+func (s *SQLiteDB) SetMaxOpenDatabases(n int) error {
+	if n < 1 {
+		return fmt.Errorf("at least one database must stay open")
+	}
+	s.parked.max = n
+	s.evictParked()
+	return nil
+}
+
+// OpenDatabases lists the databases whose pools are kept open besides the current one, most
+// recently used first
+func (s *SQLiteDB) OpenDatabases() []string {
+	paths := make([]string, 0, len(s.parked.pools))
+	for i := len(s.parked.pools) - 1; i >= 0; i-- {
+		paths = append(paths, s.parked.pools[i].path)
+	}
+	return paths
+}
+
+// maxOpenDatabases returns the limit set with SetMaxOpenDatabases
+func (s *SQLiteDB) maxOpenDatabases() int {
+	if s.parked.max == 0 {
+		return DefaultMaxOpenDatabases
+	}
+	return s.parked.max
+}
+
+// poolSettings describes every setting a pool's connections are opened with, so a parked
+// pool is only reused while it matches the current settings. The pool size limit of
+// SetSerialized is left out, as it can be applied to an open pool.
+func (s *SQLiteDB) poolSettings() string {
+	statements, files := s.attachStatements()
+	parts := append(s.connectionPragmas(), statements...)
+	parts = append(parts, files...)
+
+	s.settingsMu.RLock()
+	parts = append(parts, fmt.Sprintf("readonly=%t", s.readOnly))
+	if s.enabledFunctions != nil {
+		var names []string
+		for name, enabled := range s.enabledFunctions {
+			if enabled {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		parts = append(parts, "functions="+strings.Join(names, ","))
+	}
+	s.settingsMu.RUnlock()
+	return strings.Join(parts, "\x00")
+}
+
+// parkedKey returns the key of a database path in the pool cache
+func parkedKey(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return filepath.Clean(path)
+}
+
+// park keeps the current pool open for switching back to it, closing the least recently
+// used pools beyond the limit
+func (s *SQLiteDB) park() {
+	if s.db == nil {
+		return
+	}
+	if s.maxOpenDatabases() <= 1 {
+		s.db.Close()
+		return
+	}
+	file, err := os.Stat(s.dbPath)
+	if err != nil {
+		// In-memory or deleted databases cannot be found again by path
+		s.db.Close()
+		return
+	}
+	s.parked.pools = append(s.parked.pools, parkedPool{path: parkedKey(s.dbPath), db: s.db, settings: s.poolSettings(), file: file})
+	s.evictParked()
+}
+
+// unpark removes and returns the parked pool of path, or nil. A pool opened with other
+// settings, or to a file since replaced, is closed instead of returned.
+func (s *SQLiteDB) unpark(path string) *sql.DB {
+	key := parkedKey(path)
+	for i, pool := range s.parked.pools {
+		if pool.path != key {
+			continue
+		}
+		s.parked.pools = append(s.parked.pools[:i], s.parked.pools[i+1:]...)
+		if file, err := os.Stat(path); err != nil || !os.SameFile(file, pool.file) || pool.settings != s.poolSettings() {
+			pool.db.Close()
+			return nil
+		}
+		return pool.db
+	}
+	return nil
+}
+
+// evictParked closes the least recently used parked pools until the open databases, the
+// current one included, fit the limit
+func (s *SQLiteDB) evictParked() {
+	for len(s.parked.pools) > 0 && len(s.parked.pools)+1 > s.maxOpenDatabases() {
+		s.parked.pools[0].db.Close()
+		s.parked.pools = s.parked.pools[1:]
+	}
+}
+
+// closeParked closes every parked pool
+func (s *SQLiteDB) closeParked() {
+	for _, pool := range s.parked.pools {
+		pool.db.Close()
+	}
+	s.parked.pools = nil
+}
@@ -0,0 +1,65 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPoolStatsCountConcurrentQueries(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (id INTEGER)", "INSERT INTO t VALUES (1), (2)")
+
+	// Each open result set holds a connection
+	for i := 0; i < 3; i++ {
+		rows, err := db.db.Query("SELECT id FROM t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rows.Close()
+		rows.Next()
+	}
+	stats, err := db.GetPoolStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["in_use"].(int) < 3 || stats["open_connections"].(int) < 3 {
+		t.Fatalf("in_use %v, open_connections %v with 3 queries open", stats["in_use"], stats["open_connections"])
+	}
+	for _, key := range []string{"idle", "wait_duration", "page_size", "page_count", "sqlite_version", "busy_timeout"} {
+		if _, ok := stats[key]; !ok {
+			t.Errorf("stats lack %s", key)
+		}
+	}
+}
+
+func TestPoolStatsCountWaits(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (id INTEGER)")
+	db.SetSerialized(true)
+
+	conn, err := db.db.Conn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := db.ExecuteQuery("SELECT id FROM t")
+		done <- err
+	}()
+	// The query waits for the only connection
+	time.Sleep(50 * time.Millisecond)
+	conn.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.GetPoolStats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["max_open_connections"] != 1 || stats["wait_count"].(int64) < 1 {
+		t.Fatalf("max_open_connections %v, wait_count %v", stats["max_open_connections"], stats["wait_count"])
+	}
+	if stats["serialized"] != true {
+		t.Fatal("serialized not reported")
+	}
+}
@@ -0,0 +1,242 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// wherePrefixPattern matches a leading WHERE keyword, which TracePredicate accepts and drops
+var wherePrefixPattern = regexp.MustCompile(`(?is)^\s*WHERE\b`)
+
+// ConjunctResult is the value of one AND-ed condition of a WHERE clause for a single row
+type ConjunctResult struct {
+	Index     int    `json:"index"`
+	Condition string `json:"condition"`
+	// Value is the condition's value for the row: 1 or 0 for most comparisons, NULL when an
+	// operand is NULL, and for a bare expression whatever it evaluates to
+	Value interface{} `json:"value"`
+	// Passed is true when the value selects the row: non-NULL and not zero
+	Passed bool   `json:"passed"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PredicateTrace reports which conditions of a WHERE clause a row satisfies
+type PredicateTrace struct {
+	Table     string      `json:"table"`
+	KeyColumn string      `json:"key_column"`
+	Key       interface{} `json:"key"`
+	Where     string      `json:"where"`
+	// Matches is true when the whole WHERE clause selects the row
+	Matches   bool             `json:"matches"`
+	Conjuncts []ConjunctResult `json:"conjuncts"`
+	// Failed lists the indexes of the conditions that exclude the row
+	Failed []int `json:"failed"`
+}
+
+// TracePredicate explains why a WHERE clause does or does not select one row of a table.
+// The clause is split on its top-level ANDs and each condition is evaluated against the row
+// identified by key, its rowid, or its primary key for a WITHOUT ROWID table with a
+// single-column key. Conditions joined by OR, or nested in parentheses with OR, are evaluated
+// as a whole. All conditions are read in one transaction, so they see the same row.
+func (s *SQLiteDB) TracePredicate(tableName string, key interface{}, where string) (*PredicateTrace, error) {
+	where = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(wherePrefixPattern.ReplaceAllString(where, "")), ";"))
+	if where == "" {
+		return nil, fmt.Errorf("the WHERE clause is empty")
+	}
+	rowid, err := s.GetRowidColumn(tableName)
+	if err != nil {
+		return nil, err
+	}
+	keyColumn := rowid.RowidColumn
+	if keyColumn == "" {
+		if len(rowid.PrimaryKey) != 1 {
+			return nil, fmt.Errorf("table '%s' has no rowid and a primary key of %d columns, so a single key value cannot identify a row", tableName, len(rowid.PrimaryKey))
+		}
+		keyColumn = rowid.PrimaryKey[0]
+	}
+
+	from := fmt.Sprintf("FROM %s WHERE %s = ?", quoteIdentifier(tableName), quoteIdentifier(keyColumn))
+	evaluate := func(condition string) string {
+		// The line break ends a trailing line comment of the condition
+		return fmt.Sprintf("SELECT v, v IS TRUE FROM (SELECT (%s\n) AS v %s)", condition, from)
+	}
+	conjuncts, err := splitConjuncts(where)
+	if err != nil {
+		return nil, fmt.Errorf("invalid WHERE clause: %w", err)
+	}
+	if !IsSingleStatement(evaluate(where)) {
+		return nil, fmt.Errorf("the WHERE clause must be a single expression")
+	}
+
+	trace := &PredicateTrace{Table: tableName, KeyColumn: keyColumn, Key: key, Where: where, Failed: []int{}}
+	err = s.Transaction(func(tx *sql.Tx) error {
+		var found int
+		if err := tx.QueryRowContext(s.ctx(), "SELECT 1 "+from, key).Scan(&found); err == sql.ErrNoRows {
+			return fmt.Errorf("table '%s' has no row with %s = %v", tableName, keyColumn, key)
+		} else if err != nil {
+			return err
+		}
+
+		if err := tx.QueryRowContext(s.ctx(), evaluate(where), key).Scan(new(interface{}), &trace.Matches); err != nil {
+			return fmt.Errorf("failed to evaluate the WHERE clause: %w", err)
+		}
+		for i, condition := range conjuncts {
+			result := ConjunctResult{Index: i, Condition: condition}
+			if err := tx.QueryRowContext(s.ctx(), evaluate(condition), key).Scan(&result.Value, &result.Passed); err != nil {
+				result.Error = err.Error()
+			}
+			if !result.Passed {
+				trace.Failed = append(trace.Failed, i)
+			}
+			trace.Conjuncts = append(trace.Conjuncts, result)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return trace, nil
+}
+
+// splitConjuncts splits an expression on its top-level AND operators. The AND of
+// BETWEEN ... AND, ANDs inside parentheses or CASE expressions, and text in string literals,
+// quoted identifiers, and comments do not split it. A condition wrapped whole in parentheses
+// is unwrapped and split in turn. Unbalanced parentheses are an error.
+func splitConjuncts(expr string) ([]string, error) {
+	var conjuncts []string
+	runes := []rune(expr)
+	flush := func(part []rune) error {
+		text := strings.TrimSpace(string(part))
+		if text == "" {
+			return nil
+		}
+		inner := []rune(text)
+		if inner[0] == '(' && closingParenthesis(inner) == len(inner)-1 {
+			nested, err := splitConjuncts(string(inner[1 : len(inner)-1]))
+			if err != nil {
+				return err
+			}
+			if len(nested) > 1 {
+				conjuncts = append(conjuncts, nested...)
+				return nil
+			}
+		}
+		conjuncts = append(conjuncts, text)
+		return nil
+	}
+
+	start, depth, between, cases := 0, 0, 0, 0
+	for i := 0; i < len(runes); i++ {
+		if end := skipQuoted(runes, i); end > i {
+			i = end
+			continue
+		}
+		r := runes[i]
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth--; depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses")
+			}
+		case unicode.IsLetter(r) || r == '_':
+			wordStart := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '$') {
+				i++
+			}
+			if depth > 0 {
+				continue
+			}
+			switch strings.ToUpper(string(runes[wordStart : i+1])) {
+			case "BETWEEN":
+				between++
+			case "CASE":
+				cases++
+			case "END":
+				cases--
+			case "AND":
+				if between > 0 {
+					between--
+				} else if cases == 0 {
+					if err := flush(runes[start:wordStart]); err != nil {
+						return nil, err
+					}
+					start = i + 1
+				}
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses")
+	}
+	if err := flush(runes[start:]); err != nil {
+		return nil, err
+	}
+	return conjuncts, nil
+}
+
+// closingParenthesis returns the index of the parenthesis closing the one that opens runes,
+// or -1 when it is not closed
+func closingParenthesis(runes []rune) int {
+	depth := 0
+	for i := 0; i < len(runes); i++ {
+		if end := skipQuoted(runes, i); end > i {
+			i = end
+			continue
+		}
+		switch runes[i] {
+		case '(':
+			depth++
+		case ')':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// skipQuoted returns the index of the last rune of the string literal, quoted identifier, or
+// comment starting at i, or i when none starts there
+func skipQuoted(runes []rune, i int) int {
+	r := runes[i]
+	switch {
+	case r == '\'' || r == '"' || r == '`':
+		// Doubled quotes are escapes
+		for j := i + 1; j < len(runes); j++ {
+			if runes[j] == r {
+				if j+1 < len(runes) && runes[j+1] == r {
+					j++
+					continue
+				}
+				return j
+			}
+		}
+		return len(runes) - 1
+	case r == '[':
+		for j := i + 1; j < len(runes); j++ {
+			if runes[j] == ']' {
+				return j
+			}
+		}
+		return len(runes) - 1
+	case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+		for j := i; j < len(runes); j++ {
+			if runes[j] == '\n' {
+				return j
+			}
+		}
+		return len(runes) - 1
+	case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+		for j := i + 2; j+1 < len(runes); j++ {
+			if runes[j] == '*' && runes[j+1] == '/' {
+				return j + 1
+			}
+		}
+		return len(runes) - 1
+	}
+	return i
+}
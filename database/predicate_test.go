@@ -0,0 +1,108 @@
+package database
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplitConjuncts(t *testing.T) {
+	tests := []struct {
+		expr string
+		want []string
+	}{
+		{"a = 1", []string{"a = 1"}},
+		{"a = 1 AND b = 2 and c = 3", []string{"a = 1", "b = 2", "c = 3"}},
+		{"a BETWEEN 1 AND 5 AND b = 2", []string{"a BETWEEN 1 AND 5", "b = 2"}},
+		{"(a = 1 OR b = 2) AND c = 3", []string{"(a = 1 OR b = 2)", "c = 3"}},
+		{"(a = 1 AND b = 2)", []string{"a = 1", "b = 2"}},
+		{"CASE WHEN a AND b THEN 1 END AND c", []string{"CASE WHEN a AND b THEN 1 END", "c"}},
+		{"name = 'this AND that' AND \"x AND y\" = 1", []string{"name = 'this AND that'", "\"x AND y\" = 1"}},
+		{"a = 1 -- AND b = 2\nAND c = 3", []string{"a = 1 -- AND b = 2", "c = 3"}},
+		{"brand = 1 AND candy = 2", []string{"brand = 1", "candy = 2"}},
+	}
+	for _, tt := range tests {
+		got, err := splitConjuncts(tt.expr)
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{"(a = 1", "a = 1)", ") AND ("} {
+		if _, err := splitConjuncts(expr); err == nil {
+			t.Errorf("%q accepted", expr)
+		}
+	}
+}
+
+func TestTracePredicate(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, status TEXT, total REAL, note TEXT)",
+		"INSERT INTO orders VALUES (1, 'shipped', 40.0, NULL)",
+	)
+
+	trace, err := db.TracePredicate("orders", 1, "WHERE status = 'shipped' AND total BETWEEN 50 AND 100 AND note IS NULL;")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trace.Matches || trace.KeyColumn != "id" {
+		t.Fatalf("got matches %v with key column %q", trace.Matches, trace.KeyColumn)
+	}
+	if len(trace.Conjuncts) != 3 || trace.Conjuncts[1].Condition != "total BETWEEN 50 AND 100" {
+		t.Fatalf("got conjuncts %+v", trace.Conjuncts)
+	}
+	// Only the range excludes the row
+	if !reflect.DeepEqual(trace.Failed, []int{1}) {
+		t.Fatalf("got failed %v, want [1]", trace.Failed)
+	}
+
+	// A NULL operand fails the condition without being false
+	if trace, err = db.TracePredicate("orders", 1, "status = 'shipped' AND note = 'gift'"); err != nil {
+		t.Fatal(err)
+	}
+	if result := trace.Conjuncts[1]; result.Passed || result.Value != nil {
+		t.Fatalf("got %+v, want a NULL value", result)
+	}
+
+	if trace, err = db.TracePredicate("orders", 1, "status = 'shipped' AND total < 50"); err != nil {
+		t.Fatal(err)
+	}
+	if !trace.Matches || len(trace.Failed) != 0 {
+		t.Fatalf("got matches %v with failed %v", trace.Matches, trace.Failed)
+	}
+
+	// A clause that cannot be evaluated fails as a whole
+	if trace, err = db.TracePredicate("orders", 1, "status = 'shipped' AND missing_column = 1"); err == nil {
+		t.Fatalf("unknown column accepted: %+v", trace)
+	}
+}
+
+func TestTracePredicateRejects(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE pairs (a TEXT, b TEXT, PRIMARY KEY (a, b)) WITHOUT ROWID",
+		"CREATE TABLE items (id INTEGER PRIMARY KEY)",
+		"INSERT INTO items VALUES (1)",
+	)
+
+	for _, tt := range []struct {
+		table string
+		key   interface{}
+		where string
+		want  string
+	}{
+		{"items", 1, "  WHERE ", "empty"},
+		{"items", 7, "id = 7", "no row"},
+		{"items", 1, "id = 1; DROP TABLE items", "single expression"},
+		{"items", 1, "(id = 1", "unbalanced"},
+		{"pairs", "x", "a = 'x'", "primary key of 2 columns"},
+	} {
+		_, err := db.TracePredicate(tt.table, tt.key, tt.where)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s %q: got %v, want %q", tt.table, tt.where, err, tt.want)
+		}
+	}
+}
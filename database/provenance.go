@@ -0,0 +1,358 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+)
+
+// ColumnOrigin describes where a result column of a query comes from. Table and Column are
+// empty when the value is computed rather than read directly from a table.
+type ColumnOrigin struct {
+	Name         string `json:"name"`
+	Table        string `json:"table,omitempty"`
+	Column       string `json:"column,omitempty"`
+	DeclaredType string `json:"declared_type,omitempty"`
+	// Inputs are all the table columns the values of the column are computed from, in any
+	// arm of a compound query, such as ssn for max(ssn) or ssn || ''
+	Inputs []ColumnRef `json:"inputs,omitempty"`
+}
+
+// ColumnRef names a table column
+type ColumnRef struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+}
+
+// vdbeOp is one instruction of an EXPLAIN program
+type vdbeOp struct {
+	opcode     string
+	p1, p2, p3 int64
+	p5         int64
+}
+
+// cursorSource maps the storage positions read through a cursor to table columns
+type cursorSource struct {
+	table   string
+	columns []string // column name per storage position; "" for rowid or expressions
+	rowid   string   // column name to report for the Rowid opcode
+	index   string   // name of the index, "" for a table b-tree
+}
+
+// Opcodes that store their result in register P2 or P3, or modify register P1 in place,
+// overwriting any origin previously tracked for it
+var (
+	writesP2 = map[string]bool{"Integer": true, "Int64": true, "Real": true, "String8": true, "String": true,
+		"Blob": true, "Variable": true}
+	modifiesP1 = map[string]bool{"Cast": true, "RealAffinity": true, "AddImm": true, "SoftNull": true}
+)
+
+// QueryColumnOrigins reports the source table and column of each result column of a query.
+// go-sqlite3 only exposes column origins when built with the sqlite_column_metadata tag, so
+// the origins are derived from the query's EXPLAIN program instead: result registers are
+// traced back to the Column and Rowid instructions that loaded them. Computed columns keep
+// only their name (the alias), and list the table columns they are computed from in Inputs.
+func (s *SQLiteDB) QueryColumnOrigins(query string, args ...interface{}) ([]ColumnOrigin, error) {
+	rows, err := s.db.QueryContext(s.ctx(), query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", err)
+	}
+	columnTypes, err := rows.ColumnTypes()
+	rows.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column types: %w", err)
+	}
+
+	origins := make([]ColumnOrigin, len(columnTypes))
+	for i, ct := range columnTypes {
+		origins[i] = ColumnOrigin{Name: ct.Name(), DeclaredType: ct.DatabaseTypeName()}
+	}
+
+	program, err := s.explainProgram(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	sources, err := s.rootPageSources()
+	if err != nil {
+		return nil, err
+	}
+
+	type origin struct{ table, column string }
+	// inputs holds every table column the value of a register is computed from; stored does
+	// the same for each field of the records inserted through a cursor, such as a sorter or
+	// the ephemeral table of a UNION, and records for each record built in a register
+	type columnSet map[origin]bool
+	union := func(sets ...columnSet) columnSet {
+		merged := make(columnSet)
+		for _, set := range sets {
+			for o := range set {
+				merged[o] = true
+			}
+		}
+		return merged
+	}
+	var (
+		cursors      map[int64]*cursorSource
+		registers    map[int64]origin
+		inputs       map[int64]columnSet
+		records      map[int64][]columnSet
+		stored       = make(map[int64][]columnSet)
+		results      [][]origin
+		resultInputs [][]columnSet
+	)
+	store := func(cursor int64, record []columnSet) {
+		fields := stored[cursor]
+		for len(fields) < len(record) {
+			fields = append(fields, make(columnSet))
+		}
+		for i, set := range record {
+			fields[i] = union(fields[i], set)
+		}
+		stored[cursor] = fields
+	}
+
+	// Records are read back at lower addresses than they are stored at in loops such as
+	// those of recursive queries, so a second pass sees the inputs of every stored record
+	for pass := 0; pass < 2; pass++ {
+		cursors = make(map[int64]*cursorSource)
+		registers = make(map[int64]origin)
+		inputs = make(map[int64]columnSet)
+		records = make(map[int64][]columnSet)
+		results, resultInputs = nil, nil
+
+		for _, op := range program {
+			switch op.opcode {
+			case "OpenRead", "OpenWrite", "ReopenIdx":
+				// P3 is the database number; only the main database is mapped
+				if op.p3 == 0 {
+					cursors[op.p1] = sources[op.p2]
+				}
+			case "OpenDup":
+				stored[op.p1] = stored[op.p2]
+			case "Column":
+				registers[op.p3] = origin{}
+				delete(inputs, op.p3)
+				if src := cursors[op.p1]; src != nil && op.p2 >= 0 && int(op.p2) < len(src.columns) && src.columns[op.p2] != "" {
+					registers[op.p3] = origin{src.table, src.columns[op.p2]}
+					inputs[op.p3] = columnSet{registers[op.p3]: true}
+				} else if fields := stored[op.p1]; op.p2 >= 0 && int(op.p2) < len(fields) {
+					inputs[op.p3] = union(fields[op.p2])
+				}
+			case "Rowid", "IdxRowid":
+				registers[op.p2] = origin{}
+				delete(inputs, op.p2)
+				if src := cursors[op.p1]; src != nil {
+					registers[op.p2] = origin{src.table, src.rowid}
+					inputs[op.p2] = columnSet{registers[op.p2]: true}
+				}
+			case "Copy", "SCopy", "IntCopy", "Move":
+				count := int64(1)
+				if op.opcode == "Copy" {
+					count = op.p3 + 1
+				} else if op.opcode == "Move" {
+					count = op.p3
+				}
+				for i := int64(0); i < count; i++ {
+					registers[op.p2+i] = registers[op.p1+i]
+					inputs[op.p2+i] = inputs[op.p1+i]
+				}
+			case "Null":
+				for r := op.p2; r <= max(op.p2, op.p3); r++ {
+					delete(registers, r)
+					delete(inputs, r)
+				}
+			case "ResultRow":
+				row := make([]origin, op.p2)
+				rowInputs := make([]columnSet, op.p2)
+				for i := int64(0); i < op.p2; i++ {
+					row[i] = registers[op.p1+i]
+					rowInputs[i] = inputs[op.p1+i]
+				}
+				results = append(results, row)
+				resultInputs = append(resultInputs, rowInputs)
+			case "MakeRecord":
+				record := make([]columnSet, op.p2)
+				for i := int64(0); i < op.p2; i++ {
+					record[i] = inputs[op.p1+i]
+				}
+				records[op.p3] = record
+				delete(registers, op.p3)
+			case "SorterInsert", "IdxInsert", "Insert":
+				store(op.p1, records[op.p2])
+			case "SorterData":
+				// The record is read back through the pseudo-cursor P3
+				stored[op.p3] = stored[op.p1]
+				delete(registers, op.p2)
+			case "Function", "PureFunc", "AggStep", "AggStep1", "AggInverse":
+				// Arguments are the P5 registers from P2; aggregates accumulate in P3
+				args := []columnSet{inputs[op.p3]}
+				if op.opcode == "Function" || op.opcode == "PureFunc" {
+					args = nil
+				}
+				for i := int64(0); i < op.p5; i++ {
+					args = append(args, inputs[op.p2+i])
+				}
+				inputs[op.p3] = union(args...)
+				delete(registers, op.p3)
+			case "AggValue":
+				inputs[op.p3] = inputs[op.p1]
+				delete(registers, op.p3)
+			case "Add", "Subtract", "Multiply", "Divide", "Remainder", "Concat", "BitAnd", "BitOr", "ShiftLeft", "ShiftRight":
+				inputs[op.p3] = union(inputs[op.p1], inputs[op.p2])
+				delete(registers, op.p3)
+			case "BitNot", "Not":
+				inputs[op.p2] = inputs[op.p1]
+				delete(registers, op.p2)
+			default:
+				if writesP2[op.opcode] {
+					delete(registers, op.p2)
+					delete(inputs, op.p2)
+				} else if modifiesP1[op.opcode] {
+					delete(registers, op.p1)
+				} else {
+					// Most other expression opcodes write P3. Their inputs are kept, since
+					// comparisons also name a register they only read in P3.
+					delete(registers, op.p3)
+				}
+			}
+		}
+	}
+
+	// A compound query has one ResultRow per arm; keep an origin only if all arms agree,
+	// and the inputs of every arm
+	for i := range origins {
+		var agreed *origin
+		columnInputs := make(columnSet)
+		for arm, row := range results {
+			if i >= len(row) {
+				agreed = &origin{}
+				continue
+			}
+			columnInputs = union(columnInputs, resultInputs[arm][i])
+			if agreed == nil {
+				o := row[i]
+				agreed = &o
+			} else if *agreed != row[i] {
+				agreed = &origin{}
+			}
+		}
+		if agreed != nil {
+			origins[i].Table = agreed.table
+			origins[i].Column = agreed.column
+		}
+		for o := range columnInputs {
+			if o.column != "" {
+				origins[i].Inputs = append(origins[i].Inputs, ColumnRef{Table: o.table, Column: o.column})
+			}
+		}
+		sort.Slice(origins[i].Inputs, func(a, b int) bool {
+			x, y := origins[i].Inputs[a], origins[i].Inputs[b]
+			return x.Table < y.Table || (x.Table == y.Table && x.Column < y.Column)
+		})
+	}
+
+	return origins, nil
+}
+
+// explainProgram returns the bytecode program SQLite compiles for a statement. args are only
+// bound to satisfy the statement's placeholders; they do not change the program.
+func (s *SQLiteDB) explainProgram(query string, args ...interface{}) ([]vdbeOp, error) {
+	return explainProgramOn(s.ctx(), s.db, query, args...)
+}
+
+// explainProgramOn is explainProgram on a given connection, whose database numbers the
+// program's P3 operands then refer to
+func explainProgramOn(ctx context.Context, q queryer, query string, args ...interface{}) ([]vdbeOp, error) {
+	rows, err := q.QueryContext(ctx, "EXPLAIN "+query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to explain query: %w", err)
+	}
+	defer rows.Close()
+
+	var program []vdbeOp
+	for rows.Next() {
+		var addr int64
+		var op vdbeOp
+		var p4, comment sql.NullString
+		if err := rows.Scan(&addr, &op.opcode, &op.p1, &op.p2, &op.p3, &p4, &op.p5, &comment); err != nil {
+			return nil, err
+		}
+		program = append(program, op)
+	}
+	return program, rows.Err()
+}
+
+// rootPageSources maps the root page of every table and index b-tree to the table columns
+// stored at each position of its records
+func (s *SQLiteDB) rootPageSources() (map[int64]*cursorSource, error) {
+	objects, err := s.ExecuteQuery("SELECT type, name, tbl_name, rootpage, sql FROM sqlite_master WHERE type IN ('table', 'index') AND rootpage > 0")
+	if err != nil {
+		return nil, err
+	}
+
+	sources := make(map[int64]*cursorSource)
+	for _, obj := range objects {
+		objType, _ := obj["type"].(string)
+		name, _ := obj["name"].(string)
+		table, _ := obj["tbl_name"].(string)
+		tableSQL, _ := obj["sql"].(string)
+		rootPage := toInt64(obj["rootpage"])
+
+		if objType == "table" {
+			info, err := s.GetRowidColumn(table)
+			if err != nil {
+				return nil, err
+			}
+			columns, err := s.GetTableSchema(table)
+			if err != nil {
+				return nil, err
+			}
+			src := &cursorSource{table: table, rowid: info.RowidColumn}
+			if withoutRowidPattern.MatchString(tableSQL) {
+				// WITHOUT ROWID tables store their primary key columns first
+				src.columns = append(src.columns, info.PrimaryKey...)
+				for _, col := range columns {
+					if toInt64(col["pk"]) == 0 {
+						colName, _ := col["name"].(string)
+						src.columns = append(src.columns, colName)
+					}
+				}
+			} else {
+				for _, col := range columns {
+					colName, _ := col["name"].(string)
+					// The rowid alias is stored as NULL in the record and read with Rowid
+					if colName == info.AliasColumn {
+						colName = ""
+					}
+					src.columns = append(src.columns, colName)
+				}
+			}
+			sources[rootPage] = src
+			continue
+		}
+
+		// index_xinfo lists key columns followed by the rowid or primary key columns
+		xinfo, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_xinfo(%s)", quoteIdentifier(name)))
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(xinfo, func(i, j int) bool { return toInt64(xinfo[i]["seqno"]) < toInt64(xinfo[j]["seqno"]) })
+
+		info, err := s.GetRowidColumn(table)
+		if err != nil {
+			return nil, err
+		}
+		src := &cursorSource{table: table, rowid: info.RowidColumn, index: name}
+		for _, col := range xinfo {
+			colName, _ := col["name"].(string)
+			if toInt64(col["cid"]) == -1 {
+				colName = info.RowidColumn
+			}
+			src.columns = append(src.columns, colName)
+		}
+		sources[rootPage] = src
+	}
+
+	return sources, nil
+}
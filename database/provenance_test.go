@@ -0,0 +1,69 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryColumnOriginInputs(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, ssn TEXT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, total REAL)",
+	)
+	name := ColumnRef{"users", "name"}
+	ssn := ColumnRef{"users", "ssn"}
+
+	tests := []struct {
+		query  string
+		origin ColumnRef
+		inputs []ColumnRef
+	}{
+		{"SELECT ssn AS x FROM users", ssn, []ColumnRef{ssn}},
+		{"SELECT name FROM users UNION ALL SELECT ssn FROM users", ColumnRef{}, []ColumnRef{name, ssn}},
+		{"SELECT name FROM users UNION SELECT ssn FROM users", ColumnRef{}, []ColumnRef{name, ssn}},
+		{"SELECT max(ssn) FROM users", ColumnRef{}, []ColumnRef{ssn}},
+		{"SELECT ssn || name FROM users", ColumnRef{}, []ColumnRef{name, ssn}},
+		{"SELECT name FROM users ORDER BY ssn", ColumnRef{}, []ColumnRef{name}},
+		{"SELECT sum(total) FROM orders JOIN users ON users.id = user_id GROUP BY ssn", ColumnRef{}, []ColumnRef{{"orders", "total"}}},
+		{"SELECT 1 + 2", ColumnRef{}, nil},
+	}
+	for _, test := range tests {
+		origins, err := db.QueryColumnOrigins(test.query)
+		if err != nil {
+			t.Errorf("%s: %v", test.query, err)
+			continue
+		}
+		got := origins[0]
+		if (ColumnRef{got.Table, got.Column}) != test.origin || !reflect.DeepEqual(got.Inputs, test.inputs) {
+			t.Errorf("%s: origin %s.%s, inputs %v; want %v, inputs %v", test.query, got.Table, got.Column, got.Inputs, test.origin, test.inputs)
+		}
+	}
+}
+
+func TestQueryColumnOriginsOverJoin(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER REFERENCES users(id), total DECIMAL(10,2))",
+	)
+
+	origins, err := db.QueryColumnOrigins("SELECT u.name AS customer, o.total, o.id, count(*) AS n FROM orders o JOIN users u ON u.id = o.user_id GROUP BY o.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ name, table, column, declared string }{
+		// Aliases resolve to the tables, and result names to the columns
+		{"customer", "users", "name", "TEXT"},
+		{"total", "orders", "total", "DECIMAL(10,2)"},
+		{"id", "orders", "id", "INTEGER"},
+		{"n", "", "", ""},
+	}
+	if len(origins) != len(want) {
+		t.Fatalf("got %d origins, want %d", len(origins), len(want))
+	}
+	for i, w := range want {
+		got := origins[i]
+		if got.Name != w.name || got.Table != w.table || got.Column != w.column || got.DeclaredType != w.declared {
+			t.Errorf("column %d: got %+v, want %+v", i, got, w)
+		}
+	}
+}
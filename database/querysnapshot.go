@@ -0,0 +1,142 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// DefaultSnapshotBytes is the largest database QueryAtSnapshot copies by default
+const DefaultSnapshotBytes = 256 << 20
+
+// MaxSnapshotQueries caps the number of queries QueryAtSnapshot accepts
+const MaxSnapshotQueries = 50
+
+// SnapshotQueryResult is the result of one query run against a snapshot
+type SnapshotQueryResult struct {
+	Index   int                      `json:"index"` // position in the request, from 0
+	Query   string                   `json:"query"`
+	Columns []string                 `json:"columns,omitempty"`
+	Rows    []map[string]interface{} `json:"rows"`
+	Error   string                   `json:"error,omitempty"`
+}
+
+// SnapshotQueries holds the results of QueryAtSnapshot
+type SnapshotQueries struct {
+	TakenAt string `json:"taken_at"`
+	// SnapshotBytes is the size of the temporary copy the queries ran against
+	SnapshotBytes int64                 `json:"snapshot_bytes"`
+	Queries       []SnapshotQueryResult `json:"queries"`
+	Failed        int                   `json:"failed"`
+}
+
+// QueryAtSnapshot copies the main database with VACUUM INTO, runs every query against the
+// copy, and deletes it, so that all results reflect the same moment however the database
+// changes meanwhile. The copy is refused when the database holds more than maxBytes of
+// pages, DefaultSnapshotBytes when not positive, and goes to the temp directory set with
+// SetTempStorage or else the system one. Queries must be single SELECT statements and only
+// see the main database, not attached ones; a query that fails is reported and the others
+// still run. The copy is removed even when the call fails.
+func (s *SQLiteDB) QueryAtSnapshot(queries []string, maxBytes int64) (*SnapshotQueries, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("no queries given")
+	}
+	if len(queries) > MaxSnapshotQueries {
+		return nil, fmt.Errorf("at most %d queries can run against one snapshot", MaxSnapshotQueries)
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultSnapshotBytes
+	}
+
+	// VACUUM INTO leaves free pages behind, so only pages in use count towards the limit
+	var pageCount, freePages, pageSize int64
+	ctx := s.ctx()
+	for pragma, dest := range map[string]*int64{"page_count": &pageCount, "freelist_count": &freePages, "page_size": &pageSize} {
+		if err := s.db.QueryRowContext(ctx, "PRAGMA "+pragma).Scan(dest); err != nil {
+			return nil, err
+		}
+	}
+	if size := (pageCount - freePages) * pageSize; size > maxBytes {
+		return nil, fmt.Errorf("the database holds %d bytes, more than the snapshot limit of %d bytes", size, maxBytes)
+	}
+
+	s.settingsMu.RLock()
+	tempDir := ""
+	if s.tempDirectory != nil {
+		tempDir = *s.tempDirectory
+	}
+	s.settingsMu.RUnlock()
+	dir, err := os.MkdirTemp(tempDir, "mcp-snapshot-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create snapshot directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "snapshot.db")
+	takenAt := time.Now().UTC()
+	size, err := s.BackupTo(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to take snapshot: %w", err)
+	}
+
+	// The copy gets the server's SQL functions but none of the pool's pragmas or attachments.
+	// Nothing else knows the file, so it is opened immutable, without locking.
+	drv := &sqlite3.SQLiteDriver{ConnectHook: s.registerFunctions}
+	snapshot := sql.OpenDB(&connector{driver: drv, dsn: "file:" + uriEscaper.Replace(path) + "?mode=ro&immutable=1"})
+	defer snapshot.Close()
+	snapshot.SetMaxOpenConns(1)
+
+	result := &SnapshotQueries{
+		TakenAt:       takenAt.Format(time.RFC3339Nano),
+		SnapshotBytes: size,
+		Queries:       make([]SnapshotQueryResult, len(queries)),
+	}
+	for i, query := range queries {
+		entry := &result.Queries[i]
+		entry.Index = i
+		entry.Query = query
+		entry.Rows = []map[string]interface{}{}
+		if err := s.CheckReadQuery(query); err != nil {
+			entry.Error = err.Error()
+			result.Failed++
+			continue
+		}
+		columns, rows, err := s.snapshotQuery(snapshot, query)
+		if err != nil {
+			entry.Error = err.Error()
+			result.Failed++
+			continue
+		}
+		entry.Columns = columns
+		if rows != nil {
+			entry.Rows = rows
+		}
+	}
+	return result, nil
+}
+
+// snapshotQuery runs query on the snapshot with the statement timeout and result budget of
+// the pool
+func (s *SQLiteDB) snapshotQuery(snapshot *sql.DB, query string) ([]string, []map[string]interface{}, error) {
+	ctx, cancel := s.statementContext(context.Background())
+	defer cancel()
+
+	rows, err := snapshot.QueryContext(ctx, strings.TrimSuffix(strings.TrimSpace(query), ";"))
+	if err != nil {
+		return nil, nil, timeoutError(ctx, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	results, err := scanRows(rows, s.resultBudget())
+	return columns, results, timeoutError(ctx, err)
+}
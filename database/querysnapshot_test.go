@@ -0,0 +1,96 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestQueryAtSnapshotStableUnderWrites(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (id INTEGER PRIMARY KEY, v TEXT)")
+	for i := 0; i < 100; i++ {
+		if _, err := db.ExecuteStatement("INSERT INTO t (v) VALUES ('seed')"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tempDir := t.TempDir()
+	if err := db.SetTempStorage("", tempDir); err != nil {
+		t.Fatal(err)
+	}
+
+	// Insert rows for as long as the snapshot queries run
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				db.ExecuteStatement("INSERT INTO t (v) VALUES ('live')")
+			}
+		}
+	}()
+
+	queries := make([]string, MaxSnapshotQueries-1)
+	for i := range queries {
+		queries[i] = "SELECT COUNT(*) AS n, MAX(id) AS m FROM t"
+	}
+	queries = append(queries, "DELETE FROM t")
+	result, err := db.QueryAtSnapshot(queries, 0)
+	close(stop)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.Failed != 1 || result.Queries[len(queries)-1].Error == "" {
+		t.Fatalf("got %d failed queries, want the DELETE only", result.Failed)
+	}
+	first := result.Queries[0].Rows
+	if len(first) != 1 {
+		t.Fatalf("got %d rows", len(first))
+	}
+	for _, entry := range result.Queries[:len(queries)-1] {
+		if entry.Error != "" {
+			t.Fatalf("query %d failed: %s", entry.Index, entry.Error)
+		}
+		if fmt.Sprint(entry.Rows) != fmt.Sprint(first) {
+			t.Fatalf("query %d saw %v, query 0 saw %v", entry.Index, entry.Rows, first)
+		}
+	}
+	if n := toInt64(first[0]["n"]); n < 100 || n != toInt64(first[0]["m"]) {
+		t.Fatalf("snapshot saw %v", first[0])
+	}
+
+	// The writes went on, and the copy is gone
+	if live := queryInt(t, db, "SELECT COUNT(*) FROM t"); live < toInt64(first[0]["n"]) {
+		t.Fatalf("live table has %d rows, fewer than the snapshot", live)
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("snapshot left %d entries behind", len(entries))
+	}
+}
+
+func TestQueryAtSnapshotLimits(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (v TEXT)", "INSERT INTO t VALUES (zeroblob(100000))")
+
+	if _, err := db.QueryAtSnapshot(nil, 0); err == nil {
+		t.Fatal("no queries accepted")
+	}
+	if _, err := db.QueryAtSnapshot(make([]string, MaxSnapshotQueries+1), 0); err == nil {
+		t.Fatal("too many queries accepted")
+	}
+	_, err := db.QueryAtSnapshot([]string{"SELECT 1"}, 4096)
+	if err == nil || !strings.Contains(err.Error(), "snapshot limit") {
+		t.Fatalf("got %v, want the snapshot limit", err)
+	}
+}
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// isRecoverable reports whether err means the connection pool itself is unusable, so that
+// reopening it may let the operation succeed
+func isRecoverable(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, sql.ErrConnDone) || errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	// database/sql does not export the error returned after Close
+	if strings.Contains(err.Error(), "sql: database is closed") {
+		return true
+	}
+	// SQLITE_NOTADB can follow a transient bad read of the file header
+	var sqliteErr sqlite3.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrNotADB
+}
+
+// withReconnect runs op and, if it fails with a recoverable error, reopens the connection
+// pool once and runs op again. op must be safe to repeat after a failed attempt.
+func (s *SQLiteDB) withReconnect(op func() error) error {
+	err := op()
+	if !isRecoverable(err) {
+		return err
+	}
+
+	if reopenErr := s.reopen(); reopenErr != nil {
+		return fmt.Errorf("connection to '%s' lost (%v) and reconnecting failed: %w", s.dbPath, err, reopenErr)
+	}
+
+	if retryErr := op(); retryErr != nil {
+		return fmt.Errorf("failed after reconnecting to '%s': %w", s.dbPath, retryErr)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package database
+
+import (
+	"database/sql/driver"
+	"errors"
+	"testing"
+)
+
+func TestReconnectAfterClosedPool(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE t (id INTEGER)", "INSERT INTO t VALUES (1)")
+
+	// The pool closed under the server, as after a driver failure
+	db.db.Close()
+	rows, err := db.ExecuteQuery("SELECT id FROM t")
+	if err != nil {
+		t.Fatalf("query after the pool closed: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %v", rows)
+	}
+	if _, err := db.ExecuteStatement("INSERT INTO t VALUES (2)"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWithReconnectRetriesOnce(t *testing.T) {
+	db := newTestDB(t)
+
+	// A transient bad connection succeeds on the retry
+	attempts := 0
+	err := db.withReconnect(func() error {
+		attempts++
+		if attempts == 1 {
+			return driver.ErrBadConn
+		}
+		return nil
+	})
+	if err != nil || attempts != 2 {
+		t.Fatalf("got %v after %d attempts, want success after 2", err, attempts)
+	}
+
+	// Other errors are not retried
+	attempts = 0
+	failure := errors.New("no such table: x")
+	if err := db.withReconnect(func() error { attempts++; return failure }); err != failure || attempts != 1 {
+		t.Fatalf("got %v after %d attempts", err, attempts)
+	}
+
+	// A connection that stays bad fails after one retry
+	attempts = 0
+	if err := db.withReconnect(func() error { attempts++; return driver.ErrBadConn }); err == nil || attempts != 2 {
+		t.Fatalf("got %v after %d attempts", err, attempts)
+	}
+}
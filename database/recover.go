@@ -0,0 +1,323 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// recoverBatchSize is the number of rows RecoverTo copies per statement while the table reads cleanly
+const recoverBatchSize = 1000
+
+// maxRecoveryErrors caps the error messages kept per table; the rest are only counted
+const maxRecoveryErrors = 20
+
+// RecoveredTable reports how much of one table RecoverTo could copy
+type RecoveredTable struct {
+	Name string `json:"name"`
+	Rows int64  `json:"rows"`
+	// Complete is false when some rows could not be read from the source
+	Complete bool     `json:"complete"`
+	Errors   []string `json:"errors,omitempty"`
+	// MoreErrors counts errors beyond those listed in Errors
+	MoreErrors int `json:"more_errors,omitempty"`
+}
+
+// RecoveryResult reports the outcome of RecoverTo
+type RecoveryResult struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// SourceCheck holds the messages of PRAGMA quick_check on the source, ["ok"] when it is healthy
+	SourceCheck []string         `json:"source_check"`
+	Tables      []RecoveredTable `json:"tables"`
+	// Failed lists schema objects that could not be recreated, with the reason
+	Failed []string `json:"failed_objects,omitempty"`
+	// Skipped lists schema objects that were left out on purpose
+	Skipped []string `json:"skipped_objects,omitempty"`
+	// DestinationCheck holds the messages of PRAGMA integrity_check on the recovered database
+	DestinationCheck []string `json:"destination_check"`
+}
+
+// schemaObject is one row of sqlite_master
+type schemaObject struct {
+	kind    string
+	name    string
+	table   string
+	sql     string
+	columns []string
+}
+
+// RecoverTo salvages what can still be read from the current database into a new database
+// file at path, in the spirit of a dump and reload: the schema is recreated, every table is
+// copied row range by row range, and ranges that cannot be read are skipped and reported
+// instead of aborting the copy. Indexes, views, and triggers are created after the data.
+// Virtual tables and their shadow tables are skipped. path must not exist.
+func (s *SQLiteDB) RecoverTo(path string) (result *RecoveryResult, err error) {
+	source := s.GetCurrentDatabasePath()
+	if _, err := os.Stat(source); err != nil {
+		return nil, fmt.Errorf("cannot recover '%s': %w", source, err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("file '%s' already exists", path)
+	}
+
+	ctx := s.ctx()
+	result = &RecoveryResult{Source: source, Destination: path}
+	result.SourceCheck = checkMessages(ctx, s.db, "PRAGMA quick_check")
+
+	dest, err := sql.Open("sqlite3", "file:"+uriEscaper.Replace(path))
+	if err != nil {
+		return nil, err
+	}
+	dest.SetMaxOpenConns(1)
+	defer func() {
+		dest.Close()
+		if err != nil {
+			os.Remove(path)
+		}
+	}()
+
+	conn, err := dest.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create '%s': %w", path, err)
+	}
+	defer conn.Close()
+
+	// The source is attached read-only so that nothing in the recovery can write to it
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS src", "file:"+uriEscaper.Replace(source)+"?mode=ro"); err != nil {
+		return nil, fmt.Errorf("failed to attach the source database: %w", err)
+	}
+
+	objects, err := readSchemaObjects(ctx, conn)
+	if err != nil {
+		return nil, fmt.Errorf("the schema cannot be read, nothing can be recovered: %w", err)
+	}
+
+	var virtualTables []string
+	var tables, deferred []*schemaObject
+	for _, obj := range objects {
+		switch {
+		case obj.kind == "table" && virtualTablePattern.MatchString(obj.sql):
+			virtualTables = append(virtualTables, obj.name)
+			result.Skipped = append(result.Skipped, fmt.Sprintf("virtual table %s: recreate and repopulate it manually", obj.name))
+		case obj.kind == "table":
+			tables = append(tables, obj)
+		default:
+			deferred = append(deferred, obj)
+		}
+	}
+
+	for _, table := range tables {
+		if shadow := shadowTableOf(table.name, virtualTables); shadow != "" {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("table %s: shadow table of virtual table %s", table.name, shadow))
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, table.sql); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("table %s: %v", table.name, err))
+			continue
+		}
+		result.Tables = append(result.Tables, recoverTable(ctx, conn, table))
+	}
+
+	// AUTOINCREMENT counters, so recovered tables do not reuse rowids of lost rows. The
+	// copy has already filled in counters for the rows it inserted; they are replaced.
+	if _, err := conn.ExecContext(ctx, "DELETE FROM main.sqlite_sequence"); err == nil {
+		if _, err := conn.ExecContext(ctx, `
+			INSERT INTO main.sqlite_sequence(name, seq)
+			SELECT name, seq FROM src.sqlite_sequence WHERE name IN (SELECT name FROM main.sqlite_master WHERE type='table')
+		`); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("sqlite_sequence: %v", err))
+		}
+	}
+
+	if _, err := conn.ExecContext(ctx, "DETACH DATABASE src"); err != nil {
+		return nil, err
+	}
+
+	for _, obj := range deferred {
+		if shadowTableOf(obj.table, virtualTables) != "" {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, obj.sql); err != nil {
+			result.Failed = append(result.Failed, fmt.Sprintf("%s %s: %v", obj.kind, obj.name, err))
+		}
+	}
+
+	result.DestinationCheck = checkMessages(ctx, conn, "PRAGMA integrity_check")
+	return result, nil
+}
+
+// queryer is implemented by both *sql.DB and *sql.Conn
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// checkMessages runs an integrity check pragma and returns its messages. A check that
+// fails outright, as it can on a badly damaged file, is reported as a message too.
+func checkMessages(ctx context.Context, q queryer, pragma string) []string {
+	rows, err := q.QueryContext(ctx, pragma)
+	if err != nil {
+		return []string{fmt.Sprintf("%s failed: %v", pragma, err)}
+	}
+	defer rows.Close()
+
+	var messages []string
+	for rows.Next() {
+		var message string
+		if err := rows.Scan(&message); err != nil {
+			return append(messages, fmt.Sprintf("%s failed: %v", pragma, err))
+		}
+		messages = append(messages, message)
+	}
+	if err := rows.Err(); err != nil {
+		messages = append(messages, fmt.Sprintf("%s failed: %v", pragma, err))
+	}
+	return messages
+}
+
+// readSchemaObjects reads the user objects of the attached source in creation order
+func readSchemaObjects(ctx context.Context, conn *sql.Conn) ([]*schemaObject, error) {
+	rows, err := conn.QueryContext(ctx, `
+		SELECT type, name, tbl_name, sql FROM src.sqlite_master
+		WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+		ORDER BY rowid
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var objects []*schemaObject
+	for rows.Next() {
+		obj := &schemaObject{}
+		if err := rows.Scan(&obj.kind, &obj.name, &obj.table, &obj.sql); err != nil {
+			return nil, err
+		}
+		objects = append(objects, obj)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, obj := range objects {
+		if obj.kind != "table" || virtualTablePattern.MatchString(obj.sql) {
+			continue
+		}
+		// Generated columns are computed and cannot be inserted
+		cols, err := conn.QueryContext(ctx, "SELECT name FROM pragma_table_xinfo(?, 'src') WHERE hidden = 0", obj.name)
+		if err != nil {
+			return nil, err
+		}
+		for cols.Next() {
+			var name string
+			if err := cols.Scan(&name); err != nil {
+				cols.Close()
+				return nil, err
+			}
+			obj.columns = append(obj.columns, quoteIdentifier(name))
+		}
+		cols.Close()
+	}
+	return objects, nil
+}
+
+// shadowTableOf returns the virtual table that owns table, or "" when it has none
+func shadowTableOf(table string, virtualTables []string) string {
+	for _, vt := range virtualTables {
+		if len(table) > len(vt) && strings.EqualFold(table[:len(vt)+1], vt+"_") {
+			return vt
+		}
+	}
+	return ""
+}
+
+// recoverTable copies the readable rows of one table from src into main
+func recoverTable(ctx context.Context, conn *sql.Conn, table *schemaObject) RecoveredTable {
+	recovered := RecoveredTable{Name: table.name, Complete: true}
+	fail := func(err error) {
+		recovered.Complete = false
+		if len(recovered.Errors) < maxRecoveryErrors {
+			recovered.Errors = append(recovered.Errors, err.Error())
+		} else {
+			recovered.MoreErrors++
+		}
+	}
+
+	columns := strings.Join(table.columns, ", ")
+	name := quoteIdentifier(table.name)
+
+	if withoutRowidPattern.MatchString(table.sql) {
+		// Without a rowid there is no key to resume from, so the table is copied in one go
+		res, err := conn.ExecContext(ctx, fmt.Sprintf("INSERT INTO main.%s (%s) SELECT %s FROM src.%s", name, columns, columns, name))
+		if err != nil {
+			fail(err)
+			return recovered
+		}
+		recovered.Rows, _ = res.RowsAffected()
+		return recovered
+	}
+
+	copyRows := func(from int64, limit int) (int64, error) {
+		res, err := conn.ExecContext(ctx, fmt.Sprintf(
+			"INSERT INTO main.%s (rowid, %s) SELECT rowid, %s FROM src.%s WHERE rowid >= ? ORDER BY rowid LIMIT %d",
+			name, columns, columns, name, limit), from)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+
+	// Rows are copied in rowid order starting at next. When a batch cannot be read the
+	// rows are retried one at a time, and a row that cannot be read is skipped by probing
+	// ever larger rowid steps until reading succeeds again.
+	next := int64(math.MinInt64)
+	step := int64(1)
+	single := false
+	for {
+		if err := ctx.Err(); err != nil {
+			fail(err)
+			return recovered
+		}
+
+		limit := recoverBatchSize
+		if single {
+			limit = 1
+		}
+		n, err := copyRows(next, limit)
+		if err != nil {
+			if !single {
+				single = true
+				continue
+			}
+			fail(fmt.Errorf("rows from rowid %d: %w", next, err))
+			if next > math.MaxInt64-step {
+				return recovered
+			}
+			next += step
+			step *= 2
+			if step < 0 {
+				step = math.MaxInt64
+			}
+			continue
+		}
+		if n == 0 {
+			return recovered
+		}
+		recovered.Rows += n
+		step = 1
+		single = false
+
+		var last int64
+		if err := conn.QueryRowContext(ctx, fmt.Sprintf("SELECT max(rowid) FROM main.%s", name)).Scan(&last); err != nil {
+			fail(err)
+			return recovered
+		}
+		if last == math.MaxInt64 {
+			return recovered
+		}
+		next = last + 1
+	}
+}
@@ -0,0 +1,131 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecoverHealthyDatabase(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY AUTOINCREMENT, name TEXT NOT NULL)",
+		"CREATE INDEX idx_users_name ON users (name)",
+		"CREATE TABLE tags (name TEXT PRIMARY KEY, uses INTEGER) WITHOUT ROWID",
+		"CREATE TABLE log (user_id INTEGER)",
+		"CREATE VIEW named AS SELECT name FROM users",
+		"CREATE TRIGGER users_log AFTER INSERT ON users BEGIN INSERT INTO log VALUES (NEW.id); END",
+		"INSERT INTO users (name) VALUES ('ann'), ('bo'), ('cy')",
+		"DELETE FROM users WHERE name = 'cy'",
+		"INSERT INTO tags VALUES ('a', 1), ('b', 2)",
+	)
+	path := filepath.Join(t.TempDir(), "recovered.db")
+
+	result, err := db.RecoverTo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(result.SourceCheck, ",") != "ok" || strings.Join(result.DestinationCheck, ",") != "ok" {
+		t.Fatalf("checks %v and %v", result.SourceCheck, result.DestinationCheck)
+	}
+	if len(result.Failed) != 0 {
+		t.Fatalf("failed objects %v", result.Failed)
+	}
+	rows := make(map[string]int64)
+	for _, table := range result.Tables {
+		if !table.Complete {
+			t.Errorf("table %s incomplete: %v", table.Name, table.Errors)
+		}
+		rows[table.Name] = table.Rows
+	}
+	if rows["users"] != 2 || rows["tags"] != 2 || rows["log"] != 3 {
+		t.Fatalf("recovered rows %v", rows)
+	}
+
+	recovered, err := NewSQLiteDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.Close()
+	for _, name := range []string{"idx_users_name", "named", "users_log"} {
+		if queryInt(t, recovered, "SELECT COUNT(*) FROM sqlite_master WHERE name = ?", name) != 1 {
+			t.Errorf("%s was not recreated", name)
+		}
+	}
+	// The AUTOINCREMENT counter is kept, so the deleted row's id is not reused
+	if _, err := recovered.ExecuteStatement("INSERT INTO users (name) VALUES ('di')"); err != nil {
+		t.Fatal(err)
+	}
+	if id := queryInt(t, recovered, "SELECT id FROM users WHERE name = 'di'"); id != 4 {
+		t.Fatalf("new row got id %d, want 4", id)
+	}
+
+	if _, err := db.RecoverTo(path); err == nil {
+		t.Fatal("overwrote an existing file")
+	}
+}
+
+func TestRecoverDamagedDatabase(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "damaged.db")
+	db, err := NewSQLiteDB(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"PRAGMA journal_mode = DELETE",
+		"CREATE TABLE small (id INTEGER PRIMARY KEY, v TEXT)",
+		"INSERT INTO small VALUES (1, 'one'), (2, 'two')",
+		"CREATE TABLE big (id INTEGER PRIMARY KEY, v TEXT)",
+		"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 5000) INSERT INTO big SELECT i, printf('%0200d', i) FROM n",
+	} {
+		if _, err := db.ExecuteStatement(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pageSize := queryInt(t, db, "PRAGMA page_size")
+	pageCount := queryInt(t, db, "PRAGMA page_count")
+	db.Close()
+
+	// Overwrite a leaf page of big in the middle of the table
+	file, err := os.OpenFile(source, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	garbage := []byte(strings.Repeat("\xff", int(pageSize)))
+	if _, err := file.WriteAt(garbage, (pageCount/2)*pageSize); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	db, err = NewSQLiteDB(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	result, err := db.RecoverTo(filepath.Join(dir, "recovered.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(result.SourceCheck, ",") == "ok" {
+		t.Fatal("the damage went unnoticed")
+	}
+	if strings.Join(result.DestinationCheck, ",") != "ok" {
+		t.Fatalf("recovered database check %v", result.DestinationCheck)
+	}
+	tables := make(map[string]RecoveredTable)
+	for _, table := range result.Tables {
+		tables[table.Name] = table
+	}
+	if small := tables["small"]; !small.Complete || small.Rows != 2 {
+		t.Fatalf("small recovered as %+v", small)
+	}
+	big := tables["big"]
+	if big.Complete || len(big.Errors) == 0 {
+		t.Fatalf("big reported complete: %+v", big)
+	}
+	// Only the rows of the damaged page are lost
+	if big.Rows < 4900 || big.Rows >= 5000 {
+		t.Fatalf("recovered %d of 5000 rows of big", big.Rows)
+	}
+}
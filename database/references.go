@@ -0,0 +1,235 @@
+package database
+
+import (
+	"sort"
+	"strings"
+)
+
+// definitionScope is what the names in the definition of a view or trigger can refer to
+type definitionScope struct {
+	tokens []sqlToken
+	// tables are the lower-case names of the tables the definition reads or writes; a FROM
+	// item that is not a plain table, such as a subquery, adds an empty name
+	tables map[string]bool
+	// aliases maps each lower-case name a column can be qualified with to its table
+	aliases map[string]string
+	// tableTokens are the indexes of the tokens naming a table, see tableReferences
+	tableTokens []int
+	// triggerTable is the table a trigger is on
+	triggerTable string
+	// columns are the indexes of the tokens of the column lists of UPDATE OF in a trigger
+	// and of INSERT, by lower-case table name
+	columns map[string][]int
+	// body is the index of the first token after the header of the definition
+	body int
+}
+
+// parseDefinition collects the tables named in a CREATE VIEW or CREATE TRIGGER statement
+// after FROM, JOIN, UPDATE, INTO, and, for a trigger, ON, with their aliases. NEW and OLD
+// alias the table of a trigger.
+func parseDefinition(definition string) *definitionScope {
+	scope := &definitionScope{tokens: tokenizeSQL(definition), tables: make(map[string]bool),
+		aliases: make(map[string]string), columns: make(map[string][]int)}
+	tokens := scope.tokens
+	keyword := func(i int) string {
+		if i < len(tokens) && tokens[i].kind == 'i' && !tokens[i].quoted {
+			return strings.ToUpper(tokens[i].text)
+		}
+		return ""
+	}
+	operator := func(i int, op string) bool {
+		return i < len(tokens) && tokens[i].kind == 'o' && tokens[i].text == op
+	}
+
+	trigger := keyword(1) == "TRIGGER" || keyword(2) == "TRIGGER"
+	for i := range tokens {
+		if word := keyword(i); (!trigger && word == "AS") || (trigger && word == "BEGIN") {
+			scope.body = i + 1
+			break
+		}
+	}
+	if trigger {
+		var updateOf []int
+		for i := 0; i < scope.body; i++ {
+			switch keyword(i) {
+			case "OF":
+				for j := i + 1; j < scope.body && keyword(j) != "ON"; j++ {
+					if tokens[j].kind == 'i' {
+						updateOf = append(updateOf, j)
+					}
+				}
+			case "ON":
+				j := i + 1
+				if operator(j+1, ".") {
+					j += 2
+				}
+				if j < scope.body && tokens[j].kind == 'i' {
+					scope.triggerTable = strings.ToLower(tokens[j].text)
+					scope.tables[scope.triggerTable] = true
+					scope.aliases["new"] = scope.triggerTable
+					scope.aliases["old"] = scope.triggerTable
+					scope.tableTokens = append(scope.tableTokens, j)
+					scope.columns[scope.triggerTable] = updateOf
+				}
+			}
+		}
+	}
+
+	for i := scope.body; i < len(tokens); i++ {
+		word := keyword(i)
+		if word != "FROM" && word != "JOIN" && word != "UPDATE" && word != "INTO" {
+			continue
+		}
+		j := i + 1
+		if word == "UPDATE" && keyword(j) == "OR" {
+			j += 2
+		}
+		for {
+			if j >= len(tokens) || tokens[j].kind != 'i' || (word != "INTO" && operator(j+1, "(")) {
+				// A subquery or table-valued function
+				scope.tables[""] = true
+				break
+			}
+			if operator(j+1, ".") && j+2 < len(tokens) && tokens[j+2].kind == 'i' {
+				j += 2
+			}
+			name := strings.ToLower(tokens[j].text)
+			scope.tables[name] = true
+			scope.aliases[name] = name
+			scope.tableTokens = append(scope.tableTokens, j)
+			j++
+			if word == "INTO" && operator(j, "(") {
+				for j++; j < len(tokens) && !operator(j, ")"); j++ {
+					if tokens[j].kind == 'i' {
+						scope.columns[name] = append(scope.columns[name], j)
+					}
+				}
+				break
+			}
+			if keyword(j) == "AS" {
+				j++
+			}
+			if j < len(tokens) && tokens[j].kind == 'i' && (tokens[j].quoted || !sqliteKeywords[strings.ToUpper(tokens[j].text)]) {
+				scope.aliases[strings.ToLower(tokens[j].text)] = name
+				j++
+			}
+			// Only a FROM clause lists tables separated by commas
+			if word != "FROM" || !operator(j, ",") {
+				break
+			}
+			j++
+		}
+	}
+	return scope
+}
+
+// isOnly reports whether table is the only table the definition reads or writes, so that
+// an unqualified column can only belong to it
+func (scope *definitionScope) isOnly(table string) bool {
+	return len(scope.tables) == 1 && scope.tables[strings.ToLower(table)]
+}
+
+// columnReferences returns the tokens referring to column of table: qualified with the
+// table's name or alias, or NEW or OLD in a trigger on it, in a column list of the table, or
+// unqualified where table is the only table in the definition. Names that could refer to
+// another table are left out.
+func (scope *definitionScope) columnReferences(table, column string) []sqlToken {
+	table = strings.ToLower(table)
+	tokens := scope.tokens
+	var refs []sqlToken
+	listed := make(map[int]bool)
+	for _, indexes := range scope.columns {
+		for _, i := range indexes {
+			listed[i] = true
+		}
+	}
+	for _, i := range scope.columns[table] {
+		if strings.EqualFold(tokens[i].text, column) {
+			refs = append(refs, tokens[i])
+		}
+	}
+	isOperator := func(i int, op string) bool {
+		return i >= 0 && i < len(tokens) && tokens[i].kind == 'o' && tokens[i].text == op
+	}
+	for i := scope.body; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.kind != 'i' || !strings.EqualFold(tok.text, column) || isOperator(i+1, ".") || isOperator(i+1, "(") {
+			continue
+		}
+		if isOperator(i-1, ".") {
+			if i >= 2 && scope.aliases[strings.ToLower(tokens[i-2].text)] == table {
+				refs = append(refs, tok)
+			}
+			continue
+		}
+		if !scope.isOnly(table) || scope.isTableToken(i) || listed[i] {
+			continue
+		}
+		// The name an expression is given, not a column
+		if i > 0 && tokens[i-1].kind == 'i' && strings.EqualFold(tokens[i-1].text, "AS") {
+			continue
+		}
+		refs = append(refs, tok)
+	}
+	return refs
+}
+
+// tableReferences returns the tokens naming table, where a table is expected or as the
+// qualifier of a column. A qualifier is left out where table is also an alias of another
+// table.
+func (scope *definitionScope) tableReferences(table string) []sqlToken {
+	table = strings.ToLower(table)
+	tokens := scope.tokens
+	var refs []sqlToken
+	for _, i := range scope.tableTokens {
+		if strings.ToLower(tokens[i].text) == table {
+			refs = append(refs, tokens[i])
+		}
+	}
+	if scope.aliases[table] != table {
+		return refs
+	}
+	for i := scope.body; i+1 < len(tokens); i++ {
+		tok := tokens[i]
+		if tok.kind != 'i' || strings.ToLower(tok.text) != table || scope.isTableToken(i) {
+			continue
+		}
+		if tokens[i+1].kind == 'o' && tokens[i+1].text == "." && (i == 0 || tokens[i-1].text != ".") {
+			refs = append(refs, tok)
+		}
+	}
+	return refs
+}
+
+// isTableToken reports whether token i names a table
+func (scope *definitionScope) isTableToken(i int) bool {
+	for _, j := range scope.tableTokens {
+		if i == j {
+			return true
+		}
+	}
+	return false
+}
+
+// replaceTokens replaces refs, tokens of definition, with name, quoted where the token was
+// quoted or the name needs it
+func replaceTokens(definition string, refs []sqlToken, name string) string {
+	sort.Slice(refs, func(i, j int) bool { return refs[i].start < refs[j].start })
+	runes := []rune(definition)
+	var b strings.Builder
+	last := 0
+	for _, ref := range refs {
+		if ref.start < last {
+			continue
+		}
+		b.WriteString(string(runes[last:ref.start]))
+		if ref.quoted || !isSimpleIdentifier(name) || sqliteKeywords[strings.ToUpper(name)] {
+			b.WriteString(quoteIdentifier(name))
+		} else {
+			b.WriteString(name)
+		}
+		last = ref.end
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// RowidInfo describes how the rows of a table are addressed
+type RowidInfo struct {
+	Table        string `json:"table"`
+	WithoutRowid bool   `json:"without_rowid"`
+	// AliasColumn is the INTEGER PRIMARY KEY column that aliases rowid, if any
+	AliasColumn string `json:"alias_column,omitempty"`
+	// RowidColumn is the column to use for keyset pagination and row updates; empty for
+	// WITHOUT ROWID tables, which must be addressed by their primary key
+	RowidColumn string   `json:"rowid_column,omitempty"`
+	PrimaryKey  []string `json:"primary_key,omitempty"`
+}
+
+// GetRowidColumn reports whether a table has a rowid and which column refers to it: the
+// INTEGER PRIMARY KEY column when one aliases rowid, otherwise one of the built-in names
+// rowid, _rowid_, or oid that is not shadowed by a real column
+func (s *SQLiteDB) GetRowidColumn(tableName string) (*RowidInfo, error) {
+	var tableSQL string
+	err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	columns, err := s.GetTableSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &RowidInfo{
+		Table:        tableName,
+		WithoutRowid: withoutRowidPattern.MatchString(tableSQL),
+	}
+
+	names := make(map[string]bool)
+	var pkColumns []map[string]interface{}
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		names[strings.ToLower(name)] = true
+		if toInt64(col["pk"]) > 0 {
+			pkColumns = append(pkColumns, col)
+		}
+	}
+	// pk holds each column's position within the primary key
+	sort.SliceStable(pkColumns, func(i, j int) bool {
+		return toInt64(pkColumns[i]["pk"]) < toInt64(pkColumns[j]["pk"])
+	})
+	for _, col := range pkColumns {
+		name, _ := col["name"].(string)
+		info.PrimaryKey = append(info.PrimaryKey, name)
+	}
+
+	if info.WithoutRowid {
+		return info, nil
+	}
+
+	// Only a single-column primary key declared exactly as INTEGER aliases rowid
+	if len(pkColumns) == 1 {
+		colType, _ := pkColumns[0]["type"].(string)
+		if strings.EqualFold(strings.TrimSpace(colType), "INTEGER") {
+			info.AliasColumn, _ = pkColumns[0]["name"].(string)
+			info.RowidColumn = info.AliasColumn
+			return info, nil
+		}
+	}
+
+	for _, name := range []string{"rowid", "_rowid_", "oid"} {
+		if !names[name] {
+			info.RowidColumn = name
+			break
+		}
+	}
+
+	return info, nil
+}
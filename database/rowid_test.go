@@ -0,0 +1,41 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetRowidColumn(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE aliased (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE plain (name TEXT)",
+		// INT is not INTEGER, so id does not alias rowid
+		"CREATE TABLE int_key (id INT PRIMARY KEY, name TEXT)",
+		// A real column shadows the built-in name rowid
+		"CREATE TABLE shadowed (rowid TEXT, name TEXT)",
+		"CREATE TABLE kv (k TEXT, n INTEGER, v TEXT, PRIMARY KEY (k, n)) WITHOUT ROWID",
+	)
+
+	tests := []struct {
+		table string
+		want  RowidInfo
+	}{
+		{"aliased", RowidInfo{Table: "aliased", AliasColumn: "id", RowidColumn: "id", PrimaryKey: []string{"id"}}},
+		{"plain", RowidInfo{Table: "plain", RowidColumn: "rowid"}},
+		{"int_key", RowidInfo{Table: "int_key", RowidColumn: "rowid", PrimaryKey: []string{"id"}}},
+		{"shadowed", RowidInfo{Table: "shadowed", RowidColumn: "_rowid_"}},
+		{"kv", RowidInfo{Table: "kv", WithoutRowid: true, PrimaryKey: []string{"k", "n"}}},
+	}
+	for _, test := range tests {
+		info, err := db.GetRowidColumn(test.table)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(*info, test.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.table, *info, test.want)
+		}
+	}
+	if _, err := db.GetRowidColumn("missing"); err == nil {
+		t.Error("no error for a missing table")
+	}
+}
@@ -0,0 +1,33 @@
+package database
+
+import "testing"
+
+func TestGetAnnotatedSchema(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INTEGER REFERENCES customers(id), placed TEXT NOT NULL DEFAULT '', note TEXT)",
+		"CREATE INDEX idx_orders_placed ON orders (placed)",
+	)
+
+	columns, err := db.GetAnnotatedSchema("orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]map[string]bool{
+		"id":          {"is_primary_key": true, "is_foreign_key": false, "is_indexed": true, "has_default": false},
+		"customer_id": {"is_primary_key": false, "is_foreign_key": true, "is_indexed": false, "has_default": false},
+		"placed":      {"is_primary_key": false, "is_foreign_key": false, "is_indexed": true, "is_not_null": true, "has_default": true},
+		"note":        {"is_primary_key": false, "is_foreign_key": false, "is_indexed": false, "is_not_null": false},
+	}
+	if len(columns) != len(want) {
+		t.Fatalf("got %d columns, want %d", len(columns), len(want))
+	}
+	for _, col := range columns {
+		name := col["name"].(string)
+		for flag, value := range want[name] {
+			if col[flag] != value {
+				t.Errorf("%s.%s = %v, want %v", name, flag, col[flag], value)
+			}
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// scratchDatabases maps the lowercase alias of each scratch database to the connection that
+// keeps it alive
+type scratchDatabases map[string]driver.Conn
+
+// scratchCounter numbers scratch databases so that their in-memory names never collide
+var scratchCounter atomic.Int64
+
+// CreateScratch attaches a new, empty in-memory database under alias, as scratch space for
+// intermediate tables that can be joined with the main database as alias.table. The
+// database lives in memory under a shared-cache name so that every pooled connection sees
+// the same tables, and one extra connection holds it open until DropScratch or Close, after
+// which its contents are gone. With keepOnSwitch the server keeps it attached, contents
+// included, when switching databases.
+func (s *SQLiteDB) CreateScratch(alias string, keepOnSwitch bool) (*Attachment, error) {
+	uri := fmt.Sprintf("file:mcp-scratch-%d?mode=memory&cache=shared", scratchCounter.Add(1))
+	keeper, err := (&sqlite3.SQLiteDriver{}).Open(uri)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create scratch database: %w", err)
+	}
+
+	attachment := Attachment{Alias: alias, Path: uri, Scratch: true, KeepOnSwitch: keepOnSwitch}
+	s.settingsMu.Lock()
+	if err := s.checkAlias(alias); err != nil {
+		s.settingsMu.Unlock()
+		keeper.Close()
+		return nil, err
+	}
+	previous := s.attached
+	s.attached = append(append([]Attachment(nil), previous...), attachment)
+	if s.scratch == nil {
+		s.scratch = make(scratchDatabases)
+	}
+	s.scratch[strings.ToLower(alias)] = keeper
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.attached = previous
+		delete(s.scratch, strings.ToLower(alias))
+		s.settingsMu.Unlock()
+		keeper.Close()
+		return nil, err
+	}
+	return &attachment, nil
+}
+
+// DropScratch detaches the scratch database attached under alias and discards its contents
+func (s *SQLiteDB) DropScratch(alias string) error {
+	s.settingsMu.Lock()
+	previous := s.attached
+	var remaining []Attachment
+	found := false
+	for _, a := range previous {
+		if !strings.EqualFold(a.Alias, alias) {
+			remaining = append(remaining, a)
+		} else if !a.Scratch {
+			s.settingsMu.Unlock()
+			return fmt.Errorf("'%s' is an attached database file, not a scratch database", a.Alias)
+		} else {
+			found = true
+		}
+	}
+	if !found {
+		s.settingsMu.Unlock()
+		return fmt.Errorf("no scratch database is attached as '%s'", alias)
+	}
+	s.attached = remaining
+	s.settingsMu.Unlock()
+
+	if err := s.reopen(); err != nil {
+		s.settingsMu.Lock()
+		s.attached = previous
+		s.settingsMu.Unlock()
+		return err
+	}
+
+	// No pooled connection uses the database any more, so closing the keeper frees it
+	s.settingsMu.Lock()
+	keeper := s.scratch[strings.ToLower(alias)]
+	delete(s.scratch, strings.ToLower(alias))
+	s.settingsMu.Unlock()
+	if keeper != nil {
+		keeper.Close()
+	}
+	return nil
+}
+
+// ScratchDatabases returns the scratch databases created with CreateScratch
+func (s *SQLiteDB) ScratchDatabases() []Attachment {
+	s.settingsMu.RLock()
+	defer s.settingsMu.RUnlock()
+
+	var scratch []Attachment
+	for _, a := range s.attached {
+		if a.Scratch {
+			scratch = append(scratch, a)
+		}
+	}
+	return scratch
+}
+
+// closeScratch discards every scratch database
+func (s *SQLiteDB) closeScratch() {
+	s.settingsMu.Lock()
+	defer s.settingsMu.Unlock()
+
+	for alias, keeper := range s.scratch {
+		keeper.Close()
+		delete(s.scratch, alias)
+	}
+}
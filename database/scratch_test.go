@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestScratchDatabase(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer TEXT, total REAL)",
+		"INSERT INTO orders VALUES (1, 'ann', 10), (2, 'bo', 20), (3, 'ann', 5)",
+	)
+
+	if _, err := db.CreateScratch("work", false); err != nil {
+		t.Fatal(err)
+	}
+	for _, statement := range []string{
+		"CREATE TABLE work.totals AS SELECT customer, sum(total) AS total FROM orders GROUP BY customer",
+		"INSERT INTO work.totals VALUES ('cy', 0)",
+	} {
+		if _, err := db.ExecuteStatement(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rows, err := db.ExecuteQuery("SELECT o.id, t.total FROM orders o JOIN work.totals t ON t.customer = o.customer ORDER BY o.id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[0]["total"] != 15.0 || rows[1]["total"] != 20.0 {
+		t.Fatalf("join across the scratch database returned %v", rows)
+	}
+
+	// Every pooled connection sees the same scratch tables
+	for i := 0; i < 3; i++ {
+		conn, err := db.db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		var n int
+		if err := conn.QueryRowContext(context.Background(), "SELECT count(*) FROM work.totals").Scan(&n); err != nil || n != 3 {
+			t.Fatalf("connection %d sees %d scratch rows, %v", i, n, err)
+		}
+	}
+	if hasTable(t, db, "totals") {
+		t.Fatal("the scratch table was written to the main database")
+	}
+	if scratch := db.ScratchDatabases(); len(scratch) != 1 || scratch[0].Alias != "work" {
+		t.Fatalf("scratch databases %v", scratch)
+	}
+
+	if _, err := db.CreateScratch("WORK", false); err == nil {
+		t.Fatal("attached a second database under the same alias")
+	}
+	if err := db.DropScratch("work"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecuteQuery("SELECT * FROM work.totals"); err == nil {
+		t.Fatal("the scratch table outlived drop_scratch")
+	}
+	if err := db.DropScratch("work"); err == nil {
+		t.Fatal("dropped a scratch database twice")
+	}
+
+	// A new scratch database under the same alias starts empty
+	if _, err := db.CreateScratch("work", false); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM work.sqlite_master"); n != 0 {
+		t.Fatalf("the new scratch database has %d objects", n)
+	}
+}
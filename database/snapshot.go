@@ -0,0 +1,273 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// snapshotTable holds the stored results of snapshot_query
+const snapshotTable = "_mcp_query_snapshots"
+
+// QuerySnapshot describes a stored query result
+type QuerySnapshot struct {
+	Name      string `json:"name"`
+	Query     string `json:"query"`
+	KeyColumn string `json:"key_column"`
+	Rows      int    `json:"rows"`
+	TakenAt   string `json:"taken_at"`
+}
+
+// ValueChange is the old and new value of a changed column
+type ValueChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// ChangedRow lists the columns whose values differ between the snapshot and now
+type ChangedRow struct {
+	Key     interface{}            `json:"key"`
+	Changes map[string]ValueChange `json:"changes"`
+}
+
+// SnapshotDiff reports how a query result differs from its stored snapshot
+type SnapshotDiff struct {
+	Snapshot  QuerySnapshot            `json:"snapshot"`
+	Added     []map[string]interface{} `json:"added"`
+	Removed   []map[string]interface{} `json:"removed"`
+	Changed   []ChangedRow             `json:"changed"`
+	Unchanged int                      `json:"unchanged"`
+	// Updated is true when the snapshot was replaced by the current result
+	Updated bool `json:"updated"`
+}
+
+// snapshotRows is a query result keyed by the canonical JSON of the key column, each row
+// holding the canonical JSON of its values
+type snapshotRows map[string]map[string]json.RawMessage
+
+// keyedRows runs query and keys its rows by keyColumn, which must be unique
+func (s *SQLiteDB) keyedRows(query, keyColumn string, args []interface{}) (snapshotRows, []string, error) {
+	columns, results, err := s.ExecuteQueryColumns(query, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := false
+	for _, column := range columns {
+		found = found || column == keyColumn
+	}
+	if !found {
+		return nil, nil, fmt.Errorf("key column '%s' is not in the query result; columns: %s", keyColumn, strings.Join(columns, ", "))
+	}
+
+	rows := make(snapshotRows, len(results))
+	for _, result := range results {
+		row := make(map[string]json.RawMessage, len(result))
+		for column, value := range result {
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("column '%s': %w", column, err)
+			}
+			row[column] = encoded
+		}
+		key := string(row[keyColumn])
+		if _, dup := rows[key]; dup {
+			return nil, nil, fmt.Errorf("key column '%s' is not unique: value %s appears more than once", keyColumn, key)
+		}
+		rows[key] = row
+	}
+	return rows, columns, nil
+}
+
+// ensureSnapshotTable creates the snapshot table if needed
+func (s *SQLiteDB) ensureSnapshotTable() error {
+	_, err := s.db.ExecContext(s.ctx(), fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+		name TEXT PRIMARY KEY,
+		query TEXT NOT NULL,
+		params TEXT NOT NULL,
+		key_column TEXT NOT NULL,
+		rows TEXT NOT NULL,
+		taken_at TEXT NOT NULL
+	)`, snapshotTable))
+	return s.diskFullError(err)
+}
+
+// storeSnapshot replaces the named snapshot
+func (s *SQLiteDB) storeSnapshot(snapshot *QuerySnapshot, args []interface{}, rows snapshotRows) error {
+	encodedArgs, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	encodedRows, err := json.Marshal(rows)
+	if err != nil {
+		return err
+	}
+	if err := s.ensureSnapshotTable(); err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(s.ctx(), fmt.Sprintf("INSERT OR REPLACE INTO %s (name, query, params, key_column, rows, taken_at) VALUES (?, ?, ?, ?, ?, ?)", snapshotTable),
+		snapshot.Name, snapshot.Query, string(encodedArgs), snapshot.KeyColumn, string(encodedRows), snapshot.TakenAt)
+	return s.diskFullError(err)
+}
+
+// SnapshotQuery runs query and stores its result under name, replacing an earlier snapshot of
+// that name, so that DiffQuerySnapshot can later report what changed. Rows are matched by
+// keyColumn, which must be unique in the result.
+func (s *SQLiteDB) SnapshotQuery(name, query, keyColumn string, args ...interface{}) (*QuerySnapshot, error) {
+	if name == "" {
+		return nil, fmt.Errorf("snapshot name cannot be empty")
+	}
+	rows, _, err := s.keyedRows(query, keyColumn, args)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &QuerySnapshot{
+		Name:      name,
+		Query:     query,
+		KeyColumn: keyColumn,
+		Rows:      len(rows),
+		TakenAt:   time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := s.storeSnapshot(snapshot, args, rows); err != nil {
+		return nil, fmt.Errorf("failed to store snapshot: %w", err)
+	}
+	return snapshot, nil
+}
+
+// GetQuerySnapshot returns the stored query and parameters of a snapshot
+func (s *SQLiteDB) GetQuerySnapshot(name string) (*QuerySnapshot, []interface{}, error) {
+	snapshot, args, _, err := s.loadSnapshot(name)
+	return snapshot, args, err
+}
+
+// loadSnapshot reads a stored snapshot
+func (s *SQLiteDB) loadSnapshot(name string) (*QuerySnapshot, []interface{}, snapshotRows, error) {
+	snapshot := &QuerySnapshot{Name: name}
+	var encodedArgs, encodedRows string
+	err := s.db.QueryRowContext(s.ctx(), fmt.Sprintf("SELECT query, params, key_column, rows, taken_at FROM %s WHERE name = ?", snapshotTable), name).
+		Scan(&snapshot.Query, &encodedArgs, &snapshot.KeyColumn, &encodedRows, &snapshot.TakenAt)
+	if err == sql.ErrNoRows || (err != nil && strings.Contains(err.Error(), "no such table")) {
+		return nil, nil, nil, fmt.Errorf("no snapshot named '%s'; create one with snapshot_query", name)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	var args []interface{}
+	decoder := json.NewDecoder(strings.NewReader(encodedArgs))
+	decoder.UseNumber()
+	if err := decoder.Decode(&args); err != nil {
+		return nil, nil, nil, fmt.Errorf("snapshot '%s' has invalid parameters: %w", name, err)
+	}
+	// Whole numbers were bound as integers when the snapshot was taken
+	for i, arg := range args {
+		if n, ok := arg.(json.Number); ok {
+			if v, err := n.Int64(); err == nil {
+				args[i] = v
+			} else if f, err := n.Float64(); err == nil {
+				args[i] = f
+			}
+		}
+	}
+
+	var rows snapshotRows
+	if err := json.Unmarshal([]byte(encodedRows), &rows); err != nil {
+		return nil, nil, nil, fmt.Errorf("snapshot '%s' has invalid rows: %w", name, err)
+	}
+	snapshot.Rows = len(rows)
+	return snapshot, args, rows, nil
+}
+
+// decodeValue turns a stored JSON value back into a Go value, keeping numbers exact
+func decodeValue(raw json.RawMessage) interface{} {
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if decoder.Decode(&value) != nil {
+		return string(raw)
+	}
+	return value
+}
+
+// decodeRow turns a stored row back into a row map
+func decodeRow(row map[string]json.RawMessage) map[string]interface{} {
+	decoded := make(map[string]interface{}, len(row))
+	for column, raw := range row {
+		decoded[column] = decodeValue(raw)
+	}
+	return decoded
+}
+
+// DiffQuerySnapshot runs the query of a stored snapshot again and reports the rows added,
+// removed, and changed since the snapshot was taken. With update the snapshot is replaced by
+// the current result, so the next diff starts from now.
+func (s *SQLiteDB) DiffQuerySnapshot(name string, update bool) (*SnapshotDiff, error) {
+	snapshot, args, before, err := s.loadSnapshot(name)
+	if err != nil {
+		return nil, err
+	}
+	after, columns, err := s.keyedRows(snapshot.Query, snapshot.KeyColumn, args)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{
+		Snapshot: *snapshot,
+		Added:    []map[string]interface{}{},
+		Removed:  []map[string]interface{}{},
+		Changed:  []ChangedRow{},
+	}
+
+	// Keys are visited in sorted order so the report is deterministic
+	keys := make([]string, 0, len(before)+len(after))
+	for key := range before {
+		keys = append(keys, key)
+	}
+	for key := range after {
+		if _, ok := before[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		oldRow, hadRow := before[key]
+		newRow, hasRow := after[key]
+		switch {
+		case !hadRow:
+			diff.Added = append(diff.Added, decodeRow(newRow))
+		case !hasRow:
+			diff.Removed = append(diff.Removed, decodeRow(oldRow))
+		default:
+			changes := make(map[string]ValueChange)
+			for _, column := range columns {
+				oldValue, ok := oldRow[column]
+				if !ok {
+					oldValue = json.RawMessage("null")
+				}
+				if !bytes.Equal(oldValue, newRow[column]) {
+					changes[column] = ValueChange{Old: decodeValue(oldValue), New: decodeValue(newRow[column])}
+				}
+			}
+			if len(changes) == 0 {
+				diff.Unchanged++
+			} else {
+				diff.Changed = append(diff.Changed, ChangedRow{Key: decodeValue(json.RawMessage(key)), Changes: changes})
+			}
+		}
+	}
+
+	if update {
+		current := *snapshot
+		current.Rows = len(after)
+		current.TakenAt = time.Now().UTC().Format(time.RFC3339)
+		if err := s.storeSnapshot(&current, args, after); err != nil {
+			return nil, fmt.Errorf("failed to update snapshot: %w", err)
+		}
+		diff.Updated = true
+	}
+	return diff, nil
+}
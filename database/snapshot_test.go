@@ -0,0 +1,89 @@
+package database
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDiffQuerySnapshot(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE stock (sku TEXT PRIMARY KEY, qty INTEGER, price REAL, note TEXT)",
+		"INSERT INTO stock VALUES ('a', 1, 1.5, NULL), ('b', 2, 2.5, 'x'), ('c', 3, 3.5, 'y'), ('d', 4, 4.5, 'z')",
+	)
+
+	snapshot, err := db.SnapshotQuery("stock", "SELECT sku, qty, price, note FROM stock WHERE qty < ?", "sku", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Rows != 4 || snapshot.KeyColumn != "sku" {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+
+	for _, statement := range []string{
+		"UPDATE stock SET qty = 20, note = NULL WHERE sku = 'b'",
+		"UPDATE stock SET price = 3.5 WHERE sku = 'c'", // unchanged value
+		"DELETE FROM stock WHERE sku = 'd'",
+		"INSERT INTO stock VALUES ('e', 5, 5.5, 'new')",
+		"INSERT INTO stock VALUES ('f', 500, 1, 'filtered out')",
+	} {
+		if _, err := db.ExecuteStatement(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	diff, err := db.DiffQuerySnapshot("stock", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, _ := json.Marshal(struct {
+		Added     []map[string]interface{}
+		Removed   []map[string]interface{}
+		Changed   []ChangedRow
+		Unchanged int
+	}{diff.Added, diff.Removed, diff.Changed, diff.Unchanged})
+	want := `{"Added":[{"note":"new","price":5.5,"qty":5,"sku":"e"}],` +
+		`"Removed":[{"note":"z","price":4.5,"qty":4,"sku":"d"}],` +
+		`"Changed":[{"key":"b","changes":{"note":{"old":"x","new":null},"qty":{"old":2,"new":20}}}],` +
+		`"Unchanged":2}`
+	if string(encoded) != want {
+		t.Fatalf("diff\n got %s\nwant %s", encoded, want)
+	}
+	if diff.Updated {
+		t.Fatal("snapshot reported updated")
+	}
+
+	// Without update the same changes are reported again; with it they are taken in
+	if diff, err = db.DiffQuerySnapshot("stock", true); err != nil || len(diff.Changed) != 1 || !diff.Updated {
+		t.Fatalf("second diff %+v, %v", diff, err)
+	}
+	if diff, err = db.DiffQuerySnapshot("stock", false); err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Added)+len(diff.Removed)+len(diff.Changed) != 0 || diff.Unchanged != 4 || diff.Snapshot.Rows != 4 {
+		t.Fatalf("diff after updating %+v", diff)
+	}
+
+	// The snapshot is stored in the database
+	if !hasTable(t, db, snapshotTable) {
+		t.Fatal("no snapshot table")
+	}
+}
+
+func TestSnapshotQueryRejects(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE t (k INTEGER, v TEXT)",
+		"INSERT INTO t VALUES (1, 'a'), (1, 'b')",
+	)
+	if _, err := db.SnapshotQuery("", "SELECT k FROM t", "k"); err == nil {
+		t.Error("accepted an empty name")
+	}
+	if _, err := db.SnapshotQuery("s", "SELECT v FROM t", "k"); err == nil {
+		t.Error("accepted a key column missing from the result")
+	}
+	if _, err := db.SnapshotQuery("s", "SELECT k, v FROM t", "k"); err == nil {
+		t.Error("accepted a key column that is not unique")
+	}
+	if _, err := db.DiffQuerySnapshot("missing", false); err == nil {
+		t.Error("diffed a snapshot that does not exist")
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var (
+	createTriggerPattern = regexp.MustCompile(`(?is)^\s*CREATE\s+(TEMP\s+|TEMPORARY\s+)?TRIGGER\b`)
+	triggerEndPattern    = regexp.MustCompile(`(?is)\bEND\s*$`)
+)
+
+// SplitStatements splits a SQL script into individual statements on top-level semicolons.
+// Semicolons inside string literals, quoted identifiers, comments, and trigger bodies
+// (BEGIN ... END) do not end a statement. Empty statements, including those holding only
+// comments, are dropped and the terminating semicolon is not included.
+func SplitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	flush := func() {
+		stmt := strings.TrimSpace(current.String())
+		if leadingCommentPattern.ReplaceAllString(stmt, "") != "" {
+			statements = append(statements, stmt)
+		}
+		current.Reset()
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			// Quoted literal or identifier; doubled quotes are escapes
+			current.WriteRune(r)
+			for i++; i < len(runes); i++ {
+				current.WriteRune(runes[i])
+				if runes[i] == r {
+					if i+1 < len(runes) && runes[i+1] == r {
+						i++
+						current.WriteRune(runes[i])
+						continue
+					}
+					break
+				}
+			}
+		case r == '[':
+			// Bracket-quoted identifier
+			current.WriteRune(r)
+			for i++; i < len(runes); i++ {
+				current.WriteRune(runes[i])
+				if runes[i] == ']' {
+					break
+				}
+			}
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			// Line comment
+			for ; i < len(runes) && runes[i] != '\n'; i++ {
+				current.WriteRune(runes[i])
+			}
+			if i < len(runes) {
+				current.WriteRune(runes[i])
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			// Block comment
+			current.WriteString("/*")
+			for i += 2; i < len(runes); i++ {
+				current.WriteRune(runes[i])
+				if runes[i] == '*' && i+1 < len(runes) && runes[i+1] == '/' {
+					i++
+					current.WriteRune('/')
+					break
+				}
+			}
+		case r == ';':
+			text := current.String()
+			// Trigger bodies contain semicolons; the statement ends after the closing END
+			if createTriggerPattern.MatchString(text) && !triggerEndPattern.MatchString(text) {
+				current.WriteRune(r)
+				continue
+			}
+			flush()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	flush()
+
+	return statements
+}
+
+// LeadingKeyword returns the first keyword of a statement in upper case, skipping comments,
+// or "" when the statement does not start with one
+func LeadingKeyword(statement string) string {
+	tokens := tokenizeSQL(statement)
+	if len(tokens) == 0 || tokens[0].kind != 'i' || tokens[0].quoted {
+		return ""
+	}
+	return strings.ToUpper(tokens[0].text)
+}
+
+// IsSingleStatement reports whether the SQL text contains at most one statement
+func IsSingleStatement(sqlText string) bool {
+	return len(SplitStatements(sqlText)) <= 1
+}
+
+// HasTopLevelLimit reports whether a query has a LIMIT clause of its own. LIMIT inside
+// subqueries, string literals, quoted identifiers, and comments does not count.
+func HasTopLevelLimit(query string) bool {
+	runes := []rune(query)
+	depth := 0
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\'' || r == '"' || r == '`':
+			// A doubled quote is an escape and simply reopens the literal
+			for i++; i < len(runes) && runes[i] != r; i++ {
+			}
+		case r == '[':
+			for i++; i < len(runes) && runes[i] != ']'; i++ {
+			}
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for ; i < len(runes) && runes[i] != '\n'; i++ {
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			for i += 2; i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/'); i++ {
+			}
+			i++
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '$') {
+				i++
+			}
+			if depth == 0 && strings.EqualFold(string(runes[start:i+1]), "LIMIT") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	tests := []struct {
+		script string
+		want   []string
+	}{
+		{"SELECT 1; SELECT 2;", []string{"SELECT 1", "SELECT 2"}},
+		{"SELECT ';' AS a; SELECT \"x;y\" FROM t", []string{"SELECT ';' AS a", "SELECT \"x;y\" FROM t"}},
+		{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE u SET n = n + 1; DELETE FROM v; END; SELECT 1",
+			[]string{"CREATE TRIGGER tr AFTER INSERT ON t BEGIN UPDATE u SET n = n + 1; DELETE FROM v; END", "SELECT 1"}},
+		// A comment belongs to the statement after it, and one after the last statement is dropped
+		{"-- first\nSELECT 1; /* ; */ SELECT 2; -- done", []string{"-- first\nSELECT 1", "/* ; */ SELECT 2"}},
+		{" ; ;\n-- nothing\n", nil},
+	}
+	for _, test := range tests {
+		if got := SplitStatements(test.script); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q:\n got %q\nwant %q", test.script, got, test.want)
+		}
+	}
+}
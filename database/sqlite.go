@@ -1,57 +1,143 @@
 package database
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
 type SQLiteDB struct {
+	*dbState
+
+	// call is the context of the call the handle was made for, see WithContext
+	call context.Context
+}
+
+// dbState is the state shared by every handle of a database
+type dbState struct {
 	db     *sql.DB
 	dbPath string
+
+	// Connection settings applied to every pooled connection, see connectionPragmas
+	settingsMu       sync.RWMutex
+	journalMode      string        // WAL unless changed with SetJournalMode
+	foreignKeys      bool          // enforce foreign key constraints, see SetForeignKeys
+	maxResultBytes   int64         // approximate memory budget of a query result, see SetMaxResultBytes
+	attached         []Attachment  // databases attached to every connection, see AttachDatabase
+	queryTimeout     time.Duration // 0 means statements run without a timeout, see SetQueryTimeout
+	journalSizeLimit *int64
+	tempStore        *string
+	tempDirectory    *string
+	cacheSize        *int64
+	mmapSize         *int64
+	enabledFunctions map[string]bool // nil enables all registered SQL functions
+	readOnly         bool            // open databases with mode=ro
+	serialized       bool            // a pool of one connection, see SetSerialized
+	busyTimeout      time.Duration   // wait for locks held by other connections, see SetBusyTimeout
+
+	// Statements run under interruptCtx so that Interrupt can cancel them, see ctx
+	interruptMu     sync.Mutex
+	interruptCtx    context.Context
+	cancelInterrupt context.CancelFunc
+
+	// Connections keeping the in-memory scratch databases alive, see CreateScratch
+	scratch scratchDatabases
+
+	// Pools of databases switched away from, see SetMaxOpenDatabases
+	parked poolCache
+
+	// Row counts and sizes reused by GetTableStats
+	tableStats tableStatsCache
+
+	// Queries kept open between batches, see ReadBatch
+	cursors queryCursors
 }
 
 // NewSQLiteDB creates a new SQLite database connection
 func NewSQLiteDB(dbPath string) (*SQLiteDB, error) {
-	db, err := sql.Open("sqlite3", dbPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
-	}
+	// WAL lets readers proceed while a write is in progress
+	s := &SQLiteDB{dbState: &dbState{dbPath: dbPath, journalMode: "WAL", foreignKeys: true, busyTimeout: DefaultBusyTimeout}}
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	db, err := s.open(dbPath)
+	if err != nil {
+		return nil, err
 	}
+	s.db = db
 
-	return &SQLiteDB{
-		db:     db,
-		dbPath: dbPath,
-	}, nil
+	return s, nil
 }
 
 // Close closes the database connection
 func (s *SQLiteDB) Close() error {
+	s.closeCursors()
+	s.closeScratch()
+	s.closeParked()
 	return s.db.Close()
 }
 
 // ExecuteQuery executes a SELECT query
 func (s *SQLiteDB) ExecuteQuery(query string, args ...interface{}) ([]map[string]interface{}, error) {
-	rows, err := s.db.Query(query, args...)
+	_, results, err := s.ExecuteQueryColumnsContext(context.Background(), query, args...)
+	return results, err
+}
+
+// ExecuteQueryColumns executes a SELECT query like ExecuteQuery and also returns the result
+// column names in query order, which the row maps do not keep
+func (s *SQLiteDB) ExecuteQueryColumns(query string, args ...interface{}) ([]string, []map[string]interface{}, error) {
+	return s.ExecuteQueryColumnsContext(context.Background(), query, args...)
+}
+
+// ExecuteQueryColumnsContext is ExecuteQueryColumns with a context that interrupts the
+// query when it ends, for example when the tool call that runs it is cancelled
+func (s *SQLiteDB) ExecuteQueryColumnsContext(ctx context.Context, query string, args ...interface{}) ([]string, []map[string]interface{}, error) {
+	var columns []string
+	var results []map[string]interface{}
+	err := s.withReconnect(func() error {
+		ctx, cancel := s.statementContext(ctx)
+		defer cancel()
+
+		rows, err := s.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return timeoutError(ctx, err)
+		}
+		defer rows.Close()
+
+		if columns, err = rows.Columns(); err != nil {
+			return err
+		}
+		results, err = scanRows(rows, s.resultBudget())
+		return timeoutError(ctx, err)
+	})
 	if err != nil {
-		return nil, fmt.Errorf("query failed: %w", err)
+		return nil, nil, fmt.Errorf("query failed: %w", err)
 	}
-	defer rows.Close()
 
+	return columns, results, nil
+}
+
+// scanRows reads every remaining row into a slice of column-name keyed maps, failing with
+// ErrResultTooLarge once the values read exceed budget bytes (0 for no budget)
+func scanRows(rows *sql.Rows, budget int64) ([]map[string]interface{}, error) {
 	// Get column information
 	columns, err := rows.Columns()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get columns: %w", err)
 	}
 
+	affinities := columnAffinities(rows, len(columns))
+
 	// Prepare result set
 	var results []map[string]interface{}
 
@@ -63,6 +149,7 @@ func (s *SQLiteDB) ExecuteQuery(query string, args ...interface{}) ([]map[string
 	}
 
 	// Iterate through all rows
+	var size int64
 	for rows.Next() {
 		if err := rows.Scan(valuePtrs...); err != nil {
 			return nil, fmt.Errorf("failed to scan row: %w", err)
@@ -72,11 +159,18 @@ func (s *SQLiteDB) ExecuteQuery(query string, args ...interface{}) ([]map[string
 		row := make(map[string]interface{})
 		for i, col := range columns {
 			val := values[i]
-			// Handle []byte type (TEXT in SQLite)
+			if budget > 0 {
+				if size += valueSize(col, val); size > budget {
+					// Rows read so far are dropped with the error
+					return nil, fmt.Errorf("%w of %d bytes after reading %d complete rows; select fewer or narrower columns, filter the rows, or page with limit and offset",
+						ErrResultTooLarge, budget, len(results))
+				}
+			}
+			// The driver returns BLOB values, and only those, as []byte
 			if b, ok := val.([]byte); ok {
-				row[col] = string(b)
+				row[col] = Blob(b)
 			} else {
-				row[col] = val
+				row[col] = normalizeValue(val, affinities[i])
 			}
 		}
 		results = append(results, row)
@@ -89,11 +183,81 @@ func (s *SQLiteDB) ExecuteQuery(query string, args ...interface{}) ([]map[string
 	return results, nil
 }
 
+// columnAffinities returns the affinity of each of the n result columns of rows. Declared
+// column types decide how values are normalized; expression columns have none.
+func columnAffinities(rows *sql.Rows, n int) []string {
+	affinities := make([]string, n)
+	if columnTypes, err := rows.ColumnTypes(); err == nil {
+		for i, ct := range columnTypes {
+			if declared := ct.DatabaseTypeName(); declared != "" {
+				affinities[i] = columnAffinity(declared)
+			}
+		}
+	}
+	return affinities
+}
+
+// numericTextPattern matches text SQLite would read as a decimal number
+var numericTextPattern = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+
+// normalizeValue gives a scanned value the JSON type its column's affinity implies, so that
+// a column's values render alike in every row: numeric text left in an INTEGER, REAL, or
+// NUMERIC column becomes a number, and an integer in a REAL column becomes a float. Other
+// values, and values of columns without a declared type, are returned unchanged.
+func normalizeValue(value interface{}, affinity string) interface{} {
+	switch affinity {
+	case "INTEGER", "NUMERIC":
+		text, ok := value.(string)
+		if !ok || !numericTextPattern.MatchString(strings.TrimSpace(text)) {
+			return value
+		}
+		text = strings.TrimSpace(text)
+		if n, err := strconv.ParseInt(text, 10, 64); err == nil {
+			return n
+		}
+		if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(f, 0) {
+			return f
+		}
+	case "REAL":
+		switch v := value.(type) {
+		case int64:
+			return float64(v)
+		case string:
+			if text := strings.TrimSpace(v); numericTextPattern.MatchString(text) {
+				if f, err := strconv.ParseFloat(text, 64); err == nil && !math.IsInf(f, 0) {
+					return f
+				}
+			}
+		}
+	}
+	return value
+}
+
 // ExecuteStatement executes INSERT/UPDATE/DELETE statements
 func (s *SQLiteDB) ExecuteStatement(statement string, args ...interface{}) (int64, error) {
-	result, err := s.db.Exec(statement, args...)
+	return s.ExecuteStatementContext(context.Background(), statement, args...)
+}
+
+// ExecuteStatementContext is ExecuteStatement with a context that interrupts the statement
+// when it ends
+func (s *SQLiteDB) ExecuteStatementContext(ctx context.Context, statement string, args ...interface{}) (int64, error) {
+	var result sql.Result
+	err := s.withReconnect(func() error {
+		ctx, cancel := s.statementContext(ctx)
+		defer cancel()
+
+		// A single statement that fails on a lock changes nothing, so it can run again
+		return s.retryBusy(ctx, func() error {
+			var err error
+			result, err = s.db.ExecContext(ctx, statement, args...)
+			return timeoutError(ctx, err)
+		})
+	})
 	if err != nil {
-		return 0, fmt.Errorf("execution failed: %w", err)
+		if isDiskFull(err) {
+			return 0, s.diskFullError(err)
+		}
+		return 0, fmt.Errorf("execution failed: %w", s.ExplainForeignKeyError(err, []string{statement}, args...))
 	}
 
 	// Return different results based on statement type
@@ -105,6 +269,119 @@ func (s *SQLiteDB) ExecuteStatement(statement string, args ...interface{}) (int6
 	return result.RowsAffected()
 }
 
+// HasReturningClause reports whether a statement has a RETURNING clause. The keyword inside
+// string literals, quoted identifiers, and comments does not count.
+func HasReturningClause(statement string) bool {
+	for _, token := range tokenizeSQL(statement) {
+		if token.kind == 'i' && !token.quoted && strings.EqualFold(token.text, "RETURNING") {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecuteStatementReturning executes an INSERT, UPDATE, or DELETE statement with a RETURNING
+// clause and returns the rows it produces with their column names in order. The statement
+// runs in a transaction that is only committed once every row has been read, so a statement
+// whose rows exceed the result budget makes no changes.
+func (s *SQLiteDB) ExecuteStatementReturning(ctx context.Context, statement string, args ...interface{}) ([]string, []map[string]interface{}, error) {
+	var columns []string
+	var results []map[string]interface{}
+	err := s.RetryTransaction(func(tx *sql.Tx) error {
+		var err error
+		columns, results, err = s.QueryTx(ctx, tx, statement, args...)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrDiskFull) {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("execution failed: %w", s.ExplainForeignKeyError(err, []string{statement}, args...))
+	}
+	return columns, results, nil
+}
+
+// RawResult holds the outcome of a statement run verbatim through RawExec
+type RawResult struct {
+	Columns      []string                 `json:"columns,omitempty"`
+	Rows         []map[string]interface{} `json:"rows,omitempty"`
+	RowsAffected int64                    `json:"rows_affected"`
+	LastInsertID int64                    `json:"last_insert_id"`
+}
+
+// RawExec runs a statement exactly as written, returning its rows if it produced a result set
+// and the change counters otherwise
+func (s *SQLiteDB) RawExec(statement string) (*RawResult, error) {
+	ctx := s.ctx()
+
+	// Pin a connection so the change counters refer to this statement
+	var conn *sql.Conn
+	err := s.withReconnect(func() error {
+		var err error
+		conn, err = s.db.Conn(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	// changes() is not reset by DDL, so measure the difference in total_changes() instead
+	var changesBefore int64
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes()").Scan(&changesBefore); err != nil {
+		return nil, fmt.Errorf("failed to read change counters: %w", err)
+	}
+
+	rows, err := conn.QueryContext(ctx, statement)
+	if err != nil {
+		return nil, s.rawExecError(ctx, conn, err)
+	}
+
+	columns, err := rows.Columns()
+	if err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+
+	result := &RawResult{}
+	if len(columns) > 0 {
+		result.Columns = columns
+		result.Rows, err = scanRows(rows, s.resultBudget())
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	// Step the statement to completion before reading the change counters
+	for rows.Next() {
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, s.rawExecError(ctx, conn, err)
+	}
+
+	var changesAfter int64
+	if err := conn.QueryRowContext(ctx, "SELECT total_changes(), last_insert_rowid()").Scan(&changesAfter, &result.LastInsertID); err != nil {
+		return nil, fmt.Errorf("failed to read change counters: %w", err)
+	}
+	result.RowsAffected = changesAfter - changesBefore
+
+	return result, nil
+}
+
+// rawExecError wraps a RawExec failure. A raw statement may run inside a transaction opened by
+// an earlier raw BEGIN, so when the disk is full that transaction is rolled back explicitly.
+func (s *SQLiteDB) rawExecError(ctx context.Context, conn *sql.Conn, err error) error {
+	if isDiskFull(err) {
+		conn.ExecContext(ctx, "ROLLBACK")
+		return s.diskFullError(err)
+	}
+	return fmt.Errorf("execution failed: %w", err)
+}
+
 // GetTables gets all table names
 func (s *SQLiteDB) GetTables() ([]string, error) {
 	query := `
@@ -114,7 +391,7 @@ func (s *SQLiteDB) GetTables() ([]string, error) {
 		ORDER BY name
 	`
 
-	rows, err := s.db.Query(query)
+	rows, err := s.db.QueryContext(s.ctx(), query)
 	if err != nil {
 		return nil, err
 	}
@@ -134,12 +411,111 @@ func (s *SQLiteDB) GetTables() ([]string, error) {
 
 // GetTableSchema gets table structure
 func (s *SQLiteDB) GetTableSchema(tableName string) ([]map[string]interface{}, error) {
-	query := fmt.Sprintf("PRAGMA table_info('%s')", tableName)
+	query := fmt.Sprintf("PRAGMA table_info(%s)", quoteIdentifier(tableName))
 	return s.ExecuteQuery(query)
 }
 
-// CreateTable creates a table
+// GetAnnotatedSchema gets table structure with each column flagged for primary key, foreign key,
+// index participation, NOT NULL, and default value
+func (s *SQLiteDB) GetAnnotatedSchema(tableName string) ([]map[string]interface{}, error) {
+	columns, err := s.GetTableSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	foreignKeys, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA foreign_key_list(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	fkColumns := make(map[string]bool)
+	for _, fk := range foreignKeys {
+		if from, ok := fk["from"].(string); ok {
+			fkColumns[from] = true
+		}
+	}
+
+	indexList, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_list(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return nil, err
+	}
+	indexedColumns := make(map[string]bool)
+	for _, index := range indexList {
+		indexName, ok := index["name"].(string)
+		if !ok {
+			continue
+		}
+		indexInfo, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_info(%s)", quoteIdentifier(indexName)))
+		if err != nil {
+			return nil, err
+		}
+		for _, col := range indexInfo {
+			if name, ok := col["name"].(string); ok {
+				indexedColumns[name] = true
+			}
+		}
+	}
+
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		isPrimaryKey := toInt64(col["pk"]) > 0
+
+		col["is_primary_key"] = isPrimaryKey
+		col["is_foreign_key"] = fkColumns[name]
+		// Primary key columns are always indexed, either by the rowid or an automatic index
+		col["is_indexed"] = indexedColumns[name] || isPrimaryKey
+		col["is_not_null"] = toInt64(col["notnull"]) != 0
+		col["has_default"] = col["dflt_value"] != nil
+	}
+
+	return columns, nil
+}
+
+// GetTableDDL returns the statements needed to recreate a table together with its indexes and triggers
+func (s *SQLiteDB) GetTableDDL(tableName string) (string, error) {
+	var tableSQL string
+	err := s.db.QueryRowContext(s.ctx(), "SELECT sql FROM sqlite_master WHERE type='table' AND name=?", tableName).Scan(&tableSQL)
+	if err == sql.ErrNoRows {
+		return "", fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// Auto-created indexes (UNIQUE/PRIMARY KEY constraints) have a NULL sql column
+	// and are recreated by the table statement itself
+	rows, err := s.db.QueryContext(s.ctx(), `
+		SELECT sql FROM sqlite_master
+		WHERE tbl_name=?
+		AND type IN ('index', 'trigger')
+		AND sql IS NOT NULL
+		ORDER BY CASE type WHEN 'index' THEN 0 ELSE 1 END, name
+	`, tableName)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	statements := []string{tableSQL}
+	for rows.Next() {
+		var stmt string
+		if err := rows.Scan(&stmt); err != nil {
+			return "", err
+		}
+		statements = append(statements, stmt)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	return strings.Join(statements, ";\n\n") + ";\n", nil
+}
+
+// CreateTable creates a table. The table and column names are quoted, so they may contain
+// spaces, quotes, or keywords; each column's type and constraints are SQL and used as given.
 func (s *SQLiteDB) CreateTable(tableName string, columns []map[string]string) error {
+	if err := validateIdentifier(tableName); err != nil {
+		return fmt.Errorf("invalid table name: %w", err)
+	}
 	if len(columns) == 0 {
 		return fmt.Errorf("no columns specified")
 	}
@@ -153,22 +529,85 @@ func (s *SQLiteDB) CreateTable(tableName string, columns []map[string]string) er
 		if name == "" || dataType == "" {
 			return fmt.Errorf("column name and type are required")
 		}
+		if err := validateIdentifier(name); err != nil {
+			return fmt.Errorf("invalid column name: %w", err)
+		}
 
-		def := fmt.Sprintf("%s %s", name, dataType)
+		def := fmt.Sprintf("%s %s", quoteIdentifier(name), dataType)
 		if constraints != "" {
 			def += " " + constraints
 		}
 		columnDefs = append(columnDefs, def)
 	}
 
-	createSQL := fmt.Sprintf("CREATE TABLE %s (%s)", tableName, strings.Join(columnDefs, ", "))
-	_, err := s.db.Exec(createSQL)
+	createSQL := fmt.Sprintf("CREATE TABLE %s (%s)", quoteIdentifier(tableName), strings.Join(columnDefs, ", "))
+	_, err := s.db.ExecContext(s.ctx(), createSQL)
 	return err
 }
 
+// QueryIntoTable materializes the results of a SELECT into a destination table. In create mode the
+// table is created from the query (CREATE TABLE ... AS SELECT) and must not exist yet; in append
+// mode the rows are inserted into an existing table. Returns the number of rows written.
+func (s *SQLiteDB) QueryIntoTable(selectQuery, destTable string, appendMode bool) (int64, error) {
+	if destTable == "" {
+		return 0, fmt.Errorf("destination table name is required")
+	}
+	if err := validateIdentifier(destTable); err != nil {
+		return 0, fmt.Errorf("invalid table name: %w", err)
+	}
+	if strings.HasPrefix(strings.ToLower(destTable), "sqlite_") {
+		return 0, fmt.Errorf("table names beginning with 'sqlite_' are reserved")
+	}
+	if !IsSingleStatement(selectQuery) {
+		return 0, fmt.Errorf("the query must be a single SELECT statement")
+	}
+
+	selectQuery = strings.TrimSuffix(strings.TrimSpace(selectQuery), ";")
+
+	var exists int
+	if err := s.db.QueryRowContext(s.ctx(), "SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name=?", destTable).Scan(&exists); err != nil {
+		return 0, err
+	}
+	if appendMode && exists == 0 {
+		return 0, fmt.Errorf("destination table '%s' does not exist", destTable)
+	}
+	if !appendMode && exists > 0 {
+		return 0, fmt.Errorf("destination table '%s' already exists, use append mode to add rows", destTable)
+	}
+
+	var written int64
+	err := s.RetryTransaction(func(tx *sql.Tx) error {
+		if appendMode {
+			result, err := tx.ExecContext(s.ctx(), fmt.Sprintf("INSERT INTO %s %s", quoteIdentifier(destTable), selectQuery))
+			if err != nil {
+				return err
+			}
+			written, err = result.RowsAffected()
+			return err
+		}
+
+		if _, err := tx.ExecContext(s.ctx(), fmt.Sprintf("CREATE TABLE %s AS %s", quoteIdentifier(destTable), selectQuery)); err != nil {
+			return err
+		}
+		// CREATE TABLE ... AS does not report changes, so count the new rows
+		return tx.QueryRowContext(s.ctx(), fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteIdentifier(destTable))).Scan(&written)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return written, nil
+}
+
 // Transaction executes a transaction
 func (s *SQLiteDB) Transaction(fn func(*sql.Tx) error) error {
-	tx, err := s.db.Begin()
+	// Only starting the transaction is retried; fn may have side effects outside the database
+	var tx *sql.Tx
+	err := s.withReconnect(func() error {
+		var err error
+		tx, err = s.db.BeginTx(s.ctx(), nil)
+		return err
+	})
 	if err != nil {
 		return err
 	}
@@ -182,16 +621,44 @@ func (s *SQLiteDB) Transaction(fn func(*sql.Tx) error) error {
 
 	if err := fn(tx); err != nil {
 		tx.Rollback()
-		return err
+		return s.diskFullError(err)
+	}
+
+	// The driver rolls back a transaction whose COMMIT fails
+	return s.diskFullError(tx.Commit())
+}
+
+// QueryTx runs a statement that returns rows, a SELECT or a statement with a RETURNING
+// clause, inside a transaction started by Transaction and reads its rows within the result
+// budget, returning the column names in order
+func (s *SQLiteDB) QueryTx(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, []map[string]interface{}, error) {
+	ctx, cancel := s.statementContext(ctx)
+	defer cancel()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, timeoutError(ctx, err)
 	}
+	defer rows.Close()
 
-	return tx.Commit()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+	results, err := scanRows(rows, s.resultBudget())
+	if err != nil {
+		return nil, nil, timeoutError(ctx, err)
+	}
+	return columns, results, nil
 }
 
 // DropTable drops a table
 func (s *SQLiteDB) DropTable(tableName string) error {
-	query := fmt.Sprintf("DROP TABLE IF EXISTS %s", tableName)
-	_, err := s.db.Exec(query)
+	if err := validateIdentifier(tableName); err != nil {
+		return fmt.Errorf("invalid table name: %w", err)
+	}
+	query := fmt.Sprintf("DROP TABLE IF EXISTS %s", quoteIdentifier(tableName))
+	_, err := s.db.ExecContext(s.ctx(), query)
 	return err
 }
 
@@ -200,6 +667,12 @@ func (s *SQLiteDB) CreateIndex(indexName, tableName string, columns []string, un
 	if len(columns) == 0 {
 		return fmt.Errorf("at least one column must be specified")
 	}
+	if err := validateIdentifier(indexName); err != nil {
+		return fmt.Errorf("invalid index name: %w", err)
+	}
+	if err := validateIdentifier(tableName); err != nil {
+		return fmt.Errorf("invalid table name: %w", err)
+	}
 
 	var query string
 	existsClause := ""
@@ -212,11 +685,17 @@ func (s *SQLiteDB) CreateIndex(indexName, tableName string, columns []string, un
 		uniqueClause = "UNIQUE "
 	}
 
-	columnsStr := strings.Join(columns, ", ")
+	quoted := make([]string, len(columns))
+	for i, col := range columns {
+		if err := validateIdentifier(col); err != nil {
+			return fmt.Errorf("invalid column name: %w", err)
+		}
+		quoted[i] = quoteIdentifier(col)
+	}
 	query = fmt.Sprintf("CREATE %sINDEX %s%s ON %s (%s)",
-		uniqueClause, existsClause, indexName, tableName, columnsStr)
+		uniqueClause, existsClause, quoteIdentifier(indexName), quoteIdentifier(tableName), strings.Join(quoted, ", "))
 
-	_, err := s.db.Exec(query)
+	_, err := s.db.ExecContext(s.ctx(), query)
 	return err
 }
 
@@ -228,6 +707,12 @@ func (s *SQLiteDB) CreateIndexWithOptions(options IndexOptions) error {
 	if options.TableName == "" {
 		return fmt.Errorf("table name is required")
 	}
+	if err := validateIdentifier(options.IndexName); err != nil {
+		return fmt.Errorf("invalid index name: %w", err)
+	}
+	if err := validateIdentifier(options.TableName); err != nil {
+		return fmt.Errorf("invalid table name: %w", err)
+	}
 	if len(options.Columns) == 0 {
 		return fmt.Errorf("at least one column must be specified")
 	}
@@ -245,16 +730,23 @@ func (s *SQLiteDB) CreateIndexWithOptions(options IndexOptions) error {
 		parts = append(parts, "IF NOT EXISTS")
 	}
 
-	parts = append(parts, options.IndexName)
+	parts = append(parts, quoteIdentifier(options.IndexName))
 	parts = append(parts, "ON")
-	parts = append(parts, options.TableName)
+	parts = append(parts, quoteIdentifier(options.TableName))
 
 	// Build column specifications
 	var columnSpecs []string
 	for _, col := range options.Columns {
-		spec := col.Name
-		if col.SortOrder != "" {
-			spec += " " + strings.ToUpper(col.SortOrder)
+		if err := validateIdentifier(col.Name); err != nil {
+			return fmt.Errorf("invalid column name: %w", err)
+		}
+		spec := quoteIdentifier(col.Name)
+		switch order := strings.ToUpper(col.SortOrder); order {
+		case "":
+		case "ASC", "DESC":
+			spec += " " + order
+		default:
+			return fmt.Errorf("invalid sort order '%s' for column '%s', use ASC or DESC", col.SortOrder, col.Name)
 		}
 		columnSpecs = append(columnSpecs, spec)
 	}
@@ -268,7 +760,7 @@ func (s *SQLiteDB) CreateIndexWithOptions(options IndexOptions) error {
 	}
 
 	query := strings.Join(parts, " ")
-	_, err := s.db.Exec(query)
+	_, err := s.db.ExecContext(s.ctx(), query)
 	return err
 }
 
@@ -282,7 +774,8 @@ type IndexOptions struct {
 	WhereClause string
 }
 
-// IndexColumn represents a column in an index
+// IndexColumn represents a column in an index. Name is a column name, quoted when the index
+// is created, not an expression.
 type IndexColumn struct {
 	Name      string
 	SortOrder string // "ASC" or "DESC"
@@ -291,15 +784,21 @@ type IndexColumn struct {
 // GetIndexes gets all indexes for a table with detailed information
 func (s *SQLiteDB) GetIndexes(tableName string) ([]map[string]interface{}, error) {
 	// First get all indexes for the table
-	indexQuery := fmt.Sprintf(`
+	indexQuery := `
 		SELECT name, sql
 		FROM sqlite_master
 		WHERE type='index'
-		AND tbl_name='%s'
-		AND name NOT LIKE 'sqlite_autoindex_%%'
-	`, tableName)
+		AND tbl_name=?
+		AND name NOT LIKE 'sqlite_autoindex_%'
+	`
 
-	indexes, err := s.ExecuteQuery(indexQuery)
+	indexes, err := s.ExecuteQuery(indexQuery, tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get index list info for uniqueness
+	listInfo, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_list(%s)", quoteIdentifier(tableName)))
 	if err != nil {
 		return nil, err
 	}
@@ -310,35 +809,28 @@ func (s *SQLiteDB) GetIndexes(tableName string) ([]map[string]interface{}, error
 		indexName := index["name"].(string)
 
 		// Get index info using PRAGMA index_info
-		infoQuery := fmt.Sprintf("PRAGMA index_info(%s)", indexName)
+		infoQuery := fmt.Sprintf("PRAGMA index_info(%s)", quoteIdentifier(indexName))
 		columns, err := s.ExecuteQuery(infoQuery)
 		if err != nil {
 			continue // Skip this index if we can't get info
 		}
 
-		// Get index list info for uniqueness
-		listQuery := fmt.Sprintf("PRAGMA index_list(%s)", tableName)
-		listInfo, err := s.ExecuteQuery(listQuery)
-		if err != nil {
-			continue
-		}
-
 		// Find if this index is unique
 		isUnique := false
 		for _, listItem := range listInfo {
 			if listItem["name"] == indexName {
-				if uniqueVal, ok := listItem["unique"]; ok {
-					isUnique = uniqueVal == "1"
-				}
+				isUnique = toInt64(listItem["unique"]) == 1
 				break
 			}
 		}
 
-		// Build column list
+		// Build column list; expression columns have no name
 		var columnNames []string
 		for _, col := range columns {
-			if colName, ok := col["name"]; ok {
-				columnNames = append(columnNames, colName.(string))
+			if colName, ok := col["name"].(string); ok {
+				columnNames = append(columnNames, colName)
+			} else {
+				columnNames = append(columnNames, "(expression)")
 			}
 		}
 
@@ -357,20 +849,350 @@ func (s *SQLiteDB) GetIndexes(tableName string) ([]map[string]interface{}, error
 
 // DropIndex drops an index from the database
 func (s *SQLiteDB) DropIndex(indexName string) error {
-	query := fmt.Sprintf("DROP INDEX IF EXISTS %s", indexName)
-	_, err := s.db.Exec(query)
+	if err := validateIdentifier(indexName); err != nil {
+		return fmt.Errorf("invalid index name: %w", err)
+	}
+	query := fmt.Sprintf("DROP INDEX IF EXISTS %s", quoteIdentifier(indexName))
+	_, err := s.db.ExecContext(s.ctx(), query)
 	return err
 }
 
 // Vacuum optimizes the database
 func (s *SQLiteDB) Vacuum() error {
-	_, err := s.db.Exec("VACUUM")
-	return err
+	_, err := s.db.ExecContext(s.ctx(), "VACUUM")
+	return s.diskFullError(err)
+}
+
+// Analyze gathers table and index statistics with ANALYZE so the query planner can pick
+// better indexes. name limits it to one table or index; empty analyzes the whole database.
+func (s *SQLiteDB) Analyze(name string) error {
+	statement := "ANALYZE"
+	if name != "" {
+		statement += " " + quoteIdentifier(name)
+	}
+	_, err := s.db.ExecContext(s.ctx(), statement)
+	return s.diskFullError(err)
+}
+
+// Reindex rebuilds indexes with REINDEX, for example after a collation's definition changed
+// or to repair an index that integrity_check reports as inconsistent. name limits it to one
+// table's indexes, one index, or the indexes using one collation; empty rebuilds every index.
+func (s *SQLiteDB) Reindex(name string) error {
+	statement := "REINDEX"
+	if name != "" {
+		statement += " " + quoteIdentifier(name)
+	}
+	_, err := s.db.ExecContext(s.ctx(), statement)
+	return s.diskFullError(err)
+}
+
+// Checkpoint copies as much of the WAL into the database file as possible without waiting
+// for readers or writers. It does nothing when the database is not in WAL mode.
+func (s *SQLiteDB) Checkpoint() error {
+	_, err := s.db.ExecContext(s.ctx(), "PRAGMA wal_checkpoint(PASSIVE)")
+	return s.diskFullError(err)
+}
+
+// BackupTo writes a consistent copy of the database to path with VACUUM INTO. Other
+// connections can keep reading and writing while the copy is made. path must not exist.
+func (s *SQLiteDB) BackupTo(path string) (int64, error) {
+	if _, err := s.db.ExecContext(s.ctx(), "VACUUM INTO ?", path); err != nil {
+		return 0, s.diskFullError(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// PageSizeResult reports the outcome of SetPageSize
+type PageSizeResult struct {
+	PreviousPageSize int64 `json:"previous_page_size"`
+	PageSize         int64 `json:"page_size"`
+	PageCount        int64 `json:"page_count"`
+	// HadData is true when existing content had to be rewritten by VACUUM
+	HadData bool `json:"had_data"`
+	// JournalMode is the journal mode the database was switched back to after leaving WAL
+	// mode for the VACUUM, if it was in WAL mode
+	JournalMode string `json:"journal_mode,omitempty"`
+}
+
+// SetPageSize changes the database page size. PRAGMA page_size only takes effect when the
+// database file is created or rebuilt, so the pragma and a VACUUM run on the same pinned
+// connection. On a populated database the VACUUM rewrites the whole file. The page size of a
+// database in WAL mode cannot change, so a database in the default WAL mode is switched to
+// DELETE mode for the VACUUM and back to WAL afterwards.
+func (s *SQLiteDB) SetPageSize(size int64) (result *PageSizeResult, err error) {
+	if size < 512 || size > 65536 || size&(size-1) != 0 {
+		return nil, fmt.Errorf("page size must be a power of two between 512 and 65536")
+	}
+
+	s.settingsMu.RLock()
+	configured := s.journalMode
+	s.settingsMu.RUnlock()
+	if strings.EqualFold(configured, "WAL") {
+		if _, err := s.SetJournalMode("DELETE"); err != nil {
+			return nil, fmt.Errorf("failed to leave WAL mode: %w", err)
+		}
+		defer func() {
+			mode, restoreErr := s.SetJournalMode(configured)
+			if restoreErr != nil && err == nil {
+				result, err = nil, fmt.Errorf("page size changed, but failed to restore journal mode %s: %w", configured, restoreErr)
+			}
+			if result != nil {
+				result.JournalMode = mode
+			}
+		}()
+	}
+
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	var journalMode string
+	if err := conn.QueryRowContext(ctx, "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		return nil, err
+	}
+	if strings.EqualFold(journalMode, "wal") {
+		// Another connection, possibly of another process, keeps the database in WAL mode
+		return nil, fmt.Errorf("the page size cannot be changed in WAL mode, and the database could not leave it; close other connections to it and retry")
+	}
+
+	result = &PageSizeResult{}
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&result.PreviousPageSize); err != nil {
+		return nil, err
+	}
+	var pageCount int64
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pageCount); err != nil {
+		return nil, err
+	}
+	result.HadData = pageCount > 1
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("PRAGMA page_size = %d", size)); err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+		return nil, s.diskFullError(fmt.Errorf("failed to vacuum: %w", err))
+	}
+
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_size").Scan(&result.PageSize); err != nil {
+		return nil, err
+	}
+	if err := conn.QueryRowContext(ctx, "PRAGMA page_count").Scan(&result.PageCount); err != nil {
+		return nil, err
+	}
+	if result.PageSize != size {
+		return nil, fmt.Errorf("page size is still %d after VACUUM", result.PageSize)
+	}
+
+	return result, nil
+}
+
+// GetDatabaseStats gets database statistics: the attached databases and journal settings
+func (s *SQLiteDB) GetDatabaseStats() (map[string]interface{}, error) {
+	databases, err := s.ExecuteQuery("PRAGMA database_list")
+	if err != nil {
+		return nil, err
+	}
+
+	var journalMode string
+	if err := s.db.QueryRowContext(s.ctx(), "PRAGMA journal_mode").Scan(&journalMode); err != nil {
+		return nil, err
+	}
+
+	journalSizeLimit, err := s.GetJournalSizeLimit()
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"databases":          databases,
+		"journal_mode":       journalMode,
+		"journal_size_limit": journalSizeLimit,
+	}, nil
 }
 
-// GetDatabaseStats gets database statistics
-func (s *SQLiteDB) GetDatabaseStats() ([]map[string]interface{}, error) {
-	return s.ExecuteQuery("PRAGMA database_list")
+// GetPoolStats reports database/sql connection pool statistics plus SQLite page counters
+func (s *SQLiteDB) GetPoolStats() (map[string]interface{}, error) {
+	stats := s.db.Stats()
+
+	result := map[string]interface{}{
+		"max_open_connections": stats.MaxOpenConnections,
+		"open_connections":     stats.OpenConnections,
+		"in_use":               stats.InUse,
+		"idle":                 stats.Idle,
+		"wait_count":           stats.WaitCount,
+		"wait_duration":        stats.WaitDuration.String(),
+		"max_idle_closed":      stats.MaxIdleClosed,
+		"max_idle_time_closed": stats.MaxIdleTimeClosed,
+		"max_lifetime_closed":  stats.MaxLifetimeClosed,
+	}
+	s.settingsMu.RLock()
+	result["serialized"] = s.serialized
+	s.settingsMu.RUnlock()
+	result["max_open_databases"] = s.maxOpenDatabases()
+	busyTimeout, err := s.GetBusyTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read PRAGMA busy_timeout: %w", err)
+	}
+	result["busy_timeout"] = busyTimeout.String()
+	result["open_databases"] = s.OpenDatabases()
+
+	// SQLite-specific counters
+	for _, pragma := range []string{"cache_size", "page_count", "page_size", "freelist_count"} {
+		var value int64
+		if err := s.db.QueryRowContext(s.ctx(), fmt.Sprintf("PRAGMA %s", pragma)).Scan(&value); err != nil {
+			return nil, fmt.Errorf("failed to read PRAGMA %s: %w", pragma, err)
+		}
+		result[pragma] = value
+	}
+
+	var version string
+	if err := s.db.QueryRowContext(s.ctx(), "SELECT sqlite_version()").Scan(&version); err != nil {
+		return nil, fmt.Errorf("failed to read SQLite version: %w", err)
+	}
+	result["sqlite_version"] = version
+
+	return result, nil
+}
+
+// StorageUsage is the space used by one table or index
+type StorageUsage struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Table string `json:"table"`
+	Pages int64  `json:"pages"`
+	Bytes int64  `json:"bytes"`
+	Rows  *int64 `json:"rows,omitempty"`
+}
+
+// StorageBreakdown reports how the database file's space is divided between its objects
+type StorageBreakdown struct {
+	Method     string         `json:"method"` // dbstat or estimate
+	PageSize   int64          `json:"page_size"`
+	TotalPages int64          `json:"total_pages"`
+	FreePages  int64          `json:"free_pages"`
+	Objects    []StorageUsage `json:"objects"`
+	Note       string         `json:"note,omitempty"`
+}
+
+// StorageBreakdown reports the pages and bytes used by each table and index, largest first.
+// Exact figures come from the dbstat virtual table; SQLite builds without it (the default for
+// go-sqlite3) get estimates derived from row counts and stored value sizes instead.
+func (s *SQLiteDB) StorageBreakdown() (*StorageBreakdown, error) {
+	breakdown := &StorageBreakdown{Objects: []StorageUsage{}}
+	for pragma, target := range map[string]*int64{
+		"page_size":      &breakdown.PageSize,
+		"page_count":     &breakdown.TotalPages,
+		"freelist_count": &breakdown.FreePages,
+	} {
+		if err := s.db.QueryRowContext(s.ctx(), fmt.Sprintf("PRAGMA %s", pragma)).Scan(target); err != nil {
+			return nil, fmt.Errorf("failed to read PRAGMA %s: %w", pragma, err)
+		}
+	}
+
+	objects, err := s.ExecuteQuery("SELECT type, name, tbl_name FROM sqlite_master WHERE type IN ('table', 'index') AND rootpage > 0")
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := s.db.ExecContext(s.ctx(), "SELECT 1 FROM dbstat LIMIT 1"); err == nil {
+		breakdown.Method = "dbstat"
+		usage, err := s.ExecuteQuery("SELECT name, COUNT(*) AS pages, SUM(pgsize) AS bytes FROM dbstat GROUP BY name")
+		if err != nil {
+			return nil, err
+		}
+		byName := make(map[string]map[string]interface{})
+		for _, obj := range objects {
+			name, _ := obj["name"].(string)
+			byName[name] = obj
+		}
+		for _, row := range usage {
+			name, _ := row["name"].(string)
+			entry := StorageUsage{Name: name, Type: "table", Table: name, Pages: toInt64(row["pages"]), Bytes: toInt64(row["bytes"])}
+			if obj, ok := byName[name]; ok {
+				entry.Type, _ = obj["type"].(string)
+				entry.Table, _ = obj["tbl_name"].(string)
+			}
+			breakdown.Objects = append(breakdown.Objects, entry)
+		}
+	} else {
+		breakdown.Method = "estimate"
+		breakdown.Note = "dbstat virtual table is not available in this SQLite build (compile with -DSQLITE_ENABLE_DBSTAT_VTAB for exact figures); sizes are estimated from row counts and stored value lengths"
+		for _, obj := range objects {
+			entry, err := s.estimateStorage(obj, breakdown.PageSize)
+			if err != nil {
+				return nil, err
+			}
+			breakdown.Objects = append(breakdown.Objects, entry)
+		}
+	}
+
+	sort.SliceStable(breakdown.Objects, func(i, j int) bool {
+		return breakdown.Objects[i].Bytes > breakdown.Objects[j].Bytes
+	})
+
+	return breakdown, nil
+}
+
+// estimateStorage estimates the size of a table or index as its row count times the stored
+// length of its columns plus a small per-row overhead
+func (s *SQLiteDB) estimateStorage(obj map[string]interface{}, pageSize int64) (StorageUsage, error) {
+	objType, _ := obj["type"].(string)
+	name, _ := obj["name"].(string)
+	table, _ := obj["tbl_name"].(string)
+	entry := StorageUsage{Name: name, Type: objType, Table: table}
+
+	var columns []string
+	if objType == "table" {
+		schema, err := s.GetTableSchema(name)
+		if err != nil {
+			return entry, err
+		}
+		for _, col := range schema {
+			colName, _ := col["name"].(string)
+			columns = append(columns, colName)
+		}
+	} else {
+		info, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_info(%s)", quoteIdentifier(name)))
+		if err != nil {
+			return entry, err
+		}
+		for _, col := range info {
+			// Expression columns have no name and are not counted
+			if colName, ok := col["name"].(string); ok {
+				columns = append(columns, colName)
+			}
+		}
+	}
+
+	// Each row carries a record header and a rowid or key of a few bytes
+	const rowOverhead = 8
+	sizeExpr := fmt.Sprintf("COUNT(*) * %d", rowOverhead)
+	for _, col := range columns {
+		sizeExpr += fmt.Sprintf(" + COALESCE(SUM(length(%s)), 0)", quoteIdentifier(col))
+	}
+
+	var rows, bytes int64
+	query := fmt.Sprintf("SELECT COUNT(*), %s FROM %s", sizeExpr, quoteIdentifier(table))
+	if err := s.db.QueryRowContext(s.ctx(), query).Scan(&rows, &bytes); err != nil {
+		return entry, fmt.Errorf("failed to estimate size of %s: %w", name, err)
+	}
+
+	entry.Rows = &rows
+	entry.Bytes = bytes
+	if pageSize > 0 {
+		entry.Pages = (bytes + pageSize - 1) / pageSize
+		if entry.Pages == 0 {
+			entry.Pages = 1
+		}
+		entry.Bytes = entry.Pages * pageSize
+	}
+	return entry, nil
 }
 
 // AnalyzeQuery analyzes a query execution plan
@@ -435,23 +1257,36 @@ func DatabaseExists(dbPath string) bool {
 	return err == nil
 }
 
-// SwitchDatabase switches to a different database file
+// SwitchDatabase switches to a different database file. The pool of the current database is
+// kept open, up to the limit of SetMaxOpenDatabases, and a pool kept for newDbPath is reused
+// when it was opened with the current connection settings.
 func (s *SQLiteDB) SwitchDatabase(newDbPath string) error {
-	// Close the current connection
-	if s.db != nil {
-		s.db.Close()
+	if s.db != nil && parkedKey(newDbPath) == parkedKey(s.dbPath) {
+		s.dbPath = newDbPath
+		return nil
 	}
 
-	// Open new database connection
-	db, err := sql.Open("sqlite3", newDbPath)
-	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
+	db := s.unpark(newDbPath)
+	if db != nil {
+		s.settingsMu.RLock()
+		if s.serialized {
+			db.SetMaxOpenConns(1)
+		} else {
+			db.SetMaxOpenConns(0)
+		}
+		s.settingsMu.RUnlock()
+	} else {
+		// Open new database connection with the current connection settings; the current
+		// one stays in use when that fails
+		var err error
+		if db, err = s.open(newDbPath); err != nil {
+			return err
+		}
 	}
 
-	// Test connection
-	if err := db.Ping(); err != nil {
-		return fmt.Errorf("failed to ping database: %w", err)
-	}
+	// Keep the current connection for switching back, without the queries left open on it
+	s.closeCursors()
+	s.park()
 
 	// Update the instance
 	s.db = db
@@ -465,27 +1300,46 @@ func (s *SQLiteDB) GetCurrentDatabasePath() string {
 	return s.dbPath
 }
 
-// ListDatabaseFiles lists all SQLite database files in the given directory
+// databaseExtensions are the file extensions recognized as SQLite databases
+var databaseExtensions = []string{".db", ".sqlite", ".sqlite3", ".db3"}
+
+// HasDatabaseExtension reports whether path ends in one of the SQLite database extensions,
+// ignoring case
+func HasDatabaseExtension(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, dbExt := range databaseExtensions {
+		if ext == dbExt {
+			return true
+		}
+	}
+	return false
+}
+
+// ListDatabaseFiles lists all SQLite database files in the given directory, sorted by name
 func ListDatabaseFiles(dirPath string) ([]string, error) {
 	if dirPath == "" {
 		dirPath = "."
 	}
 
-	files, err := filepath.Glob(filepath.Join(dirPath, "*.db"))
+	entries, err := os.ReadDir(dirPath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list database files: %w", err)
 	}
 
-	// Also check for .sqlite and .sqlite3 extensions
-	sqliteFiles, _ := filepath.Glob(filepath.Join(dirPath, "*.sqlite"))
-	sqlite3Files, _ := filepath.Glob(filepath.Join(dirPath, "*.sqlite3"))
-	
-	files = append(files, sqliteFiles...)
-	files = append(files, sqlite3Files...)
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && HasDatabaseExtension(entry.Name()) {
+			files = append(files, filepath.Join(dirPath, entry.Name()))
+		}
+	}
 
 	// Filter out files that are not valid SQLite databases
 	var validDatabases []string
 	for _, file := range files {
+		// WAL and rollback journal sidecar files belong to the database next to them
+		if isSidecarFile(file) {
+			continue
+		}
 		if DatabaseExists(file) || isValidSQLiteFile(file) {
 			validDatabases = append(validDatabases, file)
 		}
@@ -494,6 +1348,19 @@ func ListDatabaseFiles(dirPath string) ([]string, error) {
 	return validDatabases, nil
 }
 
+// sidecarSuffixes are the suffixes SQLite appends to a database path for its journal files
+var sidecarSuffixes = []string{"-wal", "-shm", "-journal"}
+
+// isSidecarFile reports whether path is the WAL, shared memory, or rollback journal file of a database
+func isSidecarFile(path string) bool {
+	for _, suffix := range sidecarSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
 // isValidSQLiteFile checks if a file is a valid SQLite database by checking the header
 func isValidSQLiteFile(filePath string) bool {
 	file, err := os.Open(filePath)
@@ -527,3 +1394,25 @@ func DeleteDatabase(dbPath string) error {
 	
 	return nil
 }
+
+// toInt64 converts an integer value scanned from SQLite to int64, returning 0 for other types
+func toInt64(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	case string:
+		var n int64
+		fmt.Sscan(v, &n)
+		return n
+	}
+	return 0
+}
+
+// quoteIdentifier wraps a table, column, or index name in double quotes, escaping embedded quotes
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,45 @@
+package database
+
+import "testing"
+
+func TestStorageBreakdownLargestFirst(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE big (id INTEGER PRIMARY KEY, body TEXT)",
+		"INSERT INTO big (body) WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 2000) SELECT printf('%0500d', i) FROM n",
+		"CREATE TABLE small (id INTEGER PRIMARY KEY, name TEXT)",
+		"INSERT INTO small (name) VALUES ('a'), ('b')",
+		"CREATE INDEX idx_small_name ON small (name)",
+	)
+
+	breakdown, err := db.StorageBreakdown()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(breakdown.Objects) < 3 {
+		t.Fatalf("got %d objects, want the 2 tables and the index: %+v", len(breakdown.Objects), breakdown.Objects)
+	}
+	if largest := breakdown.Objects[0]; largest.Name != "big" || largest.Type != "table" {
+		t.Fatalf("largest object is %+v, want table big", largest)
+	}
+	// 2000 rows of 500 bytes
+	if breakdown.Objects[0].Bytes < 1000000 {
+		t.Fatalf("big uses %d bytes", breakdown.Objects[0].Bytes)
+	}
+	for i := 1; i < len(breakdown.Objects); i++ {
+		if breakdown.Objects[i].Bytes > breakdown.Objects[i-1].Bytes {
+			t.Fatalf("objects are not ordered by size: %+v", breakdown.Objects)
+		}
+	}
+	found := false
+	for _, obj := range breakdown.Objects {
+		if obj.Name == "idx_small_name" {
+			found = obj.Type == "index" && obj.Table == "small"
+		}
+	}
+	if !found {
+		t.Fatalf("index idx_small_name not reported: %+v", breakdown.Objects)
+	}
+	if breakdown.PageSize == 0 || breakdown.TotalPages == 0 || (breakdown.Method != "dbstat" && breakdown.Method != "estimate") {
+		t.Fatalf("unexpected breakdown %+v", breakdown)
+	}
+}
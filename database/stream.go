@@ -0,0 +1,101 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// ErrStopStream is returned by a row callback to end ExecuteQueryStream early; the stream
+// then ends without an error
+var ErrStopStream = errors.New("stop stream")
+
+// RowFunc receives one row of a streamed query result, keyed by column name. The map is not
+// reused, so the callback may keep it.
+type RowFunc func(row map[string]interface{}) error
+
+// ExecuteQueryStream runs a SELECT query and calls fn for each row as it is read, instead of
+// collecting the rows like ExecuteQuery. Only the current row is held in memory, so results
+// of any size can be processed; the memory budget of ExecuteQuery does not apply. The stream
+// ends at the last row, when fn returns ErrStopStream, or with the first other error fn
+// returns. It returns the result column names in query order.
+//
+// Unlike ExecuteQuery the query is not retried after a lost connection, since fn may already
+// have seen some of the rows.
+func (s *SQLiteDB) ExecuteQueryStream(ctx context.Context, query string, fn RowFunc, args ...interface{}) ([]string, error) {
+	ctx, cancel := s.statementContext(ctx)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query failed: %w", timeoutError(ctx, err))
+	}
+	defer rows.Close()
+
+	reader, err := newRowReader(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		row, err := reader.read(rows)
+		if err != nil {
+			return nil, err
+		}
+		if err := fn(row); err != nil {
+			if errors.Is(err, ErrStopStream) {
+				return reader.columns, nil
+			}
+			return nil, err
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query failed: %w", timeoutError(ctx, err))
+	}
+	return reader.columns, nil
+}
+
+// rowReader scans the rows of one result into maps keyed by column name, converting values
+// as ExecuteQuery does
+type rowReader struct {
+	columns    []string
+	affinities []string
+	values     []interface{}
+	valuePtrs  []interface{}
+}
+
+func newRowReader(rows *sql.Rows) (*rowReader, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get columns: %w", err)
+	}
+	r := &rowReader{
+		columns:    columns,
+		affinities: columnAffinities(rows, len(columns)),
+		values:     make([]interface{}, len(columns)),
+		valuePtrs:  make([]interface{}, len(columns)),
+	}
+	for i := range columns {
+		r.valuePtrs[i] = &r.values[i]
+	}
+	return r, nil
+}
+
+// read scans the current row of rows. The map is not reused.
+func (r *rowReader) read(rows *sql.Rows) (map[string]interface{}, error) {
+	if err := rows.Scan(r.valuePtrs...); err != nil {
+		return nil, fmt.Errorf("failed to scan row: %w", err)
+	}
+	row := make(map[string]interface{}, len(r.columns))
+	for i, col := range r.columns {
+		// BLOBs are the only values returned as []byte, as in ExecuteQuery
+		if b, ok := r.values[i].([]byte); ok {
+			row[col] = Blob(b)
+		} else {
+			row[col] = normalizeValue(r.values[i], r.affinities[i])
+		}
+	}
+	return row, nil
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReadBatchPagesLargeResult(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE big (id INTEGER PRIMARY KEY, amount REAL)",
+		"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 100000) INSERT INTO big SELECT i, i FROM n",
+	)
+
+	const size = 1000
+	query := "SELECT id, amount FROM big ORDER BY id"
+	var cursor string
+	offset, batches := 0, 0
+	for {
+		batch, err := db.ReadBatch(context.Background(), cursor, query, offset, size)
+		if err != nil {
+			t.Fatalf("batch at offset %d: %v", offset, err)
+		}
+		if len(batch.Rows) > size {
+			t.Fatalf("batch of %d rows, want at most %d", len(batch.Rows), size)
+		}
+		for i, row := range batch.Rows {
+			if row["id"] != int64(offset+i+1) {
+				t.Fatalf("row %d has id %v", offset+i, row["id"])
+			}
+			// Integers stored in a REAL column are normalized as in ExecuteQuery
+			if _, ok := row["amount"].(float64); !ok {
+				t.Fatalf("row %d amount is %T, want float64", offset+i, row["amount"])
+			}
+		}
+		offset += len(batch.Rows)
+		batches++
+		if !batch.More {
+			break
+		}
+		if batch.Cursor == "" {
+			t.Fatalf("no cursor kept after batch %d", batches)
+		}
+		cursor = batch.Cursor
+	}
+	if offset != 100000 || batches != 100 {
+		t.Fatalf("read %d rows in %d batches, want 100000 in 100", offset, batches)
+	}
+	if n := len(db.cursors.open); n != 0 {
+		t.Fatalf("%d cursors left open after the last batch", n)
+	}
+}
+
+func TestReadBatchWithoutCursor(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE t (id INTEGER PRIMARY KEY)",
+		"INSERT INTO t VALUES (1), (2), (3), (4), (5)",
+	)
+	query := "SELECT id FROM t ORDER BY id"
+
+	first, err := db.ReadBatch(context.Background(), "", query, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A lost cursor, or one of another query, runs the query again from the offset
+	for _, cursor := range []string{"", "unknown", first.Cursor} {
+		batch, err := db.ReadBatch(context.Background(), cursor, "SELECT id FROM t ORDER BY id DESC", 2, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(batch.Rows) != 2 || batch.Rows[0]["id"] != int64(3) || !batch.More {
+			t.Fatalf("with cursor %q got %v, more %v", cursor, batch.Rows, batch.More)
+		}
+	}
+	next, err := db.ReadBatch(context.Background(), first.Cursor, query, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Rows[0]["id"] != int64(3) {
+		t.Fatalf("continued cursor returned %v", next.Rows)
+	}
+
+	// No cursor is kept for a serialized pool
+	db.SetSerialized(true)
+	batch, err := db.ReadBatch(context.Background(), "", query, 0, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if batch.Cursor != "" || len(db.cursors.open) != 0 {
+		t.Fatalf("cursor kept for a serialized pool")
+	}
+}
@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+)
+
+// ForeignKey is one foreign key constraint of a table; a composite key lists its columns in
+// order
+type ForeignKey struct {
+	ID                int64    `json:"id"`
+	Columns           []string `json:"columns"`
+	ReferencedTable   string   `json:"referenced_table"`
+	ReferencedColumns []string `json:"referenced_columns"`
+	OnUpdate          string   `json:"on_update"`
+	OnDelete          string   `json:"on_delete"`
+}
+
+// TableDetails describes a table in full: its annotated columns, foreign keys, and indexes
+type TableDetails struct {
+	Columns     []map[string]interface{} `json:"columns"`
+	ForeignKeys []ForeignKey             `json:"foreign_keys"`
+	Indexes     []map[string]interface{} `json:"indexes"`
+}
+
+// GetForeignKeyList returns the foreign keys of a table from PRAGMA foreign_key_list. A key
+// that names no parent columns refers to the parent's primary key, whose columns are filled in
+// when the parent table exists.
+func (s *SQLiteDB) GetForeignKeyList(tableName string) ([]ForeignKey, error) {
+	rows, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA foreign_key_list(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return nil, err
+	}
+
+	// Rows come ordered by id and then seq, one per column of the key
+	keys := []ForeignKey{}
+	for _, row := range rows {
+		id := toInt64(row["id"])
+		if len(keys) == 0 || keys[len(keys)-1].ID != id {
+			keys = append(keys, ForeignKey{ID: id, Columns: []string{}, ReferencedColumns: []string{}})
+			key := &keys[len(keys)-1]
+			key.ReferencedTable, _ = row["table"].(string)
+			key.OnUpdate, _ = row["on_update"].(string)
+			key.OnDelete, _ = row["on_delete"].(string)
+		}
+		key := &keys[len(keys)-1]
+		from, _ := row["from"].(string)
+		key.Columns = append(key.Columns, from)
+		if to, ok := row["to"].(string); ok {
+			key.ReferencedColumns = append(key.ReferencedColumns, to)
+		}
+	}
+
+	for i := range keys {
+		if len(keys[i].ReferencedColumns) > 0 {
+			continue
+		}
+		parent, err := s.GetRowidColumn(keys[i].ReferencedTable)
+		if err == nil {
+			keys[i].ReferencedColumns = append(keys[i].ReferencedColumns, parent.PrimaryKey...)
+		}
+	}
+	return keys, nil
+}
+
+// GetTableDetails composes GetAnnotatedSchema, GetForeignKeyList, and GetIndexes, so that one
+// call describes a table's columns and constraints, its relationships, and its indexes. A
+// table that does not exist is an error.
+func (s *SQLiteDB) GetTableDetails(tableName string) (*TableDetails, error) {
+	columns, err := s.GetAnnotatedSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	foreignKeys, err := s.GetForeignKeyList(tableName)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := s.GetIndexes(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if indexes == nil {
+		indexes = []map[string]interface{}{}
+	}
+	return &TableDetails{Columns: columns, ForeignKeys: foreignKeys, Indexes: indexes}, nil
+}
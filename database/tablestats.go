@@ -0,0 +1,123 @@
+package database
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// TableStatsTTL is how long GetTableStats reuses row counts and sizes before reading them again
+const TableStatsTTL = 30 * time.Second
+
+// TableStats is the row count and estimated size of one table
+type TableStats struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+	// Bytes is the size of the table itself and IndexBytes that of its indexes
+	Bytes      int64 `json:"bytes"`
+	IndexBytes int64 `json:"index_bytes"`
+}
+
+// TableStatsReport holds the statistics of GetTableStats and when they were read
+type TableStatsReport struct {
+	// Method is dbstat for exact sizes or estimate, see StorageBreakdown
+	Method    string       `json:"method"`
+	CountedAt string       `json:"counted_at"`
+	Cached    bool         `json:"cached"`
+	Tables    []TableStats `json:"tables"`
+	Note      string       `json:"note"`
+}
+
+// tableStatsCache keeps the statistics of the last GetTableStats call that read them
+type tableStatsCache struct {
+	mu        sync.Mutex
+	dbPath    string
+	countedAt time.Time
+	method    string
+	tables    map[string]TableStats
+}
+
+// GetTableStats reports the row count and size of each named table. COUNT(*) reads the whole
+// table, so the figures are read for every table at once and reused for TableStatsTTL; they
+// can miss changes made in that time, which the report's note points out.
+func (s *SQLiteDB) GetTableStats(tables []string) (*TableStatsReport, error) {
+	cache := &s.tableStats
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	cached := cache.dbPath == s.dbPath && time.Since(cache.countedAt) < TableStatsTTL
+	for _, table := range tables {
+		if _, ok := cache.tables[table]; !ok {
+			cached = false
+		}
+	}
+	if !cached {
+		if err := s.readTableStats(cache); err != nil {
+			return nil, err
+		}
+	}
+
+	report := &TableStatsReport{
+		Method:    cache.method,
+		CountedAt: cache.countedAt.UTC().Format(time.RFC3339),
+		Cached:    cached,
+		Tables:    make([]TableStats, 0, len(tables)),
+		Note: fmt.Sprintf("Row counts and sizes are reused for up to %s, so changes since counted_at (%s ago) may be missing",
+			TableStatsTTL, time.Since(cache.countedAt).Round(time.Second)),
+	}
+	for _, table := range tables {
+		stats, ok := cache.tables[table]
+		if !ok {
+			return nil, fmt.Errorf("table '%s' does not exist", table)
+		}
+		report.Tables = append(report.Tables, stats)
+	}
+	return report, nil
+}
+
+// readTableStats fills the cache with the row count and size of every table
+func (s *SQLiteDB) readTableStats(cache *tableStatsCache) error {
+	breakdown, err := s.StorageBreakdown()
+	if err != nil {
+		return err
+	}
+
+	tables := make(map[string]TableStats)
+	counted := make(map[string]bool)
+	for _, obj := range breakdown.Objects {
+		stats := tables[obj.Table]
+		stats.Table = obj.Table
+		if obj.Type == "index" {
+			stats.IndexBytes += obj.Bytes
+		} else {
+			stats.Bytes += obj.Bytes
+			if obj.Rows != nil {
+				stats.Rows = *obj.Rows
+				counted[obj.Table] = true
+			}
+		}
+		tables[obj.Table] = stats
+	}
+
+	// dbstat reports pages, not rows, and virtual tables have no pages of their own
+	names, err := s.GetTables()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		stats := tables[name]
+		stats.Table = name
+		if !counted[name] {
+			if err := s.db.QueryRowContext(s.ctx(), fmt.Sprintf("SELECT COUNT(*) FROM %s", quoteIdentifier(name))).Scan(&stats.Rows); err != nil {
+				return fmt.Errorf("failed to count rows of %s: %w", name, err)
+			}
+		}
+		tables[name] = stats
+	}
+
+	cache.dbPath = s.dbPath
+	cache.countedAt = time.Now()
+	cache.method = breakdown.Method
+	cache.tables = tables
+	return nil
+}
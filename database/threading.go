@@ -0,0 +1,77 @@
+package database
+
+import (
+	"strings"
+)
+
+// threadingModes names the values of SQLite's THREADSAFE compile option
+var threadingModes = map[string]string{"0": "single-thread", "1": "serialized", "2": "multi-thread"}
+
+// ThreadingStatus reports how SQLite and the connection pool handle concurrent access
+type ThreadingStatus struct {
+	// LibraryMode is the threading mode SQLite was compiled with: serialized, multi-thread,
+	// or single-thread
+	LibraryMode string `json:"library_mode"`
+	// ConnectionMutex is "full": the driver opens every connection with SQLITE_OPEN_FULLMUTEX,
+	// so one connection is never used by two threads at once
+	ConnectionMutex string `json:"connection_mutex"`
+	// Serialized is true when the pool holds a single connection, so statements from
+	// concurrent tool calls run one after another instead of competing for the write lock
+	Serialized         bool `json:"serialized"`
+	MaxOpenConnections int  `json:"max_open_connections"` // 0 means unlimited
+	OpenConnections    int  `json:"open_connections"`
+}
+
+// SetSerialized limits the connection pool to a single connection, or lifts the limit.
+// SQLite allows one writer at a time: with several connections a write that cannot get the
+// lock within the busy timeout fails with "database is locked", while with one connection
+// concurrent calls queue for it in database/sql instead. That is the safe choice for
+// write-heavy workloads, at the cost of readers no longer running in parallel. The setting
+// applies at once and is kept when switching databases.
+func (s *SQLiteDB) SetSerialized(serialized bool) {
+	s.settingsMu.Lock()
+	s.serialized = serialized
+	s.settingsMu.Unlock()
+
+	if serialized {
+		// A query left open would hold the only connection
+		s.closeCursors()
+		s.db.SetMaxOpenConns(1)
+	} else {
+		s.db.SetMaxOpenConns(0)
+	}
+}
+
+// ThreadingMode reports the threading mode SQLite was compiled with and whether the pool is
+// serialized to one connection
+func (s *SQLiteDB) ThreadingMode() (*ThreadingStatus, error) {
+	rows, err := s.db.QueryContext(s.ctx(), "PRAGMA compile_options")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	status := &ThreadingStatus{LibraryMode: "unknown", ConnectionMutex: "full"}
+	for rows.Next() {
+		var option string
+		if err := rows.Scan(&option); err != nil {
+			return nil, err
+		}
+		if value, ok := strings.CutPrefix(option, "THREADSAFE="); ok {
+			if mode, ok := threadingModes[value]; ok {
+				status.LibraryMode = mode
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	s.settingsMu.RLock()
+	status.Serialized = s.serialized
+	s.settingsMu.RUnlock()
+	stats := s.db.Stats()
+	status.MaxOpenConnections = stats.MaxOpenConnections
+	status.OpenConnections = stats.OpenConnections
+	return status, nil
+}
@@ -0,0 +1,74 @@
+package database
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSerializedConcurrentWrites(t *testing.T) {
+	db := newTestDB(t, "CREATE TABLE log (worker INTEGER, n INTEGER)")
+	// Without a busy timeout any contention for the write lock would fail at once
+	if err := db.SetBusyTimeout(0); err != nil {
+		t.Fatal(err)
+	}
+	db.SetSerialized(true)
+
+	const workers, writes = 8, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*writes)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for n := 0; n < writes; n++ {
+				if _, err := db.ExecuteStatement("INSERT INTO log VALUES (?, ?)", w, n); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if strings.Contains(err.Error(), "database is locked") {
+			t.Fatalf("serialized write failed: %v", err)
+		}
+		t.Fatalf("write failed: %v", err)
+	}
+	if got := queryInt(t, db, "SELECT COUNT(*) FROM log"); got != workers*writes {
+		t.Fatalf("got %d rows, want %d", got, workers*writes)
+	}
+}
+
+func TestThreadingMode(t *testing.T) {
+	db := newTestDB(t)
+	db.SetSerialized(true)
+	status, err := db.ThreadingMode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !status.Serialized || status.MaxOpenConnections != 1 {
+		t.Fatalf("got serialized %v with max %d connections", status.Serialized, status.MaxOpenConnections)
+	}
+	if status.LibraryMode == "unknown" {
+		t.Fatal("library threading mode not reported")
+	}
+
+	// The limit survives reopening the pool
+	if err := db.SetBusyTimeout(time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if got := db.db.Stats().MaxOpenConnections; got != 1 {
+		t.Fatalf("max open connections %d after reopen, want 1", got)
+	}
+
+	db.SetSerialized(false)
+	if status, err = db.ThreadingMode(); err != nil {
+		t.Fatal(err)
+	}
+	if status.Serialized || status.MaxOpenConnections != 0 {
+		t.Fatalf("got serialized %v with max %d connections", status.Serialized, status.MaxOpenConnections)
+	}
+}
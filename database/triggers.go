@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// disabledTriggersTable stores the DDL of triggers removed by SetTriggersEnabled so they
+// survive a server restart and can be recreated later
+const disabledTriggersTable = "_mcp_disabled_triggers"
+
+// SetTriggersEnabled disables or re-enables all triggers of a table. SQLite has no native way
+// to disable a trigger, so disabling drops the triggers after saving their DDL, and enabling
+// recreates them from the saved DDL. Both directions run in a single transaction, so a failure
+// leaves every trigger in its previous state. Returns the names of the affected triggers.
+func (s *SQLiteDB) SetTriggersEnabled(tableName string, enabled bool) ([]string, error) {
+	var names []string
+
+	err := s.Transaction(func(tx *sql.Tx) error {
+		_, err := tx.Exec(fmt.Sprintf(`
+			CREATE TABLE IF NOT EXISTS %s (
+				table_name TEXT NOT NULL,
+				trigger_name TEXT NOT NULL PRIMARY KEY,
+				sql TEXT NOT NULL,
+				disabled_at TEXT NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)
+		`, disabledTriggersTable))
+		if err != nil {
+			return fmt.Errorf("failed to create trigger store: %w", err)
+		}
+
+		if enabled {
+			names, err = restoreTriggers(tx, tableName)
+		} else {
+			names, err = removeTriggers(tx, tableName)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// removeTriggers saves and drops the triggers of a table
+func removeTriggers(tx *sql.Tx, tableName string) ([]string, error) {
+	rows, err := tx.Query("SELECT name, sql FROM sqlite_master WHERE type='trigger' AND tbl_name=? ORDER BY name", tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	type trigger struct{ name, sql string }
+	var triggers []trigger
+	for rows.Next() {
+		var t trigger
+		if err := rows.Scan(&t.name, &t.sql); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, t := range triggers {
+		if _, err := tx.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s (table_name, trigger_name, sql) VALUES (?, ?, ?)", disabledTriggersTable),
+			tableName, t.name, t.sql); err != nil {
+			return nil, fmt.Errorf("failed to save trigger '%s': %w", t.name, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("DROP TRIGGER %s", quoteIdentifier(t.name))); err != nil {
+			return nil, fmt.Errorf("failed to drop trigger '%s': %w", t.name, err)
+		}
+		names = append(names, t.name)
+	}
+
+	return names, nil
+}
+
+// restoreTriggers recreates the saved triggers of a table and forgets them
+func restoreTriggers(tx *sql.Tx, tableName string) ([]string, error) {
+	rows, err := tx.Query(fmt.Sprintf("SELECT trigger_name, sql FROM %s WHERE table_name=? ORDER BY trigger_name", disabledTriggersTable), tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	type trigger struct{ name, sql string }
+	var triggers []trigger
+	for rows.Next() {
+		var t trigger
+		if err := rows.Scan(&t.name, &t.sql); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		triggers = append(triggers, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, t := range triggers {
+		if _, err := tx.Exec(t.sql); err != nil {
+			return nil, fmt.Errorf("failed to recreate trigger '%s': %w", t.name, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE trigger_name=?", disabledTriggersTable), t.name); err != nil {
+			return nil, err
+		}
+		names = append(names, t.name)
+	}
+
+	return names, nil
+}
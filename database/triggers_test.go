@@ -0,0 +1,79 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetTriggersEnabled(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE events (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE counter (n INTEGER)",
+		"INSERT INTO counter VALUES (0)",
+		"CREATE TRIGGER events_count AFTER INSERT ON events BEGIN UPDATE counter SET n = n + 1; END",
+		"CREATE TRIGGER events_upper AFTER INSERT ON events BEGIN UPDATE events SET name = upper(name) WHERE id = NEW.id; END",
+	)
+
+	names, err := db.SetTriggersEnabled("events", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(names, []string{"events_count", "events_upper"}) {
+		t.Fatalf("disabled %v", names)
+	}
+
+	// A bulk insert without side effects
+	for i := 0; i < 100; i++ {
+		if _, err := db.ExecuteStatement("INSERT INTO events (name) VALUES ('quiet')"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := queryInt(t, db, "SELECT n FROM counter"); n != 0 {
+		t.Fatalf("counter is %d while triggers are disabled", n)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM events WHERE name = 'quiet'"); n != 100 {
+		t.Fatalf("%d rows keep their name, want 100", n)
+	}
+
+	names, err = db.SetTriggersEnabled("events", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 2 {
+		t.Fatalf("re-enabled %v", names)
+	}
+	if _, err := db.ExecuteStatement("INSERT INTO events (name) VALUES ('loud')"); err != nil {
+		t.Fatal(err)
+	}
+	if n := queryInt(t, db, "SELECT n FROM counter"); n != 1 {
+		t.Fatalf("counter is %d after re-enabling, want 1", n)
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM events WHERE name = 'LOUD'"); n != 1 {
+		t.Fatal("events_upper did not run after re-enabling")
+	}
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM "+disabledTriggersTable); n != 0 {
+		t.Fatalf("%d triggers still saved as disabled", n)
+	}
+}
+
+func TestSetTriggersEnabledRestoresOnError(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE a (id INTEGER)",
+		"CREATE TABLE log (id INTEGER)",
+		"CREATE TRIGGER a_log AFTER INSERT ON a BEGIN INSERT INTO log VALUES (NEW.id); END",
+	)
+	if _, err := db.SetTriggersEnabled("a", false); err != nil {
+		t.Fatal(err)
+	}
+	// A trigger of the same name recreated meanwhile makes restoring fail
+	if _, err := db.ExecuteStatement("CREATE TRIGGER a_log AFTER DELETE ON a BEGIN SELECT 1; END"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.SetTriggersEnabled("a", true); err == nil {
+		t.Fatal("restoring over an existing trigger succeeded")
+	}
+	// The failed restore is rolled back, so the saved trigger can still be restored later
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM "+disabledTriggersTable); n != 1 {
+		t.Fatalf("%d saved triggers after the failed restore, want 1", n)
+	}
+}
@@ -0,0 +1,195 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// UpsertResult reports the outcome of Upsert
+type UpsertResult struct {
+	Table string `json:"table"`
+	// Action is "inserted", "updated", or "unchanged" when the row existed and every column
+	// of the row is a conflict column, so there was nothing to update
+	Action       string `json:"action"`
+	RowsAffected int64  `json:"rows_affected"`
+	// Rowid is the rowid of the inserted or updated row, omitted for WITHOUT ROWID tables
+	Rowid     *int64 `json:"rowid,omitempty"`
+	Statement string `json:"statement"`
+}
+
+// Upsert inserts row into a table or, when a row with the same values in conflictColumns
+// exists, updates that row's other columns, with INSERT ... ON CONFLICT DO UPDATE. The
+// conflict columns must have values in row and exactly match the table's primary key or a
+// unique index, since SQLite only accepts such a conflict target. Whether the row existed is
+// looked up in the same transaction as the write, so the reported action is exact.
+func (s *SQLiteDB) Upsert(tableName string, row map[string]interface{}, conflictColumns []string) (*UpsertResult, error) {
+	if len(row) == 0 {
+		return nil, fmt.Errorf("the row has no columns")
+	}
+	if len(conflictColumns) == 0 {
+		return nil, fmt.Errorf("at least one conflict column is required")
+	}
+	columns, err := s.GetTableSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+	known := make(map[string]string)
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		known[strings.ToLower(name)] = name
+	}
+
+	// Columns in a stable order, spelled as declared
+	var names []string
+	values := make(map[string]interface{})
+	for name, value := range row {
+		declared, ok := known[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("table '%s' has no column '%s'", tableName, name)
+		}
+		if _, dup := values[declared]; dup {
+			return nil, fmt.Errorf("column '%s' appears more than once in the row", declared)
+		}
+		names = append(names, declared)
+		values[declared] = value
+	}
+	sort.Strings(names)
+
+	target := make([]string, len(conflictColumns))
+	isTarget := make(map[string]bool)
+	for i, name := range conflictColumns {
+		declared, ok := known[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("table '%s' has no column '%s'", tableName, name)
+		}
+		if _, ok := values[declared]; !ok {
+			return nil, fmt.Errorf("conflict column '%s' needs a value in the row", declared)
+		}
+		target[i] = declared
+		isTarget[declared] = true
+	}
+	if err := s.checkConflictTarget(tableName, target); err != nil {
+		return nil, err
+	}
+
+	quoted := make([]string, len(names))
+	placeholders := make([]string, len(names))
+	args := make([]interface{}, len(names))
+	var updates []string
+	for i, name := range names {
+		quoted[i] = quoteIdentifier(name)
+		placeholders[i] = "?"
+		args[i] = values[name]
+		if !isTarget[name] {
+			updates = append(updates, fmt.Sprintf("%s = excluded.%s", quoted[i], quoted[i]))
+		}
+	}
+	quotedTarget := make([]string, len(target))
+	conditions := make([]string, len(target))
+	keyArgs := make([]interface{}, len(target))
+	for i, name := range target {
+		quotedTarget[i] = quoteIdentifier(name)
+		conditions[i] = quotedTarget[i] + " = ?"
+		keyArgs[i] = values[name]
+	}
+	action := "DO NOTHING"
+	if len(updates) > 0 {
+		action = "DO UPDATE SET " + strings.Join(updates, ", ")
+	}
+	statement := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (%s) %s", quoteIdentifier(tableName),
+		strings.Join(quoted, ", "), strings.Join(placeholders, ", "), strings.Join(quotedTarget, ", "), action)
+
+	rowidInfo, err := s.GetRowidColumn(tableName)
+	if err != nil {
+		return nil, err
+	}
+	hasRowid := rowidInfo.RowidColumn != ""
+	result := &UpsertResult{Table: tableName, Statement: statement}
+	err = s.RetryTransaction(func(tx *sql.Tx) error {
+		result.Rowid = nil
+		// A NULL in the key never conflicts, and = never matches it either
+		var existing sql.NullInt64
+		lookup := "SELECT 1"
+		if hasRowid {
+			lookup = "SELECT " + quoteIdentifier(rowidInfo.RowidColumn)
+		}
+		err := tx.QueryRowContext(s.ctx(), fmt.Sprintf("%s FROM %s WHERE %s", lookup, quoteIdentifier(tableName),
+			strings.Join(conditions, " AND ")), keyArgs...).Scan(&existing)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		exists := err == nil
+
+		res, err := tx.ExecContext(s.ctx(), statement, args...)
+		if err != nil {
+			return err
+		}
+		if result.RowsAffected, err = res.RowsAffected(); err != nil {
+			return err
+		}
+		switch {
+		case !exists:
+			result.Action = "inserted"
+			if hasRowid {
+				id, err := res.LastInsertId()
+				if err != nil {
+					return err
+				}
+				result.Rowid = &id
+			}
+		case len(updates) == 0:
+			result.Action = "unchanged"
+		default:
+			result.Action = "updated"
+		}
+		if exists && hasRowid {
+			result.Rowid = &existing.Int64
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// checkConflictTarget verifies that columns are exactly the columns of the table's primary key
+// or of a unique index without a WHERE clause, in any order
+func (s *SQLiteDB) checkConflictTarget(tableName string, columns []string) error {
+	keys, err := s.DetectKeys(tableName)
+	if err != nil {
+		return err
+	}
+	want := columnSet(columns)
+	var usable []string
+	for _, key := range keys.CandidateKeys {
+		if key.Partial {
+			continue
+		}
+		if columnSet(key.Columns) == want {
+			return nil
+		}
+		usable = append(usable, "("+strings.Join(key.Columns, ", ")+")")
+	}
+	if len(usable) == 0 {
+		return fmt.Errorf("table '%s' has no primary key or unique index to detect conflicts with; create a unique index on (%s) first",
+			tableName, strings.Join(columns, ", "))
+	}
+	return fmt.Errorf("no primary key or unique index of table '%s' is on exactly (%s); use one of %s as conflict columns, or create a unique index on these columns",
+		tableName, strings.Join(columns, ", "), strings.Join(usable, ", "))
+}
+
+// columnSet returns a canonical form of a set of column names, ignoring order and case
+func columnSet(columns []string) string {
+	lower := make([]string, len(columns))
+	for i, col := range columns {
+		lower[i] = strings.ToLower(col)
+	}
+	sort.Strings(lower)
+	return strings.Join(lower, "\x00")
+}
@@ -0,0 +1,36 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"math"
+)
+
+// GetUserVersion reads PRAGMA user_version, an integer SQLite stores in the database header
+// for the application's own use, typically the schema version a migration tool has applied
+func (s *SQLiteDB) GetUserVersion() (int64, error) {
+	var version int64
+	err := s.db.QueryRowContext(s.ctx(), "PRAGMA user_version").Scan(&version)
+	return version, err
+}
+
+// SetUserVersion sets PRAGMA user_version and returns the previous value, read in the same
+// transaction. The header holds a 32-bit signed integer; only non-negative values are accepted.
+func (s *SQLiteDB) SetUserVersion(version int64) (int64, error) {
+	if version < 0 || version > math.MaxInt32 {
+		return 0, fmt.Errorf("user_version must be an integer from 0 to %d", math.MaxInt32)
+	}
+	var previous int64
+	err := s.RetryTransaction(func(tx *sql.Tx) error {
+		if err := tx.QueryRowContext(s.ctx(), "PRAGMA user_version").Scan(&previous); err != nil {
+			return err
+		}
+		// PRAGMA arguments cannot be bound parameters
+		_, err := tx.ExecContext(s.ctx(), fmt.Sprintf("PRAGMA user_version = %d", version))
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return previous, nil
+}
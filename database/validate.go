@@ -0,0 +1,215 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RowProblem describes one reason a proposed row would not insert cleanly
+type RowProblem struct {
+	Column  string `json:"column,omitempty"`
+	Problem string `json:"problem"`
+}
+
+// columnAffinity determines a column's type affinity from its declared type using SQLite's rules
+func columnAffinity(declaredType string) string {
+	t := strings.ToUpper(declaredType)
+	switch {
+	case strings.Contains(t, "INT"):
+		return "INTEGER"
+	case strings.Contains(t, "CHAR"), strings.Contains(t, "CLOB"), strings.Contains(t, "TEXT"):
+		return "TEXT"
+	case t == "" || strings.Contains(t, "BLOB"):
+		return "BLOB"
+	case strings.Contains(t, "REAL"), strings.Contains(t, "FLOA"), strings.Contains(t, "DOUB"):
+		return "REAL"
+	default:
+		return "NUMERIC"
+	}
+}
+
+// valueMatchesAffinity reports whether a JSON-decoded value can be stored in a column
+// of the given affinity without being kept as a mismatched type
+func valueMatchesAffinity(value interface{}, affinity string) bool {
+	switch affinity {
+	case "INTEGER", "NUMERIC", "REAL":
+		switch v := value.(type) {
+		case float64, int, int64, bool:
+			return true
+		case string:
+			_, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			return err == nil
+		}
+		return false
+	}
+	// TEXT and BLOB affinity columns accept any value
+	return true
+}
+
+// ValidateRow checks a proposed row against a table's schema without inserting it.
+// It reports unknown columns, missing NOT NULL values, affinity mismatches, and
+// values that collide with existing rows on a single-column unique index.
+func (s *SQLiteDB) ValidateRow(tableName string, row map[string]interface{}) ([]RowProblem, error) {
+	columns, err := s.GetTableSchema(tableName)
+	if err != nil {
+		return nil, err
+	}
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("table '%s' does not exist", tableName)
+	}
+
+	problems := []RowProblem{}
+	known := make(map[string]map[string]interface{})
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		known[strings.ToLower(name)] = col
+	}
+
+	// Unknown columns, in a stable order
+	var names []string
+	for name := range row {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, ok := known[strings.ToLower(name)]; !ok {
+			problems = append(problems, RowProblem{Column: name, Problem: "column does not exist"})
+		}
+	}
+
+	provided := make(map[string]interface{})
+	for name, value := range row {
+		provided[strings.ToLower(name)] = value
+	}
+
+	for _, col := range columns {
+		name, _ := col["name"].(string)
+		colType, _ := col["type"].(string)
+		value, present := provided[strings.ToLower(name)]
+		notNull := toInt64(col["notnull"]) != 0
+		hasDefault := col["dflt_value"] != nil
+		// An INTEGER PRIMARY KEY column is filled from the rowid when omitted
+		isRowidAlias := toInt64(col["pk"]) == 1 && strings.EqualFold(colType, "INTEGER")
+
+		if !present || value == nil {
+			if notNull && !hasDefault && !isRowidAlias {
+				problems = append(problems, RowProblem{Column: name, Problem: "NOT NULL column has no value and no default"})
+			}
+			continue
+		}
+
+		affinity := columnAffinity(colType)
+		if !valueMatchesAffinity(value, affinity) {
+			problems = append(problems, RowProblem{
+				Column:  name,
+				Problem: fmt.Sprintf("value %v is not compatible with %s affinity", value, affinity),
+			})
+		}
+	}
+
+	uniqueProblems, err := s.checkUniqueCollisions(tableName, provided)
+	if err != nil {
+		return nil, err
+	}
+	problems = append(problems, uniqueProblems...)
+
+	// CHECK and remaining constraints are only known to SQLite, so try the insert and roll it
+	// back. A read-only database refuses even a rolled back insert, so they are not checked.
+	s.settingsMu.RLock()
+	readOnly := s.readOnly
+	s.settingsMu.RUnlock()
+	if len(problems) == 0 && !readOnly {
+		if err := s.trialInsert(tableName, row); err != nil {
+			problems = append(problems, RowProblem{Problem: err.Error()})
+		}
+	}
+
+	return problems, nil
+}
+
+// trialInsert inserts the row inside a transaction that is always rolled back
+func (s *SQLiteDB) trialInsert(tableName string, row map[string]interface{}) error {
+	var names []string
+	for name := range row {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var quoted, placeholders []string
+	var args []interface{}
+	for _, name := range names {
+		quoted = append(quoted, quoteIdentifier(name))
+		placeholders = append(placeholders, "?")
+		args = append(args, row[name])
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", quoteIdentifier(tableName))
+	if len(names) > 0 {
+		query = fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+			quoteIdentifier(tableName), strings.Join(quoted, ", "), strings.Join(placeholders, ", "))
+	}
+
+	tx, err := s.db.BeginTx(s.ctx(), nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(s.ctx(), query, args...)
+	return err
+}
+
+// checkUniqueCollisions looks for existing rows that already hold the provided values of a unique index
+func (s *SQLiteDB) checkUniqueCollisions(tableName string, provided map[string]interface{}) ([]RowProblem, error) {
+	indexList, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_list(%s)", quoteIdentifier(tableName)))
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []RowProblem
+	for _, index := range indexList {
+		if toInt64(index["unique"]) != 1 || toInt64(index["partial"]) != 0 {
+			continue
+		}
+		indexName, _ := index["name"].(string)
+		indexInfo, err := s.ExecuteQuery(fmt.Sprintf("PRAGMA index_info(%s)", quoteIdentifier(indexName)))
+		if err != nil {
+			return nil, err
+		}
+
+		var conditions []string
+		var args []interface{}
+		var colNames []string
+		complete := true
+		for _, col := range indexInfo {
+			name, ok := col["name"].(string)
+			value, present := provided[strings.ToLower(name)]
+			if !ok || !present || value == nil {
+				complete = false
+				break
+			}
+			conditions = append(conditions, fmt.Sprintf("%s = ?", quoteIdentifier(name)))
+			args = append(args, value)
+			colNames = append(colNames, name)
+		}
+		if !complete || len(conditions) == 0 {
+			continue
+		}
+
+		var count int64
+		query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE %s", quoteIdentifier(tableName), strings.Join(conditions, " AND "))
+		if err := s.db.QueryRowContext(s.ctx(), query, args...).Scan(&count); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			problems = append(problems, RowProblem{
+				Column:  strings.Join(colNames, ", "),
+				Problem: fmt.Sprintf("value already exists and would violate unique index '%s'", indexName),
+			})
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,52 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRow(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT NOT NULL UNIQUE, age INTEGER CHECK (age >= 0), team TEXT NOT NULL DEFAULT 'none')",
+		"INSERT INTO users (email, age) VALUES ('taken@example.com', 30)",
+	)
+
+	tests := []struct {
+		row  map[string]interface{}
+		want []string // substrings of the problems, in order
+	}{
+		{map[string]interface{}{"email": "new@example.com", "age": float64(20)}, nil},
+		{map[string]interface{}{"age": float64(20)}, []string{"email: NOT NULL column has no value"}},
+		{map[string]interface{}{"email": "x@example.com", "age": "old", "shoe": 1}, []string{"shoe: column does not exist", "age: value old is not compatible with INTEGER"}},
+		{map[string]interface{}{"email": "taken@example.com"}, []string{"email"}},
+		// Only the trial insert finds a failing CHECK
+		{map[string]interface{}{"email": "y@example.com", "age": float64(-1)}, []string{"CHECK constraint failed"}},
+	}
+	for _, test := range tests {
+		problems, err := db.ValidateRow("users", test.row)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(problems) != len(test.want) {
+			t.Errorf("%v: got problems %+v, want %d", test.row, problems, len(test.want))
+			continue
+		}
+		for i, want := range test.want {
+			got := problems[i].Problem
+			if problems[i].Column != "" {
+				got = problems[i].Column + ": " + got
+			}
+			if !strings.Contains(got, want) {
+				t.Errorf("%v: problem %q, want %q", test.row, got, want)
+			}
+		}
+	}
+
+	// Validating inserts nothing
+	if n := queryInt(t, db, "SELECT COUNT(*) FROM users"); n != 1 {
+		t.Fatalf("%d rows after validating, want 1", n)
+	}
+	if _, err := db.ValidateRow("missing", map[string]interface{}{}); err == nil {
+		t.Fatal("no error for a missing table")
+	}
+}
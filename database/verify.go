@@ -0,0 +1,261 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// verifyAlias is the schema name a backup is attached under while it is verified
+const verifyAlias = "_mcp_verify"
+
+// TableComparison compares one table of the source database with its copy in a backup
+type TableComparison struct {
+	Name           string `json:"name"`
+	SourceRows     int64  `json:"source_rows"`
+	BackupRows     int64  `json:"backup_rows"`
+	SourceChecksum string `json:"source_checksum,omitempty"`
+	BackupChecksum string `json:"backup_checksum,omitempty"`
+	Match          bool   `json:"match"`
+	// Problem explains a mismatch, such as a table missing from one side
+	Problem string `json:"problem,omitempty"`
+}
+
+// BackupVerification reports whether a backup is a faithful copy of the source database
+type BackupVerification struct {
+	Source string `json:"source"`
+	Backup string `json:"backup"`
+	Match  bool   `json:"match"`
+	// Mismatched names the tables whose contents differ
+	Mismatched []string          `json:"mismatched_tables"`
+	Tables     []TableComparison `json:"tables"`
+	// SchemaDifferences lists indexes, views, and triggers that differ between the two
+	SchemaDifferences []string `json:"schema_differences,omitempty"`
+}
+
+// VerifyBackup compares the current database with the backup file at path. Every table is
+// checksummed on both sides from its rows in a canonical order, so the result does not depend
+// on rowids or page layout, which VACUUM INTO may change; the table definitions and the other
+// schema objects are compared by their SQL. Both databases are read in one transaction, so
+// writes to the source during the comparison are not seen. The backup is opened read-only.
+func (s *SQLiteDB) VerifyBackup(path string) (*BackupVerification, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("cannot open backup: %w", err)
+	}
+
+	ctx := s.ctx()
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, fmt.Sprintf("ATTACH DATABASE ? AS %s", verifyAlias), "file:"+uriEscaper.Replace(path)+"?mode=ro"); err != nil {
+		return nil, fmt.Errorf("failed to open backup: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), fmt.Sprintf("DETACH DATABASE %s", verifyAlias))
+
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK")
+
+	source, err := schemaSQL(ctx, conn, "main")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the source schema: %w", err)
+	}
+	backup, err := schemaSQL(ctx, conn, verifyAlias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the backup schema, it may not be a database: %w", err)
+	}
+
+	result := &BackupVerification{
+		Source:     s.GetCurrentDatabasePath(),
+		Backup:     path,
+		Mismatched: []string{},
+	}
+
+	// Objects are visited in sorted order so the report is deterministic
+	names := make([]string, 0, len(source)+len(backup))
+	for key := range source {
+		names = append(names, key)
+	}
+	for key := range backup {
+		if _, ok := source[key]; !ok {
+			names = append(names, key)
+		}
+	}
+	sort.Strings(names)
+
+	for _, key := range names {
+		kind, name, _ := strings.Cut(key, " ")
+		sourceSQL, inSource := source[key]
+		backupSQL, inBackup := backup[key]
+
+		if kind != "table" {
+			switch {
+			case !inBackup:
+				result.SchemaDifferences = append(result.SchemaDifferences, fmt.Sprintf("%s %s is missing from the backup", kind, name))
+			case !inSource:
+				result.SchemaDifferences = append(result.SchemaDifferences, fmt.Sprintf("%s %s is only in the backup", kind, name))
+			case sourceSQL != backupSQL:
+				result.SchemaDifferences = append(result.SchemaDifferences, fmt.Sprintf("%s %s has a different definition", kind, name))
+			}
+			continue
+		}
+
+		comparison := TableComparison{Name: name}
+		switch {
+		case !inBackup:
+			comparison.Problem = "missing from the backup"
+		case !inSource:
+			comparison.Problem = "only in the backup"
+		case sourceSQL != backupSQL:
+			comparison.Problem = "the table definitions differ"
+		case virtualTablePattern.MatchString(sourceSQL):
+			// Virtual table contents live in shadow tables or outside the file
+			comparison.Match = true
+			result.Tables = append(result.Tables, comparison)
+			continue
+		}
+		if inSource {
+			if comparison.SourceRows, comparison.SourceChecksum, err = tableChecksum(ctx, conn, "main", name); err != nil {
+				return nil, fmt.Errorf("failed to checksum table '%s' in the source: %w", name, err)
+			}
+		}
+		if inBackup {
+			if comparison.BackupRows, comparison.BackupChecksum, err = tableChecksum(ctx, conn, verifyAlias, name); err != nil {
+				comparison.Problem = fmt.Sprintf("the backup copy cannot be read: %v", err)
+			}
+		}
+		if comparison.Problem == "" {
+			switch {
+			case comparison.SourceRows != comparison.BackupRows:
+				comparison.Problem = fmt.Sprintf("row counts differ: %d in the source, %d in the backup", comparison.SourceRows, comparison.BackupRows)
+			case comparison.SourceChecksum != comparison.BackupChecksum:
+				comparison.Problem = "row contents differ"
+			default:
+				comparison.Match = true
+			}
+		}
+		if !comparison.Match {
+			result.Mismatched = append(result.Mismatched, name)
+		}
+		result.Tables = append(result.Tables, comparison)
+	}
+
+	result.Match = len(result.Mismatched) == 0 && len(result.SchemaDifferences) == 0
+	return result, nil
+}
+
+// schemaSQL maps "type name" to the SQL of every user object in a schema; autoindexes have no
+// SQL and are covered by their table's definition
+func schemaSQL(ctx context.Context, conn *sql.Conn, schema string) (map[string]string, error) {
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf(
+		"SELECT type, name, sql FROM %s.sqlite_master WHERE sql IS NOT NULL AND name NOT LIKE 'sqlite_%%'", quoteIdentifier(schema)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	objects := make(map[string]string)
+	for rows.Next() {
+		var kind, name, sqlText string
+		if err := rows.Scan(&kind, &name, &sqlText); err != nil {
+			return nil, err
+		}
+		objects[kind+" "+name] = sqlText
+	}
+	return objects, rows.Err()
+}
+
+// tableChecksum returns the row count and a SHA-256 checksum of a table's rows. Rows are read
+// sorted by every column, so the checksum only depends on the table's contents.
+func tableChecksum(ctx context.Context, conn *sql.Conn, schema, table string) (int64, string, error) {
+	cols, err := conn.QueryContext(ctx, "SELECT name FROM pragma_table_xinfo(?, ?) WHERE hidden = 0 ORDER BY cid", table, schema)
+	if err != nil {
+		return 0, "", err
+	}
+	var columns []string
+	for cols.Next() {
+		var name string
+		if err := cols.Scan(&name); err != nil {
+			cols.Close()
+			return 0, "", err
+		}
+		columns = append(columns, quoteIdentifier(name))
+	}
+	cols.Close()
+	if err := cols.Err(); err != nil {
+		return 0, "", err
+	}
+	if len(columns) == 0 {
+		return 0, "", fmt.Errorf("table has no columns")
+	}
+
+	list := strings.Join(columns, ", ")
+	rows, err := conn.QueryContext(ctx, fmt.Sprintf("SELECT %s FROM %s.%s ORDER BY %s", list, quoteIdentifier(schema), quoteIdentifier(table), list))
+	if err != nil {
+		return 0, "", err
+	}
+	defer rows.Close()
+
+	values := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+
+	sum := sha256.New()
+	var count int64
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return 0, "", err
+		}
+		for _, value := range values {
+			hashValue(sum, value)
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return 0, "", err
+	}
+	return count, hex.EncodeToString(sum.Sum(nil)), nil
+}
+
+// hashValue writes a value to h with its storage class and length, so that different rows
+// cannot produce the same byte stream
+func hashValue(h hash.Hash, value interface{}) {
+	var tag byte
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		tag = 'n'
+	case int64:
+		tag = 'i'
+		data = binary.BigEndian.AppendUint64(nil, uint64(v))
+	case float64:
+		tag = 'r'
+		data = binary.BigEndian.AppendUint64(nil, math.Float64bits(v))
+	case string:
+		tag, data = 't', []byte(v)
+	case []byte:
+		tag, data = 'b', v
+	case time.Time:
+		tag, data = 't', []byte(v.Format(time.RFC3339Nano))
+	default:
+		tag, data = 't', []byte(fmt.Sprint(v))
+	}
+	h.Write([]byte{tag})
+	h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(data))))
+	h.Write(data)
+}
@@ -0,0 +1,80 @@
+package database
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBackup(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, photo BLOB)",
+		"CREATE TABLE tags (name TEXT)",
+		"CREATE INDEX idx_tags ON tags (name)",
+		"INSERT INTO users VALUES (1, 'ann', x'0102'), (2, 'bo', NULL)",
+		"INSERT INTO tags VALUES ('a'), ('b')",
+	)
+	backup := filepath.Join(t.TempDir(), "backup.db")
+	if _, err := db.BackupTo(backup); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := db.VerifyBackup(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Match || len(result.Mismatched) != 0 || len(result.SchemaDifferences) != 0 || len(result.Tables) != 2 {
+		t.Fatalf("a fresh backup does not match: %+v", result)
+	}
+	for _, table := range result.Tables {
+		if table.SourceChecksum == "" || table.SourceChecksum != table.BackupChecksum {
+			t.Fatalf("table %s checksums %q and %q", table.Name, table.SourceChecksum, table.BackupChecksum)
+		}
+	}
+
+	// A changed value, with the row count unchanged
+	if _, err := db.ExecuteStatement("UPDATE users SET photo = x'0103' WHERE id = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if result, err = db.VerifyBackup(backup); err != nil {
+		t.Fatal(err)
+	}
+	if result.Match || strings.Join(result.Mismatched, ",") != "users" {
+		t.Fatalf("the changed table was not detected: %+v", result)
+	}
+	for _, table := range result.Tables {
+		if table.Name == "users" && table.Problem != "row contents differ" {
+			t.Fatalf("users reported as %q", table.Problem)
+		}
+	}
+
+	for _, statement := range []string{
+		"INSERT INTO tags VALUES ('c')",
+		"DROP INDEX idx_tags",
+		"CREATE TABLE extra (v TEXT)",
+	} {
+		if _, err := db.ExecuteStatement(statement); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if result, err = db.VerifyBackup(backup); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(result.Mismatched, ",") != "extra,tags,users" {
+		t.Fatalf("mismatched %v", result.Mismatched)
+	}
+	problems := make(map[string]string)
+	for _, table := range result.Tables {
+		problems[table.Name] = table.Problem
+	}
+	if problems["tags"] != "row counts differ: 3 in the source, 2 in the backup" || problems["extra"] != "missing from the backup" {
+		t.Fatalf("problems %v", problems)
+	}
+	if strings.Join(result.SchemaDifferences, ",") != "index idx_tags is only in the backup" {
+		t.Fatalf("schema differences %v", result.SchemaDifferences)
+	}
+
+	if _, err := db.VerifyBackup(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Fatal("verified a missing backup")
+	}
+}
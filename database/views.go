@@ -0,0 +1,58 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+)
+
+// View is a view defined in the database
+type View struct {
+	Name string `json:"name"`
+	SQL  string `json:"sql"`
+}
+
+// CreateView creates a view named viewName over selectQuery, which must be a single statement
+func (s *SQLiteDB) CreateView(viewName, selectQuery string) error {
+	if viewName == "" {
+		return fmt.Errorf("view name is required")
+	}
+	if err := validateIdentifier(viewName); err != nil {
+		return fmt.Errorf("invalid view name: %w", err)
+	}
+	if strings.HasPrefix(strings.ToLower(viewName), "sqlite_") {
+		return fmt.Errorf("view names beginning with 'sqlite_' are reserved")
+	}
+	if !IsSingleStatement(selectQuery) {
+		return fmt.Errorf("the query must be a single SELECT statement")
+	}
+
+	selectQuery = strings.TrimSuffix(strings.TrimSpace(selectQuery), ";")
+	createSQL := fmt.Sprintf("CREATE VIEW %s AS %s", quoteIdentifier(viewName), selectQuery)
+	_, err := s.db.ExecContext(s.ctx(), createSQL)
+	return s.diskFullError(err)
+}
+
+// GetViews lists the views of the database with their definitions, ordered by name
+func (s *SQLiteDB) GetViews() ([]View, error) {
+	rows, err := s.db.QueryContext(s.ctx(), "SELECT name, sql FROM sqlite_master WHERE type='view' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var views []View
+	for rows.Next() {
+		var view View
+		if err := rows.Scan(&view.Name, &view.SQL); err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	return views, rows.Err()
+}
+
+// DropView drops a view; a view that does not exist is not an error
+func (s *SQLiteDB) DropView(viewName string) error {
+	_, err := s.db.ExecContext(s.ctx(), fmt.Sprintf("DROP VIEW IF EXISTS %s", quoteIdentifier(viewName)))
+	return err
+}
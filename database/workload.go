@@ -0,0 +1,239 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MaxWorkloadQueries caps the number of queries ProfileWorkload accepts
+const MaxWorkloadQueries = 200
+
+// slowestWorkloadQueries is the number of queries listed as the slowest of a workload
+const slowestWorkloadQueries = 5
+
+// WorkloadQuery is the profile of one query of a workload
+type WorkloadQuery struct {
+	Index    int        `json:"index"` // position in the workload, from 0
+	Query    string     `json:"query"`
+	MedianMs float64    `json:"median_ms"`
+	Rows     int        `json:"rows"`
+	Plan     []PlanStep `json:"plan,omitempty"`
+	// FullScans names the tables the query reads in full
+	FullScans []string `json:"full_scans,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// TableScans counts how often a table is read in full across a workload
+type TableScans struct {
+	Table   string `json:"table"`
+	Scans   int    `json:"scans"`
+	Queries []int  `json:"queries"`
+}
+
+// WorkloadIndex is an index recommended for a workload and the queries it serves
+type WorkloadIndex struct {
+	IndexSuggestion
+	Queries []int `json:"queries"`
+}
+
+// WorkloadProfile is the aggregated report of ProfileWorkload
+type WorkloadProfile struct {
+	Queries []WorkloadQuery `json:"queries"`
+	// Slowest lists the indexes of the slowest queries, slowest first
+	Slowest            []int           `json:"slowest"`
+	TotalMs            float64         `json:"total_ms"`
+	FullScans          []TableScans    `json:"full_scans"`
+	RecommendedIndexes []WorkloadIndex `json:"recommended_indexes"`
+	Failed             int             `json:"failed"`
+}
+
+// ProfileWorkload plans and times every query of a workload and aggregates the results: the
+// slowest queries, the tables read in full most often, and one set of recommended indexes
+// for the whole workload. Each query's suggestions from SuggestIndexes are merged, so an
+// index recommended for several queries appears once, and a suggestion whose columns lead a
+// longer suggestion on the same table is folded into it, since that index serves both.
+// Queries must be single SELECT statements; a query that fails is reported and skipped.
+// Each query runs iterations times and its median time is used.
+func (s *SQLiteDB) ProfileWorkload(queries []string, iterations int) (*WorkloadProfile, error) {
+	if len(queries) == 0 {
+		return nil, fmt.Errorf("the workload has no queries")
+	}
+	if len(queries) > MaxWorkloadQueries {
+		return nil, fmt.Errorf("a workload can have at most %d queries", MaxWorkloadQueries)
+	}
+	if iterations < 1 || iterations > MaxBenchmarkIterations {
+		return nil, fmt.Errorf("iterations must be between 1 and %d", MaxBenchmarkIterations)
+	}
+
+	sources, err := s.rootPageSources()
+	if err != nil {
+		return nil, err
+	}
+
+	profile := &WorkloadProfile{
+		Queries:            make([]WorkloadQuery, len(queries)),
+		FullScans:          []TableScans{},
+		RecommendedIndexes: []WorkloadIndex{},
+	}
+	scans := make(map[string]*TableScans)
+	var suggestions []WorkloadIndex
+
+	for i, query := range queries {
+		result := &profile.Queries[i]
+		result.Index = i
+		result.Query = query
+		if err := s.CheckReadQuery(query); err != nil {
+			result.Error = err.Error()
+			profile.Failed++
+			continue
+		}
+		query = strings.TrimSuffix(strings.TrimSpace(query), ";")
+
+		if result.Plan, err = s.ExplainQueryPlan(query); err != nil {
+			result.Error = err.Error()
+			profile.Failed++
+			continue
+		}
+		bench, err := s.BenchmarkQuery(query, iterations, false)
+		if err != nil {
+			result.Error = err.Error()
+			profile.Failed++
+			continue
+		}
+		result.MedianMs = bench.MedianMs
+		result.Rows = bench.RowCount
+		profile.TotalMs += bench.MedianMs
+
+		if result.FullScans, err = s.scannedTables(query, sources); err != nil {
+			return nil, err
+		}
+		for _, table := range result.FullScans {
+			entry := scans[strings.ToLower(table)]
+			if entry == nil {
+				entry = &TableScans{Table: table}
+				scans[strings.ToLower(table)] = entry
+			}
+			entry.Scans++
+			if len(entry.Queries) == 0 || entry.Queries[len(entry.Queries)-1] != i {
+				entry.Queries = append(entry.Queries, i)
+			}
+		}
+
+		suggested, err := s.SuggestIndexes(query)
+		if err != nil {
+			return nil, err
+		}
+		for _, suggestion := range suggested {
+			suggestions = append(suggestions, WorkloadIndex{IndexSuggestion: suggestion, Queries: []int{i}})
+		}
+	}
+
+	order := make([]int, 0, len(queries))
+	for i, q := range profile.Queries {
+		if q.Error == "" {
+			order = append(order, i)
+		}
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return profile.Queries[order[a]].MedianMs > profile.Queries[order[b]].MedianMs
+	})
+	profile.Slowest = order[:min(len(order), slowestWorkloadQueries)]
+
+	for _, entry := range scans {
+		profile.FullScans = append(profile.FullScans, *entry)
+	}
+	sort.Slice(profile.FullScans, func(a, b int) bool {
+		if profile.FullScans[a].Scans != profile.FullScans[b].Scans {
+			return profile.FullScans[a].Scans > profile.FullScans[b].Scans
+		}
+		return profile.FullScans[a].Table < profile.FullScans[b].Table
+	})
+
+	profile.RecommendedIndexes = consolidateIndexes(suggestions)
+	return profile, nil
+}
+
+// scannedTables returns the tables a query reads in full, from the Rewind and Last opcodes
+// that start a loop over a table b-tree, by their real names rather than the query's aliases
+func (s *SQLiteDB) scannedTables(query string, sources map[int64]*cursorSource) ([]string, error) {
+	program, err := s.explainProgram(query)
+	if err != nil {
+		return nil, err
+	}
+
+	cursors := make(map[int64]*cursorSource)
+	var tables []string
+	for _, op := range program {
+		switch {
+		case op.opcode == "OpenRead" && op.p3 == 0:
+			cursors[op.p1] = sources[op.p2]
+		case op.opcode == "Rewind" || op.opcode == "Last":
+			if src := cursors[op.p1]; src != nil && src.index == "" && !strings.HasPrefix(strings.ToLower(src.table), "sqlite_") {
+				tables = append(tables, src.table)
+			}
+		}
+	}
+	return tables, nil
+}
+
+// consolidateIndexes merges the index suggestions of several queries: identical suggestions
+// become one, and a suggestion whose columns lead a longer one on the same table is dropped in
+// favour of the longer index, which serves its queries too. Indexes serving the most queries
+// come first.
+func consolidateIndexes(suggestions []WorkloadIndex) []WorkloadIndex {
+	// Longer indexes first, so shorter ones can be folded into them
+	sort.SliceStable(suggestions, func(a, b int) bool {
+		return len(suggestions[a].Columns) > len(suggestions[b].Columns)
+	})
+
+	merged := []WorkloadIndex{}
+	for _, suggestion := range suggestions {
+		folded := false
+		for i := range merged {
+			if strings.EqualFold(merged[i].Table, suggestion.Table) && hasColumnPrefix(merged[i].Columns, suggestion.Columns) {
+				for _, q := range suggestion.Queries {
+					if !containsInt(merged[i].Queries, q) {
+						merged[i].Queries = append(merged[i].Queries, q)
+					}
+				}
+				folded = true
+				break
+			}
+		}
+		if !folded {
+			merged = append(merged, suggestion)
+		}
+	}
+
+	for i := range merged {
+		sort.Ints(merged[i].Queries)
+	}
+	sort.SliceStable(merged, func(a, b int) bool {
+		return len(merged[a].Queries) > len(merged[b].Queries)
+	})
+	return merged
+}
+
+// hasColumnPrefix reports whether prefix names the leading columns of columns
+func hasColumnPrefix(columns, prefix []string) bool {
+	if len(prefix) > len(columns) {
+		return false
+	}
+	for i := range prefix {
+		if !strings.EqualFold(columns[i], prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsInt reports whether list contains n
+func containsInt(list []int, n int) bool {
+	for _, v := range list {
+		if v == n {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package database
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestProfileWorkload(t *testing.T) {
+	db := newTestDB(t,
+		"CREATE TABLE events (id INTEGER PRIMARY KEY, user_id INTEGER, kind TEXT, payload TEXT)",
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 20000) INSERT INTO events SELECT i, i % 500, 'k' || (i % 7), printf('%050d', i) FROM n",
+		"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 500) INSERT INTO users SELECT i, 'u' || i FROM n",
+	)
+
+	queries := []string{
+		"SELECT * FROM events WHERE user_id = 5",
+		"SELECT count(*) FROM users WHERE id = 3",
+		"SELECT count(*) FROM (WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < 1000000) SELECT i FROM c) JOIN users ON users.id = 1",
+		"SELECT payload FROM events WHERE user_id = 7 AND kind = 'k3'",
+		"DELETE FROM events",
+	}
+	profile, err := db.ProfileWorkload(queries, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if profile.Failed != 1 || profile.Queries[4].Error == "" {
+		t.Fatalf("the DELETE was not reported as failed: %+v", profile.Queries[4])
+	}
+	if n := queryInt(t, db, "SELECT count(*) FROM events"); n != 20000 {
+		t.Fatalf("the workload changed the data, %d events left", n)
+	}
+	if len(profile.Slowest) != 4 || profile.Slowest[0] != 2 {
+		t.Fatalf("slowest queries %v, want query 2 first", profile.Slowest)
+	}
+	if profile.Queries[0].Rows != 40 || int64(profile.Queries[3].Rows) != queryInt(t, db, "SELECT count(*) FROM events WHERE user_id = 7 AND kind = 'k3'") {
+		t.Fatalf("rows %d and %d", profile.Queries[0].Rows, profile.Queries[3].Rows)
+	}
+
+	if len(profile.FullScans) == 0 || profile.FullScans[0].Table != "events" || profile.FullScans[0].Scans != 2 {
+		t.Fatalf("full scans %+v, want events scanned twice first", profile.FullScans)
+	}
+	for _, scans := range profile.FullScans {
+		if scans.Table == "users" {
+			t.Fatalf("users is only read by rowid, but reported as %+v", scans)
+		}
+	}
+
+	// One index on events serves both of its queries
+	if len(profile.RecommendedIndexes) == 0 {
+		t.Fatal("no index recommended")
+	}
+	index := profile.RecommendedIndexes[0]
+	if index.Table != "events" || !strings.EqualFold(index.Columns[0], "user_id") || len(index.Queries) != 2 || index.Queries[0] != 0 || index.Queries[1] != 3 {
+		t.Fatalf("recommended %+v, want one events(user_id, ...) index for queries 0 and 3", index)
+	}
+	for _, other := range profile.RecommendedIndexes[1:] {
+		if strings.EqualFold(other.Table, "events") {
+			t.Fatalf("a second index on events was recommended: %+v", other)
+		}
+	}
+}
+
+func TestConsolidateIndexes(t *testing.T) {
+	suggestion := func(table string, query int, columns ...string) WorkloadIndex {
+		return WorkloadIndex{IndexSuggestion: IndexSuggestion{Table: table, Columns: columns}, Queries: []int{query}}
+	}
+	merged := consolidateIndexes([]WorkloadIndex{
+		suggestion("a", 0, "x"),
+		suggestion("a", 1, "x", "y"),
+		suggestion("A", 2, "X"),
+		suggestion("a", 3, "y"),
+		suggestion("b", 4, "x"),
+	})
+	if len(merged) != 3 {
+		t.Fatalf("merged into %+v", merged)
+	}
+	if first := merged[0]; first.Table != "a" || len(first.Columns) != 2 || len(first.Queries) != 3 || first.Queries[0] != 0 || first.Queries[2] != 2 {
+		t.Fatalf("first index %+v, want a(x, y) serving queries 0 to 2", first)
+	}
+}
+
+func TestProfileWorkloadRejects(t *testing.T) {
+	db := newTestDB(t)
+	if _, err := db.ProfileWorkload(nil, 1); err == nil {
+		t.Error("accepted an empty workload")
+	}
+	if _, err := db.ProfileWorkload([]string{"SELECT 1"}, 0); err == nil {
+		t.Error("accepted zero iterations")
+	}
+	if _, err := db.ProfileWorkload(make([]string, MaxWorkloadQueries+1), 1); err == nil {
+		t.Error("accepted too many queries")
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"github.com/liliang-cn/mcp-sqlite-server/database"
 	"github.com/liliang-cn/mcp-sqlite-server/server"
@@ -12,10 +13,18 @@ import (
 )
 
 func isDBFile(path string) bool {
-	return strings.HasSuffix(strings.ToLower(path), ".db") ||
-		strings.HasSuffix(strings.ToLower(path), ".sqlite") ||
-		strings.HasSuffix(strings.ToLower(path), ".sqlite3") ||
-		strings.HasSuffix(strings.ToLower(path), ".db3")
+	return database.HasDatabaseExtension(path)
+}
+
+// splitList splits a comma-separated flag value, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 func main() {
@@ -24,6 +33,31 @@ func main() {
 	h := flag.Bool("h", false, "Show help message (shorthand)")
 	ver := flag.Bool("version", false, "Show version information")
 	v := flag.Bool("v", false, "Show version information (shorthand)")
+	allowRaw := flag.Bool("allow-raw", false, "Register the raw_exec tool, which runs any statement without validation")
+	readOnly := flag.Bool("readonly", false, "Open databases read-only and reject tools that modify them")
+	maxRows := flag.Int("max-rows", 0, "Maximum number of rows returned by the query tool (0 for no limit)")
+	maxCells := flag.Int("max-cells", 100000, "Maximum number of cells (rows x columns) returned by the query tool (0 for no limit)")
+	maxResultBytes := flag.Int64("max-result-bytes", 64<<20, "Approximate memory budget in bytes of the rows a query reads; larger results are aborted (0 for no limit)")
+	maxTxStatements := flag.Int("max-transaction-statements", 10000, "Maximum number of statements accepted by the transaction tool (0 for no limit)")
+	journalSizeLimit := flag.Int64("journal-size-limit", -1, "Truncate the WAL or rollback journal to this many bytes after checkpoints (-1 for SQLite's default of no limit)")
+	tempStore := flag.String("temp-store", "", "Where SQLite keeps temporary tables and spill files: DEFAULT, FILE, or MEMORY")
+	tempDir := flag.String("temp-dir", "", "Directory for SQLite temporary files")
+	enableTools := flag.String("enable-tools", "", "Comma-separated list of tools to offer; all other tools are disabled")
+	disableTools := flag.String("disable-tools", "", "Comma-separated list of tools to remove")
+	redactColumns := flag.String("redact-columns", "", "Comma-separated column name patterns whose values are masked in query results, e.g. \"*_ssn,password*\"")
+	allowTables := flag.String("allow-tables", "", "Comma-separated list of tables tools may access; all other tables are off-limits")
+	denyTables := flag.String("deny-tables", "", "Comma-separated list of tables tools may not access")
+	queryTimeout := flag.Duration("query-timeout", 30*time.Second, "Time limit of a single query or statement, e.g. 10s; longer statements are interrupted (0 for no limit)")
+	maxCallDuration := flag.Duration("max-call-duration", 0, "Time budget of a single tool call, e.g. 30s; longer calls are cancelled (0 for no limit)")
+	autoVacuum := flag.Duration("auto-vacuum-interval", 0, "Run VACUUM on the current database this often while no tool call is running, e.g. 24h (0 to disable)")
+	autoAnalyze := flag.Duration("auto-analyze-interval", 0, "Run ANALYZE on the current database this often while no tool call is running (0 to disable)")
+	autoCheckpoint := flag.Duration("auto-checkpoint-interval", 0, "Checkpoint the WAL of the current database this often while no tool call is running (0 to disable)")
+	serialized := flag.Bool("serialized", false, "Use a single database connection so that concurrent writes wait for each other instead of failing with \"database is locked\"; recommended for write-heavy workloads")
+	busyTimeout := flag.Duration("busy-timeout", database.DefaultBusyTimeout, "How long a statement waits for a lock held by another connection or process before failing with \"database is locked\", e.g. 500ms (0 to fail at once)")
+	maxOpenDatabases := flag.Int("max-open-databases", database.DefaultMaxOpenDatabases, "Number of databases, the current one included, whose connections stay open for switching back to them (1 closes a database when switching away)")
+	transport := flag.String("transport", "stdio", "How clients connect: stdio, or sse to serve MCP over HTTP with Server-Sent Events on --listen")
+	listen := flag.String("listen", "127.0.0.1:8080", "Address the sse transport listens on")
+	sqlFunctions := flag.String("sql-functions", "all", "Comma-separated custom SQL functions to register (regexp, slugify, sha256, base64_encode, base64_decode, levenshtein), \"all\", or \"none\"")
 	
 	flag.Parse()
 	
@@ -34,6 +68,9 @@ func main() {
 		fmt.Println("  1. Command-line arguments (shown above)")
 		fmt.Println("  2. MCP roots protocol (if client supports it)")
 		fmt.Println("At least one database or directory must be provided by EITHER method for the server to operate.")
+		fmt.Println("\nOptions:")
+		flag.CommandLine.SetOutput(os.Stdout)
+		flag.PrintDefaults()
 		os.Exit(0)
 	}
 	
@@ -46,21 +83,103 @@ func main() {
 		os.Exit(0)
 	}
 	
+	// Over the network anyone who can reach the port can call the tools, so write tools
+	// are only offered when --readonly=false is given explicitly
+	switch *transport {
+	case "stdio":
+	case "sse":
+		readOnlySet := false
+		flag.Visit(func(f *flag.Flag) {
+			readOnlySet = readOnlySet || f.Name == "readonly"
+		})
+		if !readOnlySet {
+			*readOnly = true
+			fmt.Fprintln(os.Stderr, "Serving over the network read-only; pass --readonly=false to allow write tools")
+		}
+	default:
+		log.Fatalf("Invalid --transport '%s', must be one of %s", *transport, strings.Join(server.Transports, ", "))
+	}
+
+	// start serves MCP on the chosen transport until the client or a signal stops it
+	start := func(srv *server.SQLiteServer) error {
+		if *transport == "sse" {
+			return srv.StartSSE(*listen)
+		}
+		return srv.Start()
+	}
+
 	// Get remaining arguments after flags
 	args := flag.Args()
+
+	// configure applies command-line options to a newly created server
+	configure := func(srv *server.SQLiteServer) {
+		srv.SetAllowRaw(*allowRaw)
+		if *readOnly {
+			if err := srv.SetReadOnly(true); err != nil {
+				log.Fatalf("Failed to open database read-only: %v", err)
+			}
+		}
+		if err := srv.SetToolFilter(splitList(*enableTools), splitList(*disableTools)); err != nil {
+			log.Fatalf("Invalid tool list: %v", err)
+		}
+		srv.SetTableAccess(splitList(*allowTables), splitList(*denyTables))
+		srv.SetResultLimits(*maxRows, *maxCells)
+		srv.SetMaxResultBytes(*maxResultBytes)
+		if err := srv.SetRedactionPatterns(splitList(*redactColumns)); err != nil {
+			log.Fatalf("Invalid --redact-columns: %v", err)
+		}
+		srv.SetMaxTransactionStatements(*maxTxStatements)
+		srv.SetMaxCallDuration(*maxCallDuration)
+		srv.SetQueryTimeout(*queryTimeout)
+		srv.SetSerialized(*serialized)
+		if err := srv.SetBusyTimeout(*busyTimeout); err != nil {
+			log.Fatalf("Invalid --busy-timeout: %v", err)
+		}
+		if err := srv.SetMaxOpenDatabases(*maxOpenDatabases); err != nil {
+			log.Fatalf("Invalid --max-open-databases: %v", err)
+		}
+		if *journalSizeLimit >= 0 {
+			if err := srv.SetJournalSizeLimit(*journalSizeLimit); err != nil {
+				log.Fatalf("Failed to set journal size limit: %v", err)
+			}
+		}
+		if *sqlFunctions != "all" {
+			names := []string{}
+			if *sqlFunctions != "none" {
+				names = splitList(*sqlFunctions)
+			}
+			if err := srv.SetEnabledFunctions(names); err != nil {
+				log.Fatalf("Invalid --sql-functions: %v", err)
+			}
+		}
+		if *tempStore != "" || *tempDir != "" {
+			if err := srv.SetTempStorage(*tempStore, *tempDir); err != nil {
+				log.Fatalf("Failed to set temp storage: %v", err)
+			}
+		}
+		schedule := server.MaintenanceSchedule{Vacuum: *autoVacuum, Analyze: *autoAnalyze, Checkpoint: *autoCheckpoint}
+		if err := srv.SetMaintenanceSchedule(schedule); err != nil {
+			log.Fatalf("Invalid maintenance schedule: %v", err)
+		}
+	}
 	
 	// Print startup message
-	fmt.Fprintln(os.Stderr, "Secure MCP SQLite Server running on stdio")
+	if *transport == "sse" {
+		fmt.Fprintf(os.Stderr, "Secure MCP SQLite Server listening on http://%s/sse\n", *listen)
+	} else {
+		fmt.Fprintln(os.Stderr, "Secure MCP SQLite Server running on stdio")
+	}
 	
 	// Check if arguments provided
 	if len(args) == 0 {
 		fmt.Fprintln(os.Stderr, "Started without database paths - waiting for client to provide roots via MCP protocol")
 		// Start server without initial database, waiting for roots
 		srv := server.NewSQLiteServerWithoutDB()
+		configure(srv)
 		defer srv.Close()
 		
-		// Start stdio server
-		if err := srv.Start(); err != nil {
+		// Start serving
+		if err := start(srv); err != nil {
 			log.Fatalf("Server error: %v", err)
 		}
 		return
@@ -111,10 +230,11 @@ func main() {
 		fmt.Fprintf(os.Stderr, "No database files found in specified paths. Server will wait for database selection via MCP protocol.\n")
 		srv := server.NewSQLiteServerWithoutDB()
 		srv.SetAllowedDirs(allowedDirs)
+		configure(srv)
 		defer srv.Close()
 		
-		// Start stdio server
-		if err := srv.Start(); err != nil {
+		// Start serving
+		if err := start(srv); err != nil {
 			log.Fatalf("Server error: %v", err)
 		}
 		return
@@ -125,6 +245,7 @@ func main() {
 	if err != nil {
 		log.Fatalf("Failed to create server: %v", err)
 	}
+	configure(srv)
 	defer srv.Close()
 
 	fmt.Fprintf(os.Stderr, "Using database: %s\n", dbPath)
@@ -132,8 +253,8 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Additional databases available: %d\n", len(foundDatabases)-1)
 	}
 
-	// Start stdio server
-	if err := srv.Start(); err != nil {
+	// Start serving
+	if err := start(srv); err != nil {
 		log.Fatalf("Server error: %v", err)
 	}
 }
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/liliang-cn/mcp-sqlite-server/database"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Tool arguments naming a table, and tool arguments holding SQL, or lists of SQL, to inspect
+// for table references
+var (
+	tableArguments         = []string{"table_name", "destination_table", "view_name"}
+	statementArguments     = []string{"query", "statement", "script", "select_query"}
+	statementListArguments = []string{"statements", "queries"}
+)
+
+// matchTools take a full-text search expression rather than SQL in their query argument
+var matchTools = map[string]bool{
+	"search_fts": true,
+}
+
+// SetTableAccess restricts which tables tools may touch. A non-empty allow list permits only
+// the listed tables; the deny list always wins. Names are case-insensitive. Tables the server
+// maintains itself (sqlite_* and _mcp_*) are exempt from the allow list so that audit
+// triggers and similar bookkeeping keep working.
+func (s *SQLiteServer) SetTableAccess(allow, deny []string) {
+	s.allowedTables = nil
+	if len(allow) > 0 {
+		s.allowedTables = make(map[string]bool)
+		for _, name := range allow {
+			s.allowedTables[strings.ToLower(name)] = true
+		}
+	}
+	s.deniedTables = make(map[string]bool)
+	for _, name := range deny {
+		s.deniedTables[strings.ToLower(name)] = true
+	}
+}
+
+// tablesRestricted reports whether a table access policy is set. The policy names tables of
+// the databases open now, so tools that would open another database next to them, such as
+// attach_database and create_scratch, are refused while it is set.
+func (s *SQLiteServer) tablesRestricted() bool {
+	return s.allowedTables != nil || len(s.deniedTables) > 0
+}
+
+// tableAllowed reports whether the table access policy permits a table
+func (s *SQLiteServer) tableAllowed(table string) bool {
+	name := strings.ToLower(table)
+	if s.deniedTables[name] {
+		return false
+	}
+	if s.allowedTables == nil || s.allowedTables[name] {
+		return true
+	}
+	return strings.HasPrefix(name, "sqlite_") || strings.HasPrefix(name, "_mcp_")
+}
+
+// checkTableAccess rejects a table denied by the access policy
+func (s *SQLiteServer) checkTableAccess(table string) error {
+	if !s.tableAllowed(table) {
+		return fmt.Errorf("access to table '%s' is denied", table)
+	}
+	return nil
+}
+
+// checkStatementAccess rejects SQL that reads or writes a table denied by the access policy.
+// params are bound to the placeholders of a single statement.
+func (s *SQLiteServer) checkStatementAccess(sql string, params []interface{}) error {
+	statements := database.SplitStatements(sql)
+	if len(statements) > 1 {
+		params = nil
+	}
+	for _, statement := range statements {
+		tables, err := s.db.ReferencedTables(statement, params...)
+		if err != nil {
+			return fmt.Errorf("failed to check table access: %w", err)
+		}
+		for _, table := range tables {
+			if err := s.checkTableAccess(table); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// restrictTables wraps a tool handler so that its table and SQL arguments are checked
+// against the table access policy before it runs
+func (s *SQLiteServer) restrictTables(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.db == nil || !s.tablesRestricted() {
+			return handler(ctx, request)
+		}
+		args, _ := request.Params.Arguments.(map[string]interface{})
+
+		for _, key := range tableArguments {
+			if table, ok := args[key].(string); ok && table != "" {
+				if err := s.checkTableAccess(table); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		statements := sqlArguments(request.Params.Name, args)
+		// Invalid params are reported by the handler itself
+		params, _ := parseParams(args["params"])
+		if query, ok := args["query"].(string); ok {
+			named, _ := parseNamedParams(query, args["named_params"])
+			params = append(params, named...)
+		}
+		for _, sql := range statements {
+			if err := s.checkStatementAccess(sql, params); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, request)
+	}
+}
+
+// sqlArguments returns the SQL passed to a tool in its statement arguments
+func sqlArguments(name string, args map[string]interface{}) []string {
+	var statements []string
+	for _, key := range statementArguments {
+		if matchTools[name] && key == "query" {
+			continue
+		}
+		if sql, ok := args[key].(string); ok {
+			statements = append(statements, sql)
+		}
+	}
+	for _, key := range statementListArguments {
+		list, _ := args[key].([]interface{})
+		for _, item := range list {
+			if sql, ok := item.(string); ok {
+				statements = append(statements, sql)
+			}
+		}
+	}
+	return statements
+}
+
+// rejectAttach wraps a tool handler so that SQL arguments cannot attach or detach databases.
+// ATTACH run as SQL reaches only the pooled connection it happens to run on and skips the
+// path checks of attach_database, which attaches a database to every connection.
+func (s *SQLiteServer) rejectAttach(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args, _ := request.Params.Arguments.(map[string]interface{})
+		for _, sql := range sqlArguments(request.Params.Name, args) {
+			for _, statement := range database.SplitStatements(sql) {
+				if keyword := database.LeadingKeyword(statement); keyword == "ATTACH" || keyword == "DETACH" {
+					return nil, fmt.Errorf("%s statements are not allowed; use attach_database and detach_database", keyword)
+				}
+			}
+		}
+		return handler(ctx, request)
+	}
+}
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDeniedTableInAttachedDatabase(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE secret (id INTEGER PRIMARY KEY, value TEXT)",
+		"INSERT INTO secret VALUES (1, 'hidden')",
+	)
+	srv.SetTableAccess(nil, []string{"secret"})
+	// The database attached to itself under another name
+	if err := srv.db.AttachDatabase(srv.db.GetCurrentDatabasePath(), "y"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		"SELECT * FROM secret",
+		"SELECT * FROM y.secret",
+		"SELECT * FROM users WHERE id IN (SELECT id FROM y.secret)",
+		"SELECT * FROM pragma_table_info('secret')",
+		"SELECT * FROM y.pragma_table_info('secret')",
+	} {
+		if text, err := callTool(t, srv, "query", map[string]interface{}{"query": query}); err == nil {
+			t.Errorf("%s was allowed: %s", query, text)
+		}
+	}
+	mustCall(t, srv, "query", map[string]interface{}{"query": "SELECT * FROM y.users"})
+}
+
+func TestAttachStatementsRejected(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER)")
+	srv.SetAllowRaw(true)
+	other := filepath.Join(t.TempDir(), "other.db")
+	attach := "ATTACH DATABASE '" + other + "' AS y"
+
+	calls := []struct {
+		tool string
+		args map[string]interface{}
+	}{
+		{"execute", map[string]interface{}{"statement": attach}},
+		{"execute", map[string]interface{}{"statement": "INSERT INTO t VALUES (1); " + attach}},
+		{"execute", map[string]interface{}{"statement": "/* comment */ detach y"}},
+		{"raw_exec", map[string]interface{}{"statement": attach}},
+		{"transaction", map[string]interface{}{"statements": []interface{}{"INSERT INTO t VALUES (1)", attach}}},
+	}
+	for _, call := range calls {
+		if _, err := callTool(t, srv, call.tool, call.args); err == nil || !strings.Contains(err.Error(), "not allowed") {
+			t.Errorf("%s %v: got %v, want ATTACH rejected", call.tool, call.args, err)
+		}
+	}
+	if _, err := os.Stat(other); err == nil {
+		t.Fatal("ATTACH created the database file")
+	}
+	if n := countRows(t, srv, "t"); n != 0 {
+		t.Fatalf("%d rows inserted alongside a rejected ATTACH", n)
+	}
+}
+
+func TestAllowedTables(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE products (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE salaries (id INTEGER PRIMARY KEY, amount INTEGER)",
+		"CREATE VIEW all_salaries AS SELECT * FROM salaries",
+		"INSERT INTO products VALUES (1, 'pen')",
+	)
+	srv.SetTableAccess([]string{"products"}, nil)
+
+	text := mustCall(t, srv, "query", map[string]interface{}{"query": "SELECT name FROM products"})
+	if !strings.Contains(text, `"name": "pen"`) {
+		t.Fatalf("unexpected result: %s", text)
+	}
+	mustCall(t, srv, "execute", map[string]interface{}{"statement": "INSERT INTO products VALUES (2, 'ink')"})
+
+	for _, call := range []struct {
+		tool string
+		args map[string]interface{}
+	}{
+		{"query", map[string]interface{}{"query": "SELECT * FROM salaries"}},
+		// A view reads the tables under it
+		{"query", map[string]interface{}{"query": "SELECT * FROM all_salaries"}},
+		{"query", map[string]interface{}{"query": "SELECT p.name FROM products p JOIN salaries s ON s.id = p.id"}},
+		{"execute", map[string]interface{}{"statement": "DELETE FROM salaries"}},
+		{"describe_table", map[string]interface{}{"table_name": "salaries"}},
+	} {
+		if _, err := callTool(t, srv, call.tool, call.args); err == nil || !strings.Contains(err.Error(), "is denied") {
+			t.Errorf("%s %v: got %v, want access denied", call.tool, call.args, err)
+		}
+	}
+}
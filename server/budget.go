@@ -0,0 +1,69 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// SetMaxCallDuration sets the time budget of a single tool call. A call that runs longer is
+// answered with a timeout error and its running statements are cancelled; statements of other
+// calls go on. Zero disables the budget.
+func (s *SQLiteServer) SetMaxCallDuration(d time.Duration) {
+	s.maxCallDuration = d
+}
+
+// SetQueryTimeout bounds how long a single query or statement may run; a statement that runs
+// longer is interrupted and its tool call fails with "query cancelled after ...". Zero
+// disables the timeout. It is a no-op while no database is open.
+func (s *SQLiteServer) SetQueryTimeout(d time.Duration) {
+	if s.db != nil {
+		s.db.SetQueryTimeout(d)
+	}
+}
+
+// limitDuration wraps a tool handler so that it is cut off when it exceeds the call budget.
+// Handlers run their statements under the context of the call, see database.WithContext, so
+// ending it cancels the statements of this call only; a transaction still open is rolled back
+// rather than committed by a handler that goes on running.
+func (s *SQLiteServer) limitDuration(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		budget := s.maxCallDuration
+		if budget <= 0 {
+			// The context still ends with the call, releasing what statements tied to it
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			return handler(ctx, request)
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, budget)
+		defer cancel()
+
+		type outcome struct {
+			result *mcp.CallToolResult
+			err    error
+		}
+		done := make(chan outcome, 1)
+		start := time.Now()
+		go func() {
+			result, err := handler(ctx, request)
+			done <- outcome{result, err}
+		}()
+
+		select {
+		case o := <-done:
+			return o.result, o.err
+		case <-ctx.Done():
+		}
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("tool %s exceeded the time budget of %s and was cancelled after %s",
+				name, budget, time.Since(start).Round(time.Millisecond))
+		}
+		return nil, fmt.Errorf("tool %s was cancelled after %s: %w", name, time.Since(start).Round(time.Millisecond), ctx.Err())
+	}
+}
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// countTo returns a query of the integers 1 to n
+func countTo(n int) string {
+	return fmt.Sprintf("WITH RECURSIVE c(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM c WHERE i < %d) SELECT i FROM c", n)
+}
+
+func TestCallBudgetCancelsOnlyItsOwnStatements(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE big (i INTEGER)",
+		"INSERT INTO big "+countTo(1000),
+		"CREATE TABLE few (i INTEGER)",
+		"INSERT INTO few "+countTo(20),
+	)
+	srv.SetMaxCallDuration(50 * time.Millisecond)
+
+	// Another client's statement, running while the call below runs out of time
+	other := make(chan error, 1)
+	go func() {
+		_, err := srv.db.ExecuteQuery("SELECT count(*) FROM big a, big b, few")
+		other <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+
+	_, err := callTool(t, srv, "query", map[string]interface{}{"query": "SELECT count(*) FROM big a, big b, big c"})
+	if err == nil || !strings.Contains(err.Error(), "exceeded the time budget") {
+		t.Fatalf("got %v, want the call to exceed its budget", err)
+	}
+	if err := <-other; err != nil {
+		t.Fatalf("a statement of another call was cancelled: %v", err)
+	}
+}
+
+func TestCallBudgetStopsLateWrites(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER)")
+	srv.SetMaxCallDuration(20 * time.Millisecond)
+
+	// A handler that goes on after its call was answered must not write anything
+	finished := make(chan error, 1)
+	handler := srv.limitDuration("slow", func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		db := srv.db.WithContext(ctx)
+		<-ctx.Done()
+		_, err := db.ExecuteStatement("INSERT INTO t VALUES (1)")
+		finished <- err
+		return nil, err
+	})
+	if _, err := handler(context.Background(), mcp.CallToolRequest{}); err == nil {
+		t.Fatal("the call did not exceed its budget")
+	}
+	if err := <-finished; err == nil {
+		t.Fatal("the handler wrote after its call was cancelled")
+	}
+	rows, err := srv.db.ExecuteQuery("SELECT count(*) AS n FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0]["n"] != int64(0) {
+		t.Fatalf("%v rows inserted after the call was cancelled", rows[0]["n"])
+	}
+}
+
+func TestQueryTimeout(t *testing.T) {
+	srv := newTestServer(t)
+	srv.SetQueryTimeout(100 * time.Millisecond)
+
+	for _, call := range []struct {
+		tool string
+		args map[string]interface{}
+	}{
+		{"query", map[string]interface{}{"query": "SELECT count(*) FROM (" + countTo(1e10) + ")"}},
+		{"execute", map[string]interface{}{"statement": "CREATE TABLE slow AS " + countTo(1e10)}},
+	} {
+		start := time.Now()
+		_, err := callTool(t, srv, call.tool, call.args)
+		if err == nil || !strings.Contains(err.Error(), "query cancelled after 100ms") {
+			t.Fatalf("%s: got %v, want the statement cancelled after 100ms", call.tool, err)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Fatalf("%s ran for %s", call.tool, elapsed)
+		}
+	}
+	if tableExists(t, srv, "slow") {
+		t.Fatal("the interrupted statement created its table")
+	}
+	mustCall(t, srv, "query", map[string]interface{}{"query": "SELECT count(*) FROM (" + countTo(10) + ")"})
+}
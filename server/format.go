@@ -0,0 +1,339 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liliang-cn/mcp-sqlite-server/database"
+)
+
+// NumberFormat describes how a numeric column is rendered for display
+type NumberFormat struct {
+	Decimals          int
+	Grouping          bool
+	ThousandsSep      string
+	DecimalSep        string
+	CurrencySymbol    string
+	SymbolAfterNumber bool
+}
+
+// localeSeparators maps supported locales to their thousands and decimal separators
+var localeSeparators = map[string][2]string{
+	"en": {",", "."},
+	"de": {".", ","},
+	"fr": {" ", ","},
+	"es": {".", ","},
+	"it": {".", ","},
+	"ch": {"'", "."},
+	"in": {",", "."},
+}
+
+// parseNumberFormats converts the number_format tool argument into per-column formats
+func parseNumberFormats(raw interface{}) (map[string]NumberFormat, error) {
+	specs, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("number_format must be an object mapping column names to format specs")
+	}
+
+	formats := make(map[string]NumberFormat)
+	for column, specRaw := range specs {
+		spec, ok := specRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("number_format for column '%s' must be an object", column)
+		}
+
+		format := NumberFormat{Decimals: -1, ThousandsSep: ",", DecimalSep: ".", Grouping: true}
+
+		if locale, ok := spec["locale"].(string); ok && locale != "" {
+			// Accept both "de" and "de-DE" style locales
+			lang := strings.ToLower(strings.SplitN(strings.ReplaceAll(locale, "_", "-"), "-", 2)[0])
+			seps, ok := localeSeparators[lang]
+			if !ok {
+				return nil, fmt.Errorf("unsupported locale '%s' for column '%s'", locale, column)
+			}
+			format.ThousandsSep, format.DecimalSep = seps[0], seps[1]
+			// French and German style currencies follow the amount
+			format.SymbolAfterNumber = lang == "de" || lang == "fr" || lang == "es" || lang == "it"
+		}
+		if decimals, ok := spec["decimals"].(float64); ok {
+			if decimals < 0 || decimals > 15 {
+				return nil, fmt.Errorf("decimals for column '%s' must be between 0 and 15", column)
+			}
+			format.Decimals = int(decimals)
+		}
+		if grouping, ok := spec["grouping"].(bool); ok {
+			format.Grouping = grouping
+		}
+		if currency, ok := spec["currency"].(string); ok {
+			format.CurrencySymbol = currency
+		}
+		if after, ok := spec["symbol_after"].(bool); ok {
+			format.SymbolAfterNumber = after
+		}
+
+		formats[column] = format
+	}
+
+	return formats, nil
+}
+
+// applyNumberFormats replaces numeric values in the configured columns with formatted strings.
+// Non-numeric values and columns without a format are left untouched.
+func applyNumberFormats(results []map[string]interface{}, formats map[string]NumberFormat) {
+	if len(formats) == 0 {
+		return
+	}
+	for _, row := range results {
+		for column, format := range formats {
+			value, ok := row[column]
+			if !ok {
+				continue
+			}
+			if formatted, ok := format.Format(value); ok {
+				row[column] = formatted
+			}
+		}
+	}
+}
+
+// applyRealFormat rewrites REAL values as plain decimal JSON numbers, so very large and very
+// small values are not written in exponent notation (encoding/json switches to it below 1e-6
+// and from 1e21). precision is the number of decimals, or -1 for the fewest digits that
+// represent the value exactly. Values already formatted by number_format are strings and
+// are left alone.
+func applyRealFormat(results []map[string]interface{}, precision int) {
+	for _, row := range results {
+		for column, value := range row {
+			if f, ok := value.(float64); ok && !math.IsInf(f, 0) && !math.IsNaN(f) {
+				row[column] = json.Number(strconv.FormatFloat(f, 'f', precision, 64))
+			}
+		}
+	}
+}
+
+// groupRowsByKey buckets rows by the value of a key column. JSON object keys must be
+// strings, so key values are stringified and NULL keys are grouped under "null".
+func groupRowsByKey(results []map[string]interface{}, key string) (map[string][]map[string]interface{}, error) {
+	groups := make(map[string][]map[string]interface{})
+	for _, row := range results {
+		value, ok := row[key]
+		if !ok {
+			return nil, fmt.Errorf("group_by_key column '%s' is not present in the query results", key)
+		}
+		groupKey := "null"
+		if value != nil {
+			groupKey = fmt.Sprint(value)
+		}
+		groups[groupKey] = append(groups[groupKey], row)
+	}
+	return groups, nil
+}
+
+// Format renders a numeric value, reporting false when the value is not a number
+func (f NumberFormat) Format(value interface{}) (string, bool) {
+	var number float64
+	isInteger := false
+	switch v := value.(type) {
+	case int64:
+		number, isInteger = float64(v), true
+	case int:
+		number, isInteger = float64(v), true
+	case float64:
+		number = v
+	default:
+		return "", false
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return "", false
+	}
+
+	decimals := f.Decimals
+	if decimals < 0 {
+		decimals = -1
+		if isInteger {
+			decimals = 0
+		}
+	}
+
+	text := strconv.FormatFloat(math.Abs(number), 'f', decimals, 64)
+	intPart, fracPart, hasFrac := strings.Cut(text, ".")
+
+	if f.Grouping && len(intPart) > 3 {
+		var grouped strings.Builder
+		lead := len(intPart) % 3
+		if lead > 0 {
+			grouped.WriteString(intPart[:lead])
+		}
+		for i := lead; i < len(intPart); i += 3 {
+			if grouped.Len() > 0 {
+				grouped.WriteString(f.ThousandsSep)
+			}
+			grouped.WriteString(intPart[i : i+3])
+		}
+		intPart = grouped.String()
+	}
+
+	result := intPart
+	if hasFrac {
+		result += f.DecimalSep + fracPart
+	}
+
+	if f.CurrencySymbol != "" {
+		if f.SymbolAfterNumber {
+			result = result + " " + f.CurrencySymbol
+		} else {
+			result = f.CurrencySymbol + result
+		}
+	}
+	if number < 0 {
+		result = "-" + result
+	}
+
+	return result, true
+}
+
+// limitResults truncates query results to at most maxRows rows and maxCells cells (rows x
+// columns), whichever is reached first. A zero limit is ignored. When rows were dropped it
+// returns a note explaining which limit applied.
+func limitResults(results []map[string]interface{}, maxRows, maxCells int) ([]map[string]interface{}, string) {
+	if len(results) == 0 {
+		return results, ""
+	}
+
+	keep := len(results)
+	reason := ""
+	if maxRows > 0 && keep > maxRows {
+		keep = maxRows
+		reason = fmt.Sprintf("row limit of %d", maxRows)
+	}
+
+	// Every row of a result set has the same columns
+	columns := len(results[0])
+	if maxCells > 0 && columns > 0 && keep*columns > maxCells {
+		keep = maxCells / columns
+		reason = fmt.Sprintf("cell limit of %d at %d columns per row", maxCells, columns)
+	}
+
+	if keep == len(results) {
+		return results, ""
+	}
+
+	return results[:keep], fmt.Sprintf("truncated from %d rows by the %s", len(results), reason)
+}
+
+// redactedValue replaces the values of columns matching a redaction pattern
+const redactedValue = "***"
+
+// validateRedactionPatterns checks that every pattern is a valid glob
+func validateRedactionPatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+			return fmt.Errorf("invalid redaction pattern '%s': %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+// matchesRedaction reports whether a column name matches one of the redaction patterns.
+// Patterns are case-insensitive globs such as "*_ssn" or "password*".
+func matchesRedaction(column string, patterns []string) bool {
+	name := strings.ToLower(column)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// redactColumns masks the values of the given columns in place. NULL values stay NULL since
+// they reveal nothing.
+func redactColumns(results []map[string]interface{}, columns map[string]bool) {
+	if len(columns) == 0 {
+		return
+	}
+	for _, row := range results {
+		for column := range columns {
+			if value, ok := row[column]; ok && value != nil {
+				row[column] = redactedValue
+			}
+		}
+	}
+}
+
+// redactJSONObject masks the members of a JSON object, such as a row recorded by the audit
+// log, whose key matches one of the redaction patterns. Text that is not a JSON object is
+// masked as a whole, since its contents cannot be checked.
+func redactJSONObject(text string, patterns []string) string {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return redactedValue
+	}
+	masked := false
+	for key, value := range object {
+		if value != nil && matchesRedaction(key, patterns) {
+			object[key] = redactedValue
+			masked = true
+		}
+	}
+	if !masked {
+		return text
+	}
+	data, err := json.Marshal(object)
+	if err != nil {
+		return redactedValue
+	}
+	return string(data)
+}
+
+// formatCSV renders query results as CSV with a header row. NULL becomes an empty field.
+// A column name that repeats is written once, since the row maps hold one value per name.
+func formatCSV(columns []string, results []map[string]interface{}) (string, error) {
+	var header []string
+	seen := make(map[string]bool)
+	for _, column := range columns {
+		if !seen[column] {
+			seen[column] = true
+			header = append(header, column)
+		}
+	}
+
+	var out strings.Builder
+	writer := csv.NewWriter(&out)
+	if err := writer.Write(header); err != nil {
+		return "", err
+	}
+	record := make([]string, len(header))
+	for _, row := range results {
+		for i, column := range header {
+			switch v := row[column].(type) {
+			case nil:
+				record[i] = ""
+			case string:
+				record[i] = v
+			case database.Blob:
+				record[i] = base64.StdEncoding.EncodeToString(v)
+			case float64:
+				record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+			case time.Time:
+				record[i] = v.Format(time.RFC3339Nano)
+			default:
+				record[i] = fmt.Sprint(v)
+			}
+		}
+		if err := writer.Write(record); err != nil {
+			return "", err
+		}
+	}
+	writer.Flush()
+	return out.String(), writer.Error()
+}
@@ -0,0 +1,258 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/liliang-cn/mcp-sqlite-server/database"
+)
+
+func TestNumberFormat(t *testing.T) {
+	formats, err := parseNumberFormats(map[string]interface{}{
+		"en":    map[string]interface{}{"decimals": float64(2)},
+		"de":    map[string]interface{}{"decimals": float64(2), "locale": "de-DE", "currency": "€"},
+		"plain": map[string]interface{}{"grouping": false, "currency": "$"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		column string
+		value  interface{}
+		want   string
+	}{
+		{"en", 1234567.5, "1,234,567.50"},
+		{"en", int64(-1234), "-1,234.00"},
+		{"de", 1234567.5, "1.234.567,50\u00a0€"},
+		{"plain", int64(1234567), "$1234567"},
+	}
+	for _, test := range tests {
+		if got, ok := formats[test.column].Format(test.value); !ok || got != test.want {
+			t.Errorf("%s of %v: got %q, want %q", test.column, test.value, got, test.want)
+		}
+	}
+	if _, ok := formats["en"].Format("text"); ok {
+		t.Error("formatted a string")
+	}
+	if _, err := parseNumberFormats(map[string]interface{}{"x": map[string]interface{}{"locale": "xx"}}); err == nil {
+		t.Error("accepted an unknown locale")
+	}
+}
+
+func TestQueryNumberFormat(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE sales (region TEXT, amount REAL, units INTEGER)",
+		"INSERT INTO sales VALUES ('north', 1234567.5, 1500)",
+	)
+
+	text := mustCall(t, srv, "query", map[string]interface{}{
+		"query":         "SELECT region, amount, units FROM sales",
+		"number_format": map[string]interface{}{"amount": map[string]interface{}{"decimals": 2, "grouping": true}},
+	})
+	for _, want := range []string{`"amount": "1,234,567.50"`, `"units": 1500`, `"region": "north"`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result lacks %s:\n%s", want, text)
+		}
+	}
+}
+
+func TestApplyRealFormat(t *testing.T) {
+	tests := []struct {
+		value     float64
+		precision int
+		want      string
+	}{
+		{1e20, -1, "100000000000000000000"},
+		{1e21, -1, "1000000000000000000000"},
+		{1.5e-7, -1, "0.00000015"},
+		{2.0 / 3, 4, "0.6667"},
+		{12.5, 0, "12"},
+		{-3.25, 3, "-3.250"},
+	}
+	for _, test := range tests {
+		results := []map[string]interface{}{{"v": test.value, "n": int64(7), "s": "1e+21"}}
+		applyRealFormat(results, test.precision)
+		encoded, err := json.Marshal(results[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := fmt.Sprintf(`{"n":7,"s":"1e+21","v":%s}`, test.want)
+		if string(encoded) != want {
+			t.Errorf("%v with precision %d encoded as %s, want %s", test.value, test.precision, encoded, want)
+		}
+	}
+}
+
+func TestQueryFixedReals(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE m (big REAL, tiny REAL, label TEXT)",
+		"INSERT INTO m VALUES (1e20, 1.5e-7, 'x'), (1e21, NULL, 'y')",
+	)
+	query := "SELECT big, tiny, label FROM m ORDER BY big"
+
+	text := mustCall(t, srv, "query", map[string]interface{}{"query": query})
+	if !strings.Contains(text, "1e+21") {
+		t.Fatalf("expected exponent notation without fixed_reals: %s", text)
+	}
+
+	text = mustCall(t, srv, "query", map[string]interface{}{"query": query, "fixed_reals": true})
+	for _, want := range []string{`"big": 100000000000000000000,`, `"big": 1000000000000000000000,`, "\"tiny\": 0.00000015\n", `"tiny": null`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("missing %s in %s", want, text)
+		}
+	}
+	if strings.Contains(text, "e+") || strings.Contains(text, "e-") {
+		t.Fatalf("exponent notation with fixed_reals: %s", text)
+	}
+
+	text = mustCall(t, srv, "query", map[string]interface{}{"query": query, "real_precision": 2})
+	if !strings.Contains(text, `"big": 1000000000000000000000.00,`) || !strings.Contains(text, "\"tiny\": 0.00\n") {
+		t.Fatalf("unexpected result with real_precision 2: %s", text)
+	}
+
+	for _, precision := range []interface{}{-1, 21, 1.5, "2"} {
+		if _, err := callTool(t, srv, "query", map[string]interface{}{"query": query, "real_precision": precision}); err == nil {
+			t.Errorf("accepted real_precision %v", precision)
+		}
+	}
+}
+
+func TestGroupRowsByKey(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE items (category TEXT, name TEXT)",
+		"INSERT INTO items VALUES ('fruit', 'apple'), ('veg', 'leek'), ('fruit', 'pear'), (NULL, 'stone')",
+	)
+
+	text := mustCall(t, srv, "query", map[string]interface{}{
+		"query":        "SELECT category, name FROM items ORDER BY name",
+		"group_by_key": "category",
+	})
+	if !strings.Contains(text, "Returned 4 rows in 3 group(s)") {
+		t.Fatalf("unexpected summary:\n%s", text)
+	}
+	results, err := srv.db.ExecuteQuery("SELECT category, name FROM items ORDER BY name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups, err := groupRowsByKey(results, "category")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups["fruit"]) != 2 || groups["fruit"][0]["name"] != "apple" || groups["fruit"][1]["name"] != "pear" ||
+		len(groups["veg"]) != 1 || len(groups["null"]) != 1 {
+		t.Fatalf("grouped as %v", groups)
+	}
+
+	if _, err := callTool(t, srv, "query", map[string]interface{}{
+		"query":        "SELECT name FROM items",
+		"group_by_key": "category",
+	}); err == nil || !strings.Contains(err.Error(), "not present") {
+		t.Fatalf("got %v for a missing key column", err)
+	}
+}
+
+func TestLimitResultsByCells(t *testing.T) {
+	// 20 columns, so 100 cells hold 5 rows
+	var columns []string
+	for i := 0; i < 20; i++ {
+		columns = append(columns, fmt.Sprintf("c%d", i))
+	}
+	srv := newTestServer(t,
+		"CREATE TABLE wide ("+strings.Join(columns, ", ")+")",
+		"INSERT INTO wide (c0) WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 50) SELECT i FROM n",
+	)
+	srv.SetResultLimits(20, 100)
+
+	text := mustCall(t, srv, "query", map[string]interface{}{"query": "SELECT * FROM wide"})
+	if !strings.Contains(text, "Returned 5 rows") || !strings.Contains(text, "truncated from 50 rows by the cell limit of 100 at 20 columns per row") {
+		t.Fatalf("unexpected result:\n%s", text)
+	}
+
+	// A narrow result is cut by the row limit instead
+	text = mustCall(t, srv, "query", map[string]interface{}{"query": "SELECT c0 FROM wide"})
+	if !strings.Contains(text, "Returned 20 rows") || !strings.Contains(text, "by the row limit of 20") {
+		t.Fatalf("unexpected result:\n%s", text)
+	}
+
+	rows := []map[string]interface{}{{"a": 1, "b": 2}, {"a": 3, "b": 4}}
+	if kept, note := limitResults(rows, 0, 0); len(kept) != 2 || note != "" {
+		t.Fatalf("limits of zero kept %d rows, note %q", len(kept), note)
+	}
+}
+
+func TestBlobParamRoundTrip(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE files (name TEXT, data BLOB)")
+
+	mustCall(t, srv, "execute", map[string]interface{}{
+		"statement": "INSERT INTO files VALUES (?, ?)",
+		"params":    []interface{}{"logo", map[string]interface{}{"base64": "AAEC/v9h"}},
+	})
+	rows, err := srv.db.ExecuteQuery("SELECT typeof(data) AS kind FROM files")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[0]["kind"] != "blob" {
+		t.Fatalf("stored as %v, want blob", rows[0]["kind"])
+	}
+
+	text := mustCall(t, srv, "query", map[string]interface{}{
+		"query":  "SELECT data FROM files WHERE data = ?",
+		"params": []interface{}{map[string]interface{}{"base64": "AAEC/v9h"}},
+	})
+	if !strings.Contains(text, `"base64": "AAEC/v9h"`) {
+		t.Fatalf("blob not returned as base64: %s", text)
+	}
+
+	if _, err := callTool(t, srv, "execute", map[string]interface{}{
+		"statement": "INSERT INTO files VALUES (?, ?)",
+		"params":    []interface{}{"bad", map[string]interface{}{"base64": "%%%"}},
+	}); err == nil {
+		t.Fatal("invalid base64 accepted")
+	}
+}
+
+func TestFormatCSV(t *testing.T) {
+	columns := []string{"id", "name", "score", "id", "data"}
+	results := []map[string]interface{}{
+		{"id": int64(1), "name": "plain", "score": 1e21, "data": database.Blob{0, 0xff}},
+		{"id": int64(2), "name": "has, comma \"and\" quotes\nnewline", "score": nil, "data": nil},
+	}
+	got, err := formatCSV(columns, results)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "id,name,score,data\n" +
+		"1,plain,1000000000000000000000,AP8=\n" +
+		"2,\"has, comma \"\"and\"\" quotes\nnewline\",,\n"
+	if got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestQueryCSVFormat(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE items (name TEXT, price REAL)",
+		"INSERT INTO items VALUES ('pen', 1.5), ('cup', NULL)",
+	)
+
+	text := mustCall(t, srv, "query", map[string]interface{}{
+		"query":  "SELECT name, price FROM items ORDER BY name",
+		"format": "csv",
+	})
+	if !strings.HasSuffix(text, ":\nname,price\ncup,\npen,1.5\n") {
+		t.Fatalf("unexpected csv result: %s", text)
+	}
+
+	for _, args := range []map[string]interface{}{
+		{"format": "xml"},
+		{"format": "csv", "group_by_key": "name"},
+		{"format": "csv", "include_provenance": true},
+	} {
+		args["query"] = "SELECT name FROM items"
+		if _, err := callTool(t, srv, "query", args); err == nil {
+			t.Errorf("accepted %v", args)
+		}
+	}
+}
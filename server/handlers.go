@@ -4,9 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
@@ -30,7 +33,7 @@ func (s *SQLiteServer) handleCallTool(ctx context.Context, request mcp.CallToolR
 	case "create_table":
 		return s.handleCreateTable(ctx, args)
 	case "list_tables":
-		return s.handleListTables(ctx)
+		return s.handleListTables(ctx, args)
 	case "describe_table":
 		return s.handleDescribeTable(ctx, args)
 	case "transaction":
@@ -60,8 +63,153 @@ func (s *SQLiteServer) handleCallTool(ctx context.Context, request mcp.CallToolR
 	}
 }
 
+// parseParams converts the params tool argument into values bound to the statement's
+// placeholders. JSON numbers without a fraction are bound as integers.
+func parseParams(raw interface{}) ([]interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("params must be an array of values")
+	}
+
+	params := make([]interface{}, len(list))
+	for i, value := range list {
+		param, ok := paramValue(value)
+		if !ok {
+			return nil, fmt.Errorf("params[%d] must be a string, number, boolean, null, or {\"base64\": \"...\"} for a BLOB", i)
+		}
+		params[i] = param
+	}
+	return params, nil
+}
+
+// parseNamedParams converts the named_params tool argument into sql.Named values bound to the
+// query's :name, @name, or $name parameters. Keys may be given with or without the prefix.
+// Every named parameter of the query must have an entry, and every entry must be used.
+func parseNamedParams(query string, raw interface{}) ([]interface{}, error) {
+	if raw == nil {
+		return nil, nil
+	}
+	values, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("named_params must be an object mapping parameter names to values")
+	}
+
+	given := make(map[string]interface{}, len(values))
+	for key, value := range values {
+		param, ok := paramValue(value)
+		if !ok {
+			return nil, fmt.Errorf("named_params[%s] must be a string, number, boolean, null, or {\"base64\": \"...\"} for a BLOB", key)
+		}
+		given[strings.TrimLeft(key, ":@$")] = param
+	}
+
+	var params []interface{}
+	var missing []string
+	used := make(map[string]bool)
+	for _, name := range database.NamedParameters(query) {
+		value, ok := given[name]
+		if !ok {
+			missing = append(missing, ":"+name)
+			continue
+		}
+		used[name] = true
+		params = append(params, sql.Named(name, value))
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("named_params has no value for %s", strings.Join(missing, ", "))
+	}
+	var unused []string
+	for name := range given {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return nil, fmt.Errorf("named_params has entries the query does not use: %s", strings.Join(unused, ", "))
+	}
+	return params, nil
+}
+
+// paramValue converts a JSON value into a value bound to a placeholder. JSON numbers without
+// a fraction are bound as integers, and {"base64": "..."} objects, the form query results use
+// for BLOBs, as BLOBs.
+func paramValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil, string, bool:
+		return v, true
+	case float64:
+		if v == math.Trunc(v) && v >= math.MinInt64 && v <= math.MaxInt64 {
+			return int64(v), true
+		}
+		return v, true
+	case map[string]interface{}:
+		if data, _, err := database.DecodeBlob(v); err == nil {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// defaultQueryLimit caps the rows returned by the query tool when no limit is given
+const defaultQueryLimit = 1000
+
+// queryPage is the window of result rows requested from the query tool
+type queryPage struct {
+	limit, offset int
+	explicit      bool // limit or offset was passed by the caller
+}
+
+// parseQueryPage reads the limit and offset arguments of the query tool
+func parseQueryPage(args map[string]interface{}) (queryPage, error) {
+	page := queryPage{limit: defaultQueryLimit}
+	if raw, ok := args["limit"]; ok && raw != nil {
+		limit, ok := raw.(float64)
+		if !ok || limit < 1 || limit != math.Trunc(limit) {
+			return page, fmt.Errorf("limit must be a positive integer")
+		}
+		page.limit = int(limit)
+		page.explicit = true
+	}
+	if raw, ok := args["offset"]; ok && raw != nil {
+		offset, ok := raw.(float64)
+		if !ok || offset < 0 || offset != math.Trunc(offset) {
+			return page, fmt.Errorf("offset must be a non-negative integer")
+		}
+		page.offset = int(offset)
+		page.explicit = true
+	}
+	return page, nil
+}
+
+// describe summarizes which rows of the result a page holds
+func (p queryPage) describe(rows int, more bool) string {
+	var note string
+	if rows == 0 {
+		note = fmt.Sprintf("no rows at offset %d, limit %d", p.offset, p.limit)
+	} else {
+		note = fmt.Sprintf("rows %d–%d, limit %d, offset %d", p.offset, p.offset+rows-1, p.limit, p.offset)
+	}
+	if more {
+		if !p.explicit {
+			note += fmt.Sprintf("; capped at the default limit of %d rows", defaultQueryLimit)
+		}
+		note += fmt.Sprintf("; more rows follow, use offset %d for the next page", p.offset+rows)
+	}
+	return note
+}
+
+// isSelectQuery reports whether query starts with SELECT
+func isSelectQuery(query string) bool {
+	return strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT")
+}
+
 // handleQuery handles query requests
 func (s *SQLiteServer) handleQuery(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	query, ok := args["query"].(string)
 	if !ok {
 		return nil, fmt.Errorf("query parameter is required")
@@ -69,17 +217,153 @@ func (s *SQLiteServer) handleQuery(ctx context.Context, args map[string]interfac
 
 	// Validate that it's a SELECT query
 	trimmedQuery := strings.TrimSpace(strings.ToUpper(query))
-	if !strings.HasPrefix(trimmedQuery, "SELECT") && !strings.HasPrefix(trimmedQuery, "PRAGMA") {
+	if !isSelectQuery(query) && !strings.HasPrefix(trimmedQuery, "PRAGMA") {
 		return nil, fmt.Errorf("only SELECT and PRAGMA queries are allowed with this tool")
 	}
 
-	results, err := s.db.ExecuteQuery(query)
+	params, err := parseParams(args["params"])
+	if err != nil {
+		return nil, err
+	}
+	named, err := parseNamedParams(query, args["named_params"])
+	if err != nil {
+		return nil, err
+	}
+	if len(params) > 0 && len(named) > 0 {
+		// SQLite numbers named parameters along with the ? ones, so positional values would
+		// land on the wrong placeholders
+		return nil, fmt.Errorf("params and named_params cannot be combined; pass every value by name or every value by position")
+	}
+	params = append(params, named...)
+
+	format, _ := args["format"].(string)
+	switch format {
+	case "", "json":
+	case "csv":
+		groupKey, _ := args["group_by_key"].(string)
+		includeProvenance, _ := args["include_provenance"].(bool)
+		if groupKey != "" || includeProvenance {
+			return nil, fmt.Errorf("group_by_key and include_provenance are not available with csv format")
+		}
+	default:
+		return nil, fmt.Errorf("format must be json or csv")
+	}
+
+	// -2 leaves REAL values to encoding/json
+	realPrecision := -2
+	if fixed, _ := args["fixed_reals"].(bool); fixed {
+		realPrecision = -1
+	}
+	if raw, ok := args["real_precision"]; ok && raw != nil {
+		precision, ok := raw.(float64)
+		if !ok || precision < 0 || precision > 20 || precision != math.Trunc(precision) {
+			return nil, fmt.Errorf("real_precision must be an integer between 0 and 20")
+		}
+		realPrecision = int(precision)
+	}
+
+	var numberFormats map[string]NumberFormat
+	if raw, ok := args["number_format"]; ok && raw != nil {
+		formats, err := parseNumberFormats(raw)
+		if err != nil {
+			return nil, err
+		}
+		numberFormats = formats
+	}
+
+	page, err := parseQueryPage(args)
+	if err != nil {
+		return nil, err
+	}
+	// A query with its own LIMIT is only paged on request, and then the two would conflict
+	paged := true
+	if database.HasTopLevelLimit(query) {
+		if page.explicit {
+			return nil, fmt.Errorf("the query already has a LIMIT clause; remove it or omit the limit and offset arguments")
+		}
+		paged = false
+	}
+
+	// One row past the page tells whether more rows follow. PRAGMA results cannot be
+	// selected from, so they are paged after reading them.
+	isPragma := strings.HasPrefix(trimmedQuery, "PRAGMA")
+	if paged && !isPragma {
+		query = fmt.Sprintf("%s\nLIMIT %d OFFSET %d",
+			strings.TrimRight(strings.TrimSpace(query), "; \t\n"), page.limit+1, page.offset)
+	}
+
+	columns, results, err := db.ExecuteQueryColumnsContext(ctx, query, params...)
 	if err != nil {
 		return nil, fmt.Errorf("query failed: %w", err)
 	}
 
+	var pageNote string
+	if paged {
+		if isPragma {
+			results = results[min(page.offset, len(results)):]
+		}
+		more := len(results) > page.limit
+		if more {
+			results = results[:page.limit]
+		}
+		if more || page.explicit {
+			pageNote = page.describe(len(results), more)
+		}
+	}
+
+	results, truncationNote := limitResults(results, s.maxRows, s.maxCells)
+
+	// Mask sensitive columns before grouping so their values cannot leak through group keys
+	redactColumns(results, s.redactedColumns(query, params, results))
+
+	// Group on the raw key values before any display formatting
+	var groups map[string][]map[string]interface{}
+	if groupKey, ok := args["group_by_key"].(string); ok && groupKey != "" {
+		groups, err = groupRowsByKey(results, groupKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// Display-only formatting, stored data is unchanged
+	applyNumberFormats(results, numberFormats)
+	if realPrecision >= -1 {
+		applyRealFormat(results, realPrecision)
+	}
+
 	// 格式化结果
-	jsonResult, err := json.MarshalIndent(results, "", "  ")
+	var output interface{} = results
+	summary := fmt.Sprintf("Returned %d rows", len(results))
+	if groups != nil {
+		output = groups
+		summary += fmt.Sprintf(" in %d group(s)", len(groups))
+	}
+	if pageNote != "" {
+		summary += " (" + pageNote + ")"
+	}
+	if truncationNote != "" {
+		summary += "; " + truncationNote
+	}
+
+	if includeProvenance, _ := args["include_provenance"].(bool); includeProvenance {
+		origins, err := db.QueryColumnOrigins(query, params...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve column provenance: %w", err)
+		}
+		output = map[string]interface{}{
+			"columns": origins,
+			"rows":    output,
+		}
+	}
+
+	var body string
+	if format == "csv" {
+		body, err = formatCSV(columns, results)
+	} else {
+		var jsonResult []byte
+		jsonResult, err = json.MarshalIndent(output, "", "  ")
+		body = string(jsonResult)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to format results: %w", err)
 	}
@@ -88,15 +372,67 @@ func (s *SQLiteServer) handleQuery(ctx context.Context, args map[string]interfac
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("[Database: %s]\nQuery executed successfully. Returned %d rows:\n%s",
-					s.db.GetCurrentDatabasePath(), len(results), string(jsonResult)),
+				Text: fmt.Sprintf("[Database: %s]\nQuery executed successfully. %s:\n%s",
+					db.GetCurrentDatabasePath(), summary, body),
 			},
 		},
 	}, nil
 }
 
+// redactedColumns returns the result columns of a query that must be masked: those whose
+// name matches a redaction pattern, and those read or computed from a matching source
+// column under any name, e.g. max(ssn) or one arm of a UNION selecting ssn.
+func (s *SQLiteServer) redactedColumns(query string, params []interface{}, results []map[string]interface{}) map[string]bool {
+	if len(s.redactPatterns) == 0 || len(results) == 0 {
+		return nil
+	}
+
+	redacted := make(map[string]bool)
+	for column := range results[0] {
+		if matchesRedaction(column, s.redactPatterns) {
+			redacted[column] = true
+		}
+	}
+	// Provenance is best effort; the name match above still applies if it fails
+	if origins, err := s.db.QueryColumnOrigins(query, params...); err == nil {
+		for _, origin := range origins {
+			if s.sensitiveOrigin(origin) {
+				redacted[origin.Name] = true
+			}
+		}
+	}
+	return redacted
+}
+
+// sensitiveOrigin reports whether a result column is read or computed from a source column
+// matching a redaction pattern
+func (s *SQLiteServer) sensitiveOrigin(origin database.ColumnOrigin) bool {
+	if origin.Column != "" && matchesRedaction(origin.Column, s.redactPatterns) {
+		return true
+	}
+	for _, input := range origin.Inputs {
+		if matchesRedaction(input.Column, s.redactPatterns) {
+			return true
+		}
+	}
+	return false
+}
+
+// nameRedactedColumns returns the result columns whose name matches a redaction pattern, for
+// statements that cannot be run again to trace their column origins
+func (s *SQLiteServer) nameRedactedColumns(columns []string) map[string]bool {
+	redacted := make(map[string]bool)
+	for _, column := range columns {
+		if matchesRedaction(column, s.redactPatterns) {
+			redacted[column] = true
+		}
+	}
+	return redacted
+}
+
 // handleExecute handles execute statement requests
 func (s *SQLiteServer) handleExecute(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	statement, ok := args["statement"].(string)
 	if !ok {
 		return nil, fmt.Errorf("statement parameter is required")
@@ -108,7 +444,22 @@ func (s *SQLiteServer) handleExecute(ctx context.Context, args map[string]interf
 		return nil, fmt.Errorf("use the 'query' tool for SELECT statements")
 	}
 
-	affected, err := s.db.ExecuteStatement(statement)
+	params, err := parseParams(args["params"])
+	if err != nil {
+		return nil, err
+	}
+
+	if dryRun, _ := args["dry_run"].(bool); dryRun {
+		return s.dryRunExecute(ctx, statement, params)
+	}
+	if database.HasReturningClause(statement) {
+		return s.executeReturning(ctx, statement, params)
+	}
+
+	affected, err := db.ExecuteStatementContext(ctx, statement, params...)
+	if errors.Is(err, database.ErrDiskFull) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("execution failed: %w", err)
 	}
@@ -130,108 +481,107 @@ func (s *SQLiteServer) handleExecute(ctx context.Context, args map[string]interf
 	}, nil
 }
 
-// handleCreateTable handles create table requests
-func (s *SQLiteServer) handleCreateTable(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
-	tableName, ok := args["table_name"].(string)
-	if !ok {
-		return nil, fmt.Errorf("table_name parameter is required")
-	}
-
-	columnsRaw, ok := args["columns"]
-	if !ok {
-		return nil, fmt.Errorf("columns parameter is required")
-	}
-
-	// Convert column definitions
-	columnsArray, ok := columnsRaw.([]interface{})
-	if !ok {
-		return nil, fmt.Errorf("columns must be an array")
-	}
-
-	var columns []map[string]string
-	for _, col := range columnsArray {
-		colMap, ok := col.(map[string]interface{})
-		if !ok {
-			return nil, fmt.Errorf("each column must be an object")
-		}
-
-		column := make(map[string]string)
-		if name, ok := colMap["name"].(string); ok {
-			column["name"] = name
-		}
-		if colType, ok := colMap["type"].(string); ok {
-			column["type"] = colType
-		}
-		if constraints, ok := colMap["constraints"].(string); ok {
-			column["constraints"] = constraints
-		}
-
-		columns = append(columns, column)
+// dryRunExecute runs a statement for the execute tool in a transaction that is rolled back,
+// reporting the rows it would affect
+func (s *SQLiteServer) dryRunExecute(ctx context.Context, statement string, params []interface{}) (*mcp.CallToolResult, error) {
+	results, affected, err := s.runStatements(ctx, []string{statement}, params, true)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := s.db.CreateTable(tableName, columns); err != nil {
-		return nil, fmt.Errorf("failed to create table: %w", err)
+	jsonResult, err := json.MarshalIndent(results[0], "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format results: %w", err)
 	}
-
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Table '%s' created successfully", tableName),
+				Text: fmt.Sprintf("%s Rows that would be affected: %d\n%s", dryRunNotice, affected, string(jsonResult)),
 			},
 		},
 	}, nil
 }
 
-// handleListTables handles list tables requests
-func (s *SQLiteServer) handleListTables(ctx context.Context) (*mcp.CallToolResult, error) {
-	tables, err := s.db.GetTables()
+// executeReturning runs a statement with a RETURNING clause for the execute tool and returns
+// its rows as JSON
+func (s *SQLiteServer) executeReturning(ctx context.Context, statement string, params []interface{}) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	columns, results, err := db.ExecuteStatementReturning(ctx, statement, params...)
+	if errors.Is(err, database.ErrDiskFull) {
+		return nil, err
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to list tables: %w", err)
+		return nil, fmt.Errorf("execution failed: %w", err)
 	}
 
-	var message string
-	if len(tables) == 0 {
-		message = "No tables found in the database"
-	} else {
-		message = fmt.Sprintf("Found %d table(s):\n", len(tables))
-		for _, table := range tables {
-			message += fmt.Sprintf("- %s\n", table)
-		}
+	results, truncationNote := limitResults(results, s.maxRows, s.maxCells)
+	// Column origins come from running the statement, so only result column names are matched
+	redactColumns(results, s.nameRedactedColumns(columns))
+
+	if results == nil {
+		results = []map[string]interface{}{}
+	}
+	jsonResult, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format results: %w", err)
 	}
 
+	summary := fmt.Sprintf("Statement executed successfully and returned %d rows", len(results))
+	if truncationNote != "" {
+		summary += "; " + truncationNote
+	}
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: message,
+				Text: fmt.Sprintf("%s:\n%s", summary, string(jsonResult)),
 			},
 		},
 	}, nil
 }
 
-// handleDescribeTable handles describe table requests
-func (s *SQLiteServer) handleDescribeTable(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
-	tableName, ok := args["table_name"].(string)
+// handleExecuteMany handles execute many requests
+func (s *SQLiteServer) handleExecuteMany(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("table_name parameter is required")
+		return nil, fmt.Errorf("invalid arguments type")
 	}
 
-	schema, err := s.db.GetTableSchema(tableName)
+	statement, ok := args["statement"].(string)
+	if !ok || statement == "" {
+		return nil, fmt.Errorf("statement parameter is required and cannot be empty")
+	}
+	rawSets, ok := args["param_sets"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("param_sets parameter is required and must be an array of parameter arrays")
+	}
+	paramSets := make([][]interface{}, len(rawSets))
+	for i, raw := range rawSets {
+		params, err := parseParams(raw)
+		if err != nil {
+			return nil, fmt.Errorf("param_sets[%d]: %w", i, err)
+		}
+		paramSets[i] = params
+	}
+	perSet, _ := args["per_set_results"].(bool)
+
+	result, err := db.ExecuteMany(ctx, statement, paramSets, perSet)
 	if err != nil {
-		return nil, fmt.Errorf("failed to describe table: %w", err)
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("execution failed, no changes were made: %w", err)
 	}
 
-	var message string
-	if len(schema) == 0 {
-		message = fmt.Sprintf("Table '%s' does not exist or has no columns", tableName)
-	} else {
-		// Format results
-		jsonSchema, err := json.MarshalIndent(schema, "", "  ")
+	message := fmt.Sprintf("Executed the statement with %d parameter set(s) in one transaction. Rows affected: %d", result.Sets, result.RowsAffected)
+	if perSet {
+		jsonResult, err := json.MarshalIndent(result.Results, "", "  ")
 		if err != nil {
-			return nil, fmt.Errorf("failed to format schema: %w", err)
+			return nil, fmt.Errorf("failed to format results: %w", err)
 		}
-		message = fmt.Sprintf("Schema for table '%s':\n%s", tableName, string(jsonSchema))
+		message += fmt.Sprintf("\nPer-set results:\n%s", string(jsonResult))
 	}
 
 	return &mcp.CallToolResult{
@@ -244,77 +594,69 @@ func (s *SQLiteServer) handleDescribeTable(ctx context.Context, args map[string]
 	}, nil
 }
 
-// handleTransaction handles transaction requests
-func (s *SQLiteServer) handleTransaction(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
-	statementsRaw, ok := args["statements"]
+// handleUpsert handles upsert requests
+func (s *SQLiteServer) handleUpsert(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("statements parameter is required")
+		return nil, fmt.Errorf("invalid arguments type")
 	}
 
-	statementsArray, ok := statementsRaw.([]interface{})
+	tableName, ok := args["table_name"].(string)
 	if !ok {
-		return nil, fmt.Errorf("statements must be an array")
+		return nil, fmt.Errorf("table_name parameter is required")
 	}
-
-	if len(statementsArray) == 0 {
-		return nil, fmt.Errorf("at least one statement is required")
+	rawRow, ok := args["row"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("row parameter is required and must be an object")
 	}
-
-	var statements []string
-	for i, stmt := range statementsArray {
-		if s, ok := stmt.(string); ok {
-			// Validate that it's not a SELECT query
-			trimmedStmt := strings.TrimSpace(strings.ToUpper(s))
-			if strings.HasPrefix(trimmedStmt, "SELECT") {
-				return nil, fmt.Errorf("statement %d: SELECT queries are not allowed in transactions, use the 'query' tool instead", i+1)
-			}
-			statements = append(statements, s)
-		} else {
-			return nil, fmt.Errorf("statement %d must be a string", i+1)
+	row := make(map[string]interface{}, len(rawRow))
+	for column, raw := range rawRow {
+		value, ok := paramValue(raw)
+		if !ok {
+			return nil, fmt.Errorf("the value of column '%s' must be a string, number, boolean, null, or {\"base64\": \"...\"} for a BLOB", column)
 		}
+		row[column] = value
 	}
-
-	var totalAffected int64
-	var executedStatements int
-
-	err := s.db.Transaction(func(tx *sql.Tx) error {
-		for i, stmt := range statements {
-			result, err := tx.Exec(stmt)
-			if err != nil {
-				return fmt.Errorf("statement %d (%s): %w", i+1, strings.Split(stmt, " ")[0], err)
-			}
-
-			if affected, err := result.RowsAffected(); err == nil {
-				totalAffected += affected
-			}
-			executedStatements++
+	list, ok := args["conflict_columns"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("conflict_columns parameter is required and must be an array of column names")
+	}
+	conflictColumns := make([]string, 0, len(list))
+	for i, item := range list {
+		column, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("conflict column %d is not a string", i)
 		}
-		return nil
-	})
+		conflictColumns = append(conflictColumns, column)
+	}
 
+	result, err := db.Upsert(tableName, row, conflictColumns)
 	if err != nil {
-		return nil, fmt.Errorf("transaction failed: %w", err)
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to upsert row: %w", err)
 	}
 
-	var message string
-	if executedStatements == 1 {
-		message = fmt.Sprintf("Transaction completed successfully. 1 statement executed. Rows affected: %d", totalAffected)
-	} else {
-		message = fmt.Sprintf("Transaction completed successfully. %d statements executed. Total rows affected: %d", executedStatements, totalAffected)
+	jsonResult, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format upsert result: %w", err)
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: message,
+				Text: fmt.Sprintf("Row %s in table '%s':\n%s", result.Action, tableName, string(jsonResult)),
 			},
 		},
 	}, nil
 }
 
-// handleDropTable handles drop table requests
-func (s *SQLiteServer) handleDropTableTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// handleInsertRows handles insert rows requests
+func (s *SQLiteServer) handleInsertRows(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	args, ok := request.Params.Arguments.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid arguments type")
@@ -324,298 +666,3525 @@ func (s *SQLiteServer) handleDropTableTool(ctx context.Context, request mcp.Call
 	if !ok {
 		return nil, fmt.Errorf("table_name parameter is required")
 	}
-
-	if err := s.db.DropTable(tableName); err != nil {
-		return nil, fmt.Errorf("failed to drop table: %w", err)
+	list, ok := args["rows"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("rows parameter is required and must be an array of objects")
+	}
+	rows := make([]map[string]interface{}, len(list))
+	for i, item := range list {
+		rawRow, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("row %d is not an object", i)
+		}
+		row := make(map[string]interface{}, len(rawRow))
+		for column, raw := range rawRow {
+			value, ok := paramValue(raw)
+			if !ok {
+				return nil, fmt.Errorf("row %d: the value of column '%s' must be a string, number, boolean, null, or {\"base64\": \"...\"} for a BLOB", i, column)
+			}
+			row[column] = value
+		}
+		rows[i] = row
+	}
+
+	result, err := db.InsertRows(ctx, tableName, rows)
+	if err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("insert failed, no rows were inserted: %w", err)
+	}
+
+	jsonResult, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format insert result: %w", err)
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Table '%s' dropped successfully", tableName),
+				Text: fmt.Sprintf("Inserted %d rows into table '%s' in one transaction:\n%s", result.Inserted, tableName, string(jsonResult)),
 			},
 		},
 	}, nil
 }
 
-// handleCreateIndex handles create index requests
-func (s *SQLiteServer) handleCreateIndexTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// handleRawExec handles raw statement execution requests
+func (s *SQLiteServer) handleRawExec(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	if !s.allowRaw {
+		return nil, fmt.Errorf("raw_exec is disabled; start the server with --allow-raw to enable it")
+	}
+
 	args, ok := request.Params.Arguments.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid arguments type")
 	}
 
-	indexName, ok := args["index_name"].(string)
+	statement, ok := args["statement"].(string)
 	if !ok {
-		return nil, fmt.Errorf("index_name parameter is required")
+		return nil, fmt.Errorf("statement parameter is required")
+	}
+
+	result, err := db.RawExec(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	var message string
+	if len(result.Columns) > 0 {
+		// The statement may have side effects, so only result column names are matched
+		redactColumns(result.Rows, s.nameRedactedColumns(result.Columns))
+
+		jsonResult, err := json.MarshalIndent(result.Rows, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format results: %w", err)
+		}
+		message = fmt.Sprintf("Statement returned %d rows:\n%s", len(result.Rows), string(jsonResult))
+	} else {
+		message = fmt.Sprintf("Statement executed successfully. Rows affected: %d, last insert ID: %d",
+			result.RowsAffected, result.LastInsertID)
 	}
 
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleCreateTable handles create table requests
+func (s *SQLiteServer) handleCreateTable(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	tableName, ok := args["table_name"].(string)
 	if !ok {
 		return nil, fmt.Errorf("table_name parameter is required")
 	}
 
-	// Parse columns
 	columnsRaw, ok := args["columns"]
 	if !ok {
 		return nil, fmt.Errorf("columns parameter is required")
 	}
 
+	// Convert column definitions
 	columnsArray, ok := columnsRaw.([]interface{})
 	if !ok {
 		return nil, fmt.Errorf("columns must be an array")
 	}
 
-	if len(columnsArray) == 0 {
-		return nil, fmt.Errorf("at least one column must be specified")
-	}
-
-	var columns []string
-	var indexColumns []database.IndexColumn
-
-	for _, colRaw := range columnsArray {
-		colMap, ok := colRaw.(map[string]interface{})
+	var columns []map[string]string
+	for _, col := range columnsArray {
+		colMap, ok := col.(map[string]interface{})
 		if !ok {
 			return nil, fmt.Errorf("each column must be an object")
 		}
 
-		colName, ok := colMap["name"].(string)
-		if !ok {
-			return nil, fmt.Errorf("column name is required")
+		column := make(map[string]string)
+		if name, ok := colMap["name"].(string); ok {
+			column["name"] = name
+		}
+		if colType, ok := colMap["type"].(string); ok {
+			column["type"] = colType
+		}
+		if constraints, ok := colMap["constraints"].(string); ok {
+			column["constraints"] = constraints
 		}
 
-		columns = append(columns, colName)
+		columns = append(columns, column)
+	}
 
-		indexCol := database.IndexColumn{Name: colName}
-		if sortOrder, ok := colMap["sort_order"].(string); ok {
-			indexCol.SortOrder = sortOrder
-		}
-		indexColumns = append(indexColumns, indexCol)
+	if err := db.CreateTable(tableName, columns); err != nil {
+		return nil, fmt.Errorf("failed to create table: %w", err)
 	}
 
-	// Parse optional parameters
-	unique := false
-	if uniqueVal, ok := args["unique"].(bool); ok {
-		unique = uniqueVal
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Table '%s' created successfully", tableName),
+			},
+		},
+	}, nil
+}
+
+// handleListTables handles list tables requests
+func (s *SQLiteServer) handleListTables(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	tables, err := db.GetTables()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tables: %w", err)
 	}
 
-	ifNotExists := false
-	if ifNotExistsVal, ok := args["if_not_exists"].(bool); ok {
-		ifNotExists = ifNotExistsVal
+	// Tables denied by the access policy are not listed
+	visible := tables[:0]
+	for _, table := range tables {
+		if s.tableAllowed(table) {
+			visible = append(visible, table)
+		}
 	}
+	tables = visible
 
-	whereClause := ""
-	if whereVal, ok := args["where_clause"].(string); ok {
-		whereClause = whereVal
+	if withStats, _ := args["with_stats"].(bool); withStats {
+		return s.listTablesWithStats(tables, args)
 	}
 
-	// Use advanced options if any advanced features are requested
-	if len(indexColumns) > 1 || whereClause != "" || (len(indexColumns) == 1 && indexColumns[0].SortOrder != "") {
-		options := database.IndexOptions{
-			IndexName:   indexName,
-			TableName:   tableName,
-			Columns:     indexColumns,
-			Unique:      unique,
-			IfNotExists: ifNotExists,
-			WhereClause: whereClause,
+	var message string
+	if len(tables) == 0 {
+		message = "No tables found in the database"
+	} else {
+		message = fmt.Sprintf("Found %d table(s):\n", len(tables))
+		for _, table := range tables {
+			message += fmt.Sprintf("- %s\n", table)
 		}
+	}
 
-		if err := s.db.CreateIndexWithOptions(options); err != nil {
-			return nil, fmt.Errorf("failed to create index: %w", err)
+	if includeViews, _ := args["include_views"].(bool); includeViews {
+		views, err := s.visibleViews()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list views: %w", err)
 		}
-	} else {
-		// Use simple method for single column, no sort order, no where clause
-		if err := s.db.CreateIndex(indexName, tableName, columns, unique, ifNotExists); err != nil {
-			return nil, fmt.Errorf("failed to create index: %w", err)
+		if len(views) == 0 {
+			message = strings.TrimSuffix(message, "\n") + "\nNo views found in the database"
+		} else {
+			message = strings.TrimSuffix(message, "\n") + fmt.Sprintf("\nFound %d view(s):\n", len(views))
+			for _, view := range views {
+				message += fmt.Sprintf("- %s (view)\n", view.Name)
+			}
 		}
 	}
 
-	// Build response message
-	indexType := "non-unique"
-	if unique {
-		indexType = "unique"
-	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
 
-	existsText := ""
-	if ifNotExists {
-		existsText = " (if not exists)"
+// listTablesWithStats answers list_tables with_stats: the row count and size of each table as JSON
+func (s *SQLiteServer) listTablesWithStats(tables []string, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	report, err := s.db.GetTableStats(tables)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get table statistics: %w", err)
 	}
 
-	response := fmt.Sprintf("%s index '%s'%s created successfully on %s.%s",
-		indexType, indexName, existsText, tableName, strings.Join(columns, ", "))
+	result := struct {
+		*database.TableStatsReport
+		Views []string `json:"views,omitempty"`
+	}{TableStatsReport: report}
+	if includeViews, _ := args["include_views"].(bool); includeViews {
+		views, err := s.visibleViews()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list views: %w", err)
+		}
+		result.Views = []string{}
+		for _, view := range views {
+			result.Views = append(result.Views, view.Name)
+		}
+	}
 
-	if whereClause != "" {
-		response += fmt.Sprintf(" WHERE %s", whereClause)
+	jsonResult, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format table statistics: %w", err)
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: response,
+				Text: fmt.Sprintf("Found %d table(s):\n%s", len(tables), string(jsonResult)),
 			},
 		},
 	}, nil
 }
 
-// handleListIndexes handles list indexes requests
-func (s *SQLiteServer) handleListIndexesTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args, ok := request.Params.Arguments.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid arguments type")
-	}
-
+// handleDescribeTable handles describe table requests
+func (s *SQLiteServer) handleDescribeTable(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	tableName, ok := args["table_name"].(string)
 	if !ok {
 		return nil, fmt.Errorf("table_name parameter is required")
 	}
 
-	indexes, err := s.db.GetIndexes(tableName)
+	details, err := db.GetTableDetails(tableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list indexes: %w", err)
+		return nil, fmt.Errorf("failed to describe table: %w", err)
 	}
 
-	var message string
-	if len(indexes) == 0 {
-		message = fmt.Sprintf("No indexes found for table '%s'", tableName)
-	} else {
-		message = fmt.Sprintf("Found %d index(es) for table '%s':\n", len(indexes), tableName)
-		for _, index := range indexes {
-			if name, ok := index["name"].(string); ok {
-				message += fmt.Sprintf("- %s\n", name)
-			}
+	// Relationships to tables denied by the access policy are not shown
+	visible := details.ForeignKeys[:0]
+	for _, fk := range details.ForeignKeys {
+		if s.tableAllowed(fk.ReferencedTable) {
+			visible = append(visible, fk)
 		}
 	}
+	details.ForeignKeys = visible
+
+	jsonDetails, err := json.MarshalIndent(details, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format schema: %w", err)
+	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: message,
+				Text: fmt.Sprintf("Schema for table '%s':\n%s", tableName, string(jsonDetails)),
 			},
 		},
 	}, nil
 }
 
-// handleDropIndexTool handles drop index requests
-func (s *SQLiteServer) handleDropIndexTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// handleGetTableDDL handles get table DDL requests
+func (s *SQLiteServer) handleGetTableDDL(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	args, ok := request.Params.Arguments.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid arguments type")
 	}
 
-	indexName, ok := args["index_name"].(string)
+	tableName, ok := args["table_name"].(string)
 	if !ok {
-		return nil, fmt.Errorf("index_name parameter is required")
+		return nil, fmt.Errorf("table_name parameter is required")
 	}
 
-	err := s.db.DropIndex(indexName)
+	ddl, err := db.GetTableDDL(tableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to drop index '%s': %w", indexName, err)
-	}
-
-	message := fmt.Sprintf("Successfully dropped index '%s'", indexName)
-	return &mcp.CallToolResult{
-		Content: []mcp.Content{
-			mcp.TextContent{
-				Type: "text",
-				Text: message,
-			},
-		},
-	}, nil
-}
-
-// handleVacuum handles vacuum requests
-func (s *SQLiteServer) handleVacuum(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	if err := s.db.Vacuum(); err != nil {
-		return nil, fmt.Errorf("failed to vacuum database: %w", err)
+		return nil, fmt.Errorf("failed to get table DDL: %w", err)
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: "Database vacuum completed successfully",
+				Text: fmt.Sprintf("DDL for table '%s':\n%s", tableName, ddl),
 			},
 		},
 	}, nil
 }
 
-// handleAnalyzeQuery handles analyze query requests
-func (s *SQLiteServer) handleAnalyzeQueryTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// handleGetRowidColumn handles rowid column requests
+func (s *SQLiteServer) handleGetRowidColumn(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	args, ok := request.Params.Arguments.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid arguments type")
 	}
 
-	query, ok := args["query"].(string)
+	tableName, ok := args["table_name"].(string)
 	if !ok {
-		return nil, fmt.Errorf("query parameter is required")
+		return nil, fmt.Errorf("table_name parameter is required")
 	}
 
-	plan, err := s.db.AnalyzeQuery(query)
+	info, err := db.GetRowidColumn(tableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to analyze query: %w", err)
+		return nil, fmt.Errorf("failed to get rowid column: %w", err)
 	}
 
-	// Format the query plan
-	jsonPlan, err := json.MarshalIndent(plan, "", "  ")
+	var summary string
+	switch {
+	case info.WithoutRowid:
+		summary = fmt.Sprintf("Table '%s' is WITHOUT ROWID; address rows by its primary key (%s)", tableName, strings.Join(info.PrimaryKey, ", "))
+	case info.AliasColumn != "":
+		summary = fmt.Sprintf("Column '%s' is an INTEGER PRIMARY KEY aliasing rowid in table '%s'", info.AliasColumn, tableName)
+	case info.RowidColumn != "":
+		summary = fmt.Sprintf("Table '%s' has no rowid alias column; use '%s'", tableName, info.RowidColumn)
+	default:
+		summary = fmt.Sprintf("Table '%s' has columns named rowid, _rowid_, and oid, so its rowid cannot be referenced", tableName)
+	}
+
+	jsonInfo, err := json.MarshalIndent(info, "", "  ")
 	if err != nil {
-		return nil, fmt.Errorf("failed to format query plan: %w", err)
+		return nil, fmt.Errorf("failed to format rowid info: %w", err)
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Query execution plan:\n%s", string(jsonPlan)),
+				Text: fmt.Sprintf("%s\n%s", summary, string(jsonInfo)),
 			},
 		},
 	}, nil
 }
 
-// handleDatabaseStats handles database stats requests
-func (s *SQLiteServer) handleDatabaseStatsTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	stats, err := s.db.GetDatabaseStats()
+// handleDetectKeys handles key detection requests
+func (s *SQLiteServer) handleDetectKeys(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	keys, err := db.DetectKeys(tableName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get database stats: %w", err)
+		return nil, fmt.Errorf("failed to detect keys: %w", err)
 	}
 
-	// Format the stats
-	jsonStats, err := json.MarshalIndent(stats, "", "  ")
+	var summary string
+	switch {
+	case len(keys.NaturalKey) > 0:
+		summary = fmt.Sprintf("Table '%s' has %d candidate key(s); natural key: (%s)", tableName, len(keys.CandidateKeys), strings.Join(keys.NaturalKey, ", "))
+	case len(keys.UpsertKey) > 0:
+		summary = fmt.Sprintf("Table '%s' has no natural key; only the primary key (%s) identifies rows", tableName, strings.Join(keys.UpsertKey, ", "))
+	default:
+		summary = fmt.Sprintf("Table '%s' has no primary key or unique constraint; rows can only be told apart by rowid, and upserts need a unique index first", tableName)
+	}
+
+	jsonKeys, err := json.MarshalIndent(keys, "", "  ")
 	if err != nil {
-		return nil, fmt.Errorf("failed to format database stats: %w", err)
+		return nil, fmt.Errorf("failed to format keys: %w", err)
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Database statistics:\n%s", string(jsonStats)),
+				Text: fmt.Sprintf("%s\n%s", summary, string(jsonKeys)),
 			},
 		},
 	}, nil
 }
 
-// handleCreateDatabase handles create database requests
-func (s *SQLiteServer) handleCreateDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	args, ok := request.Params.Arguments.(map[string]interface{})
+// handleTransaction handles transaction requests
+func (s *SQLiteServer) handleTransaction(ctx context.Context, args map[string]interface{}) (*mcp.CallToolResult, error) {
+	statementsRaw, ok := args["statements"]
 	if !ok {
-		return nil, fmt.Errorf("invalid arguments type")
+		return nil, fmt.Errorf("statements parameter is required")
 	}
 
-	directory, ok := args["directory"].(string)
-	if !ok || directory == "" {
-		return nil, fmt.Errorf("directory parameter is required and cannot be empty")
+	statementsArray, ok := statementsRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("statements must be an array")
 	}
 
-	// Auto-replace current directory with first allowed directory
-	if directory == "." || directory == "./" {
-		if len(s.allowedDirs) > 0 {
-			directory = s.allowedDirs[0]
-		} else {
-			return nil, fmt.Errorf("no allowed directories configured")
+	if len(statementsArray) == 0 {
+		return nil, fmt.Errorf("at least one statement is required")
+	}
+
+	// A huge transaction holds the write lock for its whole duration
+	if s.maxTxStatements > 0 && len(statementsArray) > s.maxTxStatements {
+		return nil, fmt.Errorf("transaction has %d statements, more than the limit of %d; split the work into smaller transactions, or use query_into_table to copy rows in bulk",
+			len(statementsArray), s.maxTxStatements)
+	}
+
+	var statements []string
+	for i, stmt := range statementsArray {
+		if s, ok := stmt.(string); ok {
+			statements = append(statements, s)
+		} else {
+			return nil, fmt.Errorf("statement %d must be a string", i+1)
+		}
+	}
+
+	dryRun, _ := args["dry_run"].(bool)
+	results, totalAffected, err := s.runStatements(ctx, statements, nil, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	var message string
+	if len(results) == 1 {
+		message = fmt.Sprintf("Transaction completed successfully. 1 statement executed. Rows affected: %d", totalAffected)
+	} else {
+		message = fmt.Sprintf("Transaction completed successfully. %d statements executed. Total rows affected: %d", len(results), totalAffected)
+	}
+	if dryRun {
+		message = fmt.Sprintf("%s %d statement(s) executed and rolled back. Rows that would be affected: %d", dryRunNotice, len(results), totalAffected)
+	}
+
+	jsonResults, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format results: %w", err)
+	}
+	message += fmt.Sprintf("\nStatements:\n%s", string(jsonResults))
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// dryRunNotice opens the response of a dry run of execute or transaction
+const dryRunNotice = "DRY RUN — no changes were committed."
+
+// errDryRun is returned from a Transaction callback to roll back a dry run
+var errDryRun = errors.New("dry run")
+
+// dryRunEscapes are the statements that end the transaction a dry run is rolled back with,
+// or act outside it, so that their effects would be kept
+var dryRunEscapes = map[string]bool{
+	"BEGIN": true, "COMMIT": true, "END": true, "ROLLBACK": true, "SAVEPOINT": true,
+	"RELEASE": true, "VACUUM": true, "ATTACH": true, "DETACH": true,
+}
+
+// checkDryRun rejects statements a dry run cannot roll back: a string holding several
+// statements, which are run past the checks as one, and statements that end or escape the
+// transaction
+func checkDryRun(statements []string) error {
+	for i, stmt := range statements {
+		if !database.IsSingleStatement(stmt) {
+			return fmt.Errorf("statement %d holds several statements; a dry run takes one statement per entry", i+1)
+		}
+		if keyword := database.LeadingKeyword(stmt); dryRunEscapes[keyword] {
+			return fmt.Errorf("statement %d (%s) cannot be part of a dry run, as its effect would not be rolled back", i+1, keyword)
+		}
+	}
+	return nil
+}
+
+// statementResult is the outcome of one statement run by runStatements, with the rows of
+// SELECT statements and RETURNING clauses
+type statementResult struct {
+	Statement int    `json:"statement"`
+	Keyword   string `json:"keyword"`
+	// RowsAffected is left out for SELECT statements, which change nothing
+	RowsAffected *int64                   `json:"rows_affected,omitempty"`
+	LastInsertID *int64                   `json:"last_insert_id,omitempty"`
+	Columns      []string                 `json:"columns,omitempty"`
+	Rows         interface{}              `json:"rows,omitempty"`
+	Note         string                   `json:"note,omitempty"`
+	rows         []map[string]interface{} `json:"-"`
+}
+
+// runStatements runs statements in order in one transaction and reports each one's outcome
+// and the total rows affected. params are bound to every statement, so they are only given
+// for a single statement. With dryRun the transaction is rolled back after the last
+// statement, and statements that would end it early are refused; errors are reported the
+// same as for a real run.
+func (s *SQLiteServer) runStatements(ctx context.Context, statements []string, params []interface{}, dryRun bool) ([]statementResult, int64, error) {
+	db := s.db.WithContext(ctx)
+	if dryRun {
+		if err := checkDryRun(statements); err != nil {
+			return nil, 0, err
+		}
+	}
+	var results []statementResult
+	var totalAffected int64
+	failed := -1
+
+	err := db.RetryTransaction(func(tx *sql.Tx) error {
+		results, totalAffected, failed = nil, 0, -1
+		for i, stmt := range statements {
+			result := statementResult{Statement: i + 1, Keyword: statementKeyword(stmt)}
+			isInsert := result.Keyword == "INSERT" || result.Keyword == "REPLACE"
+			var affected, lastID int64
+			if isSelectQuery(stmt) || database.HasReturningClause(stmt) {
+				columns, rows, err := db.QueryTx(ctx, tx, stmt, params...)
+				if err != nil {
+					failed = i
+					return fmt.Errorf("statement %d (%s): %w", i+1, strings.Split(stmt, " ")[0], err)
+				}
+				result.Columns, result.rows = columns, rows
+				if !isSelectQuery(stmt) {
+					// A statement read through Query has no sql.Result
+					if err := tx.QueryRowContext(ctx, "SELECT changes(), last_insert_rowid()").Scan(&affected, &lastID); err != nil {
+						return err
+					}
+					result.RowsAffected = &affected
+				}
+			} else {
+				res, err := tx.ExecContext(ctx, stmt, params...)
+				if err != nil {
+					failed = i
+					return fmt.Errorf("statement %d (%s): %w", i+1, strings.Split(stmt, " ")[0], err)
+				}
+				affected, _ = res.RowsAffected()
+				result.RowsAffected = &affected
+				if isInsert {
+					lastID, _ = res.LastInsertId()
+				}
+			}
+			if isInsert && result.RowsAffected != nil {
+				result.LastInsertID = &lastID
+			}
+			totalAffected += affected
+			results = append(results, result)
+		}
+		if dryRun {
+			return errDryRun
+		}
+		return nil
+	})
+
+	if err != nil && !errors.Is(err, errDryRun) {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, 0, err
+		}
+		if failed >= 0 {
+			// Replayed only now that the failed transaction no longer holds the write lock
+			err = db.ExplainForeignKeyError(err, statements[:failed+1], params...)
+		}
+		if dryRun {
+			return nil, 0, fmt.Errorf("dry run failed, no changes were committed: %w", err)
+		}
+		return nil, 0, fmt.Errorf("transaction failed: %w", err)
+	}
+
+	for i := range results {
+		result := &results[i]
+		if result.Columns == nil {
+			continue
+		}
+		rows, note := limitResults(result.rows, s.maxRows, s.maxCells)
+		// A SELECT can be traced to its source columns; running a RETURNING clause again would repeat its write
+		if stmt := statements[i]; isSelectQuery(stmt) {
+			redactColumns(rows, s.redactedColumns(stmt, params, rows))
+		} else {
+			redactColumns(rows, s.nameRedactedColumns(result.Columns))
+		}
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		result.Rows, result.Note = rows, note
+	}
+	return results, totalAffected, nil
+}
+
+// statementKeyword returns the leading keyword of a statement in upper case
+func statementKeyword(statement string) string {
+	fields := strings.Fields(statement)
+	if len(fields) == 0 {
+		return ""
+	}
+	keyword, _, _ := strings.Cut(strings.ToUpper(fields[0]), "(")
+	return strings.TrimSuffix(keyword, ";")
+}
+
+// handleQueryIntoTable handles query into table requests
+func (s *SQLiteServer) handleQueryIntoTable(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+
+	destTable, ok := args["destination_table"].(string)
+	if !ok || destTable == "" {
+		return nil, fmt.Errorf("destination_table parameter is required and cannot be empty")
+	}
+
+	// Validate that it's a SELECT query
+	trimmedQuery := strings.TrimSpace(strings.ToUpper(query))
+	if !strings.HasPrefix(trimmedQuery, "SELECT") {
+		return nil, fmt.Errorf("only SELECT queries can be written into a table")
+	}
+
+	mode := "create"
+	if modeVal, ok := args["mode"].(string); ok && modeVal != "" {
+		mode = strings.ToLower(modeVal)
+	}
+	if mode != "create" && mode != "append" {
+		return nil, fmt.Errorf("mode must be 'create' or 'append'")
+	}
+
+	written, err := db.QueryIntoTable(query, destTable, mode == "append")
+	if err != nil {
+		return nil, fmt.Errorf("failed to write query results: %w", err)
+	}
+
+	var message string
+	if mode == "append" {
+		message = fmt.Sprintf("Appended %d row(s) to table '%s'", written, destTable)
+	} else {
+		message = fmt.Sprintf("Created table '%s' with %d row(s)", destTable, written)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleRenameTable handles rename table requests
+func (s *SQLiteServer) handleRenameTable(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	// table_name is the earlier name of old_name. Only table_name is checked by
+	// restrictTables, so the table is checked here whichever argument names it.
+	tableName, _ := args["old_name"].(string)
+	if tableName == "" {
+		tableName, _ = args["table_name"].(string)
+	}
+	if tableName == "" {
+		return nil, fmt.Errorf("old_name parameter is required and cannot be empty")
+	}
+	if err := s.checkTableAccess(tableName); err != nil {
+		return nil, err
+	}
+
+	newName, ok := args["new_name"].(string)
+	if !ok || newName == "" {
+		return nil, fmt.Errorf("new_name parameter is required and cannot be empty")
+	}
+	if err := s.checkTableAccess(newName); err != nil {
+		return nil, err
+	}
+
+	checkOnly, _ := args["check_only"].(bool)
+	fixDependents, _ := args["fix_dependents"].(bool)
+	legacyAlterTable, _ := args["legacy_alter_table"].(bool)
+
+	if checkOnly {
+		dependents, err := db.FindTableDependents(tableName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dependent objects: %w", err)
+		}
+
+		var message string
+		if len(dependents) == 0 {
+			message = fmt.Sprintf("No views, triggers, or foreign keys reference %s", tableName)
+		} else {
+			message = fmt.Sprintf("Found %d object(s) referencing %s:\n", len(dependents), tableName)
+			for _, dep := range dependents {
+				message += fmt.Sprintf("- %s %s\n", dep.Type, dep.Name)
+			}
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: message,
+				},
+			},
+		}, nil
+	}
+
+	result, err := db.RenameTable(tableName, newName, legacyAlterTable, fixDependents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename table: %w", err)
+	}
+
+	message := fmt.Sprintf("Table '%s' renamed to '%s'", tableName, newName)
+	if len(result.Dependents) > 0 {
+		message += fmt.Sprintf("\nChecked %d dependent object(s)", len(result.Dependents))
+	}
+	for _, dep := range result.Fixed {
+		message += fmt.Sprintf("\nRewrote %s '%s' to use the new table name", dep.Type, dep.Name)
+	}
+	for _, dep := range result.Broken {
+		if dep.Type == "table" {
+			message += fmt.Sprintf("\nWARNING: foreign keys of table '%s' still reference '%s'; rebuild the table to point them at '%s'", dep.Name, tableName, newName)
+			continue
+		}
+		message += fmt.Sprintf("\nWARNING: %s '%s' still references '%s' and may be broken", dep.Type, dep.Name, tableName)
+	}
+	if len(result.Broken) > 0 && !fixDependents {
+		message += "\nRe-run with fix_dependents=true or recreate these objects manually"
+	}
+	message += s.updatedSchema(newName)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleAddColumn handles add column requests
+func (s *SQLiteServer) handleAddColumn(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+	columnName, ok := args["column_name"].(string)
+	if !ok || columnName == "" {
+		return nil, fmt.Errorf("column_name parameter is required and cannot be empty")
+	}
+	columnType, ok := args["type"].(string)
+	if !ok || columnType == "" {
+		return nil, fmt.Errorf("type parameter is required and cannot be empty")
+	}
+	constraints, _ := args["constraints"].(string)
+
+	if err := db.AddColumn(tableName, columnName, columnType, constraints); err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to add column: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Column '%s' added to table '%s'%s", columnName, tableName, s.updatedSchema(tableName)),
+			},
+		},
+	}, nil
+}
+
+// handleDropColumn handles drop column requests
+func (s *SQLiteServer) handleDropColumn(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+	columnName, ok := args["column_name"].(string)
+	if !ok || columnName == "" {
+		return nil, fmt.Errorf("column_name parameter is required and cannot be empty")
+	}
+
+	rebuilt, err := db.DropColumn(tableName, columnName)
+	if err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to drop column: %w", err)
+	}
+
+	message := fmt.Sprintf("Column '%s' dropped from table '%s'", columnName, tableName)
+	if rebuilt {
+		message += " by rebuilding the table"
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message + s.updatedSchema(tableName),
+			},
+		},
+	}, nil
+}
+
+// updatedSchema formats the columns of a table after a schema change, for appending to a
+// tool's response; it is empty if the schema cannot be read
+func (s *SQLiteServer) updatedSchema(tableName string) string {
+	schema, err := s.db.GetTableSchema(tableName)
+	if err != nil || len(schema) == 0 {
+		return ""
+	}
+	jsonSchema, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("\nUpdated schema:\n%s", string(jsonSchema))
+}
+
+// handleChangeColumnType handles change column type requests
+func (s *SQLiteServer) handleChangeColumnType(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+	column, ok := args["column"].(string)
+	if !ok || column == "" {
+		return nil, fmt.Errorf("column parameter is required and cannot be empty")
+	}
+	newType, ok := args["new_type"].(string)
+	if !ok || newType == "" {
+		return nil, fmt.Errorf("new_type parameter is required and cannot be empty")
+	}
+
+	result, err := db.ChangeColumnType(tableName, column, newType)
+	if err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to change column type: %w", err)
+	}
+
+	jsonResult, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format result: %w", err)
+	}
+
+	message := fmt.Sprintf("Changed column '%s' of table '%s' from %s to %s, copying %d row(s)",
+		result.Column, result.Table, describeType(result.OldType), result.NewType, result.RowsCopied)
+	if result.ValuesChanged > 0 {
+		message += fmt.Sprintf("; %d value(s) were converted by the CAST", result.ValuesChanged)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", message, string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleAddColumnConstraint handles add column constraint requests
+func (s *SQLiteServer) handleAddColumnConstraint(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+	column, ok := args["column"].(string)
+	if !ok || column == "" {
+		return nil, fmt.Errorf("column parameter is required and cannot be empty")
+	}
+	notNull, _ := args["not_null"].(bool)
+	check, _ := args["check"].(string)
+	if check != "" {
+		// The expression is evaluated against the table's rows, so it is subject to the table policy
+		if err := s.checkStatementAccess(fmt.Sprintf("SELECT 1 WHERE %s", check), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := db.AddColumnConstraint(tableName, column, notNull, check)
+	if err != nil {
+		var violation *database.ConstraintViolationError
+		if errors.As(err, &violation) {
+			// The rows come straight from the table, so their keys are its column names
+			redactColumns(violation.Rows, s.redactedColumns("", nil, violation.Rows))
+			rows, jsonErr := json.MarshalIndent(violation.Rows, "", "  ")
+			if jsonErr != nil {
+				return nil, fmt.Errorf("failed to format violating rows: %w", jsonErr)
+			}
+			return nil, fmt.Errorf("failed to add constraint: %w; first %d violating row(s):\n%s", err, len(violation.Rows), string(rows))
+		}
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to add constraint: %w", err)
+	}
+
+	jsonResult, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Added %s to column '%s' of table '%s', copying %d row(s):\n%s",
+					result.Constraint, result.Column, result.Table, result.RowsCopied, string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// describeType names a declared column type for messages, which may be empty
+func describeType(declaredType string) string {
+	if declaredType == "" {
+		return "no declared type"
+	}
+	return declaredType
+}
+
+// handleInferTableFromQuery handles infer table from query requests
+func (s *SQLiteServer) handleInferTableFromQuery(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+
+	trimmedQuery := strings.TrimSpace(strings.ToUpper(query))
+	if !strings.HasPrefix(trimmedQuery, "SELECT") && !strings.HasPrefix(trimmedQuery, "WITH") {
+		return nil, fmt.Errorf("only SELECT queries can be used to infer a table")
+	}
+
+	ddl, columns, err := db.InferTableFromQuery(query, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to infer table: %w", err)
+	}
+
+	var inferred []string
+	for _, col := range columns {
+		switch col.Source {
+		case "values":
+			inferred = append(inferred, fmt.Sprintf("%s (%s from values)", col.Name, col.Type))
+		case "none":
+			inferred = append(inferred, fmt.Sprintf("%s (no type, all values NULL)", col.Name))
+		}
+	}
+
+	message := fmt.Sprintf("Generated table definition with %d column(s):\n%s;", len(columns), ddl)
+	if execute, _ := args["execute"].(bool); execute {
+		if _, err := db.ExecuteStatement(ddl); err != nil {
+			return nil, fmt.Errorf("failed to create table: %w", err)
+		}
+		message = fmt.Sprintf("Created table '%s' with %d column(s):\n%s;", tableName, len(columns), ddl)
+	}
+	if len(inferred) > 0 {
+		message += "\nTyped from the result values: " + strings.Join(inferred, ", ")
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleExportParquet handles export parquet requests
+func (s *SQLiteServer) handleExportParquet(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+
+	trimmedQuery := strings.TrimSpace(strings.ToUpper(query))
+	if !strings.HasPrefix(trimmedQuery, "SELECT") && !strings.HasPrefix(trimmedQuery, "WITH") {
+		return nil, fmt.Errorf("only SELECT queries can be exported")
+	}
+
+	params, err := parseParams(args["params"])
+	if err != nil {
+		return nil, err
+	}
+
+	directory, _ := args["directory"].(string)
+	directory, err = s.dataFileDirectory(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	filename, _ := args["file_name"].(string)
+	if filename == "" {
+		filename = fmt.Sprintf("export_%d.parquet", time.Now().Unix())
+	}
+	if strings.ContainsAny(filename, "/\\") || filename == "." || filename == ".." {
+		return nil, fmt.Errorf("file_name must be a file name without directories")
+	}
+	if filepath.Ext(filename) == "" {
+		filename += ".parquet"
+	}
+	path := filepath.Join(directory, filename)
+
+	if overwrite, _ := args["overwrite"].(bool); overwrite {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to replace '%s': %w", path, err)
+		}
+	} else if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("file '%s' already exists; set overwrite to replace it", path)
+	}
+
+	// Redacted columns are matched by name and by source column, as in query results
+	masked := make(map[string]string)
+	if len(s.redactPatterns) > 0 {
+		origins, err := db.QueryColumnOrigins(query, params...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve columns for redaction: %w", err)
+		}
+		for _, origin := range origins {
+			if matchesRedaction(origin.Name, s.redactPatterns) || s.sensitiveOrigin(origin) {
+				masked[origin.Name] = redactedValue
+			}
+		}
+	}
+
+	export, err := db.ExportParquet(path, masked, query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export query results: %w", err)
+	}
+
+	columnsJSON, _ := json.MarshalIndent(export.Columns, "", "  ")
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Exported %d row(s) to %s\nColumns:\n%s", export.Rows, export.Path, string(columnsJSON)),
+			},
+		},
+	}, nil
+}
+
+// handleImportParquet handles import parquet requests
+func (s *SQLiteServer) handleImportParquet(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+
+	filename, ok := args["file_name"].(string)
+	if !ok || filename == "" {
+		return nil, fmt.Errorf("file_name parameter is required and cannot be empty")
+	}
+	if strings.ContainsAny(filename, "/\\") || filename == "." || filename == ".." {
+		return nil, fmt.Errorf("file_name must be a file name without directories")
+	}
+
+	directory, _ := args["directory"].(string)
+	directory, err := s.dataFileDirectory(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	batchSize := database.DefaultImportBatchSize
+	if size, ok := args["batch_size"].(float64); ok {
+		if size < 1 {
+			return nil, fmt.Errorf("batch_size must be at least 1")
+		}
+		batchSize = int(size)
+	}
+
+	path := filepath.Join(directory, filename)
+	result, err := db.ImportParquet(path, tableName, batchSize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import '%s': %w", path, err)
+	}
+
+	action := "Imported"
+	if result.Created {
+		action = "Created table '" + tableName + "' and imported"
+	}
+	columnsJSON, _ := json.MarshalIndent(result.Columns, "", "  ")
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s %d row(s) from %s into '%s'\nColumns:\n%s", action, result.Rows, path, tableName, string(columnsJSON)),
+			},
+		},
+	}, nil
+}
+
+// handleImportCSV handles import csv requests
+func (s *SQLiteServer) handleImportCSV(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+
+	csvPath, ok := args["csv_path"].(string)
+	if !ok || csvPath == "" {
+		return nil, fmt.Errorf("csv_path parameter is required and cannot be empty")
+	}
+	csvPath, err := s.resolveDataPath(csvPath)
+	if err != nil {
+		return nil, err
+	}
+
+	options := database.CSVImportOptions{HasHeader: true}
+	if hasHeader, ok := args["has_header"].(bool); ok {
+		options.HasHeader = hasHeader
+	}
+	options.CreateTable, _ = args["create_table"].(bool)
+	if delimiter, ok := args["delimiter"].(string); ok && delimiter != "" {
+		if delimiter == "tab" || delimiter == "\\t" {
+			delimiter = "\t"
+		}
+		runes := []rune(delimiter)
+		if len(runes) != 1 || strings.ContainsRune("\"\r\n", runes[0]) {
+			return nil, fmt.Errorf("delimiter must be a single character other than a quote or newline")
+		}
+		options.Delimiter = runes[0]
+	}
+	if rows, ok := args["infer_rows"].(float64); ok {
+		if rows < 1 {
+			return nil, fmt.Errorf("infer_rows must be at least 1")
+		}
+		options.InferRows = int(rows)
+	}
+
+	result, err := db.ImportCSV(csvPath, tableName, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import '%s': %w", csvPath, err)
+	}
+
+	action := "Imported"
+	if result.Created {
+		action = "Created table '" + tableName + "' and imported"
+	}
+	columnsJSON, _ := json.MarshalIndent(result.Columns, "", "  ")
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s %d row(s) from %s into '%s'\nColumns:\n%s", action, result.Rows, csvPath, tableName, string(columnsJSON)),
+			},
+		},
+	}, nil
+}
+
+// handleExportJSON handles export json requests
+func (s *SQLiteServer) handleExportJSON(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	directory, _ := args["directory"].(string)
+	directory, err := s.dataFileDirectory(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	filename, _ := args["file_name"].(string)
+	if filename == "" {
+		filename = fmt.Sprintf("export_%d.json", time.Now().Unix())
+	}
+	if strings.ContainsAny(filename, "/\\") || filename == "." || filename == ".." {
+		return nil, fmt.Errorf("file_name must be a file name without directories")
+	}
+	if filepath.Ext(filename) == "" {
+		filename += ".json"
+	}
+	path := filepath.Join(directory, filename)
+
+	if overwrite, _ := args["overwrite"].(bool); overwrite {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to replace '%s': %w", path, err)
+		}
+	} else if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("file '%s' already exists; set overwrite to replace it", path)
+	}
+
+	// Tables hidden by the access policy are left out, and redacted columns are masked
+	redact := func(column string) bool { return matchesRedaction(column, s.redactPatterns) }
+	export, err := db.ExportJSON(path, s.tableAllowed, redact)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export the database: %w", err)
+	}
+
+	var rows int64
+	for _, table := range export.Tables {
+		rows += table.Rows
+	}
+	jsonData, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Exported %d row(s) from %d table(s) to %s\n%s", rows, len(export.Tables), export.Path, string(jsonData)),
+			},
+		},
+	}, nil
+}
+
+// handleImportJSON handles import json requests
+func (s *SQLiteServer) handleImportJSON(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	filename, ok := args["file_name"].(string)
+	if !ok || filename == "" {
+		return nil, fmt.Errorf("file_name parameter is required and cannot be empty")
+	}
+	if strings.ContainsAny(filename, "/\\") || filename == "." || filename == ".." {
+		return nil, fmt.Errorf("file_name must be a file name without directories")
+	}
+
+	directory, _ := args["directory"].(string)
+	directory, err := s.dataFileDirectory(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	destination, ok := args["destination"].(string)
+	if !ok || destination == "" {
+		return nil, fmt.Errorf("destination parameter is required and cannot be empty")
+	}
+
+	destination, err = s.resolveDataPath(destination)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(destination); err == nil {
+		return nil, fmt.Errorf("file '%s' already exists; import_json always creates a new database", destination)
+	}
+
+	path := filepath.Join(directory, filename)
+	result, err := database.ImportJSON(path, destination)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import '%s': %w", path, err)
+	}
+
+	var rows int64
+	for _, table := range result.Tables {
+		rows += table.Rows
+	}
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Created %s with %d row(s) in %d table(s) from %s; use switch_database to open it\n%s",
+					destination, rows, len(result.Tables), path, string(jsonData)),
+			},
+		},
+	}, nil
+}
+
+// handleEnableAudit handles enable audit requests
+func (s *SQLiteServer) handleEnableAudit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	var message string
+	if disable, _ := args["disable"].(bool); disable {
+		if err := db.DisableAudit(tableName); err != nil {
+			return nil, fmt.Errorf("failed to disable audit: %w", err)
+		}
+		message = fmt.Sprintf("Auditing disabled for table '%s'. History in '%s' was kept",
+			tableName, database.AuditTableName(tableName))
+	} else {
+		if err := db.EnableAudit(tableName); err != nil {
+			return nil, fmt.Errorf("failed to enable audit: %w", err)
+		}
+		message = fmt.Sprintf("Auditing enabled for table '%s'. Changes are recorded in '%s'",
+			tableName, database.AuditTableName(tableName))
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleQueryAudit handles audit log query requests
+func (s *SQLiteServer) handleQueryAudit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	filter := database.AuditFilter{Limit: 100}
+	if since, ok := args["since"].(string); ok {
+		filter.Since = since
+	}
+	if until, ok := args["until"].(string); ok {
+		filter.Until = until
+	}
+	if operation, ok := args["operation"].(string); ok {
+		filter.Operation = operation
+	}
+	if limit, ok := args["limit"].(float64); ok {
+		if limit < 1 || limit > 1000 {
+			return nil, fmt.Errorf("limit must be between 1 and 1000")
+		}
+		filter.Limit = int(limit)
+	}
+
+	entries, err := db.QueryAudit(tableName, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query audit log: %w", err)
+	}
+	// Recorded rows are JSON objects keyed by column name
+	if len(s.redactPatterns) > 0 {
+		for _, entry := range entries {
+			for _, key := range []string{"old_values", "new_values"} {
+				if values, ok := entry[key].(string); ok {
+					entry[key] = redactJSONObject(values, s.redactPatterns)
+				}
+			}
+		}
+	}
+
+	jsonEntries, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format audit entries: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Found %d audit entries for table '%s':\n%s", len(entries), tableName, string(jsonEntries)),
+			},
+		},
+	}, nil
+}
+
+// handleSetTriggersEnabled handles trigger enable/disable requests
+func (s *SQLiteServer) handleSetTriggersEnabled(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	enabled, ok := args["enabled"].(bool)
+	if !ok {
+		return nil, fmt.Errorf("enabled parameter is required")
+	}
+
+	names, err := db.SetTriggersEnabled(tableName, enabled)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update triggers: %w", err)
+	}
+
+	action := "Disabled"
+	if enabled {
+		action = "Re-enabled"
+	}
+
+	var message string
+	if len(names) == 0 {
+		if enabled {
+			message = fmt.Sprintf("No disabled triggers found for table '%s'", tableName)
+		} else {
+			message = fmt.Sprintf("Table '%s' has no active triggers", tableName)
+		}
+	} else {
+		message = fmt.Sprintf("%s %d trigger(s) on table '%s':\n", action, len(names), tableName)
+		for _, name := range names {
+			message += fmt.Sprintf("- %s\n", name)
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleBackfillColumn handles requests to fill NULL column values
+func (s *SQLiteServer) handleBackfillColumn(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	column, ok := args["column"].(string)
+	if !ok {
+		return nil, fmt.Errorf("column parameter is required")
+	}
+
+	value, ok := args["value"]
+	if !ok || value == nil {
+		return nil, fmt.Errorf("value parameter is required")
+	}
+
+	batchSize := database.DefaultBackfillBatchSize
+	if size, ok := args["batch_size"].(float64); ok {
+		if size < 1 {
+			return nil, fmt.Errorf("batch_size must be at least 1")
+		}
+		batchSize = int(size)
+	}
+
+	dryRun, _ := args["dry_run"].(bool)
+
+	count, err := db.BackfillColumn(tableName, column, value, batchSize, dryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	var message string
+	if dryRun {
+		message = fmt.Sprintf("Dry run: %d row(s) in '%s' have NULL in column '%s' and would be updated", count, tableName, column)
+	} else {
+		message = fmt.Sprintf("Backfilled %d row(s) in '%s': NULL values in column '%s' set to %v", count, tableName, column, value)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleDropTable handles drop table requests
+func (s *SQLiteServer) handleDropTableTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	if err := db.DropTable(tableName); err != nil {
+		return nil, fmt.Errorf("failed to drop table: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Table '%s' dropped successfully", tableName),
+			},
+		},
+	}, nil
+}
+
+// visibleViews lists the views the table access policy permits
+func (s *SQLiteServer) visibleViews() ([]database.View, error) {
+	views, err := s.db.GetViews()
+	if err != nil {
+		return nil, err
+	}
+	visible := views[:0]
+	for _, view := range views {
+		if s.tableAllowed(view.Name) {
+			visible = append(visible, view)
+		}
+	}
+	return visible, nil
+}
+
+// handleCreateView handles create view requests
+func (s *SQLiteServer) handleCreateView(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	viewName, ok := args["view_name"].(string)
+	if !ok || viewName == "" {
+		return nil, fmt.Errorf("view_name parameter is required and cannot be empty")
+	}
+	selectQuery, ok := args["select_query"].(string)
+	if !ok || selectQuery == "" {
+		return nil, fmt.Errorf("select_query parameter is required and cannot be empty")
+	}
+	if !isSelectQuery(selectQuery) {
+		return nil, fmt.Errorf("select_query must be a SELECT query")
+	}
+
+	if err := db.CreateView(viewName, selectQuery); err != nil {
+		return nil, fmt.Errorf("failed to create view: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("View '%s' created successfully", viewName),
+			},
+		},
+	}, nil
+}
+
+// handleListViews handles list views requests
+func (s *SQLiteServer) handleListViews(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	views, err := s.visibleViews()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list views: %w", err)
+	}
+
+	if len(views) == 0 {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: "No views found in the database",
+				},
+			},
+		}, nil
+	}
+
+	jsonData, err := json.MarshalIndent(views, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal views: %w", err)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Found %d view(s):\n%s", len(views), string(jsonData)),
+			},
+		},
+	}, nil
+}
+
+// handleDropView handles drop view requests
+func (s *SQLiteServer) handleDropView(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	viewName, ok := args["view_name"].(string)
+	if !ok || viewName == "" {
+		return nil, fmt.Errorf("view_name parameter is required and cannot be empty")
+	}
+
+	if err := db.DropView(viewName); err != nil {
+		return nil, fmt.Errorf("failed to drop view: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("View '%s' dropped successfully", viewName),
+			},
+		},
+	}, nil
+}
+
+// handleCreateFTSTable handles create FTS table requests
+func (s *SQLiteServer) handleCreateFTSTable(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+	list, ok := args["columns"].([]interface{})
+	if !ok || len(list) == 0 {
+		return nil, fmt.Errorf("columns parameter is required and cannot be empty")
+	}
+	columns := make([]string, 0, len(list))
+	for _, item := range list {
+		column, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("column names must be strings")
+		}
+		columns = append(columns, column)
+	}
+	tokenize, _ := args["tokenize"].(string)
+
+	if err := db.CreateFTSTable(tableName, columns, tokenize); err != nil {
+		return nil, fmt.Errorf("failed to create FTS table: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("FTS5 table '%s' created successfully with columns: %s", tableName, strings.Join(columns, ", ")),
+			},
+		},
+	}, nil
+}
+
+// handleSearchFTS handles full-text search requests
+func (s *SQLiteServer) handleSearchFTS(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+	match, ok := args["query"].(string)
+	if !ok || match == "" {
+		return nil, fmt.Errorf("query parameter is required and cannot be empty")
+	}
+	limit := database.DefaultFTSSearchLimit
+	if limitVal, ok := args["limit"].(float64); ok {
+		if limitVal < 1 {
+			return nil, fmt.Errorf("limit must be at least 1")
+		}
+		limit = int(limitVal)
+	}
+
+	results, err := db.SearchFTS(tableName, match, limit)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+	results, truncationNote := limitResults(results, s.maxRows, s.maxCells)
+	redactColumns(results, s.redactedColumns(database.FTSSearchQuery(tableName), []interface{}{match, limit}, results))
+
+	jsonResult, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format results: %w", err)
+	}
+
+	summary := fmt.Sprintf("Found %d match(es), best first (lower rank is better)", len(results))
+	if truncationNote != "" {
+		summary += "; " + truncationNote
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", summary, string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleCreateIndex handles create index requests
+func (s *SQLiteServer) handleCreateIndexTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	indexName, ok := args["index_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("index_name parameter is required")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	// Parse columns
+	columnsRaw, ok := args["columns"]
+	if !ok {
+		return nil, fmt.Errorf("columns parameter is required")
+	}
+
+	columnsArray, ok := columnsRaw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("columns must be an array")
+	}
+
+	if len(columnsArray) == 0 {
+		return nil, fmt.Errorf("at least one column must be specified")
+	}
+
+	var columns []string
+	var indexColumns []database.IndexColumn
+
+	for _, colRaw := range columnsArray {
+		colMap, ok := colRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("each column must be an object")
+		}
+
+		colName, ok := colMap["name"].(string)
+		if !ok {
+			return nil, fmt.Errorf("column name is required")
+		}
+
+		columns = append(columns, colName)
+
+		indexCol := database.IndexColumn{Name: colName}
+		if sortOrder, ok := colMap["sort_order"].(string); ok {
+			indexCol.SortOrder = sortOrder
+		}
+		indexColumns = append(indexColumns, indexCol)
+	}
+
+	// Parse optional parameters
+	unique := false
+	if uniqueVal, ok := args["unique"].(bool); ok {
+		unique = uniqueVal
+	}
+
+	ifNotExists := false
+	if ifNotExistsVal, ok := args["if_not_exists"].(bool); ok {
+		ifNotExists = ifNotExistsVal
+	}
+
+	whereClause := ""
+	if whereVal, ok := args["where_clause"].(string); ok {
+		whereClause = whereVal
+	}
+
+	// Use advanced options if any advanced features are requested
+	if len(indexColumns) > 1 || whereClause != "" || (len(indexColumns) == 1 && indexColumns[0].SortOrder != "") {
+		options := database.IndexOptions{
+			IndexName:   indexName,
+			TableName:   tableName,
+			Columns:     indexColumns,
+			Unique:      unique,
+			IfNotExists: ifNotExists,
+			WhereClause: whereClause,
+		}
+
+		if err := db.CreateIndexWithOptions(options); err != nil {
+			return nil, fmt.Errorf("failed to create index: %w", err)
+		}
+	} else {
+		// Use simple method for single column, no sort order, no where clause
+		if err := db.CreateIndex(indexName, tableName, columns, unique, ifNotExists); err != nil {
+			return nil, fmt.Errorf("failed to create index: %w", err)
+		}
+	}
+
+	// Build response message
+	indexType := "non-unique"
+	if unique {
+		indexType = "unique"
+	}
+
+	existsText := ""
+	if ifNotExists {
+		existsText = " (if not exists)"
+	}
+
+	response := fmt.Sprintf("%s index '%s'%s created successfully on %s.%s",
+		indexType, indexName, existsText, tableName, strings.Join(columns, ", "))
+
+	if whereClause != "" {
+		response += fmt.Sprintf(" WHERE %s", whereClause)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: response,
+			},
+		},
+	}, nil
+}
+
+// handleListIndexes handles list indexes requests
+func (s *SQLiteServer) handleListIndexesTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	indexes, err := db.GetIndexes(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list indexes: %w", err)
+	}
+
+	var message string
+	if len(indexes) == 0 {
+		message = fmt.Sprintf("No indexes found for table '%s'", tableName)
+	} else {
+		message = fmt.Sprintf("Found %d index(es) for table '%s':\n", len(indexes), tableName)
+		for _, index := range indexes {
+			if name, ok := index["name"].(string); ok {
+				message += fmt.Sprintf("- %s\n", name)
+			}
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleDropIndexTool handles drop index requests
+func (s *SQLiteServer) handleDropIndexTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	indexName, ok := args["index_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("index_name parameter is required")
+	}
+
+	err := db.DropIndex(indexName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to drop index '%s': %w", indexName, err)
+	}
+
+	message := fmt.Sprintf("Successfully dropped index '%s'", indexName)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleVacuum handles vacuum requests
+func (s *SQLiteServer) handleVacuum(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	if err := db.Vacuum(); err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to vacuum database: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: "Database vacuum completed successfully",
+			},
+		},
+	}, nil
+}
+
+// handleAnalyze handles analyze requests
+func (s *SQLiteServer) handleAnalyze(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+	name, _ := args["name"].(string)
+
+	start := time.Now()
+	if err := db.Analyze(name); err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to analyze: %w", err)
+	}
+
+	target := "the database"
+	if name != "" {
+		target = fmt.Sprintf("'%s'", name)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Analyzed %s in %s; the query planner now uses the refreshed statistics", target, time.Since(start).Round(time.Millisecond)),
+			},
+		},
+	}, nil
+}
+
+// handleReindex handles reindex requests
+func (s *SQLiteServer) handleReindex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+	name, _ := args["name"].(string)
+
+	start := time.Now()
+	if err := db.Reindex(name); err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to reindex: %w", err)
+	}
+
+	target := "every index"
+	if name != "" {
+		target = fmt.Sprintf("the indexes for '%s'", name)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Rebuilt %s in %s", target, time.Since(start).Round(time.Millisecond)),
+			},
+		},
+	}, nil
+}
+
+// handleOptimize handles optimize requests
+func (s *SQLiteServer) handleOptimize(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	start := time.Now()
+	statements, err := db.Optimize()
+	if err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to optimize: %w", err)
+	}
+
+	text := fmt.Sprintf("Statistics are up to date, nothing to do (%s)", time.Since(start).Round(time.Millisecond))
+	if len(statements) > 0 {
+		text = fmt.Sprintf("Ran %d statement(s) in %s:\n%s", len(statements), time.Since(start).Round(time.Millisecond), strings.Join(statements, "\n"))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: text,
+			},
+		},
+	}, nil
+}
+
+// handleIncrementalVacuum handles incremental vacuum requests
+func (s *SQLiteServer) handleIncrementalVacuum(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+	var pages int64
+	if value, ok := args["pages"].(float64); ok {
+		if value < 1 || value != math.Trunc(value) {
+			return nil, fmt.Errorf("pages must be a positive integer")
+		}
+		pages = int64(value)
+	}
+	convert, _ := args["convert"].(bool)
+
+	start := time.Now()
+	result, err := db.IncrementalVacuum(pages, convert)
+	if err != nil {
+		if errors.Is(err, database.ErrDiskFull) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to run incremental vacuum: %w", err)
+	}
+
+	report := struct {
+		*database.IncrementalVacuumResult
+		Duration string `json:"duration"`
+	}{result, time.Since(start).Round(time.Millisecond).String()}
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(jsonData),
+			},
+		},
+	}, nil
+}
+
+// handleBackupDatabase handles backup database requests
+func (s *SQLiteServer) handleBackupDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	destination, ok := args["destination"].(string)
+	if !ok || destination == "" {
+		return nil, fmt.Errorf("destination parameter is required and cannot be empty")
+	}
+
+	destination, err := s.resolveDataPath(destination)
+	if err != nil {
+		return nil, err
+	}
+	if samePath(destination, db.GetCurrentDatabasePath()) {
+		return nil, fmt.Errorf("destination is the current database file")
+	}
+
+	if _, err := os.Stat(destination); err == nil {
+		if overwrite, _ := args["overwrite"].(bool); !overwrite {
+			return nil, fmt.Errorf("file '%s' already exists; set overwrite to replace it", destination)
+		}
+		// VACUUM INTO refuses to write over an existing file
+		if err := os.Remove(destination); err != nil {
+			return nil, fmt.Errorf("failed to replace '%s': %w", destination, err)
+		}
+	}
+
+	size, err := db.BackupTo(destination)
+	if err != nil {
+		return nil, fmt.Errorf("backup failed: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Backed up %s to %s (%d bytes)", db.GetCurrentDatabasePath(), destination, size),
+			},
+		},
+	}, nil
+}
+
+// handleVerifyBackup handles verify backup requests
+func (s *SQLiteServer) handleVerifyBackup(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	backup, ok := args["backup_path"].(string)
+	if !ok || backup == "" {
+		return nil, fmt.Errorf("backup_path parameter is required and cannot be empty")
+	}
+
+	backup, err := s.resolveDataPath(backup)
+	if err != nil {
+		return nil, err
+	}
+	if samePath(backup, db.GetCurrentDatabasePath()) {
+		return nil, fmt.Errorf("backup_path is the current database file")
+	}
+
+	result, err := db.VerifyBackup(backup)
+	if err != nil {
+		return nil, fmt.Errorf("verification failed: %w", err)
+	}
+
+	var summary string
+	if result.Match {
+		summary = fmt.Sprintf("The backup %s is a faithful copy of %s: all %d table(s) match", backup, result.Source, len(result.Tables))
+	} else {
+		summary = fmt.Sprintf("The backup %s does not match %s: %d mismatched table(s), %d other schema difference(s)",
+			backup, result.Source, len(result.Mismatched), len(result.SchemaDifferences))
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s\n%s", summary, string(jsonData)),
+			},
+		},
+	}, nil
+}
+
+// handleListForeignKeys handles list foreign keys requests
+func (s *SQLiteServer) handleListForeignKeys(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+	tableName, _ := args["table_name"].(string)
+
+	tables, err := db.ListForeignKeys(tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list foreign keys: %w", err)
+	}
+
+	// Tables denied by the access policy, and foreign keys referencing them, are not listed
+	visible := tables[:0]
+	count := 0
+	for _, table := range tables {
+		if !s.tableAllowed(table.Table) {
+			continue
+		}
+		keys := table.ForeignKeys[:0]
+		for _, fk := range table.ForeignKeys {
+			if s.tableAllowed(fk.ReferencedTable) {
+				keys = append(keys, fk)
+			}
+		}
+		table.ForeignKeys = keys
+		count += len(keys)
+		visible = append(visible, table)
+	}
+
+	jsonTables, err := json.MarshalIndent(visible, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format foreign keys: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Found %d foreign key(s):\n%s", count, string(jsonTables)),
+			},
+		},
+	}, nil
+}
+
+// handleCheckForeignKeys handles foreign key check requests
+func (s *SQLiteServer) handleCheckForeignKeys(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+	tableName, _ := args["table_name"].(string)
+	maxViolations := 0
+	if max, ok := args["max_violations"].(float64); ok {
+		maxViolations = int(max)
+	}
+
+	check, err := db.CheckForeignKeys(tableName, maxViolations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check foreign keys: %w", err)
+	}
+
+	// Violations in or against tables denied by the access policy are not reported
+	visible := check.Violations[:0]
+	for _, violation := range check.Violations {
+		if s.tableAllowed(violation.Table) && s.tableAllowed(violation.ReferencedTable) {
+			visible = append(visible, violation)
+		} else {
+			check.Total--
+		}
+	}
+	check.Violations = visible
+
+	if check.Total == 0 {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: "No foreign key violations found",
+				},
+			},
+		}, nil
+	}
+
+	jsonCheck, err := json.MarshalIndent(check, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format foreign key violations: %w", err)
+	}
+
+	message := fmt.Sprintf("Found %d row(s) violating foreign keys:\n%s", check.Total, string(jsonCheck))
+	if check.Truncated {
+		message += fmt.Sprintf("\nOnly the first %d rows are listed; raise max_violations to see more", len(check.Violations))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleRecoverDatabase handles recover database requests
+func (s *SQLiteServer) handleRecoverDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	destination, ok := args["destination"].(string)
+	if !ok || destination == "" {
+		return nil, fmt.Errorf("destination parameter is required and cannot be empty")
+	}
+
+	destination, err := s.resolveDataPath(destination)
+	if err != nil {
+		return nil, err
+	}
+	if samePath(destination, db.GetCurrentDatabasePath()) {
+		return nil, fmt.Errorf("destination is the current database file")
+	}
+	if _, err := os.Stat(destination); err == nil {
+		return nil, fmt.Errorf("file '%s' already exists; recovery always writes a new file", destination)
+	}
+
+	result, err := db.RecoverTo(destination)
+	if err != nil {
+		return nil, fmt.Errorf("recovery failed: %w", err)
+	}
+
+	var rows int64
+	incomplete := 0
+	for _, table := range result.Tables {
+		rows += table.Rows
+		if !table.Complete {
+			incomplete++
+		}
+	}
+	healthy := len(result.SourceCheck) == 1 && result.SourceCheck[0] == "ok"
+	var summary string
+	switch {
+	case incomplete == 0 && len(result.Failed) == 0 && healthy:
+		summary = fmt.Sprintf("The source passed quick_check; copied %d rows from %d tables to %s", rows, len(result.Tables), destination)
+	case incomplete == 0 && len(result.Failed) == 0:
+		summary = fmt.Sprintf("Recovered all readable data: %d rows from %d tables to %s", rows, len(result.Tables), destination)
+	default:
+		summary = fmt.Sprintf("Recovered %d rows from %d tables to %s; %d table(s) lost rows and %d schema object(s) could not be recreated",
+			rows, len(result.Tables), destination, incomplete, len(result.Failed))
+	}
+
+	jsonData, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: summary + "\n" + string(jsonData),
+			},
+		},
+	}, nil
+}
+
+// handleSnapshotQuery handles snapshot query requests
+func (s *SQLiteServer) handleSnapshotQuery(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	name, ok := args["name"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("name parameter is required")
+	}
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+	if !strings.HasPrefix(strings.TrimSpace(strings.ToUpper(query)), "SELECT") {
+		return nil, fmt.Errorf("only SELECT queries can be snapshotted")
+	}
+	keyColumn, ok := args["key_column"].(string)
+	if !ok || keyColumn == "" {
+		return nil, fmt.Errorf("key_column parameter is required")
+	}
+	params, err := parseParams(args["params"])
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := db.SnapshotQuery(name, query, keyColumn, params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to snapshot query: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Stored snapshot '%s' of %d rows keyed by %s at %s; call diff_query_result to see what changed since",
+					snapshot.Name, snapshot.Rows, snapshot.KeyColumn, snapshot.TakenAt),
+			},
+		},
+	}, nil
+}
+
+// handleDiffQueryResult handles diff query result requests
+func (s *SQLiteServer) handleDiffQueryResult(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	name, ok := args["name"].(string)
+	if !ok || name == "" {
+		return nil, fmt.Errorf("name parameter is required")
+	}
+	update, _ := args["update"].(bool)
+
+	snapshot, params, err := db.GetQuerySnapshot(name)
+	if err != nil {
+		return nil, err
+	}
+	// The stored query is not a tool argument, so restrictTables has not seen it
+	if s.tablesRestricted() {
+		if err := s.checkStatementAccess(snapshot.Query, params); err != nil {
+			return nil, err
+		}
+	}
+
+	diff, err := db.DiffQuerySnapshot(name, update)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff query result: %w", err)
+	}
+
+	// Redaction needs the result columns, which every reported row carries
+	columns := make(map[string]interface{})
+	for _, rows := range [][]map[string]interface{}{diff.Added, diff.Removed} {
+		for _, row := range rows {
+			for column := range row {
+				columns[column] = nil
+			}
+		}
+	}
+	for _, row := range diff.Changed {
+		for column := range row.Changes {
+			columns[column] = nil
+		}
+	}
+	columns[snapshot.KeyColumn] = nil
+	redacted := s.redactedColumns(snapshot.Query, params, []map[string]interface{}{columns})
+	redactColumns(diff.Added, redacted)
+	redactColumns(diff.Removed, redacted)
+	for i, row := range diff.Changed {
+		if redacted[snapshot.KeyColumn] {
+			diff.Changed[i].Key = redactedValue
+		}
+		for column, change := range row.Changes {
+			if redacted[column] {
+				if change.Old != nil {
+					change.Old = redactedValue
+				}
+				if change.New != nil {
+					change.New = redactedValue
+				}
+				row.Changes[column] = change
+			}
+		}
+	}
+
+	jsonData, err := json.MarshalIndent(diff, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal diff: %w", err)
+	}
+
+	summary := fmt.Sprintf("Since snapshot '%s' was taken at %s: %d added, %d removed, %d changed, %d unchanged",
+		name, diff.Snapshot.TakenAt, len(diff.Added), len(diff.Removed), len(diff.Changed), diff.Unchanged)
+	if diff.Updated {
+		summary += "; the snapshot now holds the current result"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: summary + "\n" + string(jsonData),
+			},
+		},
+	}, nil
+}
+
+// handleAutoIndex handles auto index requests
+func (s *SQLiteServer) handleAutoIndex(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+
+	create, _ := args["create"].(bool)
+	result, err := db.AutoIndex(query, create)
+	if err != nil {
+		return nil, fmt.Errorf("failed to index query: %w", err)
+	}
+
+	var message string
+	switch {
+	case len(result.Suggestions) == 0:
+		message = "No index to suggest: the query does not filter a full table scan on indexable columns"
+	case !result.Created:
+		message = fmt.Sprintf("Suggested %d index(es); set create=true to create them and re-analyze the query", len(result.Suggestions))
+	case result.Improved:
+		message = "Created index(es) and the query plan now uses them"
+	default:
+		message = "Created index(es) but the query plan did not use them, so they were dropped again"
+	}
+
+	jsonResult, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", message, string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleSuggestIndexes handles index suggestion requests
+func (s *SQLiteServer) handleSuggestIndexes(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+
+	advice, err := db.AdviseIndexes(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to suggest indexes: %w", err)
+	}
+
+	type suggestion struct {
+		Table     string   `json:"table"`
+		Columns   []string `json:"columns"`
+		Statement string   `json:"statement"`
+		// CreateIndex holds the arguments of the create_index tool for the same index
+		CreateIndex map[string]interface{} `json:"create_index"`
+	}
+	suggestions := make([]suggestion, 0, len(advice.Suggestions))
+	var text strings.Builder
+	for _, step := range advice.FullScans {
+		fmt.Fprintf(&text, "Full table scan: %s\n", step.Detail)
+	}
+	for _, step := range advice.AutomaticIndexes {
+		fmt.Fprintf(&text, "Automatic index built on every run: %s\n", step.Detail)
+	}
+	switch {
+	case len(advice.Suggestions) > 0:
+		text.WriteString("Suggested indexes (not created):\n")
+	case len(advice.FullScans) > 0 || len(advice.AutomaticIndexes) > 0:
+		text.WriteString("No index to suggest: the scans do not filter or join on columns an index would help with, or an index on those columns exists already\n")
+	default:
+		text.WriteString("No index to suggest: the query does not scan a whole table\n")
+	}
+	for _, index := range advice.Suggestions {
+		fmt.Fprintf(&text, "%s;\n", index.Statement)
+		columns := make([]map[string]interface{}, len(index.Columns))
+		for i, col := range index.Columns {
+			columns[i] = map[string]interface{}{"name": col}
+		}
+		suggestions = append(suggestions, suggestion{
+			Table:     index.Table,
+			Columns:   index.Columns,
+			Statement: index.Statement,
+			CreateIndex: map[string]interface{}{
+				"index_name": index.Name,
+				"table_name": index.Table,
+				"columns":    columns,
+			},
+		})
+	}
+
+	jsonResult, err := json.MarshalIndent(map[string]interface{}{
+		"suggestions":       suggestions,
+		"full_scans":        advice.FullScans,
+		"automatic_indexes": advice.AutomaticIndexes,
+		"plan":              advice.Plan,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s\n%s", text.String(), string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleCheckAffinity handles affinity check requests
+func (s *SQLiteServer) handleCheckAffinity(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+	params, err := parseParams(args["params"])
+	if err != nil {
+		return nil, err
+	}
+
+	warnings, err := db.CheckAffinity(query, params...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check affinity: %w", err)
+	}
+	if len(warnings) == 0 {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: "No affinity mismatches: every comparison of a column with a literal or parameter uses a value of the column's type",
+				},
+			},
+		}, nil
+	}
+
+	jsonResult, err := json.MarshalIndent(warnings, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Found %d comparison(s) whose value does not match the column's type affinity:\n%s", len(warnings), string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleTracePredicate handles predicate trace requests
+func (s *SQLiteServer) handleTracePredicate(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+	key, ok := paramValue(args["key"])
+	if !ok || key == nil {
+		return nil, fmt.Errorf("key parameter is required and must be a number or string")
+	}
+	where, ok := args["where"].(string)
+	if !ok {
+		return nil, fmt.Errorf("where parameter is required")
+	}
+
+	// Subqueries in the clause can read other tables
+	if s.tablesRestricted() {
+		quoted, err := database.QuoteIdentifier(tableName)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkStatementAccess(fmt.Sprintf("SELECT * FROM %s WHERE (%s\n)", quoted.Quoted, where), nil); err != nil {
+			return nil, err
+		}
+	}
+
+	trace, err := db.TracePredicate(tableName, key, where)
+	if err != nil {
+		return nil, fmt.Errorf("failed to trace predicate: %w", err)
+	}
+
+	jsonResult, err := json.MarshalIndent(trace, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format predicate trace: %w", err)
+	}
+
+	summary := fmt.Sprintf("The WHERE clause selects the row of '%s' with %s = %v", tableName, trace.KeyColumn, key)
+	if !trace.Matches {
+		summary = fmt.Sprintf("The WHERE clause does not select the row of '%s' with %s = %v; %d of %d conditions fail",
+			tableName, trace.KeyColumn, key, len(trace.Failed), len(trace.Conjuncts))
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", summary, string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleAnalyzeQuery handles analyze query requests
+func (s *SQLiteServer) handleAnalyzeQueryTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+
+	plan, err := db.ExplainQueryPlan(query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze query: %w", err)
+	}
+
+	// Format the query plan as the shell's tree, then as JSON with the steps nested and flat
+	tree := database.BuildPlanTree(plan)
+	jsonPlan, err := json.MarshalIndent(map[string]interface{}{
+		"plan": tree,
+		"raw":  plan,
+	}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format query plan: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Query execution plan:\n%s\n%s", database.RenderPlanTree(tree), string(jsonPlan)),
+			},
+		},
+	}, nil
+}
+
+// handleListFunctions handles custom SQL function listing requests
+func (s *SQLiteServer) handleListFunctions(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	functions := db.EnabledFunctions()
+
+	var message string
+	if len(functions) == 0 {
+		message = "No custom SQL functions are enabled"
+	} else {
+		message = fmt.Sprintf("Custom SQL functions (%d):\n", len(functions))
+		for _, fn := range functions {
+			message += fmt.Sprintf("- %s\n", fn.Description)
+		}
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleAnalyzeScript handles script analysis requests
+func (s *SQLiteServer) handleAnalyzeScript(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	script, ok := args["script"].(string)
+	if !ok {
+		return nil, fmt.Errorf("script parameter is required")
+	}
+
+	steps, err := db.AnalyzeScript(script)
+	if err != nil {
+		return nil, fmt.Errorf("failed to analyze script: %w", err)
+	}
+
+	jsonSteps, err := json.MarshalIndent(steps, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format script analysis: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Analyzed %d statement(s), none were executed:\n%s", len(steps), string(jsonSteps)),
+			},
+		},
+	}, nil
+}
+
+// handleBenchmarkQuery handles benchmark query requests
+func (s *SQLiteServer) handleBenchmarkQuery(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, ok := args["query"].(string)
+	if !ok {
+		return nil, fmt.Errorf("query parameter is required")
+	}
+
+	iterations := 10
+	if iterVal, ok := args["iterations"].(float64); ok {
+		iterations = int(iterVal)
+	}
+
+	clearCache, _ := args["clear_cache"].(bool)
+
+	result, err := db.BenchmarkQuery(query, iterations, clearCache)
+	if err != nil {
+		return nil, fmt.Errorf("benchmark failed: %w", err)
+	}
+
+	jsonResult, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format benchmark results: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Benchmark results:\n%s", string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleEstimateCardinality handles estimate cardinality requests
+func (s *SQLiteServer) handleEstimateCardinality(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok || tableName == "" {
+		return nil, fmt.Errorf("table_name parameter is required and cannot be empty")
+	}
+	fullScan, _ := args["full_scan"].(bool)
+	sampleSize := database.DefaultCardinalitySample
+	if sampleVal, ok := args["sample_size"].(float64); ok {
+		if sampleVal < 1 {
+			return nil, fmt.Errorf("sample_size must be at least 1")
+		}
+		sampleSize = int(sampleVal)
+	}
+
+	estimate, err := db.EstimateCardinality(tableName, sampleSize, fullScan)
+	if err != nil {
+		return nil, fmt.Errorf("failed to estimate cardinality: %w", err)
+	}
+
+	jsonResult, err := json.MarshalIndent(estimate, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format cardinality estimate: %w", err)
+	}
+
+	summary := fmt.Sprintf("Exact column cardinality of table '%s' (%d rows)", tableName, estimate.Rows)
+	if estimate.Sampled {
+		summary = fmt.Sprintf("Estimated column cardinality of table '%s' from a sample of %d of %d rows", tableName, estimate.SampleRows, estimate.Rows)
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", summary, string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleProfileWorkload handles profile workload requests
+func (s *SQLiteServer) handleProfileWorkload(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	list, ok := args["queries"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("queries parameter is required")
+	}
+	queries := make([]string, 0, len(list))
+	for i, item := range list {
+		query, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("query %d is not a string", i)
+		}
+		queries = append(queries, query)
+	}
+
+	iterations := 1
+	if iterVal, ok := args["iterations"].(float64); ok {
+		iterations = int(iterVal)
+	}
+
+	profile, err := db.ProfileWorkload(queries, iterations)
+	if err != nil {
+		return nil, fmt.Errorf("failed to profile workload: %w", err)
+	}
+
+	jsonResult, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format workload profile: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Workload profile:\n%s", string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleQuerySnapshot handles query snapshot requests
+func (s *SQLiteServer) handleQuerySnapshot(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	list, ok := args["queries"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("queries parameter is required")
+	}
+	queries := make([]string, 0, len(list))
+	for i, item := range list {
+		query, ok := item.(string)
+		if !ok {
+			return nil, fmt.Errorf("query %d is not a string", i)
+		}
+		queries = append(queries, query)
+	}
+
+	var maxBytes int64
+	if maxVal, ok := args["max_bytes"].(float64); ok {
+		if maxVal <= 0 {
+			return nil, fmt.Errorf("max_bytes must be positive")
+		}
+		maxBytes = int64(maxVal)
+	}
+
+	snapshot, err := db.QueryAtSnapshot(queries, maxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query snapshot: %w", err)
+	}
+
+	var notes []string
+	for i := range snapshot.Queries {
+		entry := &snapshot.Queries[i]
+		if entry.Error != "" {
+			continue
+		}
+		var note string
+		entry.Rows, note = limitResults(entry.Rows, s.maxRows, s.maxCells)
+		if note != "" {
+			notes = append(notes, fmt.Sprintf("query %d: %s", i, note))
+		}
+		// Redaction looks the columns up on the live database, whose schema a concurrent
+		// change could have altered; the name match still applies then
+		redactColumns(entry.Rows, s.redactedColumns(entry.Query, nil, entry.Rows))
+	}
+
+	jsonResult, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format snapshot results: %w", err)
+	}
+
+	summary := fmt.Sprintf("Ran %d queries against a snapshot taken at %s", len(queries), snapshot.TakenAt)
+	if snapshot.Failed > 0 {
+		summary += fmt.Sprintf(" (%d failed)", snapshot.Failed)
+	}
+	if len(notes) > 0 {
+		summary += "; " + strings.Join(notes, "; ")
+	}
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", summary, string(jsonResult)),
+			},
+		},
+	}, nil
+}
+
+// handleDatabaseStats handles database stats requests
+func (s *SQLiteServer) handleDatabaseStatsTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	stats, err := db.GetDatabaseStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get database stats: %w", err)
+	}
+
+	// Format the stats
+	jsonStats, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format database stats: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Database statistics:\n%s", string(jsonStats)),
+			},
+		},
+	}, nil
+}
+
+// handleSetForeignKeys handles set foreign keys requests
+func (s *SQLiteServer) handleSetForeignKeys(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	enabled, ok := args["enabled"].(bool)
+	if !ok {
+		return nil, fmt.Errorf("enabled parameter is required")
+	}
+
+	if err := db.SetForeignKeys(enabled); err != nil {
+		return nil, fmt.Errorf("failed to set foreign key enforcement: %w", err)
+	}
+
+	current, err := db.GetForeignKeys()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read foreign key enforcement: %w", err)
+	}
+
+	message := "Foreign key constraints are now enforced"
+	if !current {
+		message = "Foreign key constraints are no longer enforced"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleSetJournalMode handles set journal mode requests
+func (s *SQLiteServer) handleSetJournalMode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	mode, ok := args["mode"].(string)
+	if !ok || mode == "" {
+		return nil, fmt.Errorf("mode parameter is required")
+	}
+
+	current, err := db.SetJournalMode(mode)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set journal mode: %w", err)
+	}
+
+	message := fmt.Sprintf("Journal mode is now %s", current)
+	if !strings.EqualFold(current, mode) {
+		message = fmt.Sprintf("Journal mode %s is not supported by this database; the journal mode is %s", strings.ToUpper(mode), current)
+	}
+	if current == "WAL" {
+		message += ". WAL mode keeps -wal and -shm sidecar files next to the database file; copy them together with the database while it is open"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleSetJournalSizeLimit handles journal size limit requests
+func (s *SQLiteServer) handleSetJournalSizeLimit(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	limit, ok := args["limit"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("limit parameter is required")
+	}
+	if limit < -1 {
+		return nil, fmt.Errorf("limit must be -1 (no limit) or a size in bytes")
+	}
+
+	if err := db.SetJournalSizeLimit(int64(limit)); err != nil {
+		return nil, fmt.Errorf("failed to set journal size limit: %w", err)
+	}
+
+	current, err := db.GetJournalSizeLimit()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal size limit: %w", err)
+	}
+
+	message := fmt.Sprintf("Journal size limit set to %d bytes", current)
+	if current < 0 {
+		message = "Journal size limit removed"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleTempStorage handles temp storage show/set requests
+func (s *SQLiteServer) handleTempStorage(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		args = map[string]interface{}{}
+	}
+
+	store, _ := args["temp_store"].(string)
+	directory, _ := args["temp_directory"].(string)
+
+	message := "Temp storage settings"
+	if store != "" || directory != "" {
+		if err := db.SetTempStorage(store, directory); err != nil {
+			return nil, fmt.Errorf("failed to set temp storage: %w", err)
+		}
+		message = "Temp storage updated"
+	}
+
+	settings, err := db.GetTempStorage()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read temp storage settings: %w", err)
+	}
+
+	jsonSettings, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format temp storage settings: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", message, string(jsonSettings)),
+			},
+		},
+	}, nil
+}
+
+// handleThreadingMode handles threading mode reporting and serialization requests
+func (s *SQLiteServer) handleThreadingMode(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		args = map[string]interface{}{}
+	}
+
+	message := "Threading mode"
+	if serialized, ok := args["serialized"].(bool); ok {
+		db.SetSerialized(serialized)
+		message = "Connection pool updated"
+	}
+
+	status, err := db.ThreadingMode()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read threading mode: %w", err)
+	}
+
+	jsonStatus, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format threading mode: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", message, string(jsonStatus)),
+			},
+		},
+	}, nil
+}
+
+// handleCacheStats handles cache statistics and tuning requests
+func (s *SQLiteServer) handleCacheStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		args = map[string]interface{}{}
+	}
+
+	var cacheSize, mmapSize *int64
+	if value, ok := args["cache_size"].(float64); ok {
+		size := int64(value)
+		cacheSize = &size
+	}
+	if value, ok := args["mmap_size"].(float64); ok {
+		size := int64(value)
+		mmapSize = &size
+	}
+
+	message := "Cache statistics"
+	if cacheSize != nil || mmapSize != nil {
+		if err := db.SetCacheSettings(cacheSize, mmapSize); err != nil {
+			return nil, fmt.Errorf("failed to update cache settings: %w", err)
+		}
+		message = "Cache settings updated"
+	}
+
+	stats, err := db.GetCacheStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cache statistics: %w", err)
+	}
+
+	jsonStats, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format cache statistics: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s:\n%s", message, string(jsonStats)),
+			},
+		},
+	}, nil
+}
+
+// handleSetPageSize handles page size change requests
+func (s *SQLiteServer) handleSetPageSize(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	pageSize, ok := args["page_size"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("page_size parameter is required")
+	}
+
+	result, err := db.SetPageSize(int64(pageSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set page size: %w", err)
+	}
+
+	message := fmt.Sprintf("Page size changed from %d to %d bytes (%d pages)", result.PreviousPageSize, result.PageSize, result.PageCount)
+	if result.HadData {
+		message += ". The existing data was rewritten by VACUUM"
+	}
+	if result.JournalMode != "" {
+		message += fmt.Sprintf(". The database left WAL mode for the VACUUM; journal mode is %s again", result.JournalMode)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleGetUserVersion handles user version requests
+func (s *SQLiteServer) handleGetUserVersion(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	version, err := db.GetUserVersion()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read user version: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("user_version: %d", version),
+			},
+		},
+	}, nil
+}
+
+// handleSetUserVersion handles set user version requests
+func (s *SQLiteServer) handleSetUserVersion(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	version, ok := args["version"].(float64)
+	if !ok {
+		return nil, fmt.Errorf("version parameter is required")
+	}
+	if version != math.Trunc(version) {
+		return nil, fmt.Errorf("version must be an integer, got %v", version)
+	}
+
+	previous, err := db.SetUserVersion(int64(version))
+	if err != nil {
+		return nil, fmt.Errorf("failed to set user version: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("user_version changed from %d to %d", previous, int64(version)),
+			},
+		},
+	}, nil
+}
+
+// migrationDirectory reads and validates the directory argument of the migration tools
+func (s *SQLiteServer) migrationDirectory(request mcp.CallToolRequest) (string, error) {
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("invalid arguments type")
+	}
+
+	directory, ok := args["directory"].(string)
+	if !ok || directory == "" {
+		return "", fmt.Errorf("directory parameter is required")
+	}
+	if err := s.validateFilePath(directory); err != nil {
+		return "", err
+	}
+	return directory, nil
+}
+
+// handleApplyMigrations handles apply migrations requests
+func (s *SQLiteServer) handleApplyMigrations(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	directory, err := s.migrationDirectory(request)
+	if err != nil {
+		return nil, err
+	}
+
+	// Migrations create and change tables the access policy cannot vet in advance
+	if s.tablesRestricted() {
+		return nil, fmt.Errorf("applying migrations is not available while table access is restricted")
+	}
+
+	run, err := db.ApplyMigrations(directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply migrations: %w", err)
+	}
+
+	jsonRun, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format migration results: %w", err)
+	}
+
+	var summary string
+	switch {
+	case run.Failed != nil:
+		summary = fmt.Sprintf("Migration %s failed and was not applied: %s. Applied %d migration(s) before it; user_version is now %d",
+			run.Failed.File, run.Failed.Error, len(run.Applied), run.CurrentVersion)
+	case len(run.Applied) == 0:
+		summary = fmt.Sprintf("No pending migrations; user_version is %d", run.CurrentVersion)
+	default:
+		summary = fmt.Sprintf("Applied %d migration(s); user_version changed from %d to %d", len(run.Applied), run.PreviousVersion, run.CurrentVersion)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%s\n%s", summary, string(jsonRun)),
+			},
+		},
+	}, nil
+}
+
+// handleMigrationStatus handles migration status requests
+func (s *SQLiteServer) handleMigrationStatus(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	directory, err := s.migrationDirectory(request)
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := db.GetMigrationStatus(directory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get migration status: %w", err)
+	}
+
+	jsonStatus, err := json.MarshalIndent(status, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format migration status: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("%d applied, %d pending migration(s):\n%s", len(status.Applied), len(status.Pending), string(jsonStatus)),
+			},
+		},
+	}, nil
+}
+
+// handleStorageBreakdown handles storage breakdown requests
+func (s *SQLiteServer) handleStorageBreakdown(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	breakdown, err := db.StorageBreakdown()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute storage breakdown: %w", err)
+	}
+
+	jsonBreakdown, err := json.MarshalIndent(breakdown, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format storage breakdown: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Storage breakdown:\n%s", string(jsonBreakdown)),
+			},
+		},
+	}, nil
+}
+
+// handlePoolStats handles connection pool stats requests
+func (s *SQLiteServer) handlePoolStats(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	stats, err := db.GetPoolStats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pool stats: %w", err)
+	}
+
+	jsonStats, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format pool stats: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Connection pool statistics:\n%s", string(jsonStats)),
+			},
+		},
+	}, nil
+}
+
+// handleCreateDatabase handles create database requests
+func (s *SQLiteServer) handleCreateDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	directory, ok := args["directory"].(string)
+	if !ok || directory == "" {
+		return nil, fmt.Errorf("directory parameter is required and cannot be empty")
+	}
+
+	// Auto-replace current directory with first allowed directory
+	if directory == "." || directory == "./" {
+		if len(s.allowedDirs) > 0 {
+			directory = s.allowedDirs[0]
+		} else {
+			return nil, fmt.Errorf("no allowed directories configured")
 		}
 	}
 
@@ -636,105 +4205,394 @@ func (s *SQLiteServer) handleCreateDatabase(ctx context.Context, request mcp.Cal
 		filename = fmt.Sprintf("database_%d.db", time.Now().Unix())
 	}
 
-	// Construct full path
-	dbPath := filepath.Join(directory, filename)
+	// Construct full path
+	dbPath := filepath.Join(directory, filename)
+
+	// Check if file already exists
+	if _, err := os.Stat(dbPath); err == nil {
+		// File exists, generate unique name
+		base := strings.TrimSuffix(filename, ".db")
+		for i := 1; ; i++ {
+			testPath := filepath.Join(directory, fmt.Sprintf("%s_%d.db", base, i))
+			if _, err := os.Stat(testPath); os.IsNotExist(err) {
+				dbPath = testPath
+				filename = fmt.Sprintf("%s_%d.db", base, i)
+				break
+			}
+		}
+	}
+
+	if err := database.CreateNewDatabase(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to create database: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Database created successfully:\nPath: %s\nFilename: %s", dbPath, filename),
+			},
+		},
+	}, nil
+}
+
+// handleIntegrityCheck handles integrity check requests
+func (s *SQLiteServer) handleIntegrityCheck(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	quick := false
+	maxErrors := database.DefaultIntegrityErrors
+	if args, ok := request.Params.Arguments.(map[string]interface{}); ok {
+		quick, _ = args["quick"].(bool)
+		if maxVal, ok := args["max_errors"].(float64); ok {
+			if maxVal < 1 {
+				return nil, fmt.Errorf("max_errors must be at least 1")
+			}
+			maxErrors = int(maxVal)
+		}
+	}
+
+	messages, err := db.IntegrityCheck(quick, maxErrors)
+	if err != nil {
+		return nil, fmt.Errorf("integrity check failed, the file may be badly damaged: %w", err)
+	}
+
+	check := "integrity_check"
+	if quick {
+		check = "quick_check"
+	}
+	var message string
+	if len(messages) == 1 && messages[0] == "ok" {
+		message = fmt.Sprintf("ok: %s found no problems in %s", check, db.GetCurrentDatabasePath())
+	} else {
+		message = fmt.Sprintf("%s found %d problem(s) in %s:", check, len(messages), db.GetCurrentDatabasePath())
+		for _, problem := range messages {
+			message += "\n- " + problem
+		}
+		if len(messages) >= maxErrors {
+			message += fmt.Sprintf("\nStopped after %d problems; raise max_errors to see more", maxErrors)
+		}
+		message += "\nConsider recover_database to salvage the readable rows"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleQuoteIdentifier handles identifier quoting requests
+func (s *SQLiteServer) handleQuoteIdentifier(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments")
+	}
+	name, ok := args["name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("name is required")
+	}
+
+	quoted, err := database.QuoteIdentifier(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to quote identifier: %w", err)
+	}
+
+	resultJSON, err := json.MarshalIndent(quoted, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// handleDatabaseExists handles database exists check requests
+func (s *SQLiteServer) handleDatabaseExists(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	dbPath, ok := args["db_path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("db_path parameter is required")
+	}
+
+	// Validate that the database path is in an allowed directory
+	if err := s.validateFilePath(dbPath); err != nil {
+		return nil, err
+	}
+
+	exists := database.DatabaseExists(dbPath)
+	status := "does not exist"
+	if exists {
+		status = "exists and is valid"
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Database at %s %s", dbPath, status),
+			},
+		},
+	}, nil
+}
+
+// handleSwitchDatabase handles switching to a different database file
+func (s *SQLiteServer) handleSwitchDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	dbPath, ok := args["db_path"].(string)
+	if !ok {
+		return nil, fmt.Errorf("db_path parameter is required")
+	}
+
+	// Validate that the database path is in an allowed directory
+	if err := s.validateFilePath(dbPath); err != nil {
+		return nil, err
+	}
+
+	// Check if the database file exists
+	if !database.DatabaseExists(dbPath) {
+		return nil, fmt.Errorf("database file does not exist or is not a valid SQLite database: %s", dbPath)
+	}
+
+	// Attachments belong to the current database
+	for alias := range s.attached {
+		if err := db.DetachDatabase(alias); err != nil {
+			return nil, fmt.Errorf("failed to detach '%s': %w", alias, err)
+		}
+		delete(s.attached, alias)
+	}
+	for _, scratch := range db.ScratchDatabases() {
+		if !scratch.KeepOnSwitch {
+			if err := db.DropScratch(scratch.Alias); err != nil {
+				return nil, fmt.Errorf("failed to drop scratch database '%s': %w", scratch.Alias, err)
+			}
+		}
+	}
+
+	// Switch to the new database
+	if err := db.SwitchDatabase(dbPath); err != nil {
+		return nil, fmt.Errorf("failed to switch database: %w", err)
+	}
+
+	// Update server's dbPath field
+	s.dbPath = dbPath
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Successfully switched to database: %s", dbPath),
+			},
+		},
+	}, nil
+}
+
+// handleAttachDatabase handles attach database requests
+func (s *SQLiteServer) handleAttachDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	dbPath, ok := args["db_path"].(string)
+	if !ok || dbPath == "" {
+		return nil, fmt.Errorf("db_path parameter is required")
+	}
+	alias, ok := args["alias"].(string)
+	if !ok || alias == "" {
+		return nil, fmt.Errorf("alias parameter is required")
+	}
+
+	// An attached database is one the access policy does not cover, see tablesRestricted
+	if s.tablesRestricted() {
+		return nil, fmt.Errorf("attaching databases is not available while table access is restricted")
+	}
+
+	if err := s.validateFilePath(dbPath); err != nil {
+		return nil, err
+	}
+	// DatabaseExists would create a missing file by opening it
+	if _, err := os.Stat(dbPath); err != nil || !database.DatabaseExists(dbPath) {
+		return nil, fmt.Errorf("database file does not exist or is not a valid SQLite database: %s", dbPath)
+	}
+
+	if err := db.AttachDatabase(dbPath, alias); err != nil {
+		return nil, fmt.Errorf("failed to attach database: %w", err)
+	}
+	if s.attached == nil {
+		s.attached = make(map[string]string)
+	}
+	s.attached[alias] = dbPath
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Attached %s as %s; refer to its tables as %s.table_name", dbPath, alias, alias),
+			},
+		},
+	}, nil
+}
 
-	// Check if file already exists
-	if _, err := os.Stat(dbPath); err == nil {
-		// File exists, generate unique name
-		base := strings.TrimSuffix(filename, ".db")
-		for i := 1; ; i++ {
-			testPath := filepath.Join(directory, fmt.Sprintf("%s_%d.db", base, i))
-			if _, err := os.Stat(testPath); os.IsNotExist(err) {
-				dbPath = testPath
-				filename = fmt.Sprintf("%s_%d.db", base, i)
-				break
-			}
-		}
+// handleDetachDatabase handles detach database requests
+func (s *SQLiteServer) handleDetachDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
 	}
 
-	if err := database.CreateNewDatabase(dbPath); err != nil {
-		return nil, fmt.Errorf("failed to create database: %w", err)
+	alias, ok := args["alias"].(string)
+	if !ok || alias == "" {
+		return nil, fmt.Errorf("alias parameter is required")
+	}
+
+	if err := db.DetachDatabase(alias); err != nil {
+		return nil, fmt.Errorf("failed to detach database: %w", err)
+	}
+	for name := range s.attached {
+		if strings.EqualFold(name, alias) {
+			delete(s.attached, name)
+		}
 	}
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Database created successfully:\nPath: %s\nFilename: %s", dbPath, filename),
+				Text: fmt.Sprintf("Detached %s", alias),
 			},
 		},
 	}, nil
 }
 
-// handleDatabaseExists handles database exists check requests
-func (s *SQLiteServer) handleDatabaseExists(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// handleCreateScratch handles create scratch requests
+func (s *SQLiteServer) handleCreateScratch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	args, ok := request.Params.Arguments.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid arguments type")
 	}
 
-	dbPath, ok := args["db_path"].(string)
-	if !ok {
-		return nil, fmt.Errorf("db_path parameter is required")
+	alias, _ := args["alias"].(string)
+	if alias == "" {
+		alias = "scratch"
 	}
+	keepOnSwitch, _ := args["keep_on_switch"].(bool)
 
-	// Validate that the database path is in an allowed directory
-	if err := s.validateFilePath(dbPath); err != nil {
-		return nil, err
+	// A scratch database is one the access policy does not cover, see tablesRestricted
+	if s.tablesRestricted() {
+		return nil, fmt.Errorf("scratch databases are not available while table access is restricted")
 	}
 
-	exists := database.DatabaseExists(dbPath)
-	status := "does not exist"
-	if exists {
-		status = "exists and is valid"
+	if _, err := db.CreateScratch(alias, keepOnSwitch); err != nil {
+		return nil, fmt.Errorf("failed to create scratch database: %w", err)
 	}
 
+	lifetime := "it is discarded when switching databases or when the server stops"
+	if keepOnSwitch {
+		lifetime = "it stays attached across switch_database and is discarded when the server stops"
+	}
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Database at %s %s", dbPath, status),
+				Text: fmt.Sprintf("Created in-memory scratch database %s; create tables there as %s.table_name and join them with the main database. Nothing in it is saved: %s, or drop it with drop_scratch", alias, alias, lifetime),
 			},
 		},
 	}, nil
 }
 
-// handleSwitchDatabase handles switching to a different database file
-func (s *SQLiteServer) handleSwitchDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+// handleDropScratch handles drop scratch requests
+func (s *SQLiteServer) handleDropScratch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
 	args, ok := request.Params.Arguments.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("invalid arguments type")
 	}
 
-	dbPath, ok := args["db_path"].(string)
-	if !ok {
-		return nil, fmt.Errorf("db_path parameter is required")
+	alias, _ := args["alias"].(string)
+	if alias == "" {
+		alias = "scratch"
 	}
 
-	// Validate that the database path is in an allowed directory
-	if err := s.validateFilePath(dbPath); err != nil {
-		return nil, err
+	if err := db.DropScratch(alias); err != nil {
+		return nil, fmt.Errorf("failed to drop scratch database: %w", err)
 	}
 
-	// Check if the database file exists
-	if !database.DatabaseExists(dbPath) {
-		return nil, fmt.Errorf("database file does not exist or is not a valid SQLite database: %s", dbPath)
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Dropped scratch database %s and discarded its tables", alias),
+			},
+		},
+	}, nil
+}
+
+// handleListScratch handles list scratch requests
+func (s *SQLiteServer) handleListScratch(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	type scratchInfo struct {
+		Alias        string   `json:"alias"`
+		KeepOnSwitch bool     `json:"keep_on_switch"`
+		Tables       []string `json:"tables"`
 	}
 
-	// Switch to the new database
-	if err := s.db.SwitchDatabase(dbPath); err != nil {
-		return nil, fmt.Errorf("failed to switch database: %w", err)
+	scratch := db.ScratchDatabases()
+	if len(scratch) == 0 {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: "No scratch databases; create one with create_scratch",
+				},
+			},
+		}, nil
 	}
 
-	// Update server's dbPath field
-	s.dbPath = dbPath
+	infos := make([]scratchInfo, 0, len(scratch))
+	for _, a := range scratch {
+		rows, err := db.ExecuteQuery(fmt.Sprintf("SELECT name FROM %s.sqlite_master WHERE type = 'table' ORDER BY name", a.Alias))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tables of '%s': %w", a.Alias, err)
+		}
+		info := scratchInfo{Alias: a.Alias, KeepOnSwitch: a.KeepOnSwitch, Tables: []string{}}
+		for _, row := range rows {
+			if name, ok := row["name"].(string); ok {
+				info.Tables = append(info.Tables, name)
+			}
+		}
+		infos = append(infos, info)
+	}
 
+	jsonData, err := json.MarshalIndent(infos, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal scratch databases: %w", err)
+	}
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
 			mcp.TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Successfully switched to database: %s", dbPath),
+				Text: fmt.Sprintf("Scratch databases:\n%s", string(jsonData)),
 			},
 		},
 	}, nil
@@ -742,7 +4600,8 @@ func (s *SQLiteServer) handleSwitchDatabase(ctx context.Context, request mcp.Cal
 
 // handleCurrentDatabase handles showing the current database path
 func (s *SQLiteServer) handleCurrentDatabase(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	currentPath := s.db.GetCurrentDatabasePath()
+	db := s.db.WithContext(ctx)
+	currentPath := db.GetCurrentDatabasePath()
 
 	return &mcp.CallToolResult{
 		Content: []mcp.Content{
@@ -830,12 +4689,54 @@ func (s *SQLiteServer) validateDirectory(directory string) error {
 	return fmt.Errorf("directory '%s' is not in allowed directories: %v", directory, s.allowedDirs)
 }
 
-// validateFilePath checks if the file path is in the allowed directories
+// dataFileDirectory resolves the directory argument of a tool that reads or writes data files
+// by name. It must be an allowed directory or the directory of an allowed database file;
+// empty or "." means the first of them. Since that default passes any relative directory, it
+// does not validate the directory of a file path; resolveDataPath does.
+func (s *SQLiteServer) dataFileDirectory(directory string) (string, error) {
+	var dirs []string
+	for _, allowed := range s.allowedDirs {
+		if info, err := os.Stat(allowed); err == nil && !info.IsDir() {
+			allowed = filepath.Dir(allowed)
+		}
+		dirs = append(dirs, strings.TrimSuffix(allowed, "/"))
+	}
+	if len(dirs) == 0 {
+		return "", fmt.Errorf("no allowed directories configured")
+	}
+
+	if directory == "" || directory == "." || directory == "./" {
+		return dirs[0], nil
+	}
+	for _, dir := range dirs {
+		if strings.TrimSuffix(directory, "/") == dir {
+			return directory, nil
+		}
+	}
+	return "", fmt.Errorf("directory '%s' is not in allowed directories: %v", directory, dirs)
+}
+
+// validateFilePath checks if the file path is in the allowed directories. Both sides are made
+// absolute with symlinks resolved, so neither a shared name prefix such as /data-secret for
+// /data nor a symlink pointing outside passes; paths containing ".." are rejected outright.
 func (s *SQLiteServer) validateFilePath(filePath string) error {
+	if hasParentReference(filePath) {
+		return fmt.Errorf("file path '%s' must not contain '..'", filePath)
+	}
+	resolved, err := resolvePath(filePath)
+	if err != nil {
+		return fmt.Errorf("invalid file path '%s': %w", filePath, err)
+	}
+
 	// Check if file path is in any allowed directory
 	for _, allowedDir := range s.allowedDirs {
-		normalizedAllowedDir := strings.TrimSuffix(allowedDir, "/")
-		if strings.HasPrefix(filePath, normalizedAllowedDir+"/") || strings.HasPrefix(filePath, normalizedAllowedDir) {
+		allowed, err := resolvePath(allowedDir)
+		if err != nil {
+			continue
+		}
+		// An allowed path may also be a single database file, which only allows itself
+		rel, err := filepath.Rel(allowed, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
 			return nil
 		}
 	}
@@ -843,6 +4744,86 @@ func (s *SQLiteServer) validateFilePath(filePath string) error {
 	return fmt.Errorf("file path '%s' is not in allowed directories: %v", filePath, s.allowedDirs)
 }
 
+// hasParentReference reports whether a path has a ".." element
+func hasParentReference(path string) bool {
+	for _, part := range strings.Split(filepath.ToSlash(path), "/") {
+		if part == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDataPath returns the absolute path of a file a tool reads or writes, given as an
+// argument. A relative path is taken relative to the first allowed directory, never the
+// working directory of the server. The file must be in an allowed directory, see
+// validateFilePath, or directly in the directory of an allowed database file.
+func (s *SQLiteServer) resolveDataPath(path string) (string, error) {
+	if hasParentReference(path) {
+		return "", fmt.Errorf("file path '%s' must not contain '..'", path)
+	}
+	if !filepath.IsAbs(path) {
+		directory, err := s.dataFileDirectory("")
+		if err != nil {
+			return "", err
+		}
+		path = filepath.Join(directory, path)
+	}
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("invalid file path '%s': %w", path, err)
+	}
+
+	validateErr := s.validateFilePath(path)
+	if validateErr == nil {
+		return path, nil
+	}
+	dir, err := resolvePath(filepath.Dir(path))
+	if err != nil {
+		return "", validateErr
+	}
+	for _, allowed := range s.allowedDirs {
+		if info, err := os.Stat(allowed); err != nil || info.IsDir() {
+			continue
+		}
+		if allowedDir, err := resolvePath(filepath.Dir(allowed)); err == nil && allowedDir == dir {
+			return path, nil
+		}
+	}
+	return "", validateErr
+}
+
+// samePath reports whether two paths name the same file once made absolute with symlinks
+// resolved
+func samePath(a, b string) bool {
+	resolvedA, errA := resolvePath(a)
+	resolvedB, errB := resolvePath(b)
+	return errA == nil && errB == nil && resolvedA == resolvedB
+}
+
+// resolvePath returns the absolute, cleaned form of path with symlinks resolved. A path that
+// does not exist yet, such as a file about to be created, is resolved through its deepest
+// existing ancestor.
+func resolvePath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	existing, rest := abs, ""
+	for {
+		resolved, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			return filepath.Join(resolved, rest), nil
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return abs, nil
+		}
+		rest = filepath.Join(filepath.Base(existing), rest)
+		existing = parent
+	}
+}
+
 // generateFilenameFromPurpose creates a suitable filename based on the database purpose
 func generateFilenameFromPurpose(purpose string) string {
 	// Convert purpose to a valid filename
@@ -912,3 +4893,226 @@ func (s *SQLiteServer) handleDeleteDatabase(ctx context.Context, request mcp.Cal
 		},
 	}, nil
 }
+
+// handleDescribeRelationships handles ER model requests
+func (s *SQLiteServer) handleDescribeRelationships(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	format := "json"
+	if formatVal, ok := args["format"].(string); ok && formatVal != "" {
+		format = strings.ToLower(formatVal)
+	}
+	if format != "json" && format != "mermaid" {
+		return nil, fmt.Errorf("unsupported format '%s', use json or mermaid", format)
+	}
+
+	model, err := db.GetERModel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build relationship model: %w", err)
+	}
+
+	var message string
+	if format == "mermaid" {
+		message = model.Mermaid()
+	} else {
+		jsonModel, err := json.MarshalIndent(model, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format relationship model: %w", err)
+		}
+		message = fmt.Sprintf("Found %d table(s) and %d relationship(s):\n%s",
+			len(model.Entities), len(model.Relationships), string(jsonModel))
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleExportDDL handles schema export requests
+func (s *SQLiteServer) handleExportDDL(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	dialect := "postgres"
+	if args, ok := request.Params.Arguments.(map[string]interface{}); ok {
+		if d, ok := args["dialect"].(string); ok && d != "" {
+			dialect = d
+		}
+	}
+
+	ddl, err := db.ExportDDL(dialect)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export DDL: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: ddl,
+			},
+		},
+	}, nil
+}
+
+// handleDumpSchema handles schema dump requests
+func (s *SQLiteServer) handleDumpSchema(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	includeData := false
+	if args, ok := request.Params.Arguments.(map[string]interface{}); ok {
+		includeData, _ = args["include_data"].(bool)
+	}
+
+	// Tables hidden by the access policy are left out, and redacted columns are masked
+	var dump strings.Builder
+	err := db.DumpSchema(&dump, database.DumpOptions{
+		IncludeData: includeData,
+		Include:     s.tableAllowed,
+		Redact:      func(column string) bool { return matchesRedaction(column, s.redactPatterns) },
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to dump schema: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: dump.String(),
+			},
+		},
+	}, nil
+}
+
+// handleValidateRow handles row validation requests
+func (s *SQLiteServer) handleValidateRow(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	row, ok := args["row"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("row parameter is required and must be an object")
+	}
+
+	problems, err := db.ValidateRow(tableName, row)
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate row: %w", err)
+	}
+
+	var message string
+	if len(problems) == 0 {
+		message = fmt.Sprintf("Row is valid for table '%s'", tableName)
+	} else {
+		jsonProblems, err := json.MarshalIndent(problems, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format problems: %w", err)
+		}
+		message = fmt.Sprintf("Found %d problem(s) with the row for table '%s':\n%s", len(problems), tableName, string(jsonProblems))
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// handleRenameColumn handles rename column requests
+func (s *SQLiteServer) handleRenameColumn(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	tableName, ok := args["table_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("table_name parameter is required")
+	}
+
+	oldName, ok := args["old_name"].(string)
+	if !ok {
+		return nil, fmt.Errorf("old_name parameter is required")
+	}
+
+	newName, ok := args["new_name"].(string)
+	if !ok || newName == "" {
+		return nil, fmt.Errorf("new_name parameter is required and cannot be empty")
+	}
+
+	checkOnly, _ := args["check_only"].(bool)
+	fixDependents, _ := args["fix_dependents"].(bool)
+
+	if checkOnly {
+		dependents, err := db.FindColumnDependents(tableName, oldName)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dependent objects: %w", err)
+		}
+
+		var message string
+		if len(dependents) == 0 {
+			message = fmt.Sprintf("No views or triggers reference %s.%s", tableName, oldName)
+		} else {
+			message = fmt.Sprintf("Found %d object(s) referencing %s.%s:\n", len(dependents), tableName, oldName)
+			for _, dep := range dependents {
+				message += fmt.Sprintf("- %s %s\n", dep.Type, dep.Name)
+			}
+		}
+
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: message,
+				},
+			},
+		}, nil
+	}
+
+	result, err := db.RenameColumn(tableName, oldName, newName, fixDependents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to rename column: %w", err)
+	}
+
+	message := fmt.Sprintf("Column '%s' renamed to '%s' in table '%s'", oldName, newName, tableName)
+	if len(result.Dependents) > 0 {
+		message += fmt.Sprintf("\nChecked %d dependent object(s)", len(result.Dependents))
+	}
+	for _, dep := range result.Fixed {
+		message += fmt.Sprintf("\nRewrote %s '%s' to use the new column name", dep.Type, dep.Name)
+	}
+	for _, dep := range result.Broken {
+		message += fmt.Sprintf("\nWARNING: %s '%s' still references '%s' and may be broken", dep.Type, dep.Name, oldName)
+	}
+	if len(result.Broken) > 0 && !fixDependents {
+		message += "\nRe-run with fix_dependents=true or recreate these objects manually"
+	}
+	message += s.updatedSchema(tableName)
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
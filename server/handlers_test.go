@@ -0,0 +1,205 @@
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDryRunRollsBack(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE a (x INTEGER)", "INSERT INTO a VALUES (1), (2)")
+
+	text := mustCall(t, srv, "execute", map[string]interface{}{"statement": "DELETE FROM a WHERE x = 1", "dry_run": true})
+	if !strings.Contains(text, "Rows that would be affected: 1") {
+		t.Errorf("unexpected dry run response: %s", text)
+	}
+	text = mustCall(t, srv, "transaction", map[string]interface{}{"statements": []interface{}{"DELETE FROM a", "INSERT INTO a VALUES (3)"}, "dry_run": true})
+	if !strings.Contains(text, "Rows that would be affected: 3") {
+		t.Errorf("unexpected dry run response: %s", text)
+	}
+	if n := countRows(t, srv, "a"); n != 2 {
+		t.Errorf("dry runs changed the table: %d rows, want 2", n)
+	}
+}
+
+func TestDryRunRejectsCommit(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE a (x INTEGER)", "INSERT INTO a VALUES (1), (2)")
+
+	calls := []struct {
+		tool string
+		args map[string]interface{}
+	}{
+		{"execute", map[string]interface{}{"statement": "DELETE FROM a WHERE x = 1; COMMIT", "dry_run": true}},
+		{"execute", map[string]interface{}{"statement": "/* c */ COMMIT", "dry_run": true}},
+		{"transaction", map[string]interface{}{"statements": []interface{}{"DELETE FROM a WHERE x = 1", "COMMIT"}, "dry_run": true}},
+		{"transaction", map[string]interface{}{"statements": []interface{}{"DELETE FROM a WHERE x = 1", "end"}, "dry_run": true}},
+		{"transaction", map[string]interface{}{"statements": []interface{}{"SAVEPOINT s", "DELETE FROM a", "RELEASE s"}, "dry_run": true}},
+		{"transaction", map[string]interface{}{"statements": []interface{}{"VACUUM"}, "dry_run": true}},
+	}
+	for _, call := range calls {
+		if _, err := callTool(t, srv, call.tool, call.args); err == nil {
+			t.Errorf("%s %v: dry run was accepted", call.tool, call.args)
+		}
+	}
+	if n := countRows(t, srv, "a"); n != 2 {
+		t.Errorf("a dry run committed: %d rows, want 2", n)
+	}
+}
+
+func TestBenchmarkQueryRejectsSeveralStatements(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t1 (x INTEGER)")
+
+	if _, err := callTool(t, srv, "benchmark_query", map[string]interface{}{"query": "SELECT 1; DROP TABLE t1", "iterations": 1}); err == nil {
+		t.Error("a query with a second statement was benchmarked")
+	}
+	if !tableExists(t, srv, "t1") {
+		t.Error("benchmark_query dropped t1")
+	}
+	text := mustCall(t, srv, "benchmark_query", map[string]interface{}{"query": "SELECT * FROM t1", "iterations": 2})
+	if !strings.Contains(text, `"iterations": 2`) {
+		t.Errorf("unexpected response: %s", text)
+	}
+}
+
+func TestSetPageSizeOnDefaultDatabase(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER PRIMARY KEY)", "INSERT INTO t VALUES (1)")
+
+	text := mustCall(t, srv, "set_page_size", map[string]interface{}{"page_size": 16384})
+	if !strings.Contains(text, "to 16384 bytes") || !strings.Contains(text, "journal mode is WAL again") {
+		t.Fatalf("unexpected result: %s", text)
+	}
+	if n := countRows(t, srv, "t"); n != 1 {
+		t.Fatalf("%d rows after the change", n)
+	}
+}
+
+func TestTransactionStatementLimit(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER)")
+	srv.SetMaxTransactionStatements(5)
+
+	statements := func(n int) []interface{} {
+		var list []interface{}
+		for i := 0; i < n; i++ {
+			list = append(list, fmt.Sprintf("INSERT INTO t VALUES (%d)", i))
+		}
+		return list
+	}
+
+	mustCall(t, srv, "transaction", map[string]interface{}{"statements": statements(5)})
+	if n := countRows(t, srv, "t"); n != 5 {
+		t.Fatalf("%d rows after a transaction at the limit, want 5", n)
+	}
+	_, err := callTool(t, srv, "transaction", map[string]interface{}{"statements": statements(6)})
+	if err == nil || !strings.Contains(err.Error(), "more than the limit of 5") {
+		t.Fatalf("got %v for a transaction over the limit", err)
+	}
+	if n := countRows(t, srv, "t"); n != 5 {
+		t.Fatalf("%d rows after the rejected transaction, want 5", n)
+	}
+}
+
+func TestDiskFullRollsBack(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE blobs (data BLOB)", "INSERT INTO blobs VALUES (zeroblob(10))")
+	// max_page_count is a setting of a connection, so keep to one
+	srv.SetSerialized(true)
+	if _, err := srv.db.ExecuteStatement("PRAGMA max_page_count = 20"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, call := range []struct {
+		tool string
+		args map[string]interface{}
+	}{
+		{"execute", map[string]interface{}{"statement": "INSERT INTO blobs SELECT zeroblob(100000)"}},
+		{"transaction", map[string]interface{}{"statements": []interface{}{
+			"INSERT INTO blobs VALUES (zeroblob(10))",
+			"INSERT INTO blobs SELECT zeroblob(100000)",
+		}}},
+	} {
+		_, err := callTool(t, srv, call.tool, call.args)
+		if err == nil || !strings.Contains(err.Error(), "insufficient disk space") || !strings.Contains(err.Error(), "rolled back") {
+			t.Fatalf("%s: got %v, want a disk full error", call.tool, err)
+		}
+		if n := countRows(t, srv, "blobs"); n != 1 {
+			t.Fatalf("%s: %d rows after the failed write, want 1", call.tool, n)
+		}
+	}
+}
+
+func TestQueryIncludeProvenance(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, user_id INTEGER, total REAL)",
+		"INSERT INTO users VALUES (1, 'ann')",
+		"INSERT INTO orders VALUES (1, 1, 9.5)",
+	)
+
+	text := mustCall(t, srv, "query", map[string]interface{}{
+		"query":              "SELECT u.name, o.total FROM orders o JOIN users u ON u.id = o.user_id",
+		"include_provenance": true,
+	})
+	for _, want := range []string{`"table": "users"`, `"column": "name"`, `"table": "orders"`, `"column": "total"`, `"rows": [`, `"name": "ann"`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("result lacks %s:\n%s", want, text)
+		}
+	}
+}
+
+func TestForeignKeyViolationNamesConstraint(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE parents (id INTEGER PRIMARY KEY)",
+		"CREATE TABLE children (id INTEGER PRIMARY KEY, parent_id INTEGER, CONSTRAINT fk_child_parent FOREIGN KEY (parent_id) REFERENCES parents (id))",
+	)
+	want := "constraint fk_child_parent: children(parent_id) REFERENCES parents(id)"
+
+	_, err := callTool(t, srv, "execute", map[string]interface{}{"statement": "INSERT INTO children VALUES (1, 99)"})
+	if err == nil || !strings.Contains(err.Error(), want) || !strings.Contains(err.Error(), "SQLITE_CONSTRAINT_FOREIGNKEY") {
+		t.Fatalf("execute: got %v, want it to name %q", err, want)
+	}
+	_, err = callTool(t, srv, "transaction", map[string]interface{}{"statements": []interface{}{
+		"INSERT INTO parents VALUES (1)",
+		"INSERT INTO children VALUES (1, 1)",
+		"INSERT INTO children VALUES (2, 99)",
+	}})
+	if err == nil || !strings.Contains(err.Error(), want+" (1 row)") {
+		t.Fatalf("transaction: got %v, want it to name %q", err, want)
+	}
+	if n := countRows(t, srv, "parents"); n != 0 {
+		t.Fatal("the failed transaction was not rolled back")
+	}
+
+	mustCall(t, srv, "set_foreign_keys", map[string]interface{}{"enabled": false})
+	mustCall(t, srv, "execute", map[string]interface{}{"statement": "INSERT INTO children VALUES (1, 99)"})
+	mustCall(t, srv, "set_foreign_keys", map[string]interface{}{"enabled": true})
+	if _, err := callTool(t, srv, "execute", map[string]interface{}{"statement": "INSERT INTO children VALUES (2, 99)"}); err == nil {
+		t.Fatal("orphan accepted after turning enforcement back on")
+	}
+}
+
+func TestRenameTableArguments(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE a (id INTEGER)", "CREATE TABLE secret (id INTEGER)")
+
+	mustCall(t, srv, "rename_table", map[string]interface{}{"old_name": "a", "new_name": "b"})
+	if tableExists(t, srv, "a") || !tableExists(t, srv, "b") {
+		t.Fatal("old_name did not rename the table")
+	}
+	// table_name still works as an alias of old_name
+	mustCall(t, srv, "rename_table", map[string]interface{}{"table_name": "b", "new_name": "c"})
+	if !tableExists(t, srv, "c") {
+		t.Fatal("table_name did not rename the table")
+	}
+	if _, err := callTool(t, srv, "rename_table", map[string]interface{}{"new_name": "d"}); err == nil || !strings.Contains(err.Error(), "old_name") {
+		t.Fatalf("got %v, want old_name required", err)
+	}
+
+	srv.SetTableAccess(nil, []string{"secret"})
+	for _, key := range []string{"old_name", "table_name"} {
+		_, err := callTool(t, srv, "rename_table", map[string]interface{}{key: "secret", "new_name": "open"})
+		if err == nil || !strings.Contains(err.Error(), "is denied") {
+			t.Errorf("%s: got %v, want access denied", key, err)
+		}
+	}
+	if !tableExists(t, srv, "secret") {
+		t.Fatal("denied table was renamed")
+	}
+}
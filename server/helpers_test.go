@@ -0,0 +1,103 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestServer opens a server on a new database in a temporary directory, which is also
+// the only allowed directory, after running setup statements against it
+func newTestServer(t *testing.T, setup ...string) *SQLiteServer {
+	t.Helper()
+	dir := t.TempDir()
+	srv, err := NewSQLiteServerWithDirs(filepath.Join(dir, "test.db"), []string{dir})
+	if err != nil {
+		t.Fatalf("failed to create server: %v", err)
+	}
+	t.Cleanup(func() { srv.db.Close() })
+	for _, statement := range setup {
+		if _, err := srv.db.ExecuteStatement(statement); err != nil {
+			t.Fatalf("setup %q failed: %v", statement, err)
+		}
+	}
+	return srv
+}
+
+// callTool calls a tool the way a client does, through every wrapper the server registers
+// it with, and returns its text or the error it failed with
+func callTool(t *testing.T, srv *SQLiteServer, name string, args map[string]interface{}) (string, error) {
+	t.Helper()
+	request, err := json.Marshal(map[string]interface{}{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]interface{}{"name": name, "arguments": args},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := json.Marshal(srv.server.HandleMessage(context.Background(), request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Result *struct {
+			Content []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+			IsError bool `json:"isError"`
+		} `json:"result"`
+		Error *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(encoded, &response); err != nil {
+		t.Fatalf("invalid response %s: %v", encoded, err)
+	}
+	if response.Error != nil {
+		return "", errors.New(response.Error.Message)
+	}
+	var text []string
+	for _, content := range response.Result.Content {
+		text = append(text, content.Text)
+	}
+	if response.Result.IsError {
+		return "", errors.New(strings.Join(text, "\n"))
+	}
+	return strings.Join(text, "\n"), nil
+}
+
+// mustCall calls a tool and fails the test when the call fails
+func mustCall(t *testing.T, srv *SQLiteServer, name string, args map[string]interface{}) string {
+	t.Helper()
+	text, err := callTool(t, srv, name, args)
+	if err != nil {
+		t.Fatalf("%s failed: %v", name, err)
+	}
+	return text
+}
+
+// countRows returns the number of rows of a table
+func countRows(t *testing.T, srv *SQLiteServer, table string) int64 {
+	t.Helper()
+	rows, err := srv.db.ExecuteQuery("SELECT COUNT(*) AS n FROM " + table)
+	if err != nil {
+		t.Fatalf("failed to count %s: %v", table, err)
+	}
+	n, _ := rows[0]["n"].(int64)
+	return n
+}
+
+// tableExists reports whether the database has a table of that name
+func tableExists(t *testing.T, srv *SQLiteServer, table string) bool {
+	t.Helper()
+	rows, err := srv.db.ExecuteQuery("SELECT name FROM sqlite_master WHERE type = 'table' AND name = ?", table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(rows) > 0
+}
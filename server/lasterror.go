@@ -0,0 +1,68 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/liliang-cn/mcp-sqlite-server/database"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// toolError records the most recent failed tool call
+type toolError struct {
+	Tool    string                    `json:"tool"`
+	Message string                    `json:"message"`
+	Time    time.Time                 `json:"time"`
+	SQLite  *database.SQLiteErrorInfo `json:"sqlite,omitempty"`
+}
+
+// trackErrors wraps a tool handler so that failures are remembered for get_last_error and
+// errors coming from SQLite carry their result code, e.g. "[SQLITE_CONSTRAINT_UNIQUE 2067]"
+func (s *SQLiteServer) trackErrors(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := handler(ctx, request)
+		if err == nil {
+			return result, nil
+		}
+
+		info, ok := database.SQLiteErrorCode(err)
+		if ok {
+			err = fmt.Errorf("%w [%s %d]", err, info.Name(), info.ExtendedCode)
+		}
+
+		s.lastErrorMu.Lock()
+		s.lastError = &toolError{Tool: name, Message: err.Error(), Time: time.Now(), SQLite: info}
+		s.lastErrorMu.Unlock()
+
+		return result, err
+	}
+}
+
+// handleGetLastError returns the details of the most recent failed tool call
+func (s *SQLiteServer) handleGetLastError(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	s.lastErrorMu.Lock()
+	lastError := s.lastError
+	s.lastErrorMu.Unlock()
+
+	message := "No tool call has failed since the server started"
+	if lastError != nil {
+		jsonResult, err := json.MarshalIndent(lastError, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to format error details: %w", err)
+		}
+		message = fmt.Sprintf("Last error:\n%s", string(jsonResult))
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUniqueViolationCarriesExtendedCode(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, email TEXT UNIQUE)",
+		"INSERT INTO users VALUES (1, 'a@example.com')",
+	)
+
+	text := mustCall(t, srv, "get_last_error", map[string]interface{}{})
+	if !strings.Contains(text, "No tool call has failed") {
+		t.Fatalf("unexpected last error before any failure: %s", text)
+	}
+
+	_, err := callTool(t, srv, "execute", map[string]interface{}{"statement": "INSERT INTO users VALUES (2, 'a@example.com')"})
+	if err == nil || !strings.Contains(err.Error(), "[SQLITE_CONSTRAINT_UNIQUE 2067]") {
+		t.Fatalf("got %v, want the SQLITE_CONSTRAINT_UNIQUE code", err)
+	}
+
+	text = mustCall(t, srv, "get_last_error", map[string]interface{}{})
+	var last toolError
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(text, "Last error:\n")), &last); err != nil {
+		t.Fatalf("invalid last error %q: %v", text, err)
+	}
+	if last.Tool != "execute" || last.SQLite == nil {
+		t.Fatalf("unexpected last error: %s", text)
+	}
+	if last.SQLite.Code != 19 || last.SQLite.CodeName != "SQLITE_CONSTRAINT" ||
+		last.SQLite.ExtendedCode != 2067 || last.SQLite.ExtendedCodeName != "SQLITE_CONSTRAINT_UNIQUE" {
+		t.Fatalf("unexpected codes: %+v", last.SQLite)
+	}
+
+	// An error not coming from SQLite has no code
+	if _, err := callTool(t, srv, "describe_table", map[string]interface{}{}); err == nil {
+		t.Fatal("describe_table without a table succeeded")
+	}
+	text = mustCall(t, srv, "get_last_error", map[string]interface{}{})
+	if !strings.Contains(text, `"tool": "describe_table"`) || strings.Contains(text, `"sqlite"`) {
+		t.Fatalf("unexpected last error: %s", text)
+	}
+}
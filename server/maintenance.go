@@ -0,0 +1,189 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// maintenanceRetryDelay is how long a due maintenance task waits before trying again when a
+// tool call is in progress
+const maintenanceRetryDelay = time.Second
+
+// MaintenanceSchedule sets how often each maintenance task runs; zero disables a task
+type MaintenanceSchedule struct {
+	Vacuum     time.Duration
+	Analyze    time.Duration
+	Checkpoint time.Duration
+}
+
+// maintenanceTask is the state of one scheduled maintenance task
+type maintenanceTask struct {
+	Name     string        `json:"name"`
+	Interval string        `json:"interval"`
+	NextRun  time.Time     `json:"next_run"`
+	LastRun  *time.Time    `json:"last_run,omitempty"`
+	Duration string        `json:"last_duration,omitempty"`
+	Error    string        `json:"last_error,omitempty"`
+	Runs     int           `json:"runs"`
+	Deferred int           `json:"deferred"` // due runs postponed because a tool call was in progress
+	interval time.Duration // parsed form of Interval
+	run      func() error
+}
+
+// maintenance runs the scheduled tasks in the background
+type maintenance struct {
+	mu    sync.Mutex
+	tasks []*maintenanceTask
+	stop  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// SetMaintenanceSchedule starts running VACUUM, ANALYZE, and WAL checkpoints on the current
+// database at the given intervals. A task that falls due while a tool call is in progress is
+// postponed until no call is running, so maintenance never competes with a client request.
+// Calling it again replaces the previous schedule.
+func (s *SQLiteServer) SetMaintenanceSchedule(schedule MaintenanceSchedule) error {
+	for _, d := range []time.Duration{schedule.Vacuum, schedule.Analyze, schedule.Checkpoint} {
+		if d < 0 {
+			return fmt.Errorf("maintenance intervals cannot be negative")
+		}
+	}
+	if s.readOnly && (schedule.Vacuum > 0 || schedule.Analyze > 0 || schedule.Checkpoint > 0) {
+		return errReadOnly
+	}
+
+	s.stopMaintenance()
+
+	m := &maintenance{stop: make(chan struct{})}
+	now := time.Now()
+	add := func(name string, interval time.Duration, run func() error) {
+		if interval > 0 {
+			m.tasks = append(m.tasks, &maintenanceTask{
+				Name:     name,
+				Interval: interval.String(),
+				NextRun:  now.Add(interval),
+				interval: interval,
+				run:      run,
+			})
+		}
+	}
+	add("vacuum", schedule.Vacuum, func() error { return s.db.Vacuum() })
+	add("analyze", schedule.Analyze, func() error { return s.db.Analyze("") })
+	add("checkpoint", schedule.Checkpoint, func() error { return s.db.Checkpoint() })
+
+	s.maintenance = m
+	for _, task := range m.tasks {
+		m.wg.Add(1)
+		go s.runMaintenance(m, task)
+	}
+	return nil
+}
+
+// stopMaintenance stops the scheduler and waits for a running task to finish
+func (s *SQLiteServer) stopMaintenance() {
+	if s.maintenance == nil {
+		return
+	}
+	close(s.maintenance.stop)
+	s.maintenance.wg.Wait()
+	s.maintenance = nil
+}
+
+// runMaintenance runs task every time it falls due until the scheduler is stopped
+func (s *SQLiteServer) runMaintenance(m *maintenance, task *maintenanceTask) {
+	defer m.wg.Done()
+
+	m.mu.Lock()
+	wait := time.Until(task.NextRun)
+	m.mu.Unlock()
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-timer.C:
+		}
+
+		// Tool calls hold the read lock while they run
+		if !s.callMu.TryLock() {
+			m.mu.Lock()
+			task.Deferred++
+			wait = min(maintenanceRetryDelay, task.interval)
+			task.NextRun = time.Now().Add(wait)
+			m.mu.Unlock()
+			timer.Reset(wait)
+			continue
+		}
+
+		start := time.Now()
+		ran := s.db != nil
+		var err error
+		if ran {
+			err = task.run()
+		}
+		s.callMu.Unlock()
+
+		m.mu.Lock()
+		if ran {
+			task.LastRun = &start
+			task.Duration = time.Since(start).Round(time.Millisecond).String()
+			task.Error = ""
+			if err != nil {
+				task.Error = err.Error()
+			}
+			task.Runs++
+		}
+		task.NextRun = start.Add(task.interval)
+		wait = time.Until(task.NextRun)
+		m.mu.Unlock()
+		timer.Reset(wait)
+	}
+}
+
+// pauseMaintenance wraps a tool handler so that no maintenance task starts while it runs
+func (s *SQLiteServer) pauseMaintenance(handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		s.callMu.RLock()
+		defer s.callMu.RUnlock()
+		return handler(ctx, request)
+	}
+}
+
+// handleMaintenanceSchedule reports the scheduled maintenance tasks and when they last ran
+func (s *SQLiteServer) handleMaintenanceSchedule(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	if s.maintenance == nil || len(s.maintenance.tasks) == 0 {
+		return &mcp.CallToolResult{
+			Content: []mcp.Content{
+				mcp.TextContent{
+					Type: "text",
+					Text: "No maintenance is scheduled; start the server with --auto-vacuum-interval, --auto-analyze-interval, or --auto-checkpoint-interval",
+				},
+			},
+		}, nil
+	}
+
+	m := s.maintenance
+	m.mu.Lock()
+	jsonData, err := json.MarshalIndent(m.tasks, "", "  ")
+	m.mu.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal schedule: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Maintenance schedule:\n%s", string(jsonData)),
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// taskRuns returns how often the scheduled task name has run and been deferred
+func taskRuns(srv *SQLiteServer, name string) (runs, deferred int) {
+	m := srv.maintenance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, task := range m.tasks {
+		if task.Name == name {
+			return task.Runs, task.Deferred
+		}
+	}
+	return 0, 0
+}
+
+// waitForRun waits until the task name has run at least once
+func waitForRun(t *testing.T, srv *SQLiteServer, name string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if runs, _ := taskRuns(srv, name); runs > 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s never ran", name)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMaintenanceRunsAfterInterval(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (v INTEGER)", "CREATE INDEX idx_t ON t (v)", "INSERT INTO t VALUES (1), (2)")
+	if err := srv.SetMaintenanceSchedule(MaintenanceSchedule{Analyze: 300 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.stopMaintenance)
+
+	text := mustCall(t, srv, "maintenance_schedule", map[string]interface{}{})
+	if !strings.Contains(text, `"name": "analyze"`) || !strings.Contains(text, `"interval": "300ms"`) || strings.Contains(text, "last_run") {
+		t.Fatalf("unexpected schedule before the first run: %s", text)
+	}
+	if runs, _ := taskRuns(srv, "analyze"); runs != 0 {
+		t.Fatal("analyze ran before its interval")
+	}
+
+	waitForRun(t, srv, "analyze")
+	if n := countRows(t, srv, "sqlite_stat1"); n == 0 {
+		t.Fatal("ANALYZE left sqlite_stat1 empty")
+	}
+	text = mustCall(t, srv, "maintenance_schedule", map[string]interface{}{})
+	if !strings.Contains(text, "last_run") || strings.Contains(text, "last_error") {
+		t.Fatalf("unexpected schedule after the first run: %s", text)
+	}
+
+	// As on Close
+	srv.stopMaintenance()
+	if srv.maintenance != nil {
+		t.Fatal("the scheduler is still set after stopping")
+	}
+}
+
+func TestMaintenanceWaitsForActiveCall(t *testing.T) {
+	srv := newTestServer(t)
+	if err := srv.SetMaintenanceSchedule(MaintenanceSchedule{Checkpoint: 20 * time.Millisecond}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.stopMaintenance)
+
+	// A tool call in progress holds the read lock, as pauseMaintenance does
+	srv.callMu.RLock()
+	time.Sleep(200 * time.Millisecond)
+	runs, deferred := taskRuns(srv, "checkpoint")
+	srv.callMu.RUnlock()
+	if runs != 0 {
+		t.Fatalf("checkpoint ran %d times during a call", runs)
+	}
+	if deferred == 0 {
+		t.Fatal("the due checkpoint was not deferred")
+	}
+
+	waitForRun(t, srv, "checkpoint")
+}
+
+func TestMaintenanceScheduleRejects(t *testing.T) {
+	srv := newTestServer(t)
+	if err := srv.SetMaintenanceSchedule(MaintenanceSchedule{Vacuum: -time.Second}); err == nil {
+		t.Fatal("accepted a negative interval")
+	}
+	text := mustCall(t, srv, "maintenance_schedule", map[string]interface{}{})
+	if !strings.Contains(text, "No maintenance is scheduled") {
+		t.Fatalf("unexpected schedule: %s", text)
+	}
+}
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateFilePath(t *testing.T) {
+	root := t.TempDir()
+	data := filepath.Join(root, "data")
+	secret := filepath.Join(root, "data-secret")
+	for _, dir := range []string{data, secret} {
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(secret, filepath.Join(data, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(data, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(data, "sub"), filepath.Join(data, "inside")); err != nil {
+		t.Fatal(err)
+	}
+	srv := &SQLiteServer{allowedDirs: []string{data}}
+
+	for _, path := range []string{
+		filepath.Join(data, "a.db"),
+		filepath.Join(data, "sub", "new.db"),
+		filepath.Join(data, "inside", "b.db"),
+		data,
+	} {
+		if err := srv.validateFilePath(path); err != nil {
+			t.Errorf("%s rejected: %v", path, err)
+		}
+	}
+
+	for _, path := range []string{
+		filepath.Join(secret, "a.db"),
+		data + "-secret",
+		filepath.Join(data, "..", "etc", "passwd"),
+		data + "/../data/a.db",
+		filepath.Join(data, "escape", "a.db"),
+		filepath.Join(data, "escape"),
+		"/etc/passwd",
+	} {
+		if err := srv.validateFilePath(path); err == nil {
+			t.Errorf("%s accepted", path)
+		}
+	}
+}
+
+func TestValidateFilePathAllowedFile(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "only.db")
+	if err := os.WriteFile(allowed, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := &SQLiteServer{allowedDirs: []string{allowed}}
+
+	if err := srv.validateFilePath(allowed); err != nil {
+		t.Fatalf("allowed file rejected: %v", err)
+	}
+	for _, path := range []string{filepath.Join(dir, "other.db"), allowed + "-wal.db"} {
+		if err := srv.validateFilePath(path); err == nil {
+			t.Errorf("%s accepted", path)
+		}
+	}
+}
+
+// outsideWorkingDirectory runs the test from a new directory outside the allowed one, so a
+// path resolved against the working directory would land there
+func outsideWorkingDirectory(t *testing.T) string {
+	t.Helper()
+	cwd := t.TempDir()
+	t.Chdir(cwd)
+	return cwd
+}
+
+func TestBackupRelativeDestination(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER)")
+	cwd := outsideWorkingDirectory(t)
+	allowed := filepath.Dir(srv.db.GetCurrentDatabasePath())
+
+	text := mustCall(t, srv, "backup_database", map[string]interface{}{"destination": "copy.db"})
+	if !strings.Contains(text, filepath.Join(allowed, "copy.db")) {
+		t.Fatalf("backup not reported in the allowed directory: %s", text)
+	}
+	if _, err := os.Stat(filepath.Join(allowed, "copy.db")); err != nil {
+		t.Fatalf("backup not written to the allowed directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "copy.db")); err == nil {
+		t.Fatal("backup written to the working directory")
+	}
+
+	for _, destination := range []string{"../copy.db", "sub/../../copy.db", "test.db"} {
+		if _, err := callTool(t, srv, "backup_database", map[string]interface{}{"destination": destination, "overwrite": true}); err == nil {
+			t.Errorf("destination %s accepted", destination)
+		}
+	}
+}
+
+func TestImportCSVRelativePath(t *testing.T) {
+	srv := newTestServer(t)
+	cwd := outsideWorkingDirectory(t)
+	allowed := filepath.Dir(srv.db.GetCurrentDatabasePath())
+	if err := os.WriteFile(filepath.Join(cwd, "outside.csv"), []byte("secret\nleaked\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A file next to the working directory is not read
+	args := map[string]interface{}{"table_name": "leak", "csv_path": "outside.csv", "create_table": true}
+	if _, err := callTool(t, srv, "import_csv", args); err == nil {
+		t.Fatal("CSV file in the working directory was read")
+	}
+	if tableExists(t, srv, "leak") {
+		t.Fatal("table created from a file outside the allowed directory")
+	}
+
+	if err := os.WriteFile(filepath.Join(allowed, "inside.csv"), []byte("name\nann\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mustCall(t, srv, "import_csv", map[string]interface{}{"table_name": "people", "csv_path": "inside.csv", "create_table": true})
+	if n := countRows(t, srv, "people"); n != 1 {
+		t.Fatalf("imported %d rows, want 1", n)
+	}
+	if _, err := callTool(t, srv, "import_csv", map[string]interface{}{"table_name": "people", "csv_path": "../outside.csv"}); err == nil {
+		t.Fatal("path with .. accepted")
+	}
+}
+
+func TestImportJSONRelativeDestination(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER)", "INSERT INTO t VALUES (1)")
+	cwd := outsideWorkingDirectory(t)
+	allowed := filepath.Dir(srv.db.GetCurrentDatabasePath())
+	mustCall(t, srv, "export_json", map[string]interface{}{"file_name": "dump.json"})
+
+	mustCall(t, srv, "import_json", map[string]interface{}{"file_name": "dump.json", "destination": "restored.db"})
+	if _, err := os.Stat(filepath.Join(allowed, "restored.db")); err != nil {
+		t.Fatalf("database not created in the allowed directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "restored.db")); err == nil {
+		t.Fatal("database created in the working directory")
+	}
+
+	if _, err := callTool(t, srv, "import_json", map[string]interface{}{"file_name": "dump.json", "destination": "../escaped.db"}); err == nil {
+		t.Fatal("destination with .. accepted")
+	}
+}
+
+func TestRecoverRelativeDestination(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER)", "INSERT INTO t VALUES (1)")
+	cwd := outsideWorkingDirectory(t)
+	allowed := filepath.Dir(srv.db.GetCurrentDatabasePath())
+
+	mustCall(t, srv, "recover_database", map[string]interface{}{"destination": "recovered.db"})
+	if _, err := os.Stat(filepath.Join(allowed, "recovered.db")); err != nil {
+		t.Fatalf("recovery not written to the allowed directory: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cwd, "recovered.db")); err == nil {
+		t.Fatal("recovery written to the working directory")
+	}
+
+	for _, destination := range []string{"../recovered.db", "test.db"} {
+		if _, err := callTool(t, srv, "recover_database", map[string]interface{}{"destination": destination}); err == nil {
+			t.Errorf("destination %s accepted", destination)
+		}
+	}
+}
+
+func TestVerifyBackupRelativePath(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER)", "INSERT INTO t VALUES (1)")
+	cwd := outsideWorkingDirectory(t)
+	allowed := filepath.Dir(srv.db.GetCurrentDatabasePath())
+	mustCall(t, srv, "backup_database", map[string]interface{}{"destination": filepath.Join(allowed, "copy.db")})
+
+	text := mustCall(t, srv, "verify_backup", map[string]interface{}{"backup_path": "copy.db"})
+	if !strings.Contains(text, "faithful copy") {
+		t.Fatalf("backup in the allowed directory not verified: %s", text)
+	}
+
+	// A copy next to the working directory is not opened
+	data, err := os.ReadFile(filepath.Join(allowed, "copy.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(cwd, "outside.db"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := callTool(t, srv, "verify_backup", map[string]interface{}{"backup_path": "outside.db"}); err == nil {
+		t.Fatal("backup in the working directory was opened")
+	}
+	if _, err := os.Stat(filepath.Join(allowed, "outside.db")); err == nil {
+		t.Fatal("verifying a missing backup created it")
+	}
+}
+
+func TestResolveDataPath(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "only.db")
+	if err := os.WriteFile(allowed, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	srv := &SQLiteServer{allowedDirs: []string{allowed}}
+	outsideWorkingDirectory(t)
+
+	// Files beside an allowed database file are allowed, relative ones included
+	for path, want := range map[string]string{
+		"backup.db":                     filepath.Join(dir, "backup.db"),
+		filepath.Join(dir, "backup.db"): filepath.Join(dir, "backup.db"),
+		allowed:                         allowed,
+	} {
+		got, err := srv.resolveDataPath(path)
+		if err != nil || got != want {
+			t.Errorf("%s: got %q, %v, want %q", path, got, err, want)
+		}
+	}
+	for _, path := range []string{"sub/backup.db", "../backup.db", filepath.Join(filepath.Dir(dir), "backup.db")} {
+		if got, err := srv.resolveDataPath(path); err == nil {
+			t.Errorf("%s accepted as %s", path, got)
+		}
+	}
+}
+
+func TestDataFileDirectory(t *testing.T) {
+	dir := t.TempDir()
+	srv := &SQLiteServer{allowedDirs: []string{dir + "/"}}
+
+	for _, directory := range []string{"", ".", "./", dir, dir + "/"} {
+		got, err := srv.dataFileDirectory(directory)
+		if err != nil || strings.TrimSuffix(got, "/") != dir {
+			t.Errorf("%q: got %q, %v", directory, got, err)
+		}
+	}
+	for _, directory := range []string{"sub", filepath.Dir(dir), dir + "-secret"} {
+		if _, err := srv.dataFileDirectory(directory); err == nil {
+			t.Errorf("%q accepted", directory)
+		}
+	}
+}
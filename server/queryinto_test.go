@@ -0,0 +1,61 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestQueryIntoTable(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE orders (id INTEGER, region TEXT, total REAL)",
+		"INSERT INTO orders VALUES (1, 'north', 10), (2, 'south', 20), (3, 'north', 30)",
+	)
+
+	// Create mode builds the table from the query
+	mustCall(t, srv, "query_into_table", map[string]interface{}{
+		"query":             "SELECT region, SUM(total) AS total FROM orders GROUP BY region",
+		"destination_table": "region_totals",
+	})
+	if n := countRows(t, srv, "region_totals"); n != 2 {
+		t.Fatalf("created %d rows, want 2", n)
+	}
+	if _, err := callTool(t, srv, "query_into_table", map[string]interface{}{
+		"query":             "SELECT region, total FROM orders",
+		"destination_table": "region_totals",
+	}); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("created over an existing table: %v", err)
+	}
+
+	// Append mode adds to it
+	mustCall(t, srv, "query_into_table", map[string]interface{}{
+		"query":             "SELECT region, total FROM orders WHERE id = 1",
+		"destination_table": "region_totals",
+		"mode":              "append",
+	})
+	rows, err := srv.db.ExecuteQuery("SELECT total FROM region_totals WHERE region = 'north' ORDER BY total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 2 || rows[0]["total"] != float64(10) || rows[1]["total"] != float64(40) {
+		t.Fatalf("north rows %v", rows)
+	}
+	if _, err := callTool(t, srv, "query_into_table", map[string]interface{}{
+		"query":             "SELECT 1",
+		"destination_table": "missing",
+		"mode":              "append",
+	}); err == nil {
+		t.Fatal("appended to a missing table")
+	}
+
+	for _, query := range []string{"DELETE FROM orders", "SELECT 1; DROP TABLE orders"} {
+		if _, err := callTool(t, srv, "query_into_table", map[string]interface{}{
+			"query":             query,
+			"destination_table": "x",
+		}); err == nil {
+			t.Errorf("accepted %q", query)
+		}
+	}
+	if countRows(t, srv, "orders") != 3 {
+		t.Fatal("orders changed")
+	}
+}
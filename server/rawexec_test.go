@@ -0,0 +1,36 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRawExec(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER, name TEXT)", "INSERT INTO t VALUES (1, 'a'), (2, 'b')")
+
+	if _, err := callTool(t, srv, "raw_exec", map[string]interface{}{"statement": "SELECT 1"}); err == nil {
+		t.Fatal("raw_exec runs without --allow-raw")
+	}
+	srv.SetAllowRaw(true)
+
+	// A statement producing rows returns them
+	text := mustCall(t, srv, "raw_exec", map[string]interface{}{"statement": "PRAGMA table_info(t)"})
+	if !strings.Contains(text, "Statement returned 2 rows") || !strings.Contains(text, `"name": "name"`) {
+		t.Fatalf("unexpected result: %s", text)
+	}
+
+	// One that doesn't returns the change counters
+	text = mustCall(t, srv, "raw_exec", map[string]interface{}{"statement": "UPDATE t SET name = 'z'"})
+	if !strings.Contains(text, "Rows affected: 2") {
+		t.Fatalf("unexpected result: %s", text)
+	}
+	text = mustCall(t, srv, "raw_exec", map[string]interface{}{"statement": "CREATE TABLE u (id INTEGER)"})
+	if !strings.Contains(text, "Rows affected: 0") || !tableExists(t, srv, "u") {
+		t.Fatalf("unexpected result: %s", text)
+	}
+
+	srv.SetAllowRaw(false)
+	if _, err := callTool(t, srv, "raw_exec", map[string]interface{}{"statement": "SELECT 1"}); err == nil {
+		t.Fatal("raw_exec still runs after it was disabled")
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// errReadOnly is returned by tools that would modify a database while the server is read-only
+var errReadOnly = errors.New("server is in read-only mode")
+
+// readTools are the tools that never modify a database or write files. Every other tool
+// counts as writing, so that a tool missing from this list is refused in read-only mode
+// rather than let through. Tools that only write with some arguments are decided by
+// writesDatabase.
+var readTools = map[string]bool{
+	"query":                  true,
+	"query_stream":           true,
+	"query_snapshot":         true,
+	"list_tables":            true,
+	"describe_table":         true,
+	"get_table_ddl":          true,
+	"get_rowid_column":       true,
+	"detect_keys":            true,
+	"describe_relationships": true,
+	"export_ddl":             true,
+	"dump_schema":            true,
+	"validate_row":           true,
+	"query_audit":            true,
+	"list_views":             true,
+	"search_fts":             true,
+	"list_indexes":           true,
+	"analyze_query":          true,
+	"check_affinity":         true,
+	"trace_predicate":        true,
+	"suggest_indexes":        true,
+	"list_functions":         true,
+	"analyze_script":         true,
+	"benchmark_query":        true,
+	"estimate_cardinality":   true,
+	"profile_workload":       true,
+	"database_stats":         true,
+	"get_last_error":         true,
+	"verify_backup":          true,
+	"integrity_check":        true,
+	"list_foreign_keys":      true,
+	"check_foreign_keys":     true,
+	"maintenance_schedule":   true,
+	"get_user_version":       true,
+	"migration_status":       true,
+	"storage_breakdown":      true,
+	"pool_stats":             true,
+	"quote_identifier":       true,
+	"database_exists":        true,
+	"switch_database":        true,
+	"detach_database":        true,
+	"list_scratch":           true,
+	"current_database":       true,
+	"list_database_files":    true,
+	// These only tune how connections run, not what the database holds
+	"temp_storage":   true,
+	"threading_mode": true,
+	"cache_stats":    true,
+}
+
+// writesDatabase reports whether a call of the named tool with these arguments modifies the
+// database or writes a file
+func writesDatabase(name string, args map[string]interface{}) bool {
+	switch name {
+	case "infer_table_from_query":
+		execute, _ := args["execute"].(bool)
+		return execute
+	case "auto_index":
+		create, _ := args["create"].(bool)
+		return create
+	case "diff_query_result":
+		update, _ := args["update"].(bool)
+		return update
+	}
+	return !readTools[name]
+}
+
+// SetReadOnly rejects every tool call that would modify the database. The database is also
+// opened with mode=ro, so SQLite itself refuses writes that get past the tool checks.
+func (s *SQLiteServer) SetReadOnly(readOnly bool) error {
+	if s.db != nil {
+		if err := s.db.SetReadOnly(readOnly); err != nil {
+			return err
+		}
+	}
+	s.readOnly = readOnly
+
+	// Register the tools again so write tools are described as unavailable
+	s.registerHandlers()
+	s.SetAllowRaw(s.allowRaw)
+	return nil
+}
+
+// rejectWrites wraps a tool handler so that calls which would modify the database fail
+// while the server is read-only
+func (s *SQLiteServer) rejectWrites(name string, handler server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.readOnly {
+			args, _ := request.Params.Arguments.(map[string]interface{})
+			if writesDatabase(name, args) {
+				return nil, errReadOnly
+			}
+		}
+		return handler(ctx, request)
+	}
+}
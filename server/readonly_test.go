@@ -0,0 +1,60 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadOnlyRefusesWritingTools(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t (id INTEGER)")
+	srv.SetAllowRaw(true)
+	if err := srv.SetReadOnly(true); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Dir(srv.db.GetCurrentDatabasePath())
+
+	calls := []struct {
+		tool string
+		args map[string]interface{}
+	}{
+		{"backup_database", map[string]interface{}{"destination": filepath.Join(dir, "backup.db"), "overwrite": true}},
+		{"backup_database", map[string]interface{}{"destination": filepath.Join(dir, "new-backup.db")}},
+		{"recover_database", map[string]interface{}{"destination": filepath.Join(dir, "recovered.db")}},
+		{"export_json", map[string]interface{}{"path": filepath.Join(dir, "dump.json")}},
+		{"export_parquet", map[string]interface{}{"query": "SELECT * FROM t", "filename": "t.parquet"}},
+		{"create_scratch", map[string]interface{}{"alias": "s"}},
+		{"drop_scratch", map[string]interface{}{"alias": "s"}},
+		{"attach_database", map[string]interface{}{"db_path": srv.db.GetCurrentDatabasePath(), "alias": "y"}},
+		{"set_foreign_keys", map[string]interface{}{"enabled": false}},
+		{"execute", map[string]interface{}{"statement": "DELETE FROM t"}},
+	}
+	for _, call := range calls {
+		if _, err := callTool(t, srv, call.tool, call.args); err == nil || !strings.Contains(err.Error(), errReadOnly.Error()) {
+			t.Errorf("%s: got %v, want %v", call.tool, err, errReadOnly)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "new-backup.db")); err == nil {
+		t.Error("backup file written in read-only mode")
+	}
+
+	if _, err := callTool(t, srv, "raw_exec", map[string]interface{}{"statement": "DELETE FROM t"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("raw_exec is registered in read-only mode: %v", err)
+	}
+	mustCall(t, srv, "query", map[string]interface{}{"query": "SELECT * FROM t"})
+}
+
+func TestReadToolsAreRegistered(t *testing.T) {
+	srv := newTestServer(t)
+	registered := make(map[string]bool)
+	for _, name := range srv.toolNames {
+		registered[name] = true
+	}
+	for name := range readTools {
+		if !registered[name] {
+			t.Errorf("read tool %s is not a registered tool", name)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRedactionFollowsSourceColumns(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, user_ssn TEXT)",
+		"INSERT INTO users VALUES (1, 'ann', '123-45-6789'), (2, 'bob', '987-65-4321')",
+	)
+	if err := srv.SetRedactionPatterns([]string{"*_ssn"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{
+		"SELECT user_ssn AS x FROM users",
+		"SELECT name FROM users UNION ALL SELECT user_ssn FROM users",
+		"SELECT name FROM users UNION SELECT user_ssn FROM users",
+		"SELECT name FROM users UNION ALL SELECT user_ssn FROM users ORDER BY 1",
+		"SELECT max(user_ssn) AS m FROM users",
+		"SELECT user_ssn || '' AS s FROM users",
+		"SELECT upper(substr(user_ssn, 1, 3)) AS prefix FROM users",
+		"SELECT group_concat(user_ssn) AS all_ssn FROM users GROUP BY name",
+		"SELECT x FROM (SELECT user_ssn AS x FROM users ORDER BY name)",
+		"SELECT (SELECT user_ssn FROM users WHERE id = 1) AS s",
+	} {
+		text := mustCall(t, srv, "query", map[string]interface{}{"query": query})
+		if strings.Contains(text, "45-67") || strings.Contains(text, "65-43") {
+			t.Errorf("%s leaked a value: %s", query, text)
+		}
+	}
+
+	text := mustCall(t, srv, "query", map[string]interface{}{"query": "SELECT name, id FROM users ORDER BY name"})
+	if !strings.Contains(text, "ann") || !strings.Contains(text, "bob") {
+		t.Errorf("unrelated columns were masked: %s", text)
+	}
+}
+
+func TestQueryAuditRedactsRecordedValues(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, user_ssn TEXT)")
+	if err := srv.SetRedactionPatterns([]string{"*_ssn"}); err != nil {
+		t.Fatal(err)
+	}
+	mustCall(t, srv, "enable_audit", map[string]interface{}{"table_name": "users"})
+	mustCall(t, srv, "execute", map[string]interface{}{"statement": "INSERT INTO users VALUES (1, 'ann', '123-45-6789')"})
+	mustCall(t, srv, "execute", map[string]interface{}{"statement": "UPDATE users SET user_ssn = '987-65-4321' WHERE id = 1"})
+
+	text := mustCall(t, srv, "query_audit", map[string]interface{}{"table_name": "users"})
+	if strings.Contains(text, "45-67") || strings.Contains(text, "65-43") {
+		t.Fatalf("audit entries leaked a value: %s", text)
+	}
+	if !strings.Contains(text, "ann") {
+		t.Fatalf("unrelated values were masked: %s", text)
+	}
+}
+
+func TestRedactJSONObject(t *testing.T) {
+	patterns := []string{"*_ssn"}
+	for text, want := range map[string]string{
+		`{"id":12345678901234567890,"user_ssn":"1"}`: `{"id":12345678901234567890,"user_ssn":"***"}`,
+		`{"id":1,"user_ssn":null}`:                   `{"id":1,"user_ssn":null}`,
+		`not json`:                                   `***`,
+	} {
+		if got := redactJSONObject(text, patterns); got != want {
+			t.Errorf("redactJSONObject(%s) = %s, want %s", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExecuteReturning(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT)")
+
+	text := mustCall(t, srv, "execute", map[string]interface{}{
+		"statement": "INSERT INTO people (name) VALUES (?), (?) RETURNING id, name",
+		"params":    []interface{}{"ann", "bob"},
+	})
+	summary, body, ok := strings.Cut(text, ":\n")
+	if !ok || summary != "Statement executed successfully and returned 2 rows" {
+		t.Fatalf("unexpected result: %s", text)
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal([]byte(body), &rows); err != nil {
+		t.Fatalf("rows are not JSON: %v\n%s", err, body)
+	}
+	if len(rows) != 2 || rows[0]["id"] != float64(1) || rows[0]["name"] != "ann" || rows[1]["id"] != float64(2) || rows[1]["name"] != "bob" {
+		t.Fatalf("got rows %v", rows)
+	}
+	if n := countRows(t, srv, "people"); n != 2 {
+		t.Fatalf("inserted %d rows, want 2", n)
+	}
+
+	// A RETURNING statement that matches nothing returns an empty result set
+	text = mustCall(t, srv, "execute", map[string]interface{}{"statement": "DELETE FROM people WHERE id = 9 RETURNING id"})
+	if !strings.HasSuffix(text, "returned 0 rows:\n[]") {
+		t.Fatalf("unexpected result: %s", text)
+	}
+}
+
+func TestExecuteWithoutReturning(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE people (id INTEGER PRIMARY KEY, name TEXT)", "INSERT INTO people (name) VALUES ('ann')")
+
+	text := mustCall(t, srv, "execute", map[string]interface{}{"statement": "INSERT INTO people (name) VALUES ('bob')"})
+	if !strings.Contains(text, "Insert successful. Last insert ID: 2") {
+		t.Fatalf("unexpected result: %s", text)
+	}
+	text = mustCall(t, srv, "execute", map[string]interface{}{"statement": "UPDATE people SET name = upper(name)"})
+	if !strings.Contains(text, "Statement executed successfully. Rows affected: 2") {
+		t.Fatalf("unexpected result: %s", text)
+	}
+	// A column named returning is no RETURNING clause
+	mustCall(t, srv, "execute", map[string]interface{}{"statement": "ALTER TABLE people ADD COLUMN \"returning\" TEXT"})
+	text = mustCall(t, srv, "execute", map[string]interface{}{"statement": "UPDATE people SET \"returning\" = 'x' WHERE id = 1"})
+	if !strings.Contains(text, "Rows affected: 1") {
+		t.Fatalf("unexpected result: %s", text)
+	}
+}
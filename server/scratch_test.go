@@ -0,0 +1,59 @@
+package server
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestScratchDatabaseOnSwitch(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE orders (id INTEGER PRIMARY KEY)", "INSERT INTO orders VALUES (1), (2)")
+	other := filepath.Join(filepath.Dir(srv.db.GetCurrentDatabasePath()), "other.db")
+	file, err := sql.Open("sqlite3", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.Exec("CREATE TABLE t (v)"); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	mustCall(t, srv, "create_scratch", map[string]interface{}{"alias": "kept", "keep_on_switch": true})
+	mustCall(t, srv, "create_scratch", map[string]interface{}{"alias": "work"})
+	for _, statement := range []string{
+		"CREATE TABLE kept.ids AS SELECT id FROM orders",
+		"CREATE TABLE work.ids AS SELECT id FROM orders",
+	} {
+		mustCall(t, srv, "execute", map[string]interface{}{"statement": statement})
+	}
+	text := mustCall(t, srv, "list_scratch", map[string]interface{}{})
+	if !strings.Contains(text, `"alias": "kept"`) || !strings.Contains(text, `"alias": "work"`) || !strings.Contains(text, `"ids"`) {
+		t.Fatalf("unexpected scratch list: %s", text)
+	}
+
+	mustCall(t, srv, "switch_database", map[string]interface{}{"db_path": other})
+	text = mustCall(t, srv, "query", map[string]interface{}{"query": "SELECT count(*) AS n FROM kept.ids"})
+	if !strings.Contains(text, `"n": 2`) {
+		t.Fatalf("the kept scratch table lost its rows: %s", text)
+	}
+	if _, err := callTool(t, srv, "query", map[string]interface{}{"query": "SELECT * FROM work.ids"}); err == nil {
+		t.Fatal("a scratch database not kept on switch is still attached")
+	}
+
+	// Nothing of the scratch databases is written to either file
+	for _, path := range []string{other, filepath.Join(filepath.Dir(other), "test.db")} {
+		file, err := sql.Open("sqlite3", path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		err = file.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'ids'").Scan(&n)
+		file.Close()
+		if err != nil || n != 0 {
+			t.Fatalf("%s has %d ids tables, %v", path, n, err)
+		}
+	}
+}
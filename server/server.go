@@ -3,6 +3,8 @@ package server
 import (
 	"context"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/liliang-cn/mcp-sqlite-server/database"
 
@@ -15,6 +17,30 @@ type SQLiteServer struct {
 	db          *database.SQLiteDB
 	dbPath      string
 	allowedDirs []string
+	allowRaw    bool
+	readOnly    bool // reject tools that modify the database, see SetReadOnly
+	maxRows     int  // 0 means no row limit
+	maxCells    int  // 0 means no cell (rows x columns) limit
+
+	maxTxStatements int // 0 means no limit on statements per transaction
+
+	redactPatterns []string // column name globs whose values are masked in results
+
+	allowedTables map[string]bool // nil means every table not denied is accessible
+	deniedTables  map[string]bool
+
+	maxCallDuration time.Duration // 0 means tool calls have no time budget
+
+	lastErrorMu sync.Mutex
+	lastError   *toolError // most recent failed tool call, reported by get_last_error
+
+	toolNames     []string        // every tool the server can offer, in registration order
+	disabledTools map[string]bool // tools removed by SetToolFilter
+
+	attached map[string]string // alias -> path of databases attached with attach_database
+
+	callMu      sync.RWMutex // held for reading by running tool calls, see pauseMaintenance
+	maintenance *maintenance // background maintenance tasks, see SetMaintenanceSchedule
 }
 
 // NewSQLiteServer creates a new SQLite MCP server
@@ -81,10 +107,187 @@ func (s *SQLiteServer) SetAllowedDirs(dirs []string) {
 	s.allowedDirs = dirs
 }
 
+// SetResultLimits sets how many rows and how many cells (rows x columns) the query tool
+// returns before truncating its results. Zero disables the corresponding limit.
+func (s *SQLiteServer) SetResultLimits(maxRows, maxCells int) {
+	s.maxRows = maxRows
+	s.maxCells = maxCells
+}
+
+// SetRedactionPatterns masks the values of result columns whose name, or whose source column,
+// matches one of the case-insensitive glob patterns, e.g. "*_ssn" or "password*". Stored data
+// is not changed.
+func (s *SQLiteServer) SetRedactionPatterns(patterns []string) error {
+	if err := validateRedactionPatterns(patterns); err != nil {
+		return err
+	}
+	s.redactPatterns = patterns
+	return nil
+}
+
+// SetMaxTransactionStatements caps the number of statements accepted by the transaction tool.
+// Zero disables the limit.
+func (s *SQLiteServer) SetMaxTransactionStatements(max int) {
+	s.maxTxStatements = max
+}
+
+// SetMaxResultBytes sets the approximate memory budget of the rows a query reads; larger
+// results are aborted with an error. Zero disables the budget. It is a no-op while no
+// database is open.
+func (s *SQLiteServer) SetMaxResultBytes(limit int64) {
+	if s.db != nil {
+		s.db.SetMaxResultBytes(limit)
+	}
+}
+
+// SetJournalSizeLimit applies PRAGMA journal_size_limit to every connection of the current
+// database and to databases switched to later. It is a no-op while no database is open.
+func (s *SQLiteServer) SetJournalSizeLimit(limit int64) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.SetJournalSizeLimit(limit)
+}
+
+// SetTempStorage applies the temp_store mode and temp file directory to every connection of
+// the current database and to databases switched to later. It is a no-op while no database is open.
+func (s *SQLiteServer) SetTempStorage(store, directory string) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.SetTempStorage(store, directory)
+}
+
+// SetSerialized limits the connection pool of the current database, and of databases switched
+// to later, to a single connection. It is a no-op while no database is open.
+func (s *SQLiteServer) SetSerialized(serialized bool) {
+	if s.db != nil {
+		s.db.SetSerialized(serialized)
+	}
+}
+
+// SetBusyTimeout sets how long statements on the current database, and on databases switched
+// to later, wait for locks held by other connections. It is a no-op while no database is open.
+func (s *SQLiteServer) SetBusyTimeout(timeout time.Duration) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.SetBusyTimeout(timeout)
+}
+
+// SetMaxOpenDatabases bounds the number of databases, the current one included, whose
+// connection pools stay open for switching back to them. It is a no-op while no database is open.
+func (s *SQLiteServer) SetMaxOpenDatabases(n int) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.SetMaxOpenDatabases(n)
+}
+
+// SetEnabledFunctions limits the custom SQL functions available in queries to the named ones.
+// It is a no-op while no database is open.
+func (s *SQLiteServer) SetEnabledFunctions(names []string) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.SetEnabledFunctions(names)
+}
+
+// addTool registers a tool unless the operator disabled it, and remembers its name so
+// SetToolFilter can validate tool names
+func (s *SQLiteServer) addTool(tool mcp.Tool, handler server.ToolHandlerFunc) {
+	known := false
+	for _, name := range s.toolNames {
+		if name == tool.Name {
+			known = true
+			break
+		}
+	}
+	if !known {
+		s.toolNames = append(s.toolNames, tool.Name)
+	}
+
+	if s.disabledTools[tool.Name] {
+		return
+	}
+	// Tools that write whatever their arguments are
+	if s.readOnly && writesDatabase(tool.Name, nil) {
+		tool.Description = "Unavailable: the server is in read-only mode. " + tool.Description
+	}
+	s.server.AddTool(tool, s.trackErrors(tool.Name, s.limitDuration(tool.Name, s.rejectWrites(tool.Name, s.rejectAttach(s.restrictTables(s.pauseMaintenance(handler)))))))
+}
+
+// SetToolFilter restricts which tools are offered to clients. If enable is non-empty only the
+// named tools stay registered; tools named in disable are removed. Unknown names are an error.
+func (s *SQLiteServer) SetToolFilter(enable, disable []string) error {
+	known := make(map[string]bool)
+	for _, name := range s.toolNames {
+		known[name] = true
+	}
+	// raw_exec is only registered with --allow-raw but is still a valid name
+	known["raw_exec"] = true
+
+	for _, name := range append(append([]string{}, enable...), disable...) {
+		if !known[name] {
+			return fmt.Errorf("unknown tool '%s'", name)
+		}
+	}
+
+	disabled := make(map[string]bool)
+	if len(enable) > 0 {
+		enabled := make(map[string]bool)
+		for _, name := range enable {
+			enabled[name] = true
+		}
+		for name := range known {
+			if !enabled[name] {
+				disabled[name] = true
+			}
+		}
+	}
+	for _, name := range disable {
+		disabled[name] = true
+	}
+
+	var remove []string
+	for name := range disabled {
+		remove = append(remove, name)
+	}
+	s.server.DeleteTools(remove...)
+	s.disabledTools = disabled
+
+	return nil
+}
+
+// SetAllowRaw enables or disables the raw_exec tool, which runs statements without any validation
+func (s *SQLiteServer) SetAllowRaw(allow bool) {
+	s.allowRaw = allow
+	// Nothing raw_exec runs is checked, so it is not offered at all in read-only mode
+	if !allow || s.readOnly {
+		s.server.DeleteTools("raw_exec")
+		return
+	}
+
+	s.addTool(mcp.Tool{
+		Name:        "raw_exec",
+		Description: "Run any SQL statement exactly as written, returning rows if it produces any, otherwise affected row counts (no SELECT/execute routing or guards). ATTACH and DETACH are refused; use attach_database and detach_database",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"statement": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL statement to run",
+				},
+			},
+			Required: []string{"statement"},
+		},
+	}, s.handleRawExec)
+}
+
 // registerHandlers registers all tool handlers
 func (s *SQLiteServer) registerHandlers() {
 	// Add tools
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
 		Name:        "query",
 		Description: "Execute a SELECT query on the SQLite database",
 		InputSchema: mcp.ToolInputSchema{
@@ -94,14 +297,135 @@ func (s *SQLiteServer) registerHandlers() {
 					"type":        "string",
 					"description": "SQL SELECT query to execute",
 				},
+				"params": map[string]interface{}{
+					"type":        "array",
+					"description": "Values bound to the ? placeholders of the query, in order",
+					"items": map[string]interface{}{
+						"type": []string{"string", "number", "boolean", "null", "object"},
+					},
+				},
+				"named_params": map[string]interface{}{
+					"type":        "object",
+					"description": "Values bound to the :name, @name, or $name parameters of the query by name, e.g. {\"min\": 1} for :min. Every named parameter needs an entry",
+					"additionalProperties": map[string]interface{}{
+						"type": []string{"string", "number", "boolean", "null", "object"},
+					},
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of rows to return (default 1000). The query must not have its own LIMIT",
+				},
+				"offset": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of rows to skip before the returned page (default 0)",
+				},
+				"format": map[string]interface{}{
+					"type":        "string",
+					"enum":        []string{"json", "csv"},
+					"description": "Result format: json (default) or csv with a header row, NULL as an empty field",
+				},
+				"group_by_key": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional column name; rows are returned as an object mapping each value of this column to its rows",
+				},
+				"include_provenance": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Wrap the result as {\"columns\": [...], \"rows\": ...}, where columns gives each result column's source table, column, and declared type (empty for computed columns)",
+				},
+				"fixed_reals": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Write REAL values as plain decimals (e.g. 1000000000000000000000 instead of 1e+21) using the fewest digits that represent them exactly",
+				},
+				"real_precision": map[string]interface{}{
+					"type":        "integer",
+					"description": "Write REAL values as plain decimals rounded to this many decimal places (0-20); implies fixed_reals",
+				},
+				"number_format": map[string]interface{}{
+					"type":        "object",
+					"description": "Optional display formatting per column, e.g. {\"price\": {\"decimals\": 2, \"grouping\": true, \"locale\": \"de\", \"currency\": \"€\"}}",
+					"additionalProperties": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"decimals": map[string]interface{}{
+								"type":        "integer",
+								"description": "Fixed number of decimal places",
+							},
+							"grouping": map[string]interface{}{
+								"type":        "boolean",
+								"description": "Insert thousands separators (default true)",
+							},
+							"locale": map[string]interface{}{
+								"type":        "string",
+								"description": "Separator convention: en, de, fr, es, it, ch, in",
+							},
+							"currency": map[string]interface{}{
+								"type":        "string",
+								"description": "Currency symbol to add to the value",
+							},
+							"symbol_after": map[string]interface{}{
+								"type":        "boolean",
+								"description": "Place the currency symbol after the number",
+							},
+						},
+					},
+				},
 			},
 			Required: []string{"query"},
 		},
 	}, s.handleQueryTool)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
+		Name:        "query_stream",
+		Description: "Read the results of a large SELECT query in batches. Each call returns up to batch_size rows and, when more follow, a continuation_token for the next batch. Rows are streamed from SQLite, so memory use is bounded by the batch size however large the result; use ORDER BY for a stable order across batches",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL SELECT query to execute (omit when passing continuation_token)",
+				},
+				"params": map[string]interface{}{
+					"type":        "array",
+					"description": "Values bound to the ? placeholders of the query, in order",
+					"items": map[string]interface{}{
+						"type": []string{"string", "number", "boolean", "null", "object"},
+					},
+				},
+				"continuation_token": map[string]interface{}{
+					"type":        "string",
+					"description": "Token returned by the previous batch; fetches the next batch of the same query",
+				},
+				"batch_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of rows per batch (default 1000)",
+				},
+			},
+		},
+	}, s.handleQueryStream)
+
+	s.addTool(mcp.Tool{
+		Name:        "query_snapshot",
+		Description: "Run several SELECT queries against a copy of the database taken at call time, so every result reflects the same moment regardless of concurrent writes; the copy is deleted afterwards",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"queries": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": fmt.Sprintf("SELECT queries to run against the snapshot (at most %d); attached databases are not included in it", database.MaxSnapshotQueries),
+				},
+				"max_bytes": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Refuse to copy a database larger than this many bytes (default %d)", database.DefaultSnapshotBytes),
+				},
+			},
+			Required: []string{"queries"},
+		},
+	}, s.handleQuerySnapshot)
+
+	s.addTool(mcp.Tool{
 		Name:        "execute",
-		Description: "Execute an INSERT, UPDATE, or DELETE statement",
+		Description: "Execute an INSERT, UPDATE, or DELETE statement; with a RETURNING clause the returned rows are sent back as JSON",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
@@ -109,12 +433,91 @@ func (s *SQLiteServer) registerHandlers() {
 					"type":        "string",
 					"description": "SQL statement to execute",
 				},
+				"params": map[string]interface{}{
+					"type":        "array",
+					"description": "Values bound to the ? placeholders of the statement, in order",
+					"items": map[string]interface{}{
+						"type": []string{"string", "number", "boolean", "null", "object"},
+					},
+				},
+				"dry_run": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Run the statement and report the rows it would affect, then roll back so nothing is committed (default false)",
+				},
 			},
 			Required: []string{"statement"},
 		},
 	}, s.handleExecuteTool)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
+		Name:        "execute_many",
+		Description: "Execute one parameterized INSERT/UPDATE/DELETE statement once per parameter set, using a single prepared statement inside one transaction; much faster than separate execute calls for bulk changes",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"statement": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL statement with ? placeholders",
+				},
+				"param_sets": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "array"},
+					"description": fmt.Sprintf("Array of parameter arrays, one execution per array (at most %d)", database.MaxParameterSets),
+				},
+				"per_set_results": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Also report the rows affected by each parameter set (default false)",
+				},
+			},
+			Required: []string{"statement", "param_sets"},
+		},
+	}, s.handleExecuteMany)
+
+	s.addTool(mcp.Tool{
+		Name:        "upsert",
+		Description: "Insert a row, or update the existing row with the same values in the conflict columns, with INSERT ... ON CONFLICT DO UPDATE and bound parameters; reports whether the row was inserted or updated",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"row": map[string]interface{}{
+					"type":        "object",
+					"description": "Column name to value mapping of the row; columns other than the conflict columns are updated when the row exists",
+				},
+				"conflict_columns": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Columns identifying an existing row; they must be exactly the primary key or the columns of a unique index (see detect_keys)",
+				},
+			},
+			Required: []string{"table_name", "row", "conflict_columns"},
+		},
+	}, s.handleUpsert)
+
+	s.addTool(mcp.Tool{
+		Name:        "insert_rows",
+		Description: "Insert many rows into a table with multi-row INSERT statements inside one transaction; much faster than one execute call per row. Every row must have the same columns",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"rows": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "object"},
+					"description": fmt.Sprintf("Column name to value objects, one per row, all with the same columns (at most %d rows)", database.MaxInsertRows),
+				},
+			},
+			Required: []string{"table_name", "rows"},
+		},
+	}, s.handleInsertRows)
+
+	s.addTool(mcp.Tool{
 		Name:        "create_table",
 		Description: "Create a new table in the database",
 		InputSchema: mcp.ToolInputSchema{
@@ -151,18 +554,27 @@ func (s *SQLiteServer) registerHandlers() {
 		},
 	}, s.handleCreateTableTool)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
 		Name:        "list_tables",
 		Description: "List all tables in the database",
 		InputSchema: mcp.ToolInputSchema{
-			Type:       "object",
-			Properties: map[string]interface{}{},
+			Type: "object",
+			Properties: map[string]interface{}{
+				"include_views": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Also list the views (default false)",
+				},
+				"with_stats": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Return JSON with each table's row count and size in bytes, exact from dbstat or estimated, instead of a list of names (default false). Counts are reused for up to 30 seconds",
+				},
+			},
 		},
 	}, s.handleListTablesTool)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
 		Name:        "describe_table",
-		Description: "Get the schema of a specific table",
+		Description: "Get the schema of a specific table as {\"columns\", \"foreign_keys\", \"indexes\"}: each column flagged for primary key, foreign key, index, NOT NULL, and default, every foreign key with its referenced table and columns and ON UPDATE/ON DELETE actions, and every index with its columns and uniqueness",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
@@ -175,145 +587,1067 @@ func (s *SQLiteServer) registerHandlers() {
 		},
 	}, s.handleDescribeTableTool)
 
-	s.server.AddTool(mcp.Tool{
-		Name:        "transaction",
-		Description: "Execute multiple SQL statements in a transaction (INSERT/UPDATE/DELETE only, no SELECT)",
+	s.addTool(mcp.Tool{
+		Name:        "get_table_ddl",
+		Description: "Get the SQL script that recreates a table together with its indexes and triggers",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
-				"statements": map[string]interface{}{
-					"type":        "array",
-					"description": "Array of SQL statements to execute atomically (INSERT, UPDATE, DELETE only)",
-					"items": map[string]interface{}{
-						"type": "string",
-					},
-					"minItems": 1,
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
 				},
 			},
-			Required: []string{"statements"},
+			Required: []string{"table_name"},
 		},
-	}, s.handleTransactionTool)
+	}, s.handleGetTableDDL)
 
-	s.server.AddTool(mcp.Tool{
-		Name:        "drop_table",
-		Description: "Drop a table from the database",
+	s.addTool(mcp.Tool{
+		Name:        "get_rowid_column",
+		Description: "Report whether a table is WITHOUT ROWID and which column addresses its rows: the INTEGER PRIMARY KEY column aliasing rowid, or rowid itself. Useful for keyset pagination and targeted updates",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
 				"table_name": map[string]interface{}{
 					"type":        "string",
-					"description": "Name of the table to drop",
+					"description": "Name of the table",
 				},
 			},
 			Required: []string{"table_name"},
 		},
-	}, s.handleDropTableTool)
+	}, s.handleGetRowidColumn)
 
-	s.server.AddTool(mcp.Tool{
-		Name:        "create_index",
-		Description: "Create an index on a table column(s) with advanced options",
+	s.addTool(mcp.Tool{
+		Name:        "detect_keys",
+		Description: "Report a table's primary key and candidate keys from unique constraints and unique indexes, the natural key if one exists, and the ON CONFLICT target to use for upserts and deduplication",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
-				"index_name": map[string]interface{}{
-					"type":        "string",
-					"description": "Name of the index to create",
-				},
 				"table_name": map[string]interface{}{
 					"type":        "string",
 					"description": "Name of the table",
 				},
-				"columns": map[string]interface{}{
-					"type":        "array",
-					"description": "Array of column specifications",
-					"items": map[string]interface{}{
-						"type": "object",
-						"properties": map[string]interface{}{
-							"name": map[string]interface{}{
-								"type":        "string",
-								"description": "Column name",
-							},
-							"sort_order": map[string]interface{}{
-								"type":        "string",
-								"description": "Sort order (ASC or DESC)",
-								"enum":        []string{"ASC", "DESC"},
-							},
-						},
-						"required": []string{"name"},
-					},
-				},
-				"unique": map[string]interface{}{
-					"type":        "boolean",
-					"description": "Whether the index should be unique",
-				},
-				"if_not_exists": map[string]interface{}{
-					"type":        "boolean",
-					"description": "Only create index if it doesn't already exist",
-				},
-				"where_clause": map[string]interface{}{
+			},
+			Required: []string{"table_name"},
+		},
+	}, s.handleDetectKeys)
+
+	s.addTool(mcp.Tool{
+		Name:        "describe_relationships",
+		Description: "Describe all tables, their columns, and the foreign-key relationships between them as an entity-relationship model",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"format": map[string]interface{}{
 					"type":        "string",
-					"description": "Optional WHERE clause for partial indexes",
+					"description": "Output format: json (default) or mermaid (erDiagram text)",
+					"enum":        []string{"json", "mermaid"},
 				},
 			},
-			Required: []string{"index_name", "table_name", "columns"},
 		},
-	}, s.handleCreateIndexTool)
+	}, s.handleDescribeRelationships)
 
-	s.server.AddTool(mcp.Tool{
-		Name:        "list_indexes",
-		Description: "List all indexes for a table",
+	s.addTool(mcp.Tool{
+		Name:        "export_ddl",
+		Description: "Translate the database schema (tables, mapped column types, keys, foreign keys, indexes) into DDL for another engine as a starting point for a migration. Best effort: untranslated features are listed as comments",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
-				"table_name": map[string]interface{}{
+				"dialect": map[string]interface{}{
 					"type":        "string",
-					"description": "Name of the table",
+					"description": "Target engine (default postgres)",
+					"enum":        database.ExportDialects,
 				},
 			},
-			Required: []string{"table_name"},
 		},
-	}, s.handleListIndexesTool)
+	}, s.handleExportDDL)
 
-	s.server.AddTool(mcp.Tool{
-		Name:        "drop_index",
-		Description: "Drop an index from the database",
+	s.addTool(mcp.Tool{
+		Name:        "dump_schema",
+		Description: "Dump the database as an SQL script of CREATE TABLE/INDEX/VIEW/TRIGGER statements in dependency order that recreates an empty copy, optionally with INSERT statements for every row",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
-				"index_name": map[string]interface{}{
-					"type":        "string",
-					"description": "Name of the index to drop",
+				"include_data": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Also emit INSERT statements for the rows of every table (default false)",
 				},
 			},
-			Required: []string{"index_name"},
 		},
-	}, s.handleDropIndexTool)
+	}, s.handleDumpSchema)
 
-	s.server.AddTool(mcp.Tool{
-		Name:        "vacuum",
-		Description: "Optimize the database by rebuilding it",
+	s.addTool(mcp.Tool{
+		Name:        "validate_row",
+		Description: "Check whether a proposed row would insert cleanly into a table (unknown columns, missing NOT NULL values, type affinity, UNIQUE and CHECK constraints) without inserting it",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"row": map[string]interface{}{
+					"type":        "object",
+					"description": "Column name to value mapping of the proposed row",
+				},
+			},
+			Required: []string{"table_name", "row"},
+		},
+	}, s.handleValidateRow)
+
+	s.addTool(mcp.Tool{
+		Name:        "add_column",
+		Description: "Add a column to an existing table with ALTER TABLE ADD COLUMN",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"column_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the new column",
+				},
+				"type": map[string]interface{}{
+					"type":        "string",
+					"description": "Declared type, e.g. INTEGER, REAL, TEXT, or VARCHAR(100)",
+				},
+				"constraints": map[string]interface{}{
+					"type":        "string",
+					"description": "Column constraints, e.g. \"NOT NULL DEFAULT 0\" (a NOT NULL column needs a non-NULL default)",
+				},
+			},
+			Required: []string{"table_name", "column_name", "type"},
+		},
+	}, s.handleAddColumn)
+
+	s.addTool(mcp.Tool{
+		Name:        "drop_column",
+		Description: "Drop a column from a table, rebuilding the table on SQLite versions without ALTER TABLE DROP COLUMN",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"column_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Column to drop",
+				},
+			},
+			Required: []string{"table_name", "column_name"},
+		},
+	}, s.handleDropColumn)
+
+	s.addTool(mcp.Tool{
+		Name:        "rename_column",
+		Description: "Rename a table column and report views or triggers that reference it, optionally rewriting them",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"old_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Current column name",
+				},
+				"new_name": map[string]interface{}{
+					"type":        "string",
+					"description": "New column name",
+				},
+				"check_only": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only report the views and triggers that reference the column, without renaming",
+				},
+				"fix_dependents": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Rewrite and recreate views and triggers that still reference the old column name",
+				},
+			},
+			Required: []string{"table_name", "old_name", "new_name"},
+		},
+	}, s.handleRenameColumn)
+
+	s.addTool(mcp.Tool{
+		Name:        "rename_table",
+		Description: "Rename a table and verify that views, triggers, and foreign keys referencing it still resolve, optionally rewriting broken views and triggers",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"old_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Current table name",
+				},
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Alias of old_name, accepted for compatibility",
+				},
+				"new_name": map[string]interface{}{
+					"type":        "string",
+					"description": "New table name",
+				},
+				"check_only": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only report the views, triggers, and tables that reference the table, without renaming",
+				},
+				"fix_dependents": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Rewrite and recreate views and triggers that still reference the old table name",
+				},
+				"legacy_alter_table": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Rename with PRAGMA legacy_alter_table=ON, which leaves references in views and triggers unchanged (default false)",
+				},
+			},
+			Required: []string{"old_name", "new_name"},
+		},
+	}, s.handleRenameTable)
+
+	s.addTool(mcp.Tool{
+		Name:        "change_column_type",
+		Description: "Change a column's declared type by rebuilding the table in one transaction: values are converted with CAST, and indexes, triggers, and views on the table are recreated",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"column": map[string]interface{}{
+					"type":        "string",
+					"description": "Column whose type changes",
+				},
+				"new_type": map[string]interface{}{
+					"type":        "string",
+					"description": "New declared type, e.g. INTEGER, REAL, TEXT, or VARCHAR(100)",
+				},
+			},
+			Required: []string{"table_name", "column", "new_type"},
+		},
+	}, s.handleChangeColumnType)
+
+	s.addTool(mcp.Tool{
+		Name:        "add_column_constraint",
+		Description: "Add a NOT NULL and/or CHECK constraint to an existing column by rebuilding the table in one transaction; existing rows are checked first and the violating rows are listed if any fail, and indexes, triggers, and views on the table are recreated",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"column": map[string]interface{}{
+					"type":        "string",
+					"description": "Column to constrain",
+				},
+				"not_null": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Add a NOT NULL constraint",
+				},
+				"check": map[string]interface{}{
+					"type":        "string",
+					"description": "Expression for a CHECK constraint, e.g. \"price >= 0\"",
+				},
+			},
+			Required: []string{"table_name", "column"},
+		},
+	}, s.handleAddColumnConstraint)
+
+	s.addTool(mcp.Tool{
+		Name:        "transaction",
+		Description: "Execute multiple SQL statements atomically in one transaction and report the rows affected by each; rows of SELECT statements and RETURNING clauses are returned labeled by statement number, so values can be read and updated in the same transaction",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"statements": map[string]interface{}{
+					"type":        "array",
+					"description": "Array of SQL statements to execute atomically, in order",
+					"items": map[string]interface{}{
+						"type": "string",
+					},
+					"minItems": 1,
+				},
+				"dry_run": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Run the statements and report what they would do, then roll back so nothing is committed (default false)",
+				},
+			},
+			Required: []string{"statements"},
+		},
+	}, s.handleTransactionTool)
+
+	s.addTool(mcp.Tool{
+		Name:        "query_into_table",
+		Description: "Run a SELECT query and write its results into a new table or append them to an existing one",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL SELECT query whose results are written",
+				},
+				"destination_table": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table to write into",
+				},
+				"mode": map[string]interface{}{
+					"type":        "string",
+					"description": "create (default): create the table from the query, failing if it exists; append: insert into an existing table",
+					"enum":        []string{"create", "append"},
+				},
+			},
+			Required: []string{"query", "destination_table"},
+		},
+	}, s.handleQueryIntoTable)
+
+	s.addTool(mcp.Tool{
+		Name:        "infer_table_from_query",
+		Description: "Generate a CREATE TABLE statement whose columns match the result columns of a SELECT query, optionally creating the table. Columns keep their declared types; expression columns are typed from their values",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL SELECT query whose result columns define the table",
+				},
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table in the generated statement",
+				},
+				"execute": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Create the table (empty) instead of only returning the statement (default false)",
+				},
+			},
+			Required: []string{"query", "table_name"},
+		},
+	}, s.handleInferTableFromQuery)
+
+	s.addTool(mcp.Tool{
+		Name:        "export_parquet",
+		Description: "Write the results of a SELECT query to a Parquet file in an allowed directory for analysis in pandas, DuckDB, or Spark. Column types are inferred from the values (INT64, DOUBLE, STRING, BYTE_ARRAY) and NULLs are kept",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL SELECT query whose results are exported",
+				},
+				"params": map[string]interface{}{
+					"type":        "array",
+					"description": "Values bound to the ? placeholders of the query, in order",
+					"items": map[string]interface{}{
+						"type": []string{"string", "number", "boolean", "null", "object"},
+					},
+				},
+				"directory": map[string]interface{}{
+					"type":        "string",
+					"description": "Allowed directory to write the file to (default: the first allowed directory)",
+				},
+				"file_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the file; .parquet is added when it has no extension (default: export_<timestamp>.parquet)",
+				},
+				"overwrite": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace the file if it already exists (default false)",
+				},
+			},
+			Required: []string{"query"},
+		},
+	}, s.handleExportParquet)
+
+	s.addTool(mcp.Tool{
+		Name:        "import_parquet",
+		Description: "Load a Parquet file from an allowed directory into a table, creating the table from the file's schema if it does not exist. Rows are inserted in batched transactions; Parquet types map to INTEGER, REAL, TEXT (strings, dates, timestamps), or BLOB",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"file_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the Parquet file",
+				},
+				"directory": map[string]interface{}{
+					"type":        "string",
+					"description": "Allowed directory containing the file (default: the first allowed directory)",
+				},
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Table to load the rows into; every Parquet column must exist in it if it already exists",
+				},
+				"batch_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "Rows inserted per transaction (default 1000)",
+				},
+			},
+			Required: []string{"file_name", "table_name"},
+		},
+	}, s.handleImportParquet)
+
+	s.addTool(mcp.Tool{
+		Name:        "import_csv",
+		Description: "Load a CSV file from an allowed directory into a table in one transaction, optionally creating the table with INTEGER, REAL, or TEXT columns inferred from the first rows. Quoted fields may span lines; empty fields become NULL. Parse errors name the line",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Table to load the rows into",
+				},
+				"csv_path": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of the CSV file in an allowed directory; a relative path is taken from the first allowed directory",
+				},
+				"has_header": map[string]interface{}{
+					"type":        "boolean",
+					"description": "The first line names the columns, which are matched to the table's by name (default true); without a header fields are matched by position",
+				},
+				"create_table": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Create the table if it does not exist (default false)",
+				},
+				"delimiter": map[string]interface{}{
+					"type":        "string",
+					"description": "Field delimiter, a single character or \"tab\" (default \",\")",
+				},
+				"infer_rows": map[string]interface{}{
+					"type":        "integer",
+					"description": "Number of data rows used to infer column types when creating the table (default 100)",
+				},
+			},
+			Required: []string{"table_name", "csv_path"},
+		},
+	}, s.handleImportCSV)
+
+	s.addTool(mcp.Tool{
+		Name:        "export_json",
+		Description: "Write the whole database, its schema and every table's rows, to one JSON document in an allowed directory for systems that do not read SQLite files. Rows are streamed to the file; BLOBs are base64 encoded as {\"base64\": \"...\"}. import_json rebuilds a database from the document",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"directory": map[string]interface{}{
+					"type":        "string",
+					"description": "Allowed directory to write the file to (default: the first allowed directory)",
+				},
+				"file_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the file; .json is added when it has no extension (default: export_<timestamp>.json)",
+				},
+				"overwrite": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace the file if it already exists (default false)",
+				},
+			},
+		},
+	}, s.handleExportJSON)
+
+	s.addTool(mcp.Tool{
+		Name:        "import_json",
+		Description: "Create a new database file from a document written by export_json: tables are created and filled, then indexes, views, and triggers are recreated. The current database is not changed",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"file_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the JSON document",
+				},
+				"directory": map[string]interface{}{
+					"type":        "string",
+					"description": "Allowed directory containing the document (default: the first allowed directory)",
+				},
+				"destination": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of the new database file in an allowed directory; it must not exist. A relative path is taken from the first allowed directory",
+				},
+			},
+			Required: []string{"file_name", "destination"},
+		},
+	}, s.handleImportJSON)
+
+	s.addTool(mcp.Tool{
+		Name:        "enable_audit",
+		Description: "Record every INSERT/UPDATE/DELETE on a table into a companion <table>_audit table with timestamps and old/new values as JSON",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table to audit",
+				},
+				"disable": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Remove the audit triggers instead (the audit table and its history are kept)",
+				},
+			},
+			Required: []string{"table_name"},
+		},
+	}, s.handleEnableAudit)
+
+	s.addTool(mcp.Tool{
+		Name:        "query_audit",
+		Description: "Get audit log entries for a table (see enable_audit), newest first, filtered by time range and operation",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the audited table",
+				},
+				"since": map[string]interface{}{
+					"type":        "string",
+					"description": "Only entries at or after this UTC time (e.g. 2024-01-31 or 2024-01-31T08:00:00)",
+				},
+				"until": map[string]interface{}{
+					"type":        "string",
+					"description": "Only entries at or before this UTC time",
+				},
+				"operation": map[string]interface{}{
+					"type":        "string",
+					"description": "Only entries for this operation",
+					"enum":        []string{"INSERT", "UPDATE", "DELETE"},
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of entries to return (default 100, max 1000)",
+				},
+			},
+			Required: []string{"table_name"},
+		},
+	}, s.handleQueryAudit)
+
+	s.addTool(mcp.Tool{
+		Name:        "set_triggers_enabled",
+		Description: "Temporarily disable all triggers of a table (e.g. during bulk loads) or re-enable them. Disabled trigger definitions are saved in the database until re-enabled",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"enabled": map[string]interface{}{
+					"type":        "boolean",
+					"description": "false to disable the table's triggers, true to restore them",
+				},
+			},
+			Required: []string{"table_name", "enabled"},
+		},
+	}, s.handleSetTriggersEnabled)
+
+	s.addTool(mcp.Tool{
+		Name:        "backfill_column",
+		Description: "Set all NULL values in a column to a default value, e.g. before making the column NOT NULL. Updates run in batches inside a single transaction",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"column": map[string]interface{}{
+					"type":        "string",
+					"description": "Column whose NULL values are filled",
+				},
+				"value": map[string]interface{}{
+					"description": "Value written in place of NULL; must be compatible with the column type",
+				},
+				"batch_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "Rows updated per statement (default 1000)",
+				},
+				"dry_run": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only report how many rows would be updated",
+				},
+			},
+			Required: []string{"table_name", "column", "value"},
+		},
+	}, s.handleBackfillColumn)
+
+	s.addTool(mcp.Tool{
+		Name:        "drop_table",
+		Description: "Drop a table from the database",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table to drop",
+				},
+			},
+			Required: []string{"table_name"},
+		},
+	}, s.handleDropTableTool)
+
+	s.addTool(mcp.Tool{
+		Name:        "create_view",
+		Description: "Create a view from a SELECT query",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"view_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the view to create",
+				},
+				"select_query": map[string]interface{}{
+					"type":        "string",
+					"description": "SELECT query the view is defined by",
+				},
+			},
+			Required: []string{"view_name", "select_query"},
+		},
+	}, s.handleCreateView)
+
+	s.addTool(mcp.Tool{
+		Name:        "list_views",
+		Description: "List all views in the database with their definitions",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handleListViews)
+
+	s.addTool(mcp.Tool{
+		Name:        "drop_view",
+		Description: "Drop a view from the database if it exists",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"view_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the view to drop",
+				},
+			},
+			Required: []string{"view_name"},
+		},
+	}, s.handleDropView)
+
+	s.addTool(mcp.Tool{
+		Name:        "create_fts_table",
+		Description: "Create an FTS5 full-text search table over the given columns",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the FTS5 table to create",
+				},
+				"columns": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": "Names of the text columns to index",
+				},
+				"tokenize": map[string]interface{}{
+					"type":        "string",
+					"description": "FTS5 tokenizer, e.g. 'porter unicode61' (default unicode61)",
+				},
+			},
+			Required: []string{"table_name", "columns"},
+		},
+	}, s.handleCreateFTSTable)
+
+	s.addTool(mcp.Tool{
+		Name:        "search_fts",
+		Description: "Search an FTS5 table with a MATCH query and return the matching rows ranked by bm25() (lower rank is better)",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the FTS5 table to search",
+				},
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "FTS5 MATCH expression, e.g. 'sqlite AND (search OR index)' or '\"exact phrase\"'",
+				},
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of matches to return (default %d)", database.DefaultFTSSearchLimit),
+				},
+			},
+			Required: []string{"table_name", "query"},
+		},
+	}, s.handleSearchFTS)
+
+	s.addTool(mcp.Tool{
+		Name:        "create_index",
+		Description: "Create an index on a table column(s) with advanced options",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"index_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the index to create",
+				},
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"columns": map[string]interface{}{
+					"type":        "array",
+					"description": "Array of column specifications",
+					"items": map[string]interface{}{
+						"type": "object",
+						"properties": map[string]interface{}{
+							"name": map[string]interface{}{
+								"type":        "string",
+								"description": "Column name",
+							},
+							"sort_order": map[string]interface{}{
+								"type":        "string",
+								"description": "Sort order (ASC or DESC)",
+								"enum":        []string{"ASC", "DESC"},
+							},
+						},
+						"required": []string{"name"},
+					},
+				},
+				"unique": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Whether the index should be unique",
+				},
+				"if_not_exists": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Only create index if it doesn't already exist",
+				},
+				"where_clause": map[string]interface{}{
+					"type":        "string",
+					"description": "Optional WHERE clause for partial indexes",
+				},
+			},
+			Required: []string{"index_name", "table_name", "columns"},
+		},
+	}, s.handleCreateIndexTool)
+
+	s.addTool(mcp.Tool{
+		Name:        "list_indexes",
+		Description: "List all indexes for a table",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+			},
+			Required: []string{"table_name"},
+		},
+	}, s.handleListIndexesTool)
+
+	s.addTool(mcp.Tool{
+		Name:        "drop_index",
+		Description: "Drop an index from the database",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"index_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the index to drop",
+				},
+			},
+			Required: []string{"index_name"},
+		},
+	}, s.handleDropIndexTool)
+
+	s.addTool(mcp.Tool{
+		Name:        "vacuum",
+		Description: "Optimize the database by rebuilding it",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handleVacuum)
+
+	s.addTool(mcp.Tool{
+		Name:        "analyze",
+		Description: "Run ANALYZE to refresh the statistics the query planner uses to choose indexes, stored in sqlite_stat1. Run it after bulk loads or large changes; analyze_query then shows the plans SQLite actually picks with up-to-date statistics",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Table or index to analyze (default: the whole database)",
+				},
+			},
+		},
+	}, s.handleAnalyze)
+
+	s.addTool(mcp.Tool{
+		Name:        "reindex",
+		Description: "Rebuild indexes with REINDEX, e.g. after integrity_check reports an index that does not match its table",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Table whose indexes to rebuild, index, or collation whose indexes to rebuild (default: every index)",
+				},
+			},
+		},
+	}, s.handleReindex)
+
+	s.addTool(mcp.Tool{
+		Name:        "optimize",
+		Description: "Run PRAGMA optimize, which analyzes the tables whose planner statistics are missing or out of date and does nothing when they are current. Cheaper than analyze, so suited to running after bulk changes. Lists the statements it ran",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handleOptimize)
+
+	s.addTool(mcp.Tool{
+		Name:        "incremental_vacuum",
+		Description: "Return free pages to the file system with PRAGMA incremental_vacuum, which is quick and does not rewrite the database like vacuum does. Switches auto_vacuum to INCREMENTAL when needed; a database with auto_vacuum NONE must be rebuilt once for that, which only happens with convert. Reports the pages and bytes freed",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"pages": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum number of free pages to release (default: all)",
+				},
+				"convert": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Rebuild a database with auto_vacuum NONE using a full VACUUM to enable incremental vacuum (default false)",
+				},
+			},
+		},
+	}, s.handleIncrementalVacuum)
+
+	s.addTool(mcp.Tool{
+		Name:        "analyze_query",
+		Description: "Analyze the execution plan of a SQL query. Returns the plan as an indented tree like the sqlite3 shell shows, then as JSON: \"plan\" nests each step under its parent and flags full table scans with a warning, \"raw\" lists the steps flat. Each step includes the raw detail plus parsed operation (SCAN/SEARCH), table, index, and whether the index is covering. The planner relies on the statistics gathered by the analyze tool, so run analyze after large data changes for realistic plans",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL query to analyze",
+				},
+			},
+			Required: []string{"query"},
+		},
+	}, s.handleAnalyzeQueryTool)
+
+	s.addTool(mcp.Tool{
+		Name:        "check_affinity",
+		Description: "Check a query, without running it, for comparisons between a column and a literal or parameter of another type, such as a TEXT column compared to a number, which SQLite's type affinity rules may compare differently than intended",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL statement to check",
+				},
+				"params": map[string]interface{}{
+					"type":        "array",
+					"description": "Values bound to the ? placeholders of the query, in order; their types are checked too",
+					"items": map[string]interface{}{
+						"type": []string{"string", "number", "boolean", "null", "object"},
+					},
+				},
+			},
+			Required: []string{"query"},
+		},
+	}, s.handleCheckAffinity)
+
+	s.addTool(mcp.Tool{
+		Name:        "trace_predicate",
+		Description: "Explain why a WHERE clause does or does not select a given row: split the clause on its top-level ANDs and report which conditions the row passes and which it fails",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"key": map[string]interface{}{
+					"type":        []string{"integer", "number", "string"},
+					"description": "Rowid of the row, or its primary key value for a WITHOUT ROWID table",
+				},
+				"where": map[string]interface{}{
+					"type":        "string",
+					"description": "WHERE clause to trace, with or without the WHERE keyword",
+				},
+			},
+			Required: []string{"table_name", "key", "where"},
+		},
+	}, s.handleTracePredicate)
+
+	s.addTool(mcp.Tool{
+		Name:        "snapshot_query",
+		Description: "Run a SELECT query and store its result under a name, replacing an earlier snapshot of that name, so diff_query_result can later report what changed",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the snapshot",
+				},
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SELECT query whose result is stored",
+				},
+				"key_column": map[string]interface{}{
+					"type":        "string",
+					"description": "Result column that identifies a row, unique in the result, used to match rows between snapshots",
+				},
+				"params": map[string]interface{}{
+					"type":        "array",
+					"description": "Values bound to ? placeholders in the query, in order",
+				},
+			},
+			Required: []string{"name", "query", "key_column"},
+		},
+	}, s.handleSnapshotQuery)
+
+	s.addTool(mcp.Tool{
+		Name:        "diff_query_result",
+		Description: "Run the query of a stored snapshot again and report the rows added, removed, and changed (with old and new values) since the snapshot was taken",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the snapshot created with snapshot_query",
+				},
+				"update": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace the snapshot with the current result so the next diff starts from now (default false)",
+				},
+			},
+			Required: []string{"name"},
+		},
+	}, s.handleDiffQueryResult)
+
+	s.addTool(mcp.Tool{
+		Name:        "auto_index",
+		Description: "Find full table scans in a SELECT that filter on columns, suggest an index for each, and with create=true create them and re-analyze the query, reporting the before and after plans. Indexes the new plan does not use are dropped again",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL SELECT query to index for",
+				},
+				"create": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Create the suggested indexes (changes the schema). Without it only the suggestions are returned (default false)",
+				},
+			},
+			Required: []string{"query"},
+		},
+	}, s.handleAutoIndex)
+
+	s.addTool(mcp.Tool{
+		Name:        "suggest_indexes",
+		Description: "Plan a SELECT, list its full table scans, and propose a CREATE INDEX statement for each scan that filters or joins on columns, equality columns first. Nothing is created: copy a statement into execute_statement, or its create_index arguments into create_index. Use auto_index to create and verify the indexes in one step",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"query": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL SELECT query to suggest indexes for",
+				},
+			},
+			Required: []string{"query"},
+		},
+	}, s.handleSuggestIndexes)
+
+	s.addTool(mcp.Tool{
+		Name:        "list_functions",
+		Description: "List the custom SQL functions this server adds to SQLite (e.g. slugify, sha256, levenshtein, REGEXP support) with their usage",
 		InputSchema: mcp.ToolInputSchema{
 			Type:       "object",
 			Properties: map[string]interface{}{},
 		},
-	}, s.handleVacuum)
+	}, s.handleListFunctions)
 
-	s.server.AddTool(mcp.Tool{
-		Name:        "analyze_query",
-		Description: "Analyze the execution plan of a SQL query",
+	s.addTool(mcp.Tool{
+		Name:        "analyze_script",
+		Description: "Review a multi-statement SQL script (e.g. a migration) without running it: returns the query plan of each read and write statement and a note for DDL, so expensive steps stand out",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"script": map[string]interface{}{
+					"type":        "string",
+					"description": "SQL statements separated by semicolons",
+				},
+			},
+			Required: []string{"script"},
+		},
+	}, s.handleAnalyzeScript)
+
+	s.addTool(mcp.Tool{
+		Name:        "benchmark_query",
+		Description: "Run a SELECT query several times and report min/max/mean/median execution time",
 		InputSchema: mcp.ToolInputSchema{
 			Type: "object",
 			Properties: map[string]interface{}{
 				"query": map[string]interface{}{
 					"type":        "string",
-					"description": "SQL query to analyze",
+					"description": "SQL SELECT query to benchmark",
+				},
+				"iterations": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of runs (default 10, max %d)", database.MaxBenchmarkIterations),
+				},
+				"clear_cache": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Release the page cache before every run to measure cold reads",
 				},
 			},
 			Required: []string{"query"},
 		},
-	}, s.handleAnalyzeQueryTool)
+	}, s.handleBenchmarkQuery)
+
+	s.addTool(mcp.Tool{
+		Name:        "estimate_cardinality",
+		Description: "Estimate the number of distinct values in each column of a table, from a random sample or the full table, and flag low-cardinality columns as candidates for faceting and indexing",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Name of the table",
+				},
+				"full_scan": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Count exactly over the whole table instead of sampling (default false)",
+				},
+				"sample_size": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Approximate number of rows to sample (default %d); smaller tables are counted exactly", database.DefaultCardinalitySample),
+				},
+			},
+			Required: []string{"table_name"},
+		},
+	}, s.handleEstimateCardinality)
+
+	s.addTool(mcp.Tool{
+		Name:        "profile_workload",
+		Description: "Profile a workload of representative SELECT queries: plan and time each, then report the slowest queries, the tables most often fully scanned, and a consolidated set of recommended indexes",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"queries": map[string]interface{}{
+					"type":        "array",
+					"items":       map[string]interface{}{"type": "string"},
+					"description": fmt.Sprintf("SELECT queries making up the workload (at most %d)", database.MaxWorkloadQueries),
+				},
+				"iterations": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Number of timed runs per query, of which the median is reported (default 1, max %d)", database.MaxBenchmarkIterations),
+				},
+			},
+			Required: []string{"queries"},
+		},
+	}, s.handleProfileWorkload)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
 		Name:        "database_stats",
 		Description: "Get database statistics and information",
 		InputSchema: mcp.ToolInputSchema{
@@ -322,7 +1656,308 @@ func (s *SQLiteServer) registerHandlers() {
 		},
 	}, s.handleDatabaseStatsTool)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
+		Name:        "get_last_error",
+		Description: "Get details of the most recent failed tool call, including the SQLite result code and extended code (e.g. SQLITE_CONSTRAINT_UNIQUE vs SQLITE_BUSY) for programmatic handling",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handleGetLastError)
+
+	s.addTool(mcp.Tool{
+		Name:        "backup_database",
+		Description: "Write a consistent copy of the current database to a file in an allowed directory using VACUUM INTO, safe while the database is in use and while WAL data is pending",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"destination": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of the backup file, inside an allowed directory; a relative path is taken from the first allowed directory",
+				},
+				"overwrite": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Replace the destination file if it already exists (default false)",
+				},
+			},
+			Required: []string{"destination"},
+		},
+	}, s.handleBackupDatabase)
+
+	s.addTool(mcp.Tool{
+		Name:        "verify_backup",
+		Description: "Confirm that a backup file is a faithful copy of the current database by comparing row counts and per-table checksums of the rows, plus the table, index, view, and trigger definitions; mismatched tables are reported",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"backup_path": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of the backup file, inside an allowed directory; a relative path is taken from the first allowed directory",
+				},
+			},
+			Required: []string{"backup_path"},
+		},
+	}, s.handleVerifyBackup)
+
+	s.addTool(mcp.Tool{
+		Name:        "integrity_check",
+		Description: "Check the current database for corruption with PRAGMA integrity_check (or the faster quick_check) and report ok or the problems found; use after a crash or an interrupted VACUUM",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"quick": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Run PRAGMA quick_check, which skips verifying that indexes match their tables (default false)",
+				},
+				"max_errors": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of problems to report (default %d)", database.DefaultIntegrityErrors),
+				},
+			},
+		},
+	}, s.handleIntegrityCheck)
+
+	s.addTool(mcp.Tool{
+		Name:        "list_foreign_keys",
+		Description: "List the foreign keys of a table, or of every table, from PRAGMA foreign_key_list: the child columns, the referenced table and columns, and the ON UPDATE/ON DELETE actions",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Table whose foreign keys to list (default: every table with foreign keys)",
+				},
+			},
+		},
+	}, s.handleListForeignKeys)
+
+	s.addTool(mcp.Tool{
+		Name:        "check_foreign_keys",
+		Description: "Find rows that break referential integrity with PRAGMA foreign_key_check, such as rows imported while foreign key enforcement was off, reporting each row's table, rowid, referenced table, and foreign key",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"table_name": map[string]interface{}{
+					"type":        "string",
+					"description": "Table to check (default: every table)",
+				},
+				"max_violations": map[string]interface{}{
+					"type":        "integer",
+					"description": fmt.Sprintf("Maximum number of violating rows to report (default %d); all are counted", database.DefaultForeignKeyViolations),
+				},
+			},
+		},
+	}, s.handleCheckForeignKeys)
+
+	s.addTool(mcp.Tool{
+		Name:        "recover_database",
+		Description: "Salvage a damaged database by dump and reload: run quick_check, recreate the schema in a new file, copy every row that can still be read while skipping unreadable ranges, and report what could not be recovered. The current database is not modified",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"destination": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of the new database file, inside an allowed directory; it must not exist. A relative path is taken from the first allowed directory",
+				},
+			},
+			Required: []string{"destination"},
+		},
+	}, s.handleRecoverDatabase)
+
+	s.addTool(mcp.Tool{
+		Name:        "maintenance_schedule",
+		Description: "Show the background maintenance tasks (VACUUM, ANALYZE, WAL checkpoint) configured with the --auto-*-interval options, with their intervals, last and next run times, and last errors",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handleMaintenanceSchedule)
+
+	s.addTool(mcp.Tool{
+		Name:        "set_foreign_keys",
+		Description: "Turn enforcement of foreign key constraints (PRAGMA foreign_keys) on or off for all connections. Enforcement is on by default",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"enabled": map[string]interface{}{
+					"type":        "boolean",
+					"description": "true to reject writes that break foreign keys, false to allow them",
+				},
+			},
+			Required: []string{"enabled"},
+		},
+	}, s.handleSetForeignKeys)
+
+	s.addTool(mcp.Tool{
+		Name:        "set_journal_mode",
+		Description: "Set PRAGMA journal_mode and return the mode now in effect. WAL, the default, lets reads run while a write is in progress and keeps -wal and -shm sidecar files next to the database",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"mode": map[string]interface{}{
+					"type":        "string",
+					"enum":        database.JournalModes,
+					"description": "Journal mode: DELETE, TRUNCATE, PERSIST, MEMORY, WAL, or OFF",
+				},
+			},
+			Required: []string{"mode"},
+		},
+	}, s.handleSetJournalMode)
+
+	s.addTool(mcp.Tool{
+		Name:        "set_journal_size_limit",
+		Description: "Set PRAGMA journal_size_limit so the WAL or rollback journal file is truncated back to this size after checkpoints, preventing unbounded disk usage",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"limit": map[string]interface{}{
+					"type":        "integer",
+					"description": "Maximum journal size in bytes to keep after a checkpoint or transaction; -1 removes the limit",
+				},
+			},
+			Required: []string{"limit"},
+		},
+	}, s.handleSetJournalSizeLimit)
+
+	s.addTool(mcp.Tool{
+		Name:        "temp_storage",
+		Description: "Show or set where SQLite keeps temporary data such as sort and join spill files: PRAGMA temp_store (DEFAULT, FILE, MEMORY) and the temp file directory. Call without arguments to show the current settings",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"temp_store": map[string]interface{}{
+					"type":        "string",
+					"description": "Where temporary tables and indices are stored",
+					"enum":        []string{"DEFAULT", "FILE", "MEMORY"},
+				},
+				"temp_directory": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory for temporary files, or \"default\" to restore SQLite's choice (applies to the whole server process)",
+				},
+			},
+		},
+	}, s.handleTempStorage)
+
+	s.addTool(mcp.Tool{
+		Name:        "threading_mode",
+		Description: "Show SQLite's threading mode and whether the connection pool is serialized to a single connection, or switch serialization on or off. Serialized access makes concurrent writes wait for each other instead of failing with \"database is locked\" and is the safe choice for write-heavy workloads. Call without arguments to show the current settings",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"serialized": map[string]interface{}{
+					"type":        "boolean",
+					"description": "true to use a single connection for all statements, false to allow a pool of connections",
+				},
+			},
+		},
+	}, s.handleThreadingMode)
+
+	s.addTool(mcp.Tool{
+		Name:        "set_page_size",
+		Description: "Change the database page size. The database is rebuilt with VACUUM to apply it, which rewrites the whole file and can take a long time on large databases. A database in WAL mode leaves it for the rebuild and returns to it afterwards",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"page_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "New page size in bytes, a power of two from 512 to 65536",
+				},
+			},
+			Required: []string{"page_size"},
+		},
+	}, s.handleSetPageSize)
+
+	s.addTool(mcp.Tool{
+		Name:        "get_user_version",
+		Description: "Read PRAGMA user_version, the integer in the database header that migration tools use to record the applied schema version",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handleGetUserVersion)
+
+	s.addTool(mcp.Tool{
+		Name:        "set_user_version",
+		Description: "Set PRAGMA user_version, e.g. after applying a migration, and report the previous value",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"version": map[string]interface{}{
+					"type":        "integer",
+					"description": "New user version, an integer from 0 to 2147483647",
+				},
+			},
+			Required: []string{"version"},
+		},
+	}, s.handleSetUserVersion)
+
+	s.addTool(mcp.Tool{
+		Name:        "apply_migrations",
+		Description: "Apply the pending schema migrations of a directory of .sql files named with a version prefix, e.g. 001_init.sql. Migrations whose version exceeds PRAGMA user_version run in version order, each in its own transaction that also sets user_version to its version. Stops at the first failing migration and reports it with the ones applied before it. Files must not contain BEGIN or COMMIT",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"directory": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory of migration files, inside an allowed directory",
+				},
+			},
+			Required: []string{"directory"},
+		},
+	}, s.handleApplyMigrations)
+
+	s.addTool(mcp.Tool{
+		Name:        "migration_status",
+		Description: "List the migrations of a directory of versioned .sql files as applied (version up to PRAGMA user_version) or pending",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"directory": map[string]interface{}{
+					"type":        "string",
+					"description": "Directory of migration files, inside an allowed directory",
+				},
+			},
+			Required: []string{"directory"},
+		},
+	}, s.handleMigrationStatus)
+
+	s.addTool(mcp.Tool{
+		Name:        "storage_breakdown",
+		Description: "Show how many pages and bytes each table and index uses, largest first. Uses the dbstat virtual table when available, otherwise estimates from row counts",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handleStorageBreakdown)
+
+	s.addTool(mcp.Tool{
+		Name:        "pool_stats",
+		Description: "Get connection pool statistics (open, in-use, idle, waits) and SQLite page counters",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handlePoolStats)
+
+	s.addTool(mcp.Tool{
+		Name:        "cache_stats",
+		Description: "Report PRAGMA cache_size, mmap_size, and how much of the database fits in the page cache, optionally changing cache_size or mmap_size on every connection",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"cache_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "New cache size: a number of pages, or the size in KiB when negative (e.g. -65536 for 64 MiB)",
+				},
+				"mmap_size": map[string]interface{}{
+					"type":        "integer",
+					"description": "New memory-mapped I/O size in bytes; 0 disables mmap",
+				},
+			},
+		},
+	}, s.handleCacheStats)
+
+	s.addTool(mcp.Tool{
 		Name:        "create_database",
 		Description: "Create a new SQLite database file with an AI-generated name in the specified directory",
 		InputSchema: mcp.ToolInputSchema{
@@ -345,7 +1980,22 @@ func (s *SQLiteServer) registerHandlers() {
 		},
 	}, s.handleCreateDatabase)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
+		Name:        "quote_identifier",
+		Description: "Quote a table, column, or index name as an SQLite identifier, escaping embedded double quotes, for building SQL safely; also reports whether the name is a keyword or would need quoting anyway",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"name": map[string]interface{}{
+					"type":        "string",
+					"description": "The identifier to quote, exactly as it should be spelled",
+				},
+			},
+			Required: []string{"name"},
+		},
+	}, s.handleQuoteIdentifier)
+
+	s.addTool(mcp.Tool{
 		Name:        "database_exists",
 		Description: "Check if a database file exists and is valid in allowed directories",
 		InputSchema: mcp.ToolInputSchema{
@@ -360,7 +2010,7 @@ func (s *SQLiteServer) registerHandlers() {
 		},
 	}, s.handleDatabaseExists)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
 		Name:        "switch_database",
 		Description: "Switch to a different SQLite database file in allowed directories",
 		InputSchema: mcp.ToolInputSchema{
@@ -375,7 +2025,82 @@ func (s *SQLiteServer) registerHandlers() {
 		},
 	}, s.handleSwitchDatabase)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
+		Name:        "attach_database",
+		Description: "Attach another database file under an alias so queries can join across files, referring to its tables as alias.table. Attachments are dropped when switching databases",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"db_path": map[string]interface{}{
+					"type":        "string",
+					"description": "Path of the database file to attach, inside an allowed directory",
+				},
+				"alias": map[string]interface{}{
+					"type":        "string",
+					"description": "Schema name for the attached database: letters, digits, and underscores",
+				},
+			},
+			Required: []string{"db_path", "alias"},
+		},
+	}, s.handleAttachDatabase)
+
+	s.addTool(mcp.Tool{
+		Name:        "detach_database",
+		Description: "Detach a database attached with attach_database",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"alias": map[string]interface{}{
+					"type":        "string",
+					"description": "Alias the database was attached under",
+				},
+			},
+			Required: []string{"alias"},
+		},
+	}, s.handleDetachDatabase)
+
+	s.addTool(mcp.Tool{
+		Name:        "create_scratch",
+		Description: "Attach a new, empty in-memory database as scratch space for intermediate tables in multi-step analysis. Create tables there as alias.table_name and join them with the main database; nothing in it is ever written to disk",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"alias": map[string]interface{}{
+					"type":        "string",
+					"description": "Schema name for the scratch database: letters, digits, and underscores (default \"scratch\")",
+				},
+				"keep_on_switch": map[string]interface{}{
+					"type":        "boolean",
+					"description": "Keep the scratch database and its tables attached after switch_database (default false, it is discarded)",
+				},
+			},
+		},
+	}, s.handleCreateScratch)
+
+	s.addTool(mcp.Tool{
+		Name:        "drop_scratch",
+		Description: "Detach a scratch database created with create_scratch and discard its tables",
+		InputSchema: mcp.ToolInputSchema{
+			Type: "object",
+			Properties: map[string]interface{}{
+				"alias": map[string]interface{}{
+					"type":        "string",
+					"description": "Alias of the scratch database (default \"scratch\")",
+				},
+			},
+		},
+	}, s.handleDropScratch)
+
+	s.addTool(mcp.Tool{
+		Name:        "list_scratch",
+		Description: "List the scratch databases created with create_scratch and the tables in each",
+		InputSchema: mcp.ToolInputSchema{
+			Type:       "object",
+			Properties: map[string]interface{}{},
+		},
+	}, s.handleListScratch)
+
+	s.addTool(mcp.Tool{
 		Name:        "current_database",
 		Description: "Show the currently connected database file path",
 		InputSchema: mcp.ToolInputSchema{
@@ -384,7 +2109,7 @@ func (s *SQLiteServer) registerHandlers() {
 		},
 	}, s.handleCurrentDatabase)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
 		Name:        "list_database_files",
 		Description: "List all SQLite database files in a directory",
 		InputSchema: mcp.ToolInputSchema{
@@ -398,7 +2123,7 @@ func (s *SQLiteServer) registerHandlers() {
 		},
 	}, s.handleListDatabaseFiles)
 
-	s.server.AddTool(mcp.Tool{
+	s.addTool(mcp.Tool{
 		Name:        "delete_database",
 		Description: "Delete a SQLite database file from allowed directories (CAUTION: This permanently deletes the file)",
 		InputSchema: mcp.ToolInputSchema{
@@ -425,6 +2150,7 @@ func (s *SQLiteServer) Start() error {
 
 // Close closes the server and database connection
 func (s *SQLiteServer) Close() error {
+	s.stopMaintenance()
 	if s.db != nil {
 		return s.db.Close()
 	}
@@ -462,7 +2188,8 @@ func (s *SQLiteServer) handleCreateTableTool(ctx context.Context, request mcp.Ca
 
 // handleListTablesTool handles list tables tool
 func (s *SQLiteServer) handleListTablesTool(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-	return s.handleListTables(ctx)
+	args, _ := request.Params.Arguments.(map[string]interface{})
+	return s.handleListTables(ctx, args)
 }
 
 // handleDescribeTableTool handles describe table tool
@@ -0,0 +1,147 @@
+package server
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// defaultStreamBatchSize is the number of rows query_stream returns per call by default
+const defaultStreamBatchSize = 1000
+
+// streamCursor is the state carried by a query_stream continuation token
+type streamCursor struct {
+	Database string      `json:"db"`
+	Query    string      `json:"query"`
+	Params   interface{} `json:"params,omitempty"`
+	Offset   int         `json:"offset"`
+	Cursor   string      `json:"cursor,omitempty"`
+}
+
+// encode turns the cursor into an opaque continuation token
+func (c streamCursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeStreamCursor reads a continuation token written by streamCursor.encode
+func decodeStreamCursor(token string) (*streamCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid continuation_token")
+	}
+	var cursor streamCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.Query == "" || cursor.Offset < 0 {
+		return nil, fmt.Errorf("invalid continuation_token")
+	}
+	return &cursor, nil
+}
+
+// handleQueryStream handles query stream requests. Memory use is bounded by batch_size no
+// matter how large the result is. Between calls the query is kept open at the next row where
+// the database allows it, see database.ReadBatch, so each row is read once; otherwise the
+// next call runs the query again and steps past the rows of earlier batches.
+func (s *SQLiteServer) handleQueryStream(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	db := s.db.WithContext(ctx)
+	args, ok := request.Params.Arguments.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid arguments type")
+	}
+
+	query, _ := args["query"].(string)
+	token, _ := args["continuation_token"].(string)
+	var cursor *streamCursor
+	switch {
+	case token != "" && query != "":
+		return nil, fmt.Errorf("pass either query or continuation_token, not both")
+	case token != "":
+		var err error
+		if cursor, err = decodeStreamCursor(token); err != nil {
+			return nil, err
+		}
+		if cursor.Database != db.GetCurrentDatabasePath() {
+			return nil, fmt.Errorf("the continuation_token belongs to database '%s'; the current database is '%s'", cursor.Database, db.GetCurrentDatabasePath())
+		}
+	case query != "":
+		cursor = &streamCursor{Database: db.GetCurrentDatabasePath(), Query: query, Params: args["params"]}
+	default:
+		return nil, fmt.Errorf("query or continuation_token is required")
+	}
+
+	// A token is written by the client as much as a query is, so both are checked
+	if err := db.CheckReadQuery(cursor.Query); err != nil {
+		return nil, err
+	}
+
+	params, err := parseParams(cursor.Params)
+	if err != nil {
+		return nil, err
+	}
+	// A token carries its query past the table access check on the arguments
+	if token != "" {
+		if err := s.checkStatementAccess(cursor.Query, params); err != nil {
+			return nil, err
+		}
+	}
+
+	batchSize := defaultStreamBatchSize
+	if size, ok := args["batch_size"].(float64); ok {
+		if size < 1 {
+			return nil, fmt.Errorf("batch_size must be at least 1")
+		}
+		batchSize = int(size)
+	}
+
+	result, err := db.ReadBatch(ctx, cursor.Cursor, cursor.Query, cursor.Offset, batchSize, params...)
+	if err != nil {
+		return nil, err
+	}
+	batch, columns, more := result.Rows, result.Columns, result.More
+
+	batch, truncationNote := limitResults(batch, s.maxRows, s.maxCells)
+	more = more || truncationNote != ""
+	redactColumns(batch, s.redactedColumns(cursor.Query, params, batch))
+
+	output := map[string]interface{}{
+		"columns": columns,
+		"rows":    batch,
+	}
+	var summary string
+	if len(batch) == 0 {
+		summary = fmt.Sprintf("No rows after row %d", cursor.Offset)
+	} else {
+		summary = fmt.Sprintf("Returned rows %d–%d", cursor.Offset+1, cursor.Offset+len(batch))
+	}
+	if more {
+		next := *cursor
+		next.Offset += len(batch)
+		next.Cursor = ""
+		if truncationNote == "" {
+			next.Cursor = result.Cursor
+		}
+		output["continuation_token"] = next.encode()
+		summary += "; more rows follow, pass continuation_token to fetch the next batch"
+	} else {
+		summary += "; this is the last batch"
+	}
+	if truncationNote != "" {
+		summary += "; " + truncationNote
+	}
+
+	jsonResult, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to format results: %w", err)
+	}
+
+	return &mcp.CallToolResult{
+		Content: []mcp.Content{
+			mcp.TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("[Database: %s]\n%s:\n%s", db.GetCurrentDatabasePath(), summary, string(jsonResult)),
+			},
+		},
+	}, nil
+}
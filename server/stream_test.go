@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"regexp"
+	"testing"
+)
+
+// continuationToken returns the continuation_token of a query_stream result, or ""
+func continuationToken(text string) string {
+	match := regexp.MustCompile(`"continuation_token": "([^"]+)"`).FindStringSubmatch(text)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+func TestQueryStreamPages(t *testing.T) {
+	srv := newTestServer(t,
+		"CREATE TABLE t2 (id INTEGER PRIMARY KEY)",
+		"WITH RECURSIVE n(i) AS (SELECT 1 UNION ALL SELECT i + 1 FROM n WHERE i < 2500) INSERT INTO t2 SELECT i FROM n",
+	)
+	text := mustCall(t, srv, "query_stream", map[string]interface{}{"query": "SELECT id FROM t2 ORDER BY id", "batch_size": 1000})
+	calls := 1
+	for token := continuationToken(text); token != ""; token = continuationToken(text) {
+		text = mustCall(t, srv, "query_stream", map[string]interface{}{"continuation_token": token})
+		calls++
+	}
+	if calls != 3 {
+		t.Fatalf("read 2500 rows in %d calls, want 3", calls)
+	}
+	if !regexp.MustCompile(`Returned rows 2001–2500; this is the last batch`).MatchString(text) {
+		t.Fatalf("unexpected last batch: %s", text)
+	}
+}
+
+func TestQueryStreamRejectsWrites(t *testing.T) {
+	srv := newTestServer(t, "CREATE TABLE t2 (id INTEGER)")
+
+	for _, query := range []string{
+		"SELECT 1; DROP TABLE t2",
+		"DELETE FROM t2",
+		"WITH x AS (SELECT 1) DELETE FROM t2",
+	} {
+		if _, err := callTool(t, srv, "query_stream", map[string]interface{}{"query": query}); err == nil {
+			t.Errorf("query_stream accepted %q", query)
+		}
+
+		// A token is decoded from client input, so a crafted one must be checked as well
+		token := streamCursor{Database: srv.db.GetCurrentDatabasePath(), Query: query}.encode()
+		if _, err := callTool(t, srv, "query_stream", map[string]interface{}{"continuation_token": token}); err == nil {
+			t.Errorf("query_stream accepted a token carrying %q", query)
+		}
+	}
+	if !tableExists(t, srv, "t2") {
+		t.Fatal("t2 was dropped")
+	}
+}
+
+func TestDecodeStreamCursor(t *testing.T) {
+	cursor := streamCursor{Database: "a.db", Query: "SELECT 1", Offset: 5, Cursor: "abc"}
+	decoded, err := decodeStreamCursor(cursor.encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := json.Marshal(decoded); string(got) != `{"db":"a.db","query":"SELECT 1","offset":5,"cursor":"abc"}` {
+		t.Fatalf("decoded %s", got)
+	}
+	for _, token := range []string{"!", streamCursor{Query: "SELECT 1", Offset: -1}.encode(), streamCursor{}.encode()} {
+		if _, err := decodeStreamCursor(token); err == nil {
+			t.Errorf("accepted token %q", token)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"testing"
+)
+
+// listTools returns the sorted names of the tools a client is offered
+func listTools(t *testing.T, srv *SQLiteServer) []string {
+	t.Helper()
+	request := []byte(`{"jsonrpc": "2.0", "id": 1, "method": "tools/list"}`)
+	encoded, err := json.Marshal(srv.server.HandleMessage(context.Background(), request))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response struct {
+		Result struct {
+			Tools []struct {
+				Name string `json:"name"`
+			} `json:"tools"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(encoded, &response); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, tool := range response.Result.Tools {
+		names = append(names, tool.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestToolFilterDisable(t *testing.T) {
+	srv := newTestServer(t)
+	all := listTools(t, srv)
+	if !contains(all, "execute") || !contains(all, "drop_table") {
+		t.Fatalf("default tools %v", all)
+	}
+
+	if err := srv.SetToolFilter(nil, []string{"execute", "drop_table"}); err != nil {
+		t.Fatal(err)
+	}
+	tools := listTools(t, srv)
+	if contains(tools, "execute") || contains(tools, "drop_table") || len(tools) != len(all)-2 {
+		t.Fatalf("after disabling execute and drop_table the tools are %v", tools)
+	}
+	if _, err := callTool(t, srv, "execute", map[string]interface{}{"statement": "CREATE TABLE x (id INTEGER)"}); err == nil {
+		t.Fatal("a disabled tool can still be called")
+	}
+}
+
+func TestToolFilterEnableOnly(t *testing.T) {
+	srv := newTestServer(t)
+	if err := srv.SetToolFilter([]string{"query", "list_tables"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if tools := listTools(t, srv); len(tools) != 2 || tools[0] != "list_tables" || tools[1] != "query" {
+		t.Fatalf("enable-only mode offers %v", tools)
+	}
+	// A tool registered later is filtered as well
+	srv.SetAllowRaw(true)
+	if tools := listTools(t, srv); contains(tools, "raw_exec") {
+		t.Fatal("raw_exec is offered although it is not enabled")
+	}
+
+	if err := srv.SetToolFilter([]string{"no_such_tool"}, nil); err == nil {
+		t.Fatal("accepted an unknown tool name")
+	}
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Transports lists the values accepted by the --transport option
+var Transports = []string{"stdio", "sse"}
+
+// sseShutdownTimeout bounds how long StartSSE waits for calls in progress when stopping
+const sseShutdownTimeout = 10 * time.Second
+
+// StartSSE serves MCP over HTTP with Server-Sent Events on addr, clients connecting to /sse
+// and posting messages to /message, until SIGINT or SIGTERM. Every client shares this server:
+// the current database, attachments, and settings changed by one client apply to all. The
+// endpoints have no authentication, so anyone who can reach addr can run every offered tool.
+func (s *SQLiteServer) StartSSE(addr string) error {
+	sse := server.NewSSEServer(s.server)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signals)
+
+	failed := make(chan error, 1)
+	go func() {
+		failed <- sse.Start(addr)
+	}()
+
+	select {
+	case err := <-failed:
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return fmt.Errorf("failed to serve on %s: %w", addr, err)
+	case <-signals:
+		ctx, cancel := context.WithTimeout(context.Background(), sseShutdownTimeout)
+		defer cancel()
+		return sse.Shutdown(ctx)
+	}
+}